@@ -0,0 +1,776 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: rprompt.proto
+
+// rprompt.v1 serves a prompt registry to other services. Templates are named by their registry path, e.g.
+// "support/reply.tmpl", and configs are passed as JSON-like structs.
+
+package rpromptpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ListRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRequest) Reset() {
+	*x = ListRequest{}
+	mi := &file_rprompt_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRequest) ProtoMessage() {}
+
+func (x *ListRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rprompt_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRequest.ProtoReflect.Descriptor instead.
+func (*ListRequest) Descriptor() ([]byte, []int) {
+	return file_rprompt_proto_rawDescGZIP(), []int{0}
+}
+
+type ListResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Templates     []string               `protobuf:"bytes,1,rep,name=templates,proto3" json:"templates,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListResponse) Reset() {
+	*x = ListResponse{}
+	mi := &file_rprompt_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListResponse) ProtoMessage() {}
+
+func (x *ListResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rprompt_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListResponse.ProtoReflect.Descriptor instead.
+func (*ListResponse) Descriptor() ([]byte, []int) {
+	return file_rprompt_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListResponse) GetTemplates() []string {
+	if x != nil {
+		return x.Templates
+	}
+	return nil
+}
+
+type GenerateConfigRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Template      string                 `protobuf:"bytes,1,opt,name=template,proto3" json:"template,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GenerateConfigRequest) Reset() {
+	*x = GenerateConfigRequest{}
+	mi := &file_rprompt_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GenerateConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GenerateConfigRequest) ProtoMessage() {}
+
+func (x *GenerateConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rprompt_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GenerateConfigRequest.ProtoReflect.Descriptor instead.
+func (*GenerateConfigRequest) Descriptor() ([]byte, []int) {
+	return file_rprompt_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GenerateConfigRequest) GetTemplate() string {
+	if x != nil {
+		return x.Template
+	}
+	return ""
+}
+
+type GenerateConfigResponse struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Config *structpb.Struct       `protobuf:"bytes,1,opt,name=config,proto3" json:"config,omitempty"`
+	// Dotted paths of every variable the template closure references.
+	Variables     []string `protobuf:"bytes,2,rep,name=variables,proto3" json:"variables,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GenerateConfigResponse) Reset() {
+	*x = GenerateConfigResponse{}
+	mi := &file_rprompt_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GenerateConfigResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GenerateConfigResponse) ProtoMessage() {}
+
+func (x *GenerateConfigResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rprompt_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GenerateConfigResponse.ProtoReflect.Descriptor instead.
+func (*GenerateConfigResponse) Descriptor() ([]byte, []int) {
+	return file_rprompt_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GenerateConfigResponse) GetConfig() *structpb.Struct {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+func (x *GenerateConfigResponse) GetVariables() []string {
+	if x != nil {
+		return x.Variables
+	}
+	return nil
+}
+
+type RenderRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Template string                 `protobuf:"bytes,1,opt,name=template,proto3" json:"template,omitempty"`
+	// Registry config the values are layered over. Optional.
+	ConfigPath string           `protobuf:"bytes,2,opt,name=config_path,json=configPath,proto3" json:"config_path,omitempty"`
+	Values     *structpb.Struct `protobuf:"bytes,3,opt,name=values,proto3" json:"values,omitempty"`
+	// Validation mode: off, warn or strict. Empty uses the server's default.
+	Validation string `protobuf:"bytes,4,opt,name=validation,proto3" json:"validation,omitempty"`
+	// Fill RenderResponse.messages with a message per [[role]] section.
+	Messages      bool `protobuf:"varint,5,opt,name=messages,proto3" json:"messages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenderRequest) Reset() {
+	*x = RenderRequest{}
+	mi := &file_rprompt_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenderRequest) ProtoMessage() {}
+
+func (x *RenderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rprompt_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenderRequest.ProtoReflect.Descriptor instead.
+func (*RenderRequest) Descriptor() ([]byte, []int) {
+	return file_rprompt_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RenderRequest) GetTemplate() string {
+	if x != nil {
+		return x.Template
+	}
+	return ""
+}
+
+func (x *RenderRequest) GetConfigPath() string {
+	if x != nil {
+		return x.ConfigPath
+	}
+	return ""
+}
+
+func (x *RenderRequest) GetValues() *structpb.Struct {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+func (x *RenderRequest) GetValidation() string {
+	if x != nil {
+		return x.Validation
+	}
+	return ""
+}
+
+func (x *RenderRequest) GetMessages() bool {
+	if x != nil {
+		return x.Messages
+	}
+	return false
+}
+
+type Message struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Role          string                 `protobuf:"bytes,1,opt,name=role,proto3" json:"role,omitempty"`
+	Content       string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Message) Reset() {
+	*x = Message{}
+	mi := &file_rprompt_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Message) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Message) ProtoMessage() {}
+
+func (x *Message) ProtoReflect() protoreflect.Message {
+	mi := &file_rprompt_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Message.ProtoReflect.Descriptor instead.
+func (*Message) Descriptor() ([]byte, []int) {
+	return file_rprompt_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Message) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+func (x *Message) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+type ValidationIssue struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// One of missing, unused or type_mismatch.
+	Kind          string `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Path          string `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	Message       string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValidationIssue) Reset() {
+	*x = ValidationIssue{}
+	mi := &file_rprompt_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValidationIssue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValidationIssue) ProtoMessage() {}
+
+func (x *ValidationIssue) ProtoReflect() protoreflect.Message {
+	mi := &file_rprompt_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValidationIssue.ProtoReflect.Descriptor instead.
+func (*ValidationIssue) Descriptor() ([]byte, []int) {
+	return file_rprompt_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ValidationIssue) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *ValidationIssue) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *ValidationIssue) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type RenderResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Output        string                 `protobuf:"bytes,1,opt,name=output,proto3" json:"output,omitempty"`
+	Dependencies  []string               `protobuf:"bytes,2,rep,name=dependencies,proto3" json:"dependencies,omitempty"`
+	Variables     []string               `protobuf:"bytes,3,rep,name=variables,proto3" json:"variables,omitempty"`
+	UnusedKeys    []string               `protobuf:"bytes,4,rep,name=unused_keys,json=unusedKeys,proto3" json:"unused_keys,omitempty"`
+	Duration      *durationpb.Duration   `protobuf:"bytes,5,opt,name=duration,proto3" json:"duration,omitempty"`
+	Tokens        int64                  `protobuf:"varint,6,opt,name=tokens,proto3" json:"tokens,omitempty"`
+	Issues        []*ValidationIssue     `protobuf:"bytes,7,rep,name=issues,proto3" json:"issues,omitempty"`
+	Messages      []*Message             `protobuf:"bytes,8,rep,name=messages,proto3" json:"messages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenderResponse) Reset() {
+	*x = RenderResponse{}
+	mi := &file_rprompt_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenderResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenderResponse) ProtoMessage() {}
+
+func (x *RenderResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rprompt_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenderResponse.ProtoReflect.Descriptor instead.
+func (*RenderResponse) Descriptor() ([]byte, []int) {
+	return file_rprompt_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RenderResponse) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *RenderResponse) GetDependencies() []string {
+	if x != nil {
+		return x.Dependencies
+	}
+	return nil
+}
+
+func (x *RenderResponse) GetVariables() []string {
+	if x != nil {
+		return x.Variables
+	}
+	return nil
+}
+
+func (x *RenderResponse) GetUnusedKeys() []string {
+	if x != nil {
+		return x.UnusedKeys
+	}
+	return nil
+}
+
+func (x *RenderResponse) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+func (x *RenderResponse) GetTokens() int64 {
+	if x != nil {
+		return x.Tokens
+	}
+	return 0
+}
+
+func (x *RenderResponse) GetIssues() []*ValidationIssue {
+	if x != nil {
+		return x.Issues
+	}
+	return nil
+}
+
+func (x *RenderResponse) GetMessages() []*Message {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+type RenderChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Text          string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenderChunk) Reset() {
+	*x = RenderChunk{}
+	mi := &file_rprompt_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenderChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenderChunk) ProtoMessage() {}
+
+func (x *RenderChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_rprompt_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenderChunk.ProtoReflect.Descriptor instead.
+func (*RenderChunk) Descriptor() ([]byte, []int) {
+	return file_rprompt_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *RenderChunk) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+type ValidateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Template      string                 `protobuf:"bytes,1,opt,name=template,proto3" json:"template,omitempty"`
+	Values        *structpb.Struct       `protobuf:"bytes,2,opt,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValidateRequest) Reset() {
+	*x = ValidateRequest{}
+	mi := &file_rprompt_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValidateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValidateRequest) ProtoMessage() {}
+
+func (x *ValidateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rprompt_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValidateRequest.ProtoReflect.Descriptor instead.
+func (*ValidateRequest) Descriptor() ([]byte, []int) {
+	return file_rprompt_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ValidateRequest) GetTemplate() string {
+	if x != nil {
+		return x.Template
+	}
+	return ""
+}
+
+func (x *ValidateRequest) GetValues() *structpb.Struct {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type ValidateResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Valid         bool                   `protobuf:"varint,1,opt,name=valid,proto3" json:"valid,omitempty"`
+	Issues        []*ValidationIssue     `protobuf:"bytes,2,rep,name=issues,proto3" json:"issues,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValidateResponse) Reset() {
+	*x = ValidateResponse{}
+	mi := &file_rprompt_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValidateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValidateResponse) ProtoMessage() {}
+
+func (x *ValidateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rprompt_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValidateResponse.ProtoReflect.Descriptor instead.
+func (*ValidateResponse) Descriptor() ([]byte, []int) {
+	return file_rprompt_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ValidateResponse) GetValid() bool {
+	if x != nil {
+		return x.Valid
+	}
+	return false
+}
+
+func (x *ValidateResponse) GetIssues() []*ValidationIssue {
+	if x != nil {
+		return x.Issues
+	}
+	return nil
+}
+
+var File_rprompt_proto protoreflect.FileDescriptor
+
+const file_rprompt_proto_rawDesc = "" +
+	"\n" +
+	"\rrprompt.proto\x12\n" +
+	"rprompt.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1cgoogle/protobuf/struct.proto\"\r\n" +
+	"\vListRequest\",\n" +
+	"\fListResponse\x12\x1c\n" +
+	"\ttemplates\x18\x01 \x03(\tR\ttemplates\"3\n" +
+	"\x15GenerateConfigRequest\x12\x1a\n" +
+	"\btemplate\x18\x01 \x01(\tR\btemplate\"g\n" +
+	"\x16GenerateConfigResponse\x12/\n" +
+	"\x06config\x18\x01 \x01(\v2\x17.google.protobuf.StructR\x06config\x12\x1c\n" +
+	"\tvariables\x18\x02 \x03(\tR\tvariables\"\xb9\x01\n" +
+	"\rRenderRequest\x12\x1a\n" +
+	"\btemplate\x18\x01 \x01(\tR\btemplate\x12\x1f\n" +
+	"\vconfig_path\x18\x02 \x01(\tR\n" +
+	"configPath\x12/\n" +
+	"\x06values\x18\x03 \x01(\v2\x17.google.protobuf.StructR\x06values\x12\x1e\n" +
+	"\n" +
+	"validation\x18\x04 \x01(\tR\n" +
+	"validation\x12\x1a\n" +
+	"\bmessages\x18\x05 \x01(\bR\bmessages\"7\n" +
+	"\aMessage\x12\x12\n" +
+	"\x04role\x18\x01 \x01(\tR\x04role\x12\x18\n" +
+	"\acontent\x18\x02 \x01(\tR\acontent\"S\n" +
+	"\x0fValidationIssue\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"\xc0\x02\n" +
+	"\x0eRenderResponse\x12\x16\n" +
+	"\x06output\x18\x01 \x01(\tR\x06output\x12\"\n" +
+	"\fdependencies\x18\x02 \x03(\tR\fdependencies\x12\x1c\n" +
+	"\tvariables\x18\x03 \x03(\tR\tvariables\x12\x1f\n" +
+	"\vunused_keys\x18\x04 \x03(\tR\n" +
+	"unusedKeys\x125\n" +
+	"\bduration\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\bduration\x12\x16\n" +
+	"\x06tokens\x18\x06 \x01(\x03R\x06tokens\x123\n" +
+	"\x06issues\x18\a \x03(\v2\x1b.rprompt.v1.ValidationIssueR\x06issues\x12/\n" +
+	"\bmessages\x18\b \x03(\v2\x13.rprompt.v1.MessageR\bmessages\"!\n" +
+	"\vRenderChunk\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\"^\n" +
+	"\x0fValidateRequest\x12\x1a\n" +
+	"\btemplate\x18\x01 \x01(\tR\btemplate\x12/\n" +
+	"\x06values\x18\x02 \x01(\v2\x17.google.protobuf.StructR\x06values\"]\n" +
+	"\x10ValidateResponse\x12\x14\n" +
+	"\x05valid\x18\x01 \x01(\bR\x05valid\x123\n" +
+	"\x06issues\x18\x02 \x03(\v2\x1b.rprompt.v1.ValidationIssueR\x06issues2\xf1\x02\n" +
+	"\rPromptService\x129\n" +
+	"\x04List\x12\x17.rprompt.v1.ListRequest\x1a\x18.rprompt.v1.ListResponse\x12W\n" +
+	"\x0eGenerateConfig\x12!.rprompt.v1.GenerateConfigRequest\x1a\".rprompt.v1.GenerateConfigResponse\x12?\n" +
+	"\x06Render\x12\x19.rprompt.v1.RenderRequest\x1a\x1a.rprompt.v1.RenderResponse\x12D\n" +
+	"\fRenderStream\x12\x19.rprompt.v1.RenderRequest\x1a\x17.rprompt.v1.RenderChunk0\x01\x12E\n" +
+	"\bValidate\x12\x1b.rprompt.v1.ValidateRequest\x1a\x1c.rprompt.v1.ValidateResponseB7Z5github.com/notzree/rprompt/v2/api/rpromptpb;rpromptpbb\x06proto3"
+
+var (
+	file_rprompt_proto_rawDescOnce sync.Once
+	file_rprompt_proto_rawDescData []byte
+)
+
+func file_rprompt_proto_rawDescGZIP() []byte {
+	file_rprompt_proto_rawDescOnce.Do(func() {
+		file_rprompt_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_rprompt_proto_rawDesc), len(file_rprompt_proto_rawDesc)))
+	})
+	return file_rprompt_proto_rawDescData
+}
+
+var file_rprompt_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_rprompt_proto_goTypes = []any{
+	(*ListRequest)(nil),            // 0: rprompt.v1.ListRequest
+	(*ListResponse)(nil),           // 1: rprompt.v1.ListResponse
+	(*GenerateConfigRequest)(nil),  // 2: rprompt.v1.GenerateConfigRequest
+	(*GenerateConfigResponse)(nil), // 3: rprompt.v1.GenerateConfigResponse
+	(*RenderRequest)(nil),          // 4: rprompt.v1.RenderRequest
+	(*Message)(nil),                // 5: rprompt.v1.Message
+	(*ValidationIssue)(nil),        // 6: rprompt.v1.ValidationIssue
+	(*RenderResponse)(nil),         // 7: rprompt.v1.RenderResponse
+	(*RenderChunk)(nil),            // 8: rprompt.v1.RenderChunk
+	(*ValidateRequest)(nil),        // 9: rprompt.v1.ValidateRequest
+	(*ValidateResponse)(nil),       // 10: rprompt.v1.ValidateResponse
+	(*structpb.Struct)(nil),        // 11: google.protobuf.Struct
+	(*durationpb.Duration)(nil),    // 12: google.protobuf.Duration
+}
+var file_rprompt_proto_depIdxs = []int32{
+	11, // 0: rprompt.v1.GenerateConfigResponse.config:type_name -> google.protobuf.Struct
+	11, // 1: rprompt.v1.RenderRequest.values:type_name -> google.protobuf.Struct
+	12, // 2: rprompt.v1.RenderResponse.duration:type_name -> google.protobuf.Duration
+	6,  // 3: rprompt.v1.RenderResponse.issues:type_name -> rprompt.v1.ValidationIssue
+	5,  // 4: rprompt.v1.RenderResponse.messages:type_name -> rprompt.v1.Message
+	11, // 5: rprompt.v1.ValidateRequest.values:type_name -> google.protobuf.Struct
+	6,  // 6: rprompt.v1.ValidateResponse.issues:type_name -> rprompt.v1.ValidationIssue
+	0,  // 7: rprompt.v1.PromptService.List:input_type -> rprompt.v1.ListRequest
+	2,  // 8: rprompt.v1.PromptService.GenerateConfig:input_type -> rprompt.v1.GenerateConfigRequest
+	4,  // 9: rprompt.v1.PromptService.Render:input_type -> rprompt.v1.RenderRequest
+	4,  // 10: rprompt.v1.PromptService.RenderStream:input_type -> rprompt.v1.RenderRequest
+	9,  // 11: rprompt.v1.PromptService.Validate:input_type -> rprompt.v1.ValidateRequest
+	1,  // 12: rprompt.v1.PromptService.List:output_type -> rprompt.v1.ListResponse
+	3,  // 13: rprompt.v1.PromptService.GenerateConfig:output_type -> rprompt.v1.GenerateConfigResponse
+	7,  // 14: rprompt.v1.PromptService.Render:output_type -> rprompt.v1.RenderResponse
+	8,  // 15: rprompt.v1.PromptService.RenderStream:output_type -> rprompt.v1.RenderChunk
+	10, // 16: rprompt.v1.PromptService.Validate:output_type -> rprompt.v1.ValidateResponse
+	12, // [12:17] is the sub-list for method output_type
+	7,  // [7:12] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_rprompt_proto_init() }
+func file_rprompt_proto_init() {
+	if File_rprompt_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_rprompt_proto_rawDesc), len(file_rprompt_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_rprompt_proto_goTypes,
+		DependencyIndexes: file_rprompt_proto_depIdxs,
+		MessageInfos:      file_rprompt_proto_msgTypes,
+	}.Build()
+	File_rprompt_proto = out.File
+	file_rprompt_proto_goTypes = nil
+	file_rprompt_proto_depIdxs = nil
+}
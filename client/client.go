@@ -0,0 +1,143 @@
+// Package client is a Go client for the rprompt gRPC service, which serves a prompt registry to other
+// services. Start a server with `rprompt grpc-serve` or prompt.GRPCServer.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/notzree/rprompt/v2/api/rpromptpb"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Client calls an rprompt gRPC service
+type Client struct {
+	conn *grpc.ClientConn
+	pb   rpromptpb.PromptServiceClient
+}
+
+// Dial creates a client for the service at target. opts must include transport credentials, e.g.
+// grpc.WithTransportCredentials(insecure.NewCredentials()) for a plaintext connection.
+func Dial(target string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", target, err)
+	}
+	return &Client{conn: conn, pb: rpromptpb.NewPromptServiceClient(conn)}, nil
+}
+
+// New creates a client over an existing connection, which the caller remains responsible for closing
+func New(conn grpc.ClientConnInterface) *Client {
+	return &Client{pb: rpromptpb.NewPromptServiceClient(conn)}
+}
+
+// Close closes the connection opened by Dial
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// List returns the path of every template in the registry
+func (c *Client) List(ctx context.Context) ([]string, error) {
+	resp, err := c.pb.List(ctx, &rpromptpb.ListRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetTemplates(), nil
+}
+
+// GenerateConfig returns the skeleton config a template requires
+func (c *Client) GenerateConfig(ctx context.Context, template string) (map[string]any, error) {
+	resp, err := c.pb.GenerateConfig(ctx, &rpromptpb.GenerateConfigRequest{Template: template})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetConfig().AsMap(), nil
+}
+
+// RenderOption configures a Render or RenderStream call
+type RenderOption func(*rpromptpb.RenderRequest)
+
+// WithConfigPath layers the values over a config stored in the registry
+func WithConfigPath(path string) RenderOption {
+	return func(req *rpromptpb.RenderRequest) {
+		req.ConfigPath = path
+	}
+}
+
+// WithValidation sets the validation mode: off, warn or strict
+func WithValidation(mode string) RenderOption {
+	return func(req *rpromptpb.RenderRequest) {
+		req.Validation = mode
+	}
+}
+
+// WithMessages fills the response's messages with a message per [[role]] section
+func WithMessages() RenderOption {
+	return func(req *rpromptpb.RenderRequest) {
+		req.Messages = true
+	}
+}
+
+func renderRequest(template string, values map[string]any, opts []RenderOption) (*rpromptpb.RenderRequest, error) {
+	structValues, err := structpb.NewStruct(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode values: %w", err)
+	}
+	req := &rpromptpb.RenderRequest{Template: template, Values: structValues}
+	for _, opt := range opts {
+		opt(req)
+	}
+	return req, nil
+}
+
+// Render builds a template with values, which must be JSON-like: maps, slices, strings, numbers, bools and nil
+func (c *Client) Render(ctx context.Context, template string, values map[string]any, opts ...RenderOption) (*rpromptpb.RenderResponse, error) {
+	req, err := renderRequest(template, values, opts)
+	if err != nil {
+		return nil, err
+	}
+	return c.pb.Render(ctx, req)
+}
+
+// RenderStream builds a template with values, copying the output to w as the server writes it
+func (c *Client) RenderStream(ctx context.Context, w io.Writer, template string, values map[string]any, opts ...RenderOption) error {
+	req, err := renderRequest(template, values, opts)
+	if err != nil {
+		return err
+	}
+	stream, err := c.pb.RenderStream(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, chunk.GetText()); err != nil {
+			return err
+		}
+	}
+}
+
+// Validate checks values against the variables a template uses, returning the issues found
+func (c *Client) Validate(ctx context.Context, template string, values map[string]any) ([]*rpromptpb.ValidationIssue, error) {
+	structValues, err := structpb.NewStruct(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode values: %w", err)
+	}
+	resp, err := c.pb.Validate(ctx, &rpromptpb.ValidateRequest{Template: template, Values: structValues})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetIssues(), nil
+}
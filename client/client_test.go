@@ -0,0 +1,117 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/notzree/rprompt/v2/prompt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T) *Client {
+	dir := t.TempDir()
+	files := map[string]string{
+		"greeting.tmpl": `[[template "header.tmpl" .]] Hello [[.user.name]]`,
+		"header.tmpl":   `Header`,
+		"chat.tmpl":     `[[role "system"]]Be brief.[[role "user"]][[.question]]`,
+		"base.json":     `{"user": {"name": "John"}}`,
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	server, err := prompt.NewGRPCServer(prompt.NewInMemPromptRegistry(dir))
+	require.NoError(t, err)
+	lis := bufconn.Listen(1 << 20)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(ctx, lis) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+
+	c, err := Dial("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClient_ListAndGenerateConfig(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	templates, err := c.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"chat.tmpl", "greeting.tmpl", "header.tmpl"}, templates)
+
+	cfg, err := c.GenerateConfig(ctx, "greeting.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"user": map[string]any{"name": ""}}, cfg)
+
+	_, err = c.GenerateConfig(ctx, "missing.tmpl")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestClient_Render(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	resp, err := c.Render(ctx, "greeting.tmpl", map[string]any{"user": map[string]any{"name": "Ada"}})
+	require.NoError(t, err)
+	assert.Equal(t, "Header Hello Ada", resp.GetOutput())
+	assert.Equal(t, []string{"header.tmpl"}, resp.GetDependencies())
+
+	resp, err = c.Render(ctx, "greeting.tmpl", nil, WithConfigPath("base.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "Header Hello John", resp.GetOutput())
+
+	resp, err = c.Render(ctx, "chat.tmpl", map[string]any{"question": "Why?"}, WithMessages())
+	require.NoError(t, err)
+	require.Len(t, resp.GetMessages(), 2)
+	assert.Equal(t, "system", resp.GetMessages()[0].GetRole())
+	assert.Equal(t, "Why?", resp.GetMessages()[1].GetContent())
+
+	_, err = c.Render(ctx, "greeting.tmpl", map[string]any{"extra": 1}, WithValidation("strict"))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = c.Render(ctx, "greeting.tmpl", nil, WithValidation("loud"))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestClient_RenderStream(t *testing.T) {
+	c := newTestClient(t)
+
+	var out bytes.Buffer
+	require.NoError(t, c.RenderStream(context.Background(), &out, "greeting.tmpl", map[string]any{"user": map[string]any{"name": "Ada"}}))
+	assert.Equal(t, "Header Hello Ada", out.String())
+
+	err := c.RenderStream(context.Background(), &out, "missing.tmpl", nil)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestClient_Validate(t *testing.T) {
+	c := newTestClient(t)
+
+	issues, err := c.Validate(context.Background(), "greeting.tmpl", map[string]any{"user": map[string]any{"name": "Ada"}})
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+
+	issues, err = c.Validate(context.Background(), "greeting.tmpl", map[string]any{})
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, "missing", issues[0].GetKind())
+	assert.Equal(t, "user", issues[0].GetPath())
+}
@@ -0,0 +1,44 @@
+// Command ffi builds the rprompt render engine as a C shared library, so Python, Node and other services
+// render prompts with the same engine and registries as the CLI:
+//
+//	go build -buildmode=c-shared -o librprompt.so ./ffi
+//
+// which also writes librprompt.h. RenderPrompt takes the template to render and the config values as JSON
+// and returns JSON, either {"output": ...} or {"error": ..., "code": ...}. The returned string must be
+// released with FreeString. From Python:
+//
+//	lib = ctypes.CDLL("./librprompt.so")
+//	lib.RenderPrompt.restype = ctypes.c_void_p
+//	ptr = lib.RenderPrompt(b'{"template": "main.tmpl", "registry": "prompts"}', b'{"name": "Ana"}')
+//	result = json.loads(ctypes.string_at(ptr))
+//	lib.FreeString(ctypes.c_void_p(ptr))
+//
+// The template JSON names the template and where templates come from: a registry directory, or the
+// templates themselves by registry path:
+//
+//	{"template": "main.tmpl", "templates": {"main.tmpl": "[[template \"header.tmpl\"]] Hi", "header.tmpl": "Hello"}}
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"context"
+	"unsafe"
+)
+
+// RenderPrompt renders a template and returns the result as JSON, see the package documentation
+//
+//export RenderPrompt
+func RenderPrompt(templateJSON, configJSON *C.char) *C.char {
+	return C.CString(render(context.Background(), C.GoString(templateJSON), C.GoString(configJSON)))
+}
+
+// FreeString releases a string returned by RenderPrompt
+//
+//export FreeString
+func FreeString(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/notzree/rprompt/v2/prompt"
+)
+
+// renderRequest is the templateJSON of RenderPrompt
+type renderRequest struct {
+	// Template is the registry path of the template to render, such as main.tmpl
+	Template string `json:"template"`
+	// Registry is a registry directory to read templates from. When it is empty, Templates holds the
+	// templates instead, by registry path.
+	Registry  string            `json:"registry,omitempty"`
+	Templates map[string]string `json:"templates,omitempty"`
+	// Delims replaces the [[ ]] delimiters of the templates, in the form "{{,}}"
+	Delims string `json:"delims,omitempty"`
+	// Messages also splits the output into a message per [[role]] section
+	Messages bool `json:"messages,omitempty"`
+}
+
+// renderResponse is the JSON RenderPrompt returns. Error and its stable code, see prompt.ErrorCode, are set
+// instead of the output when rendering fails.
+type renderResponse struct {
+	Output   string           `json:"output,omitempty"`
+	Messages []prompt.Message `json:"messages,omitempty"`
+	Warnings []prompt.Warning `json:"warnings,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	Code     string           `json:"code,omitempty"`
+}
+
+// systems holds a prompt system per registry directory and delimiters, so repeated renders reuse parsed
+// templates
+var systems sync.Map
+
+// render renders the template templateJSON describes with the values of configJSON and returns the
+// response as JSON
+func render(ctx context.Context, templateJSON, configJSON string) string {
+	result, err := renderResult(ctx, templateJSON, configJSON)
+	response := renderResponse{}
+	if err != nil {
+		response.Error, response.Code = err.Error(), prompt.ErrorCode(err)
+	} else {
+		response.Output, response.Messages, response.Warnings = result.Output, result.Messages, result.Warnings
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		data, _ = json.Marshal(renderResponse{Error: err.Error(), Code: prompt.ErrorCode(err)})
+	}
+	return string(data)
+}
+
+func renderResult(ctx context.Context, templateJSON, configJSON string) (*prompt.BuildResult, error) {
+	var request renderRequest
+	if err := json.Unmarshal([]byte(templateJSON), &request); err != nil {
+		return nil, fmt.Errorf("invalid template request: %w", err)
+	}
+	if request.Template == "" {
+		return nil, errors.New("template is required")
+	}
+	var values map[string]any
+	if configJSON != "" {
+		if err := json.Unmarshal([]byte(configJSON), &values); err != nil {
+			return nil, prompt.NewConfigError("config", err)
+		}
+	}
+
+	system, err := newSystem(&request)
+	if err != nil {
+		return nil, err
+	}
+	opts := []prompt.BuildOption{prompt.WithData(values)}
+	if request.Messages {
+		opts = append(opts, prompt.WithMessages())
+	}
+	return system.BuildWithResult(ctx, request.Template, "", opts...)
+}
+
+// newSystem returns a prompt system over the request's registry directory, reused across renders, or over
+// its templates
+func newSystem(request *renderRequest) (*prompt.PromptSystem, error) {
+	var delims []string
+	if request.Delims != "" {
+		var err error
+		if delims, err = prompt.ParseDelims(request.Delims); err != nil {
+			return nil, err
+		}
+	}
+	if request.Registry == "" {
+		registry := prompt.NewMemoryRegistry()
+		for path, content := range request.Templates {
+			if err := registry.SetTemplate(path, content); err != nil {
+				return nil, err
+			}
+		}
+		if delims != nil {
+			registry.LeftDelim, registry.RightDelim = delims[0], delims[1]
+		}
+		return prompt.NewPromptSystem(registry)
+	}
+
+	key := request.Registry + "\x00" + request.Delims
+	if system, ok := systems.Load(key); ok {
+		return system.(*prompt.PromptSystem), nil
+	}
+	registry := prompt.NewInMemPromptRegistry(request.Registry)
+	if delims != nil {
+		registry.LeftDelim, registry.RightDelim = delims[0], delims[1]
+	}
+	system, err := prompt.NewPromptSystem(registry)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := systems.LoadOrStore(key, system)
+	return actual.(*prompt.PromptSystem), nil
+}
+
+// main is never called: c-shared libraries are built from a main package
+func main() {}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func renderJSON(t *testing.T, templateJSON, configJSON string) renderResponse {
+	t.Helper()
+	var response renderResponse
+	require.NoError(t, json.Unmarshal([]byte(render(context.Background(), templateJSON, configJSON)), &response))
+	return response
+}
+
+func TestRender_Templates(t *testing.T) {
+	response := renderJSON(t,
+		`{"template": "main.tmpl", "templates": {"main.tmpl": "{{role \"system\"}}{{template \"header.tmpl\"}} Hi {{.name}}", "header.tmpl": "Hello"}, "delims": "{{,}}", "messages": true}`,
+		`{"name": "Ana"}`)
+	assert.Empty(t, response.Error)
+	assert.Equal(t, "Hello Hi Ana", response.Output)
+	require.Len(t, response.Messages, 1)
+	assert.Equal(t, "system", string(response.Messages[0].Role))
+}
+
+func TestRender_Registry(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tmpl"), []byte("Hi [[.name]]"), 0644))
+	request, err := json.Marshal(renderRequest{Template: "main.tmpl", Registry: dir})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Hi Ana", renderJSON(t, string(request), `{"name": "Ana"}`).Output)
+	assert.Equal(t, "Hi Bo", renderJSON(t, string(request), `{"name": "Bo"}`).Output, "the registry's system is reused")
+}
+
+func TestRender_Errors(t *testing.T) {
+	for _, test := range []struct{ templateJSON, configJSON, err, code string }{
+		{`{`, ``, "invalid template request", ""},
+		{`{"templates": {}}`, ``, "template is required", ""},
+		{`{"template": "main.tmpl", "templates": {}}`, ``, "not found", "template_not_found"},
+		{`{"template": "main.tmpl", "templates": {"main.tmpl": "x"}}`, `[1]`, "failed to parse JSON", "config_invalid"},
+		{`{"template": "main.tmpl", "templates": {"main.tmpl": "x"}, "delims": "{{"}`, ``, "delim", ""},
+	} {
+		response := renderJSON(t, test.templateJSON, test.configJSON)
+		assert.Contains(t, response.Error, test.err, test.templateJSON)
+		if test.code != "" {
+			assert.Equal(t, test.code, response.Code, test.templateJSON)
+		}
+		assert.Empty(t, response.Output)
+	}
+}
@@ -2,15 +2,21 @@ package main
 
 import (
 	"context"
-	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/notzree/rprompt/v2/prompt"
 )
 
 func main() {
-	cmd := prompt.InitCLI()
-	if err := cmd.Run(context.Background(), os.Args); err != nil {
-		log.Fatal(err)
+	// Cancelling the context on an interrupt lets long-running commands such as serve shut down gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	app := prompt.NewApp()
+	err := app.Command().Run(ctx, os.Args)
+	stop()
+	if err != nil {
+		app.ReportError(err)
+		os.Exit(prompt.ExitCode(err))
 	}
 }
@@ -0,0 +1,424 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"text/template"
+
+	"github.com/notzree/rprompt/v2/prompt/settings"
+	"github.com/urfave/cli/v3"
+)
+
+// App is the rprompt command line interface. It carries its own registry, settings and output so the CLI
+// can be embedded in other programs and several instances can run side by side, e.g. in tests.
+type App struct {
+	registry *LocalPromptRegistry
+	// settings are the user's own settings, which commands such as set change and save
+	settings    *settings.Settings
+	settingsErr error
+	// project are the settings of the project the app runs in, layered over the user's, see
+	// settings.FindProject
+	project     *settings.Settings
+	projectPath string
+	// saveSettings persists settings changed by commands such as set
+	saveSettings func(*settings.Settings) error
+	out          *Output
+	// newProvider creates the LLM provider used by the run command
+	newProvider func(name string) (Provider, error)
+	// logger receives the library's debug output, which is only enabled by --verbose
+	logger *slog.Logger
+	// audit records every prompt the app renders, set by --audit-log
+	audit AuditSink
+	// usage notes every template the app renders, set by --usage-log
+	usage UsageSink
+	// retriever answers [[retrieve]] in the templates the app renders, set by --context
+	retriever ContextProvider
+	// flags answers [[flag]] in the templates the app renders, set by --flags or the settings' flags
+	flags FlagsProvider
+	// plugins are started from the plugins directory by the commands that build prompts, see withPlugins
+	plugins []*Plugin
+	// source is the registry a plugin stores, when the settings select one instead of a registry directory
+	source PromptRegistry
+}
+
+// AppOption configures an App
+type AppOption func(*App)
+
+// WithRegistry uses r instead of the registry directory from the settings
+func WithRegistry(r *LocalPromptRegistry) AppOption {
+	return func(a *App) {
+		a.registry = r
+	}
+}
+
+// WithSettings uses s instead of loading settings from the user's settings file
+func WithSettings(s *settings.Settings) AppOption {
+	return func(a *App) {
+		a.settings = s
+	}
+}
+
+// WithProjectSettings uses s as the project settings instead of looking for a project settings file
+func WithProjectSettings(s *settings.Settings) AppOption {
+	return func(a *App) {
+		a.project = s
+	}
+}
+
+// WithSettingsSaver replaces how changed settings are persisted, which defaults to the user's settings file
+func WithSettingsSaver(save func(*settings.Settings) error) AppOption {
+	return func(a *App) {
+		a.saveSettings = save
+	}
+}
+
+// WithProviders replaces how the run command creates LLM providers, which defaults to NewProvider
+func WithProviders(newProvider func(name string) (Provider, error)) AppOption {
+	return func(a *App) {
+		a.newProvider = newProvider
+	}
+}
+
+// WithOutput sends all CLI output through out instead of stdout and stderr
+func WithOutput(out *Output) AppOption {
+	return func(a *App) {
+		a.out = out
+	}
+}
+
+// NewApp creates an App. Unless overridden by options, settings are loaded from the user's settings file and
+// the project settings file of the working directory, if there is one, and the registry is opened from the
+// directory they point to.
+func NewApp(opts ...AppOption) *App {
+	a := &App{
+		saveSettings: (*settings.Settings).Save,
+		out:          NewOutput(os.Stdout, os.Stderr),
+		newProvider:  NewProvider,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	if a.settings == nil {
+		a.settings, a.settingsErr = settings.Load()
+		if a.settingsErr != nil {
+			a.settings = &settings.Settings{}
+		}
+	}
+	if a.project == nil {
+		if err := a.loadProjectSettings(); err != nil {
+			a.settingsErr = errors.Join(a.settingsErr, err)
+		}
+	}
+	if a.registry == nil {
+		var err error
+		if a.registry, err = openRegistry(a.effectiveSettings(), ""); err != nil {
+			a.settingsErr = errors.Join(a.settingsErr, err)
+		}
+	}
+	return a
+}
+
+// loadProjectSettings loads the project settings file of the working directory, if there is one
+func (a *App) loadProjectSettings() error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	path, err := settings.FindProject(wd)
+	if err != nil || path == "" {
+		return err
+	}
+	project, err := settings.LoadProject(path)
+	if err != nil {
+		return err
+	}
+	a.project, a.projectPath = project, path
+	return nil
+}
+
+// effectiveSettings are the user's settings with the project's layered over them
+func (a *App) effectiveSettings() *settings.Settings {
+	return a.settings.Merge(a.project)
+}
+
+// openRegistry opens the named registry from the settings, or the current one if name is empty, with its
+// delimiters, PII mode and template extensions. It returns nil when the settings don't configure a registry, or
+// configure one a plugin stores, which loadPlugins opens.
+func openRegistry(s *settings.Settings, name string) (*LocalPromptRegistry, error) {
+	configured, err := s.Registry(name)
+	if err != nil || configured == nil || configured.Plugin != "" {
+		return nil, err
+	}
+	registry := NewInMemPromptRegistry(configured.Dir)
+	if configured.Delims != "" {
+		delims, err := ParseDelims(configured.Delims)
+		if err != nil {
+			return nil, err
+		}
+		registry.LeftDelim, registry.RightDelim = delims[0], delims[1]
+	}
+	if configured.PII != "" {
+		if registry.PII, err = ParsePIIMode(configured.PII); err != nil {
+			return nil, err
+		}
+	}
+	if configured.Extensions != "" {
+		if registry.Extensions, err = ParseExtensions(configured.Extensions); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// validateSettings checks the values of settings the settings package can only check the types of, so a
+// setting that would break every command is rejected when it is set
+func validateSettings(s *settings.Settings) error {
+	if s.Delims != "" {
+		if _, err := ParseDelims(s.Delims); err != nil {
+			return fmt.Errorf("delims: %w", err)
+		}
+	}
+	if s.Format != "" {
+		if err := CheckFormat(s.Format); err != nil {
+			return fmt.Errorf("format: %w", err)
+		}
+	}
+	if s.PII != "" {
+		if _, err := ParsePIIMode(s.PII); err != nil {
+			return fmt.Errorf("pii: %w", err)
+		}
+	}
+	if s.Extensions != "" {
+		if _, err := ParseExtensions(s.Extensions); err != nil {
+			return fmt.Errorf("extensions: %w", err)
+		}
+	}
+	for name, r := range s.Registries {
+		if r.Dir == "" {
+			return fmt.Errorf("registries.%s.dir is required", name)
+		}
+		if r.Delims != "" {
+			if _, err := ParseDelims(r.Delims); err != nil {
+				return fmt.Errorf("registries.%s.delims: %w", name, err)
+			}
+		}
+		if r.PII != "" {
+			if _, err := ParsePIIMode(r.PII); err != nil {
+				return fmt.Errorf("registries.%s.pii: %w", name, err)
+			}
+		}
+		if r.Extensions != "" {
+			if _, err := ParseExtensions(r.Extensions); err != nil {
+				return fmt.Errorf("registries.%s.extensions: %w", name, err)
+			}
+		}
+	}
+	if _, ok := s.Registries[s.CurrentRegistry]; s.CurrentRegistry != "" && !ok {
+		return fmt.Errorf("current_registry: unknown registry %q", s.CurrentRegistry)
+	}
+	for name, p := range s.Profiles {
+		if p.Model == "" {
+			return fmt.Errorf("profiles.%s.model is required", name)
+		}
+		if p.Tokenizer != "" {
+			if _, err := TokenizerNamed(p.Tokenizer); err != nil {
+				return fmt.Errorf("profiles.%s.tokenizer: %w", name, err)
+			}
+		}
+		if p.MaxContext > 0 && p.MaxOutputTokens >= p.MaxContext {
+			return fmt.Errorf("profiles.%s.max_output_tokens must be less than max_context", name)
+		}
+	}
+	if _, ok := s.Profiles[s.DefaultProfile]; s.DefaultProfile != "" && !ok {
+		return fmt.Errorf("default_profile: unknown profile %q", s.DefaultProfile)
+	}
+	if s.Defaults.Validation != "" {
+		if _, err := ParseValidationMode(s.Defaults.Validation); err != nil {
+			return fmt.Errorf("defaults.validation: %w", err)
+		}
+	}
+	return nil
+}
+
+// InitCLI returns the root command of an App using the user's settings.
+//
+// Deprecated: use NewApp and App.Command, which also allow reporting errors through App.ReportError.
+func InitCLI() *cli.Command {
+	return NewApp().Command()
+}
+
+// ReportError prints an error returned from running the app's command. In JSON mode the error is printed as
+// an object with its stable code, see ErrorCode.
+func (a *App) ReportError(err error) {
+	if a.out.JSON {
+		enc := json.NewEncoder(a.out.Err)
+		enc.SetIndent("", "  ")
+		enc.Encode(map[string]string{"error": err.Error(), "code": ErrorCode(err)})
+		return
+	}
+	a.out.Errorf("%v", err)
+	// Point at the offending line of a template
+	var templateErr *TemplateError
+	if errors.As(err, &templateErr) && templateErr.Snippet != "" {
+		fmt.Fprintln(a.out.Err, templateErr.Snippet)
+	}
+}
+
+// configureOutput applies the global output flags
+func (a *App) configureOutput(c *cli.Command) error {
+	if c.Bool("quiet") && c.Bool("verbose") {
+		return fmt.Errorf("--quiet and --verbose cannot be used together")
+	}
+	a.out.JSON = c.Bool("json")
+	switch {
+	case c.Bool("quiet"):
+		a.out.Verbosity = VerbosityQuiet
+	case c.Bool("verbose"):
+		a.out.Verbosity = VerbosityVerbose
+	}
+	if c.Bool("no-color") {
+		a.out.Color = false
+	}
+
+	// Library internals are silent unless --verbose asks for their debug output
+	a.logger = nil
+	if a.out.Verbosity == VerbosityVerbose {
+		a.logger = slog.New(slog.NewTextHandler(a.out.Err, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+
+	a.audit = nil
+	if path := c.String("audit-log"); path != "" {
+		a.audit = NewAuditLog(path)
+	}
+	a.usage = nil
+	if path := c.String("usage-log"); path != "" {
+		a.usage = NewUsageLog(path)
+	}
+	a.retriever = nil
+	if source := c.String("context"); source != "" {
+		retriever, err := NewContextProvider(source)
+		if err != nil {
+			return err
+		}
+		a.retriever = retriever
+	}
+	a.flags = nil
+	if source := c.String("flags"); source != "" {
+		flags, err := NewFlagsProvider(source)
+		if err != nil {
+			return err
+		}
+		a.flags = flags
+	}
+	return nil
+}
+
+// promptRegistry is the registry prompts are built from: the registry a plugin stores if the settings select
+// one, otherwise the registry directory
+func (a *App) promptRegistry() PromptRegistry {
+	if a.source != nil {
+		return a.source
+	}
+	return a.registry
+}
+
+// newPromptSystem creates a prompt system over the app's registry that logs to the app's logger
+func (a *App) newPromptSystem() (*PromptSystem, error) {
+	system, err := NewPromptSystem(a.promptRegistry())
+	if err != nil {
+		return nil, err
+	}
+	system.Logger = a.logger
+	system.Audit = a.audit
+	system.Usage = a.usage
+	system.Retriever = a.retriever
+	effective := a.effectiveSettings()
+	system.Flags = a.flags
+	if system.Flags == nil && len(effective.Flags) > 0 {
+		system.Flags = StaticFlags(effective.Flags)
+	}
+	system.Stacks = effective.Stacks
+	system.PostProcess = effective.PostProcess
+	return system, nil
+}
+
+// withPlugins starts the plugins before action and closes them after it, so only the commands that build or
+// parse templates pay for starting every plugin
+func (a *App) withPlugins(action cli.ActionFunc) cli.ActionFunc {
+	return func(ctx context.Context, c *cli.Command) error {
+		if err := a.loadPlugins(ctx, c.Root().String("registry")); err != nil {
+			return err
+		}
+		return errors.Join(action(ctx, c), a.closePlugins())
+	}
+}
+
+// loadPlugins starts the plugins in the plugins directory and adds their functions to the registry, or opens
+// the registry a plugin stores if the named registry is one. Plugins that fail to start are skipped with a
+// warning, so the others and 'rprompt plugins' keep working.
+func (a *App) loadPlugins(ctx context.Context, registryName string) error {
+	dir, err := a.settings.Plugins()
+	if err != nil {
+		a.out.Debugf("Not loading plugins: %v", err)
+		return nil
+	}
+	plugins, err := LoadPlugins(ctx, dir)
+	if err != nil {
+		a.out.Warnf("Failed to load plugins: %v", err)
+	}
+	funcs, err := PluginFuncs(plugins)
+	if err != nil {
+		ClosePlugins(plugins)
+		return err
+	}
+	a.plugins = plugins
+	if a.source, err = a.openPluginRegistry(registryName); err != nil {
+		a.closePlugins()
+		return err
+	}
+	if a.registry != nil && len(funcs) > 0 {
+		if a.registry.Funcs == nil {
+			a.registry.Funcs = template.FuncMap{}
+		}
+		maps.Copy(a.registry.Funcs, funcs)
+	}
+	return nil
+}
+
+// openPluginRegistry opens the named registry, or the current one if name is empty, when the settings say a
+// plugin stores it. It returns nil for registry directories.
+func (a *App) openPluginRegistry(name string) (PromptRegistry, error) {
+	configured, err := a.effectiveSettings().Registry(name)
+	if err != nil || configured == nil || configured.Plugin == "" {
+		return nil, err
+	}
+	for _, p := range a.plugins {
+		if p.Manifest.Name == configured.Plugin {
+			return NewPluginRegistry(p)
+		}
+	}
+	return nil, fmt.Errorf("the registry is stored by plugin %s, which isn't installed", configured.Plugin)
+}
+
+// closePlugins stops the plugins loadPlugins started
+func (a *App) closePlugins() error {
+	err := ClosePlugins(a.plugins)
+	a.plugins, a.source = nil, nil
+	return err
+}
+
+// formatPlugin returns the plugin providing the output format, or nil if no plugin does
+func (a *App) formatPlugin(format string) *Plugin {
+	for _, p := range a.plugins {
+		if p.HasFormat(format) {
+			return p
+		}
+	}
+	return nil
+}
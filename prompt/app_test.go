@@ -0,0 +1,470 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/notzree/rprompt/v2/prompt/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestApp(t *testing.T, opts ...AppOption) (*App, *bytes.Buffer, *bytes.Buffer) {
+	var stdout, stderr bytes.Buffer
+	opts = append([]AppOption{
+		WithOutput(NewOutput(&stdout, &stderr)),
+		WithSettings(&settings.Settings{}),
+		WithProjectSettings(&settings.Settings{}),
+		WithSettingsSaver(func(*settings.Settings) error { return nil }),
+	}, opts...)
+	return NewApp(opts...), &stdout, &stderr
+}
+
+func TestApp_InjectedRegistry(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "a.tmpl", `A`)
+	createTestFile(t, tempDir, "b.tmpl", `B`)
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "--json", "list"}))
+	var result map[string][]string
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+	assert.Equal(t, []string{"a.tmpl", "b.tmpl"}, result["templates"])
+}
+
+func TestApp_NoRegistry(t *testing.T) {
+	app, _, _ := newTestApp(t)
+	err := app.Command().Run(context.Background(), []string{"rprompt", "list"})
+	assert.ErrorContains(t, err, "registry directory not set")
+}
+
+func TestApp_SetRegistryDir(t *testing.T) {
+	tempDir := setupTempDir(t)
+	var saved *settings.Settings
+	app, _, _ := newTestApp(t, WithSettingsSaver(func(s *settings.Settings) error {
+		saved = s
+		return nil
+	}))
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "set", "-d", tempDir}))
+	require.NotNil(t, saved)
+	assert.Equal(t, tempDir, saved.RegistryDir)
+	require.NotNil(t, app.registry)
+	assert.Equal(t, tempDir, app.registry.Directory)
+}
+
+func TestApp_SettingsRegistry(t *testing.T) {
+	tempDir := setupTempDir(t)
+	app, _, _ := newTestApp(t, WithSettings(&settings.Settings{RegistryDir: tempDir}))
+	require.NotNil(t, app.registry)
+	assert.Equal(t, tempDir, app.registry.Directory)
+}
+
+func TestApp_ProjectSettings(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello {{.name}}`)
+	createTestFile(t, tempDir, "main.json", `{"name": "John"}`)
+	outPath := filepath.Join(tempDir, "out.json")
+	var saved *settings.Settings
+	app, stdout, _ := newTestApp(t,
+		WithSettings(&settings.Settings{
+			RegistryDir: "/home/user/prompts",
+			Profiles:    map[string]settings.ModelProfile{"personal": {Model: "gpt-4o"}},
+		}),
+		WithProjectSettings(&settings.Settings{
+			RegistryDir: tempDir,
+			Delims:      "{{,}}",
+			Format:      FormatMessagesJSON,
+			Profiles:    map[string]settings.ModelProfile{"team": {Model: "claude-sonnet"}},
+		}),
+		WithSettingsSaver(func(s *settings.Settings) error {
+			saved = s
+			return nil
+		}),
+	)
+	require.NotNil(t, app.registry)
+	assert.Equal(t, tempDir, app.registry.Directory)
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "generate", "-t", "main.tmpl", "-c", "main.json", "-o", outPath}))
+	content, err := os.ReadFile(outPath)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"role": "user", "content": "Hello John"}]`, string(content))
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "profile", "list"}))
+	assert.Contains(t, stdout.String(), "personal")
+	assert.Contains(t, stdout.String(), "team")
+
+	// Saving keeps the project's settings out of the user's settings file
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "profile", "set", "fast", "--model", "gpt-4o-mini"}))
+	require.NotNil(t, saved)
+	assert.Equal(t, "/home/user/prompts", saved.RegistryDir)
+	assert.Empty(t, saved.Delims)
+	assert.NotContains(t, saved.Profiles, "team")
+}
+
+func TestApp_NamedRegistries(t *testing.T) {
+	work, personal := setupTempDir(t), setupTempDir(t)
+	createTestFile(t, work, "work.tmpl", `W`)
+	createTestFile(t, personal, "personal.tmpl", `P`)
+	var saved *settings.Settings
+	app, stdout, _ := newTestApp(t,
+		WithSettings(&settings.Settings{
+			Registries: map[string]settings.Registry{"work": {Dir: work}},
+		}),
+		WithSettingsSaver(func(s *settings.Settings) error {
+			saved = s
+			return nil
+		}),
+	)
+	assert.Nil(t, app.registry)
+	ctx := context.Background()
+
+	require.NoError(t, app.Command().Run(ctx, []string{"rprompt", "set", "--name", "personal", "-d", personal}))
+	assert.Equal(t, settings.Registry{Dir: personal}, saved.Registries["personal"])
+	assert.Equal(t, settings.Registry{Dir: work}, saved.Registries["work"])
+	assert.Nil(t, app.registry, "naming a registry doesn't switch to it")
+
+	require.NoError(t, app.Command().Run(ctx, []string{"rprompt", "use", "work"}))
+	assert.Equal(t, "work", saved.CurrentRegistry)
+	require.NotNil(t, app.registry)
+	assert.Equal(t, work, app.registry.Directory)
+
+	stdout.Reset()
+	require.NoError(t, app.Command().Run(ctx, []string{"rprompt", "--registry", "personal", "--json", "list"}))
+	var listed map[string][]string
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &listed))
+	assert.Equal(t, []string{"personal.tmpl"}, listed["templates"])
+
+	stdout.Reset()
+	require.NoError(t, app.Command().Run(ctx, []string{"rprompt", "use"}))
+	assert.Contains(t, stdout.String(), "* work")
+	assert.Contains(t, stdout.String(), "  personal")
+
+	assert.ErrorContains(t, app.Command().Run(ctx, []string{"rprompt", "use", "missing"}), `unknown registry "missing"`)
+	assert.ErrorContains(t, app.Command().Run(ctx, []string{"rprompt", "--registry", "missing", "list"}), `unknown registry "missing"`)
+
+	// Setting a directory replaces the named registry in use
+	require.NoError(t, app.Command().Run(ctx, []string{"rprompt", "set", "-d", personal}))
+	assert.Empty(t, saved.CurrentRegistry)
+	assert.Equal(t, personal, app.registry.Directory)
+}
+
+func TestApp_Config(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello`)
+	var saved *settings.Settings
+	app, stdout, stderr := newTestApp(t,
+		WithProjectSettings(&settings.Settings{Format: FormatText}),
+		WithSettingsSaver(func(s *settings.Settings) error {
+			saved = s
+			return nil
+		}),
+	)
+	ctx := context.Background()
+	run := func(args ...string) error {
+		stdout.Reset()
+		return app.Command().Run(ctx, append([]string{"rprompt"}, args...))
+	}
+
+	require.NoError(t, run("config", "set", "registry_dir", tempDir))
+	assert.Equal(t, tempDir, saved.RegistryDir)
+	require.NotNil(t, app.registry, "changing the registry directory opens it")
+	assert.Equal(t, tempDir, app.registry.Directory)
+
+	require.NoError(t, run("config", "set", "profiles.fast.model", "gpt-4o-mini"))
+	require.NoError(t, run("config", "set", "profiles.fast.max_context", "128000"))
+	require.NoError(t, run("config", "set", "default_profile", "fast"))
+	require.NoError(t, run("config", "get", "profiles.fast.max_context"))
+	assert.Equal(t, "128000\n", stdout.String())
+
+	require.NoError(t, run("config", "set", "format", FormatAnthropic))
+	assert.Contains(t, stderr.String(), "override format")
+	require.NoError(t, run("config", "get", "format"))
+	assert.Equal(t, FormatText+"\n", stdout.String(), "get shows the setting in effect")
+
+	require.NoError(t, run("--json", "config", "list"))
+	var list map[string]any
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &list))
+	assert.Equal(t, "gpt-4o-mini", list["profiles.fast.model"])
+	assert.Equal(t, tempDir, list["registry_dir"])
+
+	for args, expected := range map[string]string{
+		"config set colour red":                    `unknown setting "colour"`,
+		"config set profiles.fast.max_context big": "must be an integer",
+		"config set format xml":                    `unknown format "xml"`,
+		"config set default_profile slow":          `unknown profile "slow"`,
+		"config set profiles.fast.tokenizer bpe":   `unknown tokenizer "bpe"`,
+		"config set delims {{":                     "invalid delimiters",
+		"config set registry_dir /does/not/exist":  "directory does not exist",
+		"config unset profiles.fast.model":         "profiles.fast.model is required",
+	} {
+		assert.ErrorContains(t, run(strings.Fields(args)...), expected, args)
+	}
+	assert.Equal(t, "fast", saved.DefaultProfile, "invalid changes aren't saved")
+	assert.Equal(t, "gpt-4o-mini", app.settings.Profiles["fast"].Model)
+
+	require.NoError(t, run("config", "unset", "default_profile"))
+	require.NoError(t, run("config", "unset", "profiles.fast"))
+	assert.Empty(t, saved.Profiles)
+	assert.Empty(t, saved.DefaultProfile)
+}
+
+func TestApp_FlagDefaults(t *testing.T) {
+	tempDir := setupTempDir(t)
+	outDir := t.TempDir()
+	createTestFile(t, tempDir, "main.tmpl", `Hello [[.name]]`)
+	createTestFile(t, tempDir, "main.json", `{"name": "John", "extra": 1}`)
+	app, _, _ := newTestApp(t,
+		WithRegistry(NewInMemPromptRegistry(tempDir)),
+		WithSettings(&settings.Settings{Defaults: settings.FlagDefaults{
+			Output:     filepath.Join(outDir, "{{template_stem}}.txt"),
+			Validation: "strict",
+		}}),
+		WithProjectSettings(&settings.Settings{Defaults: settings.FlagDefaults{FailOnWarn: true}}),
+	)
+	ctx := context.Background()
+	run := func(args ...string) error {
+		return app.Command().Run(ctx, append([]string{"rprompt"}, args...))
+	}
+
+	// The unused key fails strict validation
+	assert.ErrorContains(t, run("generate", "-t", "main.tmpl", "-c", "main.json"), "extra")
+	// Flags given on the command line win over the defaults
+	require.NoError(t, run("generate", "-t", "main.tmpl", "-c", "main.json", "--validation", "off", "--fail-on-warn=false"))
+	content, err := os.ReadFile(filepath.Join(outDir, "main.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello John", string(content))
+
+	other := filepath.Join(t.TempDir(), "other.txt")
+	require.NoError(t, run("generate", "-t", "main.tmpl", "-c", "main.json", "--validation", "off", "--fail-on-warn=false", "-o", other))
+	assert.FileExists(t, other)
+
+	assert.ErrorContains(t, run("config", "set", "defaults.validation", "loose"), `unknown validation mode "loose"`)
+}
+
+func TestApp_ReportError(t *testing.T) {
+	app, _, stderr := newTestApp(t)
+	err := app.Command().Run(context.Background(), []string{"rprompt", "--quiet", "--verbose", "list"})
+	require.Error(t, err)
+	app.ReportError(err)
+	assert.Contains(t, stderr.String(), "--quiet and --verbose cannot be used together")
+}
+
+// fakeProvider echoes the messages it receives
+type fakeProvider struct {
+	req CompletionRequest
+}
+
+func (p *fakeProvider) Stream(ctx context.Context, req CompletionRequest, w io.Writer) error {
+	p.req = req
+	for _, message := range req.Messages {
+		fmt.Fprintf(w, "%s: %s", message.Role, message.Content)
+	}
+	return nil
+}
+
+func TestApp_Run(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello [[.name]]`)
+	createTestFile(t, tempDir, "main.json", `{"name": "John"}`)
+	provider := &fakeProvider{}
+	app, stdout, _ := newTestApp(t,
+		WithRegistry(NewInMemPromptRegistry(tempDir)),
+		WithProviders(func(name string) (Provider, error) {
+			assert.Equal(t, ProviderOpenAI, name)
+			return provider, nil
+		}),
+	)
+
+	err := app.Command().Run(context.Background(), []string{
+		"rprompt", "run", "-t", "main.tmpl", "-c", "main.json", "--provider", "openai", "--model", "gpt-4o", "--max-tokens", "10",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "user: Hello John\n", stdout.String())
+	assert.Equal(t, "gpt-4o", provider.req.Model)
+	assert.Equal(t, 10, provider.req.MaxTokens)
+}
+
+func TestApp_RunProfile(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello [[.name]]`)
+	createTestFile(t, tempDir, "main.json", `{"name": "John"}`)
+	provider := &fakeProvider{}
+	app, _, _ := newTestApp(t,
+		WithRegistry(NewInMemPromptRegistry(tempDir)),
+		WithSettings(&settings.Settings{
+			Profiles: map[string]settings.ModelProfile{
+				"fast": {Provider: ProviderAnthropic, Model: "claude-haiku", MaxOutputTokens: 256},
+			},
+			DefaultProfile: "fast",
+		}),
+		WithProviders(func(name string) (Provider, error) {
+			assert.Equal(t, ProviderAnthropic, name)
+			return provider, nil
+		}),
+	)
+
+	err := app.Command().Run(context.Background(), []string{"rprompt", "run", "-t", "main.tmpl", "-c", "main.json", "--max-tokens", "10"})
+	require.NoError(t, err)
+	assert.Equal(t, "claude-haiku", provider.req.Model)
+	assert.Equal(t, 10, provider.req.MaxTokens, "flags override the profile")
+}
+
+func TestApp_RunRequiresModel(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello`)
+	app, _, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+
+	err := app.Command().Run(context.Background(), []string{"rprompt", "run", "-t", "main.tmpl", "--provider", "openai"})
+	assert.ErrorContains(t, err, "--provider and --model are required")
+}
+
+func TestApp_GenerateProfileBudget(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Keep[[trimmable 1]]`+strings.Repeat("filler ", 50)+`[[endtrimmable]]`)
+	createTestFile(t, tempDir, "main.json", `{}`)
+	outPath := filepath.Join(tempDir, "out.txt")
+	app, _, _ := newTestApp(t,
+		WithRegistry(NewInMemPromptRegistry(tempDir)),
+		WithSettings(&settings.Settings{
+			Profiles: map[string]settings.ModelProfile{
+				"small": {Model: "gpt-4o", MaxContext: 21, MaxOutputTokens: 20},
+			},
+		}),
+	)
+
+	err := app.Command().Run(context.Background(), []string{"rprompt", "--profile", "small", "generate", "-t", "main.tmpl", "-c", "main.json", "-o", outPath})
+	require.NoError(t, err)
+	content, err := os.ReadFile(outPath)
+	require.NoError(t, err)
+	assert.Equal(t, "Keep", string(content), "the profile leaves 1 token for the prompt")
+}
+
+func TestApp_UnknownProfile(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello`)
+	app, _, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+
+	err := app.Command().Run(context.Background(), []string{"rprompt", "--profile", "missing", "tokens", "-t", "main.tmpl"})
+	assert.ErrorContains(t, err, `unknown profile "missing"`)
+}
+
+func TestApp_Tokens(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", strings.Repeat("a", 400))
+	app, stdout, _ := newTestApp(t,
+		WithRegistry(NewInMemPromptRegistry(tempDir)),
+		WithSettings(&settings.Settings{
+			Profiles: map[string]settings.ModelProfile{
+				"gpt": {Model: "gpt-4o", Tokenizer: TokenizerEstimate, MaxContext: 1000, InputPrice: 2.5},
+			},
+			DefaultProfile: "gpt",
+		}),
+	)
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "--json", "tokens", "-t", "main.tmpl"}))
+	var result map[string]any
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+	assert.Equal(t, float64(100), result["tokens"])
+	assert.Equal(t, float64(1000), result["max_context"])
+	assert.InDelta(t, 0.00025, result["input_cost"], 1e-9)
+}
+
+func TestApp_SetProfile(t *testing.T) {
+	var saved *settings.Settings
+	app, stdout, _ := newTestApp(t, WithSettingsSaver(func(s *settings.Settings) error {
+		saved = s
+		return nil
+	}))
+
+	err := app.Command().Run(context.Background(), []string{
+		"rprompt", "profile", "set", "--model", "claude-sonnet", "--provider", "anthropic", "--max-context", "200000", "--default", "sonnet",
+	})
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	assert.Equal(t, "sonnet", saved.DefaultProfile)
+	assert.Equal(t, settings.ModelProfile{Provider: "anthropic", Model: "claude-sonnet", MaxContext: 200000}, saved.Profiles["sonnet"])
+
+	stdout.Reset()
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "profile", "list"}))
+	assert.Equal(t, "* sonnet\tanthropic claude-sonnet\n", stdout.String())
+
+	err = app.Command().Run(context.Background(), []string{"rprompt", "profile", "set", "--model", "x", "--tokenizer", "bpe", "bad"})
+	assert.ErrorContains(t, err, `unknown tokenizer "bpe"`)
+}
+
+func TestApp_RunValidatesResponse(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", answerSchemaTemplate)
+	createTestFile(t, tempDir, "main.json", `{"question": "Why?"}`)
+	provider := &fakeProvider{}
+	app, _, _ := newTestApp(t,
+		WithRegistry(NewInMemPromptRegistry(tempDir)),
+		WithProviders(func(name string) (Provider, error) { return provider, nil }),
+	)
+
+	// fakeProvider echoes the prompt, which isn't JSON
+	err := app.Command().Run(context.Background(), []string{"rprompt", "run", "-t", "main.tmpl", "-c", "main.json", "--provider", "openai", "--model", "gpt-4o"})
+	var formatErr *ResponseFormatError
+	assert.ErrorAs(t, err, &formatErr)
+	require.Len(t, provider.req.Messages, 2)
+	assert.Contains(t, provider.req.Messages[1].Content, "Respond only with JSON matching this schema")
+}
+
+func TestApp_VerboseLogging(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "header.tmpl" .]]Body`)
+	createTestFile(t, tempDir, "header.tmpl", `Header`)
+	createTestFile(t, tempDir, "main.json", `{}`)
+	outPath := filepath.Join(tempDir, "out.txt")
+
+	app, _, stderr := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "generate", "-t", "main.tmpl", "-c", "main.json", "-o", outPath}))
+	assert.NotContains(t, stderr.String(), "found dependencies", "library logging is silent by default")
+
+	app, _, stderr = newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "--verbose", "generate", "-t", "main.tmpl", "-c", "main.json", "-o", outPath}))
+	assert.Contains(t, stderr.String(), "level=DEBUG msg=\"found dependencies\" template=main.tmpl dependencies=[header.tmpl]")
+}
+
+func TestApp_ReportTemplateError(t *testing.T) {
+	app, _, stderr := newTestApp(t)
+	app.ReportError(fmt.Errorf("main.tmpl: %w", NewTemplateError("main.tmpl", 2, 4, "boom", "2 | a [[.x]]\n  |    ^", nil)))
+	assert.Equal(t, "Error: main.tmpl: main.tmpl:2:4: boom\n2 | a [[.x]]\n  |    ^\n", stderr.String())
+}
+
+func TestApp_GenerateFailOnWarn(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello [[.name]]`)
+	createTestFile(t, tempDir, "main.json", `{"name": "John", "extra": 1}`)
+	outPath := filepath.Join(tempDir, "out.txt")
+	app, _, stderr := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "generate", "-t", "main.tmpl", "-c", "main.json", "-o", outPath}))
+	assert.Contains(t, stderr.String(), "main.tmpl: extra is set in the config but not used by the template")
+
+	err := app.Command().Run(context.Background(), []string{"rprompt", "generate", "-t", "main.tmpl", "-c", "main.json", "-o", outPath, "--fail-on-warn"})
+	var warningsErr *WarningsError
+	require.ErrorAs(t, err, &warningsErr)
+	assert.Equal(t, WarningUnusedKey, warningsErr.Warnings[0].Kind)
+}
+
+func TestApp_ReportErrorJSON(t *testing.T) {
+	tempDir := setupTempDir(t)
+	app, _, stderr := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	err := app.Command().Run(context.Background(), []string{"rprompt", "--json", "tokens", "-t", "absent.tmpl"})
+	require.Error(t, err)
+	app.ReportError(err)
+
+	var reported map[string]string
+	require.NoError(t, json.Unmarshal(stderr.Bytes(), &reported))
+	assert.Equal(t, CodeTemplateNotFound, reported["code"])
+	assert.Equal(t, 3, ExitCode(err))
+}
@@ -0,0 +1,51 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// AuditLog is an AuditSink appending each record as a line of JSON to a file. It is safe for concurrent use.
+type AuditLog struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// NewAuditLog creates an AuditLog writing to path. The file and its directory are created on the first record.
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{Path: path}
+}
+
+// Record appends record to the log
+func (l *AuditLog) Record(ctx context.Context, record AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("err encoding audit record: %w", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := appendLine(l.Path, data); err != nil {
+		return fmt.Errorf("failed to write audit log %s: %w", l.Path, err)
+	}
+	return nil
+}
+
+// appendLine appends data and a newline to the file at path, creating the file and its directory if needed
+func appendLine(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directories: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,113 @@
+package prompt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSink keeps every audit record in memory
+type recordingSink struct {
+	records []AuditRecord
+	err     error
+}
+
+func (s *recordingSink) Record(ctx context.Context, record AuditRecord) error {
+	s.records = append(s.records, record)
+	return s.err
+}
+
+func TestAudit_Records(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "header.tmpl" .]] Hello [[.name]]`)
+	createTestFile(t, tempDir, "header.tmpl", `Header`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	sink := &recordingSink{}
+	system.Audit = sink
+	ctx := context.Background()
+
+	output, err := system.Build(ctx, "main.tmpl", "", WithValue("name", "John"), WithAuditLabels(map[string]string{"request": "42"}))
+	require.NoError(t, err)
+	builder, err := system.NewBuilder(ctx, "main.tmpl", "", WithValue("name", "Jane"))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, builder.BuildTo(ctx, &buf))
+
+	require.Len(t, sink.records, 2)
+	first, second := sink.records[0], sink.records[1]
+	assert.Equal(t, "main.tmpl", first.Template)
+	assert.Equal(t, map[string]string{"request": "42"}, first.Labels)
+	assert.Equal(t, len(output), first.OutputLength)
+	assert.Equal(t, buf.Len(), second.OutputLength)
+	assert.False(t, first.Time.IsZero())
+	// Same templates, different configs
+	assert.Equal(t, first.TemplateHash, second.TemplateHash)
+	assert.NotEqual(t, first.ConfigHash, second.ConfigHash)
+}
+
+func TestAudit_SinkErrorFailsBuild(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	system.Audit = &recordingSink{err: errors.New("disk full")}
+
+	_, err = system.Build(context.Background(), "main.tmpl", "")
+	assert.ErrorContains(t, err, "disk full")
+}
+
+func TestAuditLog(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello [[.name]]`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	logPath := filepath.Join(tempDir, "logs", "audit.jsonl")
+	system.Audit = NewAuditLog(logPath)
+	ctx := context.Background()
+
+	for _, name := range []string{"John", "Jane"} {
+		_, err := system.Build(ctx, "main.tmpl", "", WithValue("name", name))
+		require.NoError(t, err)
+	}
+
+	f, err := os.Open(logPath)
+	require.NoError(t, err)
+	defer f.Close()
+	var records []AuditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record AuditRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 2)
+	assert.Equal(t, len("Hello John"), records[0].OutputLength)
+	assert.Len(t, records[0].TemplateHash, 64)
+}
+
+func TestApp_ServeAuditLog(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "hello.tmpl", `Hello [[.name]]`)
+	app, _, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	logPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	cmd := app.Command()
+	cmd.Reader = strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"prompts/get","params":{"name":"hello","arguments":{"name":"Ada"}}}` + "\n")
+
+	require.NoError(t, cmd.Run(context.Background(), []string{"rprompt", "--audit-log", logPath, "mcp-serve"}))
+	data, err := os.ReadFile(logPath)
+	require.NoError(t, err, "served renders are audited")
+	var record AuditRecord
+	require.NoError(t, json.Unmarshal(data, &record))
+	assert.Equal(t, "hello.tmpl", record.Template)
+	assert.Equal(t, len("Hello Ada"), record.OutputLength)
+}
@@ -0,0 +1,44 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptSystem_Bench(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "header.tmpl" .]] Hello [[.name]]`)
+	createTestFile(t, tempDir, "header.tmpl", `# [[.title]]`)
+	createTestFile(t, tempDir, "main.json", `{"name": "World", "title": "Greeting"}`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	report, err := system.Bench(ctx, "main.tmpl", "main.json", 5)
+	require.NoError(t, err)
+	assert.Equal(t, 5, report.Iterations)
+	assert.Positive(t, report.Parse.NsPerOp)
+	assert.Positive(t, report.Resolve.AllocsPerOp)
+	assert.Positive(t, report.Execute.BytesPerOp)
+
+	_, err = system.Bench(ctx, "main.tmpl", "", 0)
+	assert.ErrorContains(t, err, "iterations must be positive")
+	_, err = system.Bench(ctx, "missing.tmpl", "", 1)
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+}
+
+func TestApp_Bench(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello [[.name]]`)
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "--json", "bench", "-t", "main.tmpl", "-n", "3"}))
+	var report BenchReport
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.Equal(t, "main.tmpl", report.Template)
+	assert.Equal(t, 3, report.Iterations)
+}
@@ -0,0 +1,76 @@
+package prompt
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/notzree/rprompt/v2/prompt/core"
+)
+
+// CompileRegistry reads, parses and resolves every template in the registry and collects its configs into a
+// Bundle. It fails on the first template that doesn't parse or includes a template that doesn't exist, so a
+// bundle never holds a template that can't be built.
+func CompileRegistry(ctx context.Context, r *LocalPromptRegistry) (*Bundle, error) {
+	paths, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	bundle := &Bundle{
+		Version:    core.BundleVersion,
+		Templates:  make(map[string]*BundledTemplate, len(paths)),
+		Configs:    make(map[string]string),
+		LeftDelim:  r.LeftDelim,
+		RightDelim: r.RightDelim,
+		Extensions: r.Extensions,
+	}
+	for _, path := range paths {
+		template, err := r.Find(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile %s: %w", path, core.WrapNotFound(path, err))
+		}
+		schema, err := template.Schema(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile %s: %w", path, err)
+		}
+		bundle.Templates[path] = &BundledTemplate{
+			Content:      template.OriginalContent,
+			Dependencies: template.Dependencies(),
+			Schema:       schema,
+		}
+	}
+
+	configPaths, err := r.ListConfigs()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range configPaths {
+		fullPath, err := r.ResolvePath(path)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(fullPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+		}
+		if _, err := CfgFromJSONString(string(data), path); err != nil {
+			return nil, fmt.Errorf("failed to compile %s: %w", path, err)
+		}
+		bundle.Configs[path] = string(data)
+	}
+	return bundle, nil
+}
+
+// LoadCompiledRegistry reads the bundle at path and returns a registry serving it
+func LoadCompiledRegistry(path string) (*CompiledRegistry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+	bundle, err := ReadBundle(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load bundle %s: %w", path, err)
+	}
+	return NewCompiledRegistry(bundle)
+}
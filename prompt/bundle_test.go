@@ -0,0 +1,77 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompiledRegistry(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "header" .]] Hello [[.name]]`)
+	createTestFile(t, tempDir, "header.tmpl", `# [[.title]]`)
+	createTestFile(t, tempDir, "main.json", `{"name": "World", "title": "Greeting"}`)
+	ctx := context.Background()
+
+	bundle, err := CompileRegistry(ctx, NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"header.tmpl"}, bundle.Templates["main.tmpl"].Dependencies)
+	assert.Contains(t, bundle.Templates["main.tmpl"].Schema.Properties, "title")
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteBundle(&buf, bundle))
+	// The registry directory isn't needed once the bundle is written
+	require.NoError(t, os.RemoveAll(tempDir))
+
+	loaded, err := ReadBundle(&buf)
+	require.NoError(t, err)
+	registry, err := NewCompiledRegistry(loaded)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"header.tmpl", "main.tmpl"}, registry.List())
+
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	output, err := system.Build(ctx, "main.tmpl", "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, "# Greeting Hello World", output)
+
+	_, err = system.Build(ctx, "missing.tmpl", "")
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+	assert.Error(t, registry.SaveConfig(ctx, NewConfig(map[string]any{}, "new.json")))
+
+	schema, ok := registry.Schema("main.tmpl")
+	require.True(t, ok)
+	assert.Equal(t, "object", schema.Type)
+}
+
+func TestCompileRegistry_Errors(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "missing.tmpl" .]]`)
+
+	_, err := CompileRegistry(context.Background(), NewInMemPromptRegistry(tempDir))
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+	assert.ErrorContains(t, err, "failed to compile main.tmpl")
+
+	_, err = ReadBundle(strings.NewReader("not a bundle"))
+	assert.ErrorContains(t, err, "not an rprompt bundle")
+}
+
+func TestApp_Compile(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello [[.name]]`)
+	output := filepath.Join(t.TempDir(), "prompts.bundle")
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "compile", "-o", output}))
+	assert.Contains(t, stdout.String(), "Compiled 1 templates and 0 configs")
+
+	registry, err := LoadCompiledRegistry(output)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.tmpl"}, registry.List())
+}
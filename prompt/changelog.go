@@ -0,0 +1,397 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/notzree/rprompt/v2/utils"
+)
+
+// CurrentRef is the registry state of the files as they are now, see LocalPromptRegistry.Changelog
+const CurrentRef = "current"
+
+// ChangeKind is how a file differs between two registry states
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+)
+
+// FileChange is how a template or config differs between two registry states
+type FileChange struct {
+	Path string     `json:"path"`
+	Kind ChangeKind `json:"kind"`
+	// LinesAdded and LinesRemoved count the changed lines of a modified file
+	LinesAdded   int `json:"lines_added,omitempty"`
+	LinesRemoved int `json:"lines_removed,omitempty"`
+	// VariablesAdded and VariablesRemoved are the variables a template started or stopped using
+	VariablesAdded   []string `json:"variables_added,omitempty"`
+	VariablesRemoved []string `json:"variables_removed,omitempty"`
+	// KeysAdded, KeysRemoved and KeysChanged are the dotted keys of a config that changed
+	KeysAdded   []string `json:"keys_added,omitempty"`
+	KeysRemoved []string `json:"keys_removed,omitempty"`
+	KeysChanged []string `json:"keys_changed,omitempty"`
+}
+
+// ChangelogGroup is the changes to a template and the configs named after it, such as agents/billing.tmpl and
+// agents/billing.json
+type ChangelogGroup struct {
+	Template string       `json:"template"`
+	Changes  []FileChange `json:"changes"`
+}
+
+// Changelog is every template and config that changed between two registry states, grouped by template
+type Changelog struct {
+	From   string           `json:"from"`
+	To     string           `json:"to"`
+	Groups []ChangelogGroup `json:"groups"`
+}
+
+// Changelog compares the templates and configs of the registry at two refs. A ref is one of:
+//
+//   - a tag such as v1.2.0, which stands for the newest version of each file tagged at or below it, so files
+//     that weren't retagged for a release keep their earlier release's version
+//   - a date such as 2026-01-31 or a time in RFC 3339 format, which stands for the newest version of each
+//     file saved by then
+//   - current, which stands for the files as they are now
+//
+// Only versions saved through the registry are known, see History.
+func (r *LocalPromptRegistry) Changelog(ctx context.Context, from, to string) (*Changelog, error) {
+	before, err := r.stateAt(from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := r.stateAt(to)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(before)+len(after))
+	for path := range before {
+		paths = append(paths, path)
+	}
+	for path := range after {
+		paths = append(paths, path)
+	}
+	paths = utils.UniqueString(paths)
+	sort.Strings(paths)
+
+	changelog := &Changelog{From: from, To: to, Groups: []ChangelogGroup{}}
+	groups := make(map[string]int)
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		old, hadOld := before[path]
+		updated, hasNew := after[path]
+		var change FileChange
+		switch {
+		case !hadOld:
+			change = FileChange{Path: path, Kind: ChangeAdded}
+		case !hasNew:
+			change = FileChange{Path: path, Kind: ChangeRemoved}
+		case bytes.Equal(old, updated):
+			continue
+		default:
+			change = FileChange{Path: path, Kind: ChangeModified}
+			change.LinesAdded, change.LinesRemoved = lineChanges(string(old), string(updated))
+		}
+		if strings.HasSuffix(path, ".tmpl") {
+			change.VariablesAdded, change.VariablesRemoved = r.variableChanges(ctx, path, old, updated)
+		} else {
+			change.KeysAdded, change.KeysRemoved, change.KeysChanged = configChanges(path, old, updated)
+		}
+
+		template := strings.TrimSuffix(strings.TrimSuffix(path, ".json"), ".tmpl") + ".tmpl"
+		i, ok := groups[template]
+		if !ok {
+			i = len(changelog.Groups)
+			groups[template] = i
+			changelog.Groups = append(changelog.Groups, ChangelogGroup{Template: template})
+		}
+		changelog.Groups[i].Changes = append(changelog.Groups[i].Changes, change)
+	}
+	sort.Slice(changelog.Groups, func(i, j int) bool { return changelog.Groups[i].Template < changelog.Groups[j].Template })
+	for _, group := range changelog.Groups {
+		// Templates before their configs
+		sort.SliceStable(group.Changes, func(i, j int) bool {
+			return strings.HasSuffix(group.Changes[i].Path, ".tmpl") && !strings.HasSuffix(group.Changes[j].Path, ".tmpl")
+		})
+	}
+	return changelog, nil
+}
+
+// WriteChangelog writes the changelog as Markdown, with a section per template, for release notes
+func WriteChangelog(w io.Writer, changelog *Changelog) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Prompt changes from %s to %s\n", changelog.From, changelog.To)
+	if len(changelog.Groups) == 0 {
+		b.WriteString("\nNo templates or configs changed.\n")
+	}
+	for _, group := range changelog.Groups {
+		fmt.Fprintf(&b, "\n## %s\n\n", group.Template)
+		for _, change := range group.Changes {
+			kind := "Config " + change.Path
+			if change.Path == group.Template {
+				kind = "Template"
+			}
+			switch change.Kind {
+			case ChangeModified:
+				fmt.Fprintf(&b, "- %s modified: %d %s added, %d removed\n", kind, change.LinesAdded, plural(change.LinesAdded, "line"), change.LinesRemoved)
+			default:
+				fmt.Fprintf(&b, "- %s %s\n", kind, change.Kind)
+			}
+			for _, detail := range []struct {
+				label string
+				items []string
+			}{
+				{"New variables", change.VariablesAdded},
+				{"Variables no longer used", change.VariablesRemoved},
+				{"Keys added", change.KeysAdded},
+				{"Keys removed", change.KeysRemoved},
+				{"Keys changed", change.KeysChanged},
+			} {
+				if len(detail.items) > 0 {
+					fmt.Fprintf(&b, "  - %s: %s\n", detail.label, strings.Join(detail.items, ", "))
+				}
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
+
+// stateAt returns the content of every template and config in the registry at ref, see Changelog
+func (r *LocalPromptRegistry) stateAt(ref string) (map[string][]byte, error) {
+	if ref == "" || ref == CurrentRef {
+		return r.currentState()
+	}
+	var pick func(versions []Version) (int, bool)
+	switch {
+	case semverTagPattern.MatchString(ref):
+		pick = func(versions []Version) (int, bool) {
+			best, bestTag := 0, ""
+			for _, v := range versions {
+				for _, tag := range v.Tags {
+					if compareSemver(tag, ref) <= 0 && (bestTag == "" || compareSemver(tag, bestTag) > 0) {
+						best, bestTag = v.Version, tag
+					}
+				}
+			}
+			return best, bestTag != ""
+		}
+	default:
+		cutoff, err := parseRefTime(ref)
+		if err != nil {
+			return nil, err
+		}
+		pick = func(versions []Version) (int, bool) {
+			best := 0
+			for _, v := range versions {
+				if !v.Time.After(cutoff) {
+					best = v.Version
+				}
+			}
+			return best, best > 0
+		}
+	}
+
+	paths, err := r.versionedPaths()
+	if err != nil {
+		return nil, err
+	}
+	state := make(map[string][]byte, len(paths))
+	tagged := false
+	for _, path := range paths {
+		versions, err := r.History(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range versions {
+			for _, tag := range v.Tags {
+				tagged = tagged || tag == ref
+			}
+		}
+		version, ok := pick(versions)
+		if !ok {
+			continue
+		}
+		if state[path], err = r.ReadVersion(path, version); err != nil {
+			return nil, err
+		}
+	}
+	if semverTagPattern.MatchString(ref) && !tagged {
+		return nil, fmt.Errorf("no template or config is tagged %s", ref)
+	}
+	return state, nil
+}
+
+// currentState returns the content of every template and config in the registry directory
+func (r *LocalPromptRegistry) currentState() (map[string][]byte, error) {
+	state := make(map[string][]byte)
+	for _, ext := range []string{".tmpl", ".json"} {
+		paths, err := r.listFiles(ext)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			content, err := os.ReadFile(filepath.Join(r.Directory, filepath.FromSlash(path)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			state[path] = content
+		}
+	}
+	return state, nil
+}
+
+// versionedPaths returns the paths of every file with saved versions, sorted
+func (r *LocalPromptRegistry) versionedPaths() ([]string, error) {
+	root := filepath.Join(r.Directory, HistoryDir)
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && path == root {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !versionNumberPattern.MatchString(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the history of %s: %w", r.Directory, err)
+	}
+	paths = utils.UniqueString(paths)
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// parseRefTime parses a date, which stands for the end of that day, or a time in RFC 3339 format
+func parseRefTime(ref string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, ref, time.Local); err == nil {
+		return t.Add(24*time.Hour - time.Nanosecond), nil
+	}
+	if t, err := time.Parse(time.RFC3339, ref); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid ref %q, expected a tag such as v1.2.0, a date such as 2026-01-31 or %s", ref, CurrentRef)
+}
+
+// compareSemver compares two tags matching semverTagPattern. Pre-releases sort before their release and
+// are compared as strings among themselves.
+func compareSemver(a, b string) int {
+	ma, mb := semverTagPattern.FindStringSubmatch(a), semverTagPattern.FindStringSubmatch(b)
+	for i := 1; i <= 3; i++ {
+		na, _ := strconv.Atoi(ma[i])
+		nb, _ := strconv.Atoi(mb[i])
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch pa, pb := ma[4], mb[4]; {
+	case pa == pb:
+		return 0
+	case pa == "":
+		return 1
+	case pb == "":
+		return -1
+	default:
+		return strings.Compare(pa, pb)
+	}
+}
+
+// lineChanges counts the lines added to and removed from old to make updated, see NewDiff
+func lineChanges(old, updated string) (int, int) {
+	diff := NewDiff("", "", old, updated)
+	return diff.LinesAdded, diff.LinesRemoved
+}
+
+// variableChanges returns the variables a template started and stopped using. Either content may be nil for
+// a template that was added or removed. Content that fails to parse uses no variables.
+func (r *LocalPromptRegistry) variableChanges(ctx context.Context, path string, old, updated []byte) ([]string, []string) {
+	variables := func(content []byte) []string {
+		if content == nil {
+			return nil
+		}
+		cfg, err := NewTemplate(path, string(content), r).GenerateConfig(ctx, "")
+		if err != nil {
+			return nil
+		}
+		return utils.FlattenKeys(cfg.Config)
+	}
+	added, removed := setDifference(variables(updated), variables(old)), setDifference(variables(old), variables(updated))
+	return added, removed
+}
+
+// configChanges returns the keys a config gained, lost and changed the value of. Content that fails to parse
+// has no keys.
+func configChanges(path string, old, updated []byte) ([]string, []string, []string) {
+	data := func(content []byte) map[string]any {
+		if content == nil {
+			return map[string]any{}
+		}
+		cfg, err := CfgFromJSONString(string(content), path)
+		if err != nil {
+			return map[string]any{}
+		}
+		return cfg.Config
+	}
+	before, after := data(old), data(updated)
+	beforeKeys, afterKeys := utils.FlattenKeys(before), utils.FlattenKeys(after)
+	var changed []string
+	for _, key := range afterKeys {
+		oldValue, ok := utils.GetPath(before, key)
+		newValue, _ := utils.GetPath(after, key)
+		if ok && !reflect.DeepEqual(oldValue, newValue) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return setDifference(afterKeys, beforeKeys), setDifference(beforeKeys, afterKeys), changed
+}
+
+// setDifference returns the items of a that aren't in b, sorted
+func setDifference(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, item := range b {
+		in[item] = true
+	}
+	var diff []string
+	for _, item := range a {
+		if !in[item] {
+			diff = append(diff, item)
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
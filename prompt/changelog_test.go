@@ -0,0 +1,111 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalPromptRegistry_Changelog(t *testing.T) {
+	registry := NewInMemPromptRegistry(setupTempDir(t))
+	ctx := context.Background()
+	require.NoError(t, registry.SaveTemplate("billing.tmpl", "Hello [[.name]]\nYou owe [[.amount]]"))
+	require.NoError(t, registry.SaveConfig(ctx, NewConfig(map[string]any{"name": "John", "amount": 1}, "billing.json")))
+	require.NoError(t, registry.SaveTemplate("support.tmpl", "Help [[.name]]"))
+	for _, path := range []string{"billing.tmpl", "billing.json", "support.tmpl"} {
+		_, err := registry.Tag(path, 0, "v1.0.0")
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, registry.SaveTemplate("billing.tmpl", "Hello [[.name]]\nYou owe [[.amount]] by [[.due]]"))
+	require.NoError(t, registry.SaveConfig(ctx, NewConfig(map[string]any{"name": "John", "amount": 2, "due": "Friday"}, "billing.json")))
+	require.NoError(t, registry.SaveTemplate("refunds.tmpl", "Refund"))
+	for _, path := range []string{"billing.tmpl", "billing.json", "refunds.tmpl"} {
+		_, err := registry.Tag(path, 0, "v1.1.0")
+		require.NoError(t, err)
+	}
+	// support.tmpl keeps its v1.0.0 version at v1.1.0, so it only changes afterwards
+	require.NoError(t, registry.SaveTemplate("support.tmpl", "Help [[.user]]"))
+
+	changelog, err := registry.Changelog(ctx, "v1.0.0", "v1.1.0")
+	require.NoError(t, err)
+	assert.Equal(t, []ChangelogGroup{
+		{Template: "billing.tmpl", Changes: []FileChange{
+			{Path: "billing.tmpl", Kind: ChangeModified, LinesAdded: 1, LinesRemoved: 1, VariablesAdded: []string{"due"}},
+			{Path: "billing.json", Kind: ChangeModified, LinesAdded: 2, LinesRemoved: 1, KeysAdded: []string{"due"}, KeysChanged: []string{"amount"}},
+		}},
+		{Template: "refunds.tmpl", Changes: []FileChange{{Path: "refunds.tmpl", Kind: ChangeAdded}}},
+	}, changelog.Groups)
+
+	changelog, err = registry.Changelog(ctx, "v1.1.0", CurrentRef)
+	require.NoError(t, err)
+	require.Len(t, changelog.Groups, 1)
+	assert.Equal(t, FileChange{
+		Path: "support.tmpl", Kind: ChangeModified, LinesAdded: 1, LinesRemoved: 1,
+		VariablesAdded: []string{"user"}, VariablesRemoved: []string{"name"},
+	}, changelog.Groups[0].Changes[0])
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteChangelog(&buf, changelog))
+	assert.Equal(t, `# Prompt changes from v1.1.0 to current
+
+## support.tmpl
+
+- Template modified: 1 line added, 1 removed
+  - New variables: user
+  - Variables no longer used: name
+`, buf.String())
+
+	changelog, err = registry.Changelog(ctx, "2000-01-01", "v1.0.0")
+	require.NoError(t, err)
+	assert.Len(t, changelog.Groups, 2, "nothing was saved by 2000, so every file is added")
+
+	_, err = registry.Changelog(ctx, "v0.9.0", CurrentRef)
+	assert.ErrorContains(t, err, "no template or config is tagged v0.9.0")
+	_, err = registry.Changelog(ctx, "last-week", CurrentRef)
+	assert.ErrorContains(t, err, `invalid ref "last-week"`)
+}
+
+func TestCompareSemver(t *testing.T) {
+	ordered := []string{"v0.9.0", "v1.0.0-rc.1", "v1.0.0", "v1.2.0", "v1.10.0", "v2.0.0"}
+	for i := range ordered {
+		for j := range ordered {
+			expected := 0
+			if i < j {
+				expected = -1
+			} else if i > j {
+				expected = 1
+			}
+			assert.Equal(t, expected, compareSemver(ordered[i], ordered[j]), "%s vs %s", ordered[i], ordered[j])
+		}
+	}
+}
+
+func TestLineChanges(t *testing.T) {
+	added, removed := lineChanges("a\nb\nc", "a\nx\nc\nd")
+	assert.Equal(t, 2, added)
+	assert.Equal(t, 1, removed)
+	added, removed = lineChanges("same", "same")
+	assert.Zero(t, added)
+	assert.Zero(t, removed)
+}
+
+func TestApp_Changelog(t *testing.T) {
+	registry := NewInMemPromptRegistry(setupTempDir(t))
+	require.NoError(t, registry.SaveTemplate("main.tmpl", "Hello"))
+	_, err := registry.Tag("main.tmpl", 0, "v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, registry.SaveTemplate("main.tmpl", "Hi"))
+	app, stdout, _ := newTestApp(t, WithRegistry(registry))
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "--json", "changelog", "--from", "v1.0.0"}))
+	var changelog Changelog
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &changelog))
+	assert.Equal(t, CurrentRef, changelog.To)
+	require.Len(t, changelog.Groups, 1)
+	assert.Equal(t, ChangeModified, changelog.Groups[0].Changes[0].Kind)
+}
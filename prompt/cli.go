@@ -1,37 +1,101 @@
 package prompt
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/notzree/rprompt/v2/prompt/core"
 	"github.com/notzree/rprompt/v2/prompt/settings"
 	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
 )
 
-var registry *LocalPromptRegistry
-
-func InitCLI() *cli.Command {
-	// Load settings at startup
-	s, err := settings.Load()
-	if err != nil {
-		fmt.Printf("Warning: Failed to load settings: %v\n", err)
-	} else if s.RegistryDir != "" {
-		// Initialize registry if directory is set
-		registry = NewInMemPromptRegistry(s.RegistryDir)
-	}
-
+// Command returns the root command of the CLI, with every subcommand acting on the app's registry
+func (a *App) Command() *cli.Command {
 	return &cli.Command{
 		Name:  "rprompt",
 		Usage: "A CLI tool for managing and generating prompts",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print command results as JSON",
+			},
+			&cli.BoolFlag{
+				Name:    "quiet",
+				Aliases: []string{"q"},
+				Usage:   "Only print command results and errors",
+			},
+			&cli.BoolFlag{
+				Name:  "verbose",
+				Usage: "Print debug output",
+			},
+			&cli.BoolFlag{
+				Name:  "no-color",
+				Usage: "Disable colored output",
+			},
+			&cli.StringFlag{
+				Name:  "profile",
+				Usage: "Model profile from the settings to use, see 'rprompt profile'. Defaults to the default profile",
+			},
+			&cli.StringFlag{
+				Name:  "audit-log",
+				Usage: "Append a JSON line describing every rendered prompt to this file",
+			},
+			&cli.StringFlag{
+				Name:  "usage-log",
+				Usage: "Append a JSON line noting every rendered template to this file, which 'rprompt unused' reads",
+			},
+			&cli.StringFlag{
+				Name:  "context",
+				Usage: "Where [[retrieve]] finds documents: a glob of files such as docs/*.md, or the URL of a retrieval service",
+			},
+			&cli.StringFlag{
+				Name:  "flags",
+				Usage: "Feature flags [[flag]] checks: comma separated flags to turn on, env to read " + EnvFlagsPrefix + "<NAME> variables, or the URL of a flag service. Defaults to the flags setting",
+			},
+			&cli.StringFlag{
+				Name:  "registry",
+				Usage: "Named registry from the settings to use, see 'rprompt use'. Defaults to the current registry",
+			},
+		},
+		Before: func(ctx context.Context, c *cli.Command) (context.Context, error) {
+			if err := a.configureOutput(c); err != nil {
+				return ctx, err
+			}
+			if a.settingsErr != nil {
+				a.out.Warnf("Failed to load settings: %v", a.settingsErr)
+			}
+			if a.projectPath != "" {
+				a.out.Debugf("Using project settings from %s", a.projectPath)
+			}
+			if name := c.String("registry"); name != "" {
+				registry, err := openRegistry(a.effectiveSettings(), name)
+				if err != nil {
+					return ctx, err
+				}
+				a.registry = registry
+			}
+			return ctx, nil
+		},
 		Commands: []*cli.Command{
 			{
 				Name:    "set",
 				Aliases: []string{"s"},
-				Usage:   "Set the prompt registry directory",
+				Usage:   "Set the prompt registry directory. 'rprompt config set registry_dir <dir>' sets it too",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     "directory",
@@ -39,13 +103,210 @@ func InitCLI() *cli.Command {
 						Usage:    "Directory path for the prompt registry",
 						Required: true,
 					},
+					&cli.StringFlag{
+						Name:    "name",
+						Aliases: []string{"n"},
+						Usage:   "Save the directory as a named registry to switch to with 'rprompt use' instead",
+					},
+				},
+				Action: a.setRegistryDir,
+			},
+			{
+				Name:  "config",
+				Usage: "Read and change the settings. Keys are dotted, such as format or profiles.fast.model",
+				Commands: []*cli.Command{
+					{
+						Name:      "get",
+						Usage:     "Print a setting, including the project's settings",
+						ArgsUsage: "<key>",
+						Action:    a.configGet,
+					},
+					{
+						Name:      "set",
+						Usage:     "Change a setting in your settings file",
+						ArgsUsage: "<key> <value>",
+						Action:    a.configSet,
+					},
+					{
+						Name:      "unset",
+						Usage:     "Remove a setting from your settings file",
+						ArgsUsage: "<key>",
+						Action:    a.configUnset,
+					},
+					{
+						Name:   "list",
+						Usage:  "Print every setting, including the project's settings",
+						Action: a.configList,
+					},
 				},
-				Action: setRegistryDir,
+			},
+			{
+				Name:      "use",
+				Usage:     "Switch to a named registry, or list the named registries",
+				ArgsUsage: "[name]",
+				Action:    a.useRegistry,
 			},
 			{
 				Name:    "generate",
 				Aliases: []string{"gen", "g"},
 				Usage:   "Generate a prompt from a template and config",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:    "template",
+						Aliases: []string{"t"},
+						Usage:   "Path to the template file (relative to registry directory). May be repeated",
+					},
+					&cli.StringSliceFlag{
+						Name:  "stack",
+						Usage: "Stack from the settings to render as one prompt, its templates a blank line apart. May be repeated",
+					},
+					&cli.StringFlag{
+						Name:     "config",
+						Aliases:  []string{"c"},
+						Usage:    "Path to the config file (relative to registry directory)",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Path to output the generated prompt. May contain {{template_stem}}, {{template_path}} or {{template_dir}}. Defaults to the defaults.output setting",
+					},
+					&cli.BoolFlag{
+						Name:  "clipboard",
+						Usage: "Copy the generated prompt to the system clipboard",
+					},
+					&cli.StringFlag{
+						Name:  "validation",
+						Usage: "How strictly to check the config against the template: default, off, warn or strict. Defaults to the defaults.validation setting",
+						Value: "default",
+					},
+					&cli.BoolFlag{
+						Name:  "hash-footer",
+						Usage: "Append a hash identifying the template and config versions to the prompt",
+					},
+					&cli.StringFlag{
+						Name:  "provenance",
+						Usage: "Write a comment recording the template, its version hash, the render time and the config hash to the prompt: header or footer",
+					},
+					&cli.BoolFlag{
+						Name:  "deterministic",
+						Usage: "Fix the output of the now, randInt and shuffle template functions",
+					},
+					&cli.StringFlag{
+						Name:  "history",
+						Usage: "JSON file holding the conversation [[history]] renders, an array of turns with a role and content",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Output format: text, messages-json, anthropic, json or yaml wrapping the prompt and its sections with build metadata, markdown with a heading per role, or a format a plugin adds",
+						Value: FormatText,
+					},
+					&cli.IntFlag{
+						Name:  "max-tokens",
+						Usage: "Trim [[trimmable]] sections until the prompt fits this many tokens. Defaults to the profile's context window less its output tokens",
+					},
+					&cli.StringFlag{
+						Name:  "model",
+						Usage: "Model the prompt is for, which templates read as .model and whose tokenizer counts tokens for --max-tokens. Defaults to the profile's model and tokenizer, then the defaults.model setting",
+					},
+					&cli.StringFlag{
+						Name:  "provider",
+						Usage: "Provider the prompt is for, which templates read as .provider. Defaults to the profile's provider, then the defaults.provider setting",
+					},
+					&cli.BoolFlag{
+						Name:  "response-format",
+						Usage: "Append the response schema from the template's front matter to the prompt",
+					},
+					&cli.BoolFlag{
+						Name:  "allow-draft",
+						Usage: "Build templates marked as drafts in their front matter, see 'rprompt publish'",
+					},
+					&cli.StringFlag{
+						Name:  "locale",
+						Usage: "Build the locale's variants of the template and its includes, such as main.fr.tmpl for main.tmpl",
+					},
+					&cli.BoolFlag{
+						Name:  "fail-on-warn",
+						Usage: "Fail instead of writing a prompt whose build has warnings, such as unused keys or <no value> output. Defaults to the defaults.fail_on_warn setting",
+					},
+					&cli.StringSliceFlag{
+						Name:  "label",
+						Usage: "Label as key=value to attach to the --audit-log record. May be repeated",
+					},
+					&cli.BoolFlag{
+						Name:  "only-changed",
+						Usage: "Skip outputs whose template, includes, config and options haven't changed since they were last generated, as recorded in " + BuildStateFile,
+					},
+				},
+				Action: a.withPlugins(a.generatePrompt),
+			},
+			{
+				Name:  "run",
+				Usage: "Render a prompt and stream a completion for it from an LLM provider. If the template declares a response schema, it is appended to the prompt and the reply is checked against it",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "template",
+						Aliases:  []string{"t"},
+						Usage:    "Path to the template file (relative to registry directory)",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Usage:   "Path to the config file (relative to registry directory)",
+					},
+					&cli.StringFlag{
+						Name:  "provider",
+						Usage: "Provider to send the prompt to: openai or anthropic. The API key is read from OPENAI_API_KEY or ANTHROPIC_API_KEY. Defaults to the profile's provider, then the defaults.provider setting",
+					},
+					&cli.StringFlag{
+						Name:  "model",
+						Usage: "Model to use, e.g. gpt-4o. Defaults to the profile's model, then the defaults.model setting",
+					},
+					&cli.IntFlag{
+						Name:  "max-tokens",
+						Usage: "Maximum number of tokens to generate. Defaults to the profile's output tokens",
+					},
+					&cli.BoolFlag{
+						Name:  "allow-draft",
+						Usage: "Build templates marked as drafts in their front matter, see 'rprompt publish'",
+					},
+					&cli.StringFlag{
+						Name:  "locale",
+						Usage: "Build the locale's variants of the template and its includes, such as main.fr.tmpl for main.tmpl",
+					},
+					&cli.StringSliceFlag{
+						Name:  "label",
+						Usage: "Label as key=value to attach to the --audit-log record. May be repeated",
+					},
+				},
+				Action: a.withPlugins(a.runPrompt),
+			},
+			{
+				Name:  "preview",
+				Usage: "Render a template with a config, highlighting the text its actions wrote apart from the template's own",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "template",
+						Aliases:  []string{"t"},
+						Usage:    "Path to the template file (relative to registry directory)",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Usage:   "Path to the config file (relative to registry directory)",
+					},
+					&cli.BoolFlag{
+						Name:  "annotate",
+						Usage: "Follow each highlighted region with the variables it came from",
+					},
+				},
+				Action: a.withPlugins(a.previewPrompt),
+			},
+			{
+				Name:  "fanout",
+				Usage: "Generate a prompt per element of an array in the config, such as one email per customer",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     "template",
@@ -59,14 +320,136 @@ func InitCLI() *cli.Command {
 						Usage:    "Path to the config file (relative to registry directory)",
 						Required: true,
 					},
+					&cli.StringFlag{
+						Name:     "each",
+						Usage:    "Path of the array in the config to render the template for each element of, such as .customers. Each element's keys are layered over the rest of the config",
+						Required: true,
+					},
 					&cli.StringFlag{
 						Name:     "output",
 						Aliases:  []string{"o"},
-						Usage:    "Path to output the generated prompt",
+						Usage:    "Path to output each prompt, a template executed with the element's config such as 'out/{{.id}}.txt'. May contain {{template_stem}}, {{template_path}} or {{template_dir}}",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "validation",
+						Usage: "How strictly to check the config against the template: default, off, warn or strict. Defaults to the defaults.validation setting",
+						Value: "default",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Output format: text, messages-json, anthropic, json, yaml, markdown or a format a plugin adds",
+						Value: FormatText,
+					},
+					&cli.BoolFlag{
+						Name:  "deterministic",
+						Usage: "Fix the output of the now, randInt and shuffle template functions",
+					},
+					&cli.BoolFlag{
+						Name:  "allow-draft",
+						Usage: "Build templates marked as drafts in their front matter, see 'rprompt publish'",
+					},
+					&cli.StringFlag{
+						Name:  "locale",
+						Usage: "Build the locale's variants of the template and its includes, such as main.fr.tmpl for main.tmpl",
+					},
+				},
+				Action: a.withPlugins(a.fanoutPrompts),
+			},
+			{
+				Name:  "tokens",
+				Usage: "Count the tokens of a rendered prompt, with its share of the profile's context window and its cost",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "template",
+						Aliases:  []string{"t"},
+						Usage:    "Path to the template file (relative to registry directory)",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Usage:   "Path to the config file (relative to registry directory)",
+					},
+				},
+				Action: a.withPlugins(a.countTokens),
+			},
+			{
+				Name:  "bench",
+				Usage: "Measure the time and allocations of parsing, resolving and executing a template",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "template",
+						Aliases:  []string{"t"},
+						Usage:    "Path to the template file (relative to registry directory)",
 						Required: true,
 					},
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Usage:   "Path to the config file (relative to registry directory)",
+					},
+					&cli.IntFlag{
+						Name:    "iterations",
+						Aliases: []string{"n"},
+						Usage:   "Number of times to run each phase",
+						Value:   1000,
+					},
+				},
+				Action: a.withPlugins(a.benchTemplate),
+			},
+			{
+				Name:  "profile",
+				Usage: "Manage the model profiles in the settings",
+				Commands: []*cli.Command{
+					{
+						Name:      "set",
+						Usage:     "Create or replace a model profile",
+						ArgsUsage: "<name>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "provider",
+								Usage: "Provider the run command sends prompts to: openai or anthropic",
+							},
+							&cli.StringFlag{
+								Name:     "model",
+								Usage:    "Model name, e.g. gpt-4o",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "tokenizer",
+								Usage: "How to count tokens: estimate or claude. Defaults to the tokenizer for the model",
+							},
+							&cli.IntFlag{
+								Name:  "max-context",
+								Usage: "Context window in tokens",
+							},
+							&cli.IntFlag{
+								Name:  "max-output-tokens",
+								Usage: "Tokens to generate, reserved out of the context window",
+							},
+							&cli.FloatFlag{
+								Name:  "input-price",
+								Usage: "Dollars per million input tokens",
+							},
+							&cli.FloatFlag{
+								Name:  "output-price",
+								Usage: "Dollars per million output tokens",
+							},
+							&cli.BoolFlag{
+								Name:  "default",
+								Usage: "Use the profile when no --profile is given",
+							},
+						},
+						Action: a.setProfile,
+					},
+					{
+						Name:    "list",
+						Aliases: []string{"ls"},
+						Usage:   "List the model profiles",
+						Action:  a.listProfiles,
+					},
 				},
-				Action: generatePrompt,
 			},
 			{
 				Name:  "gen-cfg",
@@ -85,7 +468,20 @@ func InitCLI() *cli.Command {
 						Required: true,
 					},
 				},
-				Action: generateConfig,
+				Action: a.withPlugins(a.generateConfig),
+			},
+			{
+				Name:  "tool-schema",
+				Usage: "Print an OpenAI function tool whose parameters are the template's config",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "template",
+						Aliases:  []string{"t"},
+						Usage:    "Path to the template file (relative to registry directory)",
+						Required: true,
+					},
+				},
+				Action: a.withPlugins(a.toolSchema),
 			},
 			{
 				Name:  "new-template",
@@ -98,7 +494,7 @@ func InitCLI() *cli.Command {
 						Required: true,
 					},
 				},
-				Action: newTemplate,
+				Action: a.newTemplate,
 			},
 			{
 				Name:  "new-config",
@@ -111,147 +507,2624 @@ func InitCLI() *cli.Command {
 						Required: true,
 					},
 				},
-				Action: newConfig,
+				Action: a.newConfig,
 			},
-		},
-	}
-}
-
-func setRegistryDir(ctx context.Context, c *cli.Command) error {
-	dir := c.String("directory")
-	absDir, err := filepath.Abs(dir)
-	if err != nil {
-		return fmt.Errorf("failed to resolve absolute path: %w", err)
-	}
-
-	// Check if directory exists
-	if _, err := os.Stat(absDir); os.IsNotExist(err) {
-		return fmt.Errorf("directory does not exist: %s", absDir)
-	}
-
-	// Save the directory in settings
-	s := &settings.Settings{
-		RegistryDir: absDir,
-	}
-	if err := s.Save(); err != nil {
-		return fmt.Errorf("failed to save settings: %w", err)
-	}
-
-	registry = NewInMemPromptRegistry(absDir)
-	return nil
-}
-
-func generatePrompt(ctx context.Context, c *cli.Command) error {
-	if registry == nil {
-		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
-	}
-	// relative to directory
-	templatePath := c.String("template")
-	configPath := c.String("config")
-	//absolute
-	outputPath := c.String("output")
-
-	// Create a new prompt system
-	system, err := NewPromptSystem(registry)
-	if err != nil {
-		return fmt.Errorf("failed to create prompt system: %w", err)
-	}
-
-	// Build the prompt
-	prompt, err := system.Build(templatePath, configPath)
-	if err != nil {
-		// If there's an error, try to generate/fill missing config fields
-		if err := system.GenerateOrFillConfig(templatePath, configPath); err != nil {
-			return fmt.Errorf("failed to generate/fill config: %w", err)
-		}
-
-		// Retry with the updated config
-		prompt, err = system.Build(templatePath, configPath)
-		if err != nil {
-			return fmt.Errorf("failed to build prompt with updated config: %w", err)
-		}
-	}
-
-	// Write the prompt to the output file
-	if err := os.WriteFile(outputPath, []byte(prompt), 0644); err != nil {
-		return fmt.Errorf("failed to write output file: %w", err)
-	}
-
-	fmt.Printf("Successfully generated prompt at: %s\n", outputPath)
-	return nil
-}
-
-func generateConfig(ctx context.Context, c *cli.Command) error {
-	if registry == nil {
-		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
-	}
-
-	templatePath := c.String("template")
-	configPath := c.String("config")
-
-	system, err := NewPromptSystem(registry)
-	if err != nil {
-		return fmt.Errorf("failed to create prompt system: %w", err)
-	}
-
-	if err := system.GenerateOrFillConfig(templatePath, configPath); err != nil {
-		return fmt.Errorf("failed to generate/fill config: %w", err)
-	}
-
-	fmt.Printf("Successfully generated/updated config at: %s\n", configPath)
-	return nil
-}
-
-func newTemplate(ctx context.Context, c *cli.Command) error {
-	if registry == nil {
-		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
-	}
-
-	path := c.String("path")
-	if !strings.HasSuffix(path, ".tmpl") {
-		return fmt.Errorf("template must end in .tmpl")
-	}
-
-	fullPath := filepath.Join(registry.Directory, path)
-
-	// Create directories if they don't exist
-	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
-		return fmt.Errorf("failed to create directories: %w", err)
-	}
-
-	// Create an empty template file
-	if err := os.WriteFile(fullPath, []byte(""), 0644); err != nil {
-		return fmt.Errorf("failed to create template file: %w", err)
-	}
-
-	fmt.Printf("Created new template file at: %s\n", fullPath)
-	return nil
-}
-
-func newConfig(ctx context.Context, c *cli.Command) error {
-	if registry == nil {
-		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
-	}
-
-	path := c.String("path")
-	fullPath := filepath.Join(registry.Directory, path)
-
-	// Create directories if they don't exist
-	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
-		return fmt.Errorf("failed to create directories: %w", err)
-	}
-
-	// Create an empty config file with a basic structure
-	emptyConfig := make(map[string]interface{})
-	data, err := json.MarshalIndent(emptyConfig, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
-	}
-
-	if err := os.WriteFile(fullPath, data, 0644); err != nil {
+			{
+				Name:    "list",
+				Aliases: []string{"ls"},
+				Usage:   "List the templates in the registry",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "owners",
+						Usage: "List each template's owners, from its front matter or the registry's " + OwnersFile + " file",
+					},
+				},
+				Action: a.listTemplates,
+			},
+			{
+				Name:      "repl",
+				Usage:     "Fill in a template one value at a time, re-rendering after each change. Type help for the commands",
+				ArgsUsage: "[template]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Usage:   "Config to start from",
+					},
+				},
+				Action: a.withPlugins(a.repl),
+			},
+			{
+				Name:   "tui",
+				Usage:  "Browse the templates in the terminal, preview them with a config and edit its values",
+				Action: a.withPlugins(a.browse),
+			},
+			{
+				Name:  "unused",
+				Usage: "List templates that --usage-log shows weren't rendered recently, directly or as a dependency",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "since",
+						Usage: "How far back to look, as an age such as 90d or 12w, a duration such as 36h or a date",
+						Value: "90d",
+					},
+				},
+				Action: a.listUnused,
+			},
+			{
+				Name:  "plugins",
+				Usage: "Manage the plugins that add template functions, output formats and registries",
+				Commands: []*cli.Command{
+					{
+						Name:   "list",
+						Usage:  "List the installed plugins and what they add",
+						Action: a.withPlugins(a.listPlugins),
+					},
+					{
+						Name:      "install",
+						Usage:     "Check a plugin executable and copy it into the plugins directory, see the plugins_dir setting",
+						ArgsUsage: "<path>",
+						Action:    a.installPlugin,
+					},
+				},
+			},
+			{
+				Name:  "migrate",
+				Usage: "Rewrite the registry's templates and configs for a change that spans many files",
+				Commands: []*cli.Command{
+					{
+						Name:      "delims",
+						Usage:     "Change the delimiters of every template and save them in the settings, e.g. '{{,}}'",
+						ArgsUsage: "<left,right>",
+						Flags:     []cli.Flag{dryRunFlag()},
+						Action:    a.withPlugins(a.migrateDelims),
+					},
+					{
+						Name:      "rename-var",
+						Usage:     "Rename a variable in the templates that use it and the configs that set it",
+						ArgsUsage: "<from> <to>",
+						Flags:     []cli.Flag{dryRunFlag()},
+						Action:    a.withPlugins(a.renameVariable),
+					},
+					{
+						Name:      "move",
+						Usage:     "Move a template with its config, prompt tests and history, rewriting the templates that include it",
+						ArgsUsage: "<from> <to>",
+						Flags:     []cli.Flag{dryRunFlag()},
+						Action:    a.withPlugins(a.moveTemplate),
+					},
+				},
+			},
+			{
+				Name:      "info",
+				Usage:     "Describe a template's owners, dependencies, sections and variables",
+				ArgsUsage: "<template>",
+				Action:    a.withPlugins(a.templateInfo),
+			},
+			{
+				Name:      "history",
+				Usage:     "List the saved versions of a template or config",
+				ArgsUsage: "<path>",
+				Action:    a.showHistory,
+			},
+			{
+				Name:      "show",
+				Usage:     "Print a template or config, or one of its saved versions such as agents/billing.tmpl@v3",
+				ArgsUsage: "<path>[@v<version>]",
+				Action:    a.showVersion,
+			},
+			{
+				Name:      "diff",
+				Usage:     "Compare two versions of a template or config line by line, by default a saved version with the current file",
+				ArgsUsage: "<path>@v<version> [<path>[@v<version>]]",
+				Action:    a.diffVersions,
+			},
+			{
+				Name:      "publish",
+				Usage:     "Remove the draft flag from a template's front matter, so it can be built without --allow-draft",
+				ArgsUsage: "<path>",
+				Action:    a.publishTemplate,
+			},
+			{
+				Name:  "changelog",
+				Usage: "Summarize the template and config changes between two registry states, grouped by template, for release notes",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "from",
+						Usage:    "Registry state to compare from: a tag such as v1.2.0, a date such as 2026-01-31 or " + CurrentRef,
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "to",
+						Usage: "Registry state to compare to, like --from",
+						Value: CurrentRef,
+					},
+				},
+				Action: a.changelog,
+			},
+			{
+				Name:      "tag",
+				Usage:     "Tag the current or a saved version of a template or config with a semantic version such as v1.2.0, which code can pin with Find(\"main.tmpl@v1.2.0\")",
+				ArgsUsage: "<path>[@v<version>] <tag>",
+				Action:    a.tagVersion,
+			},
+			{
+				Name:      "rollback",
+				Usage:     "Restore a saved version of a template or config as its newest version. Without a version it restores the one before the newest",
+				ArgsUsage: "<path>[@v<version>]",
+				Action:    a.rollback,
+			},
+			{
+				Name:   "stats",
+				Usage:  "Summarize templates, token lengths, partials, variables and incomplete configs in the registry",
+				Action: a.withPlugins(a.registryStats),
+			},
+			{
+				Name:  "build-all",
+				Usage: "Generate a prompt for every template with a config sharing its name, such as main.json for main.tmpl, skipping those unchanged since the last run",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "output",
+						Aliases:  []string{"o"},
+						Usage:    "Path to output each prompt. Must contain {{template_stem}} or {{template_path}}",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "validation",
+						Usage: "How strictly to check the config against the template: default, off, warn or strict. Defaults to the defaults.validation setting",
+						Value: "default",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Output format: text, messages-json, anthropic, json, yaml, markdown or a format a plugin adds",
+						Value: FormatText,
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Generate every prompt, even those unchanged since the last run",
+					},
+				},
+				Action: a.withPlugins(a.buildAll),
+			},
+			{
+				Name:   "index",
+				Usage:  "Write " + IndexFile + " so listing templates and walking their dependencies doesn't read every file",
+				Action: a.withPlugins(a.indexRegistry),
+			},
+			{
+				Name:  "compile",
+				Usage: "Compile every template and config into a bundle a CompiledRegistry serves without the registry directory",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Path to write the bundle to",
+						Value:   "prompts.bundle",
+					},
+				},
+				Action: a.withPlugins(a.compileRegistry),
+			},
+			{
+				Name:  "test",
+				Usage: "Run the cases in every " + PromptTestSuffix + " file in the registry",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "junit",
+						Usage: "Also write the results as JUnit XML to this file",
+					},
+					&cli.BoolFlag{
+						Name:  "coverage",
+						Usage: "Report the if, with and range branches of the tested templates no case rendered",
+					},
+				},
+				Action: a.withPlugins(a.runPromptTests),
+			},
+			{
+				Name:  "mutation-check",
+				Usage: "Build a template with each variable removed from and emptied in the config, and report the omissions that silently break the output",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "template",
+						Aliases:  []string{"t"},
+						Usage:    "Path to the template file (relative to registry directory)",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "config",
+						Aliases:  []string{"c"},
+						Usage:    "Path to a config the template builds with (relative to registry directory)",
+						Required: true,
+					},
+				},
+				Action: a.withPlugins(a.mutationCheck),
+			},
+			{
+				Name:   "fuzz-check",
+				Usage:  "Generate configs, schemas and builds for every template with configs of varying shapes and report any that panic",
+				Action: a.withPlugins(a.fuzzCheck),
+			},
+			{
+				Name:  "lint",
+				Usage: "Check every template for parse errors, unknown functions and includes of missing templates without rendering it",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Output format: text, or sarif or rdjson for code review tools and editors",
+						Value: DiagnosticsText,
+					},
+				},
+				Action: a.withPlugins(a.lintRegistry),
+			},
+			{
+				Name:  "check",
+				Usage: "List deprecated templates and variables along with the templates and configs that still use them",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Output format: text, or sarif or rdjson for code review tools and editors",
+						Value: DiagnosticsText,
+					},
+					&cli.BoolFlag{
+						Name:  "require-owner",
+						Usage: "Fail if any template has no owner in its front matter or the registry's " + OwnersFile + " file",
+					},
+					&cli.BoolFlag{
+						Name:  "locales",
+						Usage: "Fail if any template translated into some locales, such as main.fr.tmpl, is missing a translation another template has",
+					},
+					&cli.StringFlag{
+						Name:  "default-locale",
+						Usage: "Locale the unsuffixed templates are written in, so --locales doesn't expect a variant for it",
+					},
+				},
+				Action: a.withPlugins(a.checkRegistry),
+			},
+			{
+				Name:  "audit",
+				Usage: "Scan the templates for prompt injection risks: user input in system sections, user input without delimiters and instructions to ignore previous instructions",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "fail",
+						Usage: "Fail if any risk is found, for CI",
+					},
+				},
+				Action: a.withPlugins(a.auditRegistry),
+			},
+			{
+				Name:      "open",
+				Usage:     "Open a template in $EDITOR",
+				ArgsUsage: "<template>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Usage:   "Config file to open side-by-side (relative to registry directory)",
+					},
+					&cli.BoolFlag{
+						Name:  "with-config",
+						Usage: "Open the config sharing the template's name side-by-side (main.tmpl -> main.json)",
+					},
+					&cli.BoolFlag{
+						Name:  "print",
+						Usage: "Print the absolute paths instead of opening an editor",
+					},
+				},
+				Action: a.openTemplate,
+			},
+			{
+				Name:  "export",
+				Usage: "Export a template with its dependencies and configs to a directory or .tar.gz archive",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "template",
+						Aliases:  []string{"t"},
+						Usage:    "Path to the template file (relative to registry directory)",
+						Required: true,
+					},
+					&cli.StringSliceFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Usage:   "Additional config files to include (relative to registry directory)",
+					},
+					&cli.StringFlag{
+						Name:     "out",
+						Usage:    "Output directory, or archive path ending in .tar.gz/.tgz",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Export format: rprompt, langchain, promptfoo or jinja",
+						Value: ExportNative,
+					},
+				},
+				Action: a.withPlugins(a.exportTemplate),
+			},
+			{
+				Name:  "serve",
+				Usage: "Serve the registry over an HTTP REST API",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "addr",
+						Usage: "Address to listen on",
+						Value: ":8080",
+					},
+					&cli.BoolFlag{
+						Name:  "ui",
+						Usage: "Also serve a web UI at /ui/ to browse templates, edit configs and preview renders",
+					},
+				},
+				Action: a.withPlugins(a.serveHTTP),
+			},
+			{
+				Name:  "grpc-serve",
+				Usage: "Serve the registry over gRPC, see api/rprompt.proto",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "addr",
+						Usage: "Address to listen on",
+						Value: ":9090",
+					},
+				},
+				Action: a.withPlugins(a.serveGRPC),
+			},
+			{
+				Name:   "mcp-serve",
+				Usage:  "Serve the registry's templates as MCP prompts over stdin and stdout",
+				Action: a.withPlugins(a.serveMCP),
+			},
+			{
+				Name:   "lsp",
+				Usage:  "Run a language server for the registry's templates over stdin and stdout, for editors such as VS Code and Neovim",
+				Action: a.withPlugins(a.serveLSP),
+			},
+			{
+				Name:      "import",
+				Usage:     "Import external prompt files into the registry",
+				ArgsUsage: "<file-or-dir>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "from",
+						Usage: "Syntax of the source files: go, jinja or fstring",
+						Value: SyntaxGo,
+					},
+					&cli.StringFlag{
+						Name:  "convert-delims",
+						Usage: "Delimiters used by Go template source files to rewrite to [[ ]], e.g. '{{,}}'",
+					},
+					&cli.StringFlag{
+						Name:    "dest",
+						Aliases: []string{"d"},
+						Usage:   "Directory to import into (relative to registry directory)",
+					},
+					&cli.BoolFlag{
+						Name:  "gen-cfg",
+						Usage: "Generate a config next to each imported template",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Overwrite templates that already exist in the registry",
+					},
+				},
+				Action: a.importPrompts,
+			},
+		},
+	}
+}
+
+func (a *App) setRegistryDir(ctx context.Context, c *cli.Command) error {
+	dir := c.String("directory")
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve absolute path: %w", err)
+	}
+
+	// Check if directory exists
+	if _, err := os.Stat(absDir); os.IsNotExist(err) {
+		return fmt.Errorf("directory does not exist: %s", absDir)
+	}
+
+	// Save the directory in settings
+	s := *a.settings
+	name := c.String("name")
+	if name != "" {
+		s.Registries = make(map[string]settings.Registry, len(a.settings.Registries)+1)
+		for existing, r := range a.settings.Registries {
+			s.Registries[existing] = r
+		}
+		s.Registries[name] = settings.Registry{Dir: absDir}
+	} else {
+		s.RegistryDir = absDir
+		// An explicit directory replaces the named registry in use
+		s.CurrentRegistry = ""
+	}
+	if err := a.saveSettings(&s); err != nil {
+		return fmt.Errorf("failed to save settings: %w", err)
+	}
+	a.settings = &s
+	if name != "" {
+		return nil
+	}
+
+	// The directory is used even if the project settings point elsewhere, but with their delimiters
+	effective := a.effectiveSettings()
+	effective.RegistryDir, effective.CurrentRegistry = absDir, ""
+	a.registry, err = openRegistry(effective, "")
+	return err
+}
+
+func (a *App) configGet(ctx context.Context, c *cli.Command) error {
+	key := c.Args().First()
+	if key == "" {
+		return fmt.Errorf("a setting key is required")
+	}
+	value, err := a.effectiveSettings().Get(key)
+	if err != nil {
+		return err
+	}
+	return a.out.Report(map[string]any{"key": key, "value": value}, func() {
+		a.out.Println(formatSetting(value))
+	})
+}
+
+func (a *App) configSet(ctx context.Context, c *cli.Command) error {
+	if c.Args().Len() != 2 {
+		return fmt.Errorf("a setting key and value are required")
+	}
+	key, value := c.Args().Get(0), c.Args().Get(1)
+	if key == "defaults.output" {
+		// The output is written relative to wherever generate runs otherwise
+		absOutput, err := filepath.Abs(value)
+		if err != nil {
+			return fmt.Errorf("failed to resolve absolute path: %w", err)
+		}
+		value = absOutput
+	}
+	if key == "registry_dir" || (strings.HasPrefix(key, "registries.") && strings.HasSuffix(key, ".dir")) {
+		absDir, err := filepath.Abs(value)
+		if err != nil {
+			return fmt.Errorf("failed to resolve absolute path: %w", err)
+		}
+		if _, err := os.Stat(absDir); os.IsNotExist(err) {
+			return fmt.Errorf("directory does not exist: %s", absDir)
+		}
+		value = absDir
+	}
+	return a.changeSetting(key, func(s *settings.Settings) error { return s.Set(key, value) })
+}
+
+func (a *App) configUnset(ctx context.Context, c *cli.Command) error {
+	key := c.Args().First()
+	if key == "" {
+		return fmt.Errorf("a setting key is required")
+	}
+	return a.changeSetting(key, func(s *settings.Settings) error { return s.Unset(key) })
+}
+
+// changeSetting applies change to the user's settings, see applySetting, and reports the new value of key
+func (a *App) changeSetting(key string, change func(*settings.Settings) error) error {
+	if err := a.applySetting(key, change); err != nil {
+		return err
+	}
+	value, _ := a.settings.Get(key)
+	return a.out.Report(map[string]any{"key": key, "value": value}, func() {
+		a.out.Successf("Saved %s", key)
+	})
+}
+
+// applySetting applies change to a copy of the user's settings, validates and saves it, and reopens the
+// registry the settings point to
+func (a *App) applySetting(key string, change func(*settings.Settings) error) error {
+	s := a.settings.Clone()
+	if err := change(s); err != nil {
+		return err
+	}
+	// The user's own values are checked too, in case the project settings override them, but may refer to
+	// the project's profiles and registries
+	project := a.project
+	if project == nil {
+		project = &settings.Settings{}
+	}
+	for _, merged := range []*settings.Settings{s.Merge(a.project), project.Merge(s)} {
+		if err := validateSettings(merged); err != nil {
+			return fmt.Errorf("invalid settings: %w", err)
+		}
+	}
+	if err := a.saveSettings(s); err != nil {
+		return fmt.Errorf("failed to save settings: %w", err)
+	}
+	a.settings = s
+
+	group, _, _ := strings.Cut(key, ".")
+	switch group {
+	case "registry_dir", "delims", "extensions", "registries", "current_registry":
+		registry, err := openRegistry(a.effectiveSettings(), "")
+		if err != nil {
+			return err
+		}
+		a.registry = registry
+	}
+	value, _ := s.Get(key)
+	if effective, _ := a.effectiveSettings().Get(key); !reflect.DeepEqual(value, effective) {
+		a.out.Warnf("The project settings in %s override %s", a.projectPath, key)
+	}
+	return nil
+}
+
+func (a *App) configList(ctx context.Context, c *cli.Command) error {
+	list, err := a.effectiveSettings().List()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(list))
+	for key := range list {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return a.out.Report(list, func() {
+		for _, key := range keys {
+			a.out.Printf("%s = %s\n", key, formatSetting(list[key]))
+		}
+	})
+}
+
+// formatSetting prints scalar settings as they are and groups of settings as JSON
+func formatSetting(value any) string {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Struct, reflect.Map:
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
+
+func (a *App) useRegistry(ctx context.Context, c *cli.Command) error {
+	name := c.Args().First()
+	if name == "" {
+		return a.listRegistries()
+	}
+	registry, err := openRegistry(a.effectiveSettings(), name)
+	if err != nil {
+		return err
+	}
+
+	s := *a.settings
+	s.CurrentRegistry = name
+	if err := a.saveSettings(&s); err != nil {
+		return fmt.Errorf("failed to save settings: %w", err)
+	}
+	a.settings = &s
+	a.registry = registry
+	if a.effectiveSettings().CurrentRegistry != name {
+		a.out.Warnf("The project settings in %s choose their own registry, use --registry %s to override them", a.projectPath, name)
+	}
+
+	if registry == nil {
+		plugin := a.effectiveSettings().Registries[name].Plugin
+		return a.out.Report(map[string]any{"registry": name, "plugin": plugin}, func() {
+			a.out.Successf("Using registry %s (plugin %s)", name, plugin)
+		})
+	}
+	return a.out.Report(map[string]any{"registry": name, "directory": registry.Directory}, func() {
+		a.out.Successf("Using registry %s (%s)", name, registry.Directory)
+	})
+}
+
+func (a *App) listRegistries() error {
+	s := a.effectiveSettings()
+	names := make([]string, 0, len(s.Registries))
+	for name := range s.Registries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return a.out.Report(map[string]any{"current": s.CurrentRegistry, "registries": s.Registries}, func() {
+		for _, name := range names {
+			marker := " "
+			if name == s.CurrentRegistry {
+				marker = "*"
+			}
+			location := s.Registries[name].Dir
+			if plugin := s.Registries[name].Plugin; plugin != "" {
+				location = "plugin " + plugin
+			}
+			a.out.Printf("%s %s\t%s\n", marker, name, location)
+		}
+	})
+}
+
+func (a *App) generatePrompt(ctx context.Context, c *cli.Command) error {
+	if a.promptRegistry() == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	// relative to directory
+	templatePaths := c.StringSlice("template")
+	stacks := a.effectiveSettings().Stacks
+	for _, name := range c.StringSlice("stack") {
+		if _, ok := stacks[name]; !ok {
+			return fmt.Errorf("unknown stack %q, stacks are set in the settings", name)
+		}
+		templatePaths = append(templatePaths, StackPath(name))
+	}
+	if len(templatePaths) == 0 {
+		return fmt.Errorf("--template or --stack is required")
+	}
+	configPath := c.String("config")
+	clipboard := c.Bool("clipboard")
+	defaults := a.effectiveSettings().Defaults
+	// The default output doesn't apply when copying to the clipboard instead
+	output := c.String("output")
+	if !clipboard {
+		output = flagOrDefault(c, "output", defaults.Output)
+	}
+	if output == "" && !clipboard {
+		return fmt.Errorf("either --output or --clipboard is required")
+	}
+	if clipboard && len(templatePaths) > 1 {
+		return fmt.Errorf("--clipboard can only be used with a single template")
+	}
+	onlyChanged := c.Bool("only-changed")
+	if onlyChanged && clipboard {
+		return fmt.Errorf("--only-changed can't be used with --clipboard")
+	}
+	if onlyChanged && a.registry == nil {
+		return fmt.Errorf("--only-changed needs a registry directory to keep its build state in")
+	}
+	//absolute, may contain placeholders such as {{template_stem}}
+	var outputPaths map[string]string
+	if output != "" {
+		var err error
+		outputPaths, err = ExpandOutputPaths(output, templatePaths)
+		if err != nil {
+			return err
+		}
+	}
+
+	validation, err := ParseValidationMode(flagOrDefault(c, "validation", defaults.Validation))
+	if err != nil {
+		return err
+	}
+
+	// Create a new prompt system
+	system, err := a.newPromptSystem()
+	if err != nil {
+		return fmt.Errorf("failed to create prompt system: %w", err)
+	}
+	system.Validation = validation
+	var opts []BuildOption
+	if c.Bool("hash-footer") {
+		opts = append(opts, WithHashFooter())
+	}
+	if position := c.String("provenance"); position != "" {
+		parsed, err := ParseProvenancePosition(position)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, WithProvenance(parsed))
+	}
+	if c.Bool("deterministic") {
+		opts = append(opts, WithDeterministic())
+	}
+	if path := c.String("history"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open history: %w", err)
+		}
+		history, err := ReadHistory(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read history %s: %w", path, err)
+		}
+		opts = append(opts, WithHistory(history))
+	}
+	if c.Bool("allow-draft") {
+		opts = append(opts, WithAllowDraft())
+	}
+	if locale := c.String("locale"); locale != "" {
+		opts = append(opts, WithLocale(locale))
+	}
+	if c.Bool("response-format") {
+		opts = append(opts, WithResponseFormat())
+	}
+	failOnWarn := c.Bool("fail-on-warn")
+	if !c.IsSet("fail-on-warn") {
+		failOnWarn = defaults.FailOnWarn
+	}
+	format := flagOrDefault(c, "format", a.effectiveSettings().Format)
+	// Plugin formats are given the messages as well as the output
+	formatPlugin := a.formatPlugin(format)
+	if formatPlugin == nil {
+		if err := CheckFormat(format); err != nil {
+			return err
+		}
+	}
+	if core.FormatNeedsMessages(format) || formatPlugin != nil {
+		opts = append(opts, WithMessages())
+	}
+	if core.FormatNeedsSections(format) {
+		opts = append(opts, WithSections())
+	}
+	budget, err := a.tokenBudget(c)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, budget...)
+	target, err := a.target(c)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, WithTarget(target))
+	labels, err := auditLabels(c)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, labels...)
+
+	type generated struct {
+		Template   string            `json:"template"`
+		Output     string            `json:"output,omitempty"`
+		Clipboard  bool              `json:"clipboard,omitempty"`
+		Issues     []ValidationIssue `json:"issues,omitempty"`
+		Warnings   []Warning         `json:"warnings,omitempty"`
+		Hash       string            `json:"hash,omitempty"`
+		Provenance *Provenance       `json:"provenance,omitempty"`
+		Skipped    bool              `json:"skipped,omitempty"`
+	}
+	var state *BuildState
+	var options string
+	if onlyChanged {
+		if state, err = a.registry.LoadBuildState(); err != nil {
+			return err
+		}
+		if options, err = a.buildOptionsDigest(c); err != nil {
+			return err
+		}
+	}
+	results := make([]generated, 0, len(templatePaths))
+	for _, templatePath := range templatePaths {
+		if state != nil {
+			// Templates that can't be hashed yet, such as those missing config values, are built to find out why
+			record, err := system.BuildRecord(ctx, templatePath, configPath, options, opts...)
+			if err == nil && state.Fresh(outputPaths[templatePath], record) {
+				results = append(results, generated{Template: templatePath, Output: outputPaths[templatePath], Skipped: true})
+				continue
+			}
+		}
+		built, err := buildOrFillConfig(ctx, system, templatePath, configPath, opts...)
+		if err != nil {
+			return fmt.Errorf("%s: %w", templatePath, err)
+		}
+		var prompt string
+		if formatPlugin != nil {
+			prompt, err = formatPlugin.Format(ctx, format, built)
+		} else {
+			prompt, err = FormatResult(format, built)
+		}
+		if err != nil {
+			return err
+		}
+		if failOnWarn && len(built.Warnings) > 0 {
+			return fmt.Errorf("%s: %w", templatePath, NewWarningsError(built.Warnings))
+		}
+		result := generated{Template: templatePath, Hash: built.Hash, Provenance: built.Provenance, Warnings: built.Warnings}
+		if validation == ValidationWarn {
+			result.Issues = built.Issues
+		}
+
+		if outputPath, ok := outputPaths[templatePath]; ok {
+			// Write the prompt to the output file
+			if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+				return fmt.Errorf("failed to create directories: %w", err)
+			}
+			if err := os.WriteFile(outputPath, []byte(prompt), 0644); err != nil {
+				return fmt.Errorf("failed to write output file: %w", err)
+			}
+			result.Output = outputPath
+			// Recorded after the build, which may have filled the config in
+			if state != nil {
+				record, err := system.BuildRecord(ctx, templatePath, configPath, options, opts...)
+				if err != nil {
+					return err
+				}
+				state.Record(outputPath, record, []byte(prompt))
+			}
+		}
+		if clipboard {
+			if err := CopyToClipboard(ctx, prompt); err != nil {
+				return err
+			}
+			result.Clipboard = true
+		}
+		results = append(results, result)
+	}
+	if state != nil {
+		if err := a.registry.SaveBuildState(state); err != nil {
+			return err
+		}
+	}
+
+	return a.out.Report(map[string]any{
+		"config":  configPath,
+		"outputs": results,
+	}, func() {
+		for _, result := range results {
+			if result.Skipped {
+				a.out.Infof("Skipped %s, unchanged since it was generated at: %s", result.Template, result.Output)
+				continue
+			}
+			reported := make(map[string]bool)
+			for _, issue := range result.Issues {
+				a.out.Warnf("%s: %s", result.Template, issue.Message)
+				reported[issue.Path] = true
+			}
+			for _, warning := range result.Warnings {
+				// Validation issues already cover warnings about the same config value
+				if warning.Path == "" || !reported[warning.Path] {
+					a.out.Warnf("%s: %s", result.Template, warning.Message)
+				}
+			}
+			if result.Output != "" {
+				a.out.Successf("Successfully generated prompt at: %s", result.Output)
+			}
+			if result.Clipboard {
+				a.out.Successf("Copied %s to the clipboard", result.Template)
+			}
+		}
+	})
+}
+
+func (a *App) runPrompt(ctx context.Context, c *cli.Command) error {
+	if a.promptRegistry() == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	profile, err := a.profile(c)
+	if err != nil {
+		return err
+	}
+	req := CompletionRequest{
+		Model:     c.String("model"),
+		MaxTokens: int(c.Int("max-tokens")),
+	}
+	providerName := c.String("provider")
+	if profile != nil {
+		if providerName == "" {
+			providerName = profile.Provider
+		}
+		if req.Model == "" {
+			req.Model = profile.Model
+		}
+		if req.MaxTokens == 0 {
+			req.MaxTokens = profile.MaxOutputTokens
+		}
+	}
+	defaults := a.effectiveSettings().Defaults
+	if providerName == "" {
+		providerName = defaults.Provider
+	}
+	if req.Model == "" {
+		req.Model = defaults.Model
+	}
+	if providerName == "" || req.Model == "" {
+		return fmt.Errorf("--provider and --model are required without a profile or defaults that set them")
+	}
+	provider, err := a.newProvider(providerName)
+	if err != nil {
+		return err
+	}
+
+	system, err := a.newPromptSystem()
+	if err != nil {
+		return fmt.Errorf("failed to create prompt system: %w", err)
+	}
+	schema, err := system.ResponseSchema(ctx, c.String("template"))
+	if err != nil {
+		return err
+	}
+	opts, err := auditLabels(c)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, WithResponseFormat(), WithTarget(Target{Provider: providerName, Model: req.Model}))
+	if c.Bool("allow-draft") {
+		opts = append(opts, WithAllowDraft())
+	}
+	if locale := c.String("locale"); locale != "" {
+		opts = append(opts, WithLocale(locale))
+	}
+	if profile != nil && profile.ContextBudget() > 0 {
+		tokenizer, err := TokenizerForProfile(profile)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, WithTokenBudget(profile.ContextBudget(), tokenizer))
+	}
+	req.Messages, err = system.BuildMessages(ctx, c.String("template"), c.String("config"), opts...)
+	if err != nil {
+		return err
+	}
+
+	var reply strings.Builder
+	if err := provider.Stream(ctx, req, io.MultiWriter(a.out.Out, &reply)); err != nil {
+		return err
+	}
+	// End the streamed completion with a newline
+	a.out.Println()
+	if schema != nil {
+		return ValidateResponse(schema, reply.String())
+	}
+	return nil
+}
+
+// profile returns the model profile selected by --profile, the default profile, or nil if there is neither
+func (a *App) profile(c *cli.Command) (*settings.ModelProfile, error) {
+	return a.effectiveSettings().Profile(c.String("profile"))
+}
+
+// flagOrDefault returns the value of a string flag, or def from the settings when the flag isn't given and
+// def is set
+func flagOrDefault(c *cli.Command, name, def string) string {
+	if c.IsSet(name) || def == "" {
+		return c.String(name)
+	}
+	return def
+}
+
+// target returns the model a prompt is generated for from --provider and --model, then the profile, then the
+// defaults
+func (a *App) target(c *cli.Command) (Target, error) {
+	profile, err := a.profile(c)
+	if err != nil {
+		return Target{}, err
+	}
+	target := Target{Provider: c.String("provider"), Model: c.String("model")}
+	if profile != nil {
+		if target.Provider == "" {
+			target.Provider = profile.Provider
+		}
+		if target.Model == "" {
+			target.Model = profile.Model
+		}
+	}
+	defaults := a.effectiveSettings().Defaults
+	if target.Provider == "" {
+		target.Provider = defaults.Provider
+	}
+	if target.Model == "" {
+		target.Model = defaults.Model
+	}
+	return target, nil
+}
+
+// tokenBudget returns the build options trimming a prompt to --max-tokens, or to the profile's context
+// budget when --max-tokens isn't given
+func (a *App) tokenBudget(c *cli.Command) ([]BuildOption, error) {
+	profile, err := a.profile(c)
+	if err != nil {
+		return nil, err
+	}
+	maxTokens := int(c.Int("max-tokens"))
+	if maxTokens <= 0 && profile != nil {
+		maxTokens = profile.ContextBudget()
+	}
+	if maxTokens <= 0 {
+		return nil, nil
+	}
+
+	tokenizer := TokenizerForModel(c.String("model"))
+	switch {
+	case c.String("model") != "":
+	case profile != nil:
+		tokenizer, err = TokenizerForProfile(profile)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		tokenizer = TokenizerForModel(a.effectiveSettings().Defaults.Model)
+	}
+	return []BuildOption{WithTokenBudget(maxTokens, tokenizer)}, nil
+}
+
+// auditLabels returns the build option attaching the --label flags to the audit record
+func auditLabels(c *cli.Command) ([]BuildOption, error) {
+	if len(c.StringSlice("label")) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string)
+	for _, label := range c.StringSlice("label") {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", label)
+		}
+		labels[key] = value
+	}
+	return []BuildOption{WithAuditLabels(labels)}, nil
+}
+
+func (a *App) countTokens(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	profile, err := a.profile(c)
+	if err != nil {
+		return err
+	}
+	tokenizer := EstimateTokens
+	if profile != nil {
+		if tokenizer, err = TokenizerForProfile(profile); err != nil {
+			return err
+		}
+	}
+
+	system, err := a.newPromptSystem()
+	if err != nil {
+		return fmt.Errorf("failed to create prompt system: %w", err)
+	}
+	// Drafts are counted too, since sizing a prompt is part of writing it
+	result, err := system.BuildWithResult(ctx, c.String("template"), c.String("config"), WithAllowDraft())
+	if err != nil {
+		return err
+	}
+
+	type tokenCount struct {
+		Template   string  `json:"template"`
+		Tokens     int     `json:"tokens"`
+		Profile    string  `json:"profile,omitempty"`
+		MaxContext int     `json:"max_context,omitempty"`
+		InputCost  float64 `json:"input_cost,omitempty"`
+	}
+	count := tokenCount{Template: c.String("template"), Tokens: tokenizer(result.Output)}
+	if profile != nil {
+		count.Profile = profile.Model
+		count.MaxContext = profile.MaxContext
+		count.InputCost = profile.InputCost(count.Tokens)
+	}
+
+	return a.out.Report(count, func() {
+		a.out.Printf("%d tokens\n", count.Tokens)
+		if count.MaxContext > 0 {
+			a.out.Printf("%.1f%% of the %d token context window of %s\n", 100*float64(count.Tokens)/float64(count.MaxContext), count.MaxContext, count.Profile)
+		}
+		if count.InputCost > 0 {
+			a.out.Printf("$%.6f input cost\n", count.InputCost)
+		}
+	})
+}
+
+func (a *App) benchTemplate(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	system, err := a.newPromptSystem()
+	if err != nil {
+		return fmt.Errorf("failed to create prompt system: %w", err)
+	}
+	report, err := system.Bench(ctx, c.String("template"), c.String("config"), int(c.Int("iterations")))
+	if err != nil {
+		return err
+	}
+
+	return a.out.Report(report, func() {
+		a.out.Printf("%s, %d iterations\n", report.Template, report.Iterations)
+		for _, phase := range []struct {
+			name string
+			BenchPhase
+		}{{"parse", report.Parse}, {"resolve", report.Resolve}, {"execute", report.Execute}} {
+			a.out.Printf("  %-8s %12s/op %8d allocs/op %10d B/op\n", phase.name, time.Duration(phase.NsPerOp), phase.AllocsPerOp, phase.BytesPerOp)
+		}
+	})
+}
+
+func (a *App) setProfile(ctx context.Context, c *cli.Command) error {
+	name := c.Args().First()
+	if name == "" {
+		return fmt.Errorf("a profile name is required")
+	}
+	profile := settings.ModelProfile{
+		Provider:        c.String("provider"),
+		Model:           c.String("model"),
+		Tokenizer:       c.String("tokenizer"),
+		MaxContext:      int(c.Int("max-context")),
+		MaxOutputTokens: int(c.Int("max-output-tokens")),
+		InputPrice:      c.Float("input-price"),
+		OutputPrice:     c.Float("output-price"),
+	}
+	if profile.Tokenizer != "" {
+		if _, err := TokenizerNamed(profile.Tokenizer); err != nil {
+			return err
+		}
+	}
+	if profile.MaxContext > 0 && profile.MaxOutputTokens >= profile.MaxContext {
+		return fmt.Errorf("--max-output-tokens must be less than --max-context")
+	}
+
+	s := *a.settings
+	s.Profiles = make(map[string]settings.ModelProfile, len(a.settings.Profiles)+1)
+	for existing, p := range a.settings.Profiles {
+		s.Profiles[existing] = p
+	}
+	s.Profiles[name] = profile
+	if c.Bool("default") {
+		s.DefaultProfile = name
+	}
+	if err := a.saveSettings(&s); err != nil {
+		return fmt.Errorf("failed to save settings: %w", err)
+	}
+	a.settings = &s
+
+	return a.out.Report(map[string]any{"name": name, "profile": profile}, func() {
+		a.out.Successf("Saved profile %s", name)
+	})
+}
+
+func (a *App) listProfiles(ctx context.Context, c *cli.Command) error {
+	s := a.effectiveSettings()
+	names := make([]string, 0, len(s.Profiles))
+	for name := range s.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return a.out.Report(map[string]any{"default": s.DefaultProfile, "profiles": s.Profiles}, func() {
+		for _, name := range names {
+			profile := s.Profiles[name]
+			marker := " "
+			if name == s.DefaultProfile {
+				marker = "*"
+			}
+			a.out.Printf("%s %s\t%s %s\n", marker, name, profile.Provider, profile.Model)
+		}
+	})
+}
+
+// outputIndependentFlags are the flags that don't change what a prompt renders, or are recorded apart from the
+// options, see BuildRecord
+var outputIndependentFlags = map[string]bool{
+	"json": true, "quiet": true, "q": true, "verbose": true, "no-color": true, "audit-log": true, "usage-log": true,
+	"template": true, "t": true, "stack": true, "config": true, "c": true, "output": true, "o": true,
+	"clipboard": true, "label": true, "only-changed": true, "force": true,
+}
+
+// buildOptionsDigest identifies the flags and settings that shape the prompts a command writes, so outputs
+// generated with different ones aren't taken for up to date
+func (a *App) buildOptionsDigest(c *cli.Command) (string, error) {
+	h := sha256.New()
+	names := c.FlagNames()
+	sort.Strings(names)
+	for _, name := range names {
+		if !outputIndependentFlags[name] {
+			core.WriteHashPart(h, "flag", name, fmt.Sprint(c.Value(name)))
+		}
+	}
+	effective, err := json.Marshal(a.effectiveSettings())
+	if err != nil {
+		return "", fmt.Errorf("failed to encode settings: %w", err)
+	}
+	core.WriteHashPart(h, "settings", "", string(effective))
+	if path := c.String("history"); path != "" {
+		history, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read history: %w", err)
+		}
+		core.WriteHashPart(h, "history", path, string(history))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (a *App) buildAll(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	output := c.String("output")
+	if !HasOutputPlaceholder(output) {
+		return fmt.Errorf("output %q must contain a placeholder such as {{template_stem}}", output)
+	}
+	validation, err := ParseValidationMode(flagOrDefault(c, "validation", a.effectiveSettings().Defaults.Validation))
+	if err != nil {
+		return err
+	}
+	format := flagOrDefault(c, "format", a.effectiveSettings().Format)
+	formatPlugin := a.formatPlugin(format)
+	if formatPlugin == nil {
+		if err := CheckFormat(format); err != nil {
+			return err
+		}
+	}
+	var opts []BuildOption
+	if core.FormatNeedsMessages(format) || formatPlugin != nil {
+		opts = append(opts, WithMessages())
+	}
+	if core.FormatNeedsSections(format) {
+		opts = append(opts, WithSections())
+	}
+	system, err := a.newPromptSystem()
+	if err != nil {
+		return fmt.Errorf("failed to create prompt system: %w", err)
+	}
+	system.Validation = validation
+
+	// Templates without a config of their own are partials other templates include
+	exts := core.RegistryExtensions(a.registry)
+	var templatePaths []string
+	paths, err := a.registry.List()
+	if err != nil {
+		return err
+	}
+	for _, templatePath := range paths {
+		if _, err := os.Stat(a.registry.fullPath(core.TrimTemplateExtension(templatePath, exts) + ".json")); err == nil {
+			templatePaths = append(templatePaths, templatePath)
+		}
+	}
+	outputPaths, err := ExpandOutputPaths(output, templatePaths)
+	if err != nil {
+		return err
+	}
+	state, err := a.registry.LoadBuildState()
+	if err != nil {
+		return err
+	}
+	options, err := a.buildOptionsDigest(c)
+	if err != nil {
+		return err
+	}
+
+	type generated struct {
+		Template string    `json:"template"`
+		Config   string    `json:"config"`
+		Output   string    `json:"output"`
+		Warnings []Warning `json:"warnings,omitempty"`
+		Skipped  bool      `json:"skipped,omitempty"`
+	}
+	results := make([]generated, 0, len(templatePaths))
+	built := 0
+	for _, templatePath := range templatePaths {
+		configPath := core.TrimTemplateExtension(templatePath, exts) + ".json"
+		result := generated{Template: templatePath, Config: configPath, Output: outputPaths[templatePath]}
+		record, err := system.BuildRecord(ctx, templatePath, configPath, options)
+		if err != nil {
+			return fmt.Errorf("%s: %w", templatePath, err)
+		}
+		if !c.Bool("force") && state.Fresh(result.Output, record) {
+			result.Skipped = true
+			results = append(results, result)
+			continue
+		}
+		buildResult, err := system.BuildWithResult(ctx, templatePath, configPath, opts...)
+		if err != nil {
+			return fmt.Errorf("%s: %w", templatePath, err)
+		}
+		var prompt string
+		if formatPlugin != nil {
+			prompt, err = formatPlugin.Format(ctx, format, buildResult)
+		} else {
+			prompt, err = FormatResult(format, buildResult)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", templatePath, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(result.Output), 0755); err != nil {
+			return fmt.Errorf("failed to create directories: %w", err)
+		}
+		if err := os.WriteFile(result.Output, []byte(prompt), 0644); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+		state.Record(result.Output, record, []byte(prompt))
+		result.Warnings = buildResult.Warnings
+		results = append(results, result)
+		built++
+	}
+	if err := a.registry.SaveBuildState(state); err != nil {
+		return err
+	}
+
+	return a.out.Report(map[string]any{"outputs": results}, func() {
+		for _, result := range results {
+			for _, warning := range result.Warnings {
+				a.out.Warnf("%s: %s", result.Template, warning.Message)
+			}
+		}
+		a.out.Successf("Generated %d %s, %d unchanged", built, plural(built, "prompt"), len(results)-built)
+	})
+}
+
+// buildOrFillConfig builds a single template, filling in missing config fields if the first build fails
+func buildOrFillConfig(ctx context.Context, system *PromptSystem, templatePath, configPath string, opts ...BuildOption) (*BuildResult, error) {
+	// Build the prompt
+	result, err := system.BuildWithResult(ctx, templatePath, configPath, opts...)
+	if errors.Is(err, ErrDraft) {
+		// Filling the config can't help
+		return nil, err
+	}
+	if err != nil {
+		// If there's an error, try to generate/fill missing config fields
+		if err := system.GenerateOrFillConfig(ctx, templatePath, configPath); err != nil {
+			return nil, fmt.Errorf("failed to generate/fill config: %w", err)
+		}
+
+		// Retry with the updated config
+		result, err = system.BuildWithResult(ctx, templatePath, configPath, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build prompt with updated config: %w", err)
+		}
+	}
+	return result, nil
+}
+
+func (a *App) fanoutPrompts(ctx context.Context, c *cli.Command) error {
+	if a.promptRegistry() == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	templatePath := c.String("template")
+	defaults := a.effectiveSettings().Defaults
+	validation, err := ParseValidationMode(flagOrDefault(c, "validation", defaults.Validation))
+	if err != nil {
+		return err
+	}
+	system, err := a.newPromptSystem()
+	if err != nil {
+		return fmt.Errorf("failed to create prompt system: %w", err)
+	}
+	system.Validation = validation
+	var opts []BuildOption
+	if c.Bool("deterministic") {
+		opts = append(opts, WithDeterministic())
+	}
+	if c.Bool("allow-draft") {
+		opts = append(opts, WithAllowDraft())
+	}
+	if locale := c.String("locale"); locale != "" {
+		opts = append(opts, WithLocale(locale))
+	}
+	format := flagOrDefault(c, "format", a.effectiveSettings().Format)
+	formatPlugin := a.formatPlugin(format)
+	if formatPlugin == nil {
+		if err := CheckFormat(format); err != nil {
+			return err
+		}
+	}
+	if core.FormatNeedsMessages(format) || formatPlugin != nil {
+		opts = append(opts, WithMessages())
+	}
+	if core.FormatNeedsSections(format) {
+		opts = append(opts, WithSections())
+	}
+
+	results, err := system.Fanout(ctx, templatePath, c.String("config"), c.String("each"), opts...)
+	if err != nil {
+		return fmt.Errorf("failed to fan out %s: %w", templatePath, err)
+	}
+	outputPaths, err := FanoutOutputPaths(c.String("output"), templatePath, results)
+	if err != nil {
+		return err
+	}
+
+	type generated struct {
+		Index    int               `json:"index"`
+		Output   string            `json:"output"`
+		Issues   []ValidationIssue `json:"issues,omitempty"`
+		Warnings []Warning         `json:"warnings,omitempty"`
+	}
+	outputs := make([]generated, 0, len(results))
+	for i, fanned := range results {
+		var prompt string
+		if formatPlugin != nil {
+			prompt, err = formatPlugin.Format(ctx, format, fanned.Result)
+		} else {
+			prompt, err = FormatResult(format, fanned.Result)
+		}
+		if err != nil {
+			return fmt.Errorf("element %d: %w", fanned.Index, err)
+		}
+		outputPath := outputPaths[i]
+		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directories: %w", err)
+		}
+		if err := os.WriteFile(outputPath, []byte(prompt), 0644); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+		output := generated{Index: fanned.Index, Output: outputPath, Warnings: fanned.Result.Warnings}
+		if validation == ValidationWarn {
+			output.Issues = fanned.Result.Issues
+		}
+		outputs = append(outputs, output)
+	}
+
+	return a.out.Report(map[string]any{
+		"template": templatePath,
+		"config":   c.String("config"),
+		"outputs":  outputs,
+	}, func() {
+		for _, output := range outputs {
+			for _, issue := range output.Issues {
+				a.out.Warnf("element %d: %s", output.Index, issue.Message)
+			}
+			for _, warning := range output.Warnings {
+				a.out.Warnf("element %d: %s", output.Index, warning.Message)
+			}
+		}
+		a.out.Successf("Generated %d %s from %s", len(outputs), plural(len(outputs), "prompt"), templatePath)
+	})
+}
+
+func (a *App) previewPrompt(ctx context.Context, c *cli.Command) error {
+	if a.promptRegistry() == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	system, err := a.newPromptSystem()
+	if err != nil {
+		return fmt.Errorf("failed to create prompt system: %w", err)
+	}
+	preview, err := system.Preview(ctx, c.String("template"), c.String("config"))
+	if err != nil {
+		return fmt.Errorf("failed to preview prompt: %w", err)
+	}
+	return a.out.Report(preview, func() {
+		a.out.Println(preview.Highlight(a.out.Color, c.Bool("annotate")))
+	})
+}
+
+func (a *App) generateConfig(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	templatePath := c.String("template")
+	configPath := c.String("config")
+
+	system, err := a.newPromptSystem()
+	if err != nil {
+		return fmt.Errorf("failed to create prompt system: %w", err)
+	}
+
+	if err := system.GenerateOrFillConfig(ctx, templatePath, configPath); err != nil {
+		return fmt.Errorf("failed to generate/fill config: %w", err)
+	}
+
+	return a.out.Report(map[string]string{
+		"template": templatePath,
+		"config":   configPath,
+	}, func() {
+		a.out.Successf("Successfully generated/updated config at: %s", configPath)
+	})
+}
+
+func (a *App) newTemplate(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	path := c.String("path")
+	if err := core.CheckTemplateExtension(path, core.RegistryExtensions(a.registry)); err != nil {
+		return err
+	}
+
+	fullPath := filepath.Join(a.registry.Directory, path)
+
+	// Create directories if they don't exist
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directories: %w", err)
+	}
+
+	// Create an empty template file
+	if err := os.WriteFile(fullPath, []byte(""), 0644); err != nil {
+		return fmt.Errorf("failed to create template file: %w", err)
+	}
+
+	return a.out.Report(map[string]string{"path": fullPath}, func() {
+		a.out.Successf("Created new template file at: %s", fullPath)
+	})
+}
+
+func (a *App) newConfig(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	path := c.String("path")
+	fullPath := filepath.Join(a.registry.Directory, path)
+
+	// Create directories if they don't exist
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directories: %w", err)
+	}
+
+	// Create an empty config file with a basic structure
+	emptyConfig := make(map[string]interface{})
+	data, err := json.MarshalIndent(emptyConfig, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	if err := os.WriteFile(fullPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to create config file: %w", err)
 	}
 
-	fmt.Printf("Created new config file at: %s\n", fullPath)
+	return a.out.Report(map[string]string{"path": fullPath}, func() {
+		a.out.Successf("Created new config file at: %s", fullPath)
+	})
+}
+
+func (a *App) exportTemplate(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	system, err := a.newPromptSystem()
+	if err != nil {
+		return fmt.Errorf("failed to create prompt system: %w", err)
+	}
+
+	outPath := c.String("out")
+	result, err := ExportAs(ctx, system, c.String("format"), c.String("template"), c.StringSlice("config"), outPath)
+	if err != nil {
+		return fmt.Errorf("failed to export template: %w", err)
+	}
+
+	return a.out.Report(result, func() {
+		for _, unsupported := range result.Unsupported {
+			a.out.Warnf("Could not convert %s", unsupported)
+		}
+		a.out.Successf("Exported %d template(s) and %d config(s) to: %s", len(result.Templates), len(result.Configs), outPath)
+	})
+}
+
+func (a *App) importPrompts(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	src := c.Args().First()
+	if src == "" {
+		return fmt.Errorf("a file or directory to import is required")
+	}
+
+	opts := ImportOptions{
+		Syntax:          c.String("from"),
+		Dest:            c.String("dest"),
+		GenerateConfigs: c.Bool("gen-cfg"),
+		Force:           c.Bool("force"),
+	}
+	if delims := c.String("convert-delims"); delims != "" {
+		parsed, err := ParseDelims(delims)
+		if err != nil {
+			return err
+		}
+		opts.Delims = parsed
+	}
+
+	result, err := a.registry.Import(ctx, src, opts)
+	if err != nil {
+		return fmt.Errorf("failed to import prompts: %w", err)
+	}
+
+	return a.out.Report(result, func() {
+		for _, path := range result.Templates {
+			a.out.Successf("Imported template: %s", path)
+		}
+		for _, path := range result.Configs {
+			a.out.Successf("Generated config: %s", path)
+		}
+		for _, unsupported := range result.Unsupported {
+			a.out.Warnf("Could not convert %s", unsupported)
+		}
+	})
+}
+
+func (a *App) toolSchema(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	system, err := a.newPromptSystem()
+	if err != nil {
+		return err
+	}
+	tool, err := system.ToolSchema(ctx, c.String("template"))
+	if err != nil {
+		return fmt.Errorf("failed to generate tool schema: %w", err)
+	}
+	data, err := json.MarshalIndent(tool, "", "  ")
+	if err != nil {
+		return err
+	}
+	return a.out.Report(tool, func() {
+		a.out.Println(string(data))
+	})
+}
+
+func (a *App) serveHTTP(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	server, err := NewHTTPServer(a.registry)
+	if err != nil {
+		return err
+	}
+	server.system.Logger = a.logger
+	server.system.Audit = a.audit
+	server.system.Usage = a.usage
+	if c.Bool("ui") {
+		server.EnableUI()
+		a.out.Infof("Web UI at %s/ui/", uiURL(c.String("addr")))
+	}
+	a.out.Infof("Serving %s on %s", a.registry.Directory, c.String("addr"))
+	return server.ListenAndServe(ctx, c.String("addr"))
+}
+
+// uiURL is the base URL of a server listening on addr, such as http://localhost:8080 for :8080
+func uiURL(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	return "http://" + addr
+}
+
+func (a *App) serveGRPC(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	server, err := NewGRPCServer(a.registry)
+	if err != nil {
+		return err
+	}
+	server.system.Logger = a.logger
+	server.system.Audit = a.audit
+	server.system.Usage = a.usage
+	lis, err := net.Listen("tcp", c.String("addr"))
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", c.String("addr"), err)
+	}
+	a.out.Infof("Serving %s over gRPC on %s", a.registry.Directory, lis.Addr())
+	return server.Serve(ctx, lis)
+}
+
+func (a *App) serveMCP(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	server, err := NewMCPServer(a.registry)
+	if err != nil {
+		return err
+	}
+	server.system.Logger = a.logger
+	server.system.Audit = a.audit
+	server.system.Usage = a.usage
+	return server.Serve(ctx, c.Root().Reader, a.out.Out)
+}
+
+func (a *App) serveLSP(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	return NewLanguageServer(a.registry).Serve(ctx, c.Root().Reader, a.out.Out)
+}
+
+func (a *App) listTemplates(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	templates, err := a.registry.List()
+	if err != nil {
+		return err
+	}
+	if !c.Bool("owners") {
+		return a.out.Report(map[string][]string{"templates": templates}, func() {
+			for _, path := range templates {
+				a.out.Println(path)
+			}
+		})
+	}
+
+	owners, err := TemplateOwners(ctx, a.registry)
+	if err != nil {
+		return err
+	}
+	return a.out.Report(map[string]any{"templates": templates, "owners": owners}, func() {
+		for _, path := range templates {
+			a.out.Printf("%s\t%s\n", path, ownersLabel(owners[path]))
+		}
+	})
+}
+
+func (a *App) templateInfo(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	templatePath := c.Args().First()
+	if templatePath == "" {
+		return fmt.Errorf("a template path is required")
+	}
+
+	system, err := a.newPromptSystem()
+	if err != nil {
+		return fmt.Errorf("failed to create prompt system: %w", err)
+	}
+	info, err := system.Describe(ctx, templatePath)
+	if err != nil {
+		return err
+	}
+	return a.out.Report(info, func() {
+		a.out.Println(info.Path)
+		a.out.Printf("  owners:       %s\n", ownersLabel(info.Owners))
+		for _, field := range []struct {
+			label string
+			items []string
+		}{
+			{"dependencies:", info.Dependencies},
+			{"sections:", info.Sections},
+			{"variables:", info.Variables},
+		} {
+			if len(field.items) > 0 {
+				a.out.Printf("  %-13s %s\n", field.label, strings.Join(field.items, ", "))
+			}
+		}
+	})
+}
+
+func (a *App) repl(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	system, err := a.newPromptSystem()
+	if err != nil {
+		return fmt.Errorf("failed to create prompt system: %w", err)
+	}
+	session := &replSession{system: system, registry: a.registry, out: a.out, values: map[string]any{}}
+	if template := c.Args().First(); template != "" {
+		var config []string
+		if c.IsSet("config") {
+			config = []string{c.String("config")}
+		}
+		if err := session.load(ctx, template, config); err != nil {
+			return err
+		}
+	}
+
+	// Only prompt people, not notebooks and scripts piping commands in
+	reader := c.Root().Reader
+	prompt := ""
+	if f, ok := reader.(*os.File); ok && term.IsTerminal(int(f.Fd())) && !a.out.JSON {
+		prompt = "rprompt> "
+	}
+	return session.run(ctx, reader, prompt)
+}
+
+func (a *App) browse(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !isTerminal(a.out.Out) {
+		return fmt.Errorf("rprompt tui needs an interactive terminal")
+	}
+	system, err := a.newPromptSystem()
+	if err != nil {
+		return fmt.Errorf("failed to create prompt system: %w", err)
+	}
+	return browseTUI(ctx, system, a.registry, a.out)
+}
+
+func (a *App) listUnused(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	usage, ok := a.usage.(UsageSource)
+	if !ok {
+		return fmt.Errorf("no usage log to read. Pass the --usage-log given to the commands that render templates")
+	}
+	since, err := ParseSince(c.String("since"), time.Now())
+	if err != nil {
+		return err
+	}
+
+	unused, err := UnusedTemplates(ctx, a.registry, usage, since)
+	if err != nil {
+		return err
+	}
+	return a.out.Report(map[string]any{"since": since, "templates": unused}, func() {
+		for _, template := range unused {
+			lastUsed := "never rendered"
+			if template.LastUsed != nil {
+				lastUsed = "last rendered " + template.LastUsed.Local().Format(time.DateTime)
+			}
+			a.out.Printf("%s\t%s\n", template.Path, lastUsed)
+		}
+	})
+}
+
+func (a *App) listPlugins(ctx context.Context, c *cli.Command) error {
+	type listed struct {
+		Path string `json:"path"`
+		PluginManifest
+	}
+	plugins := make([]listed, 0, len(a.plugins))
+	for _, p := range a.plugins {
+		plugins = append(plugins, listed{Path: p.Path, PluginManifest: p.Manifest})
+	}
+	return a.out.Report(plugins, func() {
+		if len(plugins) == 0 {
+			a.out.Println("No plugins installed")
+			return
+		}
+		for _, p := range plugins {
+			a.out.Printf("%s\t%s\t%s\n", p.Name, p.Version, pluginAdds(&p.PluginManifest))
+		}
+	})
+}
+
+// pluginAdds describes what a plugin adds, e.g. "functions shout, whisper; formats upper"
+func pluginAdds(m *PluginManifest) string {
+	var adds []string
+	if len(m.Functions) > 0 {
+		adds = append(adds, "functions "+strings.Join(m.Functions, ", "))
+	}
+	if len(m.Formats) > 0 {
+		adds = append(adds, "formats "+strings.Join(m.Formats, ", "))
+	}
+	if m.Registry {
+		adds = append(adds, "registry")
+	}
+	return strings.Join(adds, "; ")
+}
+
+func (a *App) installPlugin(ctx context.Context, c *cli.Command) error {
+	path := c.Args().First()
+	if path == "" {
+		return fmt.Errorf("a plugin executable is required")
+	}
+	dir, err := a.settings.Plugins()
+	if err != nil {
+		return err
+	}
+	manifest, err := InstallPlugin(ctx, path, dir)
+	if err != nil {
+		return err
+	}
+	return a.out.Report(manifest, func() {
+		a.out.Successf("Installed plugin %s %s into %s", manifest.Name, manifest.Version, dir)
+	})
+}
+
+func dryRunFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "List the files that would change without changing them",
+	}
+}
+
+func (a *App) migrateDelims(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	delims, err := ParseDelims(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	result, err := a.registry.MigrateDelims(ctx, delims[0], delims[1], c.Bool("dry-run"))
+	if err != nil {
+		return fmt.Errorf("failed to migrate delimiters: %w", err)
+	}
+	// The registry's templates only parse with the new delimiters from now on
+	key := "delims"
+	if name := c.Root().String("registry"); name != "" {
+		key = "registries." + name + ".delims"
+	} else if name := a.effectiveSettings().CurrentRegistry; name != "" {
+		key = "registries." + name + ".delims"
+	}
+	if !result.DryRun {
+		if err := a.applySetting(key, func(s *settings.Settings) error { return s.Set(key, c.Args().First()) }); err != nil {
+			return err
+		}
+	}
+	return a.reportMigration(result)
+}
+
+func (a *App) renameVariable(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	if c.Args().Len() != 2 {
+		return fmt.Errorf("the variable to rename and its new name are required")
+	}
+
+	result, err := a.registry.RenameVariable(ctx, c.Args().Get(0), c.Args().Get(1), c.Bool("dry-run"))
+	if err != nil {
+		return fmt.Errorf("failed to rename variable: %w", err)
+	}
+	return a.reportMigration(result)
+}
+
+func (a *App) moveTemplate(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	if c.Args().Len() != 2 {
+		return fmt.Errorf("the template to move and its new path are required")
+	}
+
+	result, err := a.registry.MoveTemplate(ctx, c.Args().Get(0), c.Args().Get(1), c.Bool("dry-run"))
+	if err != nil {
+		return fmt.Errorf("failed to move template: %w", err)
+	}
+	return a.reportMigration(result)
+}
+
+// reportMigration lists the files a migration changed, or would have on a dry run
+func (a *App) reportMigration(result *MigrationResult) error {
+	return a.out.Report(result, func() {
+		say := func(done, planned string, args ...any) {
+			if result.DryRun {
+				a.out.Printf(planned+"\n", args...)
+				return
+			}
+			a.out.Successf(done, args...)
+		}
+		for _, src := range sortedKeys(result.Moved) {
+			say("Moved %s to %s", "Would move %s to %s", src, result.Moved[src])
+		}
+		for _, path := range append(append([]string{}, result.Templates...), result.Configs...) {
+			say("Rewrote %s", "Would rewrite %s", path)
+		}
+		if len(result.Moved) == 0 && len(result.Templates) == 0 && len(result.Configs) == 0 {
+			a.out.Println("Nothing to change")
+		}
+	})
+}
+
+// ownersLabel lists owners for human output
+func ownersLabel(owners []string) string {
+	if len(owners) == 0 {
+		return "(no owner)"
+	}
+	return strings.Join(owners, ", ")
+}
+
+func (a *App) showHistory(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	path := c.Args().First()
+	if path == "" {
+		return fmt.Errorf("a template or config path is required")
+	}
+
+	versions, err := a.registry.History(path)
+	if err != nil {
+		return err
+	}
+	return a.out.Report(map[string]any{"path": path, "versions": versions}, func() {
+		if len(versions) == 0 {
+			a.out.Printf("%s has no saved versions\n", path)
+			return
+		}
+		for i := len(versions) - 1; i >= 0; i-- {
+			v := versions[i]
+			a.out.Printf("v%d\t%s\t%d bytes\t%s\n", v.Version, v.Time.Format(time.DateTime), v.Size, strings.Join(v.Tags, " "))
+		}
+	})
+}
+
+func (a *App) showVersion(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	path, version, content, err := a.readRef(c.Args().First())
+	if err != nil {
+		return err
+	}
+	return a.out.Report(map[string]any{"path": path, "version": version, "content": string(content)}, func() {
+		a.out.Printf("%s", content)
+	})
+}
+
+// readRef reads the template or config ref names, a path with an optional version such as
+// agents/billing.tmpl@v3. Without a version it reads the current file.
+func (a *App) readRef(ref string) (string, int, []byte, error) {
+	path, version, err := a.registry.ResolveRef(ref)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	if path == "" {
+		return "", 0, nil, fmt.Errorf("a template or config path is required")
+	}
+
+	if version > 0 {
+		content, err := a.registry.ReadVersion(path, version)
+		return path, version, content, err
+	}
+	fullPath, err := a.registry.ResolvePath(path)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return path, 0, content, nil
+}
+
+func (a *App) diffVersions(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	if c.Args().Len() == 0 || c.Args().Len() > 2 {
+		return fmt.Errorf("one or two versions to compare are required")
+	}
+	path, version, old, err := a.readRef(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	// The second version defaults to the current file
+	toRef := c.Args().Get(1)
+	if toRef == "" {
+		toRef = path
+	}
+	toPath, toVersion, updated, err := a.readRef(toRef)
+	if err != nil {
+		return err
+	}
+
+	diff := NewDiff(versionLabel(path, version), versionLabel(toPath, toVersion), string(old), string(updated))
+	var buf bytes.Buffer
+	if err := WriteDiff(&buf, diff); err != nil {
+		return err
+	}
+	return a.out.Report(diff, func() {
+		a.out.Printf("%s", buf.String())
+	})
+}
+
+// versionLabel names a version of the file at path as a ref, or just the path for the current file
+func versionLabel(path string, version int) string {
+	if version == 0 {
+		return path
+	}
+	return fmt.Sprintf("%s@v%d", path, version)
+}
+
+func (a *App) rollback(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	path, version, err := a.registry.ResolveRef(c.Args().First())
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return fmt.Errorf("a template or config path is required")
+	}
+
+	saved, err := a.registry.Rollback(path, version)
+	if err != nil {
+		return err
+	}
+	return a.out.Report(saved, func() {
+		a.out.Successf("Rolled back %s, saved as v%d", path, saved.Version)
+	})
+}
+
+func (a *App) publishTemplate(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	path := c.Args().First()
+	if path == "" {
+		return fmt.Errorf("a template path is required")
+	}
+
+	if err := a.registry.Publish(ctx, path); err != nil {
+		return err
+	}
+	return a.out.Report(map[string]string{"path": path}, func() {
+		a.out.Successf("Published %s", path)
+	})
+}
+
+func (a *App) changelog(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	changelog, err := a.registry.Changelog(ctx, c.String("from"), c.String("to"))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := WriteChangelog(&buf, changelog); err != nil {
+		return err
+	}
+	return a.out.Report(changelog, func() {
+		a.out.Printf("%s", buf.String())
+	})
+}
+
+func (a *App) tagVersion(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	if c.Args().Len() != 2 {
+		return fmt.Errorf("a template or config path and a tag are required")
+	}
+	path, version, err := a.registry.ResolveRef(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	tag := c.Args().Get(1)
+
+	tagged, err := a.registry.Tag(path, version, tag)
+	if err != nil {
+		return err
+	}
+	return a.out.Report(tagged, func() {
+		a.out.Successf("Tagged %s as %s@%s", tagged, path, tag)
+	})
+}
+
+func (a *App) openTemplate(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	templatePath := c.Args().First()
+	if templatePath == "" {
+		return fmt.Errorf("a template to open is required")
+	}
+	exts := core.RegistryExtensions(a.registry)
+	if err := core.CheckTemplateExtension(templatePath, exts); err != nil {
+		return err
+	}
+
+	paths := []string{templatePath}
+	if configPath := c.String("config"); configPath != "" {
+		paths = append(paths, configPath)
+	} else if c.Bool("with-config") {
+		paths = append(paths, core.TrimTemplateExtension(templatePath, exts)+".json")
+	}
+
+	absPaths := make([]string, 0, len(paths))
+	for _, path := range paths {
+		absPath, err := a.registry.ResolvePath(path)
+		if err != nil {
+			return err
+		}
+		absPaths = append(absPaths, absPath)
+	}
+
+	if c.Bool("print") {
+		return a.out.Report(map[string][]string{"paths": absPaths}, func() {
+			for _, path := range absPaths {
+				a.out.Println(path)
+			}
+		})
+	}
+	return openInEditor(ctx, absPaths)
+}
+
+// openInEditor opens the given files in $VISUAL or $EDITOR, falling back to vi. Multiple files are opened
+// side-by-side when the editor is a vi variant.
+func openInEditor(ctx context.Context, paths []string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	args := strings.Fields(editor)
+	switch filepath.Base(args[0]) {
+	case "vi", "vim", "nvim":
+		if len(paths) > 1 {
+			args = append(args, "-O")
+		}
+	}
+	args = append(args, paths...)
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run editor %s: %w", args[0], err)
+	}
+	return nil
+}
+
+func (a *App) registryStats(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	stats, err := ComputeStats(ctx, a.registry)
+	if err != nil {
+		return fmt.Errorf("failed to compute stats: %w", err)
+	}
+
+	return a.out.Report(stats, func() {
+		a.out.Printf("Templates: %d\n", stats.TemplateCount)
+		a.out.Printf("Tokens (estimated): avg %.0f, max %d", stats.AvgTokens, stats.MaxTokens)
+		if stats.MaxTokensTemplate != "" {
+			a.out.Printf(" (%s)", stats.MaxTokensTemplate)
+		}
+		a.out.Println()
+
+		a.out.Println("\nMost included partials:")
+		for _, partial := range stats.Partials {
+			a.out.Printf("  %4d  %s\n", partial.Count, partial.Name)
+		}
+		a.out.Println("\nVariables:")
+		for _, variable := range stats.Variables {
+			a.out.Printf("  %4d  %s\n", variable.Count, variable.Name)
+		}
+		a.out.Println("\nConfigs missing values:")
+		for _, gaps := range stats.IncompleteConfigs {
+			if gaps.Error != "" {
+				a.out.Printf("  %s: %s\n", gaps.Path, gaps.Error)
+				continue
+			}
+			a.out.Printf("  %s: %s\n", gaps.Path, strings.Join(gaps.MissingValues, ", "))
+		}
+		for path, parseErr := range stats.ParseErrors {
+			a.out.Warnf("failed to parse %s: %s", path, parseErr)
+		}
+	})
+}
+
+func (a *App) indexRegistry(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	index, err := a.registry.WriteIndex(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to index registry: %w", err)
+	}
+
+	return a.out.Report(index, func() {
+		for _, entry := range index.Templates {
+			if entry.Error != "" {
+				a.out.Warnf("failed to parse %s: %s", entry.Path, entry.Error)
+			}
+		}
+		a.out.Successf("Indexed %d templates in %s", len(index.Templates), IndexFile)
+	})
+}
+
+func (a *App) compileRegistry(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	bundle, err := CompileRegistry(ctx, a.registry)
+	if err != nil {
+		return fmt.Errorf("failed to compile registry: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, bundle); err != nil {
+		return err
+	}
+	output := c.String("output")
+	if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+
+	result := map[string]any{"path": output, "templates": len(bundle.Templates), "configs": len(bundle.Configs), "size": buf.Len()}
+	return a.out.Report(result, func() {
+		a.out.Successf("Compiled %d templates and %d configs into %s (%d bytes)", len(bundle.Templates), len(bundle.Configs), output, buf.Len())
+	})
+}
+
+func (a *App) runPromptTests(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	var opts []BuildOption
+	coverage := NewCoverage()
+	if c.Bool("coverage") {
+		opts = append(opts, WithCoverage(coverage))
+	}
+	report, err := RunPromptTests(ctx, a.registry, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to run prompt tests: %w", err)
+	}
+	if c.Bool("coverage") {
+		report.Coverage = coverage.Report()
+	}
+	if path := c.String("junit"); path != "" {
+		var buf bytes.Buffer
+		if err := WriteJUnit(&buf, report); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write JUnit report: %w", err)
+		}
+	}
+
+	if err := a.out.Report(report, func() {
+		for _, result := range report.Results {
+			if result.Passed {
+				a.out.Printf("ok    %s: %s\n", result.File, result.Name)
+				continue
+			}
+			a.out.Printf("FAIL  %s: %s\n", result.File, result.Name)
+			for _, failure := range result.Failures {
+				a.out.Printf("        %s\n", failure)
+			}
+		}
+		if report.Failed == 0 {
+			a.out.Successf("%d tests passed", report.Tests)
+		}
+		if report.Coverage != nil {
+			a.out.Printf("Branch coverage: %d of %d (%.1f%%)\n", report.Coverage.Covered, report.Coverage.Total, report.Coverage.Percent())
+			for _, branch := range report.Coverage.Uncovered() {
+				a.out.Printf("  not rendered: %s\n", branch)
+			}
+		}
+	}); err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d tests failed", report.Failed, report.Tests)
+	}
+	return nil
+}
+
+func (a *App) mutationCheck(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	system, err := a.newPromptSystem()
+	if err != nil {
+		return fmt.Errorf("failed to create prompt system: %w", err)
+	}
+	// Mutated builds aren't real renders
+	system.Usage = nil
+	report, err := system.MutationCheck(ctx, c.String("template"), c.String("config"))
+	if err != nil {
+		return err
+	}
+
+	return a.out.Report(report, func() {
+		for _, result := range report.Results {
+			if result.Outcome == MutationBroken {
+				a.out.Warnf("%s", result)
+				continue
+			}
+			a.out.Printf("%s\n", result)
+		}
+		if fragile := report.Fragile(); len(fragile) > 0 {
+			a.out.Printf("Consider requiring: %s\n", strings.Join(fragile, ", "))
+		} else {
+			a.out.Successf("No omission silently broke %s", report.Template)
+		}
+	})
+}
+
+func (a *App) fuzzCheck(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	report, err := FuzzCheck(ctx, a.registry)
+	if err != nil {
+		return fmt.Errorf("failed to fuzz registry: %w", err)
+	}
+
+	if err := a.out.Report(report, func() {
+		for _, crash := range report.Crashes {
+			a.out.Warnf("%s panicked during %s", crash.Template, crash.Step)
+			if crash.Input != "" {
+				a.out.Printf("  input: %s\n", crash.Input)
+			}
+			a.out.Printf("  panic: %s\n", crash.Panic)
+		}
+		if len(report.Crashes) == 0 {
+			a.out.Successf("No crashes in %d runs over %d templates", report.Runs, report.Templates)
+		}
+	}); err != nil {
+		return err
+	}
+	if len(report.Crashes) > 0 {
+		return fmt.Errorf("found %d crashes in %d runs over %d templates", len(report.Crashes), report.Runs, report.Templates)
+	}
+	return nil
+}
+
+func (a *App) auditRegistry(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+
+	paths, err := a.registry.List()
+	if err != nil {
+		return fmt.Errorf("failed to audit registry: %w", err)
+	}
+	report, err := ScanInjectionRisks(ctx, a.registry, paths)
+	if err != nil {
+		return fmt.Errorf("failed to audit registry: %w", err)
+	}
+	err = a.out.Report(report, func() {
+		if len(report.Findings) == 0 {
+			a.out.Successf("No prompt injection risks found")
+		}
+		for _, finding := range report.Findings {
+			a.out.Println(finding.String())
+		}
+		for path, auditErr := range report.Errors {
+			a.out.Warnf("failed to read %s: %s", path, auditErr)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if c.Bool("fail") && len(report.Findings) > 0 {
+		return fmt.Errorf("%d prompt injection %s found", len(report.Findings), plural(len(report.Findings), "risk"))
+	}
+	return nil
+}
+
+func (a *App) lintRegistry(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	format, err := ParseDiagnosticsFormat(c.String("format"))
+	if err != nil {
+		return err
+	}
+
+	report, err := LintRegistry(ctx, a.registry)
+	if err != nil {
+		return fmt.Errorf("failed to lint registry: %w", err)
+	}
+	if format != DiagnosticsText {
+		err = WriteDiagnostics(a.out.Out, format, a.displayDiagnostics(report.Diagnostics))
+	} else {
+		err = a.out.Report(report, func() {
+			if len(report.Diagnostics) == 0 {
+				a.out.Successf("No problems found")
+			}
+			for _, diagnostic := range report.Diagnostics {
+				a.out.Println(diagnostic.String())
+				if diagnostic.Snippet != "" {
+					a.out.Println(diagnostic.Snippet)
+				}
+			}
+		})
+	}
+	if err != nil {
+		return err
+	}
+	for path, lintErr := range report.Errors {
+		a.out.Warnf("failed to read %s: %s", path, lintErr)
+	}
+	if len(report.Diagnostics) > 0 {
+		return fmt.Errorf("%d %s found", len(report.Diagnostics), plural(len(report.Diagnostics), "problem"))
+	}
+	return nil
+}
+
+// displayDiagnostics returns diagnostics with their registry paths rewritten relative to the working directory,
+// or absolute when the registry is outside it, so tools run from the repository root find the files
+func (a *App) displayDiagnostics(diagnostics []Diagnostic) []Diagnostic {
+	dir, err := filepath.Abs(a.registry.Directory)
+	if err != nil {
+		return diagnostics
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			dir = rel
+		}
+	}
+	displayed := make([]Diagnostic, len(diagnostics))
+	for i, diagnostic := range diagnostics {
+		diagnostic.Path = filepath.Join(dir, filepath.FromSlash(diagnostic.Path))
+		displayed[i] = diagnostic
+	}
+	return displayed
+}
+
+func (a *App) checkRegistry(ctx context.Context, c *cli.Command) error {
+	if a.registry == nil {
+		return fmt.Errorf("registry directory not set. Use 'rprompt set --directory=<path>' first")
+	}
+	format, err := ParseDiagnosticsFormat(c.String("format"))
+	if err != nil {
+		return err
+	}
+
+	deprecations, err := FindDeprecations(ctx, a.registry)
+	if err != nil {
+		return fmt.Errorf("failed to check registry: %w", err)
+	}
+	report := struct {
+		*DeprecationReport
+		Unowned []string      `json:"unowned,omitempty"`
+		Locales *LocaleReport `json:"locales,omitempty"`
+	}{DeprecationReport: deprecations}
+	if c.Bool("require-owner") {
+		owners, err := TemplateOwners(ctx, a.registry)
+		if err != nil {
+			return fmt.Errorf("failed to check registry: %w", err)
+		}
+		report.Unowned = Unowned(owners)
+	}
+	if c.Bool("locales") {
+		if report.Locales, err = FindMissingTranslations(ctx, a.registry, c.String("default-locale")); err != nil {
+			return fmt.Errorf("failed to check registry: %w", err)
+		}
+	}
+
+	if format != DiagnosticsText {
+		diagnostics := deprecations.Diagnostics(ctx, a.registry)
+		for _, path := range report.Unowned {
+			diagnostics = append(diagnostics, Diagnostic{Path: path, Rule: RuleMissingOwner, Severity: DiagnosticError, Message: fmt.Sprintf("%s has no owner, add owners to its front matter or to %s", path, OwnersFile)})
+		}
+		if report.Locales != nil {
+			for _, missing := range report.Locales.Missing {
+				diagnostics = append(diagnostics, Diagnostic{Path: missing.Template, Rule: RuleMissingTranslation, Severity: DiagnosticError, Message: fmt.Sprintf("%s is missing translations for %s", missing.Template, strings.Join(missing.Locales, ", "))})
+			}
+		}
+		err = WriteDiagnostics(a.out.Out, format, a.displayDiagnostics(diagnostics))
+		for path, checkErr := range report.Errors {
+			a.out.Warnf("failed to read %s: %s", path, checkErr)
+		}
+	} else {
+		err = a.out.Report(report, func() {
+			a.out.Println("Deprecated templates:")
+			for _, usage := range report.Templates {
+				deprecation := TemplateDeprecation{Replacement: usage.Replacement, Note: usage.Note}
+				a.out.Printf("  %s\n", deprecation.Message(usage.Path))
+				for _, includer := range usage.IncludedBy {
+					a.out.Printf("    included by %s\n", includer)
+				}
+			}
+			a.out.Println("\nDeprecated variables:")
+			for _, usage := range report.Variables {
+				a.out.Printf("  %s in %s", usage.Path, usage.Template)
+				if usage.Note != "" {
+					a.out.Printf(": %s", usage.Note)
+				}
+				a.out.Println()
+				for _, config := range usage.SetBy {
+					a.out.Printf("    set by %s\n", config)
+				}
+			}
+			if len(report.Unowned) > 0 {
+				a.out.Println("\nTemplates without an owner:")
+				for _, path := range report.Unowned {
+					a.out.Printf("  %s\n", path)
+				}
+			}
+			if report.Locales != nil && len(report.Locales.Missing) > 0 {
+				a.out.Println("\nMissing translations:")
+				for _, missing := range report.Locales.Missing {
+					a.out.Printf("  %s: %s\n", missing.Template, strings.Join(missing.Locales, ", "))
+				}
+			}
+			for path, checkErr := range report.Errors {
+				a.out.Warnf("failed to read %s: %s", path, checkErr)
+			}
+		})
+	}
+	if err != nil {
+		return err
+	}
+	if len(report.Unowned) > 0 {
+		return fmt.Errorf("%d %s without an owner, add owners to their front matter or to %s", len(report.Unowned), plural(len(report.Unowned), "template"), OwnersFile)
+	}
+	if report.Locales != nil && len(report.Locales.Missing) > 0 {
+		return fmt.Errorf("%d %s missing translations", len(report.Locales.Missing), plural(len(report.Locales.Missing), "template"))
+	}
 	return nil
 }
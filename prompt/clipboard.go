@@ -0,0 +1,55 @@
+package prompt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// lookPath is swapped out in tests
+var lookPath = exec.LookPath
+
+// clipboardCommand returns the command used to copy stdin to the system clipboard on this platform
+func clipboardCommand() ([]string, error) {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	case "windows":
+		candidates = [][]string{{"clip"}}
+	default:
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			candidates = append(candidates, []string{"wl-copy"})
+		}
+		candidates = append(candidates,
+			[]string{"xclip", "-selection", "clipboard"},
+			[]string{"xsel", "--clipboard", "--input"},
+		)
+	}
+
+	names := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		if _, err := lookPath(candidate[0]); err == nil {
+			return candidate, nil
+		}
+		names = append(names, candidate[0])
+	}
+	return nil, fmt.Errorf("no clipboard utility found, install one of: %s", strings.Join(names, ", "))
+}
+
+// CopyToClipboard copies text to the system clipboard
+func CopyToClipboard(ctx context.Context, text string) error {
+	args, err := clipboardCommand()
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to copy to clipboard with %s: %w: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package prompt
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stubLookPath(t *testing.T, available ...string) {
+	original := lookPath
+	t.Cleanup(func() { lookPath = original })
+	lookPath = func(file string) (string, error) {
+		for _, name := range available {
+			if name == file {
+				return "/usr/bin/" + file, nil
+			}
+		}
+		return "", errors.New("not found")
+	}
+}
+
+func TestClipboardCommand(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("clipboard fallbacks are only exercised on linux")
+	}
+	t.Setenv("WAYLAND_DISPLAY", "")
+
+	stubLookPath(t, "xsel")
+	args, err := clipboardCommand()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"xsel", "--clipboard", "--input"}, args)
+
+	stubLookPath(t, "xclip", "xsel")
+	args, err = clipboardCommand()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"xclip", "-selection", "clipboard"}, args)
+
+	t.Setenv("WAYLAND_DISPLAY", "wayland-0")
+	stubLookPath(t, "wl-copy", "xclip")
+	args, err = clipboardCommand()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"wl-copy"}, args)
+
+	stubLookPath(t)
+	_, err = clipboardCommand()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no clipboard utility found")
+}
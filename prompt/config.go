@@ -1,54 +1,35 @@
 package prompt
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
-)
 
-// type Config map[string]any
-type Config struct {
-	Config map[string]any
-	Path   string
-}
+	"github.com/notzree/rprompt/v2/prompt/core"
+)
 
-func NewConfig(data map[string]any, path string) *Config {
-	return &Config{
-		Config: data,
-		Path:   path,
-	}
-}
-func (c *Config) Save() error {
-	dir := filepath.Dir(c.Path)
+// SaveConfigFile writes cfg to the file at its path, creating the directories on the way
+func SaveConfigFile(cfg *Config) error {
+	dir := filepath.Dir(cfg.Path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory %s: %w", dir, err)
 	}
-	// Marshal config to JSON with indentation
-	bytes, err := json.MarshalIndent(c.Config, "", "  ")
+	// Keep the key order and indentation of the file being replaced
+	previous, _ := os.ReadFile(cfg.Path)
+	data, err := core.MarshalConfig(cfg.Config, previous)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config to JSON: %w", err)
 	}
 
 	// Write to file
-	if err := os.WriteFile(c.Path, bytes, 0644); err != nil {
-		return fmt.Errorf("failed to write config file %s: %w", c.Path, err)
+	if err := os.WriteFile(cfg.Path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", cfg.Path, err)
 	}
 
 	return nil
 
 }
 
-func CfgFromJSONString(jsonString string, path string) (*Config, error) {
-	var data map[string]any
-	err := json.Unmarshal([]byte(jsonString), &data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse JSON into Config: %w", err)
-	}
-
-	return NewConfig(data, path), nil
-}
-
 func CfgFromFile(path string) (*Config, error) {
 	bytes, err := os.ReadFile(path)
 	if err != nil {
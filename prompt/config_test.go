@@ -1,12 +1,14 @@
 package prompt
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewConfig(t *testing.T) {
@@ -35,7 +37,7 @@ func TestConfig_Save(t *testing.T) {
 
 	cfg := NewConfig(data, configPath)
 
-	err := cfg.Save()
+	err := SaveConfigFile(cfg)
 	assert.NoError(t, err)
 
 	// Verify file exists
@@ -52,6 +54,16 @@ func TestConfig_Save(t *testing.T) {
 	assert.Equal(t, data, savedData)
 }
 
+func TestApp_GenerateConfigKeepsKeyOrder(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[.subject]] [[.body]] [[.author.name]] [[.author.role]]`)
+	createTestFile(t, tempDir, "main.json", "{\n\t\"subject\": \"Hi\",\n\t\"author\": {\n\t\t\"name\": \"Ada\"\n\t}\n}\n")
+	app, _, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "gen-cfg", "-t", "main.tmpl", "-c", "main.json"}))
+	assert.Equal(t, "{\n\t\"subject\": \"Hi\",\n\t\"author\": {\n\t\t\"name\": \"Ada\",\n\t\t\"role\": \"\"\n\t},\n\t\"body\": \"\"\n}\n", readTestFile(t, tempDir, "main.json"))
+}
+
 func TestCfgFromJSONString(t *testing.T) {
 	tests := []struct {
 		name       string
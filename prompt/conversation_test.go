@@ -0,0 +1,77 @@
+package prompt
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testHistory = History{
+	{Role: RoleUser, Content: "Hi, my invoice is wrong."},
+	{Role: RoleAssistant, Content: "Sorry to hear that! Which invoice?"},
+	{Role: RoleUser, Content: "March."},
+	{Role: RoleAssistant, Content: "Let me check March."},
+}
+
+func TestReadHistory(t *testing.T) {
+	history, err := ReadHistory(strings.NewReader(`[{"role": "user", "content": "hi"}]`))
+	require.NoError(t, err)
+	assert.Equal(t, History{{Role: RoleUser, Content: "hi"}}, history)
+
+	history, err = ReadHistory(strings.NewReader(`{"model": "x", "messages": [{"role": "assistant", "content": "hello"}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, History{{Role: RoleAssistant, Content: "hello"}}, history)
+
+	_, err = ReadHistory(strings.NewReader(`[{"role": "robot", "content": "beep"}]`))
+	assert.ErrorContains(t, err, `turn 1: unknown role "robot"`)
+	_, err = ReadHistory(strings.NewReader(`"hi"`))
+	assert.ErrorContains(t, err, "history must be a JSON array")
+}
+
+func TestWithHistory(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "transcript.tmpl", "Conversation so far:\n[[history \"last=2\"]]")
+	createTestFile(t, tempDir, "chat.tmpl", `[[role "system"]]You are a billing agent.
+[[range history "tokens=20"]][[role .Role]][[.Content]]
+[[end]]`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	output, err := system.Build(ctx, "transcript.tmpl", "")
+	require.NoError(t, err)
+	assert.Equal(t, "Conversation so far:\n", output, "the first turn has no history")
+
+	output, err = system.Build(ctx, "transcript.tmpl", "", WithHistory(testHistory))
+	require.NoError(t, err)
+	assert.Equal(t, "Conversation so far:\nUser: March.\nAssistant: Let me check March.", output)
+
+	messages, err := system.BuildMessages(ctx, "chat.tmpl", "", WithHistory(testHistory))
+	require.NoError(t, err)
+	assert.Equal(t, []Message{
+		{Role: RoleSystem, Content: "You are a billing agent."},
+		{Role: RoleAssistant, Content: "Sorry to hear that! Which invoice?"},
+		{Role: RoleUser, Content: "March."},
+		{Role: RoleAssistant, Content: "Let me check March."},
+	}, messages, "the oldest turn is dropped to fit 20 tokens")
+}
+
+func TestApp_GenerateHistory(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[history]]`)
+	createTestFile(t, tempDir, "main.json", `{}`)
+	createTestFile(t, tempDir, "history.json", `[{"role": "user", "content": "hi"}, {"role": "assistant", "content": "hello"}]`)
+	app, _, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	outDir := t.TempDir()
+	run := func(history string) error {
+		return app.Command().Run(context.Background(), []string{"rprompt", "generate", "-t", "main.tmpl", "-c", "main.json", "--history", history, "-o", filepath.Join(outDir, "out.txt")})
+	}
+
+	require.NoError(t, run(filepath.Join(tempDir, "history.json")))
+	assert.Equal(t, "User: hi\nAssistant: hello", readTestFile(t, outDir, "out.txt"))
+	assert.ErrorContains(t, run(filepath.Join(tempDir, "main.tmpl")), "failed to read history")
+}
@@ -0,0 +1,79 @@
+package prompt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func convertTemplate(t *testing.T, files map[string]string, path string) *JinjaPrompt {
+	tempDir := setupTempDir(t)
+	for name, content := range files {
+		createTestFile(t, tempDir, name, content)
+	}
+	system, _ := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	template, err := system.Find(context.Background(), path)
+	require.NoError(t, err)
+	prompt, err := template.ConvertToJinja(context.Background())
+	require.NoError(t, err)
+	return prompt
+}
+
+func TestConvertToJinja(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"field", `Hello [[.user.name]]!`, `Hello {{ user.name }}!`},
+		{"if else", `[[if .admin]]admin[[else]]user[[end]]`, `{% if admin %}admin{% else %}user{% endif %}`},
+		{"comparison", `[[if eq .role "admin"]]yes[[end]]`, `{% if (role == "admin") %}yes{% endif %}`},
+		{"with", `[[with .user]][[.name]][[end]]`, `{% if user %}{{ user.name }}{% endif %}`},
+		{"range", `[[range .items]]- [[.]][[end]]`, `{% for item in items %}- {{ item }}{% endfor %}`},
+		{"range variables", `[[range $i, $x := .items]][[$i]]=[[$x.id]][[end]]`, `{% for x in items %}{{ loop.index0 }}={{ x.id }}{% endfor %}`},
+		{"len pipeline", `[[.items | len]]`, `{{ (items|length) }}`},
+		{"raw text", `Use {{ braces }} [[.x]]`, `{% raw %}Use {{ braces }} {% endraw %}{{ x }}`},
+		{"trimmable kept", `[[trimmable 1]]extra[[endtrimmable]]`, `extra`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt := convertTemplate(t, map[string]string{"main.tmpl": tt.template}, "main.tmpl")
+			assert.Equal(t, tt.want, prompt.Template)
+			assert.Empty(t, prompt.Unsupported)
+		})
+	}
+}
+
+func TestConvertToJinja_InlinesIncludes(t *testing.T) {
+	prompt := convertTemplate(t, map[string]string{
+		"main.tmpl":   `[[template "header.tmpl" .user]] [[.topic]]`,
+		"header.tmpl": `Hi [[.name]]`,
+	}, "main.tmpl")
+
+	assert.Equal(t, `Hi {{ user.name }} {{ topic }}`, prompt.Template)
+	assert.Equal(t, []string{"topic", "user"}, prompt.Variables)
+}
+
+func TestConvertToJinja_Roles(t *testing.T) {
+	prompt := convertTemplate(t, map[string]string{
+		"main.tmpl": "[[role \"system\"]]Be brief.\n[[role \"user\"]]Tell me about [[.topic]]",
+	}, "main.tmpl")
+
+	assert.Equal(t, []Message{
+		{Role: RoleSystem, Content: "Be brief."},
+		{Role: RoleUser, Content: "Tell me about {{ topic }}"},
+	}, prompt.Messages)
+	assert.Equal(t, "{# role: system #}Be brief.\n{# role: user #}Tell me about {{ topic }}", prompt.Template)
+}
+
+func TestConvertToJinja_Unsupported(t *testing.T) {
+	prompt := convertTemplate(t, map[string]string{
+		"main.tmpl": `[[if .x]][[role "user"]][[end]][[call .fn]]`,
+	}, "main.tmpl")
+
+	assert.Len(t, prompt.Unsupported, 2)
+	assert.Contains(t, prompt.Template, "{# unsupported: ")
+	assert.Empty(t, prompt.Messages)
+}
@@ -0,0 +1,568 @@
+package prompt
+
+import (
+	"context"
+	"io"
+	"text/template/parse"
+	"time"
+
+	"github.com/notzree/rprompt/v2/prompt/core"
+)
+
+// The engine that parses, checks and builds templates is the core package, which doesn't touch the file system,
+// processes or the network so it builds for WebAssembly too. Its API is re-exported here, so the CLI, the servers
+// and callers of this package use the same types.
+
+// Constants of the core package
+const (
+	CodeBudgetExceeded        = core.CodeBudgetExceeded
+	CodeCanceled              = core.CodeCanceled
+	CodeConfigInvalid         = core.CodeConfigInvalid
+	CodeCycle                 = core.CodeCycle
+	CodeDraft                 = core.CodeDraft
+	CodeLimit                 = core.CodeLimit
+	CodeMissingFields         = core.CodeMissingFields
+	CodeOutsideRegistry       = core.CodeOutsideRegistry
+	CodePanic                 = core.CodePanic
+	CodeTemplate              = core.CodeTemplate
+	CodeTemplateNotFound      = core.CodeTemplateNotFound
+	CodeUnknown               = core.CodeUnknown
+	DefaultParseCacheSize     = core.DefaultParseCacheSize
+	DefaultTemplateExtension  = core.DefaultTemplateExtension
+	DeterministicSeed         = core.DeterministicSeed
+	ErrorTypeBudget           = core.ErrorTypeBudget
+	ErrorTypeCanceled         = core.ErrorTypeCanceled
+	ErrorTypeCycle            = core.ErrorTypeCycle
+	ErrorTypeLimit            = core.ErrorTypeLimit
+	ErrorTypeMissing          = core.ErrorTypeMissing
+	ErrorTypeNotFound         = core.ErrorTypeNotFound
+	ErrorTypeOther            = core.ErrorTypeOther
+	ErrorTypePanic            = core.ErrorTypePanic
+	ErrorTypeTemplate         = core.ErrorTypeTemplate
+	ErrorTypeValidation       = core.ErrorTypeValidation
+	ExportJinja               = core.ExportJinja
+	ExportLangChain           = core.ExportLangChain
+	ExportNative              = core.ExportNative
+	ExportPromptfoo           = core.ExportPromptfoo
+	FormatAnthropic           = core.FormatAnthropic
+	FormatJSON                = core.FormatJSON
+	FormatMarkdown            = core.FormatMarkdown
+	FormatMessagesJSON        = core.FormatMessagesJSON
+	FormatText                = core.FormatText
+	FormatYAML                = core.FormatYAML
+	HashFooterFormat          = core.HashFooterFormat
+	IDPrefix                  = core.IDPrefix
+	IssueMissing              = core.IssueMissing
+	IssueTypeMismatch         = core.IssueTypeMismatch
+	IssueUnused               = core.IssueUnused
+	LeftDelim                 = core.LeftDelim
+	MutationBroken            = core.MutationBroken
+	MutationFailed            = core.MutationFailed
+	MutationOK                = core.MutationOK
+	MutationUnchanged         = core.MutationUnchanged
+	NearTokenLimitRatio       = core.NearTokenLimitRatio
+	PIIBlock                  = core.PIIBlock
+	PIICreditCard             = core.PIICreditCard
+	PIIEmail                  = core.PIIEmail
+	PIIMask                   = core.PIIMask
+	PIIOff                    = core.PIIOff
+	PIIPhone                  = core.PIIPhone
+	PIIWarn                   = core.PIIWarn
+	PostCollapseBlankLines    = core.PostCollapseBlankLines
+	PostDedent                = core.PostDedent
+	PostMaxWidth              = core.PostMaxWidth
+	PostStripComments         = core.PostStripComments
+	PostSuffix                = core.PostSuffix
+	ProvenanceFooter          = core.ProvenanceFooter
+	ProvenanceHeader          = core.ProvenanceHeader
+	ProvenanceNone            = core.ProvenanceNone
+	ResponseFormatFormat      = core.ResponseFormatFormat
+	RightDelim                = core.RightDelim
+	RoleAssistant             = core.RoleAssistant
+	RoleSystem                = core.RoleSystem
+	RoleUser                  = core.RoleUser
+	RuleInstructionOverride   = core.RuleInstructionOverride
+	RuleSystemInterpolation   = core.RuleSystemInterpolation
+	RuleUndelimitedInput      = core.RuleUndelimitedInput
+	SeverityHigh              = core.SeverityHigh
+	SeverityMedium            = core.SeverityMedium
+	StackPrefix               = core.StackPrefix
+	TargetModelKey            = core.TargetModelKey
+	TargetProviderKey         = core.TargetProviderKey
+	TokenizerClaude           = core.TokenizerClaude
+	TokenizerEstimate         = core.TokenizerEstimate
+	TracerName                = core.TracerName
+	ValidationDefault         = core.ValidationDefault
+	ValidationOff             = core.ValidationOff
+	ValidationStrict          = core.ValidationStrict
+	ValidationWarn            = core.ValidationWarn
+	WarningDeprecated         = core.WarningDeprecated
+	WarningDeprecatedTemplate = core.WarningDeprecatedTemplate
+	WarningNearTokenLimit     = core.WarningNearTokenLimit
+	WarningNoValue            = core.WarningNoValue
+	WarningPII                = core.WarningPII
+	WarningUnusedKey          = core.WarningUnusedKey
+)
+
+// Errors and values of the core package
+var (
+	DeterministicTime   = core.DeterministicTime
+	ErrBudgetExceeded   = core.ErrBudgetExceeded
+	ErrConfigInvalid    = core.ErrConfigInvalid
+	ErrCycle            = core.ErrCycle
+	ErrDraft            = core.ErrDraft
+	ErrMissingFields    = core.ErrMissingFields
+	ErrOutsideRegistry  = core.ErrOutsideRegistry
+	ErrTemplateNotFound = core.ErrTemplateNotFound
+)
+
+// Types of the core package
+type (
+	AnthropicRequest      = core.AnthropicRequest
+	AuditRecord           = core.AuditRecord
+	AuditSink             = core.AuditSink
+	BenchPhase            = core.BenchPhase
+	BenchReport           = core.BenchReport
+	BranchCoverage        = core.BranchCoverage
+	BuildOption           = core.BuildOption
+	BuildRecord           = core.BuildRecord
+	BuildResult           = core.BuildResult
+	Bundle                = core.Bundle
+	BundledTemplate       = core.BundledTemplate
+	ChangeNotifier        = core.ChangeNotifier
+	CompiledRegistry      = core.CompiledRegistry
+	Config                = core.Config
+	ConfigError           = core.ConfigError
+	ContextProvider       = core.ContextProvider
+	ContextProviderFunc   = core.ContextProviderFunc
+	Coverage              = core.Coverage
+	CoverageReport        = core.CoverageReport
+	DelimitedRegistry     = core.DelimitedRegistry
+	DependencyCycleError  = core.DependencyCycleError
+	Document              = core.Document
+	DocumentOpener        = core.DocumentOpener
+	Documents             = core.Documents
+	DraftError            = core.DraftError
+	Envelope              = core.Envelope
+	EnvelopeMeta          = core.EnvelopeMeta
+	ExportResult          = core.ExportResult
+	ExtensionRegistry     = core.ExtensionRegistry
+	FanoutResult          = core.FanoutResult
+	FlagsProvider         = core.FlagsProvider
+	FlagsProviderFunc     = core.FlagsProviderFunc
+	FrontMatter           = core.FrontMatter
+	FuncRegistry          = core.FuncRegistry
+	History               = core.History
+	IncludeDepthError     = core.IncludeDepthError
+	InjectionFinding      = core.InjectionFinding
+	InjectionReport       = core.InjectionReport
+	IssueKind             = core.IssueKind
+	JSONSchema            = core.JSONSchema
+	JinjaPrompt           = core.JinjaPrompt
+	Limits                = core.Limits
+	MemoryRegistry        = core.MemoryRegistry
+	Message               = core.Message
+	MetricsRecorder       = core.MetricsRecorder
+	Middleware            = core.Middleware
+	MissingFieldsError    = core.MissingFieldsError
+	MutationOutcome       = core.MutationOutcome
+	MutationReport        = core.MutationReport
+	MutationResult        = core.MutationResult
+	OutputLimitError      = core.OutputLimitError
+	OutsideRegistryError  = core.OutsideRegistryError
+	OwnerRegistry         = core.OwnerRegistry
+	PIIError              = core.PIIError
+	PIIMatch              = core.PIIMatch
+	PIIMode               = core.PIIMode
+	PathRegistry          = core.PathRegistry
+	Preview               = core.Preview
+	PreviewRegion         = core.PreviewRegion
+	PromptBuilder         = core.PromptBuilder
+	PromptRegistry        = core.PromptRegistry
+	PromptSystem          = core.PromptSystem
+	Provenance            = core.Provenance
+	ProvenancePosition    = core.ProvenancePosition
+	RawConfigRegistry     = core.RawConfigRegistry
+	RenderFunc            = core.RenderFunc
+	RenderPanicError      = core.RenderPanicError
+	RenderTimeoutError    = core.RenderTimeoutError
+	ResponseFormatError   = core.ResponseFormatError
+	StaticFlags           = core.StaticFlags
+	Target                = core.Target
+	Template              = core.Template
+	TemplateConfigPair    = core.TemplateConfigPair
+	TemplateDependency    = core.TemplateDependency
+	TemplateDeprecation   = core.TemplateDeprecation
+	TemplateError         = core.TemplateError
+	TemplateInfo          = core.TemplateInfo
+	TemplateNotFoundError = core.TemplateNotFoundError
+	TokenBudgetError      = core.TokenBudgetError
+	Tokenizer             = core.Tokenizer
+	ToolDefinition        = core.ToolDefinition
+	ToolFunction          = core.ToolFunction
+	UsageRecord           = core.UsageRecord
+	UsageSink             = core.UsageSink
+	ValidationError       = core.ValidationError
+	ValidationIssue       = core.ValidationIssue
+	ValidationMode        = core.ValidationMode
+	VariableInfo          = core.VariableInfo
+	VariableUse           = core.VariableUse
+	Warning               = core.Warning
+	WarningKind           = core.WarningKind
+	WarningsError         = core.WarningsError
+)
+
+// CfgFromJSONString calls core.CfgFromJSONString
+func CfgFromJSONString(jsonString string, path string) (*Config, error) {
+	return core.CfgFromJSONString(jsonString, path)
+}
+
+// CheckFormat calls core.CheckFormat
+func CheckFormat(format string) error {
+	return core.CheckFormat(format)
+}
+
+// ErrorCode calls core.ErrorCode
+func ErrorCode(err error) string {
+	return core.ErrorCode(err)
+}
+
+// ErrorType calls core.ErrorType
+func ErrorType(err error) string {
+	return core.ErrorType(err)
+}
+
+// EstimateTokens calls core.EstimateTokens
+func EstimateTokens(text string) int {
+	return core.EstimateTokens(text)
+}
+
+// ExitCode calls core.ExitCode
+func ExitCode(err error) int {
+	return core.ExitCode(err)
+}
+
+// ExpandOutputPath calls core.ExpandOutputPath
+func ExpandOutputPath(pattern string, templatePath string) string {
+	return core.ExpandOutputPath(pattern, templatePath)
+}
+
+// ExpandOutputPaths calls core.ExpandOutputPaths
+func ExpandOutputPaths(pattern string, templatePaths []string) (map[string]string, error) {
+	return core.ExpandOutputPaths(pattern, templatePaths)
+}
+
+// ExtractVarsFromPipe calls core.ExtractVarsFromPipe
+func ExtractVarsFromPipe(pipe *parse.PipeNode) map[string]any {
+	return core.ExtractVarsFromPipe(pipe)
+}
+
+// FanoutOutputPaths calls core.FanoutOutputPaths
+func FanoutOutputPaths(pattern string, templatePath string, results []FanoutResult) ([]string, error) {
+	return core.FanoutOutputPaths(pattern, templatePath, results)
+}
+
+// FindPII calls core.FindPII
+func FindPII(config map[string]any) []PIIMatch {
+	return core.FindPII(config)
+}
+
+// FormatResult calls core.FormatResult
+func FormatResult(format string, result *BuildResult) (string, error) {
+	return core.FormatResult(format, result)
+}
+
+// HasOutputPlaceholder calls core.HasOutputPlaceholder
+func HasOutputPlaceholder(pattern string) bool {
+	return core.HasOutputPlaceholder(pattern)
+}
+
+// MaskPII calls core.MaskPII
+func MaskPII(config map[string]any) (map[string]any, []PIIMatch) {
+	return core.MaskPII(config)
+}
+
+// NewAnthropicRequest calls core.NewAnthropicRequest
+func NewAnthropicRequest(messages []Message) *AnthropicRequest {
+	return core.NewAnthropicRequest(messages)
+}
+
+// NewCompiledRegistry calls core.NewCompiledRegistry
+func NewCompiledRegistry(bundle *Bundle) (*CompiledRegistry, error) {
+	return core.NewCompiledRegistry(bundle)
+}
+
+// NewConfig calls core.NewConfig
+func NewConfig(data map[string]any, path string) *Config {
+	return core.NewConfig(data, path)
+}
+
+// NewConfigError calls core.NewConfigError
+func NewConfigError(path string, err error) *ConfigError {
+	return core.NewConfigError(path, err)
+}
+
+// NewCoverage calls core.NewCoverage
+func NewCoverage() *Coverage {
+	return core.NewCoverage()
+}
+
+// NewDependencyCycleError calls core.NewDependencyCycleError
+func NewDependencyCycleError(cycle []string) *DependencyCycleError {
+	return core.NewDependencyCycleError(cycle)
+}
+
+// NewEnvelope calls core.NewEnvelope
+func NewEnvelope(result *BuildResult) *Envelope {
+	return core.NewEnvelope(result)
+}
+
+// NewIncludeDepthError calls core.NewIncludeDepthError
+func NewIncludeDepthError(limit int, chain []string) *IncludeDepthError {
+	return core.NewIncludeDepthError(limit, chain)
+}
+
+// NewMemoryRegistry calls core.NewMemoryRegistry
+func NewMemoryRegistry() *MemoryRegistry {
+	return core.NewMemoryRegistry()
+}
+
+// NewMissingFieldsError calls core.NewMissingFieldsError
+func NewMissingFieldsError(byTemplate map[string][]string) *MissingFieldsError {
+	return core.NewMissingFieldsError(byTemplate)
+}
+
+// NewOutputLimitError calls core.NewOutputLimitError
+func NewOutputLimitError(limit int64) *OutputLimitError {
+	return core.NewOutputLimitError(limit)
+}
+
+// NewOutsideRegistryError calls core.NewOutsideRegistryError
+func NewOutsideRegistryError(path string) *OutsideRegistryError {
+	return core.NewOutsideRegistryError(path)
+}
+
+// NewPIIError calls core.NewPIIError
+func NewPIIError(matches []PIIMatch) *PIIError {
+	return core.NewPIIError(matches)
+}
+
+// NewRenderPanicError calls core.NewRenderPanicError
+func NewRenderPanicError(path string, value any, stack string) *RenderPanicError {
+	return core.NewRenderPanicError(path, value, stack)
+}
+
+// NewRenderTimeoutError calls core.NewRenderTimeoutError
+func NewRenderTimeoutError(limit time.Duration) *RenderTimeoutError {
+	return core.NewRenderTimeoutError(limit)
+}
+
+// NewResponseFormatError calls core.NewResponseFormatError
+func NewResponseFormatError(problems []string) *ResponseFormatError {
+	return core.NewResponseFormatError(problems)
+}
+
+// NewTemplate calls core.NewTemplate
+func NewTemplate(name string, content string, r PromptRegistry) *Template {
+	return core.NewTemplate(name, content, r)
+}
+
+// NewTemplateConfigPair calls core.NewTemplateConfigPair
+func NewTemplateConfigPair(t *Template, c Config) *TemplateConfigPair {
+	return core.NewTemplateConfigPair(t, c)
+}
+
+// NewTemplateError calls core.NewTemplateError
+func NewTemplateError(path string, line int, column int, message string, snippet string, err error) *TemplateError {
+	return core.NewTemplateError(path, line, column, message, snippet, err)
+}
+
+// NewTemplateNotFoundError calls core.NewTemplateNotFoundError
+func NewTemplateNotFoundError(path string, err error) *TemplateNotFoundError {
+	return core.NewTemplateNotFoundError(path, err)
+}
+
+// NewTokenBudgetError calls core.NewTokenBudgetError
+func NewTokenBudgetError(budget int, tokens int) *TokenBudgetError {
+	return core.NewTokenBudgetError(budget, tokens)
+}
+
+// NewValidationError calls core.NewValidationError
+func NewValidationError(issues []ValidationIssue) *ValidationError {
+	return core.NewValidationError(issues)
+}
+
+// NewWarningsError calls core.NewWarningsError
+func NewWarningsError(warnings []Warning) *WarningsError {
+	return core.NewWarningsError(warnings)
+}
+
+// ParseExtensions calls core.ParseExtensions
+func ParseExtensions(s string) ([]string, error) {
+	return core.ParseExtensions(s)
+}
+
+// ParsePIIMode calls core.ParsePIIMode
+func ParsePIIMode(s string) (PIIMode, error) {
+	return core.ParsePIIMode(s)
+}
+
+// ParseProvenance calls core.ParseProvenance
+func ParseProvenance(content string) (*Provenance, error) {
+	return core.ParseProvenance(content)
+}
+
+// ParseProvenancePosition calls core.ParseProvenancePosition
+func ParseProvenancePosition(s string) (ProvenancePosition, error) {
+	return core.ParseProvenancePosition(s)
+}
+
+// ParseValidationMode calls core.ParseValidationMode
+func ParseValidationMode(s string) (ValidationMode, error) {
+	return core.ParseValidationMode(s)
+}
+
+// PostProcess calls core.PostProcess
+func PostProcess(output string, steps []string) (string, error) {
+	return core.PostProcess(output, steps)
+}
+
+// ReadBundle calls core.ReadBundle
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	return core.ReadBundle(r)
+}
+
+// ReadHistory calls core.ReadHistory
+func ReadHistory(r io.Reader) (History, error) {
+	return core.ReadHistory(r)
+}
+
+// ScanInjectionRisks calls core.ScanInjectionRisks
+func ScanInjectionRisks(ctx context.Context, r PromptRegistry, paths []string) (*InjectionReport, error) {
+	return core.ScanInjectionRisks(ctx, r, paths)
+}
+
+// SetParseCacheSize calls core.SetParseCacheSize
+func SetParseCacheSize(size int) {
+	core.SetParseCacheSize(size)
+}
+
+// StackPath calls core.StackPath
+func StackPath(name string) string {
+	return core.StackPath(name)
+}
+
+// TokenizerForModel calls core.TokenizerForModel
+func TokenizerForModel(model string) Tokenizer {
+	return core.TokenizerForModel(model)
+}
+
+// TokenizerNamed calls core.TokenizerNamed
+func TokenizerNamed(name string) (Tokenizer, error) {
+	return core.TokenizerNamed(name)
+}
+
+// ValidateResponse calls core.ValidateResponse
+func ValidateResponse(schema map[string]any, reply string) error {
+	return core.ValidateResponse(schema, reply)
+}
+
+// WithAllowDraft calls core.WithAllowDraft
+func WithAllowDraft() BuildOption {
+	return core.WithAllowDraft()
+}
+
+// WithAuditLabels calls core.WithAuditLabels
+func WithAuditLabels(labels map[string]string) BuildOption {
+	return core.WithAuditLabels(labels)
+}
+
+// WithCoverage calls core.WithCoverage
+func WithCoverage(c *Coverage) BuildOption {
+	return core.WithCoverage(c)
+}
+
+// WithData calls core.WithData
+func WithData(data map[string]any) BuildOption {
+	return core.WithData(data)
+}
+
+// WithDeterministic calls core.WithDeterministic
+func WithDeterministic() BuildOption {
+	return core.WithDeterministic()
+}
+
+// WithHashFooter calls core.WithHashFooter
+func WithHashFooter() BuildOption {
+	return core.WithHashFooter()
+}
+
+// WithHistory calls core.WithHistory
+func WithHistory(history History) BuildOption {
+	return core.WithHistory(history)
+}
+
+// WithLazyDeps calls core.WithLazyDeps
+func WithLazyDeps() BuildOption {
+	return core.WithLazyDeps()
+}
+
+// WithLimits calls core.WithLimits
+func WithLimits(limits Limits) BuildOption {
+	return core.WithLimits(limits)
+}
+
+// WithLocale calls core.WithLocale
+func WithLocale(locale string) BuildOption {
+	return core.WithLocale(locale)
+}
+
+// WithMessages calls core.WithMessages
+func WithMessages() BuildOption {
+	return core.WithMessages()
+}
+
+// WithParam calls core.WithParam
+func WithParam(name string, value string) BuildOption {
+	return core.WithParam(name, value)
+}
+
+// WithProvenance calls core.WithProvenance
+func WithProvenance(position ProvenancePosition) BuildOption {
+	return core.WithProvenance(position)
+}
+
+// WithResponseFormat calls core.WithResponseFormat
+func WithResponseFormat() BuildOption {
+	return core.WithResponseFormat()
+}
+
+// WithSections calls core.WithSections
+func WithSections() BuildOption {
+	return core.WithSections()
+}
+
+// WithTarget calls core.WithTarget
+func WithTarget(target Target) BuildOption {
+	return core.WithTarget(target)
+}
+
+// WithTokenBudget calls core.WithTokenBudget
+func WithTokenBudget(maxTokens int, tokenizer Tokenizer) BuildOption {
+	return core.WithTokenBudget(maxTokens, tokenizer)
+}
+
+// WithValidation calls core.WithValidation
+func WithValidation(mode ValidationMode) BuildOption {
+	return core.WithValidation(mode)
+}
+
+// WithValue calls core.WithValue
+func WithValue(path string, v any) BuildOption {
+	return core.WithValue(path, v)
+}
+
+// WriteBundle calls core.WriteBundle
+func WriteBundle(w io.Writer, bundle *Bundle) error {
+	return core.WriteBundle(w, bundle)
+}
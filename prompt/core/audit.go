@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// AuditRecord describes a rendered prompt without its content, so what was sent to an LLM can be reviewed
+// later without storing the prompts themselves
+type AuditRecord struct {
+	Time     time.Time `json:"time"`
+	Template string    `json:"template"`
+	// TemplateHash digests the template and every template it includes
+	TemplateHash string `json:"template_hash"`
+	// ConfigHash digests the config the template was built with, build data included
+	ConfigHash string `json:"config_hash"`
+	// Labels are the caller's labels from WithAuditLabels, e.g. a request or user id
+	Labels map[string]string `json:"labels,omitempty"`
+	// OutputLength is the size of the rendered prompt in bytes
+	OutputLength int `json:"output_length"`
+}
+
+// AuditSink receives a record of every prompt a PromptSystem renders. A build fails if its record can't be
+// written, so no prompt goes out unaudited.
+type AuditSink interface {
+	Record(ctx context.Context, record AuditRecord) error
+}
+
+// WithAuditLabels attaches labels to the build's audit record. Labels from repeated options are merged.
+func WithAuditLabels(labels map[string]string) BuildOption {
+	return func(o *buildOptions) {
+		if o.auditLabels == nil {
+			o.auditLabels = make(map[string]string, len(labels))
+		}
+		for key, value := range labels {
+			o.auditLabels[key] = value
+		}
+	}
+}
+
+// audit records a rendered prompt to the system's audit sink, if it has one. The template's dependencies
+// must be loaded.
+func (s *PromptSystem) audit(ctx context.Context, template *Template, config *Config, o *buildOptions, outputLength int) error {
+	if s.Audit == nil {
+		return nil
+	}
+	templateHash, err := s.templateHash(ctx, template)
+	if err != nil {
+		return err
+	}
+	cfgHash, err := configHash(config)
+	if err != nil {
+		return err
+	}
+	record := AuditRecord{
+		Time:         time.Now().UTC(),
+		Template:     template.Path,
+		TemplateHash: templateHash,
+		ConfigHash:   cfgHash,
+		Labels:       o.auditLabels,
+		OutputLength: outputLength,
+	}
+	if err := s.Audit.Record(ctx, record); err != nil {
+		return fmt.Errorf("err recording audit log: %w", err)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
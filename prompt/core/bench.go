@@ -0,0 +1,102 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+)
+
+// BenchPhase is how long one phase of a build took and how much it allocated, per iteration
+type BenchPhase struct {
+	NsPerOp     int64  `json:"ns_per_op"`
+	AllocsPerOp uint64 `json:"allocs_per_op"`
+	BytesPerOp  uint64 `json:"bytes_per_op"`
+}
+
+// BenchReport is the cost of each phase of building a template, measured by PromptSystem.Bench
+type BenchReport struct {
+	Template   string `json:"template"`
+	Iterations int    `json:"iterations"`
+	// Parse is parsing the template's own source, without the parse cache
+	Parse BenchPhase `json:"parse"`
+	// Resolve is finding and parsing every template it includes, starting from an empty parse cache
+	Resolve BenchPhase `json:"resolve"`
+	// Execute is rendering the resolved template with the config
+	Execute BenchPhase `json:"execute"`
+}
+
+// Bench builds the template n times and measures parsing, dependency resolution and execution separately, so
+// the slow phase of a slow template is visible. Each phase runs n times on its own, with allocations counted
+// across the whole run, so other goroutines allocating at the same time skew the numbers.
+func (s *PromptSystem) Bench(ctx context.Context, templatePath, configPath string, n int) (*BenchReport, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("iterations must be positive, got %d", n)
+	}
+	root, err := s.find(ctx, templatePath)
+	if err != nil {
+		return nil, err
+	}
+	config, err := s.loadConfig(ctx, configPath, &buildOptions{})
+	if err != nil {
+		return nil, err
+	}
+	report := &BenchReport{Template: templatePath, Iterations: n}
+
+	// copies returns n unparsed copies of the root template, so every iteration starts from scratch
+	copies := func(cache bool) []*Template {
+		templates := make([]*Template, n)
+		for i := range templates {
+			templates[i] = NewTemplate(root.Path, root.OriginalContent, s.Registry)
+			templates[i].limits = root.limits
+			if cache {
+				templates[i].cache = newParseCache(0)
+			}
+		}
+		return templates
+	}
+
+	parsed := copies(false)
+	if report.Parse, err = measure(n, func(i int) error { return parsed[i].Compile() }); err != nil {
+		return nil, err
+	}
+
+	resolved := copies(true)
+	for _, t := range resolved {
+		if err := t.Compile(); err != nil {
+			return nil, err
+		}
+	}
+	if report.Resolve, err = measure(n, func(i int) error { return resolved[i].LoadDependencies(ctx) }); err != nil {
+		return nil, err
+	}
+
+	if err := root.LoadDependencies(ctx); err != nil {
+		return nil, err
+	}
+	if report.Execute, err = measure(n, func(int) error { return root.execute(ctx, io.Discard, root.Path, *config) }); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// measure runs f n times and returns the average time and allocations of a run
+func measure(n int, f func(i int) error) (BenchPhase, error) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		if err := f(i); err != nil {
+			return BenchPhase{}, err
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return BenchPhase{
+		NsPerOp:     elapsed.Nanoseconds() / int64(n),
+		AllocsPerOp: (after.Mallocs - before.Mallocs) / uint64(n),
+		BytesPerOp:  (after.TotalAlloc - before.TotalAlloc) / uint64(n),
+	}, nil
+}
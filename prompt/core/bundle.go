@@ -0,0 +1,179 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// bundleMagic starts every bundle file, so other files are rejected before decoding
+const bundleMagic = "RPROMPTBUNDLE"
+
+// BundleVersion is bumped whenever the bundle format changes
+const BundleVersion = 1
+
+// Bundle is a registry compiled into a single artifact, such as by the prompt package's CompileRegistry, for serving prompts without the
+// registry directory. Every template in it parsed and resolved its dependencies when it was compiled.
+//
+// text/template parse trees can't be serialized, so a bundle holds each template's checked source along with
+// its resolved dependencies and schema, and NewCompiledRegistry parses every template once when it loads.
+type Bundle struct {
+	Version   int
+	Templates map[string]*BundledTemplate
+	// Configs maps config paths to their JSON
+	Configs map[string]string
+	// LeftDelim and RightDelim are the delimiters of the compiled registry, empty for the defaults
+	LeftDelim  string
+	RightDelim string
+	// Extensions are the template extensions of the compiled registry, empty for DefaultTemplateExtension
+	Extensions []string
+}
+
+// BundledTemplate is a template compiled into a Bundle
+type BundledTemplate struct {
+	Content string
+	// Dependencies are the registry paths of every template it transitively includes, sorted
+	Dependencies []string
+	Schema       *JSONSchema
+}
+
+// WriteBundle encodes the bundle to w
+func WriteBundle(w io.Writer, bundle *Bundle) error {
+	if _, err := io.WriteString(w, bundleMagic); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := gob.NewEncoder(w).Encode(bundle); err != nil {
+		return fmt.Errorf("failed to encode bundle: %w", err)
+	}
+	return nil
+}
+
+// ReadBundle decodes a bundle written by WriteBundle
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(bundleMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic, []byte(bundleMagic)) {
+		return nil, errors.New("not an rprompt bundle")
+	}
+	bundle := &Bundle{}
+	if err := gob.NewDecoder(br).Decode(bundle); err != nil {
+		return nil, fmt.Errorf("failed to decode bundle: %w", err)
+	}
+	if bundle.Version != BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d, expected %d", bundle.Version, BundleVersion)
+	}
+	return bundle, nil
+}
+
+// CompiledRegistry serves templates and configs from a Bundle instead of a directory. It is read-only and
+// safe for concurrent use.
+type CompiledRegistry struct {
+	bundle *Bundle
+	// cache holds the parse trees of every template in the bundle and is never evicted
+	cache *parseCache
+	// ids maps the ids in the templates' front matter to the paths declaring them, see IDPrefix
+	ids map[string][]string
+}
+
+// TemplateExtensions returns the template extensions of the registry the bundle was compiled from, see
+// ExtensionRegistry
+func (r *CompiledRegistry) TemplateExtensions() []string {
+	return r.bundle.Extensions
+}
+
+// Delimiters returns the delimiters of the registry the bundle was compiled from, see DelimitedRegistry
+func (r *CompiledRegistry) Delimiters() (string, string) {
+	return r.bundle.LeftDelim, r.bundle.RightDelim
+}
+
+// NewCompiledRegistry parses every template in the bundle up front, so finding and building them never parses
+func NewCompiledRegistry(bundle *Bundle) (*CompiledRegistry, error) {
+	r := &CompiledRegistry{bundle: bundle, cache: newParseCache(0)}
+	contents := make(map[string]string, len(bundle.Templates))
+	for path, bundled := range bundle.Templates {
+		contents[path] = bundled.Content
+	}
+	r.ids = TemplateIDs(contents)
+	for path, bundled := range bundle.Templates {
+		template := NewTemplate(path, bundled.Content, r)
+		template.cache = r.cache
+		if err := template.Compile(); err != nil {
+			return nil, fmt.Errorf("error parsing template %s: %w", path, err)
+		}
+	}
+	return r, nil
+}
+
+// Find returns the bundled template at path along with its parse trees. Path may name the template by its id
+// instead, see IDPrefix.
+func (r *CompiledRegistry) Find(ctx context.Context, path string) (*Template, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if id, ok := strings.CutPrefix(path, IDPrefix); ok {
+		resolved, err := PathForID(id, r.ids)
+		if err != nil {
+			return nil, err
+		}
+		path = resolved
+	}
+	bundled, ok := r.bundle.Templates[path]
+	if !ok {
+		return nil, fmt.Errorf("template %s is not in the bundle: %w", path, fs.ErrNotExist)
+	}
+	template := NewTemplate(path, bundled.Content, r)
+	template.cache = r.cache
+	return template, nil
+}
+
+// LoadConfig returns the bundled config at path
+func (r *CompiledRegistry) LoadConfig(ctx context.Context, path string) (*Config, error) {
+	data, err := r.ReadConfig(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return CfgFromJSONString(string(data), path)
+}
+
+// ReadConfig returns the JSON of the bundled config at path as it was compiled, see RawConfigRegistry
+func (r *CompiledRegistry) ReadConfig(ctx context.Context, path string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	data, ok := r.bundle.Configs[path]
+	if !ok {
+		return nil, fmt.Errorf("config %s is not in the bundle: %w", path, fs.ErrNotExist)
+	}
+	return []byte(data), nil
+}
+
+// SaveConfig always fails: bundles are read-only
+func (r *CompiledRegistry) SaveConfig(ctx context.Context, cfg *Config) error {
+	return fmt.Errorf("cannot save config %s: compiled registries are read-only", cfg.Path)
+}
+
+// List returns the paths of every template in the bundle, sorted
+func (r *CompiledRegistry) List() []string {
+	paths := make([]string, 0, len(r.bundle.Templates))
+	for path := range r.bundle.Templates {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Schema returns the schema the template at path was compiled with
+func (r *CompiledRegistry) Schema(path string) (*JSONSchema, bool) {
+	bundled, ok := r.bundle.Templates[path]
+	if !ok {
+		return nil, false
+	}
+	return bundled.Schema, true
+}
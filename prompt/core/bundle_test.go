@@ -0,0 +1,40 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompiledRegistry_ParsesOnLoad(t *testing.T) {
+	bundle := &Bundle{
+		Version: BundleVersion,
+		Templates: map[string]*BundledTemplate{
+			"main.tmpl":   {Content: `[[template "header" .]] Hello [[.name]]`, Dependencies: []string{"header.tmpl"}},
+			"header.tmpl": {Content: `# [[.title]]`},
+		},
+		Configs: map[string]string{"main.json": `{"name": "World", "title": "Greeting"}`},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, WriteBundle(&buf, bundle))
+	loaded, err := ReadBundle(&buf)
+	require.NoError(t, err)
+
+	registry, err := NewCompiledRegistry(loaded)
+	require.NoError(t, err)
+	assert.Equal(t, 2, registry.cache.len())
+
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	output, err := system.Build(context.Background(), "main.tmpl", "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, "# Greeting Hello World", output)
+	// Building parses nothing new
+	assert.Equal(t, 2, registry.cache.len())
+
+	_, err = ReadBundle(bytes.NewReader([]byte("not a bundle")))
+	assert.Error(t, err)
+}
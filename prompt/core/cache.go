@@ -0,0 +1,111 @@
+package core
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"text/template/parse"
+)
+
+// DefaultParseCacheSize is how many parsed templates the process-wide parse cache holds before evicting the
+// least recently used
+const DefaultParseCacheSize = 1024
+
+// sharedParseCache is the process-wide parse cache every PromptSystem uses, so systems over the same templates
+// parse them once between them
+var sharedParseCache = newParseCache(DefaultParseCacheSize)
+
+// SetParseCacheSize changes how many parsed templates the process-wide parse cache holds, evicting the least
+// recently used ones if it holds more. A size of zero or less disables eviction.
+func SetParseCacheSize(size int) {
+	sharedParseCache.resize(size)
+}
+
+// parseCache is an LRU cache of parsed template trees keyed by template path and content hash, so unchanged
+// templates are only parsed once. It is safe for concurrent use; cached trees are never mutated after parsing.
+type parseCache struct {
+	mu   sync.Mutex
+	size int
+	// order holds a *cacheEntry per key, most recently used first
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key   string
+	trees map[string]*parse.Tree
+}
+
+func newParseCache(size int) *parseCache {
+	return &parseCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// cacheKey identifies a template by its path and a hash of its content, so edits invalidate the entry
+func cacheKey(path, content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return path + "@" + hex.EncodeToString(sum[:])
+}
+
+func (c *parseCache) get(key string) (map[string]*parse.Tree, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).trees, true
+}
+
+func (c *parseCache) put(key string, trees map[string]*parse.Tree) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheEntry).trees = trees
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, trees: trees})
+	c.evict()
+}
+
+// invalidate drops every cached version of the template at path
+func (c *parseCache) invalidate(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prefix := path + "@"
+	for key, elem := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.order.Remove(elem)
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *parseCache) resize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size = size
+	c.evict()
+}
+
+// evict drops the least recently used entries until the cache fits its size. c.mu must be held.
+func (c *parseCache) evict() {
+	for c.size > 0 && c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *parseCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
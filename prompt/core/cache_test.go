@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheKey(t *testing.T) {
+	assert.Equal(t, cacheKey("a.tmpl", "content"), cacheKey("a.tmpl", "content"))
+	assert.NotEqual(t, cacheKey("a.tmpl", "content"), cacheKey("b.tmpl", "content"))
+	assert.NotEqual(t, cacheKey("a.tmpl", "content"), cacheKey("a.tmpl", "changed"))
+}
+
+func TestPromptSystem_ParseCache(t *testing.T) {
+	registry := newTestRegistry(t, map[string]string{
+		"main.tmpl":   `[[template "footer.tmpl" .]] Hello [[.name]]`,
+		"footer.tmpl": `Footer`,
+	})
+	require.NoError(t, registry.SetConfig("config.json", `{"name": "John"}`))
+
+	system, _ := NewPromptSystem(registry)
+	// A cache of its own so entries from other tests don't count
+	system.cache = newParseCache(DefaultParseCacheSize)
+	ctx := context.Background()
+
+	result, err := system.Build(ctx, "main.tmpl", "config.json")
+	require.NoError(t, err)
+	assert.Equal(t, "Footer Hello John", result)
+	assert.Equal(t, 2, system.cache.len())
+
+	// Unchanged templates are served from the cache
+	result, err = system.Build(ctx, "main.tmpl", "config.json")
+	require.NoError(t, err)
+	assert.Equal(t, "Footer Hello John", result)
+	assert.Equal(t, 2, system.cache.len())
+
+	// Editing a template invalidates its entry
+	require.NoError(t, registry.SetTemplate("footer.tmpl", `New footer`))
+	result, err = system.Build(ctx, "main.tmpl", "config.json")
+	require.NoError(t, err)
+	assert.Equal(t, "New footer Hello John", result)
+	assert.Equal(t, 3, system.cache.len())
+}
+
+func TestParseCache_LRU(t *testing.T) {
+	cache := newParseCache(2)
+	cache.put("a", nil)
+	cache.put("b", nil)
+	_, ok := cache.get("a")
+	require.True(t, ok)
+
+	// b is the least recently used
+	cache.put("c", nil)
+	assert.Equal(t, 2, cache.len())
+	_, ok = cache.get("b")
+	assert.False(t, ok)
+	_, ok = cache.get("a")
+	assert.True(t, ok)
+
+	cache.resize(1)
+	assert.Equal(t, 1, cache.len())
+	_, ok = cache.get("a")
+	assert.True(t, ok)
+}
+
+func TestPromptSystem_SharedParseCache(t *testing.T) {
+	first, _ := NewPromptSystem(NewMemoryRegistry())
+	second, _ := NewPromptSystem(NewMemoryRegistry())
+	assert.Same(t, first.cache, second.cache)
+}
+
+func TestPromptSystem_InvalidateOnChange(t *testing.T) {
+	registry := newTestRegistry(t, map[string]string{"main.tmpl": `Hello`})
+	system, _ := NewPromptSystem(registry)
+	system.cache = newParseCache(DefaultParseCacheSize)
+	registry.OnChange(system.cache.invalidate)
+	ctx := context.Background()
+
+	_, err := system.Build(ctx, "main.tmpl", "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, system.cache.len())
+
+	// Saving drops every cached version of the template
+	require.NoError(t, registry.SetTemplate("main.tmpl", `Bye`))
+	assert.Equal(t, 0, system.cache.len())
+	result, err := system.Build(ctx, "main.tmpl", "")
+	require.NoError(t, err)
+	assert.Equal(t, "Bye", result)
+}
+
+func TestPromptSystem_ConcurrentBuilds(t *testing.T) {
+	registry := newTestRegistry(t, map[string]string{
+		"main.tmpl":   `[[template "header.tmpl" .]] Hello [[.name]]`,
+		"header.tmpl": `Header [[template "title.tmpl"]]`,
+		"title.tmpl":  `Title`,
+	})
+	require.NoError(t, registry.SetConfig("config.json", `{"name": "John"}`))
+
+	system, _ := NewPromptSystem(registry)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := system.Build(context.Background(), "main.tmpl", "config.json")
+			if err != nil {
+				errs <- err
+				return
+			}
+			if result != "Header Title Hello John" {
+				errs <- fmt.Errorf("unexpected result %q", result)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,197 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// type Config map[string]any
+type Config struct {
+	Config map[string]any
+	Path   string
+}
+
+func NewConfig(data map[string]any, path string) *Config {
+	return &Config{
+		Config: data,
+		Path:   path,
+	}
+}
+
+func CfgFromJSONString(jsonString string, path string) (*Config, error) {
+	var data map[string]any
+	err := json.Unmarshal([]byte(jsonString), &data)
+	if err != nil {
+		return nil, NewConfigError(path, err)
+	}
+
+	return NewConfig(data, path), nil
+}
+
+// MarshalConfig encodes config as indented JSON. When previous holds the JSON of the file being replaced, the
+// keys of every object keep their order in it, with new keys after them in sorted order, and the file keeps its
+// indentation and trailing newline, so an updated config diffs only where its values changed.
+func MarshalConfig(config map[string]any, previous []byte) ([]byte, error) {
+	order, err := decodeKeyOrder(json.NewDecoder(bytes.NewReader(previous)))
+	if err != nil || len(bytes.TrimSpace(previous)) == 0 {
+		return json.MarshalIndent(config, "", "  ")
+	}
+	var compact bytes.Buffer
+	if err := encodeOrdered(&compact, config, order); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, compact.Bytes(), "", jsonIndent(previous)); err != nil {
+		return nil, err
+	}
+	if bytes.HasSuffix(previous, []byte("\n")) {
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// jsonIndent returns the indentation of the first nested line of an indented JSON document, or two spaces
+func jsonIndent(data []byte) string {
+	_, rest, ok := bytes.Cut(data, []byte("\n"))
+	if !ok {
+		return "  "
+	}
+	indent := rest[:len(rest)-len(bytes.TrimLeft(rest, " \t"))]
+	if len(indent) == 0 {
+		return "  "
+	}
+	return string(indent)
+}
+
+// keyOrder records the order of the keys of a JSON object, and of the objects nested in it
+type keyOrder struct {
+	keys   []string
+	fields map[string]*keyOrder
+	items  []*keyOrder
+}
+
+// field returns the order of the object at key, nil when o doesn't record one
+func (o *keyOrder) field(key string) *keyOrder {
+	if o == nil {
+		return nil
+	}
+	return o.fields[key]
+}
+
+// item returns the order of the ith item of an array, nil when o doesn't record one
+func (o *keyOrder) item(i int) *keyOrder {
+	if o == nil || i >= len(o.items) {
+		return nil
+	}
+	return o.items[i]
+}
+
+// sorted returns the keys of object in the order o records, with the keys o doesn't record after them in sorted
+// order. Keys o records in sorted order, as in every file rprompt writes, stay sorted with the new ones among
+// them.
+func (o *keyOrder) sorted(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	seen := make(map[string]bool, len(object))
+	if o != nil {
+		for _, key := range o.keys {
+			if _, ok := object[key]; ok && !seen[key] {
+				keys = append(keys, key)
+				seen[key] = true
+			}
+		}
+	}
+	start := len(keys)
+	if sort.StringsAreSorted(keys) {
+		start = 0
+	}
+	for key := range object {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys[start:])
+	return keys
+}
+
+// decodeKeyOrder reads the next JSON value from dec, returning the order of its keys if it is an object or
+// array and nil otherwise
+func decodeKeyOrder(dec *json.Decoder) (*keyOrder, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		order := &keyOrder{fields: make(map[string]*keyOrder)}
+		for dec.More() {
+			token, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := token.(string)
+			child, err := decodeKeyOrder(dec)
+			if err != nil {
+				return nil, err
+			}
+			order.keys = append(order.keys, key)
+			order.fields[key] = child
+		}
+		_, err = dec.Token()
+		return order, err
+	case json.Delim('['):
+		order := &keyOrder{}
+		for dec.More() {
+			child, err := decodeKeyOrder(dec)
+			if err != nil {
+				return nil, err
+			}
+			order.items = append(order.items, child)
+		}
+		_, err = dec.Token()
+		return order, err
+	default:
+		return nil, nil
+	}
+}
+
+// encodeOrdered writes value to buf as compact JSON, ordering the keys of its objects by order
+func encodeOrdered(buf *bytes.Buffer, value any, order *keyOrder) error {
+	switch v := value.(type) {
+	case map[string]any:
+		buf.WriteByte('{')
+		for i, key := range order.sorted(v) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			name, err := json.Marshal(key)
+			if err != nil {
+				return err
+			}
+			buf.Write(name)
+			buf.WriteByte(':')
+			if err := encodeOrdered(buf, v[key], order.field(key)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []any:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeOrdered(buf, item, order.item(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+	return nil
+}
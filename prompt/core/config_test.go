@@ -0,0 +1,50 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalConfig_KeepsKeyOrder(t *testing.T) {
+	previous := "{\n    \"zebra\": 1,\n    \"apple\": {\"y\": true, \"x\": false},\n    \"items\": [{\"b\": 1, \"a\": 2}]\n}\n"
+	config := map[string]any{
+		"zebra": 2,
+		"apple": map[string]any{"x": false, "y": true, "new": ""},
+		"items": []any{map[string]any{"a": 2, "b": 1, "c": ""}, map[string]any{"b": 3, "a": 4}},
+		"mango": "",
+	}
+
+	data, err := MarshalConfig(config, []byte(previous))
+	require.NoError(t, err)
+	assert.Equal(t, `{
+    "zebra": 2,
+    "apple": {
+        "y": true,
+        "x": false,
+        "new": ""
+    },
+    "items": [
+        {
+            "b": 1,
+            "a": 2,
+            "c": ""
+        },
+        {
+            "a": 4,
+            "b": 3
+        }
+    ],
+    "mango": ""
+}
+`, string(data))
+
+	data, err = MarshalConfig(map[string]any{"b": 1, "c": 2, "a": 3}, []byte(`{"a": 1, "c": 2}`))
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"a\": 3,\n  \"b\": 1,\n  \"c\": 2\n}", string(data), "new keys join sorted keys in order")
+
+	data, err = MarshalConfig(map[string]any{"b": 1, "a": 2}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"a\": 2,\n  \"b\": 1\n}", string(data))
+}
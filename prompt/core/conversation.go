@@ -0,0 +1,128 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// History is a conversation transcript, oldest turn first. Chat agents that rebuild their prompt every turn
+// pass it to the build WithHistory and lay it out with [[history]].
+type History []Message
+
+// WithHistory gives the build a conversation for [[history]] to render. Without it [[history]] renders nothing,
+// as on the first turn of a conversation.
+func WithHistory(history History) BuildOption {
+	return func(o *buildOptions) {
+		o.history = history
+	}
+}
+
+// ReadHistory decodes a history from JSON: an array of {"role": ..., "content": ...} turns, or an object
+// holding them under "messages" as chat completion requests do
+func ReadHistory(r io.Reader) (History, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var history History
+	if err := json.Unmarshal(data, &history); err != nil {
+		var request struct {
+			Messages History `json:"messages"`
+		}
+		if json.Unmarshal(data, &request) != nil {
+			return nil, fmt.Errorf("history must be a JSON array of turns with a role and content: %w", err)
+		}
+		history = request.Messages
+	}
+	for i, turn := range history {
+		if _, err := role(turn.Role); err != nil {
+			return nil, fmt.Errorf("turn %d: %w", i+1, err)
+		}
+	}
+	return history, nil
+}
+
+// String lays the history out as a transcript, a line per turn labelled with its role, e.g. "User: hi"
+func (h History) String() string {
+	var b strings.Builder
+	for i, turn := range h {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if turn.Role != "" {
+			b.WriteString(strings.ToUpper(turn.Role[:1]) + turn.Role[1:] + ": ")
+		}
+		b.WriteString(turn.Content)
+	}
+	return b.String()
+}
+
+// sanitized returns the history without the NUL bytes that would let a turn forge a [[role]] marker and start
+// a message of its own, see sanitizeData
+func (h History) sanitized() History {
+	var clean History
+	for i, turn := range h {
+		if !strings.Contains(turn.Role+turn.Content, "\x00") {
+			continue
+		}
+		if clean == nil {
+			clean = append(History(nil), h...)
+		}
+		clean[i] = Message{Role: sanitizeData(turn.Role).(string), Content: sanitizeData(turn.Content).(string)}
+	}
+	if clean == nil {
+		return h
+	}
+	return clean
+}
+
+// Last returns the most recent n turns
+func (h History) Last(n int) History {
+	if n < len(h) {
+		return h[len(h)-max(n, 0):]
+	}
+	return h
+}
+
+// Fit returns the most recent turns whose content fits within maxTokens as counted by count, or EstimateTokens
+// when count is nil. Turns are dropped oldest first and a turn is never cut, so a turn too long for the budget
+// ends the history there.
+func (h History) Fit(maxTokens int, count Tokenizer) History {
+	if count == nil {
+		count = EstimateTokens
+	}
+	used := 0
+	for i := len(h) - 1; i >= 0; i-- {
+		used += count(h[i].Content)
+		if used > maxTokens {
+			return h[i+1:]
+		}
+	}
+	return h
+}
+
+// recent is the template function behind [[history "last=10" "tokens=2000"]]. It returns the build's history,
+// limited to the last turns and then to the most recent turns that fit the token budget when those options
+// are given. Printed directly the history is a transcript; ranging over it lays out each turn, e.g. as its
+// own message with [[role .Role]][[.Content]].
+func (h History) recent(options ...string) (History, error) {
+	for _, option := range options {
+		key, value, _ := strings.Cut(option, "=")
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("history option %s must be set to a number, e.g. %s=10", key, key)
+		}
+		switch key {
+		case "last":
+			h = h.Last(n)
+		case "tokens":
+			h = h.Fit(n, nil)
+		default:
+			return nil, fmt.Errorf("unknown history option %q, expected last=<turns> or tokens=<tokens>", option)
+		}
+	}
+	return h, nil
+}
@@ -0,0 +1,38 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testHistory = History{
+	{Role: RoleUser, Content: "Hi, my invoice is wrong."},
+	{Role: RoleAssistant, Content: "Sorry to hear that! Which invoice?"},
+	{Role: RoleUser, Content: "March."},
+	{Role: RoleAssistant, Content: "Let me check March."},
+}
+
+func TestHistory(t *testing.T) {
+	assert.Equal(t, "User: March.\nAssistant: Let me check March.", testHistory.Last(2).String())
+	assert.Equal(t, testHistory, testHistory.Last(10))
+	assert.Empty(t, testHistory.Last(0))
+
+	count := func(text string) int { return len(strings.Fields(text)) }
+	assert.Equal(t, testHistory[2:], testHistory.Fit(5, count), "turns are dropped oldest first")
+	assert.Equal(t, testHistory[3:], testHistory.Fit(4, count), "a turn is never cut")
+	assert.Empty(t, testHistory.Fit(3, count))
+	assert.Equal(t, testHistory, testHistory.Fit(1000, nil))
+
+	budget := EstimateTokens(testHistory[2].Content) + EstimateTokens(testHistory[3].Content)
+	recent, err := testHistory.recent("last=3", fmt.Sprintf("tokens=%d", budget))
+	require.NoError(t, err)
+	assert.Len(t, recent, 2)
+	_, err = testHistory.recent("first=2")
+	assert.ErrorContains(t, err, `unknown history option "first=2"`)
+	_, err = testHistory.recent("last=ten")
+	assert.ErrorContains(t, err, "history option last must be set to a number")
+}
@@ -0,0 +1,417 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// maxConvertDepth bounds how deeply included templates are inlined, since defines may call themselves
+const maxConvertDepth = 32
+
+// JinjaPrompt is a template converted to Jinja2 syntax, which LangChain, promptfoo (Nunjucks) and most
+// Python tooling understand
+type JinjaPrompt struct {
+	// Template is the whole prompt, with role sections marked by {# role: ... #} comments
+	Template string `json:"template"`
+	// Messages holds a message per [[role]] section, each with Jinja2 content. It is empty for templates
+	// without roles.
+	Messages []Message `json:"messages,omitempty"`
+	// Variables are the top-level config keys the prompt uses
+	Variables []string `json:"variables"`
+	// Unsupported lists the constructs that couldn't be converted. They are left in the output as comments.
+	Unsupported []string `json:"unsupported,omitempty"`
+}
+
+// ConvertToJinja converts the template, with every template it includes inlined, to Jinja2. Fields,
+// conditionals, loops, with blocks, variables, comparisons and common functions are translated; anything
+// else is reported in Unsupported.
+func (t *Template) ConvertToJinja(ctx context.Context) (*JinjaPrompt, error) {
+	if err := t.LoadDependencies(ctx); err != nil {
+		return nil, err
+	}
+
+	c := &jinjaConverter{t: t, vars: map[string]string{}, variables: map[string]bool{}}
+	var b strings.Builder
+	c.list(&b, t.Tmpl.Tree.Root, "")
+	raw := b.String()
+
+	prompt := &JinjaPrompt{
+		Template:    roleMarkerPattern.ReplaceAllString(raw, "{# role: $1 #}"),
+		Variables:   []string{},
+		Unsupported: c.unsupported,
+	}
+	if c.hasRoles {
+		prompt.Messages = splitMessages(raw)
+	}
+	for name := range c.variables {
+		prompt.Variables = append(prompt.Variables, name)
+	}
+	sort.Strings(prompt.Variables)
+	return prompt, nil
+}
+
+// jinjaConverter walks a template's parse tree writing the equivalent Jinja2. dot is the Jinja2
+// expression the template's dot refers to at each point, empty at the root where fields are plain names.
+type jinjaConverter struct {
+	t *Template
+	// vars maps template variables such as $item to Jinja2 expressions
+	vars map[string]string
+	// variables collects the top-level config keys referenced
+	variables map[string]bool
+	// depth counts enclosing control structures; roles only split messages outside of them
+	depth int
+	// includes counts the templates currently being inlined
+	includes    int
+	loops       int
+	hasRoles    bool
+	unsupported []string
+}
+
+func (c *jinjaConverter) fail(b *strings.Builder, node parse.Node, reason string) {
+	c.unsupported = append(c.unsupported, fmt.Sprintf("%s: %s", node.String(), reason))
+	fmt.Fprintf(b, "{# unsupported: %s #}", strings.ReplaceAll(node.String(), "#}", "# }"))
+}
+
+func (c *jinjaConverter) list(b *strings.Builder, list *parse.ListNode, dot string) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		c.node(b, node, dot)
+	}
+}
+
+func (c *jinjaConverter) node(b *strings.Builder, node parse.Node, dot string) {
+	switch n := node.(type) {
+	case *parse.TextNode:
+		text := string(n.Text)
+		if strings.Contains(text, "{{") || strings.Contains(text, "{%") || strings.Contains(text, "{#") {
+			text = "{% raw %}" + text + "{% endraw %}"
+		}
+		b.WriteString(text)
+	case *parse.CommentNode:
+	case *parse.ActionNode:
+		c.action(b, n, dot)
+	case *parse.IfNode:
+		c.branch(b, n.Pipe, n.List, n.ElseList, dot, func(expr string) string { return dot })
+	case *parse.WithNode:
+		c.branch(b, n.Pipe, n.List, n.ElseList, dot, func(expr string) string { return expr })
+	case *parse.RangeNode:
+		c.rangeNode(b, n, dot)
+	case *parse.TemplateNode:
+		c.include(b, n, dot)
+	default:
+		c.fail(b, node, "no Jinja2 equivalent")
+	}
+}
+
+func (c *jinjaConverter) action(b *strings.Builder, n *parse.ActionNode, dot string) {
+	if name, args, ok := builtinCall(n.Pipe); ok {
+		switch name {
+		case "role":
+			role, isString := args[0].(*parse.StringNode)
+			if !isString || c.depth > 0 {
+				c.fail(b, n, "roles can only be converted outside of conditionals and loops")
+				return
+			}
+			c.hasRoles = true
+			b.WriteString(marker("role", role.Text))
+			return
+		case "trimmable", "endtrimmable":
+			// Jinja2 has no token budgets, so trimmable sections are kept in full
+			return
+		}
+	}
+
+	expr, ok := c.pipe(n.Pipe, dot)
+	if !ok {
+		c.fail(b, n, "unsupported expression")
+		return
+	}
+	if len(n.Pipe.Decl) > 0 {
+		name := c.declare(n.Pipe.Decl[0].Ident[0])
+		fmt.Fprintf(b, "{%% set %s = %s %%}", name, expr)
+		return
+	}
+	fmt.Fprintf(b, "{{ %s }}", expr)
+}
+
+// branch writes an if block, used for both if and with. inner gives the dot inside the block.
+func (c *jinjaConverter) branch(b *strings.Builder, pipe *parse.PipeNode, list, elseList *parse.ListNode, dot string, inner func(expr string) string) {
+	expr, ok := c.pipe(pipe, dot)
+	if !ok {
+		c.fail(b, pipe, "unsupported condition")
+		return
+	}
+	if len(pipe.Decl) > 0 {
+		c.vars[pipe.Decl[0].Ident[0]] = expr
+	}
+	c.depth++
+	defer func() { c.depth-- }()
+
+	fmt.Fprintf(b, "{%% if %s %%}", expr)
+	c.list(b, list, inner(expr))
+	if elseList != nil {
+		b.WriteString("{% else %}")
+		c.list(b, elseList, dot)
+	}
+	b.WriteString("{% endif %}")
+}
+
+func (c *jinjaConverter) rangeNode(b *strings.Builder, n *parse.RangeNode, dot string) {
+	expr, ok := c.pipe(n.Pipe, dot)
+	if !ok {
+		c.fail(b, n, "unsupported range expression")
+		return
+	}
+
+	var item string
+	switch len(n.Pipe.Decl) {
+	case 0:
+		c.loops++
+		item = "item"
+		if c.loops > 1 {
+			item = fmt.Sprintf("item%d", c.loops)
+		}
+		defer func() { c.loops-- }()
+	case 1:
+		item = c.declare(n.Pipe.Decl[0].Ident[0])
+	default:
+		c.vars[n.Pipe.Decl[0].Ident[0]] = "loop.index0"
+		item = c.declare(n.Pipe.Decl[1].Ident[0])
+	}
+	c.depth++
+	defer func() { c.depth-- }()
+
+	fmt.Fprintf(b, "{%% for %s in %s %%}", item, expr)
+	c.list(b, n.List, item)
+	if n.ElseList != nil {
+		b.WriteString("{% else %}")
+		c.list(b, n.ElseList, dot)
+	}
+	b.WriteString("{% endfor %}")
+}
+
+// include inlines an included template, define or block
+func (c *jinjaConverter) include(b *strings.Builder, n *parse.TemplateNode, dot string) {
+	included := c.t.templates().Lookup(n.Name)
+	if included == nil || included.Tree == nil {
+		c.fail(b, n, "template not found")
+		return
+	}
+	if c.includes >= maxConvertDepth {
+		c.fail(b, n, "recursive templates can't be inlined")
+		return
+	}
+
+	innerDot := ""
+	if isDotPipe(n.Pipe) {
+		innerDot = dot
+	} else if n.Pipe != nil {
+		expr, ok := c.pipe(n.Pipe, dot)
+		if !ok {
+			c.fail(b, n, "unsupported template argument")
+			return
+		}
+		innerDot = expr
+	}
+	c.includes++
+	defer func() { c.includes-- }()
+	c.list(b, included.Tree.Root, innerDot)
+}
+
+// isDotPipe reports whether a pipeline is just the dot, which passes the root through unchanged
+func isDotPipe(pipe *parse.PipeNode) bool {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Decl) > 0 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	_, ok := pipe.Cmds[0].Args[0].(*parse.DotNode)
+	return ok
+}
+
+// declare registers a template variable and returns its Jinja2 name
+func (c *jinjaConverter) declare(variable string) string {
+	name := strings.TrimPrefix(variable, "$")
+	c.vars[variable] = name
+	return name
+}
+
+// pipe converts a pipeline to a Jinja2 expression. Each command's result is passed as the last argument of
+// the next, as in Go templates.
+func (c *jinjaConverter) pipe(pipe *parse.PipeNode, dot string) (string, bool) {
+	if pipe == nil || len(pipe.Cmds) == 0 {
+		return "", false
+	}
+	var expr string
+	for i, cmd := range pipe.Cmds {
+		args := make([]string, 0, len(cmd.Args))
+		for _, arg := range cmd.Args[1:] {
+			converted, ok := c.arg(arg, dot)
+			if !ok {
+				return "", false
+			}
+			args = append(args, converted)
+		}
+		if i > 0 {
+			args = append(args, expr)
+		}
+
+		ident, isFunc := cmd.Args[0].(*parse.IdentifierNode)
+		if !isFunc {
+			if len(args) > 0 {
+				return "", false
+			}
+			converted, ok := c.arg(cmd.Args[0], dot)
+			if !ok {
+				return "", false
+			}
+			expr = converted
+			continue
+		}
+		converted, ok := jinjaFunc(ident.Ident, args)
+		if !ok {
+			return "", false
+		}
+		expr = converted
+	}
+	return expr, true
+}
+
+func (c *jinjaConverter) arg(node parse.Node, dot string) (string, bool) {
+	switch n := node.(type) {
+	case *parse.DotNode:
+		return dot, dot != ""
+	case *parse.FieldNode:
+		if dot == "" {
+			c.variables[n.Ident[0]] = true
+		}
+		return joinField(dot, n.Ident), true
+	case *parse.VariableNode:
+		base := ""
+		if n.Ident[0] != "$" {
+			var ok bool
+			if base, ok = c.vars[n.Ident[0]]; !ok {
+				return "", false
+			}
+		}
+		if len(n.Ident) == 1 {
+			return base, base != ""
+		}
+		if base == "" {
+			c.variables[n.Ident[1]] = true
+		}
+		return joinField(base, n.Ident[1:]), true
+	case *parse.ChainNode:
+		base, ok := c.arg(n.Node, dot)
+		if !ok {
+			return "", false
+		}
+		return joinField(base, n.Field), true
+	case *parse.PipeNode:
+		expr, ok := c.pipe(n, dot)
+		return "(" + expr + ")", ok
+	case *parse.StringNode:
+		return n.Quoted, true
+	case *parse.NumberNode:
+		return n.Text, true
+	case *parse.BoolNode:
+		if n.True {
+			return "true", true
+		}
+		return "false", true
+	case *parse.NilNode:
+		return "none", true
+	}
+	return "", false
+}
+
+func joinField(base string, idents []string) string {
+	if base == "" {
+		return strings.Join(idents, ".")
+	}
+	return base + "." + strings.Join(idents, ".")
+}
+
+var jinjaOperators = map[string]string{
+	"eq": "==",
+	"ne": "!=",
+	"lt": "<",
+	"le": "<=",
+	"gt": ">",
+	"ge": ">=",
+}
+
+// jinjaFunc converts a call to a Go template function
+func jinjaFunc(name string, args []string) (string, bool) {
+	switch name {
+	case "eq", "ne", "lt", "le", "gt", "ge":
+		if len(args) != 2 {
+			return "", false
+		}
+		return fmt.Sprintf("(%s %s %s)", args[0], jinjaOperators[name], args[1]), true
+	case "and", "or":
+		if len(args) == 0 {
+			return "", false
+		}
+		return "(" + strings.Join(args, " "+name+" ") + ")", true
+	case "not":
+		if len(args) != 1 {
+			return "", false
+		}
+		return "(not " + args[0] + ")", true
+	case "len":
+		if len(args) != 1 {
+			return "", false
+		}
+		return "(" + args[0] + "|length)", true
+	case "index":
+		if len(args) == 0 {
+			return "", false
+		}
+		expr := args[0]
+		for _, key := range args[1:] {
+			expr += "[" + key + "]"
+		}
+		return expr, true
+	case "print":
+		if len(args) == 0 {
+			return "", false
+		}
+		return "(" + strings.Join(args, " ~ ") + ")", true
+	case "printf":
+		if len(args) == 0 {
+			return "", false
+		}
+		return fmt.Sprintf("(%s|format(%s))", args[0], strings.Join(args[1:], ", ")), true
+	case "html":
+		if len(args) != 1 {
+			return "", false
+		}
+		return "(" + args[0] + "|e)", true
+	case "urlquery":
+		if len(args) != 1 {
+			return "", false
+		}
+		return "(" + args[0] + "|urlencode)", true
+	}
+	return "", false
+}
+
+// builtinCall reports whether a pipeline is a single call to a function, returning its name and arguments
+func builtinCall(pipe *parse.PipeNode) (string, []parse.Node, bool) {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Decl) > 0 {
+		return "", nil, false
+	}
+	ident, ok := pipe.Cmds[0].Args[0].(*parse.IdentifierNode)
+	if !ok {
+		return "", nil, false
+	}
+	args := pipe.Cmds[0].Args[1:]
+	if ident.Ident == "role" && len(args) != 1 {
+		return "", nil, false
+	}
+	return ident.Ident, args, true
+}
@@ -0,0 +1,215 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"text/template"
+	"text/template/parse"
+)
+
+// BranchCoverage is how many times one branch of an if, with or range was rendered. Its position is that of
+// the condition or the ranged over value.
+type BranchCoverage struct {
+	Template string `json:"template"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	// Kind is if, with or range
+	Kind string `json:"kind"`
+	// Branch is then or else for if and with, and body or else for range. Else branches are tracked even when
+	// the template has no else, since leaving a section out is worth testing too.
+	Branch string `json:"branch"`
+	Hits   int    `json:"hits"`
+}
+
+func (b BranchCoverage) String() string {
+	return fmt.Sprintf("%s:%d:%d %s %s", b.Template, b.Line, b.Column, b.Kind, b.Branch)
+}
+
+// CoverageReport lists the branches of every template a set of builds executed, in source order
+type CoverageReport struct {
+	Covered  int              `json:"covered"`
+	Total    int              `json:"total"`
+	Branches []BranchCoverage `json:"branches"`
+}
+
+// Uncovered returns the branches no build rendered
+func (r *CoverageReport) Uncovered() []BranchCoverage {
+	var uncovered []BranchCoverage
+	for _, b := range r.Branches {
+		if b.Hits == 0 {
+			uncovered = append(uncovered, b)
+		}
+	}
+	return uncovered
+}
+
+// Percent returns the percentage of branches rendered, which is 100 when there are no branches
+func (r *CoverageReport) Percent() float64 {
+	if r.Total == 0 {
+		return 100
+	}
+	return float64(r.Covered) / float64(r.Total) * 100
+}
+
+// Coverage records which branches of their templates builds render, see WithCoverage. It is safe to share
+// between concurrent builds.
+type Coverage struct {
+	mu       sync.Mutex
+	branches map[string]*BranchCoverage
+}
+
+func NewCoverage() *Coverage {
+	return &Coverage{branches: make(map[string]*BranchCoverage)}
+}
+
+// WithCoverage records the branches of the template and its dependencies the build renders into c. The
+// template is copied and instrumented for each build, so it slows builds down and is meant for tests.
+func WithCoverage(c *Coverage) BuildOption {
+	return func(o *buildOptions) {
+		o.coverage = c
+	}
+}
+
+// Report returns the branches recorded so far
+func (c *Coverage) Report() *CoverageReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report := &CoverageReport{Total: len(c.branches), Branches: make([]BranchCoverage, 0, len(c.branches))}
+	for _, b := range c.branches {
+		if b.Hits > 0 {
+			report.Covered++
+		}
+		report.Branches = append(report.Branches, *b)
+	}
+	sort.Slice(report.Branches, func(i, j int) bool {
+		a, b := report.Branches[i], report.Branches[j]
+		if a.Template != b.Template {
+			return a.Template < b.Template
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return b.Branch == "else" && a.Branch != "else"
+	})
+	return report
+}
+
+// register adds a branch with no hits if it isn't known yet, returning its key
+func (c *Coverage) register(b BranchCoverage) string {
+	key := b.String()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.branches[key]; !ok {
+		c.branches[key] = &b
+	}
+	return key
+}
+
+func (c *Coverage) hit(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if b, ok := c.branches[key]; ok {
+		b.Hits++
+	}
+}
+
+// instrument returns a copy of the template set with a branch marker at the start of every branch, which
+// coverageWriter records as the set executes
+func (c *Coverage) instrument(t *Template, tmpl *template.Template) (*template.Template, error) {
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	// Clone shares parse trees between the sets, so the trees are copied before they are changed
+	for _, named := range clone.Templates() {
+		if named.Tree == nil {
+			continue
+		}
+		tree := named.Tree.Copy()
+		c.instrumentList(t, tree, tree.Root)
+		if _, err := clone.AddParseTree(named.Name(), tree); err != nil {
+			return nil, err
+		}
+	}
+	return clone, nil
+}
+
+func (c *Coverage) instrumentList(t *Template, tree *parse.Tree, list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.IfNode:
+			c.instrumentBranch(t, tree, &n.BranchNode, "if", "then")
+		case *parse.WithNode:
+			c.instrumentBranch(t, tree, &n.BranchNode, "with", "then")
+		case *parse.RangeNode:
+			c.instrumentBranch(t, tree, &n.BranchNode, "range", "body")
+		case *parse.ListNode:
+			c.instrumentList(t, tree, n)
+		}
+	}
+}
+
+// instrumentBranch prepends a marker to both lists of a branch node, adding an else list if it has none
+func (c *Coverage) instrumentBranch(t *Template, tree *parse.Tree, node *parse.BranchNode, kind, then string) {
+	c.instrumentList(t, tree, node.List)
+	c.instrumentList(t, tree, node.ElseList)
+	path, line, column := t.position(tree, node)
+	mark := func(list *parse.ListNode, branch string) {
+		key := c.register(BranchCoverage{Template: path, Line: line, Column: column, Kind: kind, Branch: branch})
+		text := &parse.TextNode{NodeType: parse.NodeText, Pos: node.Pos, Text: []byte(marker("branch", key))}
+		list.Nodes = append([]parse.Node{text}, list.Nodes...)
+	}
+	mark(node.List, then)
+	if node.ElseList == nil {
+		node.ElseList = &parse.ListNode{NodeType: parse.NodeList, Pos: node.Pos}
+	}
+	mark(node.ElseList, "else")
+}
+
+// nodeLocationPattern matches the location parse.Tree.ErrorContext returns: "name:line:col", where col is
+// a 0-based byte offset
+var nodeLocationPattern = regexp.MustCompile(`^(.*):(\d+):(\d+)$`)
+
+// position returns the template path, 1-based line and column of a node in one of t's parse trees
+func (t *Template) position(tree *parse.Tree, node parse.Node) (string, int, int) {
+	location, _ := tree.ErrorContext(node)
+	m := nodeLocationPattern.FindStringSubmatch(location)
+	if m == nil {
+		return tree.ParseName, 0, 0
+	}
+	line, _ := strconv.Atoi(m[2])
+	offset, _ := strconv.Atoi(m[3])
+	content, ok := t.source(m[1])
+	if !ok {
+		return m[1], line, offset + 1
+	}
+	_, line, column := sourcePosition(content, line, offset)
+	return m[1], line, column
+}
+
+var branchMarkerPrefix = []byte(markerPrefix + "branch:")
+
+// coverageWriter records the branch markers an instrumented template writes and drops them from the output
+type coverageWriter struct {
+	w io.Writer
+	c *Coverage
+}
+
+func (cw *coverageWriter) Write(p []byte) (int, error) {
+	if !bytes.HasPrefix(p, branchMarkerPrefix) || !markerPattern.Match(p) {
+		return cw.w.Write(p)
+	}
+	cw.c.hit(string(p[len(branchMarkerPrefix) : len(p)-len(markerSuffix)]))
+	return len(p), nil
+}
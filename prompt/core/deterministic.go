@@ -0,0 +1,73 @@
+package core
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"text/template"
+	"time"
+)
+
+// DeterministicTime is what now returns in deterministic builds
+var DeterministicTime = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// DeterministicSeed seeds randInt and shuffle in deterministic builds. Every build starts from the seed, so a
+// template renders the same output each time however many builds ran before it.
+const DeterministicSeed = 1
+
+// WithDeterministic fixes the output of the time and random template functions for this build: now returns
+// DeterministicTime and randInt and shuffle draw from a source seeded with DeterministicSeed. Snapshot tests
+// of templates that print dates or shuffle examples then don't flake. Ranging over and printing maps is
+// already in key order, so nothing else in a template depends on chance.
+func WithDeterministic() BuildOption {
+	return func(o *buildOptions) {
+		o.deterministic = true
+	}
+}
+
+// randomness is the source behind randInt and shuffle. A nil rng uses the global source.
+type randomness struct {
+	rng *rand.Rand
+}
+
+// intN is the template function behind [[randInt 10]], returning a random integer in [0, n)
+func (r randomness) intN(n int) (int, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("randInt: n must be positive, got %d", n)
+	}
+	if r.rng == nil {
+		return rand.IntN(n), nil
+	}
+	return r.rng.IntN(n), nil
+}
+
+// shuffle is the template function behind [[range shuffle .examples]], returning a shuffled copy of a slice
+// or array
+func (r randomness) shuffle(list any) ([]any, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("shuffle: expected a list, got %T", list)
+	}
+	shuffled := make([]any, v.Len())
+	for i := range shuffled {
+		shuffled[i] = v.Index(i).Interface()
+	}
+	swap := func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] }
+	if r.rng == nil {
+		rand.Shuffle(len(shuffled), swap)
+	} else {
+		r.rng.Shuffle(len(shuffled), swap)
+	}
+	return shuffled, nil
+}
+
+// deterministicFuncs returns fixed implementations of the time and random functions, with a freshly seeded
+// source so concurrent builds don't share it
+func deterministicFuncs() template.FuncMap {
+	r := randomness{rng: rand.New(rand.NewPCG(DeterministicSeed, DeterministicSeed))}
+	return template.FuncMap{
+		"now":     func() time.Time { return DeterministicTime },
+		"randInt": r.intN,
+		"shuffle": r.shuffle,
+	}
+}
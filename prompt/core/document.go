@@ -0,0 +1,143 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// documentChunkSize is how much of a document is written at a time, so output limits and cancellation apply
+// part way through large documents
+const documentChunkSize = 64 << 10
+
+// DocumentOpener is implemented by registries that can stream documents included with the document function
+type DocumentOpener interface {
+	// OpenDocument opens the file at the registry path for reading
+	OpenDocument(ctx context.Context, path string) (io.ReadCloser, error)
+}
+
+// document is the template function behind [[document "docs/spec.md"]]. It includes a file from the registry
+// verbatim without parsing it or holding it in the template, so large context documents cost no parse time
+// or cache space. The file is streamed into the output in chunks while the template executes.
+func document(path string) string {
+	return marker("document", path)
+}
+
+// include is the template function behind [[include "docs/spec.md"]]. Like document, it inlines a file from the
+// registry verbatim without parsing it as a template, and it can cut out lines: [[include "docs/spec.md" 10]]
+// includes line 10 to the end and [[include "docs/spec.md" 10 20]] lines 10 to 20. Lines are 1-based and a range
+// running past the end of the file stops there. Lines keep their line breaks.
+func include(path string, lines ...int) (string, error) {
+	start, end := 1, 0
+	switch len(lines) {
+	case 0:
+	case 1:
+		start = lines[0]
+	case 2:
+		start, end = lines[0], lines[1]
+	default:
+		return "", fmt.Errorf("include %s: expected at most a first and last line, got %d lines", path, len(lines))
+	}
+	if start < 1 || (len(lines) == 2 && end < start) {
+		return "", fmt.Errorf("include %s: invalid line range %v", path, lines)
+	}
+	return marker("include", fmt.Sprintf("%d,%d,%s", start, end, path)), nil
+}
+
+var (
+	documentMarkerPrefix = []byte(markerPrefix + "document:")
+	includeMarkerPrefix  = []byte(markerPrefix + "include:")
+)
+
+// documentWriter streams each document into w in place of the marker the document or include function wrote
+type documentWriter struct {
+	ctx context.Context
+	w   io.Writer
+	r   PromptRegistry
+}
+
+func (d *documentWriter) Write(p []byte) (int, error) {
+	if !bytes.HasPrefix(p, []byte(markerPrefix)) || !markerPattern.Match(p) {
+		return d.w.Write(p)
+	}
+	var err error
+	switch {
+	case bytes.HasPrefix(p, documentMarkerPrefix):
+		err = d.include(string(p[len(documentMarkerPrefix):len(p)-len(markerSuffix)]), d.w)
+	case bytes.HasPrefix(p, includeMarkerPrefix):
+		var start, end int
+		var path string
+		arg := string(p[len(includeMarkerPrefix) : len(p)-len(markerSuffix)])
+		if parts := strings.SplitN(arg, ",", 3); len(parts) == 3 {
+			start, _ = strconv.Atoi(parts[0])
+			end, _ = strconv.Atoi(parts[1])
+			path = parts[2]
+		}
+		err = d.include(path, &lineRangeWriter{w: d.w, start: start, end: end, line: 1})
+		if errors.Is(err, errLineRangeDone) {
+			err = nil
+		}
+	default:
+		return d.w.Write(p)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// include copies the document at path into w a chunk at a time
+func (d *documentWriter) include(path string, w io.Writer) error {
+	opener, ok := d.r.(DocumentOpener)
+	if !ok {
+		return fmt.Errorf("cannot include document %s: the registry doesn't support documents", path)
+	}
+	rc, err := opener.OpenDocument(d.ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to open document %s: %w", path, err)
+	}
+	defer rc.Close()
+	// Hide WriterTo and ReaderFrom so the copy goes through the chunk buffer instead of a single write
+	buf := make([]byte, documentChunkSize)
+	_, err = io.CopyBuffer(struct{ io.Writer }{w}, struct{ io.Reader }{rc}, buf)
+	return err
+}
+
+// errLineRangeDone stops copying a document once a lineRangeWriter has written its last line
+var errLineRangeDone = errors.New("line range done")
+
+// lineRangeWriter writes the lines from start to end of what is written to it, through to the end when end is 0
+type lineRangeWriter struct {
+	w          io.Writer
+	start, end int
+	// line is the line the next byte written is on
+	line int
+}
+
+func (lw *lineRangeWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if lw.end > 0 && lw.line > lw.end {
+			return 0, errLineRangeDone
+		}
+		i := bytes.IndexByte(p, '\n')
+		chunk := p
+		if i >= 0 {
+			chunk = p[:i+1]
+		}
+		if lw.line >= lw.start {
+			if _, err := lw.w.Write(chunk); err != nil {
+				return 0, err
+			}
+		}
+		p = p[len(chunk):]
+		if i >= 0 {
+			lw.line++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingWriter records the size of every write
+type recordingWriter struct {
+	strings.Builder
+	writes []int
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, len(p))
+	return w.Builder.Write(p)
+}
+
+func TestTemplate_Document(t *testing.T) {
+	spec := strings.Repeat("[[not a template]] line\n", 10000)
+	registry := newTestRegistry(t, map[string]string{"main.tmpl": `Intro [[.name]]
+[[document "docs/spec.md"]][[document "docs/empty.md"]]End`})
+	registry.SetDocument("docs/spec.md", spec)
+	registry.SetDocument("docs/empty.md", "")
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	builder, err := system.NewBuilder(ctx, "main.tmpl", "", WithValue("name", "World"))
+	require.NoError(t, err)
+	var w recordingWriter
+	require.NoError(t, builder.BuildTo(ctx, &w))
+	assert.Equal(t, "Intro World\n"+spec+"End", w.String())
+	// The document arrives in chunks rather than a single write
+	for _, n := range w.writes {
+		assert.LessOrEqual(t, n, documentChunkSize)
+	}
+
+	// Output limits stop part way through
+	_, err = system.Build(ctx, "main.tmpl", "", WithValue("name", "World"), WithLimits(Limits{MaxOutputBytes: 1000}))
+	var limitErr *OutputLimitError
+	assert.ErrorAs(t, err, &limitErr)
+
+	// Rendering through middleware includes the document too
+	system.Use(func(next RenderFunc) RenderFunc { return next })
+	output, err := system.Build(ctx, "main.tmpl", "", WithValue("name", "World"))
+	require.NoError(t, err)
+	assert.Equal(t, "Intro World\n"+spec+"End", output)
+}
+
+func TestTemplate_DocumentMarkerInConfig(t *testing.T) {
+	registry := newTestRegistry(t, map[string]string{"main.tmpl": `Hi [[.name]]`})
+	registry.SetDocument("secret.txt", "TOP SECRET")
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	for _, injected := range []string{
+		marker("document", "secret.txt"),
+		marker("include", "0,0,secret.txt"),
+	} {
+		output, err := system.Build(ctx, "main.tmpl", "", WithData(map[string]any{"name": injected}))
+		require.NoError(t, err)
+		assert.NotContains(t, output, "TOP SECRET", "config values can't inline registry files")
+		assert.NotContains(t, output, "\x00")
+	}
+	output, err := system.Build(ctx, "main.tmpl", "", WithData(map[string]any{"name": []any{map[string]any{"x\x00": "\x00rprompt-document:secret.txt\x00"}}}))
+	require.NoError(t, err)
+	assert.Equal(t, "Hi [map[x:rprompt-document:secret.txt]]", output)
+}
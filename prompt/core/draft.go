@@ -0,0 +1,60 @@
+package core
+
+// WithAllowDraft builds templates marked as drafts in their front matter, and templates including them, which
+// builds otherwise refuse with a *DraftError. Tests and previews of unreviewed prompts use it.
+func WithAllowDraft() BuildOption {
+	return func(o *buildOptions) {
+		o.allowDraft = true
+	}
+}
+
+// DraftError is returned when building a template that is, or includes, a draft without WithAllowDraft
+type DraftError struct {
+	// Path is the draft template
+	Path string `json:"path"`
+	// IncludedBy is the template being built when the draft is one of its dependencies
+	IncludedBy string `json:"included_by,omitempty"`
+}
+
+func (e *DraftError) Error() string {
+	msg := e.Path + " is a draft"
+	if e.IncludedBy != "" {
+		msg += " included by " + e.IncludedBy
+	}
+	return msg + ", publish it with 'rprompt publish " + e.Path + "' or build it with --allow-draft"
+}
+
+func (e *DraftError) Is(target error) bool {
+	return target == ErrDraft
+}
+
+// checkDrafts returns a *DraftError if the template or one of its dependencies is a draft. The dependencies
+// must be loaded.
+func checkDrafts(template *Template, fm *FrontMatter) error {
+	if fm.Draft {
+		return &DraftError{Path: template.Path}
+	}
+	for _, dep := range template.Dependencies() {
+		source, _ := template.source(dep)
+		depFrontMatter, err := parseFrontMatter(dep, source)
+		if err != nil {
+			return err
+		}
+		if depFrontMatter.Draft {
+			return &DraftError{Path: dep, IncludedBy: template.Path}
+		}
+	}
+	return nil
+}
+
+// checkDrafts refuses drafts unless the build allows them
+func (o *buildOptions) checkDrafts(template *Template) error {
+	if o.allowDraft {
+		return nil
+	}
+	fm, err := template.FrontMatter()
+	if err != nil {
+		return err
+	}
+	return checkDrafts(template, fm)
+}
@@ -0,0 +1,430 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sentinel errors for each failure category. Every error of a category matches its sentinel with errors.Is,
+// while errors.As gives the details, e.g. errors.As(err, &missing) for a *MissingFieldsError.
+var (
+	ErrTemplateNotFound = errors.New("template not found")
+	ErrConfigInvalid    = errors.New("invalid config")
+	ErrMissingFields    = errors.New("missing config values")
+	ErrCycle            = errors.New("dependency cycle")
+	ErrBudgetExceeded   = errors.New("token budget exceeded")
+	ErrDraft            = errors.New("draft template")
+	ErrOutsideRegistry  = errors.New("path outside registry")
+)
+
+// Stable error codes returned by ErrorCode. Codes never change once published, so scripts and callers can
+// branch on them instead of on error messages.
+const (
+	CodeTemplateNotFound = "template_not_found"
+	CodeConfigInvalid    = "config_invalid"
+	CodeMissingFields    = "missing_fields"
+	CodeCycle            = "dependency_cycle"
+	CodeBudgetExceeded   = "budget_exceeded"
+	CodeTemplate         = "template_error"
+	CodeLimit            = "limit_exceeded"
+	CodePanic            = "render_panic"
+	CodeCanceled         = "canceled"
+	CodeDraft            = "draft_template"
+	CodeOutsideRegistry  = "outside_registry"
+	CodeUnknown          = "unknown"
+)
+
+// errorCatalog lists the error codes with the process exit code the CLI uses for each, checked in order
+var errorCatalog = []struct {
+	code     string
+	exitCode int
+	match    func(error) bool
+}{
+	{CodeTemplateNotFound, 3, func(err error) bool { return errors.Is(err, ErrTemplateNotFound) }},
+	{CodeConfigInvalid, 4, func(err error) bool { return errors.Is(err, ErrConfigInvalid) }},
+	{CodeMissingFields, 5, func(err error) bool { return errors.Is(err, ErrMissingFields) }},
+	{CodeCycle, 6, func(err error) bool { return errors.Is(err, ErrCycle) }},
+	{CodeBudgetExceeded, 7, func(err error) bool { return errors.Is(err, ErrBudgetExceeded) }},
+	{CodeLimit, 8, func(err error) bool {
+		var output *OutputLimitError
+		var timeout *RenderTimeoutError
+		var depth *IncludeDepthError
+		return errors.As(err, &output) || errors.As(err, &timeout) || errors.As(err, &depth)
+	}},
+	{CodeCanceled, 9, func(err error) bool {
+		return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+	}},
+	{CodePanic, 10, func(err error) bool {
+		var panicked *RenderPanicError
+		return errors.As(err, &panicked)
+	}},
+	{CodeTemplate, 11, func(err error) bool {
+		var located *TemplateError
+		return errors.As(err, &located)
+	}},
+	{CodeDraft, 12, func(err error) bool { return errors.Is(err, ErrDraft) }},
+	{CodeOutsideRegistry, 13, func(err error) bool { return errors.Is(err, ErrOutsideRegistry) }},
+}
+
+// ErrorCode returns the stable code of err's category, CodeUnknown if it has none, or "" for a nil error
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, entry := range errorCatalog {
+		if entry.match(err) {
+			return entry.code
+		}
+	}
+	return CodeUnknown
+}
+
+// ExitCode returns the process exit code for err: 0 for nil, a code per category from 3 up, and 1 otherwise
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	for _, entry := range errorCatalog {
+		if entry.match(err) {
+			return entry.exitCode
+		}
+	}
+	return 1
+}
+
+func NewTemplateNotFoundError(path string, err error) *TemplateNotFoundError {
+	return &TemplateNotFoundError{Path: path, Err: err}
+}
+
+// TemplateNotFoundError is returned when the registry has no template at a path
+type TemplateNotFoundError struct {
+	Path string `json:"path"`
+	// Err is the registry's error, e.g. one matching fs.ErrNotExist
+	Err error `json:"-"`
+}
+
+func (e *TemplateNotFoundError) Error() string {
+	return fmt.Sprintf("template %s not found: %v", e.Path, e.Err)
+}
+
+func (e *TemplateNotFoundError) Is(target error) bool {
+	return target == ErrTemplateNotFound
+}
+
+func (e *TemplateNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// WrapNotFound wraps a registry error finding the template at path in a TemplateNotFoundError when the
+// template doesn't exist
+func WrapNotFound(path string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) && !errors.Is(err, ErrTemplateNotFound) {
+		return NewTemplateNotFoundError(path, err)
+	}
+	return err
+}
+
+func NewOutsideRegistryError(path string) *OutsideRegistryError {
+	return &OutsideRegistryError{Path: path}
+}
+
+// OutsideRegistryError is returned when a path given to a LocalPromptRegistry leads outside its directory
+type OutsideRegistryError struct {
+	Path string `json:"path"`
+}
+
+func (e *OutsideRegistryError) Error() string {
+	return fmt.Sprintf("path %s is outside the registry", e.Path)
+}
+
+func (e *OutsideRegistryError) Is(target error) bool {
+	return target == ErrOutsideRegistry
+}
+
+func NewConfigError(path string, err error) *ConfigError {
+	return &ConfigError{Path: path, Err: err}
+}
+
+// ConfigError is returned when a config can't be parsed
+type ConfigError struct {
+	Path string `json:"path"`
+	Err  error  `json:"-"`
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("failed to parse JSON into Config: %v", e.Err)
+}
+
+func (e *ConfigError) Is(target error) bool {
+	return target == ErrConfigInvalid
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// NewMissingFieldsError creates a MissingFieldsError from the missing paths each template requires
+func NewMissingFieldsError(byTemplate map[string][]string) *MissingFieldsError {
+	seen := make(map[string]bool)
+	fields := []string{}
+	templates := make(map[string][]string, len(byTemplate))
+	for path, paths := range byTemplate {
+		sorted := append([]string{}, paths...)
+		sort.Strings(sorted)
+		templates[path] = sorted
+		for _, field := range paths {
+			if !seen[field] {
+				seen[field] = true
+				fields = append(fields, field)
+			}
+		}
+	}
+	sort.Strings(fields)
+	return &MissingFieldsError{MissingFields: fields, Templates: templates}
+}
+
+// MissingFieldsError is returned when a config lacks values its template requires
+type MissingFieldsError struct {
+	// MissingFields are the dotted paths of every missing value, e.g. user.profile.name, sorted
+	MissingFields []string `json:"missing_fields"`
+	// Templates maps the registry path of each template to the missing paths it uses. A path used by
+	// several templates is listed under each.
+	Templates map[string][]string `json:"templates"`
+}
+
+func (e *MissingFieldsError) Error() string {
+	var errMsg strings.Builder
+	errMsg.WriteString("missing config values:\n")
+	paths := make([]string, 0, len(e.Templates))
+	for path := range e.Templates {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		errMsg.WriteString(fmt.Sprintf("  %s: %s\n", path, strings.Join(e.Templates[path], ", ")))
+	}
+	return errMsg.String()
+}
+
+func (e *MissingFieldsError) Is(target error) bool {
+	return target == ErrMissingFields
+}
+
+func NewValidationError(issues []ValidationIssue) *ValidationError {
+	return &ValidationError{Issues: issues}
+}
+
+// ValidationError is returned by strict validation when a config doesn't match its template
+type ValidationError struct {
+	Issues []ValidationIssue `json:"issues"`
+}
+
+func (e *ValidationError) Error() string {
+	var errMsg strings.Builder
+	errMsg.WriteString("config validation failed:\n")
+	for _, issue := range e.Issues {
+		errMsg.WriteString(fmt.Sprintf("  %s: %s\n", issue.Kind, issue.Message))
+	}
+	return errMsg.String()
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrConfigInvalid
+}
+
+func NewPIIError(matches []PIIMatch) *PIIError {
+	return &PIIError{Matches: matches}
+}
+
+// PIIError is returned when a config holds likely PII and the system's PII mode is PIIBlock
+type PIIError struct {
+	Matches []PIIMatch `json:"matches"`
+}
+
+func (e *PIIError) Error() string {
+	values := make([]string, len(e.Matches))
+	for i, match := range e.Matches {
+		values[i] = match.String()
+	}
+	return fmt.Sprintf("config holds likely PII in %s", strings.Join(values, ", "))
+}
+
+func (e *PIIError) Is(target error) bool {
+	return target == ErrConfigInvalid
+}
+
+func NewDependencyCycleError(cycle []string) *DependencyCycleError {
+	return &DependencyCycleError{Cycle: cycle}
+}
+
+// DependencyCycleError is returned when templates include each other in a loop
+type DependencyCycleError struct {
+	// Cycle is the chain of template paths that loops, starting and ending with the same template
+	Cycle []string `json:"cycle"`
+}
+
+func (e *DependencyCycleError) Error() string {
+	return fmt.Sprintf("dependency cycle: %s", strings.Join(e.Cycle, " -> "))
+}
+
+func (e *DependencyCycleError) Is(target error) bool {
+	return target == ErrCycle
+}
+
+func NewOutputLimitError(limit int64) *OutputLimitError {
+	return &OutputLimitError{Limit: limit}
+}
+
+// OutputLimitError is returned when a build writes more than Limits.MaxOutputBytes
+type OutputLimitError struct {
+	Limit int64 `json:"limit"`
+}
+
+func (e *OutputLimitError) Error() string {
+	return fmt.Sprintf("output exceeds the limit of %d bytes", e.Limit)
+}
+
+func NewIncludeDepthError(limit int, chain []string) *IncludeDepthError {
+	return &IncludeDepthError{Limit: limit, Chain: chain}
+}
+
+// IncludeDepthError is returned when templates include each other deeper than Limits.MaxIncludeDepth
+type IncludeDepthError struct {
+	Limit int `json:"limit"`
+	// Chain is the path of includes that went too deep, starting at the root template
+	Chain []string `json:"chain"`
+}
+
+func (e *IncludeDepthError) Error() string {
+	return fmt.Sprintf("include depth exceeds the limit of %d: %s", e.Limit, strings.Join(e.Chain, " -> "))
+}
+
+func NewRenderTimeoutError(limit time.Duration) *RenderTimeoutError {
+	return &RenderTimeoutError{Limit: limit}
+}
+
+// RenderTimeoutError is returned when executing a template takes longer than Limits.MaxRenderDuration
+type RenderTimeoutError struct {
+	Limit time.Duration `json:"limit"`
+}
+
+func (e *RenderTimeoutError) Error() string {
+	return fmt.Sprintf("rendering exceeded the limit of %s", e.Limit)
+}
+
+func NewTokenBudgetError(budget, tokens int) *TokenBudgetError {
+	return &TokenBudgetError{Budget: budget, Tokens: tokens}
+}
+
+// TokenBudgetError is returned when a prompt doesn't fit its token budget even with every trimmable
+// section removed
+type TokenBudgetError struct {
+	Budget int `json:"budget"`
+	Tokens int `json:"tokens"`
+}
+
+func (e *TokenBudgetError) Error() string {
+	return fmt.Sprintf("prompt is %d tokens after trimming, over the budget of %d", e.Tokens, e.Budget)
+}
+
+func (e *TokenBudgetError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+func NewResponseFormatError(problems []string) *ResponseFormatError {
+	return &ResponseFormatError{Problems: problems}
+}
+
+// ResponseFormatError is returned when a reply doesn't match the response schema its template declares
+type ResponseFormatError struct {
+	Problems []string `json:"problems"`
+}
+
+func (e *ResponseFormatError) Error() string {
+	var errMsg strings.Builder
+	errMsg.WriteString("response does not match the schema:\n")
+	for _, problem := range e.Problems {
+		errMsg.WriteString(fmt.Sprintf("  %s\n", problem))
+	}
+	return errMsg.String()
+}
+
+func NewTemplateError(path string, line, column int, message, snippet string, err error) *TemplateError {
+	return &TemplateError{Path: path, Line: line, Column: column, Message: message, Snippet: snippet, Err: err}
+}
+
+// TemplateError locates a parse or execution error in a template's source
+type TemplateError struct {
+	// Path is the registry path of the template the error is in, which may be a dependency
+	Path string `json:"path"`
+	// Line and Column are 1-based positions in the template file, front matter included. Column is 0 when
+	// text/template only reports the line, as it does for parse errors.
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+	// Snippet is the offending source line with a caret under the column, empty when the source isn't known
+	Snippet string `json:"snippet,omitempty"`
+	// Err is the text/template error
+	Err error `json:"-"`
+}
+
+func (e *TemplateError) Error() string {
+	if e.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", e.Path, e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Message)
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+func NewRenderPanicError(path string, value any, stack string) *RenderPanicError {
+	return &RenderPanicError{Path: path, Value: value, Stack: stack}
+}
+
+// RenderPanicError is returned when executing a template panics, e.g. in a template function or a writer.
+// The panic is recovered so it can't crash the host process.
+type RenderPanicError struct {
+	// Path is the registry path of the template being executed
+	Path string `json:"path"`
+	// Value is what was passed to panic
+	Value any `json:"-"`
+	// Stack is the goroutine's stack at the time of the panic
+	Stack string `json:"stack"`
+}
+
+func (e *RenderPanicError) Error() string {
+	return fmt.Sprintf("panic while rendering %s: %v", e.Path, e.Value)
+}
+
+// Unwrap returns the panic value if it is an error
+func (e *RenderPanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+func NewWarningsError(warnings []Warning) *WarningsError {
+	return &WarningsError{Warnings: warnings}
+}
+
+// WarningsError is returned by the CLI's --fail-on-warn when a build has warnings
+type WarningsError struct {
+	Warnings []Warning `json:"warnings"`
+}
+
+func (e *WarningsError) Error() string {
+	var errMsg strings.Builder
+	errMsg.WriteString("build has warnings:\n")
+	for _, warning := range e.Warnings {
+		errMsg.WriteString(fmt.Sprintf("  %s: %s\n", warning.Kind, warning.Message))
+	}
+	return errMsg.String()
+}
@@ -0,0 +1,18 @@
+package core
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCatalog_NotFoundKeepsCause(t *testing.T) {
+	err := WrapNotFound("main.tmpl", fs.ErrNotExist)
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	var notFoundErr *TemplateNotFoundError
+	require.ErrorAs(t, err, &notFoundErr)
+	assert.Equal(t, "main.tmpl", notFoundErr.Path)
+}
@@ -0,0 +1,24 @@
+package core
+
+import (
+	"text/template"
+)
+
+// executionSet is the template set a template executes in: its own parse trees and those of every template it
+// transitively includes. LoadDependencies builds a new set rather than adding to the template's own, and a set
+// is never modified once built, so it can be executed and walked from many goroutines at once.
+type executionSet struct {
+	tmpl *template.Template
+	// deps are the registry paths of every template included, sorted
+	deps []string
+	// sources maps the path of each dependency to its content, for locating errors in them
+	sources map[string]string
+}
+
+// templates returns the set the template executes in, or its own template set until LoadDependencies has run
+func (t *Template) templates() *template.Template {
+	if set := t.set.Load(); set != nil {
+		return set.tmpl
+	}
+	return &t.Tmpl
+}
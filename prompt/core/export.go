@@ -0,0 +1,191 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// Formats a template can be exported in
+const (
+	// ExportNative copies the templates unchanged
+	ExportNative = "rprompt"
+	// ExportLangChain writes a JSON document for LangChain's PromptTemplate, or for
+	// ChatPromptTemplate.from_messages when the template has roles, using jinja2 templating
+	ExportLangChain = "langchain"
+	// ExportPromptfoo writes a promptfoo prompt file: a JSON chat array when the template has roles, plain
+	// text otherwise, using Nunjucks templating
+	ExportPromptfoo = "promptfoo"
+	// ExportJinja writes the template as a single Jinja2 file
+	ExportJinja = "jinja"
+)
+
+// ExportResult describes the files written by an export
+type ExportResult struct {
+	Templates []string `json:"templates"`
+	Configs   []string `json:"configs"`
+	// Unsupported lists template constructs that couldn't be converted to the export format
+	Unsupported []string `json:"unsupported,omitempty"`
+}
+
+// ExportFiles returns the files an export of a template writes, keyed by their paths relative to the
+// registry root: the template, its full transitive dependency set and the matching configs. Configs listed in
+// configPaths are always included; in addition, any config sharing a stem with a template in the closure
+// (main.tmpl -> main.json) is picked up if it exists in the registry. Formats other than ExportNative replace
+// the template closure with a single converted file, see Template.ConvertToJinja.
+func (s *PromptSystem) ExportFiles(ctx context.Context, format, templatePath string, configPaths []string) (map[string][]byte, *ExportResult, error) {
+	files, result, err := s.collectExport(ctx, templatePath, configPaths)
+	if err != nil {
+		return nil, nil, err
+	}
+	if format != ExportNative {
+		if err := s.convertExport(ctx, format, templatePath, files, result); err != nil {
+			return nil, nil, err
+		}
+	}
+	return files, result, nil
+}
+
+// collectExport resolves the template closure and returns the contents of every file to export keyed by
+// its path relative to the registry root
+func (s *PromptSystem) collectExport(ctx context.Context, templatePath string, configPaths []string) (map[string][]byte, *ExportResult, error) {
+	template, err := s.find(ctx, templatePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("err finding template: %w", err)
+	}
+	if err := template.LoadDependencies(ctx); err != nil {
+		return nil, nil, fmt.Errorf("err loading dependencies: %w", err)
+	}
+
+	files := make(map[string][]byte)
+	result := &ExportResult{}
+
+	templatePaths := append([]string{templatePath}, template.Dependencies()...)
+	for _, path := range templatePaths {
+		t := template
+		if path != templatePath {
+			if t, err = s.Registry.Find(ctx, path); err != nil {
+				return nil, nil, fmt.Errorf("err finding template %s: %w", path, err)
+			}
+		}
+		files[path] = []byte(t.OriginalContent)
+		result.Templates = append(result.Templates, path)
+	}
+
+	addConfig := func(path string, required bool) error {
+		if _, ok := files[path]; ok {
+			return nil
+		}
+		data, err := s.exportConfig(ctx, path)
+		if err != nil {
+			if !required && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return fmt.Errorf("err loading config %s: %w", path, err)
+		}
+		files[path] = data
+		result.Configs = append(result.Configs, path)
+		return nil
+	}
+
+	for _, path := range configPaths {
+		if err := addConfig(path, true); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, path := range templatePaths {
+		if err := addConfig(strings.TrimSuffix(path, ".tmpl")+".json", false); err != nil {
+			return nil, nil, err
+		}
+	}
+	sort.Strings(result.Configs)
+	return files, result, nil
+}
+
+// exportConfig returns the config at path as the registry stores it, or its values encoded as JSON when the
+// registry doesn't keep the original, see RawConfigRegistry
+func (s *PromptSystem) exportConfig(ctx context.Context, path string) ([]byte, error) {
+	raw, ok := s.Registry.(RawConfigRegistry)
+	if !ok {
+		cfg, err := s.Registry.LoadConfig(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.MarshalIndent(cfg.Config, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal config %s: %w", path, err)
+		}
+		return data, nil
+	}
+	data, err := raw.ReadConfig(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	// Checked like LoadConfig would, so a broken config fails the export instead of being copied into it
+	if _, err := CfgFromJSONString(string(data), path); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// convertExport replaces the exported templates with the template converted to format
+func (s *PromptSystem) convertExport(ctx context.Context, format, templatePath string, files map[string][]byte, result *ExportResult) error {
+	template, err := s.find(ctx, templatePath)
+	if err != nil {
+		return fmt.Errorf("err finding template: %w", err)
+	}
+	prompt, err := template.ConvertToJinja(ctx)
+	if err != nil {
+		return fmt.Errorf("err converting template: %w", err)
+	}
+
+	stem := strings.TrimSuffix(templatePath, ".tmpl")
+	var path string
+	var content []byte
+	switch format {
+	case ExportJinja:
+		path, content = stem+".j2", []byte(prompt.Template)
+	case ExportLangChain:
+		doc := map[string]any{
+			"input_variables": prompt.Variables,
+			"template_format": "jinja2",
+		}
+		if len(prompt.Messages) > 0 {
+			messages := make([][]string, 0, len(prompt.Messages))
+			for _, message := range prompt.Messages {
+				messages = append(messages, []string{message.Role, message.Content})
+			}
+			doc["messages"] = messages
+		} else {
+			doc["_type"] = "prompt"
+			doc["template"] = prompt.Template
+		}
+		path = stem + ".langchain.json"
+		if content, err = json.MarshalIndent(doc, "", "  "); err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", path, err)
+		}
+	case ExportPromptfoo:
+		if len(prompt.Messages) == 0 {
+			path, content = stem+".promptfoo.txt", []byte(prompt.Template)
+			break
+		}
+		path = stem + ".promptfoo.json"
+		if content, err = json.MarshalIndent(prompt.Messages, "", "  "); err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("unknown export format %q, expected one of: %s, %s, %s, %s", format, ExportNative, ExportLangChain, ExportPromptfoo, ExportJinja)
+	}
+
+	for _, t := range result.Templates {
+		delete(files, t)
+	}
+	files[path] = content
+	result.Templates = []string{path}
+	result.Unsupported = prompt.Unsupported
+	return nil
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultTemplateExtension is the extension of template files in registries that don't accept others
+const DefaultTemplateExtension = ".tmpl"
+
+// ExtensionRegistry is implemented by registries whose template files may have other extensions than
+// DefaultTemplateExtension, such as .prompt or .md.tmpl
+type ExtensionRegistry interface {
+	// TemplateExtensions returns the accepted extensions in the order an include without one looks them up,
+	// or nil for DefaultTemplateExtension alone
+	TemplateExtensions() []string
+}
+
+// RegistryExtensions returns the extensions of the template files in r
+func RegistryExtensions(r PromptRegistry) []string {
+	if e, ok := r.(ExtensionRegistry); ok {
+		if exts := e.TemplateExtensions(); len(exts) > 0 {
+			return exts
+		}
+	}
+	return []string{DefaultTemplateExtension}
+}
+
+// ParseExtensions parses a comma separated list of template extensions such as ".tmpl,.prompt,.md.tmpl",
+// adding the leading dot where it is missing
+func ParseExtensions(s string) ([]string, error) {
+	var exts []string
+	for _, ext := range strings.Split(s, ",") {
+		ext = strings.TrimSpace(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if ext == "." || strings.ContainsAny(ext, `/\ `) {
+			return nil, fmt.Errorf("invalid template extension %q in %q", ext, s)
+		}
+		exts = append(exts, ext)
+	}
+	return exts, nil
+}
+
+// TemplateExtension returns the accepted extension path ends in, the longest when several do, so main.md.tmpl
+// has .md.tmpl rather than .tmpl when both are accepted
+func TemplateExtension(path string, exts []string) (string, bool) {
+	found := ""
+	for _, ext := range exts {
+		if strings.HasSuffix(path, ext) && len(path) > len(ext) && len(ext) > len(found) {
+			found = ext
+		}
+	}
+	return found, found != ""
+}
+
+// CheckTemplateExtension returns an error if path doesn't end in one of the accepted extensions
+func CheckTemplateExtension(path string, exts []string) error {
+	if _, ok := TemplateExtension(path, exts); ok {
+		return nil
+	}
+	if len(exts) == 1 {
+		return fmt.Errorf("template file must have %s extension: %s", exts[0], path)
+	}
+	return fmt.Errorf("template file must have one of the extensions %s: %s", strings.Join(exts, ", "), path)
+}
+
+// TrimTemplateExtension returns path without its template extension
+func TrimTemplateExtension(path string, exts []string) string {
+	ext, _ := TemplateExtension(path, exts)
+	return strings.TrimSuffix(path, ext)
+}
+
+// DependencyCandidates returns the registry paths a name used in a template action may refer to, in the order
+// they are looked up: the name itself when it has an accepted extension, or else the name with each one added
+func DependencyCandidates(name string, exts []string) []string {
+	if _, ok := TemplateExtension(name, exts); ok {
+		return []string{name}
+	}
+	candidates := make([]string, len(exts))
+	for i, ext := range exts {
+		candidates[i] = name + ext
+	}
+	return candidates
+}
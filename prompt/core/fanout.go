@@ -0,0 +1,97 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/notzree/rprompt/v2/utils"
+)
+
+// FanoutResult is the build of a template for one element of a fanout's array
+type FanoutResult struct {
+	// Index is the element's position in the array
+	Index int `json:"index"`
+	// Data is the config the element was built with: the config without the array, the element layered over it
+	Data   map[string]any `json:"-"`
+	Result *BuildResult   `json:"result"`
+}
+
+// Fanout builds a template once per element of the array at each, a dotted path into the config such as
+// customers or .customers, for generating personalized prompts in bulk. Each build sees the config without the
+// array with the element's keys layered over it, so templates read [[.name]] for the element's name and still
+// see the values every element shares. Elements must be objects. Results are in the array's order.
+func (s *PromptSystem) Fanout(ctx context.Context, templatePath, configPath, each string, opts ...BuildOption) ([]FanoutResult, error) {
+	config, err := s.loadConfig(ctx, configPath, newBuildOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	shared, elements, err := fanoutElements(config.Config, each)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]FanoutResult, 0, len(elements))
+	for i, element := range elements {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		data := utils.DeepMerge(shared, element)
+		result, err := s.BuildWithResult(ctx, templatePath, "", append(opts[:len(opts):len(opts)], WithData(data))...)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		results = append(results, FanoutResult{Index: i, Data: data, Result: result})
+	}
+	return results, nil
+}
+
+// fanoutElements splits a config into the values every element shares and the elements of the array at each
+func fanoutElements(config map[string]any, each string) (map[string]any, []map[string]any, error) {
+	path := strings.TrimPrefix(each, ".")
+	if path == "" {
+		return nil, nil, fmt.Errorf("fanout needs the path of an array in the config, such as .customers")
+	}
+	value, ok := utils.GetPath(config, path)
+	if !ok {
+		return nil, nil, fmt.Errorf("config has no %s to fan out over", path)
+	}
+	array, ok := value.([]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is a %T, not an array", path, value)
+	}
+	elements := make([]map[string]any, 0, len(array))
+	for i, item := range array {
+		element, ok := item.(map[string]any)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s.%d is a %T, not an object", path, i, item)
+		}
+		elements = append(elements, element)
+	}
+	return utils.WithoutPath(config, path), elements, nil
+}
+
+// FanoutOutputPaths returns the file each fanout result is written to. pattern is a text/template with the
+// default {{ }} delimiters executed with the element's config, such as out/{{.id}}.txt, and may contain the
+// placeholders ExpandOutputPath fills. Two elements can't share a file.
+func FanoutOutputPaths(pattern, templatePath string, results []FanoutResult) ([]string, error) {
+	tmpl, err := template.New("output").Option("missingkey=error").Parse(ExpandOutputPath(pattern, templatePath))
+	if err != nil {
+		return nil, fmt.Errorf("invalid output %q: %w", pattern, err)
+	}
+	paths := make([]string, 0, len(results))
+	seen := make(map[string]int, len(results))
+	for _, result := range results {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, result.Data); err != nil {
+			return nil, fmt.Errorf("element %d: invalid output path: %w", result.Index, err)
+		}
+		output := b.String()
+		if other, ok := seen[output]; ok {
+			return nil, fmt.Errorf("elements %d and %d would both be written to %s, add a placeholder such as {{.id}} to the output", other, result.Index, output)
+		}
+		seen[output] = result.Index
+		paths = append(paths, output)
+	}
+	return paths, nil
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+// FlagsProvider decides which feature flags are on, so a change to a prompt can be rolled out gradually behind
+// a flag with [[if flag "new-tone"]]...[[else]]...[[end]]. Providers are set on PromptSystem.Flags; any flag
+// service can be adapted with FlagsProviderFunc.
+type FlagsProvider interface {
+	// Enabled reports whether the named flag is on. Flags the provider doesn't know are off.
+	Enabled(ctx context.Context, name string) (bool, error)
+}
+
+// FlagsProviderFunc adapts a function to a FlagsProvider
+type FlagsProviderFunc func(ctx context.Context, name string) (bool, error)
+
+// Enabled calls f
+func (f FlagsProviderFunc) Enabled(ctx context.Context, name string) (bool, error) {
+	return f(ctx, name)
+}
+
+// flag is the template function behind [[if flag "new-tone"]]. Builds of a system with Flags replace it with
+// flagFunc, so without a provider every flag is off.
+func flag(name string) bool {
+	return false
+}
+
+// flagFunc returns the flag function of an execution, asking provider while ctx is live
+func flagFunc(ctx context.Context, provider FlagsProvider) func(string) (bool, error) {
+	return func(name string) (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		on, err := provider.Enabled(ctx, name)
+		if err != nil {
+			return false, fmt.Errorf("failed to evaluate flag %q: %w", name, err)
+		}
+		return on, nil
+	}
+}
+
+// StaticFlags are flags set once, such as those in the settings' flags, on when true
+type StaticFlags map[string]bool
+
+// Enabled reports whether the flag is in f and true
+func (f StaticFlags) Enabled(_ context.Context, name string) (bool, error) {
+	return f[name], nil
+}
@@ -0,0 +1,204 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats a build result can be written in
+const (
+	// FormatText is the rendered prompt as plain text
+	FormatText = "text"
+	// FormatMessagesJSON is a JSON array of {role, content} messages, as chat completion APIs expect
+	FormatMessagesJSON = "messages-json"
+	// FormatAnthropic is an Anthropic Messages API request body, see AnthropicRequest
+	FormatAnthropic = "anthropic"
+	// FormatJSON is a JSON Envelope holding the prompt, its sections and its build metadata
+	FormatJSON = "json"
+	// FormatYAML is the Envelope as a YAML document, which keeps multi-line sections readable
+	FormatYAML = "yaml"
+	// FormatMarkdown is a markdown document with a heading per [[role]] section
+	FormatMarkdown = "markdown"
+)
+
+// formats lists every known output format
+var formats = []string{FormatText, FormatMessagesJSON, FormatAnthropic, FormatJSON, FormatYAML, FormatMarkdown}
+
+// CheckFormat returns an error if format isn't a known output format
+func CheckFormat(format string) error {
+	for _, known := range formats {
+		if format == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown format %q, expected one of: %s", format, strings.Join(formats, ", "))
+}
+
+// FormatNeedsMessages reports whether format is built from the result's messages
+func FormatNeedsMessages(format string) bool {
+	return format == FormatMessagesJSON || format == FormatAnthropic || format == FormatMarkdown
+}
+
+// FormatNeedsSections reports whether format is built from the result's sections
+func FormatNeedsSections(format string) bool {
+	return format == FormatJSON || format == FormatYAML
+}
+
+// WithSections fills BuildResult.Sections with each of the template's defines and blocks rendered on their
+// own, so pipelines can pick out the parts of a multi-section template without parsing the prompt
+func WithSections() BuildOption {
+	return func(o *buildOptions) {
+		o.sections = true
+	}
+}
+
+// buildSections renders each of the template's sections with cfg, post-processing them like the prompt
+func buildSections(ctx context.Context, t *Template, cfg *Config, steps []postStep) (map[string]string, error) {
+	names := t.Sections()
+	if len(names) == 0 {
+		return nil, nil
+	}
+	sections := make(map[string]string, len(names))
+	for _, name := range names {
+		output, err := t.BuildSection(ctx, name, *cfg)
+		if err != nil {
+			return nil, fmt.Errorf("err building section %q: %w", name, err)
+		}
+		sections[name] = postProcess(stripMarkers(output), steps)
+	}
+	return sections, nil
+}
+
+// Envelope is a prompt wrapped with its build metadata, as FormatJSON and FormatYAML write it
+type Envelope struct {
+	Prompt string `json:"prompt" yaml:"prompt"`
+	// Sections are the template's defines and blocks rendered on their own, by name
+	Sections map[string]string `json:"sections,omitempty" yaml:"sections,omitempty"`
+	Meta     EnvelopeMeta      `json:"meta" yaml:"meta"`
+}
+
+// EnvelopeMeta describes how an enveloped prompt was built
+type EnvelopeMeta struct {
+	Template     string   `json:"template,omitempty" yaml:"template,omitempty"`
+	Tokens       int      `json:"tokens" yaml:"tokens"`
+	Variables    []string `json:"variables,omitempty" yaml:"variables,omitempty"`
+	Dependencies []string `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
+	Hash         string   `json:"hash,omitempty" yaml:"hash,omitempty"`
+	// TrimmedSections is how many [[trimmable]] sections were shortened or dropped to fit the token budget
+	TrimmedSections int `json:"trimmed_sections,omitempty" yaml:"trimmed_sections,omitempty"`
+}
+
+// NewEnvelope wraps a build result's output and sections with its metadata
+func NewEnvelope(result *BuildResult) *Envelope {
+	return &Envelope{
+		Prompt:   result.Output,
+		Sections: result.Sections,
+		Meta: EnvelopeMeta{
+			Template:        result.Template,
+			Tokens:          result.Tokens,
+			Variables:       result.Variables,
+			Dependencies:    result.Dependencies,
+			Hash:            result.Hash,
+			TrimmedSections: result.TrimmedSections,
+		},
+	}
+}
+
+// markdownMessages writes a heading per message followed by its content
+func markdownMessages(messages []Message) string {
+	var b strings.Builder
+	for i, message := range messages {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		role := message.Role
+		if role != "" {
+			role = strings.ToUpper(role[:1]) + role[1:]
+		}
+		fmt.Fprintf(&b, "## %s\n\n%s", role, strings.TrimSpace(message.Content))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// FormatContentType returns the media type of output in format
+func FormatContentType(format string) string {
+	switch format {
+	case FormatYAML:
+		return "application/yaml"
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case FormatText, "":
+		return "text/plain; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+// FormatResult renders a build result in the given format. The messages-json, anthropic and markdown formats
+// need the result to have been built WithMessages, and json and yaml include sections when built WithSections.
+func FormatResult(format string, result *BuildResult) (string, error) {
+	switch format {
+	case FormatText, "":
+		return result.Output, nil
+	case FormatMessagesJSON:
+		data, err := json.MarshalIndent(result.Messages, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("err encoding messages: %w", err)
+		}
+		return string(data), nil
+	case FormatAnthropic:
+		data, err := json.MarshalIndent(NewAnthropicRequest(result.Messages), "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("err encoding messages: %w", err)
+		}
+		return string(data), nil
+	case FormatJSON:
+		data, err := json.MarshalIndent(NewEnvelope(result), "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("err encoding prompt: %w", err)
+		}
+		return string(data), nil
+	case FormatYAML:
+		data, err := yaml.Marshal(NewEnvelope(result))
+		if err != nil {
+			return "", fmt.Errorf("err encoding prompt: %w", err)
+		}
+		return string(data), nil
+	case FormatMarkdown:
+		return markdownMessages(result.Messages), nil
+	default:
+		return "", CheckFormat(format)
+	}
+}
+
+// AnthropicRequest is the prompt part of an Anthropic Messages API request
+type AnthropicRequest struct {
+	System   string    `json:"system,omitempty"`
+	Messages []Message `json:"messages"`
+}
+
+// NewAnthropicRequest adapts chat messages to the Anthropic Messages API, which takes the system prompt
+// separately and expects user and assistant turns to alternate. System messages are joined into the system
+// prompt and consecutive messages from the same role are merged.
+func NewAnthropicRequest(messages []Message) *AnthropicRequest {
+	request := &AnthropicRequest{Messages: []Message{}}
+	var system []string
+	for _, message := range messages {
+		if message.Role == RoleSystem {
+			system = append(system, message.Content)
+			continue
+		}
+		if last := len(request.Messages) - 1; last >= 0 && request.Messages[last].Role == message.Role {
+			request.Messages[last].Content += "\n\n" + message.Content
+			continue
+		}
+		request.Messages = append(request.Messages, message)
+	}
+	request.System = strings.Join(system, "\n\n")
+	return request
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FrontMatterDelim opens and closes the YAML front matter at the start of a template
+const FrontMatterDelim = "---"
+
+// FrontMatter is metadata declared in YAML between --- lines at the very start of a template:
+//
+//	---
+//	response_schema:
+//	  type: object
+//	  properties:
+//	    answer: {type: string}
+//	  required: [answer]
+//	---
+//	[[role "user"]]...
+//
+// Front matter is not part of the template's output.
+type FrontMatter struct {
+	// ID is a stable name callers can find the template by instead of its path, as id:<ID>, so the file can be
+	// moved without breaking them. Ids must be unique within a registry.
+	ID string `yaml:"id" json:"id,omitempty"`
+	// ResponseSchema is the JSON schema a reply to the prompt should match, see WithResponseFormat and
+	// ValidateResponse
+	ResponseSchema map[string]any `yaml:"response_schema" json:"response_schema,omitempty"`
+	// Deprecated maps dotted config paths that should no longer be set to a note on what to use instead.
+	// Builds warn when a config sets one.
+	Deprecated map[string]string `yaml:"deprecated" json:"deprecated,omitempty"`
+	// Deprecation marks the whole template as deprecated. Building it, or any template that includes it, warns.
+	Deprecation *TemplateDeprecation `yaml:"deprecation" json:"deprecation,omitempty"`
+	// Draft marks the template as not yet reviewed. Builds refuse it, and any template that includes it,
+	// unless WithAllowDraft is given, until it is published with LocalPromptRegistry.Publish.
+	Draft bool `yaml:"draft" json:"draft,omitempty"`
+	// Owners are the people or teams accountable for the template, overriding the registry's OwnersFile
+	Owners []string `yaml:"owners" json:"owners,omitempty"`
+	// PostProcess lists the post-processors applied to the template's output, before the system's, see
+	// PostDedent
+	PostProcess []string `yaml:"post_process" json:"post_process,omitempty"`
+	// Untrusted lists the dotted config paths holding user-controlled content, which 'rprompt audit' checks are
+	// delimited and kept out of system sections. Variables named like user input, such as question, are
+	// untrusted unless listed in Trusted.
+	Untrusted []string `yaml:"untrusted" json:"untrusted,omitempty"`
+	Trusted   []string `yaml:"trusted" json:"trusted,omitempty"`
+}
+
+// TemplateDeprecation describes why a template is deprecated and what replaces it:
+//
+//	---
+//	deprecation:
+//	  replacement: greeting_v2.tmpl
+//	  note: greeting_v2 supports role sections
+//	---
+type TemplateDeprecation struct {
+	// Replacement is the registry path of the template to use instead
+	Replacement string `yaml:"replacement" json:"replacement,omitempty"`
+	Note        string `yaml:"note" json:"note,omitempty"`
+}
+
+// Message describes the deprecation of the template at path
+func (d *TemplateDeprecation) Message(path string) string {
+	msg := path + " is deprecated"
+	if d.Replacement != "" {
+		msg += ", use " + d.Replacement + " instead"
+	}
+	if d.Note != "" {
+		msg += ": " + d.Note
+	}
+	return msg
+}
+
+// SplitFrontMatter separates a template's front matter from its body. The front matter is empty when the
+// content doesn't start with a --- line or the block is never closed.
+func SplitFrontMatter(content string) (frontMatter, body string) {
+	first, rest, ok := strings.Cut(content, "\n")
+	if !ok || strings.TrimRight(first, "\r") != FrontMatterDelim {
+		return "", content
+	}
+	for offset := 0; offset < len(rest); {
+		line, _, _ := strings.Cut(rest[offset:], "\n")
+		end := offset + len(line) + 1
+		if strings.TrimRight(line, "\r") == FrontMatterDelim {
+			return rest[:offset], rest[min(end, len(rest)):]
+		}
+		offset = end
+	}
+	return "", content
+}
+
+// Body is the template's content without its front matter
+func (t *Template) Body() string {
+	_, body := SplitFrontMatter(t.OriginalContent)
+	return body
+}
+
+// FrontMatter parses the template's front matter. A template without front matter has an empty one.
+func (t *Template) FrontMatter() (*FrontMatter, error) {
+	return parseFrontMatter(t.Path, t.OriginalContent)
+}
+
+// parseFrontMatter parses the front matter of the template at path with the given content
+func parseFrontMatter(path, content string) (*FrontMatter, error) {
+	raw, _ := SplitFrontMatter(content)
+	fm := &FrontMatter{}
+	if err := yaml.Unmarshal([]byte(raw), fm); err != nil {
+		return nil, fmt.Errorf("invalid front matter in %s: %w", path, err)
+	}
+	return fm, nil
+}
@@ -0,0 +1,28 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name, content, frontMatter, body string
+	}{
+		{"none", "Hello", "", "Hello"},
+		{"yaml", "---\nkey: value\n---\nHello", "key: value\n", "Hello"},
+		{"crlf", "---\r\nkey: value\r\n---\r\nHello", "key: value\r\n", "Hello"},
+		{"empty", "---\n---\nHello", "", "Hello"},
+		{"only front matter", "---\nkey: value\n---", "key: value\n", ""},
+		{"unclosed", "---\nkey: value\nHello", "", "---\nkey: value\nHello"},
+		{"not at start", "Hi\n---\nkey: value\n---\n", "", "Hi\n---\nkey: value\n---\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frontMatter, body := SplitFrontMatter(tt.content)
+			assert.Equal(t, tt.frontMatter, frontMatter)
+			assert.Equal(t, tt.body, body)
+		})
+	}
+}
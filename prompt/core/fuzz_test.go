@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"text/template/parse"
+)
+
+// fuzzSeeds are templates exercising every construct the walker handles
+var fuzzSeeds = []string{
+	`Hello [[.name]]`,
+	`[[if .a.b]][[.c]][[else if .d]][[.e.f]][[else]][[$.g]][[end]]`,
+	`[[range $i, $v := .items]][[$v.name]][[.x]][[else]][[.empty]][[end]]`,
+	`[[with .user]][[.name]] [[.email]][[else]][[.anon]][[end]]`,
+	`[[define "part"]][[.p]][[end]][[template "part" .q]][[block "b" .]][[.r]][[end]]`,
+	`[[$x := .a]][[$x.b]][[.a.b | printf "%s" .c]][[len .list]][[index .m "k"]]`,
+	`[[range .a.b.c]][[end]][[with .x.y]][[.z]][[end]][[if and .p (not .q)]][[end]]`,
+	`[[role "system"]]sys[[role "user"]][[trimmable]][[.t]][[endtrimmable]][[document "d.md"]]`,
+}
+
+// FuzzWalk checks that the walkers generating configs, schemas and lazy dependencies never panic, whatever
+// the template
+func FuzzWalk(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	registry := NewMemoryRegistry()
+	f.Fuzz(func(t *testing.T, content string) {
+		template := NewTemplate("fuzz.tmpl", content, registry)
+		if err := template.Compile(); err != nil {
+			return
+		}
+		data := template.Walk(template.Tmpl.Tree.Root)
+		template.reachableDependencies(data)
+		template.Schema(context.Background())
+	})
+}
+
+// FuzzExtractVarsFromPipe checks that extracting the variables of every pipeline never panics
+func FuzzExtractVarsFromPipe(f *testing.F) {
+	for _, seed := range []string{".a", ".a.b.c", "$x.y", "printf \"%s\" .a .b", ".a | len", "$", "."} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, pipe string) {
+		tmpl, err := newTemplateSet("fuzz", nil).Parse("[[" + pipe + "]]")
+		if err != nil {
+			return
+		}
+		for _, node := range tmpl.Tree.Root.Nodes {
+			if action, ok := node.(*parse.ActionNode); ok {
+				ExtractVarsFromPipe(action.Pipe)
+			}
+		}
+	})
+}
+
+// FuzzBuild checks that building with the config the template generates never panics
+func FuzzBuild(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	// Includes resolve against an empty registry
+	registry := NewMemoryRegistry()
+	f.Fuzz(func(t *testing.T, content string) {
+		template := NewTemplate("fuzz.tmpl", content, registry)
+		cfg, err := template.GenerateConfig(context.Background(), "")
+		if err != nil {
+			return
+		}
+		template.Build(context.Background(), *cfg)
+	})
+}
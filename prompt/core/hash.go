@@ -0,0 +1,92 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// HashFooterFormat is the comment appended to output built with WithHashFooter
+const HashFooterFormat = "\n<!-- rprompt:%s -->\n"
+
+// Hash returns a stable hex-encoded sha256 of a template, every template it includes and the config it
+// would be built with. The same inputs always give the same hash, so it identifies exactly which prompt
+// version handled a request. Built WithLocale, the locale's variants are hashed.
+func (s *PromptSystem) Hash(ctx context.Context, templatePath, configPath string, opts ...BuildOption) (string, error) {
+	o := newBuildOptions(opts)
+	template, err := s.findLocalized(ctx, templatePath, o)
+	if err != nil {
+		return "", fmt.Errorf("err finding template: %w", err)
+	}
+	config, err := s.loadConfig(ctx, configPath, o)
+	if err != nil {
+		return "", err
+	}
+	if err := template.LoadDependencies(ctx); err != nil {
+		return "", err
+	}
+	return s.hash(ctx, template, config)
+}
+
+// hash digests a template whose dependencies are loaded along with a config. Each part is written with its
+// length so different splits of the same bytes can't collide.
+func (s *PromptSystem) hash(ctx context.Context, template *Template, config *Config) (string, error) {
+	h := sha256.New()
+	if err := s.writeTemplates(ctx, h, template); err != nil {
+		return "", err
+	}
+	if err := writeConfig(h, config); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// templateHash digests a template whose dependencies are loaded, without any config
+func (s *PromptSystem) templateHash(ctx context.Context, template *Template) (string, error) {
+	h := sha256.New()
+	if err := s.writeTemplates(ctx, h, template); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// configHash digests a config on its own
+func configHash(config *Config) (string, error) {
+	h := sha256.New()
+	if err := writeConfig(h, config); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WriteHashPart writes one part of a digest, prefixed with its kind, name and length
+func WriteHashPart(w io.Writer, kind, name, content string) {
+	fmt.Fprintf(w, "%s %s %d\n%s\n", kind, name, len(content), content)
+}
+
+// writeTemplates writes a template and every template it includes to a digest
+func (s *PromptSystem) writeTemplates(ctx context.Context, w io.Writer, template *Template) error {
+	WriteHashPart(w, "template", template.Path, template.OriginalContent)
+	for _, dep := range template.Dependencies() {
+		depTemplate, err := s.Registry.Find(ctx, dep)
+		if err != nil {
+			return fmt.Errorf("err finding template %s: %w", dep, err)
+		}
+		WriteHashPart(w, "template", dep, depTemplate.OriginalContent)
+	}
+	return nil
+}
+
+// writeConfig writes a config to a digest
+func writeConfig(w io.Writer, config *Config) error {
+	// encoding/json sorts map keys, so the encoding is stable
+	data, err := json.Marshal(config.Config)
+	if err != nil {
+		return fmt.Errorf("err encoding config: %w", err)
+	}
+	WriteHashPart(w, "config", "", string(data))
+	return nil
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// IDPrefix starts template references that name a template by the stable id in its front matter instead of by
+// its path, such as id:onboarding-email or id:onboarding-email@v3, so files can move without breaking callers
+const IDPrefix = "id:"
+
+// TemplateIDs maps each id declared in the front matter of the given templates, keyed by path, to the paths
+// declaring it. Templates whose front matter can't be parsed are skipped.
+func TemplateIDs(contents map[string]string) map[string][]string {
+	ids := make(map[string][]string)
+	for path, content := range contents {
+		if fm, err := parseFrontMatter(path, content); err == nil && fm.ID != "" {
+			ids[fm.ID] = append(ids[fm.ID], path)
+		}
+	}
+	return ids
+}
+
+// PathForID returns the path of the template a reference such as onboarding-email@v3, without IDPrefix,
+// names, keeping any version. It fails when no template or more than one declares the id.
+func PathForID(ref string, ids map[string][]string) (string, error) {
+	id, version, pinned := strings.Cut(ref, "@")
+	paths := ids[id]
+	switch len(paths) {
+	case 0:
+		return "", fmt.Errorf("no template has id %q: %w", id, fs.ErrNotExist)
+	case 1:
+	default:
+		sorted := append([]string{}, paths...)
+		sort.Strings(sorted)
+		return "", fmt.Errorf("id %q is declared by more than one template: %s", id, strings.Join(sorted, ", "))
+	}
+	if pinned {
+		return paths[0] + "@" + version, nil
+	}
+	return paths[0], nil
+}
@@ -0,0 +1,30 @@
+package core
+
+import (
+	"context"
+)
+
+// BuildRecord describes the inputs of an output. An output whose record is unchanged renders the same, unless
+// its template reads the time, retrieves documents or checks flags.
+type BuildRecord struct {
+	Template string `json:"template"`
+	Config   string `json:"config"`
+	// Hash identifies the template closure and config, see PromptSystem.Hash
+	Hash string `json:"hash"`
+	// Options identifies everything else that shapes the output, such as its format and the settings
+	Options string `json:"options,omitempty"`
+	// OutputHash is the hex-encoded sha256 of the output as written, so outputs edited or deleted since are
+	// rebuilt
+	OutputHash string `json:"output_hash,omitempty"`
+}
+
+// BuildRecord returns the record of building a template with a config, for telling whether an output built
+// from them is up to date, such as with the prompt package's BuildState. options identifies whatever else
+// shapes the output.
+func (s *PromptSystem) BuildRecord(ctx context.Context, templatePath, configPath, options string, opts ...BuildOption) (BuildRecord, error) {
+	hash, err := s.Hash(ctx, templatePath, configPath, opts...)
+	if err != nil {
+		return BuildRecord{}, err
+	}
+	return BuildRecord{Template: templatePath, Config: configPath, Hash: hash, Options: options}, nil
+}
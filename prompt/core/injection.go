@@ -0,0 +1,352 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// Rules ScanInjectionRisks reports
+const (
+	// RuleSystemInterpolation flags user-controlled variables written into a system section, where their content
+	// carries the authority of the system prompt
+	RuleSystemInterpolation = "system-interpolation"
+	// RuleUndelimitedInput flags user-controlled variables written without delimiters around them, such as
+	// <input>...</input>, a code fence or quotes, so the model can't tell where they end
+	RuleUndelimitedInput = "undelimited-input"
+	// RuleInstructionOverride flags text telling the model to ignore its previous instructions, which in an
+	// included template overrides the prompts that include it
+	RuleInstructionOverride = "instruction-override"
+)
+
+// Severities of injection findings
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+)
+
+var (
+	// untrustedNamePattern matches the last element of variable paths named like user input, such as
+	// .question or .user_message
+	untrustedNamePattern = regexp.MustCompile(`(?i)^user|(?:^|_)(?:input|query|question|message|request|comment|feedback|reply)s?$`)
+	overridePattern      = regexp.MustCompile(`(?i)\b(?:ignore|disregard|forget)\s+(?:all\s+|any\s+)?(?:of\s+)?(?:the\s+|your\s+)?(?:previous|prior|above|earlier|preceding)\s+(?:instructions|prompts|rules|messages|directions)`)
+	// openDelimPattern and closeDelimPattern match the text just before and after a delimited variable
+	openDelimPattern  = regexp.MustCompile("(?:<[A-Za-z][^<>]*>|```[\\w-]*|[\"'])$")
+	closeDelimPattern = regexp.MustCompile("^(?:</|```|[\"'])")
+)
+
+// InjectionFinding is a pattern in a template that leaves its prompts open to prompt injection
+type InjectionFinding struct {
+	Template string `json:"template"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (f InjectionFinding) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s %s: %s", f.Template, f.Line, f.Column, f.Severity, f.Rule, f.Message)
+}
+
+// InjectionReport lists the prompt injection risks in a registry's templates
+type InjectionReport struct {
+	Findings []InjectionFinding `json:"findings"`
+	// Errors maps templates that couldn't be read to why
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// ScanInjectionRisks walks the templates at paths in the registry, usually all of them, for patterns that expose prompts to prompt
+// injection: user-controlled variables in system sections, user-controlled variables without delimiters
+// around them and text telling the model to ignore its previous instructions. Variables are user-controlled
+// when the template's front matter lists them as untrusted, or when they are named like user input and not
+// listed as trusted. Templates included from a system section are treated as part of it.
+func ScanInjectionRisks(ctx context.Context, r PromptRegistry, paths []string) (*InjectionReport, error) {
+	report := &InjectionReport{Findings: []InjectionFinding{}}
+	fail := func(path, message string) {
+		if report.Errors == nil {
+			report.Errors = make(map[string]string)
+		}
+		report.Errors[path] = message
+	}
+	var scanners []*injectionScanner
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		t, err := r.Find(ctx, path)
+		if err != nil {
+			fail(path, err.Error())
+			continue
+		}
+		fm, err := t.FrontMatter()
+		if err != nil {
+			fail(path, err.Error())
+			continue
+		}
+		if err := t.Compile(); err != nil {
+			fail(path, err.Error())
+			continue
+		}
+		scanners = append(scanners, &injectionScanner{t: t, trusted: fm.Trusted, untrusted: fm.Untrusted})
+	}
+
+	// Which templates are included, and which from a system section, is only known once their includers have
+	// been walked, so walk until that settles
+	includers := make(map[string][]string)
+	fromSystem := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, s := range scanners {
+			s.scan(fromSystem[s.t.Path])
+			for dep, role := range s.includes {
+				if role == RoleSystem && !fromSystem[dep] {
+					fromSystem[dep], changed = true, true
+				}
+			}
+		}
+	}
+	for _, s := range scanners {
+		for dep := range s.includes {
+			includers[dep] = append(includers[dep], s.t.Path)
+		}
+	}
+
+	for _, s := range scanners {
+		for _, finding := range s.findings {
+			if finding.Rule == RuleInstructionOverride {
+				if by := includers[s.t.Path]; len(by) > 0 {
+					sort.Strings(by)
+					finding.Severity = SeverityHigh
+					finding.Message += ", and overrides the instructions of " + strings.Join(by, ", ") + " which include it"
+				}
+			}
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if a.Template != b.Template {
+			return a.Template < b.Template
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return report, nil
+}
+
+// injectionScanner walks one template's parse trees, tracking the role section each node renders in
+type injectionScanner struct {
+	t         *Template
+	trusted   []string
+	untrusted []string
+	tree      *parse.Tree
+	// role is the section being walked, empty before the template's first role
+	role string
+	// inSystem treats the content before the template's first role as part of a system section
+	inSystem bool
+	// includes maps the templates included from other files to the role they are included in
+	includes map[string]string
+	findings []InjectionFinding
+}
+
+// scan walks the template again, replacing the findings of any previous walk
+func (s *injectionScanner) scan(inSystem bool) {
+	s.inSystem = inSystem
+	s.includes = make(map[string]string)
+	s.findings = nil
+	for _, tmpl := range s.t.Tmpl.Templates() {
+		if tmpl.Tree == nil {
+			continue
+		}
+		s.tree, s.role = tmpl.Tree, ""
+		s.list(tmpl.Tree.Root, "")
+	}
+}
+
+// section returns the role of the section being walked
+func (s *injectionScanner) section() string {
+	if s.role == "" && s.inSystem {
+		return RoleSystem
+	}
+	return s.role
+}
+
+func (s *injectionScanner) report(node parse.Node, line, column int, rule, severity, message string) {
+	path, nodeLine, nodeColumn := s.t.position(s.tree, node)
+	if line == 0 {
+		line, column = nodeLine, nodeColumn
+	}
+	s.findings = append(s.findings, InjectionFinding{Template: path, Line: line, Column: column, Rule: rule, Severity: severity, Message: message})
+}
+
+// list walks the nodes of a list. dot is the config path dot refers to, empty at the root.
+func (s *injectionScanner) list(list *parse.ListNode, dot string) {
+	if list == nil {
+		return
+	}
+	for i, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.TextNode:
+			s.text(n)
+		case *parse.ActionNode:
+			if name, ok := roleCall(n.Pipe); ok {
+				s.role = name
+				continue
+			}
+			if len(n.Pipe.Decl) > 0 {
+				continue
+			}
+			for _, path := range pipeFields(n.Pipe, dot) {
+				s.variable(n, path, list.Nodes, i)
+			}
+		case *parse.IfNode:
+			s.list(n.List, dot)
+			s.list(n.ElseList, dot)
+		case *parse.WithNode:
+			s.list(n.List, rangeDot(n.Pipe, dot))
+			s.list(n.ElseList, dot)
+		case *parse.RangeNode:
+			s.list(n.List, rangeDot(n.Pipe, dot))
+			s.list(n.ElseList, dot)
+		case *parse.TemplateNode:
+			if !s.t.defines[n.Name] {
+				if role := s.section(); role == RoleSystem || s.includes[n.Name] == "" {
+					s.includes[n.Name] = role
+				}
+			}
+		case *parse.ListNode:
+			s.list(n, dot)
+		}
+	}
+}
+
+// variable checks a variable an action writes into the prompt
+func (s *injectionScanner) variable(node *parse.ActionNode, path string, siblings []parse.Node, i int) {
+	if !s.isUntrusted(path) {
+		return
+	}
+	if s.section() == RoleSystem {
+		s.report(node, 0, 0, RuleSystemInterpolation, SeverityHigh, fmt.Sprintf(".%s is user-controlled and written into a system section, move it into a user message", path))
+	}
+	var before, after string
+	if i > 0 {
+		if text, ok := siblings[i-1].(*parse.TextNode); ok {
+			before = strings.TrimRight(string(text.Text), " \t\r\n")
+		}
+	}
+	if i < len(siblings)-1 {
+		if text, ok := siblings[i+1].(*parse.TextNode); ok {
+			after = strings.TrimLeft(string(text.Text), " \t\r\n")
+		}
+	}
+	if !openDelimPattern.MatchString(before) || !closeDelimPattern.MatchString(after) {
+		s.report(node, 0, 0, RuleUndelimitedInput, SeverityMedium, fmt.Sprintf(".%s is user-controlled and has no delimiters around it, wrap it in tags such as <input>...</input>", path))
+	}
+}
+
+// text checks a template's literal text for instructions overriding the prompt
+func (s *injectionScanner) text(node *parse.TextNode) {
+	_, line, column := s.t.position(s.tree, node)
+	text := string(node.Text)
+	for _, match := range overridePattern.FindAllStringIndex(text, -1) {
+		matchLine, matchColumn := line, column+match[0]
+		if newline := strings.LastIndex(text[:match[0]], "\n"); newline >= 0 {
+			matchLine += strings.Count(text[:match[0]], "\n")
+			matchColumn = match[0] - newline
+		}
+		s.report(node, matchLine, matchColumn, RuleInstructionOverride, SeverityMedium, fmt.Sprintf("%q tells the model to ignore its instructions", text[match[0]:match[1]]))
+	}
+}
+
+// isUntrusted reports whether the variable at the dotted path holds user-controlled content
+func (s *injectionScanner) isUntrusted(path string) bool {
+	within := func(paths []string) bool {
+		for _, p := range paths {
+			if path == p || strings.HasPrefix(path, p+".") {
+				return true
+			}
+		}
+		return false
+	}
+	if within(s.untrusted) {
+		return true
+	}
+	if within(s.trusted) {
+		return false
+	}
+	return untrustedNamePattern.MatchString(path[strings.LastIndex(path, ".")+1:])
+}
+
+// roleCall returns the role an action like [[role "system"]] starts
+func roleCall(pipe *parse.PipeNode) (string, bool) {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 2 {
+		return "", false
+	}
+	ident, ok := pipe.Cmds[0].Args[0].(*parse.IdentifierNode)
+	if !ok || ident.Ident != "role" {
+		return "", false
+	}
+	name, ok := pipe.Cmds[0].Args[1].(*parse.StringNode)
+	if !ok {
+		return "", false
+	}
+	return name.Text, true
+}
+
+// pipeFields returns the dotted config paths of the fields and dots a pipeline uses, resolved against dot
+func pipeFields(pipe *parse.PipeNode, dot string) []string {
+	var paths []string
+	join := func(fields []string) {
+		path := strings.Join(fields, ".")
+		if dot != "" {
+			path = dot + "." + path
+		}
+		paths = append(paths, path)
+	}
+	var visit func(node parse.Node)
+	visit = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.FieldNode:
+			join(n.Ident)
+		case *parse.VariableNode:
+			if n.Ident[0] == "$" && len(n.Ident) > 1 {
+				paths = append(paths, strings.Join(n.Ident[1:], "."))
+			}
+		case *parse.DotNode:
+			if dot != "" {
+				paths = append(paths, dot)
+			}
+		case *parse.ChainNode:
+			if _, ok := n.Node.(*parse.DotNode); ok {
+				join(n.Field)
+			} else {
+				visit(n.Node)
+			}
+		case *parse.PipeNode:
+			for _, cmd := range n.Cmds {
+				for _, arg := range cmd.Args {
+					visit(arg)
+				}
+			}
+		}
+	}
+	visit(pipe)
+	return paths
+}
+
+// rangeDot returns the config path dot refers to inside a with or range over a single field, or dot itself
+func rangeDot(pipe *parse.PipeNode, dot string) string {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return dot
+	}
+	if paths := pipeFields(pipe, dot); len(paths) == 1 {
+		return paths[0]
+	}
+	return dot
+}
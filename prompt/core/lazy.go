@@ -0,0 +1,179 @@
+package core
+
+import (
+	"reflect"
+	"text/template/parse"
+)
+
+// WithLazyDeps resolves only the dependencies a build can reach with its config, skipping templates included
+// under if, with and range branches the config rules out. It saves fetching partials a render never executes
+// from remote registries. Conditions that can't be evaluated without running the template, such as function
+// calls, count as reaching every branch.
+//
+// Builders ignore it: NewBuilder resolves dependencies once for every config the builder is built with.
+// PromptSystem.BuildTo doesn't, since its builder only builds one config.
+func WithLazyDeps() BuildOption {
+	return func(o *buildOptions) {
+		o.lazyDeps = true
+	}
+}
+
+// lazyDependency is a template included from another file that executing its includer can reach
+type lazyDependency struct {
+	name string
+	// rootData reports whether it is passed the build's whole config, so its own dependencies can be filtered
+	// against it too
+	rootData bool
+}
+
+// reachableDependencies returns the templates t includes from other files that executing it with data can
+// reach, in the order they appear. t must already be parsed.
+func (t *Template) reachableDependencies(data map[string]any) []lazyDependency {
+	w := &reachWalker{root: data, seen: make(map[string]int)}
+	w.walk(t.Tmpl.Tree.Root, data, true, true)
+	deps := []lazyDependency{}
+	for _, dep := range w.deps {
+		if !t.defines[dep.name] {
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
+
+// reachWalker walks a parse tree, following only the branches its data selects
+type reachWalker struct {
+	root map[string]any
+	deps []lazyDependency
+	// seen maps each dependency's name to its index in deps
+	seen map[string]int
+}
+
+// walk visits node with dot as the value of ".". known is false once dot can't be determined statically, and
+// atRoot reports whether dot is still the root data.
+func (w *reachWalker) walk(node parse.Node, dot any, known, atRoot bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, item := range n.Nodes {
+			w.walk(item, dot, known, atRoot)
+		}
+	case *parse.TemplateNode:
+		w.add(n.Name, atRoot && passesDot(n.Pipe))
+	case *parse.IfNode:
+		value, ok := w.eval(n.Pipe, dot, known)
+		if !ok || truthy(value) {
+			w.walk(n.List, dot, known, atRoot)
+		}
+		if !ok || !truthy(value) {
+			w.walk(n.ElseList, dot, known, atRoot)
+		}
+	case *parse.WithNode:
+		value, ok := w.eval(n.Pipe, dot, known)
+		if !ok || truthy(value) {
+			w.walk(n.List, value, ok, false)
+		}
+		if !ok || !truthy(value) {
+			w.walk(n.ElseList, dot, known, atRoot)
+		}
+	case *parse.RangeNode:
+		value, ok := w.eval(n.Pipe, dot, known)
+		// The element each iteration sets dot to isn't evaluated
+		if !ok || truthy(value) {
+			w.walk(n.List, nil, false, false)
+		}
+		if !ok || !truthy(value) {
+			w.walk(n.ElseList, dot, known, atRoot)
+		}
+	}
+}
+
+// add records a dependency. One included both with and without the root data is treated as without.
+func (w *reachWalker) add(name string, rootData bool) {
+	if i, ok := w.seen[name]; ok {
+		w.deps[i].rootData = w.deps[i].rootData && rootData
+		return
+	}
+	w.seen[name] = len(w.deps)
+	w.deps = append(w.deps, lazyDependency{name: name, rootData: rootData})
+}
+
+// eval evaluates a pipeline made of a single field, variable, dot or bool, optionally negated with not. ok is
+// false for anything else, or when the pipeline depends on a dot that isn't known.
+func (w *reachWalker) eval(pipe *parse.PipeNode, dot any, known bool) (value any, ok bool) {
+	if pipe == nil || len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 {
+		return nil, false
+	}
+	args := pipe.Cmds[0].Args
+	if len(args) == 2 {
+		if ident, isIdent := args[0].(*parse.IdentifierNode); isIdent && ident.Ident == "not" {
+			value, ok := w.evalArg(args[1], dot, known)
+			return !truthy(value), ok
+		}
+	}
+	if len(args) != 1 {
+		return nil, false
+	}
+	return w.evalArg(args[0], dot, known)
+}
+
+func (w *reachWalker) evalArg(arg parse.Node, dot any, known bool) (any, bool) {
+	switch n := arg.(type) {
+	case *parse.BoolNode:
+		return n.True, true
+	case *parse.DotNode:
+		return dot, known
+	case *parse.FieldNode:
+		if !known {
+			return nil, false
+		}
+		return lookupField(dot, n.Ident)
+	case *parse.VariableNode:
+		if len(n.Ident) == 0 || n.Ident[0] != "$" {
+			return nil, false
+		}
+		return lookupField(w.root, n.Ident[1:])
+	}
+	return nil, false
+}
+
+// lookupField follows a chain of map keys from value. A missing key gives nil, as text/template renders it
+// as no value, while anything but a map along the way can't be evaluated.
+func lookupField(value any, idents []string) (any, bool) {
+	for _, ident := range idents {
+		if value == nil {
+			return nil, true
+		}
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		value = m[ident]
+	}
+	return value, true
+}
+
+// passesDot reports whether a template action passes its whole dot, as in [[template "x" .]]
+func passesDot(pipe *parse.PipeNode) bool {
+	if pipe == nil || len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	_, ok := pipe.Cmds[0].Args[0].(*parse.DotNode)
+	return ok
+}
+
+// truthy reports whether text/template treats value as true: set, non-zero and non-empty
+func truthy(value any) bool {
+	if value == nil {
+		return false
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() > 0
+	case reflect.Pointer, reflect.Interface, reflect.Chan, reflect.Func:
+		return !v.IsNil()
+	}
+	return !v.IsZero()
+}
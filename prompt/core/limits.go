@@ -0,0 +1,46 @@
+package core
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Limits bounds the resources a single build may use, so a runaway template can't take down a service.
+// A zero value for any field means no limit.
+type Limits struct {
+	// MaxOutputBytes is the most output a build may write
+	MaxOutputBytes int64
+	// MaxIncludeDepth is how deeply templates may include other templates; a template with no includes has
+	// depth 0
+	MaxIncludeDepth int
+	// MaxRenderDuration is how long executing a template may take. It is checked as output is written, so a
+	// template that loops without writing anything is only stopped once it writes again.
+	MaxRenderDuration time.Duration
+}
+
+// limitedWriter enforces output and duration limits on a template execution. Any error it returns is
+// surfaced unchanged from template execution.
+type limitedWriter struct {
+	w io.Writer
+	// ctx is the build's context and parent the caller's, to tell a render timeout apart from cancellation
+	ctx     context.Context
+	parent  context.Context
+	limits  Limits
+	written int64
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if err := lw.ctx.Err(); err != nil {
+		if lw.parent.Err() == nil && lw.limits.MaxRenderDuration > 0 {
+			return 0, NewRenderTimeoutError(lw.limits.MaxRenderDuration)
+		}
+		return 0, err
+	}
+	if lw.limits.MaxOutputBytes > 0 && lw.written+int64(len(p)) > lw.limits.MaxOutputBytes {
+		return 0, NewOutputLimitError(lw.limits.MaxOutputBytes)
+	}
+	n, err := lw.w.Write(p)
+	lw.written += int64(n)
+	return n, err
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupLimitsSystem(t *testing.T, limits Limits) *PromptSystem {
+	registry := newTestRegistry(t, map[string]string{
+		"long.tmpl": `[[range .items]][[.]][[end]]`,
+		"a.tmpl":    `A [[template "b.tmpl" .]]`,
+		"b.tmpl":    `B [[template "c.tmpl" .]]`,
+		"c.tmpl":    `C`,
+	})
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	system.Limits = limits
+	return system
+}
+
+func TestLimits_MaxOutputBytes(t *testing.T) {
+	system := setupLimitsSystem(t, Limits{MaxOutputBytes: 5})
+	ctx := context.Background()
+
+	_, err := system.Build(ctx, "long.tmpl", "", WithValue("items", []string{"abc", "def"}))
+	var outputErr *OutputLimitError
+	require.ErrorAs(t, err, &outputErr)
+	assert.Equal(t, int64(5), outputErr.Limit)
+
+	// Streaming stops before the limit is crossed
+	var buf bytes.Buffer
+	err = system.BuildTo(ctx, &buf, "long.tmpl", "", WithValue("items", []string{"abc", "def"}))
+	assert.ErrorAs(t, err, &outputErr)
+	assert.Equal(t, "abc", buf.String())
+
+	output, err := system.Build(ctx, "long.tmpl", "", WithValue("items", []string{"abc", "de"}))
+	require.NoError(t, err)
+	assert.Equal(t, "abcde", output)
+}
+
+func TestLimits_MaxIncludeDepth(t *testing.T) {
+	system := setupLimitsSystem(t, Limits{MaxIncludeDepth: 1})
+	ctx := context.Background()
+
+	_, err := system.Build(ctx, "a.tmpl", "")
+	var depthErr *IncludeDepthError
+	require.ErrorAs(t, err, &depthErr)
+	assert.Equal(t, []string{"a.tmpl", "b.tmpl", "c.tmpl"}, depthErr.Chain)
+
+	output, err := system.Build(ctx, "b.tmpl", "")
+	require.NoError(t, err)
+	assert.Equal(t, "B C", output)
+
+	// Per-build limits override the system's
+	output, err = system.Build(ctx, "a.tmpl", "", WithLimits(Limits{MaxIncludeDepth: 2}))
+	require.NoError(t, err)
+	assert.Equal(t, "A B C", output)
+}
+
+func TestLimits_MaxRenderDuration(t *testing.T) {
+	system := setupLimitsSystem(t, Limits{MaxRenderDuration: time.Nanosecond})
+	items := make([]int, 10000)
+
+	_, err := system.Build(context.Background(), "long.tmpl", "", WithValue("items", items))
+	var timeoutErr *RenderTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, time.Nanosecond, timeoutErr.Limit)
+}
+
+func TestLimits_Cancelled(t *testing.T) {
+	system := setupLimitsSystem(t, Limits{MaxRenderDuration: time.Hour})
+	tmpl, err := system.Find(context.Background(), "long.tmpl")
+	require.NoError(t, err)
+	require.NoError(t, tmpl.LoadDependencies(context.Background()))
+
+	// Cancellation by the caller isn't reported as a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = tmpl.execute(ctx, &bytes.Buffer{}, "long.tmpl", *NewConfig(map[string]any{"items": []int{1}}, ""))
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/notzree/rprompt/v2/utils"
+)
+
+// localePattern matches the locales templates can be translated into: a lowercase ISO 639-1 language such as
+// fr, optionally followed by an uppercase region such as fr-CA or fr_CA
+var localePattern = regexp.MustCompile(`^[a-z]{2}([-_][A-Z]{2})?$`)
+
+// WithLocale builds the variant of the template for locale, such as greeting.fr.tmpl for greeting.tmpl, and
+// includes the locale's variants of its dependencies. A region's locale such as fr-CA falls back to its
+// language, then to the system's DefaultLocale, then to the unsuffixed template, so only the templates that
+// differ need translating.
+func WithLocale(locale string) BuildOption {
+	return func(o *buildOptions) {
+		o.locale = locale
+	}
+}
+
+// localizedPath returns the path of the variant of the template at path for locale
+func localizedPath(path, locale string) string {
+	return strings.TrimSuffix(path, ".tmpl") + "." + locale + ".tmpl"
+}
+
+// LocaleVariant splits the path of a locale variant such as agents/greeting.fr.tmpl into the path of the
+// template it translates and its locale. ok is false for templates that aren't variants.
+func LocaleVariant(path string) (template, locale string, ok bool) {
+	stem := strings.TrimSuffix(path, ".tmpl")
+	i := strings.LastIndex(stem, ".")
+	if i < 0 || i < strings.LastIndex(stem, "/") || !localePattern.MatchString(stem[i+1:]) {
+		return path, "", false
+	}
+	return stem[:i] + ".tmpl", stem[i+1:], true
+}
+
+// locales returns the locales whose variants a build uses, most specific first
+func (o *buildOptions) locales(defaultLocale string) ([]string, error) {
+	var locales []string
+	for _, locale := range []string{o.locale, defaultLocale} {
+		if locale == "" {
+			continue
+		}
+		if !localePattern.MatchString(locale) {
+			return nil, fmt.Errorf("invalid locale %q, expected a language such as fr or a language and region such as fr-CA", locale)
+		}
+		locales = append(locales, locale)
+		if language, _, found := strings.Cut(strings.ReplaceAll(locale, "_", "-"), "-"); found {
+			locales = append(locales, language)
+		}
+	}
+	return utils.UniqueString(locales), nil
+}
+
+// findLocalized finds the variant of the template for the build's locales, see WithLocale, once the build's
+// params are filled in, see WithParam
+func (s *PromptSystem) findLocalized(ctx context.Context, templatePath string, o *buildOptions) (*Template, error) {
+	templatePath, err := o.expandPath(templatePath)
+	if err != nil {
+		return nil, err
+	}
+	locales, err := o.locales(s.DefaultLocale)
+	if err != nil {
+		return nil, err
+	}
+	for _, locale := range locales {
+		template, err := s.find(ctx, localizedPath(templatePath, locale))
+		if err == nil {
+			template.locales = locales
+			return template, nil
+		}
+		if !errors.Is(err, ErrTemplateNotFound) {
+			return nil, err
+		}
+	}
+	template, err := s.find(ctx, templatePath)
+	if err != nil {
+		return nil, err
+	}
+	template.locales = locales
+	return template, nil
+}
@@ -0,0 +1,23 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocaleVariant(t *testing.T) {
+	for path, expected := range map[string][2]string{
+		"greeting.fr.tmpl":           {"greeting.tmpl", "fr"},
+		"agents/greeting.pt-BR.tmpl": {"agents/greeting.tmpl", "pt-BR"},
+		"greeting.en_GB.tmpl":        {"greeting.tmpl", "en_GB"},
+	} {
+		template, locale, ok := LocaleVariant(path)
+		assert.True(t, ok, path)
+		assert.Equal(t, expected, [2]string{template, locale}, path)
+	}
+	for _, path := range []string{"greeting.tmpl", "prompt.v2.tmpl", "main.old.tmpl", "v1.fr/greeting.tmpl"} {
+		_, _, ok := LocaleVariant(path)
+		assert.False(t, ok, path)
+	}
+}
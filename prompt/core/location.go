@@ -0,0 +1,95 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// templateErrorPattern matches the position text/template puts in its errors: "template: name:line: msg" for
+// parse errors and "template: name:line:col: msg" for execution errors, where col is a 0-based byte offset
+var templateErrorPattern = regexp.MustCompile(`(?s)^template: (.+?):(\d+):(?:(\d+):)? (.*)$`)
+
+// locateError converts a text/template error into a *TemplateError pointing into the source of the template
+// it occurred in, which is t or one of the dependencies loaded into its set. Errors without a position are
+// returned unchanged.
+func (t *Template) locateError(err error) error {
+	var located *TemplateError
+	if errors.As(err, &located) {
+		return err
+	}
+	m := templateErrorPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	path, message := m[1], m[4]
+	line, _ := strconv.Atoi(m[2])
+	offset := -1
+	if m[3] != "" {
+		offset, _ = strconv.Atoi(m[3])
+	}
+
+	content, ok := t.source(path)
+	if !ok {
+		column := 0
+		if offset >= 0 {
+			column = offset + 1
+		}
+		return NewTemplateError(path, line, column, message, "", err)
+	}
+	sourceLine, line, column := sourcePosition(content, line, offset)
+	return NewTemplateError(path, line, column, message, snippet(sourceLine, line, column), err)
+}
+
+// sourcePosition converts a position text/template reports, a line of the body it parsed and a 0-based byte
+// offset into that line, into a 1-based line and rune column of the template's content. The column is 0 when
+// offset is negative. It also returns the source line.
+func sourcePosition(content string, line, offset int) (string, int, int) {
+	// Positions are relative to the body text/template parsed, after any front matter
+	_, body := SplitFrontMatter(content)
+	line += strings.Count(content[:len(content)-len(body)], "\n")
+	sourceLine := LineAt(content, line)
+	column := 0
+	if offset >= 0 {
+		column = utf8.RuneCountInString(sourceLine[:min(offset, len(sourceLine))]) + 1
+	}
+	return sourceLine, line, column
+}
+
+// source returns the content of the template at path, if it is t or one of its loaded dependencies
+func (t *Template) source(path string) (string, bool) {
+	if path == t.Path {
+		return t.OriginalContent, true
+	}
+	set := t.set.Load()
+	if set == nil {
+		return "", false
+	}
+	content, ok := set.sources[path]
+	return content, ok
+}
+
+// LineAt returns the 1-based line of content, or an empty string if there is no such line
+func LineAt(content string, line int) string {
+	lines := strings.Split(content, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[line-1], "\r")
+}
+
+// snippet formats a source line with its number, and a caret under column when it's known:
+//
+//	3 | Hello [[.user.nme]]
+//	  |         ^
+func snippet(sourceLine string, line, column int) string {
+	number := strconv.Itoa(line)
+	text := fmt.Sprintf("%s | %s", number, strings.ReplaceAll(sourceLine, "\t", " "))
+	if column <= 0 {
+		return text
+	}
+	return text + fmt.Sprintf("\n%s | %s^", strings.Repeat(" ", len(number)), strings.Repeat(" ", column-1))
+}
@@ -0,0 +1,25 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateError_FrontMatterLines(t *testing.T) {
+	template := NewTemplate("main.tmpl", "---\nkey: value\n---\nok\n[[.a.b]]", nil)
+	template.Tmpl = *newTemplateSet("main.tmpl", nil)
+	_, err := template.Tmpl.Parse(template.Body())
+	require.NoError(t, err)
+
+	err = template.Tmpl.Execute(io.Discard, map[string]any{"a": 1})
+	require.Error(t, err)
+	located := template.locateError(err)
+	var templateErr *TemplateError
+	require.True(t, errors.As(located, &templateErr), "got %v", located)
+	assert.Equal(t, 5, templateErr.Line, "lines count from the start of the file")
+	assert.Equal(t, "5 | [[.a.b]]\n  |     ^", templateErr.Snippet)
+}
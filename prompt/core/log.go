@@ -0,0 +1,25 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardLogger is used when no logger is set, so the library is silent by default
+var discardLogger = slog.New(discardHandler{})
+
+// discardHandler drops every record without formatting it
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// logger returns the template's logger, or one that discards everything if none is set
+func (t *Template) logger() *slog.Logger {
+	if t.Logger == nil {
+		return discardLogger
+	}
+	return t.Logger
+}
@@ -0,0 +1,14 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate_SilentWithoutLogger(t *testing.T) {
+	template := NewTemplate("main.tmpl", `Hi`, nil)
+	assert.False(t, template.logger().Enabled(context.Background(), slog.LevelError))
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Template functions such as role and trimmable write markers into the raw output that later passes act on.
+// Markers are delimited by NUL bytes so they can't clash with ordinary prompt text.
+const (
+	markerPrefix = "\x00rprompt-"
+	markerSuffix = "\x00"
+)
+
+var markerPattern = regexp.MustCompile("\x00rprompt-[a-z]+(?::[^\x00]*)?\x00")
+
+// marker returns the marker for kind, with an optional argument
+func marker(kind, arg string) string {
+	if arg == "" {
+		return markerPrefix + kind + markerSuffix
+	}
+	return markerPrefix + kind + ":" + arg + markerSuffix
+}
+
+// sanitizeData returns config data with the NUL bytes removed from its strings and keys, so a value such as an
+// HTTP request body can't forge the markers template functions write. Data without NUL bytes is returned as it
+// is; values other than strings, maps and slices of them are left alone.
+func sanitizeData(v any) any {
+	if !hasNUL(v) {
+		return v
+	}
+	switch v := v.(type) {
+	case string:
+		return strings.ReplaceAll(v, "\x00", "")
+	case map[string]any:
+		sanitized := make(map[string]any, len(v))
+		for key, value := range v {
+			sanitized[strings.ReplaceAll(key, "\x00", "")] = sanitizeData(value)
+		}
+		return sanitized
+	case []any:
+		sanitized := make([]any, len(v))
+		for i, value := range v {
+			sanitized[i] = sanitizeData(value)
+		}
+		return sanitized
+	case []string:
+		sanitized := make([]string, len(v))
+		for i, value := range v {
+			sanitized[i] = strings.ReplaceAll(value, "\x00", "")
+		}
+		return sanitized
+	case map[string]string:
+		sanitized := make(map[string]string, len(v))
+		for key, value := range v {
+			sanitized[strings.ReplaceAll(key, "\x00", "")] = strings.ReplaceAll(value, "\x00", "")
+		}
+		return sanitized
+	}
+	return v
+}
+
+// hasNUL reports whether any string sanitizeData cleans holds a NUL byte
+func hasNUL(v any) bool {
+	switch v := v.(type) {
+	case string:
+		return strings.Contains(v, "\x00")
+	case map[string]any:
+		for key, value := range v {
+			if strings.Contains(key, "\x00") || hasNUL(value) {
+				return true
+			}
+		}
+	case []any:
+		for _, value := range v {
+			if hasNUL(value) {
+				return true
+			}
+		}
+	case []string:
+		for _, value := range v {
+			if strings.Contains(value, "\x00") {
+				return true
+			}
+		}
+	case map[string]string:
+		for key, value := range v {
+			if strings.Contains(key, "\x00") || strings.Contains(value, "\x00") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stripMarkers removes every marker, leaving plain text
+func stripMarkers(raw string) string {
+	return markerPattern.ReplaceAllString(raw, "")
+}
+
+// markerFilter drops markers from plain text output. Template functions write their result in a single
+// call, so a marker always arrives as one whole write.
+type markerFilter struct {
+	w io.Writer
+}
+
+func (f *markerFilter) Write(p []byte) (int, error) {
+	if bytes.HasPrefix(p, []byte(markerPrefix)) && markerPattern.Match(p) {
+		return len(p), nil
+	}
+	return f.w.Write(p)
+}
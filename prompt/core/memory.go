@@ -0,0 +1,207 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// MemoryRegistry keeps templates, configs and documents in memory and never touches the filesystem, so the
+// prompt engine can run where there is none, such as a GOOS=js build previewing prompts in a browser. Despite
+// its name, the prompt package's NewInMemPromptRegistry returns a LocalPromptRegistry reading a directory.
+//
+// It is safe for concurrent use. Changing a template notifies the registry's change listeners, so a
+// PromptSystem over it rebuilds with the new source.
+type MemoryRegistry struct {
+	// LeftDelim and RightDelim replace the default action delimiters of the registry's templates when both
+	// are set
+	LeftDelim  string
+	RightDelim string
+	// Funcs are extra functions the registry's templates can call, see FuncRegistry
+	Funcs template.FuncMap
+	// Extensions are the accepted extensions of the registry's template paths, nil for
+	// DefaultTemplateExtension alone, see ExtensionRegistry
+	Extensions []string
+
+	mu        sync.RWMutex
+	templates map[string]string
+	// configs maps config paths to their JSON, so every LoadConfig returns a copy callers may change
+	configs   map[string]string
+	documents map[string]string
+	listeners []func(path string)
+}
+
+// NewMemoryRegistry creates an empty MemoryRegistry
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		templates: map[string]string{},
+		configs:   map[string]string{},
+		documents: map[string]string{},
+	}
+}
+
+// Delimiters returns the registry's action delimiters, see DelimitedRegistry
+func (r *MemoryRegistry) Delimiters() (string, string) {
+	return r.LeftDelim, r.RightDelim
+}
+
+// TemplateFuncs returns the registry's extra template functions, see FuncRegistry
+func (r *MemoryRegistry) TemplateFuncs() template.FuncMap {
+	return r.Funcs
+}
+
+// TemplateExtensions returns the registry's template extensions, see ExtensionRegistry
+func (r *MemoryRegistry) TemplateExtensions() []string {
+	return r.Extensions
+}
+
+// SetTemplate adds the template at path or replaces its source
+func (r *MemoryRegistry) SetTemplate(path, content string) error {
+	if err := CheckTemplateExtension(path, RegistryExtensions(r)); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.templates[path] = content
+	r.mu.Unlock()
+	r.notify(path)
+	return nil
+}
+
+// RemoveTemplate removes the template at path, if there is one
+func (r *MemoryRegistry) RemoveTemplate(path string) {
+	r.mu.Lock()
+	_, ok := r.templates[path]
+	delete(r.templates, path)
+	r.mu.Unlock()
+	if ok {
+		r.notify(path)
+	}
+}
+
+// SetConfig adds the config at path or replaces it. data must be a JSON object.
+func (r *MemoryRegistry) SetConfig(path, data string) error {
+	if _, err := CfgFromJSONString(data, path); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.configs[path] = data
+	return nil
+}
+
+// SetDocument adds the document at path, which templates include with the document function
+func (r *MemoryRegistry) SetDocument(path, content string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.documents[path] = content
+}
+
+// Find returns the template at path, which may name the template by its id instead, see IDPrefix
+func (r *MemoryRegistry) Find(ctx context.Context, path string) (*Template, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	if id, ok := strings.CutPrefix(path, IDPrefix); ok {
+		resolved, err := PathForID(id, TemplateIDs(r.templates))
+		if err != nil {
+			r.mu.RUnlock()
+			return nil, err
+		}
+		path = resolved
+	}
+	content, ok := r.templates[path]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("template %s is not in the registry: %w", path, fs.ErrNotExist)
+	}
+	return NewTemplate(path, content, r), nil
+}
+
+// LoadConfig returns a copy of the config at path
+func (r *MemoryRegistry) LoadConfig(ctx context.Context, path string) (*Config, error) {
+	data, err := r.ReadConfig(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return CfgFromJSONString(string(data), path)
+}
+
+// ReadConfig returns the JSON of the config at path as it was set, see RawConfigRegistry
+func (r *MemoryRegistry) ReadConfig(ctx context.Context, path string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	data, ok := r.configs[path]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("config %s is not in the registry: %w", path, fs.ErrNotExist)
+	}
+	return []byte(data), nil
+}
+
+// SaveConfig stores the config at its path
+func (r *MemoryRegistry) SaveConfig(ctx context.Context, cfg *Config) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(cfg.Config)
+	if err != nil {
+		return fmt.Errorf("failed to encode config %s: %w", cfg.Path, err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.configs[cfg.Path] = string(data)
+	return nil
+}
+
+// OpenDocument opens the document at path, see DocumentOpener
+func (r *MemoryRegistry) OpenDocument(ctx context.Context, path string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	content, ok := r.documents[path]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("document %s is not in the registry: %w", path, fs.ErrNotExist)
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+// List returns the paths of every template in the registry, sorted
+func (r *MemoryRegistry) List() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	paths := make([]string, 0, len(r.templates))
+	for path := range r.templates {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// OnChange registers fn to be called with the path of every template that is set or removed, see
+// ChangeNotifier
+func (r *MemoryRegistry) OnChange(fn func(path string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// notify calls every change listener with path
+func (r *MemoryRegistry) notify(path string) {
+	r.mu.RLock()
+	listeners := append([]func(string){}, r.listeners...)
+	r.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(path)
+	}
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"context"
+	"go/build"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRegistry(t *testing.T) {
+	registry := NewMemoryRegistry()
+	require.NoError(t, registry.SetTemplate("main.tmpl", `[[template "header.tmpl" .]] Hello [[.name]] [[document "docs/spec.md"]]`))
+	require.NoError(t, registry.SetTemplate("header.tmpl", "Header"))
+	require.NoError(t, registry.SetConfig("main.json", `{"name": "John"}`))
+	registry.SetDocument("docs/spec.md", "Spec")
+	assert.Error(t, registry.SetTemplate("main.txt", ""))
+	assert.Error(t, registry.SetConfig("bad.json", "{"))
+	var changed []string
+	registry.OnChange(func(path string) { changed = append(changed, path) })
+
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	ctx := context.Background()
+	output, err := system.Build(ctx, "main.tmpl", "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, "Header Hello John Spec", output)
+
+	require.NoError(t, registry.SetTemplate("header.tmpl", "New header"))
+	registry.RemoveTemplate("missing.tmpl")
+	assert.Equal(t, []string{"header.tmpl"}, changed)
+	output, err = system.Build(ctx, "main.tmpl", "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, "New header Hello John Spec", output)
+
+	cfg, err := registry.LoadConfig(ctx, "main.json")
+	require.NoError(t, err)
+	cfg.Config["name"] = "Jane"
+	reloaded, err := registry.LoadConfig(ctx, "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, "John", reloaded.Config["name"], "loaded configs are copies")
+	raw, err := registry.ReadConfig(ctx, "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, `{"name": "John"}`, string(raw), "configs are kept as they were set")
+	require.NoError(t, registry.SaveConfig(ctx, cfg))
+	output, err = system.Build(ctx, "main.tmpl", "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, "New header Hello Jane Spec", output)
+
+	registry.RemoveTemplate("header.tmpl")
+	_, err = system.Build(ctx, "main.tmpl", "main.json")
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+	assert.Equal(t, []string{"main.tmpl"}, registry.List())
+}
+
+// TestImports keeps the engine free of the file system, processes and the network, so it builds for GOOS=js
+// and wasip1 without the CLI, the servers or plugins
+func TestImports(t *testing.T) {
+	pkg, err := build.ImportDir(".", 0)
+	require.NoError(t, err)
+	for _, path := range []string{"os", "os/exec", "path/filepath", "net", "net/http"} {
+		assert.NotContains(t, pkg.Imports, path)
+	}
+	for _, path := range pkg.Imports {
+		assert.False(t, strings.HasPrefix(path, "github.com/notzree/rprompt/v2/prompt"), "core imports %s", path)
+	}
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Roles a template can declare with [[role "..."]]
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is a single chat message, in the shape chat completion APIs expect
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// roleMarkerPrefix starts the marker role writes, followed by the role name
+const roleMarkerPrefix = markerPrefix + "role:"
+
+var roleMarkerPattern = regexp.MustCompile("\x00rprompt-role:([a-z]+)\x00")
+
+// role is the template function behind [[role "system"]]. It starts a new message; everything written until
+// the next role belongs to it.
+func role(name string) (string, error) {
+	switch name {
+	case RoleSystem, RoleUser, RoleAssistant:
+		return marker("role", name), nil
+	default:
+		return "", fmt.Errorf("unknown role %q, expected one of: %s, %s, %s", name, RoleSystem, RoleUser, RoleAssistant)
+	}
+}
+
+// splitMessages splits raw template output into one message per role section. Other markers are removed,
+// content is trimmed, empty sections are dropped, and any text before the first role is treated as a user
+// message.
+func splitMessages(raw string) []Message {
+	messages := []Message{}
+	add := func(role, content string) {
+		if content = strings.TrimSpace(stripMarkers(content)); content != "" {
+			messages = append(messages, Message{Role: role, Content: content})
+		}
+	}
+
+	current := RoleUser
+	last := 0
+	for _, match := range roleMarkerPattern.FindAllStringSubmatchIndex(raw, -1) {
+		add(current, raw[last:match[0]])
+		current = raw[match[2]:match[3]]
+		last = match[1]
+	}
+	add(current, raw[last:])
+	return messages
+}
+
+// BuildMessages renders the template and splits it into a message per [[role]] section
+func (t *Template) BuildMessages(ctx context.Context, cfg Config) ([]Message, error) {
+	raw, err := t.buildRaw(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return splitMessages(raw), nil
+}
+
+// buildRaw renders the template keeping its role markers
+func (t *Template) buildRaw(ctx context.Context, cfg Config) (string, error) {
+	if err := t.LoadDependencies(ctx); err != nil {
+		return "", err
+	}
+	var builder strings.Builder
+	if err := t.executeRaw(ctx, &builder, t.Path, cfg); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// BuildMessages builds a template given a config, returning a message per [[role]] section
+func (s *PromptSystem) BuildMessages(ctx context.Context, templatePath, configPath string, opts ...BuildOption) ([]Message, error) {
+	result, err := s.BuildWithResult(ctx, templatePath, configPath, append(opts, WithMessages())...)
+	if err != nil {
+		return nil, err
+	}
+	return result.Messages, nil
+}
+
+// BuildMessages renders the builder's template with its config, returning a message per [[role]] section
+func (b *PromptBuilder) BuildMessages(ctx context.Context) ([]Message, error) {
+	requiredConfig, err := b.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config, err := b.config()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := validate(b.validation, b.ParentTemplate, requiredConfig, config); err != nil {
+		return nil, err
+	}
+	raw, err := b.renderRaw(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	section, err := b.responseFormat()
+	if err != nil {
+		return nil, err
+	}
+	raw, _, err = b.buildOptions().fit(raw + section)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := b.postProcessors()
+	if err != nil {
+		return nil, err
+	}
+	return postProcessMessages(splitMessages(raw), steps), nil
+}
+
+// renderRaw renders the builder's template with config through the system's middleware, keeping markers
+func (b *PromptBuilder) renderRaw(ctx context.Context, config *Config) (string, error) {
+	render := RenderFunc(func(ctx context.Context, t *Template, cfg *Config) (string, error) {
+		return t.buildRaw(ctx, *cfg)
+	})
+	if b.System != nil {
+		render = b.System.chain(render)
+	}
+	return render(ctx, b.ParentTemplate, config)
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"time"
+)
+
+// MetricsRecorder records the builds of a PromptSystem, such as into the Prometheus collectors of the prompt
+// package's Metrics
+type MetricsRecorder interface {
+	// ObserveBuild records a finished build of templatePath that started at start
+	ObserveBuild(templatePath string, start time.Time, err error)
+	// ObserveTokens records the token count of a built prompt
+	ObserveTokens(templatePath string, tokens int)
+	// ObserveCacheLookup records a parse cache hit or miss
+	ObserveCacheLookup(hit bool)
+}
+
+// Error types recorded by rprompt_render_errors_total
+const (
+	ErrorTypeNotFound   = "not_found"
+	ErrorTypeMissing    = "missing_fields"
+	ErrorTypeValidation = "validation"
+	ErrorTypeTemplate   = "template"
+	ErrorTypeBudget     = "token_budget"
+	ErrorTypeLimit      = "limit"
+	ErrorTypeCycle      = "dependency_cycle"
+	ErrorTypeCanceled   = "canceled"
+	ErrorTypePanic      = "panic"
+	ErrorTypeOther      = "other"
+)
+
+// ErrorType classifies a build error for metrics
+func ErrorType(err error) string {
+	var missing *MissingFieldsError
+	var invalid *ValidationError
+	var located *TemplateError
+	var budget *TokenBudgetError
+	var output *OutputLimitError
+	var timeout *RenderTimeoutError
+	var depth *IncludeDepthError
+	var cycle *DependencyCycleError
+	var panicked *RenderPanicError
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrorTypeNotFound
+	case errors.As(err, &missing):
+		return ErrorTypeMissing
+	case errors.As(err, &invalid):
+		return ErrorTypeValidation
+	case errors.As(err, &budget):
+		return ErrorTypeBudget
+	case errors.As(err, &output), errors.As(err, &timeout), errors.As(err, &depth):
+		return ErrorTypeLimit
+	case errors.As(err, &cycle):
+		return ErrorTypeCycle
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ErrorTypeCanceled
+	case errors.As(err, &panicked):
+		return ErrorTypePanic
+	case errors.As(err, &located):
+		return ErrorTypeTemplate
+	}
+	return ErrorTypeOther
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"context"
+)
+
+// RenderFunc renders a template with a config
+type RenderFunc func(ctx context.Context, t *Template, cfg *Config) (string, error)
+
+// Middleware wraps a RenderFunc, e.g. to log, redact, enforce token budgets or post-process output.
+// A middleware may modify the config before calling next, the output after it, or skip next entirely.
+type Middleware func(next RenderFunc) RenderFunc
+
+// Use appends middleware to the render chain. Middleware run in the order they were added, so the first one
+// added is the outermost. Use should be called while setting up the system, before concurrent builds start.
+func (s *PromptSystem) Use(mw ...Middleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.middleware = append(s.middleware, mw...)
+}
+
+// hasMiddleware reports whether any middleware has been registered
+func (s *PromptSystem) hasMiddleware() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.middleware) > 0
+}
+
+// renderer returns the render function wrapped in every registered middleware
+func (s *PromptSystem) renderer() RenderFunc {
+	return s.chain(func(ctx context.Context, t *Template, cfg *Config) (string, error) {
+		return t.Build(ctx, *cfg)
+	})
+}
+
+// chain wraps render in every registered middleware
+func (s *PromptSystem) chain(render RenderFunc) RenderFunc {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		render = s.middleware[i](render)
+	}
+	return render
+}
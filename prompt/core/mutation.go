@@ -0,0 +1,196 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/notzree/rprompt/v2/utils"
+)
+
+// MutationOutcome is how removing or emptying a variable affected a build
+type MutationOutcome string
+
+const (
+	// MutationBroken is output that shows signs of the missing value, such as a dangling "Hello ,"
+	MutationBroken MutationOutcome = "broken"
+	// MutationFailed is a build that returned an error, which is loud rather than silent
+	MutationFailed MutationOutcome = "failed"
+	// MutationUnchanged is output identical to the unmutated build's
+	MutationUnchanged MutationOutcome = "unchanged"
+	// MutationOK is output that changed without looking broken
+	MutationOK MutationOutcome = "ok"
+)
+
+// MutationResult is how a build's output changed when one variable was removed from or emptied in its config
+type MutationResult struct {
+	Variable string `json:"variable"`
+	// Mutation is removed or emptied
+	Mutation string          `json:"mutation"`
+	Outcome  MutationOutcome `json:"outcome"`
+	// Problems describe what looks broken in the output
+	Problems []string `json:"problems,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// String describes the result on one line
+func (r MutationResult) String() string {
+	desc := fmt.Sprintf("%s %s: %s", r.Variable, r.Mutation, r.Outcome)
+	switch {
+	case len(r.Problems) > 0:
+		desc += ", " + strings.Join(r.Problems, ", ")
+	case r.Error != "":
+		desc += ", " + r.Error
+	}
+	return desc
+}
+
+// MutationReport is the result of PromptSystem.MutationCheck
+type MutationReport struct {
+	Template string           `json:"template"`
+	Config   string           `json:"config"`
+	Results  []MutationResult `json:"results"`
+}
+
+// Broken returns the mutations that silently broke the output
+func (r *MutationReport) Broken() []MutationResult {
+	var broken []MutationResult
+	for _, result := range r.Results {
+		if result.Outcome == MutationBroken {
+			broken = append(broken, result)
+		}
+	}
+	return broken
+}
+
+// Fragile returns the variables the output breaks without, sorted. They are candidates for being required.
+func (r *MutationReport) Fragile() []string {
+	var variables []string
+	for _, result := range r.Broken() {
+		variables = append(variables, result.Variable)
+	}
+	variables = utils.UniqueString(variables)
+	sort.Strings(variables)
+	return variables
+}
+
+// brokenOutputPatterns are signs of a value missing from a prompt. Prompts use most of them legitimately too,
+// so a mutation only counts as broken when it adds occurrences the unmutated output doesn't have.
+var brokenOutputPatterns = []struct {
+	problem string
+	pattern *regexp.Regexp
+}{
+	{"renders " + noValue, regexp.MustCompile(regexp.QuoteMeta(noValue))},
+	{"space before punctuation", regexp.MustCompile(`[ \t]+[,.;:!?](?:\s|$)`)},
+	{"repeated commas", regexp.MustCompile(`,\s*,`)},
+	{"line starts with punctuation", regexp.MustCompile(`(?m)^[ \t]*[,.;:!?]`)},
+	{"doubled spaces", regexp.MustCompile(`\S[ \t]{2,}\S`)},
+	{"empty brackets", regexp.MustCompile(`\(\s*\)|\[\s*\]|\{\s*\}`)},
+	{"empty quotes", regexp.MustCompile(`"\s*"|'\s*'`)},
+	{"line ends after a colon", regexp.MustCompile(`(?m):[ \t]*$`)},
+	{"empty list item", regexp.MustCompile(`(?m)^[ \t]*(?:[-*+]|\d+\.)[ \t]*$`)},
+	{"empty heading", regexp.MustCompile(`(?m)^#+[ \t]*$`)},
+}
+
+// configMutation is a config with one variable removed or emptied
+type configMutation struct {
+	name string
+	data map[string]any
+}
+
+// MutationCheck builds the template with the config, then again with each variable the template uses
+// individually removed from and emptied in the config, and reports which omissions silently produce broken
+// output rather than an error. The unmutated build must succeed. Mutated builds skip validation, since
+// missing values are the point, and every build is deterministic so only the mutation changes the output.
+func (s *PromptSystem) MutationCheck(ctx context.Context, templatePath, configPath string) (*MutationReport, error) {
+	template, err := s.find(ctx, templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("err finding template: %w", err)
+	}
+	required, err := template.GenerateConfig(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	config, err := s.loadConfig(ctx, configPath, &buildOptions{})
+	if err != nil {
+		return nil, err
+	}
+	base, err := s.Build(ctx, templatePath, configPath, WithDeterministic(), WithAllowDraft())
+	if err != nil {
+		return nil, fmt.Errorf("the template must build with the config before it can be mutated: %w", err)
+	}
+
+	report := &MutationReport{Template: templatePath, Config: configPath, Results: []MutationResult{}}
+	for _, variable := range utils.FlattenKeys(required.Config) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		value, ok := utils.GetPath(config.Config, variable)
+		if !ok {
+			continue
+		}
+		mutations := []configMutation{{"removed", utils.WithoutPath(config.Config, variable)}}
+		if empty, ok := emptyValue(value); ok {
+			// WithoutPath copies the maps along the path, so setting it leaves the config untouched
+			emptied := utils.WithoutPath(config.Config, variable)
+			utils.SetPath(emptied, variable, empty)
+			mutations = append(mutations, configMutation{"emptied", emptied})
+		}
+
+		for _, mutation := range mutations {
+			result := MutationResult{Variable: variable, Mutation: mutation.name}
+			output, err := s.Build(ctx, templatePath, "", WithData(mutation.data), WithValidation(ValidationOff), WithDeterministic(), WithAllowDraft())
+			switch {
+			case err != nil:
+				result.Outcome, result.Error = MutationFailed, err.Error()
+			case output == base:
+				result.Outcome = MutationUnchanged
+			default:
+				result.Problems = brokenOutputProblems(base, output)
+				result.Outcome = MutationOK
+				if len(result.Problems) > 0 {
+					result.Outcome = MutationBroken
+				}
+			}
+			report.Results = append(report.Results, result)
+		}
+	}
+	return report, nil
+}
+
+// emptyValue returns the empty value of v's type, and false when v is already empty or has no empty value
+func emptyValue(v any) (any, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice:
+		if rv.Len() == 0 {
+			return nil, false
+		}
+		return reflect.MakeSlice(rv.Type(), 0, 0).Interface(), true
+	case reflect.Map:
+		if rv.Len() == 0 {
+			return nil, false
+		}
+		return reflect.MakeMap(rv.Type()).Interface(), true
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+		if rv.IsZero() {
+			return nil, false
+		}
+		return reflect.Zero(rv.Type()).Interface(), true
+	}
+	return nil, false
+}
+
+// brokenOutputProblems describes the signs of a missing value output has more of than base
+func brokenOutputProblems(base, output string) []string {
+	var problems []string
+	for _, p := range brokenOutputPatterns {
+		if added := len(p.pattern.FindAllStringIndex(output, -1)) - len(p.pattern.FindAllStringIndex(base, -1)); added > 0 {
+			problems = append(problems, fmt.Sprintf("%s (%d more)", p.problem, added))
+		}
+	}
+	return problems
+}
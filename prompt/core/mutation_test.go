@@ -0,0 +1,27 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmptyValue(t *testing.T) {
+	for _, tt := range []struct {
+		value any
+		empty any
+		ok    bool
+	}{
+		{"text", "", true},
+		{"", nil, false},
+		{[]any{1}, []any{}, true},
+		{map[string]any{"a": 1}, map[string]any{}, true},
+		{true, false, true},
+		{float64(2), float64(0), true},
+		{nil, nil, false},
+	} {
+		empty, ok := emptyValue(tt.value)
+		assert.Equal(t, tt.ok, ok, tt.value)
+		assert.Equal(t, tt.empty, empty, tt.value)
+	}
+}
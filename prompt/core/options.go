@@ -0,0 +1,159 @@
+package core
+
+import (
+	"github.com/notzree/rprompt/v2/utils"
+)
+
+// BuildOption customizes a single build
+type BuildOption func(*buildOptions)
+
+type buildOptions struct {
+	// data is layered over the loaded config
+	data map[string]any
+	// validation overrides the system's validation mode when set
+	validation *ValidationMode
+	// hashFooter appends the build's hash to the output
+	hashFooter bool
+	// limits overrides the system's limits when set
+	limits *Limits
+	// messages splits the output into a message per role section
+	messages bool
+	// maxTokens trims the output to fit when positive, counting with tokenizer
+	maxTokens int
+	tokenizer Tokenizer
+	// responseFormat appends the template's response schema to the output
+	responseFormat bool
+	// auditLabels are attached to the build's audit record
+	auditLabels map[string]string
+	// lazyDeps resolves only the dependencies reachable with the build's config
+	lazyDeps bool
+	// deterministic fixes the time and random template functions
+	deterministic bool
+	// coverage records the branches the build renders
+	coverage *Coverage
+	// allowDraft builds templates marked as drafts
+	allowDraft bool
+	// locale picks the variants of the template and its dependencies to build
+	locale string
+	// history is the conversation [[history]] renders
+	history History
+	// target is the model the prompt is rendered for
+	target Target
+	// provenance writes a provenance comment before or after the output
+	provenance ProvenancePosition
+	// params fill the placeholders of a parameterized template path
+	params map[string]string
+	// sections renders each of the template's sections on its own as well
+	sections bool
+}
+
+func newBuildOptions(opts []BuildOption) *buildOptions {
+	o := &buildOptions{
+		data: map[string]any{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithData merges data over the loaded config for this build. Nested maps are merged key by key and any other
+// value replaces the config's value.
+func WithData(data map[string]any) BuildOption {
+	return func(o *buildOptions) {
+		o.data = utils.DeepMerge(o.data, data)
+	}
+}
+
+// WithValue sets the value at a dotted path such as "user.name" over the loaded config for this build
+func WithValue(path string, v any) BuildOption {
+	return func(o *buildOptions) {
+		value := map[string]any{}
+		utils.SetPath(value, path, v)
+		o.data = utils.DeepMerge(o.data, value)
+	}
+}
+
+// WithValidation overrides the system's validation mode for this build
+func WithValidation(mode ValidationMode) BuildOption {
+	return func(o *buildOptions) {
+		o.validation = &mode
+	}
+}
+
+// WithHashFooter appends the build's hash, see PromptSystem.Hash, to the output as a comment
+func WithHashFooter() BuildOption {
+	return func(o *buildOptions) {
+		o.hashFooter = true
+	}
+}
+
+// WithLimits overrides the system's limits for this build
+func WithLimits(limits Limits) BuildOption {
+	return func(o *buildOptions) {
+		o.limits = &limits
+	}
+}
+
+// WithMessages fills BuildResult.Messages with a message per [[role]] section of the output
+func WithMessages() BuildOption {
+	return func(o *buildOptions) {
+		o.messages = true
+	}
+}
+
+// WithTokenBudget trims [[trimmable]] sections of the output until it fits within maxTokens as counted by
+// tokenizer, or EstimateTokens when tokenizer is nil. The build fails with a *TokenBudgetError if the output
+// can't be trimmed enough.
+func WithTokenBudget(maxTokens int, tokenizer Tokenizer) BuildOption {
+	return func(o *buildOptions) {
+		o.maxTokens = maxTokens
+		o.tokenizer = tokenizer
+	}
+}
+
+// validationMode returns the mode to validate with, preferring a per-build override
+func (o *buildOptions) validationMode(systemMode ValidationMode) ValidationMode {
+	if o.validation != nil {
+		return *o.validation
+	}
+	return systemMode
+}
+
+// applyConfig returns cfg with the build's data layered over it. cfg itself is left untouched so configs
+// shared between builds are never modified.
+func (o *buildOptions) applyConfig(cfg *Config) *Config {
+	if len(o.data) == 0 {
+		return cfg
+	}
+	return NewConfig(utils.DeepMerge(cfg.Config, o.data), cfg.Path)
+}
+
+// limitsFor returns the limits to build with, preferring a per-build override
+func (o *buildOptions) limitsFor(systemLimits Limits) Limits {
+	if o.limits != nil {
+		return *o.limits
+	}
+	return systemLimits
+}
+
+// rawOutput reports whether the build needs the raw output, markers included, to post-process it
+func (o *buildOptions) rawOutput() bool {
+	return o.messages || o.maxTokens > 0
+}
+
+// fit trims raw output to the build's token budget, if it has one
+func (o *buildOptions) fit(raw string) (string, int, error) {
+	if o.maxTokens <= 0 {
+		return raw, 0, nil
+	}
+	return fitTokenBudget(raw, o.maxTokens, o.count)
+}
+
+// count counts tokens with the build's tokenizer
+func (o *buildOptions) count(text string) int {
+	if o.tokenizer == nil {
+		return EstimateTokens(text)
+	}
+	return o.tokenizer(text)
+}
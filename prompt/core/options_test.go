@@ -0,0 +1,16 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildOptions_ApplyConfigDoesNotModifyInput(t *testing.T) {
+	cfg := NewConfig(map[string]any{"name": "John"}, "config.json")
+	applied := newBuildOptions([]BuildOption{WithValue("name", "Jane")}).applyConfig(cfg)
+
+	assert.Equal(t, "Jane", applied.Config["name"])
+	assert.Equal(t, "config.json", applied.Path)
+	assert.Equal(t, "John", cfg.Config["name"])
+}
@@ -0,0 +1,18 @@
+package core
+
+// OwnerRegistry is implemented by registries that assign owners to templates besides their front matter
+type OwnerRegistry interface {
+	// FileOwners returns the owners the registry assigns the template at path, or none
+	FileOwners(path string) ([]string, error)
+}
+
+// templateOwners returns the owners listed in a template's front matter, or else those its registry assigns it
+func templateOwners(r PromptRegistry, templatePath string, fm *FrontMatter) ([]string, error) {
+	if len(fm.Owners) > 0 {
+		return fm.Owners, nil
+	}
+	if owned, ok := r.(OwnerRegistry); ok {
+		return owned.FileOwners(templatePath)
+	}
+	return nil, nil
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// paramPattern matches the placeholders of a parameterized template path, such as {agent} in agents/{agent}.tmpl
+var paramPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_-]*)\}`)
+
+// WithParam fills the {name} placeholder of a parameterized template path such as agents/{agent}.tmpl with
+// value, so one call site can build a template per agent, tenant or product without assembling paths itself.
+// A value is a single path segment: it can't be empty, contain a slash or be . or .., so values taken from
+// requests can't reach templates outside the intended directory.
+func WithParam(name, value string) BuildOption {
+	return func(o *buildOptions) {
+		if o.params == nil {
+			o.params = make(map[string]string)
+		}
+		o.params[name] = value
+	}
+}
+
+// Find returns the template at templatePath with the system's settings attached, after filling the path's
+// placeholders with the WithParam options and picking the WithLocale variant. The template can then be built
+// with Template.Build.
+func (s *PromptSystem) Find(ctx context.Context, templatePath string, opts ...BuildOption) (*Template, error) {
+	return s.findLocalized(ctx, templatePath, newBuildOptions(opts))
+}
+
+// expandPath fills the placeholders of a template path with the build's params. Params the path doesn't use
+// are ignored, so the same options can be shared between builds of different templates.
+func (o *buildOptions) expandPath(path string) (string, error) {
+	if !strings.Contains(path, "{") {
+		return path, nil
+	}
+	var err error
+	expanded := paramPattern.ReplaceAllStringFunc(path, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		value, ok := o.params[name]
+		switch {
+		case err != nil:
+		case !ok:
+			err = fmt.Errorf("template path %q needs a value for {%s}, set it with WithParam", path, name)
+		case value == "" || value == "." || value == ".." || strings.ContainsAny(value, `/\`):
+			err = fmt.Errorf("invalid value %q for {%s} in %q: params must be a single path segment", value, name, path)
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	return expanded, nil
+}
@@ -0,0 +1,216 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PIIMode controls what a build does with likely personal data, such as email addresses, in config values
+type PIIMode int
+
+const (
+	// PIIOff doesn't scan configs. It is the zero value.
+	PIIOff PIIMode = iota
+	// PIIWarn renders config values as they are and reports each one holding likely PII as a warning
+	PIIWarn
+	// PIIMask replaces likely PII in config values with a placeholder such as [EMAIL] before rendering
+	PIIMask
+	// PIIBlock fails the build with a *PIIError when any config value holds likely PII
+	PIIBlock
+)
+
+var piiModeNames = map[PIIMode]string{
+	PIIOff:   "off",
+	PIIWarn:  "warn",
+	PIIMask:  "mask",
+	PIIBlock: "block",
+}
+
+func (m PIIMode) String() string {
+	if name, ok := piiModeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("PIIMode(%d)", int(m))
+}
+
+// ParsePIIMode parses one of "off", "warn", "mask" or "block"
+func ParsePIIMode(s string) (PIIMode, error) {
+	for mode, name := range piiModeNames {
+		if strings.EqualFold(s, name) {
+			return mode, nil
+		}
+	}
+	return PIIOff, fmt.Errorf("unknown PII mode %q, expected one of: off, warn, mask, block", s)
+}
+
+// Kinds of PII the scanner detects
+const (
+	PIIEmail      = "email"
+	PIIPhone      = "phone"
+	PIICreditCard = "credit_card"
+)
+
+// piiDetectors are checked in order, so a card number isn't also reported as a phone number
+var piiDetectors = []struct {
+	kind        string
+	placeholder string
+	pattern     *regexp.Regexp
+	// number detectors skip matches that are only part of a longer number, such as an account ID
+	number bool
+	// valid filters out matches of the pattern that aren't PII, nil accepts every match
+	valid func(string) bool
+}{
+	{PIIEmail, "[EMAIL]", regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), false, nil},
+	{PIICreditCard, "[CREDIT_CARD]", regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), true, luhnValid},
+	{PIIPhone, "[PHONE]", regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{2,4}\)[ .-]?|\b\d{2,4}[ .-])\d{3,4}[ .-]\d{4}\b`), true, nil},
+}
+
+// PIIMatch is a config value holding likely PII
+type PIIMatch struct {
+	// Path is the dotted config path of the value
+	Path string `json:"path"`
+	// Kinds are the kinds of PII found in the value, such as email, sorted
+	Kinds []string `json:"kinds"`
+}
+
+func (m PIIMatch) String() string {
+	return fmt.Sprintf("%s (%s)", m.Path, strings.Join(m.Kinds, ", "))
+}
+
+// FindPII returns the config values that hold likely PII: email addresses, phone numbers and credit card
+// numbers that pass the Luhn check. Matches are sorted by path.
+func FindPII(config map[string]any) []PIIMatch {
+	_, matches := MaskPII(config)
+	return matches
+}
+
+// MaskPII returns a copy of config with likely PII in its values replaced by placeholders such as [EMAIL],
+// along with the values it changed. Config is left as it is.
+func MaskPII(config map[string]any) (map[string]any, []PIIMatch) {
+	var matches []PIIMatch
+	var visit func(value any, path string) any
+	visit = func(value any, path string) any {
+		switch v := value.(type) {
+		case string:
+			masked, kinds := maskString(v)
+			if len(kinds) > 0 {
+				matches = append(matches, PIIMatch{Path: path, Kinds: kinds})
+			}
+			return masked
+		case map[string]any:
+			copied := make(map[string]any, len(v))
+			for key, item := range v {
+				copied[key] = visit(item, joinPath(path, key))
+			}
+			return copied
+		case []any:
+			copied := make([]any, len(v))
+			for i, item := range v {
+				copied[i] = visit(item, joinPath(path, fmt.Sprint(i)))
+			}
+			return copied
+		default:
+			return value
+		}
+	}
+	masked := visit(config, "").(map[string]any)
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Path < matches[j].Path })
+	return masked, matches
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// maskString replaces the PII in s with placeholders, returning the kinds it found
+func maskString(s string) (string, []string) {
+	var kinds []string
+	for _, detector := range piiDetectors {
+		var b strings.Builder
+		last := 0
+		for _, loc := range detector.pattern.FindAllStringIndex(s, -1) {
+			match := s[loc[0]:loc[1]]
+			if detector.number && partOfNumber(s, loc[0], loc[1]) {
+				continue
+			}
+			if detector.valid != nil && !detector.valid(match) {
+				continue
+			}
+			b.WriteString(s[last:loc[0]])
+			b.WriteString(detector.placeholder)
+			last = loc[1]
+		}
+		if last > 0 {
+			b.WriteString(s[last:])
+			s = b.String()
+			kinds = append(kinds, detector.kind)
+		}
+	}
+	sort.Strings(kinds)
+	return s, kinds
+}
+
+// partOfNumber reports whether the digits between start and end continue into more digits on either side,
+// past at most one separator
+func partOfNumber(s string, start, end int) bool {
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+	before := strings.TrimRight(s[:start], " .-")
+	if len(before) > 0 && len(before) >= start-1 && isDigit(before[len(before)-1]) {
+		return true
+	}
+	after := strings.TrimLeft(s[end:], " .-")
+	return len(after) > 0 && len(after) >= len(s)-end-1 && isDigit(after[0])
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum credit card numbers use
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// screenPII applies the system's PII mode to a build's config. It returns the config to render, masked in
+// PIIMask mode, and the values holding likely PII, failing with a *PIIError in PIIBlock mode.
+func (s *PromptSystem) screenPII(config *Config) (*Config, []PIIMatch, error) {
+	if s == nil || s.PII == PIIOff {
+		return config, nil, nil
+	}
+	masked, matches := MaskPII(config.Config)
+	if len(matches) == 0 {
+		return config, nil, nil
+	}
+	switch s.PII {
+	case PIIBlock:
+		return nil, nil, NewPIIError(matches)
+	case PIIMask:
+		return NewConfig(masked, config.Path), matches, nil
+	default:
+		return config, matches, nil
+	}
+}
+
+// logPII warns through t's logger about each config value holding likely PII, for builds that don't return
+// warnings
+func (s *PromptSystem) logPII(t *Template, matches []PIIMatch) {
+	for _, match := range matches {
+		t.logger().Warn("config value holds likely PII", "path", match.Path, "kinds", match.Kinds, "masked", s.PII == PIIMask)
+	}
+}
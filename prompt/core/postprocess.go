@@ -0,0 +1,209 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Post-processors a template's front matter or PromptSystem.PostProcess can list. Steps are written as the name
+// alone, or as name=value for those that take a value: max_width=100 or suffix=Answer in English.
+const (
+	// PostDedent removes the indentation every non-blank line shares
+	PostDedent = "dedent"
+	// PostCollapseBlankLines replaces runs of blank lines with a single blank line
+	PostCollapseBlankLines = "collapse_blank_lines"
+	// PostStripComments removes markdown comments, <!-- ... --> and [//]: # (...)
+	PostStripComments = "strip_comments"
+	// PostMaxWidth wraps lines longer than the given width at spaces
+	PostMaxWidth = "max_width"
+	// PostSuffix appends the given text
+	PostSuffix = "suffix"
+)
+
+var (
+	// standaloneCommentPattern matches comments on lines of their own, so removing them leaves no blank line
+	standaloneCommentPattern = regexp.MustCompile(`(?m)^[ \t]*(?:<!--(?s:.*?)-->|\[//\]: #.*)[ \t]*(?:\r?\n|$)`)
+	inlineCommentPattern     = regexp.MustCompile(`(?s)<!--.*?-->`)
+)
+
+// postStep is a parsed post-processor
+type postStep struct {
+	apply func(string) string
+	// whole steps apply to the output as a whole, so of a prompt split into messages only the last one gets them
+	whole bool
+}
+
+// parsePostProcessors parses post-processor steps, see PostDedent and the other steps
+func parsePostProcessors(steps []string) ([]postStep, error) {
+	parsed := make([]postStep, 0, len(steps))
+	for _, step := range steps {
+		name, value, hasValue := strings.Cut(step, "=")
+		var p postStep
+		switch name {
+		case PostDedent:
+			p.apply = dedent
+		case PostCollapseBlankLines:
+			p.apply = collapseBlankLines
+		case PostStripComments:
+			p.apply = stripComments
+		case PostMaxWidth:
+			width, err := strconv.Atoi(value)
+			if err != nil || width <= 0 {
+				return nil, fmt.Errorf("post-processor max_width needs a positive width, e.g. max_width=100")
+			}
+			p.apply = func(s string) string { return wrapWidth(s, width) }
+		case PostSuffix:
+			if !hasValue {
+				return nil, fmt.Errorf("post-processor suffix needs the text to append, e.g. suffix=Answer in English.")
+			}
+			p.apply, p.whole = func(s string) string { return s + value }, true
+		default:
+			return nil, fmt.Errorf("unknown post-processor %q, expected one of: %s, %s, %s, %s or %s", name, PostDedent, PostCollapseBlankLines, PostStripComments, PostMaxWidth, PostSuffix)
+		}
+		if hasValue && name != PostMaxWidth && name != PostSuffix {
+			return nil, fmt.Errorf("post-processor %s doesn't take a value", name)
+		}
+		parsed = append(parsed, p)
+	}
+	return parsed, nil
+}
+
+// PostProcess applies post-processor steps to output in order
+func PostProcess(output string, steps []string) (string, error) {
+	parsed, err := parsePostProcessors(steps)
+	if err != nil {
+		return "", err
+	}
+	return postProcess(output, parsed), nil
+}
+
+func postProcess(output string, steps []postStep) string {
+	for _, step := range steps {
+		output = step.apply(output)
+	}
+	return output
+}
+
+// postProcessMessages applies the steps to each message, except for steps on the whole output which only the
+// last message gets
+func postProcessMessages(messages []Message, steps []postStep) []Message {
+	processed := make([]Message, len(messages))
+	for i, message := range messages {
+		for _, step := range steps {
+			if !step.whole || i == len(messages)-1 {
+				message.Content = step.apply(message.Content)
+			}
+		}
+		processed[i] = message
+	}
+	return processed
+}
+
+// postProcessors returns the steps to build t with: those in its front matter, then the system's
+func postProcessors(t *Template, system []string) ([]postStep, error) {
+	fm, err := t.FrontMatter()
+	if err != nil {
+		return nil, err
+	}
+	steps := append(append([]string{}, fm.PostProcess...), system...)
+	if len(steps) == 0 {
+		return nil, nil
+	}
+	parsed, err := parsePostProcessors(steps)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", t.Path, err)
+	}
+	return parsed, nil
+}
+
+// postProcessors returns the steps to build the builder's template with
+func (b *PromptBuilder) postProcessors() ([]postStep, error) {
+	var system []string
+	if b.System != nil {
+		system = b.System.PostProcess
+	}
+	return postProcessors(b.ParentTemplate, system)
+}
+
+// dedent removes the leading whitespace every non-blank line shares
+func dedent(s string) string {
+	lines := strings.Split(s, "\n")
+	prefix := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			prefix, first = indent, false
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if prefix == "" {
+		return s
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, prefix)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// collapseBlankLines replaces runs of blank lines with a single empty line
+func collapseBlankLines(s string) string {
+	lines := strings.Split(s, "\n")
+	kept := lines[:0]
+	blank := false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			if blank {
+				continue
+			}
+			blank = true
+			kept = append(kept, "")
+			continue
+		}
+		blank = false
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
+}
+
+// stripComments removes markdown comments, dropping the lines of those that stand alone
+func stripComments(s string) string {
+	s = standaloneCommentPattern.ReplaceAllString(s, "")
+	return inlineCommentPattern.ReplaceAllString(s, "")
+}
+
+// wrapWidth wraps lines longer than width at spaces, indenting continuations like the line they continue.
+// Words longer than the width are left whole.
+func wrapWidth(s string, width int) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if len([]rune(line)) <= width {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeftFunc(line, unicode.IsSpace))]
+		var wrapped []string
+		current := indent
+		for _, word := range strings.Fields(line) {
+			switch {
+			case current == indent:
+				current += word
+			case len([]rune(current))+1+len([]rune(word)) > width:
+				wrapped = append(wrapped, current)
+				current = indent + word
+			default:
+				current += " " + word
+			}
+		}
+		lines[i] = strings.Join(append(wrapped, current), "\n")
+	}
+	return strings.Join(lines, "\n")
+}
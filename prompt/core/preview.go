@@ -0,0 +1,225 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// PreviewRegion is a part of a preview's output an action wrote, rather than the template's static text
+type PreviewRegion struct {
+	// Start and End are byte offsets into the output, End exclusive
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// Action is the pipeline of the action, such as .user.name or upper .name
+	Action string `json:"action"`
+	// Variables are the config values the action reads, relative to dot where the action is, so within a range
+	// or with they are relative to the item
+	Variables []string `json:"variables,omitempty"`
+	Template  string   `json:"template"`
+	Line      int      `json:"line"`
+	Column    int      `json:"column"`
+}
+
+// Preview is a template's output along with the regions of it that actions wrote, in order, so authors can see
+// what came from the config rather than the template's text. Regions written by an action within another
+// action's output, such as a template rendered by a function, belong to the outer region.
+type Preview struct {
+	Output  string          `json:"output"`
+	Regions []PreviewRegion `json:"regions"`
+}
+
+// Preview renders a template with a config like Build, reporting which parts of the output each action wrote.
+// The output is the template's own, before token budgets, post-processing, middleware and footers apply.
+func (s *PromptSystem) Preview(ctx context.Context, templatePath, configPath string, opts ...BuildOption) (*Preview, error) {
+	builder, err := s.NewBuilder(ctx, templatePath, configPath, opts...)
+	if err != nil {
+		return nil, err
+	}
+	requiredConfig, err := builder.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config, err := builder.config()
+	if err != nil {
+		return nil, err
+	}
+	if err := builder.buildOptions().checkDrafts(builder.ParentTemplate); err != nil {
+		return nil, err
+	}
+	if _, err := validate(builder.validation, builder.ParentTemplate, requiredConfig, config); err != nil {
+		return nil, err
+	}
+	return builder.ParentTemplate.Preview(ctx, *config)
+}
+
+// Preview renders the template like Build, reporting which parts of the output each action wrote
+func (t *Template) Preview(ctx context.Context, cfg Config) (*Preview, error) {
+	recorder := &previewRecorder{regions: make(map[string]PreviewRegion)}
+	t.preview = recorder
+	defer func() { t.preview = nil }()
+	raw, err := t.buildRaw(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return recorder.preview(raw), nil
+}
+
+// The terminal colors of Highlight, matching those of the CLI's output
+const (
+	highlightColor  = "\033[36m"
+	annotationColor = "\033[90m"
+	resetColor      = "\033[0m"
+)
+
+// Highlight returns the output with the regions actions wrote in color, or between « and » without color. With
+// annotate, each region is followed by the variables it came from, or its action when it reads none.
+func (p *Preview) Highlight(color, annotate bool) string {
+	var b strings.Builder
+	last := 0
+	for _, region := range p.Regions {
+		b.WriteString(p.Output[last:region.Start])
+		text := p.Output[region.Start:region.End]
+		if color {
+			b.WriteString(highlightColor + text + resetColor)
+		} else {
+			b.WriteString("«" + text + "»")
+		}
+		if annotate {
+			source := strings.Join(region.Variables, ", ")
+			if source == "" {
+				source = region.Action
+			}
+			if color {
+				b.WriteString(annotationColor + "⟨" + source + "⟩" + resetColor)
+			} else {
+				b.WriteString("⟨" + source + "⟩")
+			}
+		}
+		last = region.End
+	}
+	b.WriteString(p.Output[last:])
+	return b.String()
+}
+
+// previewRecorder instruments templates with a marker around every action that writes output and reads the
+// regions between the markers back from the rendered output
+type previewRecorder struct {
+	// regions are the actions by key, their offsets unset
+	regions map[string]PreviewRegion
+}
+
+// instrument returns a copy of the template set with markers around every action that isn't an assignment
+func (p *previewRecorder) instrument(t *Template, tmpl *template.Template) (*template.Template, error) {
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	// Clone shares parse trees between the sets, so the trees are copied before they are changed
+	for _, named := range clone.Templates() {
+		if named.Tree == nil {
+			continue
+		}
+		tree := named.Tree.Copy()
+		p.instrumentList(t, tree, tree.Root)
+		if _, err := clone.AddParseTree(named.Name(), tree); err != nil {
+			return nil, err
+		}
+	}
+	return clone, nil
+}
+
+func (p *previewRecorder) instrumentList(t *Template, tree *parse.Tree, list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	nodes := make([]parse.Node, 0, len(list.Nodes))
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.ActionNode:
+			if len(n.Pipe.Decl) == 0 {
+				key := p.register(t, tree, n)
+				nodes = append(nodes,
+					&parse.TextNode{NodeType: parse.NodeText, Pos: n.Pos, Text: []byte(marker("action", key))},
+					n,
+					&parse.TextNode{NodeType: parse.NodeText, Pos: n.Pos, Text: []byte(marker("actionend", ""))},
+				)
+				continue
+			}
+		case *parse.IfNode:
+			p.instrumentList(t, tree, n.List)
+			p.instrumentList(t, tree, n.ElseList)
+		case *parse.WithNode:
+			p.instrumentList(t, tree, n.List)
+			p.instrumentList(t, tree, n.ElseList)
+		case *parse.RangeNode:
+			p.instrumentList(t, tree, n.List)
+			p.instrumentList(t, tree, n.ElseList)
+		case *parse.ListNode:
+			p.instrumentList(t, tree, n)
+		}
+		nodes = append(nodes, node)
+	}
+	list.Nodes = nodes
+}
+
+// register records an action, returning the key its markers carry
+func (p *previewRecorder) register(t *Template, tree *parse.Tree, node *parse.ActionNode) string {
+	path, line, column := t.position(tree, node)
+	key := fmt.Sprintf("%s:%d:%d", path, line, column)
+	if _, ok := p.regions[key]; ok {
+		return key
+	}
+	region := PreviewRegion{Action: node.Pipe.String(), Template: path, Line: line, Column: column}
+	seen := make(map[string]bool)
+	inspectNodes(node.Pipe, func(node parse.Node) {
+		var name string
+		switch n := node.(type) {
+		case *parse.FieldNode, *parse.DotNode:
+			name = n.String()
+		case *parse.VariableNode:
+			if len(n.Ident) > 1 || n.Ident[0] != "$" {
+				name = n.String()
+			}
+		}
+		if name != "" && !seen[name] {
+			seen[name] = true
+			region.Variables = append(region.Variables, name)
+		}
+	})
+	p.regions[key] = region
+	return key
+}
+
+// preview strips the markers from raw output, recording the regions between the action markers
+func (p *previewRecorder) preview(raw string) *Preview {
+	preview := &Preview{Regions: []PreviewRegion{}}
+	var b strings.Builder
+	var open PreviewRegion
+	depth := 0
+	last := 0
+	for _, loc := range markerPattern.FindAllStringIndex(raw, -1) {
+		b.WriteString(raw[last:loc[0]])
+		last = loc[1]
+		kind, arg, _ := strings.Cut(raw[loc[0]+len(markerPrefix):loc[1]-len(markerSuffix)], ":")
+		switch kind {
+		case "action":
+			if depth == 0 {
+				open = p.regions[arg]
+				open.Start = b.Len()
+			}
+			depth++
+		case "actionend":
+			depth--
+			if depth == 0 && b.Len() > open.Start {
+				open.End = b.Len()
+				preview.Regions = append(preview.Regions, open)
+			}
+		}
+	}
+	b.WriteString(raw[last:])
+	preview.Output = b.String()
+	return preview
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ProvenancePosition is where WithProvenance writes the provenance comment
+type ProvenancePosition int
+
+const (
+	// ProvenanceNone writes no provenance comment. It is the zero value.
+	ProvenanceNone ProvenancePosition = iota
+	// ProvenanceHeader writes the comment before the prompt
+	ProvenanceHeader
+	// ProvenanceFooter writes the comment after the prompt
+	ProvenanceFooter
+)
+
+// ParseProvenancePosition parses "header" or "footer"
+func ParseProvenancePosition(s string) (ProvenancePosition, error) {
+	switch strings.ToLower(s) {
+	case "header":
+		return ProvenanceHeader, nil
+	case "footer":
+		return ProvenanceFooter, nil
+	default:
+		return ProvenanceNone, fmt.Errorf("unknown provenance position %q, expected header or footer", s)
+	}
+}
+
+// provenancePattern matches the comment Provenance.Comment writes, capturing its JSON
+var provenancePattern = regexp.MustCompile(`<!-- rprompt-provenance (\{.*?\}) -->`)
+
+// Provenance records where a rendered prompt came from, so files generated from it can be traced back to the
+// exact template and config
+type Provenance struct {
+	Template string `json:"template"`
+	// Version is the hash of the template and every template it includes
+	Version    string    `json:"version"`
+	RenderedAt time.Time `json:"rendered_at"`
+	// Config is the registry path of the config, empty when the build didn't load one
+	Config     string `json:"config,omitempty"`
+	ConfigHash string `json:"config_hash"`
+}
+
+// Comment returns the provenance as a single line HTML comment holding JSON, which ParseProvenance reads back
+func (p *Provenance) Comment() string {
+	// Provenance only holds strings and a time, which always encode
+	data, _ := json.Marshal(p)
+	return "<!-- rprompt-provenance " + string(data) + " -->"
+}
+
+// ParseProvenance reads the provenance comment from a generated file's content
+func ParseProvenance(content string) (*Provenance, error) {
+	m := provenancePattern.FindStringSubmatch(content)
+	if m == nil {
+		return nil, fmt.Errorf("no rprompt provenance comment found")
+	}
+	var p Provenance
+	if err := json.Unmarshal([]byte(m[1]), &p); err != nil {
+		return nil, fmt.Errorf("invalid provenance comment: %w", err)
+	}
+	return &p, nil
+}
+
+// WithProvenance writes a provenance comment, see Provenance, before or after the text output. Deterministic
+// builds record DeterministicTime as the render time, so their output stays the same.
+func WithProvenance(position ProvenancePosition) BuildOption {
+	return func(o *buildOptions) {
+		o.provenance = position
+	}
+}
+
+// provenance describes a build of a template whose dependencies are loaded with config
+func (s *PromptSystem) provenance(ctx context.Context, template *Template, config *Config, o *buildOptions) (*Provenance, error) {
+	version, err := s.templateHash(ctx, template)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := configHash(config)
+	if err != nil {
+		return nil, err
+	}
+	renderedAt := time.Now().UTC().Truncate(time.Second)
+	if o.deterministic {
+		renderedAt = DeterministicTime
+	}
+	configPath := config.Path
+	if paths, ok := s.Registry.(PathRegistry); ok && configPath != "" {
+		if rel, ok := paths.RelativePath(configPath); ok {
+			configPath = rel
+		}
+	}
+	return &Provenance{
+		Template:   template.Path,
+		Version:    version,
+		RenderedAt: renderedAt,
+		Config:     configPath,
+		ConfigHash: hash,
+	}, nil
+}
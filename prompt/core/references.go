@@ -0,0 +1,238 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// builtinFuncs are the functions text/template provides to every template
+var builtinFuncs = []string{
+	"and", "call", "html", "index", "slice", "js", "len", "not", "or", "print", "printf", "println", "urlquery",
+	"eq", "ge", "gt", "le", "lt", "ne",
+}
+
+// Validate checks the template without a config and without executing it: that the delimiters of its actions
+// balance, that every function it calls is a built in function or one of the registry's, see FuncRegistry, and
+// that every template it includes from another file resolves in the registry. It returns a *TemplateError for
+// each problem, in source order, and an error only when the registry can't be searched.
+//
+// Only the template's own source is checked, not that of the templates it includes.
+func (t *Template) Validate(ctx context.Context) ([]*TemplateError, error) {
+	if t.r == nil {
+		return nil, fmt.Errorf("no registry set for template %s", t.Path)
+	}
+	left, right := RegistryDelims(t.r)
+	body := t.Body()
+	problems := t.checkDelimiters(body, left, right)
+
+	trees := make(map[string]*parse.Tree)
+	tree := parse.New(t.Path)
+	tree.Mode = parse.SkipFuncCheck
+	if _, err := tree.Parse(body, left, right, trees); err != nil {
+		// An unbalanced action is already reported more clearly than text/template does
+		if len(problems) == 0 {
+			var located *TemplateError
+			if errors.As(t.locateError(err), &located) {
+				problems = append(problems, located)
+			} else {
+				return nil, err
+			}
+		}
+		return problems, nil
+	}
+
+	known := make(map[string]bool)
+	for _, name := range builtinFuncs {
+		known[name] = true
+	}
+	for name := range templateFuncs {
+		known[name] = true
+	}
+	if f, ok := t.r.(FuncRegistry); ok {
+		for name := range f.TemplateFuncs() {
+			known[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(trees))
+	for name := range trees {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	resolved := make(map[string]error)
+	for _, name := range names {
+		tree := trees[name]
+		var err error
+		inspectNodes(tree.Root, func(node parse.Node) {
+			if err != nil {
+				return
+			}
+			switch n := node.(type) {
+			case *parse.IdentifierNode:
+				if !known[n.Ident] {
+					problems = append(problems, t.problemAt(tree, n, fmt.Sprintf("function %q not defined", n.Ident)))
+				}
+			case *parse.TemplateNode:
+				if _, ok := trees[n.Name]; ok {
+					return
+				}
+				missing, ok := resolved[n.Name]
+				if !ok {
+					if missing, err = t.resolveReference(ctx, n.Name); err != nil {
+						return
+					}
+					resolved[n.Name] = missing
+				}
+				if missing != nil {
+					problems = append(problems, t.problemAt(tree, n, missing.Error()))
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Line != problems[j].Line {
+			return problems[i].Line < problems[j].Line
+		}
+		return problems[i].Column < problems[j].Column
+	})
+	return problems, nil
+}
+
+// resolveReference looks up a template named in a template action in the registry. It returns an error
+// describing the problem when no template has the name, and an error of its own when the registry fails.
+func (t *Template) resolveReference(ctx context.Context, name string) (missing error, err error) {
+	for _, candidate := range DependencyCandidates(name, RegistryExtensions(t.r)) {
+		if _, err := t.r.Find(ctx, candidate); err == nil {
+			return nil, nil
+		} else if !errors.Is(WrapNotFound(candidate, err), ErrTemplateNotFound) {
+			return nil, fmt.Errorf("error finding template %s: %w", candidate, err)
+		}
+	}
+	return fmt.Errorf("template %q not found in the registry", name), nil
+}
+
+// problemAt returns a *TemplateError at a node of one of the template's parse trees
+func (t *Template) problemAt(tree *parse.Tree, node parse.Node, message string) *TemplateError {
+	path, line, column := t.position(tree, node)
+	return NewTemplateError(path, line, column, message, snippet(LineAt(t.OriginalContent, line), line, column), nil)
+}
+
+// checkDelimiters reports actions that are never closed and right delimiters outside any action in body
+func (t *Template) checkDelimiters(body, left, right string) []*TemplateError {
+	var problems []*TemplateError
+	problem := func(offset int, message string) {
+		start := strings.LastIndex(body[:offset], "\n") + 1
+		sourceLine, line, column := sourcePosition(t.OriginalContent, strings.Count(body[:offset], "\n")+1, offset-start)
+		problems = append(problems, NewTemplateError(t.Path, line, column, message, snippet(sourceLine, line, column), nil))
+	}
+	for i := 0; i < len(body); {
+		open := strings.Index(body[i:], left)
+		closing := strings.Index(body[i:], right)
+		if closing >= 0 && (open < 0 || closing < open) {
+			problem(i+closing, fmt.Sprintf("unexpected %q outside an action", right))
+			i += closing + len(right)
+			continue
+		}
+		if open < 0 {
+			break
+		}
+		end, ok := actionEnd(body, i+open+len(left), right)
+		if !ok {
+			problem(i+open, fmt.Sprintf("unclosed action, missing %q", right))
+			break
+		}
+		i = end
+	}
+	return problems
+}
+
+// actionEnd returns the offset just past the right delimiter closing the action whose text starts at i, skipping
+// delimiters in strings, raw strings, characters and comments. It returns false if the action is never closed.
+func actionEnd(body string, i int, right string) (int, bool) {
+	for i < len(body) {
+		switch c := body[i]; {
+		case strings.HasPrefix(body[i:], right):
+			return i + len(right), true
+		case strings.HasPrefix(body[i:], "/*"):
+			end := strings.Index(body[i+2:], "*/")
+			if end < 0 {
+				return 0, false
+			}
+			i += 2 + end + 2
+		case c == '"' || c == '\'':
+			for i++; i < len(body) && body[i] != c && body[i] != '\n'; i++ {
+				if body[i] == '\\' {
+					i++
+				}
+			}
+			i++
+		case c == '`':
+			end := strings.IndexByte(body[i+1:], '`')
+			if end < 0 {
+				return 0, false
+			}
+			i += 1 + end + 1
+		default:
+			i++
+		}
+	}
+	return 0, false
+}
+
+// inspectNodes calls fn for node and every node below it, depth first in source order
+func inspectNodes(node parse.Node, fn func(parse.Node)) {
+	if node == nil {
+		return
+	}
+	fn(node)
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, item := range n.Nodes {
+			inspectNodes(item, fn)
+		}
+	case *parse.ActionNode:
+		inspectNodes(n.Pipe, fn)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, decl := range n.Decl {
+			inspectNodes(decl, fn)
+		}
+		for _, cmd := range n.Cmds {
+			inspectNodes(cmd, fn)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			inspectNodes(arg, fn)
+		}
+	case *parse.ChainNode:
+		inspectNodes(n.Node, fn)
+	case *parse.IfNode:
+		inspectBranch(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		inspectBranch(&n.BranchNode, fn)
+	case *parse.WithNode:
+		inspectBranch(&n.BranchNode, fn)
+	case *parse.TemplateNode:
+		inspectNodes(n.Pipe, fn)
+	}
+}
+
+func inspectBranch(n *parse.BranchNode, fn func(parse.Node)) {
+	inspectNodes(n.Pipe, fn)
+	inspectNodes(n.List, fn)
+	if n.ElseList != nil {
+		inspectNodes(n.ElseList, fn)
+	}
+}
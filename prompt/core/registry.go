@@ -0,0 +1,33 @@
+package core
+
+import (
+	"context"
+)
+
+type PromptRegistry interface {
+	Find(ctx context.Context, path string) (*Template, error)
+	LoadConfig(ctx context.Context, path string) (*Config, error)
+	SaveConfig(ctx context.Context, cfg *Config) error
+}
+
+// ChangeNotifier is implemented by registries that report when their templates change, so a PromptSystem can
+// drop stale parse trees from its cache
+type ChangeNotifier interface {
+	// OnChange registers fn to be called with the registry path of every template that changes
+	OnChange(fn func(path string))
+}
+
+// PathRegistry is implemented by registries whose files have paths outside of them, such as a directory's, so
+// a config given by its file path can be named by its path within the registry
+type PathRegistry interface {
+	// RelativePath returns the slash-separated path within the registry of the file at path, and false for
+	// files outside it
+	RelativePath(path string) (string, bool)
+}
+
+// RawConfigRegistry is implemented by registries that keep the JSON of their configs as it was written, so
+// exports can copy it with its key order, formatting and numbers instead of re-encoding the values
+type RawConfigRegistry interface {
+	// ReadConfig returns the JSON of the config at path as the registry stores it
+	ReadConfig(ctx context.Context, path string) ([]byte, error)
+}
@@ -0,0 +1,259 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// dependencyResolver loads every template a root template transitively includes into a new execution set.
+// It is the only place dependencies are resolved, so building, config generation, export and hashing all see
+// the same closure.
+type dependencyResolver struct {
+	root *Template
+	// set starts as a copy of the root's own template set and gains the parse trees of its dependencies
+	set *template.Template
+	// loaded maps the registry path of every template resolved so far to its template
+	loaded map[string]*Template
+	// stack holds the paths currently being resolved, innermost last, to detect cycles
+	stack []string
+	// full holds the paths of the templates whose every dependency has been resolved, rather than only those
+	// reachable with the root's lazy data
+	full map[string]bool
+	// variants maps the paths of dependencies to the paths of their variants for the root's locales, and
+	// found holds the variants' templates until they are loaded, see WithLocale
+	variants map[string]string
+	found    map[string]*Template
+}
+
+func newDependencyResolver(root *Template) *dependencyResolver {
+	return &dependencyResolver{
+		root:     root,
+		loaded:   make(map[string]*Template),
+		full:     make(map[string]bool),
+		variants: make(map[string]string),
+		found:    make(map[string]*Template),
+	}
+}
+
+// resolve loads the root's dependencies and returns the set it executes in
+func (r *dependencyResolver) resolve(ctx context.Context) (*executionSet, error) {
+	if err := r.root.Compile(); err != nil {
+		return nil, fmt.Errorf("error parsing template %s: %w", r.root.Path, err)
+	}
+	set, err := r.root.Tmpl.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("error copying template %s: %w", r.root.Path, err)
+	}
+	r.set = set
+	if err := r.visit(ctx, r.root, r.root.lazyData != nil); err != nil {
+		return nil, err
+	}
+
+	resolved := &executionSet{
+		tmpl:    r.set,
+		deps:    make([]string, 0, len(r.loaded)),
+		sources: make(map[string]string, len(r.loaded)),
+	}
+	for path, t := range r.loaded {
+		if path != r.root.Path {
+			resolved.deps = append(resolved.deps, path)
+			resolved.sources[path] = t.OriginalContent
+		}
+	}
+	sort.Strings(resolved.deps)
+	return resolved, nil
+}
+
+// visit resolves the dependencies of t depth first. When lazy, only the dependencies reachable with the
+// root's lazy data are resolved.
+func (r *dependencyResolver) visit(ctx context.Context, t *Template, lazy bool) error {
+	r.loaded[t.Path] = t
+	if !lazy {
+		r.full[t.Path] = true
+	}
+	r.stack = append(r.stack, t.Path)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+	// Parse the template if not already parsed
+	if err := t.Compile(); err != nil {
+		return fmt.Errorf("error parsing template %s: %w", t.Path, err)
+	}
+
+	deps := r.dependencies(t, lazy)
+	names := make([]string, len(deps))
+	for i, dep := range deps {
+		names[i] = dep.name
+	}
+	r.root.logger().Debug("found dependencies", "template", t.Path, "dependencies", names)
+
+	for _, dep := range deps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		depName := dep.name
+		depPath, err := r.path(ctx, depName)
+		if err != nil {
+			return err
+		}
+		depPath, err = r.variant(ctx, depPath)
+		if err != nil {
+			return err
+		}
+
+		for i, path := range r.stack {
+			if path == depPath {
+				cycle := append(append([]string{}, r.stack[i:]...), depPath)
+				return NewDependencyCycleError(cycle)
+			}
+		}
+		if limit := r.root.limits.MaxIncludeDepth; limit > 0 && len(r.stack) > limit {
+			return NewIncludeDepthError(limit, append(append([]string{}, r.stack...), depPath))
+		}
+
+		depTemplate, ok := r.loaded[depPath]
+		if !ok {
+			var err error
+			if depTemplate, err = r.load(ctx, depPath); err != nil {
+				return err
+			}
+		}
+
+		// The same file may be included under different names, e.g. "header" and "header.tmpl"
+		if r.set.Lookup(depName) == nil {
+			r.root.logger().Debug("adding parse tree", "dependency", depName, "root", r.root.Path)
+			if _, err := r.set.AddParseTree(depName, depTemplate.Tmpl.Tree); err != nil {
+				return fmt.Errorf("error adding template %s to set: %w", depName, err)
+			}
+		}
+		// Templates already resolved lazily are resolved again in full when included without the root data
+		if ok && (dep.rootData || r.full[depPath]) {
+			continue
+		}
+
+		// Along with any defines and blocks it declares
+		if !ok {
+			for name := range depTemplate.defines {
+				if _, err := r.set.AddParseTree(name, depTemplate.Tmpl.Lookup(name).Tree); err != nil {
+					return fmt.Errorf("error adding template %s to set: %w", name, err)
+				}
+			}
+		}
+
+		if err := r.visit(ctx, depTemplate, dep.rootData); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dependencies returns the templates t includes from other files. When lazy, only those reachable with the
+// root's lazy data are returned, and each reports whether it is passed that data; otherwise every dependency
+// is returned and none is.
+func (r *dependencyResolver) dependencies(t *Template, lazy bool) []lazyDependency {
+	if lazy {
+		return t.reachableDependencies(r.root.lazyData)
+	}
+	deps := []lazyDependency{}
+	for _, name := range t.FileDependencies() {
+		deps = append(deps, lazyDependency{name: name})
+	}
+	return deps
+}
+
+// path returns the registry path of a dependency named in a template action. A name without an accepted
+// extension refers to the first file the registry has with one of them added, so with .tmpl and .prompt
+// accepted "header" finds header.prompt when there is no header.tmpl.
+func (r *dependencyResolver) path(ctx context.Context, name string) (string, error) {
+	candidates := DependencyCandidates(name, RegistryExtensions(r.root.r))
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+	for _, candidate := range candidates {
+		if _, ok := r.loaded[candidate]; ok {
+			return candidate, nil
+		}
+		if _, ok := r.found[candidate]; ok {
+			return candidate, nil
+		}
+		ctx, span := startSpan(ctx, r.root.tracer(), "Find", candidate)
+		t, err := r.root.r.Find(ctx, candidate)
+		endSpan(span, err)
+		if err == nil {
+			r.found[candidate] = t
+			return candidate, nil
+		}
+		if !errors.Is(WrapNotFound(candidate, err), ErrTemplateNotFound) {
+			return "", fmt.Errorf("error finding template %s: %w", candidate, err)
+		}
+	}
+	// None exists, so loading the first reports it missing
+	return candidates[0], nil
+}
+
+// variant returns the path of the variant of a dependency for the first of the root's locales that has one,
+// or else the dependency's own path
+func (r *dependencyResolver) variant(ctx context.Context, path string) (string, error) {
+	if len(r.root.locales) == 0 {
+		return path, nil
+	}
+	if variant, ok := r.variants[path]; ok {
+		return variant, nil
+	}
+	for _, locale := range r.root.locales {
+		candidate := localizedPath(path, locale)
+		if _, ok := r.loaded[candidate]; ok {
+			r.variants[path] = candidate
+			return candidate, nil
+		}
+		ctx, span := startSpan(ctx, r.root.tracer(), "Find", candidate)
+		t, err := r.root.r.Find(ctx, candidate)
+		endSpan(span, err)
+		if err == nil {
+			r.variants[path], r.found[candidate] = candidate, t
+			return candidate, nil
+		}
+		if !errors.Is(WrapNotFound(candidate, err), ErrTemplateNotFound) {
+			return "", fmt.Errorf("error finding template %s: %w", candidate, err)
+		}
+	}
+	r.variants[path] = path
+	return path, nil
+}
+
+// load finds and parses a dependency, sharing the root's registry, parse cache, logger, tracer and metrics
+func (r *dependencyResolver) load(ctx context.Context, path string) (*Template, error) {
+	depTemplate, ok := r.found[path]
+	if ok {
+		delete(r.found, path)
+	} else {
+		var err error
+		ctx, span := startSpan(ctx, r.root.tracer(), "Find", path)
+		depTemplate, err = r.root.r.Find(ctx, path)
+		endSpan(span, err)
+		if err != nil {
+			return nil, fmt.Errorf("error finding template %s: %w", path, WrapNotFound(path, err))
+		}
+	}
+	depTemplate.cache = r.root.cache
+	depTemplate.Logger = r.root.Logger
+	depTemplate.Tracer = r.root.Tracer
+	depTemplate.metrics = r.root.metrics
+	if err := depTemplate.Compile(); err != nil {
+		return nil, fmt.Errorf("error parsing dependent template %s: %w", path, err)
+	}
+	return depTemplate, nil
+}
+
+// DependencyPath returns the registry path for a name used in a template action, adding the .tmpl
+// extension the registry requires when it's missing. Registries accepting other extensions resolve names
+// with dependencyResolver.path instead.
+func DependencyPath(name string) string {
+	if !strings.HasSuffix(name, DefaultTemplateExtension) {
+		return name + DefaultTemplateExtension
+	}
+	return name
+}
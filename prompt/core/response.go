@@ -0,0 +1,202 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ResponseFormatFormat is the section WithResponseFormat appends to a prompt, filled with the indented schema
+const ResponseFormatFormat = "\n\nRespond only with JSON matching this schema:\n%s\n"
+
+// WithResponseFormat appends a section asking for a reply matching the template's response schema, see
+// FrontMatter. Templates without a response schema are built unchanged.
+func WithResponseFormat() BuildOption {
+	return func(o *buildOptions) {
+		o.responseFormat = true
+	}
+}
+
+// responseFormatSection returns the section WithResponseFormat appends for a template, or an empty string if
+// it declares no response schema
+func responseFormatSection(t *Template) (string, error) {
+	fm, err := t.FrontMatter()
+	if err != nil || fm.ResponseSchema == nil {
+		return "", err
+	}
+	schema, err := json.MarshalIndent(fm.ResponseSchema, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(ResponseFormatFormat, schema), nil
+}
+
+// ResponseSchema returns the response schema a template declares in its front matter, or nil if it has none
+func (s *PromptSystem) ResponseSchema(ctx context.Context, templatePath string) (map[string]any, error) {
+	template, err := s.find(ctx, templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("err finding template: %w", err)
+	}
+	fm, err := template.FrontMatter()
+	if err != nil {
+		return nil, err
+	}
+	return fm.ResponseSchema, nil
+}
+
+// ValidateResponse checks a reply against a response schema, returning a *ResponseFormatError describing
+// every mismatch. The reply may be wrapped in a ```json code fence, as models often do. The keywords type,
+// properties, required, additionalProperties, items and enum are checked; any others are ignored.
+func ValidateResponse(schema map[string]any, reply string) error {
+	var value any
+	if err := json.Unmarshal([]byte(unfence(reply)), &value); err != nil {
+		return NewResponseFormatError([]string{fmt.Sprintf("reply is not JSON: %v", err)})
+	}
+	var problems []string
+	checkSchema(schema, value, "$", &problems)
+	if len(problems) > 0 {
+		return NewResponseFormatError(problems)
+	}
+	return nil
+}
+
+// unfence strips a markdown code fence around text
+func unfence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	_, inner, ok := strings.Cut(text, "\n")
+	if !ok {
+		return text
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(inner), "```"))
+}
+
+// checkSchema appends a problem for each way value fails schema, naming values by their path from the root $
+func checkSchema(schema map[string]any, value any, path string, problems *[]string) {
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !matchesAnyType(types, value) {
+		*problems = append(*problems, fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonType(value)))
+		return
+	}
+	if enum, ok := schema["enum"].([]any); ok && !containsValue(enum, value) {
+		*problems = append(*problems, fmt.Sprintf("%s: %v is not one of the allowed values", path, value))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		if required, ok := schema["required"].([]any); ok {
+			for _, name := range required {
+				if key, ok := name.(string); ok {
+					if _, present := v[key]; !present {
+						*problems = append(*problems, fmt.Sprintf("%s.%s: required", path, key))
+					}
+				}
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if property, ok := properties[key].(map[string]any); ok {
+				checkSchema(property, v[key], path+"."+key, problems)
+				continue
+			}
+			if _, declared := properties[key]; declared {
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					*problems = append(*problems, fmt.Sprintf("%s.%s: not allowed", path, key))
+				}
+			case map[string]any:
+				checkSchema(additional, v[key], path+"."+key, problems)
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				checkSchema(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	}
+}
+
+func schemaTypes(t any) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, name := range t {
+			if s, ok := name.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func matchesAnyType(types []string, value any) bool {
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType names the JSON schema type of a decoded JSON value
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// containsValue reports whether enum holds value, comparing numbers by value since the schema comes from
+// YAML and the reply from JSON
+func containsValue(enum []any, value any) bool {
+	for _, allowed := range enum {
+		if reflect.DeepEqual(normalizeNumber(allowed), normalizeNumber(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeNumber(v any) any {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	}
+	return v
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errNoContextProvider is returned by [[retrieve]] when the system has no ContextProvider
+var errNoContextProvider = errors.New("retrieve needs a context provider, see PromptSystem.Retriever")
+
+// Document is a piece of context a ContextProvider retrieved
+type Document struct {
+	// Source identifies where the document came from, such as a file path or URL
+	Source  string `json:"source"`
+	Content string `json:"content"`
+	// Score is how relevant the provider judged the document to the query, higher is more relevant
+	Score float64 `json:"score,omitempty"`
+}
+
+// Documents are the result of [[retrieve]]. Printed directly they are their contents separated by blank
+// lines, and they can be ranged over to lay each document out, e.g. with its source.
+type Documents []Document
+
+func (d Documents) String() string {
+	contents := make([]string, len(d))
+	for i, doc := range d {
+		contents[i] = doc.Content
+	}
+	return strings.Join(contents, "\n\n")
+}
+
+// ContextProvider retrieves the documents most relevant to a query, so templates can assemble retrieved
+// context with [[retrieve "billing docs" 3]]. Providers are set on PromptSystem.Retriever; any retrieval
+// backend, such as a vector database client, can be adapted with ContextProviderFunc.
+type ContextProvider interface {
+	// Fetch returns at most k documents for query, most relevant first
+	Fetch(query string, k int) ([]Document, error)
+}
+
+// ContextProviderFunc adapts a function to a ContextProvider
+type ContextProviderFunc func(query string, k int) ([]Document, error)
+
+// Fetch calls f
+func (f ContextProviderFunc) Fetch(query string, k int) ([]Document, error) {
+	return f(query, k)
+}
+
+// retrieve is the template function behind [[retrieve "billing docs" 3]]. Builds of a system with a
+// Retriever replace it with retrieveFunc.
+func retrieve(query string, k int) (Documents, error) {
+	return nil, errNoContextProvider
+}
+
+// retrieveFunc returns the retrieve function of an execution, fetching from provider while ctx is live
+func retrieveFunc(ctx context.Context, provider ContextProvider) func(string, int) (Documents, error) {
+	return func(query string, k int) (Documents, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if k <= 0 {
+			return nil, fmt.Errorf("retrieve %q: the number of documents must be positive, got %d", query, k)
+		}
+		docs, err := provider.Fetch(query, k)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve %q: %w", query, err)
+		}
+		if len(docs) > k {
+			docs = docs[:k]
+		}
+		// Retrieved text is data like the config, so it mustn't forge markers either, see sanitizeData
+		sanitized := make(Documents, len(docs))
+		for i, doc := range docs {
+			doc.Source = sanitizeData(doc.Source).(string)
+			doc.Content = sanitizeData(doc.Content).(string)
+			sanitized[i] = doc
+		}
+		return sanitized, nil
+	}
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetrieve(t *testing.T) {
+	registry := newTestRegistry(t, map[string]string{
+		"main.tmpl": "[[retrieve .query 2]]",
+		"sources.tmpl": `[[range retrieve "billing" 5]]- [[.Source]] ([[.Score]])
+[[end]]`,
+	})
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = system.Build(ctx, "main.tmpl", "", WithValue("query", "billing"))
+	assert.ErrorIs(t, err, errNoContextProvider)
+
+	var queries []string
+	system.Retriever = ContextProviderFunc(func(query string, k int) ([]Document, error) {
+		queries = append(queries, query)
+		return []Document{
+			{Source: "a.md", Content: "Invoices are sent monthly.", Score: 0.9},
+			{Source: "b.md", Content: "Refunds take 5 days.", Score: 0.5},
+			{Source: "c.md", Content: "Not returned, beyond k.", Score: 0.1},
+		}, nil
+	})
+	output, err := system.Build(ctx, "main.tmpl", "", WithValue("query", "billing docs"))
+	require.NoError(t, err)
+	assert.Equal(t, "Invoices are sent monthly.\n\nRefunds take 5 days.", output, "at most k documents, separated by blank lines")
+	assert.Equal(t, []string{"billing docs"}, queries)
+
+	output, err = system.Build(ctx, "sources.tmpl", "")
+	require.NoError(t, err)
+	assert.Equal(t, "- a.md (0.9)\n- b.md (0.5)\n- c.md (0.1)\n", output)
+
+	system.Retriever = ContextProviderFunc(func(string, int) ([]Document, error) {
+		return nil, errors.New("index unavailable")
+	})
+	_, err = system.Build(ctx, "main.tmpl", "", WithValue("query", "billing"))
+	assert.ErrorContains(t, err, `failed to retrieve "billing": index unavailable`)
+}
@@ -0,0 +1,367 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template/parse"
+	"unicode/utf8"
+)
+
+// JSONSchema is the subset of JSON Schema used to describe a template's variables
+type JSONSchema struct {
+	Type                 string                 `json:"type"`
+	Description          string                 `json:"description,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+}
+
+// ToolDefinition is an OpenAI tool, as passed in the tools of a chat completion request
+type ToolDefinition struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction describes the function a tool calls
+type ToolFunction struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Parameters  *JSONSchema `json:"parameters"`
+}
+
+// Schema infers a JSON schema for the config a template takes from how its variables are used: ranged
+// over values are arrays, values with fields are objects, values compared to numbers are numbers, values
+// only tested in conditions are booleans and anything printed is a string. Values tested by if or with are
+// optional, every other value is required.
+func (t *Template) Schema(ctx context.Context) (*JSONSchema, error) {
+	if err := t.LoadDependencies(ctx); err != nil {
+		return nil, err
+	}
+	// The config itself is always an object
+	root := &schemaNode{props: map[string]*schemaNode{}}
+	w := &schemaWalker{t: t, vars: map[string]*schemaNode{"$": root}}
+	w.list(t.Tmpl.Tree.Root, root)
+	return root.schema(), nil
+}
+
+// ToolSchema describes a template's config as an OpenAI function tool, so the arguments a model calls the
+// tool with can be used directly as the config. The tool is named after the template path, and described by
+// a comment at the start of the template if there is one.
+func (s *PromptSystem) ToolSchema(ctx context.Context, templatePath string) (*ToolDefinition, error) {
+	template, err := s.find(ctx, templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("err finding template: %w", err)
+	}
+	parameters, err := template.Schema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &ToolDefinition{
+		Type: "function",
+		Function: ToolFunction{
+			Name:        toolName(templatePath),
+			Description: leadingComment(template),
+			Parameters:  parameters,
+		},
+	}, nil
+}
+
+var toolNamePattern = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// toolName turns a template path into a valid function name, e.g. support/reply.tmpl -> support_reply
+func toolName(templatePath string) string {
+	return strings.Trim(toolNamePattern.ReplaceAllString(strings.TrimSuffix(templatePath, ".tmpl"), "_"), "_")
+}
+
+var leadingCommentPattern = regexp.MustCompile(`^\s*\[\[-?\s*/\*([\s\S]*?)\*/\s*-?\]\]`)
+
+// leadingComment returns the text of a comment at the very start of a template
+func leadingComment(t *Template) string {
+	m := leadingCommentPattern.FindStringSubmatch(t.Body())
+	if m == nil {
+		return ""
+	}
+	return strings.TrimSpace(m[1])
+}
+
+// schemaNode collects how a value is used while walking a template
+type schemaNode struct {
+	props map[string]*schemaNode
+	items *schemaNode
+	// kind is the JSON type the value was used as, empty when only printed or tested
+	kind string
+	// used is set when the value is needed for more than a condition
+	used bool
+	// tested is set when the template checks whether the value is set, making it optional
+	tested bool
+	// uses are where the template refers to the value, recorded when walking for Variables
+	uses []VariableUse
+}
+
+func (n *schemaNode) field(name string) *schemaNode {
+	if n.props == nil {
+		n.props = make(map[string]*schemaNode)
+	}
+	if n.props[name] == nil {
+		n.props[name] = &schemaNode{}
+	}
+	return n.props[name]
+}
+
+func (n *schemaNode) elem() *schemaNode {
+	if n.items == nil {
+		n.items = &schemaNode{}
+	}
+	n.kind = "array"
+	return n.items
+}
+
+func (n *schemaNode) required() bool {
+	return !n.tested && (n.used || n.props != nil || n.items != nil)
+}
+
+func (n *schemaNode) schema() *JSONSchema {
+	switch {
+	case n.props != nil:
+		closed := false
+		s := &JSONSchema{Type: "object", Properties: map[string]*JSONSchema{}, AdditionalProperties: &closed}
+		for name, prop := range n.props {
+			s.Properties[name] = prop.schema()
+			if prop.required() {
+				s.Required = append(s.Required, name)
+			}
+		}
+		sort.Strings(s.Required)
+		return s
+	case n.items != nil:
+		return &JSONSchema{Type: "array", Items: n.items.schema()}
+	case n.kind != "":
+		return &JSONSchema{Type: n.kind}
+	case !n.used:
+		return &JSONSchema{Type: "boolean"}
+	}
+	return &JSONSchema{Type: "string"}
+}
+
+// schemaWalker walks a template's parse tree recording how each variable is used
+type schemaWalker struct {
+	t *Template
+	// vars maps template variables to the values they hold
+	vars map[string]*schemaNode
+	// includes counts the templates being walked into, bounding recursive defines
+	includes int
+	// track records the position of every use in tree, the parse tree being walked, and whether it is inside
+	// conditional if or with blocks
+	track       bool
+	tree        *parse.Tree
+	conditional int
+}
+
+func (w *schemaWalker) list(list *parse.ListNode, dot *schemaNode) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		w.node(node, dot)
+	}
+}
+
+func (w *schemaWalker) node(node parse.Node, dot *schemaNode) {
+	switch n := node.(type) {
+	case *parse.ActionNode:
+		if value := w.pipe(n.Pipe, dot); value != nil {
+			value.used = true
+		}
+	case *parse.IfNode:
+		w.conditional++
+		defer func() { w.conditional-- }()
+		if value := w.pipe(n.Pipe, dot); value != nil {
+			value.tested = true
+		}
+		w.list(n.List, dot)
+		w.list(n.ElseList, dot)
+	case *parse.WithNode:
+		w.conditional++
+		defer func() { w.conditional-- }()
+		inner := w.pipe(n.Pipe, dot)
+		if inner == nil {
+			inner = &schemaNode{}
+		}
+		inner.tested = true
+		w.list(n.List, inner)
+		w.list(n.ElseList, dot)
+	case *parse.RangeNode:
+		value := w.pipe(n.Pipe, dot)
+		elem := &schemaNode{}
+		if value != nil {
+			value.used = true
+			elem = value.elem()
+		}
+		if decl := n.Pipe.Decl; len(decl) > 0 {
+			w.vars[decl[len(decl)-1].Ident[0]] = elem
+		}
+		w.list(n.List, elem)
+		w.list(n.ElseList, dot)
+	case *parse.TemplateNode:
+		included := w.t.templates().Lookup(n.Name)
+		if included == nil || included.Tree == nil || w.includes >= maxConvertDepth {
+			return
+		}
+		inner := &schemaNode{}
+		if n.Pipe != nil {
+			if value := w.pipe(n.Pipe, dot); value != nil {
+				inner = value
+			}
+		}
+		w.includes++
+		tree := w.tree
+		w.tree = included.Tree
+		w.list(included.Tree.Root, inner)
+		w.tree = tree
+		w.includes--
+	}
+}
+
+// pipe records the uses in a pipeline and returns the value it evaluates to, or nil when it's computed
+func (w *schemaWalker) pipe(pipe *parse.PipeNode, dot *schemaNode) *schemaNode {
+	if pipe == nil {
+		return nil
+	}
+	var result *schemaNode
+	for i, cmd := range pipe.Cmds {
+		args := make([]*schemaNode, 0, len(cmd.Args))
+		for _, arg := range cmd.Args[1:] {
+			args = append(args, w.arg(arg, dot))
+		}
+		if i > 0 {
+			args = append(args, result)
+		}
+
+		ident, isFunc := cmd.Args[0].(*parse.IdentifierNode)
+		if !isFunc {
+			result = w.arg(cmd.Args[0], dot)
+			continue
+		}
+		w.call(ident.Ident, cmd.Args[1:], args)
+		result = nil
+	}
+	for _, decl := range pipe.Decl {
+		w.vars[decl.Ident[0]] = result
+	}
+	return result
+}
+
+// call records how a function uses its arguments
+func (w *schemaWalker) call(name string, nodes []parse.Node, args []*schemaNode) {
+	switch name {
+	case "eq", "ne", "lt", "le", "gt", "ge":
+		// Comparing to a literal gives the value its type
+		kind := ""
+		for _, node := range nodes {
+			switch node.(type) {
+			case *parse.NumberNode:
+				kind = "number"
+			case *parse.StringNode:
+				kind = "string"
+			case *parse.BoolNode:
+				kind = "boolean"
+			}
+		}
+		for _, arg := range args {
+			if arg != nil {
+				arg.used = true
+				if arg.kind == "" && arg.props == nil && arg.items == nil {
+					arg.kind = kind
+				}
+			}
+		}
+	case "len":
+		if len(args) == 1 && args[0] != nil && args[0].props == nil {
+			args[0].used = true
+			args[0].elem()
+		}
+	case "index":
+		if len(args) > 1 && args[0] != nil {
+			args[0].used = true
+			if key, ok := nodes[len(nodes)-1].(*parse.StringNode); ok && len(nodes) == 2 {
+				args[0].field(key.Text).used = true
+			} else {
+				args[0].elem().used = true
+			}
+		}
+	case "and", "or", "not":
+	default:
+		for _, arg := range args {
+			if arg != nil {
+				arg.used = true
+			}
+		}
+	}
+}
+
+func (w *schemaWalker) arg(node parse.Node, dot *schemaNode) *schemaNode {
+	switch n := node.(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.FieldNode:
+		return w.use(node, fieldPath(dot, n.Ident))
+	case *parse.VariableNode:
+		base, ok := w.vars[n.Ident[0]]
+		if !ok || base == nil {
+			return nil
+		}
+		if len(n.Ident) == 1 {
+			return base
+		}
+		return w.use(node, fieldPath(base, n.Ident[1:]))
+	case *parse.ChainNode:
+		base := w.arg(n.Node, dot)
+		if base == nil {
+			return nil
+		}
+		return w.use(node, fieldPath(base, n.Field))
+	case *parse.PipeNode:
+		return w.pipe(n, dot)
+	}
+	return nil
+}
+
+// use records node as a use of value when tracking uses, and returns value
+func (w *schemaWalker) use(node parse.Node, value *schemaNode) *schemaNode {
+	if !w.track || w.tree == nil {
+		return value
+	}
+	path, line, column := w.t.position(w.tree, node)
+	// text/template positions fields with several parts, such as .user.name, at their last part
+	var idents []string
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		idents = n.Ident
+	case *parse.VariableNode:
+		idents = n.Ident
+	}
+	if len(idents) > 1 && column > 0 {
+		column -= utf8.RuneCountInString(node.String()) - utf8.RuneCountInString(idents[len(idents)-1]) - 1
+	}
+	use := VariableUse{Template: path, Line: line, Column: column, Conditional: w.conditional > 0}
+	for i, existing := range value.uses {
+		if existing.Template == use.Template && existing.Line == use.Line && existing.Column == use.Column {
+			// The same source is walked once per include, and is conditional only if every include is
+			value.uses[i].Conditional = existing.Conditional && use.Conditional
+			return value
+		}
+	}
+	value.uses = append(value.uses, use)
+	return value
+}
+
+func fieldPath(base *schemaNode, idents []string) *schemaNode {
+	for _, ident := range idents {
+		base = base.field(ident)
+	}
+	return base
+}
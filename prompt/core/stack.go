@@ -0,0 +1,46 @@
+package core
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// StackPrefix starts the path of a stack, see PromptSystem.Stacks. Registry templates always end in .tmpl and
+// stack paths never do, so a stack can't hide a template.
+const StackPrefix = "stacks/"
+
+// stackSeparator is written between the outputs of a stack's templates
+const stackSeparator = "\n\n"
+
+// StackPath returns the path that builds the named stack
+func StackPath(name string) string {
+	return StackPrefix + name
+}
+
+// stackName returns the name of the stack path names, if it names one
+func stackName(path string) (string, bool) {
+	if strings.HasSuffix(path, ".tmpl") {
+		return "", false
+	}
+	return strings.CutPrefix(path, StackPrefix)
+}
+
+// stackTemplate returns a template rendering the named stack's templates in order, a blank line apart, as one
+// prompt. The templates are its dependencies, so the stack is validated, budgeted and hashed as a whole.
+func (s *PromptSystem) stackTemplate(name string) (*Template, error) {
+	templates, ok := s.Stacks[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown stack %q: %w", name, fs.ErrNotExist)
+	}
+	if len(templates) == 0 {
+		return nil, fmt.Errorf("stack %s has no templates", name)
+	}
+	left, right := RegistryDelims(s.Registry)
+	includes := make([]string, len(templates))
+	for i, template := range templates {
+		includes[i] = left + "template " + strconv.Quote(DependencyPath(template)) + " ." + right
+	}
+	return NewTemplate(StackPath(name), strings.Join(includes, stackSeparator), s.Registry), nil
+}
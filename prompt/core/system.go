@@ -0,0 +1,707 @@
+// Package core is the prompt engine: it parses templates, resolves their includes and builds prompts from
+// configs, reading both from a PromptRegistry such as a MemoryRegistry. It never touches the file system,
+// processes or the network, so it builds for GOOS=js and wasip1. Package prompt re-exports it and adds the
+// directory registry, the CLI, the servers and plugins.
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/notzree/rprompt/v2/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PromptSystem builds prompts from a registry. It is safe for concurrent use: every build works on its own
+// template set, and parse trees are shared through a process-wide LRU cache keyed by template path and content
+// hash.
+type PromptSystem struct {
+	Registry PromptRegistry
+	// Validation controls how configs are checked against templates before rendering
+	Validation ValidationMode
+	// Limits bounds the resources each build may use
+	Limits Limits
+	// Logger receives debug output from the templates the system builds. Nil discards it.
+	Logger *slog.Logger
+	// TracerProvider creates the OpenTelemetry tracer for spans around registry lookups, dependency
+	// resolution, template execution and whole builds. Nil disables tracing.
+	TracerProvider trace.TracerProvider
+	// Metrics records renders, parse cache lookups, errors, latency and output sizes. Nil disables metrics.
+	Metrics MetricsRecorder
+	// Audit records every rendered prompt, see AuditRecord. Nil disables auditing.
+	Audit AuditSink
+	// Usage notes which templates are rendered and when, see UsageRecord. Nil disables usage tracking.
+	Usage UsageSink
+	// DefaultLocale is the locale builds fall back to when the build's locale has no variant of a template, see
+	// WithLocale. Empty falls back to the unsuffixed template directly.
+	DefaultLocale string
+	// Retriever fetches the documents templates ask for with [[retrieve "billing docs" 3]]. Nil makes retrieve
+	// fail the build.
+	Retriever ContextProvider
+	// Flags decides which feature flags are on for templates that check them with [[if flag "new-tone"]]. Nil
+	// turns every flag off.
+	Flags FlagsProvider
+	// Stacks name ordered lists of templates, such as persona, task, constraints and examples, that build as one
+	// prompt wherever a template path is accepted, at StackPath(name)
+	Stacks map[string][]string
+	// PostProcess lists the post-processors applied to the output of every build, after those in the template's
+	// front matter, see PostDedent
+	PostProcess []string
+	// PII scans config values for likely personal data, such as email addresses, before rendering, to warn about
+	// it, mask it or block the build. The zero value doesn't scan.
+	PII PIIMode
+
+	cache      *parseCache
+	mu         sync.RWMutex
+	middleware []Middleware
+}
+
+type TemplateConfigPair struct {
+	// Template is a pointer since templates must not be copied once used
+	Template *Template
+	Config   Config
+}
+
+func NewTemplateConfigPair(t *Template, c Config) *TemplateConfigPair {
+	return &TemplateConfigPair{
+		Template: t,
+		Config:   c,
+	}
+}
+
+type PromptBuilder struct {
+	BusinessId     string
+	ParentTemplate *Template
+	// Deprecated: TemplateDeps is never populated, use ParentTemplate.Dependencies instead
+	TemplateDeps []Template
+	Config       *Config
+	System       *PromptSystem
+	validation   ValidationMode
+	options      *buildOptions
+	// resolved is shared with clones so dependencies are only resolved once
+	resolved *resolvedTemplate
+}
+
+// resolvedTemplate caches the dependency resolution from a builder's first build
+type resolvedTemplate struct {
+	mu       sync.Mutex
+	required *Config
+}
+
+// NewBuilder finds a template and loads a config, returning a builder that renders them.
+// configPath may be empty when all data is provided through WithData or WithValue.
+func (s *PromptSystem) NewBuilder(ctx context.Context, templatePath, configPath string, opts ...BuildOption) (*PromptBuilder, error) {
+	o := newBuildOptions(opts)
+	template, err := s.findLocalized(ctx, templatePath, o)
+	if err != nil {
+		return nil, fmt.Errorf("err finding template: %w", err)
+	}
+	template.limits = o.limitsFor(s.Limits)
+	template.deterministic = o.deterministic
+	template.history = o.history
+	template.target = o.target
+	template.coverage = o.coverage
+	config, err := s.loadConfig(ctx, configPath, o)
+	if err != nil {
+		return nil, err
+	}
+	return &PromptBuilder{
+		ParentTemplate: template,
+		Config:         config,
+		System:         s,
+		validation:     o.validationMode(s.Validation),
+		options:        o,
+		resolved:       &resolvedTemplate{},
+	}, nil
+}
+
+// Clone returns a copy of the builder sharing its template and resolved dependencies. The clone's Config can
+// be replaced without affecting b, and clones can be built concurrently, so a builder set up once can serve
+// request-scoped renders without repeating registry lookups.
+func (b *PromptBuilder) Clone() *PromptBuilder {
+	if b.resolved == nil {
+		b.resolved = &resolvedTemplate{}
+	}
+	clone := *b
+	return &clone
+}
+
+// config returns the config to build with, after applying the system's PII mode to the builder's config
+func (b *PromptBuilder) config() (*Config, error) {
+	if b.System == nil {
+		return b.Config, nil
+	}
+	config, pii, err := b.System.screenPII(b.Config)
+	if err != nil {
+		return nil, err
+	}
+	b.System.logPII(b.ParentTemplate, pii)
+	return config, nil
+}
+
+// buildOptions returns the options the builder was created with
+func (b *PromptBuilder) buildOptions() *buildOptions {
+	if b.options == nil {
+		// Builders created as struct literals have no options
+		return newBuildOptions(nil)
+	}
+	return b.options
+}
+
+// resolve loads the template's dependencies and the variables it requires on the first build, reusing them
+// for every later build of this builder and its clones
+func (b *PromptBuilder) resolve(ctx context.Context) (*Config, error) {
+	if b.resolved == nil {
+		// Builders created as struct literals resolve on every build
+		return b.ParentTemplate.GenerateConfig(ctx, "")
+	}
+	b.resolved.mu.Lock()
+	defer b.resolved.mu.Unlock()
+	if b.resolved.required != nil {
+		return b.resolved.required, nil
+	}
+	required, err := b.ParentTemplate.GenerateConfig(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	b.ParentTemplate.pinned = true
+	b.resolved.required = required
+	return required, nil
+}
+
+// responseFormat returns the section to append when the builder was created WithResponseFormat
+func (b *PromptBuilder) responseFormat() (string, error) {
+	if !b.buildOptions().responseFormat {
+		return "", nil
+	}
+	return responseFormatSection(b.ParentTemplate)
+}
+
+// Build renders the builder's template with its config
+func (b *PromptBuilder) Build(ctx context.Context) (string, error) {
+	var builder strings.Builder
+	if err := b.BuildTo(ctx, &builder); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// BuildTo renders the builder's template with its config directly into w.
+// The config is validated before anything is written. When the system has middleware the output is rendered
+// through the chain first, since middleware may rewrite it. Builders are reusable: Config may be replaced
+// between builds, and the template's dependencies are only resolved by the first one.
+func (b *PromptBuilder) BuildTo(ctx context.Context, w io.Writer) (err error) {
+	if b.System != nil {
+		start := time.Now()
+		defer func() {
+			if b.System.Metrics != nil {
+				b.System.Metrics.ObserveBuild(b.ParentTemplate.Path, start, err)
+			}
+		}()
+	}
+	requiredConfig, err := b.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	config, err := b.config()
+	if err != nil {
+		return err
+	}
+	o := b.buildOptions()
+	if err := o.checkDrafts(b.ParentTemplate); err != nil {
+		return err
+	}
+	if _, err := validate(b.validation, b.ParentTemplate, requiredConfig, config); err != nil {
+		return err
+	}
+	// Deferred first so it runs last, once the audit record is written
+	if b.System != nil {
+		defer func() {
+			if err == nil {
+				b.System.recordUsage(ctx, b.ParentTemplate)
+			}
+		}()
+	}
+	if b.System != nil && b.System.Audit != nil {
+		counter := &countingWriter{w: w}
+		w = counter
+		defer func() {
+			if err == nil {
+				err = b.System.audit(ctx, b.ParentTemplate, config, o, int(counter.n))
+			}
+		}()
+	}
+	section, err := b.responseFormat()
+	if err != nil {
+		return err
+	}
+	steps, err := b.postProcessors()
+	if err != nil {
+		return err
+	}
+	var provenance *Provenance
+	if o.provenance != ProvenanceNone && b.System != nil {
+		if provenance, err = b.System.provenance(ctx, b.ParentTemplate, config, o); err != nil {
+			return err
+		}
+		if o.provenance == ProvenanceHeader {
+			if _, err := io.WriteString(w, provenance.Comment()+"\n"); err != nil {
+				return err
+			}
+		}
+	}
+	whole := o.maxTokens > 0 || len(steps) > 0
+	switch {
+	case whole:
+		// The whole output is needed to fit it to the budget or post-process it
+		raw, err := b.renderRaw(ctx, config)
+		if err != nil {
+			return err
+		}
+		output, _, err := o.fit(raw + section)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, postProcess(stripMarkers(output), steps)); err != nil {
+			return err
+		}
+	case b.System == nil || !b.System.hasMiddleware():
+		if err := b.ParentTemplate.BuildTo(ctx, w, *config); err != nil {
+			return err
+		}
+	default:
+		output, err := b.System.renderer()(ctx, b.ParentTemplate, config)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, output); err != nil {
+			return err
+		}
+	}
+	if !whole && section != "" {
+		if _, err := io.WriteString(w, section); err != nil {
+			return err
+		}
+	}
+
+	if o.hashFooter && b.System != nil {
+		hash, err := b.System.hash(ctx, b.ParentTemplate, config)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, HashFooterFormat, hash); err != nil {
+			return err
+		}
+	}
+	if provenance != nil && o.provenance == ProvenanceFooter {
+		if _, err := io.WriteString(w, "\n"+provenance.Comment()+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewPromptSystem creates a system building prompts from registry. Parse trees are shared with every other
+// system through the process-wide parse cache; if registry implements ChangeNotifier, changed templates are
+// dropped from it.
+func NewPromptSystem(registry PromptRegistry) (*PromptSystem, error) {
+	s := &PromptSystem{
+		Registry: registry,
+		cache:    sharedParseCache,
+	}
+	if notifier, ok := registry.(ChangeNotifier); ok {
+		notifier.OnChange(s.cache.invalidate)
+	}
+	return s, nil
+}
+
+// find looks up a template in the registry and attaches the system's parse cache, limits, logger, tracer,
+// metrics, retriever and flags to it
+func (s *PromptSystem) find(ctx context.Context, templatePath string) (template *Template, err error) {
+	ctx, span := startSpan(ctx, s.tracer(), "Find", templatePath)
+	defer func() { endSpan(span, err) }()
+
+	if name, ok := stackName(templatePath); ok {
+		template, err = s.stackTemplate(name)
+	} else {
+		template, err = s.Registry.Find(ctx, templatePath)
+	}
+	if err != nil {
+		return nil, WrapNotFound(templatePath, err)
+	}
+	// Registries that parse ahead, such as CompiledRegistry, attach their own cache
+	if template.cache == nil {
+		template.cache = s.cache
+	}
+	template.limits = s.Limits
+	template.Logger = s.Logger
+	template.Tracer = s.tracer()
+	template.metrics = s.Metrics
+	template.retriever = s.Retriever
+	template.flags = s.Flags
+	if fm, err := template.FrontMatter(); err == nil && fm.Deprecation != nil {
+		template.logger().Warn(fm.Deprecation.Message(templatePath))
+	}
+	return template, nil
+}
+
+// loadConfig loads the config at configPath, or starts from an empty config if configPath is empty, and
+// layers any data from the build options over it
+func (s *PromptSystem) loadConfig(ctx context.Context, configPath string, o *buildOptions) (*Config, error) {
+	if configPath == "" {
+		return o.applyConfig(NewConfig(map[string]any{}, "")), nil
+	}
+	config, err := s.Registry.LoadConfig(ctx, configPath)
+	if err != nil {
+		return nil, fmt.Errorf("err loading confing: %w", err)
+	}
+	return o.applyConfig(config), nil
+}
+
+// BuildResult is a rendered prompt along with metadata about how it was built
+type BuildResult struct {
+	Output string `json:"output"`
+	// Template is the registry path of the template built, with its params filled in and its locale picked
+	Template string `json:"template,omitempty"`
+	// Dependencies are the registry paths of every template transitively included
+	Dependencies []string `json:"dependencies"`
+	// Variables are the dotted paths of every variable the template closure references
+	Variables []string `json:"variables"`
+	// UnusedKeys are the dotted paths of config values no template references
+	UnusedKeys []string      `json:"unused_keys"`
+	Duration   time.Duration `json:"duration"`
+	// Tokens is an estimate of the output's token count, see EstimateTokens
+	Tokens int `json:"tokens"`
+	// Issues are the validation problems found in the config, empty when validation is off
+	Issues []ValidationIssue `json:"issues"`
+	// TrimmedSections is how many [[trimmable]] sections were shortened or dropped to fit WithTokenBudget
+	TrimmedSections int `json:"trimmed_sections,omitempty"`
+	// Messages are the output split by [[role]] section. They are only set when built WithMessages.
+	Messages []Message `json:"messages,omitempty"`
+	// Hash identifies the template closure and config, see PromptSystem.Hash. It is only set when built
+	// WithHashFooter.
+	Hash string `json:"hash,omitempty"`
+	// Provenance describes where the prompt came from. It is only set when built WithProvenance.
+	Provenance *Provenance `json:"provenance,omitempty"`
+	// Warnings are likely mistakes that didn't stop the build, such as unused keys or <no value> output
+	Warnings []Warning `json:"warnings,omitempty"`
+	// Sections are the template's defines and blocks rendered on their own, by name. They are only set when
+	// built WithSections.
+	Sections map[string]string `json:"sections,omitempty"`
+}
+
+// Build builds a template given a config
+func (s *PromptSystem) Build(ctx context.Context, templatePath, configPath string, opts ...BuildOption) (string, error) {
+	result, err := s.BuildWithResult(ctx, templatePath, configPath, opts...)
+	if err != nil {
+		return "", err
+	}
+	return result.Output, nil
+}
+
+// BuildTo builds a template given a config, writing the output directly to w
+func (s *PromptSystem) BuildTo(ctx context.Context, w io.Writer, templatePath, configPath string, opts ...BuildOption) error {
+	builder, err := s.NewBuilder(ctx, templatePath, configPath, opts...)
+	if err != nil {
+		return err
+	}
+	if builder.options.lazyDeps {
+		// The builder only builds this config, so like Build it can skip what the config can't reach
+		config, _, err := s.screenPII(builder.Config)
+		if err != nil {
+			return err
+		}
+		builder.ParentTemplate.lazyData = config.Config
+	}
+	return builder.BuildTo(ctx, w)
+}
+
+// BuildWithResult builds a template given a config, returning the output along with build metadata
+func (s *PromptSystem) BuildWithResult(ctx context.Context, templatePath, configPath string, opts ...BuildOption) (result *BuildResult, err error) {
+	ctx, span := startSpan(ctx, s.tracer(), "Build", templatePath, attribute.String("rprompt.config", configPath))
+	defer func() { endSpan(span, err) }()
+	start := time.Now()
+	defer func() {
+		if s.Metrics != nil {
+			s.Metrics.ObserveBuild(templatePath, start, err)
+		}
+	}()
+	o := newBuildOptions(opts)
+	template, err := s.findLocalized(ctx, templatePath, o)
+	if err != nil {
+		return nil, fmt.Errorf("err finding template: %w", err)
+	}
+	template.limits = o.limitsFor(s.Limits)
+	template.deterministic = o.deterministic
+	template.history = o.history
+	template.target = o.target
+	template.coverage = o.coverage
+	config, err := s.loadConfig(ctx, configPath, o)
+	if err != nil {
+		return nil, err
+	}
+	config, pii, err := s.screenPII(config)
+	if err != nil {
+		return nil, err
+	}
+	if o.lazyDeps {
+		template.lazyData = config.Config
+	}
+
+	requiredConfig, err := template.GenerateConfig(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := o.checkDrafts(template); err != nil {
+		return nil, err
+	}
+	issues, err := validate(o.validationMode(s.Validation), template, requiredConfig, config)
+	if err != nil {
+		return nil, err
+	}
+	render := s.renderer()
+	if o.rawOutput() {
+		// Middleware sees the markers so the output can still be post-processed after it runs
+		render = s.chain(func(ctx context.Context, t *Template, cfg *Config) (string, error) {
+			return t.buildRaw(ctx, *cfg)
+		})
+	}
+	output, err := render(ctx, template, config)
+	if err != nil {
+		return nil, err
+	}
+	if o.responseFormat {
+		// Appended before fitting so the budget leaves room for it
+		section, err := responseFormatSection(template)
+		if err != nil {
+			return nil, err
+		}
+		output += section
+	}
+	output, trimmed, err := o.fit(output)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := postProcessors(template, s.PostProcess)
+	if err != nil {
+		return nil, err
+	}
+	var messages []Message
+	if o.messages {
+		messages = postProcessMessages(splitMessages(output), steps)
+	}
+	output = postProcess(stripMarkers(output), steps)
+	var sections map[string]string
+	if o.sections {
+		if sections, err = buildSections(ctx, template, config, steps); err != nil {
+			return nil, err
+		}
+	}
+	var warnings warningCollector
+	if o.maxTokens > 0 {
+		warnings.nearTokenLimit(o.count(output), o.maxTokens)
+	}
+	var hash string
+	if o.hashFooter {
+		if hash, err = s.hash(ctx, template, config); err != nil {
+			return nil, err
+		}
+		output += fmt.Sprintf(HashFooterFormat, hash)
+	}
+	var provenance *Provenance
+	if o.provenance != ProvenanceNone {
+		if provenance, err = s.provenance(ctx, template, config, o); err != nil {
+			return nil, err
+		}
+		if o.provenance == ProvenanceHeader {
+			output = provenance.Comment() + "\n" + output
+		} else {
+			output += "\n" + provenance.Comment() + "\n"
+		}
+	}
+
+	variables := utils.FlattenKeys(requiredConfig.Config)
+	unused := unusedKeys(utils.FlattenKeys(config.Config), variables)
+	fm, err := template.FrontMatter()
+	if err != nil {
+		return nil, err
+	}
+	warnings.pii(pii, s.PII == PIIMask)
+	warnings.unusedKeys(unused)
+	warnings.noValues(output, issues)
+	if err := warnings.deprecations(template, fm, config.Config); err != nil {
+		return nil, err
+	}
+	if err := s.audit(ctx, template, config, o, len(output)); err != nil {
+		return nil, err
+	}
+	s.recordUsage(ctx, template)
+	tokens := EstimateTokens(output)
+	if s.Metrics != nil {
+		s.Metrics.ObserveTokens(templatePath, tokens)
+	}
+	return &BuildResult{
+		Output:          output,
+		Template:        template.Path,
+		Dependencies:    template.Dependencies(),
+		Variables:       variables,
+		UnusedKeys:      unused,
+		Duration:        time.Since(start),
+		Tokens:          tokens,
+		Issues:          issues,
+		Messages:        messages,
+		TrimmedSections: trimmed,
+		Hash:            hash,
+		Provenance:      provenance,
+		Warnings:        warnings.warnings,
+		Sections:        sections,
+	}, nil
+}
+
+// BuildSection renders only the named define or block within a template, so one template file can hold
+// several prompt sections. The config is validated against the variables that section uses.
+func (s *PromptSystem) BuildSection(ctx context.Context, templatePath, section, configPath string, opts ...BuildOption) (string, error) {
+	o := newBuildOptions(opts)
+	template, err := s.findLocalized(ctx, templatePath, o)
+	if err != nil {
+		return "", fmt.Errorf("err finding template: %w", err)
+	}
+	template.limits = o.limitsFor(s.Limits)
+	template.deterministic = o.deterministic
+	template.history = o.history
+	template.target = o.target
+	template.coverage = o.coverage
+	config, err := s.loadConfig(ctx, configPath, o)
+	if err != nil {
+		return "", err
+	}
+	config, pii, err := s.screenPII(config)
+	if err != nil {
+		return "", err
+	}
+	s.logPII(template, pii)
+
+	requiredConfig, err := template.GenerateSectionConfig(ctx, section, "")
+	if err != nil {
+		return "", err
+	}
+	if err := o.checkDrafts(template); err != nil {
+		return "", err
+	}
+	if _, err := validate(o.validationMode(s.Validation), template, requiredConfig, config); err != nil {
+		return "", err
+	}
+	render := s.chain(func(ctx context.Context, t *Template, cfg *Config) (string, error) {
+		return t.BuildSection(ctx, section, *cfg)
+	})
+	output, err := render(ctx, template, config)
+	if err != nil {
+		return "", err
+	}
+	if err := s.audit(ctx, template, config, o, len(output)); err != nil {
+		return "", err
+	}
+	s.recordUsage(ctx, template)
+	return output, nil
+}
+
+// unusedKeys returns the config keys not referenced by any variable. A key counts as used if a variable
+// references it, one of its parents (e.g. ranging over .items uses items.0.name), or one of its children.
+func unusedKeys(configKeys, variables []string) []string {
+	unused := []string{}
+	for _, key := range configKeys {
+		if isTargetKey(key) {
+			continue
+		}
+		used := false
+		for _, variable := range variables {
+			if key == variable || strings.HasPrefix(key, variable+".") || strings.HasPrefix(variable, key+".") {
+				used = true
+				break
+			}
+		}
+		if !used {
+			unused = append(unused, key)
+		}
+	}
+	return unused
+}
+
+// TemplateInfo describes what a template needs without rendering it
+type TemplateInfo struct {
+	Path string `json:"path"`
+	// Dependencies are the registry paths of every template transitively included
+	Dependencies []string `json:"dependencies"`
+	// Sections are the defines and blocks that can be built on their own, see BuildSection
+	Sections []string `json:"sections"`
+	// Variables are the dotted paths of every variable the template closure references
+	Variables []string `json:"variables"`
+	// Config is the skeleton config the template requires, as written by GenerateConfig
+	Config map[string]any `json:"config"`
+	// ResponseSchema is the schema replies should match, from the template's front matter
+	ResponseSchema map[string]any `json:"response_schema,omitempty"`
+	// Owners are accountable for the template, from its front matter or the registry's OwnersFile
+	Owners []string `json:"owners,omitempty"`
+}
+
+// Describe returns the dependencies, sections, variables and owners of a template
+func (s *PromptSystem) Describe(ctx context.Context, templatePath string) (*TemplateInfo, error) {
+	template, err := s.find(ctx, templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("err finding template: %w", err)
+	}
+	required, err := template.GenerateConfig(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	fm, err := template.FrontMatter()
+	if err != nil {
+		return nil, err
+	}
+	owners, err := templateOwners(s.Registry, templatePath, fm)
+	if err != nil {
+		return nil, err
+	}
+	return &TemplateInfo{
+		Path:           templatePath,
+		Dependencies:   template.Dependencies(),
+		Sections:       template.Sections(),
+		Variables:      utils.FlattenKeys(required.Config),
+		Config:         required.Config,
+		ResponseSchema: fm.ResponseSchema,
+		Owners:         owners,
+	}, nil
+}
+
+// GenerateOrFillConfig writes the config the template requires to configPath, or fills in the values an
+// existing config there lacks. Existing values are kept whatever their type, nested objects are filled in
+// recursively and every item of an array the template ranges over gains the fields the template uses, see
+// utils.FillMissing.
+func (s *PromptSystem) GenerateOrFillConfig(ctx context.Context, templatePath string, configPath string) error {
+	template, err := s.find(ctx, templatePath)
+	if err != nil {
+		return fmt.Errorf("err finding template: %w", err)
+	}
+	genCfg, err := template.GenerateConfig(ctx, configPath)
+	if err != nil {
+		return fmt.Errorf("err generating config: %w", err)
+	}
+	existing := map[string]any{}
+	loadedCfg, err := s.Registry.LoadConfig(ctx, configPath)
+	switch {
+	case err == nil:
+		existing = loadedCfg.Config
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("err loading config: %w", err)
+	}
+	finalCfg := NewConfig(utils.FillMissing(existing, genCfg.Config), configPath)
+	return s.Registry.SaveConfig(ctx, finalCfg)
+}
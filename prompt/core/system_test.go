@@ -0,0 +1,13 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnusedKeys(t *testing.T) {
+	variables := []string{"items", "user", "profile.name"}
+	configKeys := []string{"items.0.name", "user.email", "profile", "other"}
+	assert.Equal(t, []string{"other"}, unusedKeys(configKeys, variables))
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"maps"
+	"strings"
+)
+
+// Top-level variables the system sets for every execution from the build's Target, so templates can adapt to
+// the model they're rendered for with [[if eq .provider "anthropic"]]. They are never required from a
+// config and never reported unused, and a config that sets them overrides the target.
+const (
+	TargetModelKey    = "model"
+	TargetProviderKey = "provider"
+)
+
+// Target is the model a prompt is rendered for
+type Target struct {
+	// Provider names the API serving the model, such as anthropic or openai
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+}
+
+// WithTarget sets .model and .provider for this build. Without it both are empty strings, so comparisons
+// against them still work.
+func WithTarget(target Target) BuildOption {
+	return func(o *buildOptions) {
+		o.target = target
+	}
+}
+
+// isTargetKey reports whether the dotted config path is, or is inside, a variable the target sets
+func isTargetKey(path string) bool {
+	for _, key := range []string{TargetModelKey, TargetProviderKey} {
+		if path == key || strings.HasPrefix(path, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// executionData returns the data templates execute with: the config over the target's values, without the NUL
+// bytes that would let a value forge a marker, see sanitizeData
+func (t *Template) executionData(config map[string]any) map[string]any {
+	data := make(map[string]any, len(config)+2)
+	data[TargetModelKey] = sanitizeData(t.target.Model)
+	data[TargetProviderKey] = sanitizeData(t.target.Provider)
+	maps.Copy(data, sanitizeData(config).(map[string]any))
+	return data
+}
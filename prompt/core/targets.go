@@ -0,0 +1,61 @@
+package core
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Placeholders that may appear in an output path pattern, each expanded per rendered template
+var outputPathPlaceholders = map[string]func(templatePath string) string{
+	// template_stem is the file name without directory or extension, e.g. "billing" for agents/billing.tmpl
+	"template_stem": func(templatePath string) string {
+		return strings.TrimSuffix(path.Base(templatePath), ".tmpl")
+	},
+	// template_path is the registry path without extension, e.g. "agents/billing" for agents/billing.tmpl
+	"template_path": func(templatePath string) string {
+		return strings.TrimSuffix(templatePath, ".tmpl")
+	},
+	// template_dir is the registry directory of the template, e.g. "agents" for agents/billing.tmpl
+	"template_dir": func(templatePath string) string {
+		return path.Dir(templatePath)
+	},
+}
+
+// HasOutputPlaceholder reports whether pattern contains any output path placeholder
+func HasOutputPlaceholder(pattern string) bool {
+	for name := range outputPathPlaceholders {
+		if strings.Contains(pattern, "{{"+name+"}}") {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpandOutputPath substitutes the output path placeholders in pattern for the given template
+func ExpandOutputPath(pattern, templatePath string) string {
+	expanded := pattern
+	for name, value := range outputPathPlaceholders {
+		expanded = strings.ReplaceAll(expanded, "{{"+name+"}}", value(templatePath))
+	}
+	return expanded
+}
+
+// ExpandOutputPaths maps each template to its output path, returning an error if two templates would be
+// written to the same file
+func ExpandOutputPaths(pattern string, templatePaths []string) (map[string]string, error) {
+	if len(templatePaths) > 1 && !HasOutputPlaceholder(pattern) {
+		return nil, fmt.Errorf("output %q must contain a placeholder such as {{template_stem}} when rendering multiple templates", pattern)
+	}
+	outputs := make(map[string]string, len(templatePaths))
+	seen := make(map[string]string, len(templatePaths))
+	for _, templatePath := range templatePaths {
+		output := ExpandOutputPath(pattern, templatePath)
+		if other, ok := seen[output]; ok && other != templatePath {
+			return nil, fmt.Errorf("templates %s and %s would both be written to %s", other, templatePath, output)
+		}
+		seen[output] = templatePath
+		outputs[templatePath] = output
+	}
+	return outputs, nil
+}
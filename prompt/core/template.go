@@ -0,0 +1,810 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/notzree/rprompt/v2/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Default delimiters of the templates in a registry, see DelimitedRegistry
+const (
+	LeftDelim  = "[["
+	RightDelim = "]]"
+)
+
+// templateFuncs are the functions available to every template
+var templateFuncs = template.FuncMap{
+	"role":         role,
+	"trimmable":    trimmable,
+	"endtrimmable": endtrimmable,
+	"document":     document,
+	"include":      include,
+	"retrieve":     retrieve,
+	"flag":         flag,
+	"history":      History(nil).recent,
+	"now":          time.Now,
+	"randInt":      randomness{}.intN,
+	"shuffle":      randomness{}.shuffle,
+}
+
+// BuiltinFunc reports whether name is one of the functions available to every template, which registries
+// can't replace
+func BuiltinFunc(name string) bool {
+	_, ok := templateFuncs[name]
+	return ok
+}
+
+// DelimitedRegistry is implemented by registries whose templates use other delimiters than LeftDelim and
+// RightDelim
+type DelimitedRegistry interface {
+	// Delimiters returns the registry's action delimiters, or empty strings for the defaults
+	Delimiters() (left, right string)
+}
+
+// RegistryDelims returns the delimiters of the templates in r
+func RegistryDelims(r PromptRegistry) (string, string) {
+	if d, ok := r.(DelimitedRegistry); ok {
+		if left, right := d.Delimiters(); left != "" && right != "" {
+			return left, right
+		}
+	}
+	return LeftDelim, RightDelim
+}
+
+// FuncRegistry is implemented by registries whose templates can call functions besides templateFuncs, such as
+// the functions of plugins, see Plugin
+type FuncRegistry interface {
+	// TemplateFuncs returns the registry's extra template functions. They can't replace the built in ones.
+	TemplateFuncs() template.FuncMap
+}
+
+// newTemplateSet creates an empty template set using the registry's delimiters and functions
+func newTemplateSet(name string, r PromptRegistry) *template.Template {
+	set := template.New(name).Delims(RegistryDelims(r))
+	if f, ok := r.(FuncRegistry); ok {
+		set.Funcs(f.TemplateFuncs())
+	}
+	return set.Funcs(templateFuncs)
+}
+
+type Template struct {
+	Path            string
+	OriginalContent string
+	// Tmpl holds the parse trees of the template's own source. The set it executes in, with the templates it
+	// includes, is built by LoadDependencies.
+	Tmpl    template.Template
+	r       PromptRegistry
+	cache   *parseCache
+	metrics MetricsRecorder
+	// mu guards parsing into Tmpl
+	mu sync.Mutex
+	// set is the execution set LoadDependencies built last
+	set atomic.Pointer[executionSet]
+	// defines are the names of the defines and blocks declared in this template's own source
+	defines map[string]bool
+	// pinned templates keep the dependencies they have already loaded instead of resolving them again
+	pinned bool
+	limits Limits
+	// deterministic executes with fixed time and random functions, see WithDeterministic
+	deterministic bool
+	// coverage records the branches executions render, see WithCoverage
+	coverage *Coverage
+	// preview marks the output of every action, see Template.Preview
+	preview *previewRecorder
+	// retriever answers [[retrieve]], see PromptSystem.Retriever
+	retriever ContextProvider
+	// flags answers [[flag]], see PromptSystem.Flags
+	flags FlagsProvider
+	// history is the conversation [[history]] renders, see WithHistory
+	history History
+	// target sets .model and .provider, see WithTarget
+	target Target
+	// locales are the locales, most specific first, whose variants of the template's dependencies are
+	// included in their place, see WithLocale
+	locales []string
+	// lazyData is the config LoadDependencies evaluates conditions against to resolve only the reachable
+	// dependencies, see WithLazyDeps. Nil resolves every dependency.
+	lazyData map[string]any
+	// Logger receives debug output about parsing and dependency resolution. Nil discards it.
+	Logger *slog.Logger
+	// Tracer starts OpenTelemetry spans for dependency resolution and execution. Nil disables tracing.
+	Tracer trace.Tracer
+}
+type TemplateDependency struct {
+	Path string
+}
+
+func NewTemplate(name string, content string, r PromptRegistry) *Template {
+	tmpl := newTemplateSet(name, r)
+	t := &Template{
+		Path:            name,
+		OriginalContent: content,
+		Tmpl:            *tmpl,
+		r:               r,
+	}
+	// Parse the template but don't fail if there's an error
+	// The error will be caught later when needed
+	// t.Tmpl.Parse(content)
+	return t
+}
+
+func (t *Template) Build(ctx context.Context, cfg Config) (string, error) {
+	var builder strings.Builder
+	if err := t.BuildTo(ctx, &builder, cfg); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// BuildTo executes the template directly into w instead of buffering the whole prompt in memory.
+// If execution fails part way through, w may already hold partial output.
+func (t *Template) BuildTo(ctx context.Context, w io.Writer, cfg Config) error {
+	if err := t.LoadDependencies(ctx); err != nil {
+		return err
+	}
+	return t.execute(ctx, w, t.Path, cfg)
+}
+
+// execute runs the named template in the template's set, enforcing its limits and stopping once ctx is done.
+// Markers are dropped so the output is plain text.
+func (t *Template) execute(ctx context.Context, w io.Writer, name string, cfg Config) error {
+	return t.executeRaw(ctx, &markerFilter{w: w}, name, cfg)
+}
+
+// executeRaw is execute without dropping markers
+func (t *Template) executeRaw(ctx context.Context, w io.Writer, name string, cfg Config) (err error) {
+	ctx, span := startSpan(ctx, t.tracer(), "Execute", t.Path, attribute.String("rprompt.section", name))
+	defer func() { endSpan(span, err) }()
+	// Recovered before the span ends so the span records the panic as an error
+	defer func() {
+		if r := recover(); r != nil {
+			err = NewRenderPanicError(t.Path, r, string(debug.Stack()))
+		}
+	}()
+	execCtx := ctx
+	if t.limits.MaxRenderDuration > 0 {
+		var cancel context.CancelFunc
+		execCtx, cancel = context.WithTimeout(ctx, t.limits.MaxRenderDuration)
+		defer cancel()
+	}
+	lw := &limitedWriter{w: w, ctx: execCtx, parent: ctx, limits: t.limits}
+	defer func() { span.SetAttributes(attribute.Int64("rprompt.output_bytes", lw.written)) }()
+	var out io.Writer = &documentWriter{ctx: execCtx, w: lw, r: t.r}
+	tmpl := t.templates()
+	if funcs := t.executionFuncs(execCtx); len(funcs) > 0 {
+		clone, err := tmpl.Clone()
+		if err != nil {
+			return err
+		}
+		tmpl = clone.Funcs(funcs)
+	}
+	if t.coverage != nil {
+		if tmpl, err = t.coverage.instrument(t, tmpl); err != nil {
+			return err
+		}
+		out = &coverageWriter{w: out, c: t.coverage}
+	}
+	if t.preview != nil {
+		if tmpl, err = t.preview.instrument(t, tmpl); err != nil {
+			return err
+		}
+	}
+	if err := tmpl.ExecuteTemplate(out, name, t.executionData(cfg.Config)); err != nil {
+		return fmt.Errorf("template execution error: %w", t.locateError(err))
+	}
+	return nil
+}
+
+// executionFuncs returns the functions replacing templateFuncs for a single execution, such as the fixed time
+// and random functions of deterministic builds
+func (t *Template) executionFuncs(ctx context.Context) template.FuncMap {
+	funcs := template.FuncMap{}
+	if t.deterministic {
+		maps.Copy(funcs, deterministicFuncs())
+	}
+	if t.retriever != nil {
+		funcs["retrieve"] = retrieveFunc(ctx, t.retriever)
+	}
+	if t.flags != nil {
+		funcs["flag"] = flagFunc(ctx, t.flags)
+	}
+	if len(t.history) > 0 {
+		funcs["history"] = t.history.sanitized().recent
+	}
+	return funcs
+}
+
+// BuildSection executes only the named define or block within the template
+func (t *Template) BuildSection(ctx context.Context, name string, cfg Config) (string, error) {
+	if err := t.LoadDependencies(ctx); err != nil {
+		return "", err
+	}
+	if _, err := t.section(name); err != nil {
+		return "", err
+	}
+	var builder strings.Builder
+	if err := t.execute(ctx, &builder, name, cfg); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// GenerateSectionConfig generates an empty config for the variables used by the named define or block
+func (t *Template) GenerateSectionConfig(ctx context.Context, name string, path string) (*Config, error) {
+	if err := t.LoadDependencies(ctx); err != nil {
+		return nil, fmt.Errorf("error loading dependencies: %w", err)
+	}
+	section, err := t.section(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewConfig(t.Walk(section.Tree.Root), path), nil
+}
+
+// Sections returns the names of the defines and blocks available to the template, sorted.
+// It is only complete once LoadDependencies has run.
+func (t *Template) Sections() []string {
+	sections := []string{}
+	for _, tmpl := range t.templates().Templates() {
+		if tmpl.Tree == nil || tmpl.Name() == t.Path || t.isDependency(tmpl.Name()) {
+			continue
+		}
+		sections = append(sections, tmpl.Name())
+	}
+	sort.Strings(sections)
+	return sections
+}
+
+// section looks up a named define or block in the template set
+func (t *Template) section(name string) (*template.Template, error) {
+	section := t.templates().Lookup(name)
+	if section == nil || section.Tree == nil || name == t.Path || t.isDependency(name) {
+		return nil, fmt.Errorf("section %q not found in template %s", name, t.Path)
+	}
+	return section, nil
+}
+
+// isDependency reports whether name refers to one of the files this template includes
+func (t *Template) isDependency(name string) bool {
+	for _, path := range DependencyCandidates(name, RegistryExtensions(t.r)) {
+		for _, dep := range t.Dependencies() {
+			if dep == path {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Parse checks for any missing fields from a given config
+func (t *Template) Parse(ctx context.Context, cfg Config) error {
+	requiredConfig, err := t.GenerateConfig(ctx, "__")
+	if err != nil {
+		return err
+	}
+	return checkMissingFields(t, requiredConfig, cfg)
+}
+
+// checkMissingFields returns a MissingFieldsError if cfg lacks any top-level value requiredConfig requires.
+// Gaps inside values cfg has don't fail a build; validation reports those as issues. The error lists the
+// full dotted path of each required value under a missing key, attributed to the templates in t's set that
+// use it.
+func checkMissingFields(t *Template, requiredConfig *Config, cfg Config) error {
+	var missing []string
+	for key, value := range requiredConfig.Config {
+		// $-prefixed identifiers are template variables, not config values
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+		if _, ok := cfg.Config[key]; ok {
+			continue
+		}
+		nested, isObject := value.(map[string]any)
+		if !isObject || len(nested) == 0 {
+			missing = append(missing, key)
+			continue
+		}
+		for _, leaf := range utils.FlattenKeys(nested) {
+			missing = append(missing, key+"."+leaf)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return NewMissingFieldsError(t.attributeFields(missing))
+}
+
+// attributeFields groups paths by the templates in t's set whose own source uses them. Paths no template can
+// be found for are attributed to t.
+func (t *Template) attributeFields(paths []string) map[string][]string {
+	byTemplate := make(map[string][]string)
+	uses := make(map[string]map[string]bool)
+	for _, tmpl := range t.templates().Templates() {
+		if tmpl.Tree == nil || tmpl.Tree.Root == nil {
+			continue
+		}
+		owner := tmpl.Tree.ParseName
+		if uses[owner] == nil {
+			uses[owner] = make(map[string]bool)
+		}
+		for _, path := range utils.FlattenKeys(t.walkNode(tmpl.Tree.Root, false)) {
+			uses[owner][path] = true
+		}
+	}
+
+	for _, path := range paths {
+		attributed := false
+		for owner, used := range uses {
+			if usesPath(used, path) {
+				byTemplate[owner] = append(byTemplate[owner], path)
+				attributed = true
+			}
+		}
+		if !attributed {
+			byTemplate[t.Path] = append(byTemplate[t.Path], path)
+		}
+	}
+	return byTemplate
+}
+
+// usesPath reports whether a template using the given leaf paths needs path: it uses path itself, a value
+// beneath it, or the object holding it
+func usesPath(used map[string]bool, path string) bool {
+	if used[path] {
+		return true
+	}
+	for use := range used {
+		if strings.HasPrefix(use, path+".") || strings.HasPrefix(path, use+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateConfig will generate an empty config based on the required variables
+func (t *Template) GenerateConfig(ctx context.Context, path string) (*Config, error) {
+	// First load all dependencies to ensure they are available for walking
+	if err := t.LoadDependencies(ctx); err != nil {
+		return nil, fmt.Errorf("error loading dependencies: %w", err)
+	}
+
+	data := t.Walk(t.Tmpl.Tree.Root)
+	t.logger().Debug("generated config", "template", t.Tmpl.Name(), "config", data)
+	return NewConfig(data, path), nil
+}
+
+// Walk returns the config structure the variables used under node require, following included templates
+func (t *Template) Walk(node parse.Node) map[string]any {
+	data := t.walkNode(node, true)
+	// The system sets these, see Target
+	delete(data, TargetModelKey)
+	delete(data, TargetProviderKey)
+	return data
+}
+
+func (t *Template) walkNode(node parse.Node, follow bool) map[string]any {
+	data := make(map[string]any)
+	t.walkInto(data, node, follow, make(map[string]bool))
+	return data
+}
+
+// walkInto adds the variables used under node to data in place, so a whole tree is walked into a single
+// structure rather than a map per node. Paths already in data keep their value, as with utils.MergeAsSet.
+// walking holds the included templates being walked, so templates that include themselves aren't followed
+// forever.
+func (t *Template) walkInto(data map[string]any, node parse.Node, follow bool, walking map[string]bool) {
+	if node == nil {
+		return
+	}
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n != nil {
+			for _, item := range n.Nodes {
+				t.walkInto(data, item, follow, walking)
+			}
+		}
+	case *parse.ActionNode:
+		if n != nil && n.Pipe != nil {
+			utils.MergeInto(data, ExtractVarsFromPipe(n.Pipe))
+		}
+	case *parse.IfNode:
+		if n != nil {
+			if n.Pipe != nil {
+				utils.MergeInto(data, ExtractVarsFromPipe(n.Pipe))
+			}
+			if n.List != nil {
+				t.walkInto(data, n.List, follow, walking)
+			}
+			if n.ElseList != nil {
+				t.walkInto(data, n.ElseList, follow, walking)
+			}
+		}
+	case *parse.RangeNode:
+		if n != nil {
+			if n.Pipe != nil {
+				// Extract the range variable (e.g., .navigation.links)
+				utils.MergeInto(data, ExtractVarsFromPipe(n.Pipe))
+
+				// Also extract any variables used inside the range block
+				if n.List != nil {
+					// Find the range variable path
+					var rangePath []string
+					for _, cmd := range n.Pipe.Cmds {
+						for _, arg := range cmd.Args {
+							if field, ok := arg.(*parse.FieldNode); ok && len(field.Ident) > 0 {
+								// Skip the dot
+								start := 0
+								if field.Ident[0] == "." {
+									start = 1
+								}
+								rangePath = field.Ident[start:]
+								break
+							}
+						}
+					}
+
+					// If we found a range path, add the item structure to it
+					if len(rangePath) > 0 {
+						item := make(map[string]any)
+						t.walkInto(item, n.List, follow, walking)
+						// $-prefixed identifiers are template variables, not fields of the item
+						for key := range item {
+							if strings.HasPrefix(key, "$") {
+								delete(item, key)
+							}
+						}
+						addRangePath(data, rangePath, item)
+					}
+				}
+			}
+			if n.ElseList != nil {
+				t.walkInto(data, n.ElseList, follow, walking)
+			}
+		}
+	case *parse.WithNode:
+		if n != nil {
+			if n.Pipe != nil {
+				// Extract the variable being "with-ed"
+				withVars := ExtractVarsFromPipe(n.Pipe)
+				keys := make([]string, 0, len(withVars))
+				for withKey := range withVars {
+					keys = append(keys, withKey)
+				}
+				utils.MergeInto(data, withVars)
+
+				// Walk the list inside the with block
+				if n.List != nil {
+					// For each variable in withVars, create a nested structure
+					for _, withKey := range keys {
+						// Initialize or get the map for this key
+						withMap, ok := data[withKey].(map[string]any)
+						if !ok {
+							withMap = make(map[string]any)
+							data[withKey] = withMap
+						}
+						// Merge the variables used inside the with block into the with variable's map
+						t.walkInto(withMap, n.List, follow, walking)
+					}
+				}
+			}
+			if n.ElseList != nil {
+				t.walkInto(data, n.ElseList, follow, walking)
+			}
+		}
+	case *parse.TemplateNode:
+		if n != nil {
+			templateName := n.Name
+			t.logger().Debug("walking included template", "template", t.Path, "include", templateName)
+
+			// look up in the parent set
+			nestedTemplate := t.templates().Lookup(templateName)
+
+			if nestedTemplate != nil && follow {
+				// Get the parse tree of the nested template
+				nestedTree := nestedTemplate.Tree
+
+				if nestedTree != nil && nestedTree.Root != nil && !walking[templateName] {
+					walking[templateName] = true
+					// Walk each dependency this template might have into the main data first
+					for _, depName := range findTemplateDependencies(nestedTree.Root) {
+						if depTemplate := t.templates().Lookup(depName); depTemplate != nil && depTemplate.Tree != nil && !walking[depName] {
+							walking[depName] = true
+							t.walkInto(data, depTemplate.Tree.Root, follow, walking)
+							delete(walking, depName)
+						}
+					}
+
+					// Then the template itself
+					t.walkInto(data, nestedTree.Root, follow, walking)
+					delete(walking, templateName)
+				}
+			}
+
+			// Also process any pipe parameters
+			if n.Pipe != nil {
+				utils.MergeInto(data, ExtractVarsFromPipe(n.Pipe))
+			}
+		}
+	}
+}
+
+// addRangePath marks the variable a range block iterates over, creating the maps leading to it. When the block
+// uses fields of the item it is an array holding one item with those fields. A value already in data along the
+// path is kept, gaining the item's fields if it is such an array.
+func addRangePath(data map[string]any, rangePath []string, item map[string]any) {
+	current := data
+	for _, key := range rangePath[:len(rangePath)-1] {
+		if _, ok := current[key]; !ok {
+			current[key] = make(map[string]any)
+		}
+		next, ok := current[key].(map[string]any)
+		if !ok {
+			return
+		}
+		current = next
+	}
+
+	lastKey := rangePath[len(rangePath)-1]
+	existing, ok := current[lastKey]
+	switch {
+	case len(item) == 0:
+		if !ok {
+			current[lastKey] = ""
+		}
+	case !ok || existing == "":
+		current[lastKey] = []any{item}
+	default:
+		if items, isArray := existing.([]any); isArray && len(items) > 0 {
+			if first, isMap := items[0].(map[string]any); isMap {
+				utils.MergeInto(first, item)
+			}
+		}
+	}
+}
+
+// ExtractVarsFromPipe extracts variables from pipe nodes and builds a nested structure
+func ExtractVarsFromPipe(pipe *parse.PipeNode) map[string]any {
+	data := make(map[string]any)
+	if pipe == nil {
+		return data
+	}
+
+	for _, cmd := range pipe.Cmds {
+		if cmd == nil {
+			continue
+		}
+
+		for _, arg := range cmd.Args {
+			if arg == nil {
+				continue
+			}
+
+			switch node := arg.(type) {
+			case *parse.FieldNode:
+				if len(node.Ident) > 0 {
+					// Skip the first identifier if it's a dot
+					start := 0
+					if node.Ident[0] == "." {
+						start = 1
+					}
+					if start < len(node.Ident) {
+						buildNestedStructure(data, node.Ident[start:], "")
+					}
+				}
+			case *parse.VariableNode:
+				if len(node.Ident) > 0 {
+					buildNestedStructure(data, node.Ident, "")
+				}
+			case *parse.DotNode:
+				// Handle the special . node
+				continue
+			}
+		}
+	}
+	return data
+}
+
+// buildNestedStructure recursively builds a nested map from a path of identifiers
+func buildNestedStructure(data map[string]any, path []string, value any) {
+	if len(path) == 0 {
+		return
+	}
+
+	key := path[0]
+
+	if len(path) == 1 {
+		// We've reached the leaf node, set an empty string as value
+		data[key] = ""
+		return
+	}
+
+	// We need to go deeper
+	nestedMap, exists := data[key]
+	if !exists {
+		// Create a new map if it doesn't exist
+		nestedMap = make(map[string]any)
+		data[key] = nestedMap
+	}
+
+	// Check if the value is a map so we can recurse
+	nestedMapValue, ok := nestedMap.(map[string]any)
+	if !ok {
+		// If it's not a map, replace it with a new map
+		nestedMapValue = make(map[string]any)
+		data[key] = nestedMapValue
+	}
+
+	// Recurse with the rest of the path
+	buildNestedStructure(nestedMapValue, path[1:], value)
+}
+
+// Compile parses the template's content into its template set, reusing cached parse trees when a cache is set.
+// Templates are only parsed once, so it is safe to call from every goroutine using the template.
+func (t *Template) Compile() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Tmpl.Name() == t.Path && t.Tmpl.Tree != nil {
+		return nil
+	}
+	// Templates built as struct literals have no template set yet
+	if t.Tmpl.Name() != t.Path {
+		t.Tmpl = *newTemplateSet(t.Path, t.r)
+	}
+	if t.cache == nil {
+		if _, err := t.Tmpl.Parse(t.Body()); err != nil {
+			return t.locateError(err)
+		}
+		t.defines = make(map[string]bool)
+		for _, tmpl := range t.Tmpl.Templates() {
+			if tmpl.Name() != t.Path && tmpl.Tree != nil {
+				t.defines[tmpl.Name()] = true
+			}
+		}
+		return nil
+	}
+
+	key := cacheKey(t.Path, t.OriginalContent)
+	// The same source parses differently with other delimiters
+	if left, right := RegistryDelims(t.r); left != LeftDelim || right != RightDelim {
+		key += "@" + left + right
+	}
+	trees, ok := t.cache.get(key)
+	if t.metrics != nil {
+		t.metrics.ObserveCacheLookup(ok)
+	}
+	if !ok {
+		parsed, err := newTemplateSet(t.Path, t.r).Parse(t.Body())
+		if err != nil {
+			return t.locateError(err)
+		}
+		trees = make(map[string]*parse.Tree)
+		for _, tmpl := range parsed.Templates() {
+			if tmpl.Tree != nil {
+				trees[tmpl.Name()] = tmpl.Tree
+			}
+		}
+		t.cache.put(key, trees)
+	}
+
+	t.defines = make(map[string]bool)
+	for name, tree := range trees {
+		if _, err := t.Tmpl.AddParseTree(name, tree); err != nil {
+			return err
+		}
+		if name != t.Path {
+			t.defines[name] = true
+		}
+	}
+	return nil
+}
+
+// LoadDependencies finds and loads all template dependencies recursively, returning a
+// *DependencyCycleError if templates include each other in a loop.
+// The walk stops early with the context's error if ctx is cancelled.
+//
+// Each call builds a new execution set and replaces the template's set only once it is complete, so a
+// template can be built from several goroutines at once.
+func (t *Template) LoadDependencies(ctx context.Context) (err error) {
+	if t.r == nil {
+		return fmt.Errorf("no registry set for template %s", t.Path)
+	}
+	if t.pinned && t.set.Load() != nil {
+		return nil
+	}
+
+	ctx, span := startSpan(ctx, t.tracer(), "ResolveDependencies", t.Path)
+	defer func() { endSpan(span, err) }()
+	set, err := newDependencyResolver(t).resolve(ctx)
+	if err != nil {
+		return err
+	}
+	t.set.Store(set)
+	span.SetAttributes(attribute.Int("rprompt.dependencies", len(set.deps)))
+	return nil
+}
+
+// Dependencies returns the registry paths of every template this template transitively includes.
+// It is only populated once LoadDependencies has run.
+func (t *Template) Dependencies() []string {
+	if set := t.set.Load(); set != nil {
+		return set.deps
+	}
+	return nil
+}
+
+// FileDependencies returns the templates this template includes from other files, leaving out the defines
+// and blocks it declares itself. The template must already be parsed.
+func (t *Template) FileDependencies() []string {
+	deps := []string{}
+	for _, dep := range findTemplateDependencies(t.Tmpl.Tree.Root) {
+		if !t.defines[dep] {
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
+
+// findTemplateDependencies extracts all template names from TemplateNodes
+func findTemplateDependencies(node parse.Node) []string {
+	deps := []string{}
+	if node == nil {
+		return deps
+	}
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n != nil {
+			for _, item := range n.Nodes {
+				deps = append(deps, findTemplateDependencies(item)...)
+			}
+		}
+	case *parse.TemplateNode:
+		if n != nil {
+			deps = append(deps, n.Name)
+		}
+	case *parse.IfNode:
+		if n != nil {
+			if n.List != nil {
+				deps = append(deps, findTemplateDependencies(n.List)...)
+			}
+			if n.ElseList != nil {
+				deps = append(deps, findTemplateDependencies(n.ElseList)...)
+			}
+		}
+	case *parse.RangeNode:
+		if n != nil {
+			if n.List != nil {
+				deps = append(deps, findTemplateDependencies(n.List)...)
+			}
+			if n.ElseList != nil {
+				deps = append(deps, findTemplateDependencies(n.ElseList)...)
+			}
+		}
+	case *parse.WithNode:
+		if n != nil {
+			if n.List != nil {
+				deps = append(deps, findTemplateDependencies(n.List)...)
+			}
+			if n.ElseList != nil {
+				deps = append(deps, findTemplateDependencies(n.ElseList)...)
+			}
+		}
+	}
+
+	// Remove duplicates
+	return utils.UniqueString(deps)
+}
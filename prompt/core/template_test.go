@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockPromptRegistry is a mock implementation of PromptRegistry
+type MockPromptRegistry struct {
+	mock.Mock
+}
+
+func (m *MockPromptRegistry) Find(ctx context.Context, path string) (*Template, error) {
+	args := m.Called(path)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Template), args.Error(1)
+}
+
+func (m *MockPromptRegistry) LoadConfig(ctx context.Context, path string) (*Config, error) {
+	args := m.Called(path)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Config), args.Error(1)
+}
+
+func (m *MockPromptRegistry) SaveConfig(ctx context.Context, cfg *Config) error {
+	args := m.Called(cfg)
+	return args.Error(0)
+}
+
+// newTestRegistry creates a MemoryRegistry holding templates, keyed by path
+func newTestRegistry(t testing.TB, templates map[string]string) *MemoryRegistry {
+	registry := NewMemoryRegistry()
+	for path, content := range templates {
+		require.NoError(t, registry.SetTemplate(path, content))
+	}
+	return registry
+}
+
+// Test NewTemplate function
+func TestNewTemplate(t *testing.T) {
+	registry := &MockPromptRegistry{}
+	template := NewTemplate("test.tmpl", "This is a [[.test]] template", registry)
+
+	assert.Equal(t, "test.tmpl", template.Path)
+	assert.Equal(t, "This is a [[.test]] template", template.OriginalContent)
+	assert.NotNil(t, template.Tmpl)
+	assert.Equal(t, registry, template.r)
+}
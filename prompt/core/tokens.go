@@ -0,0 +1,51 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// charsPerToken is the rough number of characters per token for English text across common LLM tokenizers
+const charsPerToken = 4
+
+// EstimateTokens returns an approximate token count for text. It is a character-based heuristic rather than a
+// real tokenizer, so treat the result as an estimate for budgeting and reporting only.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken
+}
+
+// Tokenizer counts the tokens in text
+type Tokenizer func(text string) int
+
+// Tokenizers a model profile can name
+const (
+	// TokenizerEstimate counts four characters per token, see EstimateTokens
+	TokenizerEstimate = "estimate"
+	// TokenizerClaude counts 3.5 characters per token, closer to Claude's tokenizer
+	TokenizerClaude = "claude"
+)
+
+// TokenizerNamed returns the tokenizer with the given name
+func TokenizerNamed(name string) (Tokenizer, error) {
+	switch name {
+	case TokenizerEstimate:
+		return EstimateTokens, nil
+	case TokenizerClaude:
+		return countClaudeTokens, nil
+	}
+	return nil, fmt.Errorf("unknown tokenizer %q, expected %s or %s", name, TokenizerEstimate, TokenizerClaude)
+}
+
+func countClaudeTokens(text string) int {
+	return (utf8.RuneCountInString(text)*2 + 6) / 7
+}
+
+// TokenizerForModel returns the tokenizer to budget prompts for a model with. Every model uses a
+// character-based estimate; Claude models are counted at a slightly denser 3.5 characters per token.
+func TokenizerForModel(model string) Tokenizer {
+	if strings.HasPrefix(strings.ToLower(model), "claude") {
+		return countClaudeTokens
+	}
+	return EstimateTokens
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// TracerName is the instrumentation name of the library's OpenTelemetry spans
+const TracerName = "github.com/notzree/rprompt/v2/prompt"
+
+// noopTracer is used when no tracer provider is set, so tracing costs nothing by default
+var noopTracer = noop.NewTracerProvider().Tracer(TracerName)
+
+// tracer returns the system's tracer, or a no-op tracer if no provider is set
+func (s *PromptSystem) tracer() trace.Tracer {
+	if s.TracerProvider == nil {
+		return noopTracer
+	}
+	return s.TracerProvider.Tracer(TracerName)
+}
+
+// tracer returns the template's tracer, or a no-op tracer if none is set
+func (t *Template) tracer() trace.Tracer {
+	if t.Tracer == nil {
+		return noopTracer
+	}
+	return t.Tracer
+}
+
+// startSpan starts a span named rprompt.<name> for work on a template
+func startSpan(ctx context.Context, tracer trace.Tracer, name, templatePath string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("rprompt.template", templatePath))
+	return tracer.Start(ctx, "rprompt."+name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err on the span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ellipsis marks where a trimmable section was shortened
+const ellipsis = "…"
+
+var trimMarkerPattern = regexp.MustCompile("\x00rprompt-(trimmable:(-?[0-9]+)|endtrimmable)\x00")
+
+// trimmable is the template function behind [[trimmable 2]]. Everything written until the matching
+// [[endtrimmable]] may be shortened or dropped to fit a token budget, lowest priority first.
+func trimmable(priority int) string {
+	return marker("trimmable", strconv.Itoa(priority))
+}
+
+// endtrimmable closes the most recent [[trimmable]] section
+func endtrimmable() string {
+	return marker("endtrimmable", "")
+}
+
+// trimSection is a trimmable section of raw output. start and end span its markers, contentStart and
+// contentEnd only what's between them.
+type trimSection struct {
+	start, end               int
+	contentStart, contentEnd int
+	priority                 int
+	// nested is set when the section contains other sections or markers, so it can only be dropped whole
+	nested bool
+}
+
+// parseTrimSections finds the trimmable sections in raw output. A section left open runs to the end of the
+// output and stray end markers are ignored.
+func parseTrimSections(raw string) []trimSection {
+	var sections, stack []trimSection
+	for _, match := range trimMarkerPattern.FindAllStringSubmatchIndex(raw, -1) {
+		if match[4] >= 0 {
+			priority, _ := strconv.Atoi(raw[match[4]:match[5]])
+			if len(stack) > 0 {
+				stack[len(stack)-1].nested = true
+			}
+			stack = append(stack, trimSection{start: match[0], contentStart: match[1], priority: priority})
+			continue
+		}
+		if len(stack) == 0 {
+			continue
+		}
+		section := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		section.contentEnd, section.end = match[0], match[1]
+		sections = append(sections, section)
+	}
+	for _, section := range stack {
+		section.contentEnd, section.end = len(raw), len(raw)
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+// fitTokenBudget shortens or drops trimmable sections of raw output until its plain text fits within
+// maxTokens. The lowest priority section goes first, earliest in the output on ties. The last section
+// trimmed is shortened rather than dropped when that's enough. Other markers are kept; trimmable markers
+// are removed. It returns the fitted output and the number of sections trimmed.
+func fitTokenBudget(raw string, maxTokens int, count Tokenizer) (string, int, error) {
+	trimmed := 0
+	for {
+		tokens := count(stripMarkers(raw))
+		if tokens <= maxTokens {
+			return trimMarkerPattern.ReplaceAllString(raw, ""), trimmed, nil
+		}
+		sections := parseTrimSections(raw)
+		if len(sections) == 0 {
+			return "", trimmed, NewTokenBudgetError(maxTokens, tokens)
+		}
+
+		lowest := sections[0]
+		for _, section := range sections[1:] {
+			if section.priority < lowest.priority ||
+				(section.priority == lowest.priority && section.start < lowest.start) {
+				lowest = section
+			}
+		}
+		trimmed++
+
+		content := raw[lowest.contentStart:lowest.contentEnd]
+		replacement := ""
+		if !lowest.nested && !markerPattern.MatchString(content) {
+			replacement = shorten(content, count(content)-(tokens-maxTokens), count)
+		}
+		raw = raw[:lowest.start] + replacement + raw[lowest.end:]
+	}
+}
+
+// shorten returns the longest prefix of text that, with an ellipsis, counts as at most target tokens, or
+// an empty string if none does
+func shorten(text string, target int, count Tokenizer) string {
+	if target <= 0 {
+		return ""
+	}
+	runes := []rune(text)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if count(string(runes[:mid])+ellipsis) <= target {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	if lo == 0 {
+		return ""
+	}
+	return strings.TrimRightFunc(string(runes[:lo]), func(r rune) bool { return r == ' ' }) + ellipsis
+}
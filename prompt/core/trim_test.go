@@ -0,0 +1,88 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countRunes is a tokenizer counting one token per character, to keep budgets easy to reason about
+func countRunes(text string) int {
+	return len([]rune(text))
+}
+
+func TestFitTokenBudget(t *testing.T) {
+	section := func(priority int, content string) string {
+		return trimmable(priority) + content + endtrimmable()
+	}
+	raw := "Q:" + section(2, "aaaa") + section(1, "bbbb") + section(1, "cccc")
+
+	tests := []struct {
+		name      string
+		maxTokens int
+		expected  string
+		trimmed   int
+	}{
+		{"fits", 14, "Q:aaaabbbbcccc", 0},
+		{"drops lowest priority first", 10, "Q:aaaacccc", 1},
+		{"shortens the last section trimmed", 12, "Q:aaaab…cccc", 1},
+		{"drops by priority then position", 6, "Q:aaaa", 2},
+		{"shortens higher priority once lower are gone", 4, "Q:a…", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, trimmed, err := fitTokenBudget(raw, tt.maxTokens, countRunes)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, output)
+			assert.Equal(t, tt.trimmed, trimmed)
+		})
+	}
+
+	_, _, err := fitTokenBudget(raw, 1, countRunes)
+	var budgetErr *TokenBudgetError
+	require.ErrorAs(t, err, &budgetErr)
+	assert.Equal(t, 2, budgetErr.Tokens)
+}
+
+func TestFitTokenBudget_Nested(t *testing.T) {
+	raw := trimmable(1) + "outer " + trimmable(2) + "inner" + endtrimmable() + endtrimmable() + "end"
+
+	// The outer section has the lowest priority and is dropped whole, along with everything inside it
+	output, trimmed, err := fitTokenBudget(raw, 5, countRunes)
+	require.NoError(t, err)
+	assert.Equal(t, "end", output)
+	assert.Equal(t, 1, trimmed)
+}
+
+func TestBuild_TokenBudget(t *testing.T) {
+	registry := newTestRegistry(t, map[string]string{"chat.tmpl": `[[role "system"]]Rules[[range .history]][[trimmable 1]][[role "user"]][[.]][[endtrimmable]][[end]][[role "user"]]Now`})
+	require.NoError(t, registry.SetConfig("chat.json", `{"history": ["first message", "second message"]}`))
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	ctx := context.Background()
+	budget := WithTokenBudget(len("RulesNow")+len("second message"), countRunes)
+
+	result, err := system.BuildWithResult(ctx, "chat.tmpl", "chat.json", budget, WithMessages())
+	require.NoError(t, err)
+	assert.Equal(t, "Rulessecond messageNow", result.Output)
+	assert.Equal(t, 1, result.TrimmedSections)
+	assert.Equal(t, []Message{
+		{Role: RoleSystem, Content: "Rules"},
+		{Role: RoleUser, Content: "second message"},
+		{Role: RoleUser, Content: "Now"},
+	}, result.Messages)
+
+	var buf bytes.Buffer
+	require.NoError(t, system.BuildTo(ctx, &buf, "chat.tmpl", "chat.json", budget))
+	assert.Equal(t, result.Output, buf.String())
+
+	// Without a budget the markers are just dropped
+	output, err := system.Build(ctx, "chat.tmpl", "chat.json")
+	require.NoError(t, err)
+	assert.Equal(t, "Rulesfirst messagesecond messageNow", output)
+	assert.False(t, strings.Contains(output, markerPrefix))
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// UsageRecord notes that a template was rendered, so templates nobody renders anymore can be found and pruned
+type UsageRecord struct {
+	Time     time.Time `json:"time"`
+	Template string    `json:"template"`
+	// Dependencies are the templates the rendered template includes, which were rendered along with it
+	Dependencies []string `json:"dependencies,omitempty"`
+}
+
+// UsageSink receives a record of every template a PromptSystem renders. Unlike an AuditSink, a sink that
+// fails to record doesn't fail the build, since usage is only advisory. The error is logged instead.
+type UsageSink interface {
+	Record(ctx context.Context, record UsageRecord) error
+}
+
+// recordUsage notes a rendered template in the system's usage sink, if it has one. The template's
+// dependencies must be loaded.
+func (s *PromptSystem) recordUsage(ctx context.Context, template *Template) {
+	if s.Usage == nil {
+		return
+	}
+	record := UsageRecord{
+		Time:         time.Now().UTC(),
+		Template:     template.Path,
+		Dependencies: template.Dependencies(),
+	}
+	if err := s.Usage.Record(ctx, record); err != nil {
+		template.logger().WarnContext(ctx, "failed to record template usage", "template", template.Path, "error", err)
+	}
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/notzree/rprompt/v2/utils"
+)
+
+// ValidationMode controls how strictly a config is checked against the variables a template uses
+type ValidationMode int
+
+const (
+	// ValidationDefault fails the build when top-level fields are missing and reports every other issue in the
+	// BuildResult. It is the zero value and matches the behavior before validation modes existed.
+	ValidationDefault ValidationMode = iota
+	// ValidationOff skips validation entirely; missing values render as "<no value>"
+	ValidationOff
+	// ValidationWarn reports every issue in the BuildResult without failing the build
+	ValidationWarn
+	// ValidationStrict fails the build on any issue, including unused config keys
+	ValidationStrict
+)
+
+var validationModeNames = map[ValidationMode]string{
+	ValidationDefault: "default",
+	ValidationOff:     "off",
+	ValidationWarn:    "warn",
+	ValidationStrict:  "strict",
+}
+
+func (m ValidationMode) String() string {
+	if name, ok := validationModeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("ValidationMode(%d)", int(m))
+}
+
+// ParseValidationMode parses one of "default", "off", "warn" or "strict"
+func ParseValidationMode(s string) (ValidationMode, error) {
+	for mode, name := range validationModeNames {
+		if strings.EqualFold(s, name) {
+			return mode, nil
+		}
+	}
+	return ValidationDefault, fmt.Errorf("unknown validation mode %q, expected one of: default, off, warn, strict", s)
+}
+
+type IssueKind string
+
+const (
+	// IssueMissing is a variable the template uses that the config doesn't provide
+	IssueMissing IssueKind = "missing"
+	// IssueUnused is a config value no template uses
+	IssueUnused IssueKind = "unused"
+	// IssueTypeMismatch is a config value whose shape doesn't match how the template uses it
+	IssueTypeMismatch IssueKind = "type_mismatch"
+)
+
+// ValidationIssue is a single problem found when checking a config against a template
+type ValidationIssue struct {
+	Kind    IssueKind `json:"kind"`
+	Path    string    `json:"path"`
+	Message string    `json:"message"`
+}
+
+// Validate checks cfg against the variables templatePath uses and returns every issue found. Unlike a build,
+// it reports the issues rather than failing on them.
+func (s *PromptSystem) Validate(ctx context.Context, templatePath string, cfg *Config) ([]ValidationIssue, error) {
+	template, err := s.find(ctx, templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("err finding template: %w", err)
+	}
+	required, err := template.GenerateConfig(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return validate(ValidationWarn, template, required, cfg)
+}
+
+// validate checks cfg against the variables in required, as generated for t, according to mode. The issues
+// found are always returned; the error is non-nil when the mode says the build should fail.
+func validate(mode ValidationMode, t *Template, required *Config, cfg *Config) ([]ValidationIssue, error) {
+	if mode == ValidationOff {
+		return nil, nil
+	}
+
+	issues := []ValidationIssue{}
+	compareRequired(required.Config, cfg.Config, "", &issues)
+	for _, key := range unusedKeys(utils.FlattenKeys(cfg.Config), utils.FlattenKeys(required.Config)) {
+		issues = append(issues, ValidationIssue{
+			Kind:    IssueUnused,
+			Path:    key,
+			Message: fmt.Sprintf("%s is not used by the template", key),
+		})
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].Path != issues[j].Path {
+			return issues[i].Path < issues[j].Path
+		}
+		return issues[i].Kind < issues[j].Kind
+	})
+
+	switch mode {
+	case ValidationStrict:
+		if len(issues) > 0 {
+			return issues, NewValidationError(issues)
+		}
+	case ValidationDefault:
+		if err := checkMissingFields(t, required, *cfg); err != nil {
+			return issues, err
+		}
+	}
+	return issues, nil
+}
+
+// compareRequired walks the required variable structure alongside the config data, recording variables the
+// config is missing and values that aren't objects where the template accesses fields on them
+func compareRequired(required, data map[string]any, prefix string, issues *[]ValidationIssue) {
+	for key, requiredValue := range required {
+		// $-prefixed identifiers are template variables, not config values
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		value, ok := data[key]
+		if !ok || value == nil {
+			*issues = append(*issues, ValidationIssue{
+				Kind:    IssueMissing,
+				Path:    path,
+				Message: fmt.Sprintf("%s is used by the template but missing from the config", path),
+			})
+			continue
+		}
+
+		requiredMap, ok := requiredValue.(map[string]any)
+		if !ok || len(requiredMap) == 0 {
+			continue
+		}
+		valueMap, ok := value.(map[string]any)
+		if !ok {
+			*issues = append(*issues, ValidationIssue{
+				Kind:    IssueTypeMismatch,
+				Path:    path,
+				Message: fmt.Sprintf("%s should be an object but is %T", path, value),
+			})
+			continue
+		}
+		compareRequired(requiredMap, valueMap, path, issues)
+	}
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"context"
+	"sort"
+)
+
+// VariableInfo describes a config value a template uses
+type VariableInfo struct {
+	// Path is the dotted path of the value in the config. Fields of the items of an array are under the
+	// array's path followed by [], such as items[].title.
+	Path string `json:"path"`
+	// Type is the JSON type inferred from how the value is used, see Template.Schema
+	Type string `json:"type"`
+	// Uses are where the template and the templates it includes refer to the value, in source order
+	Uses []VariableUse `json:"uses"`
+	// Conditional is set when every use is inside an if or with block, or is the condition of one, so the
+	// prompt can render without the value
+	Conditional bool `json:"conditional"`
+}
+
+// VariableUse is a place a template refers to a config value
+type VariableUse struct {
+	Template string `json:"template"`
+	// Line and Column are 1-based positions in the template file, front matter included
+	Line        int  `json:"line"`
+	Column      int  `json:"column"`
+	Conditional bool `json:"conditional"`
+}
+
+// Variables returns every config value the template and the templates it includes refer to, sorted by path.
+// Objects are only listed when the template refers to them directly, such as with [[with .user]], and not
+// only to their fields.
+func (t *Template) Variables(ctx context.Context) ([]VariableInfo, error) {
+	if err := t.LoadDependencies(ctx); err != nil {
+		return nil, err
+	}
+	root := &schemaNode{props: map[string]*schemaNode{}}
+	w := &schemaWalker{t: t, vars: map[string]*schemaNode{"$": root}, track: true, tree: t.Tmpl.Tree}
+	w.list(t.Tmpl.Tree.Root, root)
+
+	variables := []VariableInfo{}
+	var collect func(node *schemaNode, path string)
+	collect = func(node *schemaNode, path string) {
+		if len(node.uses) > 0 {
+			info := VariableInfo{Path: path, Type: node.schema().Type, Uses: node.uses, Conditional: true}
+			sort.Slice(info.Uses, func(i, j int) bool {
+				a, b := info.Uses[i], info.Uses[j]
+				if a.Template != b.Template {
+					return a.Template < b.Template
+				}
+				if a.Line != b.Line {
+					return a.Line < b.Line
+				}
+				return a.Column < b.Column
+			})
+			for _, use := range info.Uses {
+				info.Conditional = info.Conditional && use.Conditional
+			}
+			variables = append(variables, info)
+		}
+		for name, prop := range node.props {
+			collect(prop, joinPath(path, name))
+		}
+		if node.items != nil {
+			collect(node.items, path+"[]")
+		}
+	}
+	collect(root, "")
+	// The system sets these, see Target
+	kept := variables[:0]
+	for _, info := range variables {
+		if info.Path != TargetModelKey && info.Path != TargetProviderKey {
+			kept = append(kept, info)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Path < kept[j].Path })
+	return kept, nil
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/notzree/rprompt/v2/utils"
+)
+
+// WarningKind classifies a build warning
+type WarningKind string
+
+const (
+	// WarningUnusedKey is a config value no template uses
+	WarningUnusedKey WarningKind = "unused_key"
+	// WarningNoValue is a variable the config doesn't set, rendered as <no value>
+	WarningNoValue WarningKind = "no_value"
+	// WarningDeprecated is a config value the template's front matter marks as deprecated
+	WarningDeprecated WarningKind = "deprecated"
+	// WarningDeprecatedTemplate is a template, or one it includes, whose front matter marks it as deprecated
+	WarningDeprecatedTemplate WarningKind = "deprecated_template"
+	// WarningPII is a config value holding likely PII, see PromptSystem.PII
+	WarningPII WarningKind = "pii"
+	// WarningNearTokenLimit is output within NearTokenLimitRatio of the build's token budget
+	WarningNearTokenLimit WarningKind = "near_token_limit"
+)
+
+// NearTokenLimitRatio is the share of a token budget above which a build warns it is close to the limit
+const NearTokenLimitRatio = 0.9
+
+// noValue is what text/template renders for a missing map key
+const noValue = "<no value>"
+
+// Warning is something about a build that doesn't stop it but is likely a mistake
+type Warning struct {
+	Kind WarningKind `json:"kind"`
+	// Path is the dotted config path the warning is about, empty for warnings about the whole output
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+// warningCollector gathers the warnings of a single build
+type warningCollector struct {
+	warnings []Warning
+}
+
+func (c *warningCollector) add(kind WarningKind, path, format string, args ...any) {
+	c.warnings = append(c.warnings, Warning{Kind: kind, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *warningCollector) unusedKeys(keys []string) {
+	for _, key := range keys {
+		c.add(WarningUnusedKey, key, "%s is set in the config but not used by the template", key)
+	}
+}
+
+// noValues warns about each variable the config is missing when the output shows it rendered as <no value>
+func (c *warningCollector) noValues(output string, issues []ValidationIssue) {
+	count := strings.Count(output, noValue)
+	if count == 0 {
+		return
+	}
+	found := false
+	for _, issue := range issues {
+		if issue.Kind == IssueMissing {
+			c.add(WarningNoValue, issue.Path, "%s is missing from the config and may render as %s", issue.Path, noValue)
+			found = true
+		}
+	}
+	if !found {
+		c.add(WarningNoValue, "", "the output contains %s %d time(s)", noValue, count)
+	}
+}
+
+// deprecated warns about each deprecated path the config sets
+func (c *warningCollector) deprecated(fm *FrontMatter, config map[string]any) {
+	if len(fm.Deprecated) == 0 {
+		return
+	}
+	paths := make([]string, 0, len(fm.Deprecated))
+	for path := range fm.Deprecated {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if _, ok := utils.GetPath(config, path); !ok {
+			continue
+		}
+		if note := fm.Deprecated[path]; note != "" {
+			c.add(WarningDeprecated, path, "%s is deprecated: %s", path, note)
+		} else {
+			c.add(WarningDeprecated, path, "%s is deprecated", path)
+		}
+	}
+}
+
+// deprecations warns about deprecated config paths the config sets and deprecated templates, looking at the
+// front matter of the template and of each dependency. The dependencies must be loaded.
+func (c *warningCollector) deprecations(template *Template, fm *FrontMatter, config map[string]any) error {
+	c.deprecated(fm, config)
+	if fm.Deprecation != nil {
+		c.add(WarningDeprecatedTemplate, "", "%s", fm.Deprecation.Message(template.Path))
+	}
+	for _, dep := range template.Dependencies() {
+		source, _ := template.source(dep)
+		depFrontMatter, err := parseFrontMatter(dep, source)
+		if err != nil {
+			return err
+		}
+		c.deprecated(depFrontMatter, config)
+		if depFrontMatter.Deprecation != nil {
+			c.add(WarningDeprecatedTemplate, "", "%s (included by %s)", depFrontMatter.Deprecation.Message(dep), template.Path)
+		}
+	}
+	return nil
+}
+
+// pii warns about each config value holding likely PII, noting whether it was masked
+func (c *warningCollector) pii(matches []PIIMatch, masked bool) {
+	for _, match := range matches {
+		if masked {
+			c.add(WarningPII, match.Path, "%s held likely PII (%s), masked before rendering", match.Path, strings.Join(match.Kinds, ", "))
+		} else {
+			c.add(WarningPII, match.Path, "%s holds likely PII (%s)", match.Path, strings.Join(match.Kinds, ", "))
+		}
+	}
+}
+
+// nearTokenLimit warns when tokens are within NearTokenLimitRatio of a positive budget
+func (c *warningCollector) nearTokenLimit(tokens, budget int) {
+	if budget > 0 && float64(tokens) >= NearTokenLimitRatio*float64(budget) {
+		c.add(WarningNearTokenLimit, "", "the prompt is %d tokens, %.0f%% of the budget of %d", tokens, 100*float64(tokens)/float64(budget), budget)
+	}
+}
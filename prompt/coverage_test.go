@@ -0,0 +1,79 @@
+package prompt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCoverage(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "---\ndescription: greeting\n---\n"+
+		"[[if .formal]]Dear[[else]]Hi[[end]] [[.name]]\n"+
+		"[[range .items]]- [[.]]\n[[end]]"+
+		`[[template "footer.tmpl" .]]`)
+	createTestFile(t, tempDir, "footer.tmpl", `[[with .signature]][[.]][[end]]`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	coverage := NewCoverage()
+	ctx := context.Background()
+
+	output, err := system.Build(ctx, "main.tmpl", "", WithCoverage(coverage), WithData(map[string]any{
+		"formal": true, "name": "Alice", "items": []any{"a", "b"}, "signature": "",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "Dear Alice\n- a\n- b\n", output, "markers are dropped from the output")
+
+	report := coverage.Report()
+	assert.Equal(t, 6, report.Total)
+	assert.Equal(t, 3, report.Covered)
+	assert.Equal(t, []string{
+		"footer.tmpl:1:8 with then",
+		"main.tmpl:4:6 if else",
+		"main.tmpl:5:9 range else",
+	}, branchNames(report.Uncovered()))
+	assert.Equal(t, 2, report.Branches[len(report.Branches)-2].Hits, "one hit per range iteration")
+
+	_, err = system.Build(ctx, "main.tmpl", "", WithCoverage(coverage), WithData(map[string]any{
+		"formal": false, "name": "Bob", "items": []any{}, "signature": "Thanks",
+	}))
+	require.NoError(t, err)
+	report = coverage.Report()
+	assert.Equal(t, 6, report.Covered)
+	assert.Empty(t, report.Uncovered())
+	assert.InDelta(t, 100, report.Percent(), 0.001)
+}
+
+func TestWithCoverage_ElseIf(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[if eq .n 1]]one[[else if eq .n 2]]two[[end]]`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	coverage := NewCoverage()
+
+	output, err := system.Build(context.Background(), "main.tmpl", "", WithCoverage(coverage), WithValue("n", 2))
+	require.NoError(t, err)
+	assert.Equal(t, "two", output)
+	assert.Equal(t, []string{"main.tmpl:1:6 if then", "main.tmpl:1:28 if else"}, branchNames(coverage.Report().Uncovered()))
+}
+
+func TestApp_TestCoverage(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "greeting.tmpl", `[[if .formal]]Dear[[else]]Hi[[end]] [[.name]]`)
+	createTestFile(t, tempDir, "greeting"+PromptTestSuffix, "cases:\n  - data: {formal: true, name: Alice}\n    contains: [Dear]\n")
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "test", "--coverage"}))
+	assert.Contains(t, stdout.String(), "Branch coverage: 1 of 2 (50.0%)")
+	assert.Contains(t, stdout.String(), "not rendered: greeting.tmpl:1:6 if else")
+}
+
+func branchNames(branches []BranchCoverage) []string {
+	names := make([]string, len(branches))
+	for i, b := range branches {
+		names[i] = b.String()
+	}
+	return names
+}
@@ -0,0 +1,120 @@
+package prompt
+
+import (
+	"context"
+	"sort"
+
+	"github.com/notzree/rprompt/v2/utils"
+)
+
+// DeprecatedTemplateUsage is a deprecated template and the templates that still include it
+type DeprecatedTemplateUsage struct {
+	Path        string `json:"path"`
+	Replacement string `json:"replacement,omitempty"`
+	Note        string `json:"note,omitempty"`
+	// IncludedBy are the templates that include it directly, sorted
+	IncludedBy []string `json:"included_by"`
+}
+
+// DeprecatedVariableUsage is a config path a template's front matter deprecates and the configs that still set it
+type DeprecatedVariableUsage struct {
+	// Template declares the path deprecated
+	Template string `json:"template"`
+	Path     string `json:"path"`
+	Note     string `json:"note,omitempty"`
+	// SetBy are the configs in the registry that set the path, sorted
+	SetBy []string `json:"set_by"`
+}
+
+// DeprecationReport lists every deprecated template and variable in a registry along with what still uses them
+type DeprecationReport struct {
+	Templates []DeprecatedTemplateUsage `json:"templates"`
+	Variables []DeprecatedVariableUsage `json:"variables"`
+	// Errors maps templates and configs that couldn't be read to why
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// FindDeprecations walks every template and config in the registry and reports the usages of deprecated
+// templates and variables, so they can be cleaned up before they are removed. Templates that haven't changed
+// since the registry's index was written aren't read again.
+func FindDeprecations(ctx context.Context, r *LocalPromptRegistry) (*DeprecationReport, error) {
+	entries, err := r.entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DeprecationReport{
+		Templates: []DeprecatedTemplateUsage{},
+		Variables: []DeprecatedVariableUsage{},
+	}
+	fail := func(path, message string) {
+		if report.Errors == nil {
+			report.Errors = make(map[string]string)
+		}
+		report.Errors[path] = message
+	}
+	includers := make(map[string][]string)
+	deprecated := make(map[string]*TemplateDeprecation)
+	for _, entry := range entries {
+		if entry.Error != "" {
+			fail(entry.Path, entry.Error)
+		}
+		if entry.Deprecation != nil {
+			deprecated[entry.Path] = entry.Deprecation
+		}
+		for variable, note := range entry.Deprecated {
+			report.Variables = append(report.Variables, DeprecatedVariableUsage{Template: entry.Path, Path: variable, Note: note, SetBy: []string{}})
+		}
+		// Only each template's own includes count, so each includer is listed once
+		for _, dep := range entry.Dependencies {
+			includers[dep] = append(includers[dep], entry.Path)
+		}
+	}
+
+	for path, deprecation := range deprecated {
+		includedBy := append([]string{}, includers[path]...)
+		sort.Strings(includedBy)
+		report.Templates = append(report.Templates, DeprecatedTemplateUsage{
+			Path:        path,
+			Replacement: deprecation.Replacement,
+			Note:        deprecation.Note,
+			IncludedBy:  includedBy,
+		})
+	}
+	sort.Slice(report.Templates, func(i, j int) bool { return report.Templates[i].Path < report.Templates[j].Path })
+
+	if len(report.Variables) > 0 {
+		configPaths, err := r.ListConfigs()
+		if err != nil {
+			return nil, err
+		}
+		for _, configPath := range configPaths {
+			cfg, err := r.LoadConfig(ctx, configPath)
+			if err != nil {
+				fail(configPath, err.Error())
+				continue
+			}
+			for i := range report.Variables {
+				if _, ok := utils.GetPath(cfg.Config, report.Variables[i].Path); ok {
+					report.Variables[i].SetBy = append(report.Variables[i].SetBy, configPath)
+				}
+			}
+		}
+	}
+	sort.Slice(report.Variables, func(i, j int) bool {
+		if report.Variables[i].Template != report.Variables[j].Template {
+			return report.Variables[i].Template < report.Variables[j].Template
+		}
+		return report.Variables[i].Path < report.Variables[j].Path
+	})
+	return report, nil
+}
+
+// mapStrings applies f to every string in values
+func mapStrings(values []string, f func(string) string) []string {
+	mapped := make([]string, len(values))
+	for i, value := range values {
+		mapped[i] = f(value)
+	}
+	return mapped
+}
@@ -0,0 +1,60 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildWarnings_DeprecatedTemplate(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "old.tmpl" .]]`)
+	createTestFile(t, tempDir, "old.tmpl", "---\ndeprecation:\n  replacement: new.tmpl\ndeprecated:\n  nick: use name\n---\nHi [[.name]]")
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	result, err := system.BuildWithResult(ctx, "main.tmpl", "", WithData(map[string]any{"name": "John", "nick": "jd"}))
+	require.NoError(t, err)
+	assert.Contains(t, result.Warnings, Warning{Kind: WarningDeprecated, Path: "nick", Message: "nick is deprecated: use name"})
+	assert.Contains(t, result.Warnings, Warning{Kind: WarningDeprecatedTemplate, Message: "old.tmpl is deprecated, use new.tmpl instead (included by main.tmpl)"})
+
+	result, err = system.BuildWithResult(ctx, "old.tmpl", "", WithValue("name", "John"))
+	require.NoError(t, err)
+	assert.Equal(t, []Warning{{Kind: WarningDeprecatedTemplate, Message: "old.tmpl is deprecated, use new.tmpl instead"}}, result.Warnings)
+}
+
+func TestFindDeprecations(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "a.tmpl", `[[template "old" .]] [[template "old.tmpl" .]]`)
+	createTestFile(t, tempDir, "b.tmpl", `[[template "old.tmpl" .]]`)
+	createTestFile(t, tempDir, "old.tmpl", "---\ndeprecation:\n  note: too chatty\ndeprecated:\n  user.nick: use user.name\n---\nHi")
+	createTestFile(t, tempDir, "a.json", `{"user": {"nick": "jd"}}`)
+	createTestFile(t, tempDir, "b.json", `{"user": {"name": "John"}}`)
+
+	report, err := FindDeprecations(context.Background(), NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	assert.Equal(t, []DeprecatedTemplateUsage{{Path: "old.tmpl", Note: "too chatty", IncludedBy: []string{"a.tmpl", "b.tmpl"}}}, report.Templates)
+	assert.Equal(t, []DeprecatedVariableUsage{{Template: "old.tmpl", Path: "user.nick", Note: "use user.name", SetBy: []string{"a.json"}}}, report.Variables)
+	assert.Empty(t, report.Errors)
+}
+
+func TestApp_Check(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "old.tmpl" .]]`)
+	createTestFile(t, tempDir, "old.tmpl", "---\ndeprecation:\n  replacement: new.tmpl\n---\nHi")
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "check"}))
+	assert.Contains(t, stdout.String(), "old.tmpl is deprecated, use new.tmpl instead\n    included by main.tmpl")
+
+	stdout.Reset()
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "--json", "check"}))
+	var report DeprecationReport
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	require.Len(t, report.Templates, 1)
+	assert.Equal(t, []string{"main.tmpl"}, report.Templates[0].IncludedBy)
+}
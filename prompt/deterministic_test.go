@@ -0,0 +1,56 @@
+package prompt
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDeterministic(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[now.Format "2006-01-02"]] [[randInt 1000]] [[range shuffle .examples]][[.]] [[end]]`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	data := WithData(map[string]any{"examples": []any{"a", "b", "c", "d", "e", "f", "g", "h"}})
+	ctx := context.Background()
+
+	first, err := system.Build(ctx, "main.tmpl", "", data, WithDeterministic())
+	require.NoError(t, err)
+	assert.Regexp(t, `^2000-01-01 \d+ ([a-h] ){8}$`, first)
+
+	var wg sync.WaitGroup
+	outputs := make([]string, 8)
+	for i := range outputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			outputs[i], _ = system.Build(ctx, "main.tmpl", "", data, WithDeterministic())
+		}()
+	}
+	wg.Wait()
+	for _, output := range outputs {
+		assert.Equal(t, first, output)
+	}
+
+	// Without the option the template sees the real clock
+	live, err := system.Build(ctx, "main.tmpl", "", data)
+	require.NoError(t, err)
+	assert.NotContains(t, live, "2000-01-01")
+}
+
+func TestRandomFuncs_Errors(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "zero.tmpl", `[[randInt 0]]`)
+	createTestFile(t, tempDir, "scalar.tmpl", `[[shuffle .name]]`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = system.Build(ctx, "zero.tmpl", "", WithDeterministic())
+	assert.ErrorContains(t, err, "n must be positive")
+	_, err = system.Build(ctx, "scalar.tmpl", "", WithValue("name", "Alice"))
+	assert.ErrorContains(t, err, "expected a list")
+}
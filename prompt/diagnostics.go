@@ -0,0 +1,433 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/notzree/rprompt/v2/prompt/core"
+)
+
+// Formats 'rprompt lint' and 'rprompt check' write their results in
+const (
+	DiagnosticsText = "text"
+	// DiagnosticsSARIF is SARIF 2.1.0, which code scanning tools such as GitHub's and the VS Code SARIF viewer read
+	DiagnosticsSARIF = "sarif"
+	// DiagnosticsRDJSON is reviewdog's diagnostic format, for comments on code reviews
+	DiagnosticsRDJSON = "rdjson"
+)
+
+// Rules of the diagnostics 'rprompt lint' and 'rprompt check' report
+const (
+	// RuleInvalidTemplate flags the problems Template.Validate finds
+	RuleInvalidTemplate = "invalid-template"
+	// RuleDeprecatedTemplate flags includes of a template its front matter deprecates
+	RuleDeprecatedTemplate = "deprecated-template"
+	// RuleDeprecatedVariable flags configs setting a path a template's front matter deprecates
+	RuleDeprecatedVariable = "deprecated-variable"
+	// RuleMissingOwner flags templates without an owner
+	RuleMissingOwner = "missing-owner"
+	// RuleMissingTranslation flags templates missing a variant for some of the registry's locales
+	RuleMissingTranslation = "missing-translation"
+)
+
+var ruleDescriptions = map[string]string{
+	RuleInvalidTemplate:    "The template doesn't parse, calls an unknown function or includes a missing template",
+	RuleDeprecatedTemplate: "The template includes a deprecated template",
+	RuleDeprecatedVariable: "The config sets a deprecated variable",
+	RuleMissingOwner:       "The template has no owner",
+	RuleMissingTranslation: "The template is missing translations",
+}
+
+// Severities of diagnostics
+const (
+	DiagnosticError   = "error"
+	DiagnosticWarning = "warning"
+)
+
+// Diagnostic is a problem in a file of the registry, located as precisely as it is known
+type Diagnostic struct {
+	// Path is the path of the file, within the registry unless it has been rewritten for display
+	Path string `json:"path"`
+	// Line and Column are 1-based, and 0 when the problem is with the file as a whole or only its line is known
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	// Snippet is the offending source line with a caret under the column, when it is known
+	Snippet string `json:"snippet,omitempty"`
+}
+
+func (d Diagnostic) String() string {
+	location := d.Path
+	if d.Line > 0 {
+		location += ":" + strconv.Itoa(d.Line)
+		if d.Column > 0 {
+			location += ":" + strconv.Itoa(d.Column)
+		}
+	}
+	return fmt.Sprintf("%s: %s %s: %s", location, d.Severity, d.Rule, d.Message)
+}
+
+// LintReport lists the problems Template.Validate finds in a registry's templates
+type LintReport struct {
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	// Errors maps templates that couldn't be read to why
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// LintRegistry validates every template in the registry, see Template.Validate
+func LintRegistry(ctx context.Context, r *LocalPromptRegistry) (*LintReport, error) {
+	paths, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	report := &LintReport{Diagnostics: []Diagnostic{}}
+	for _, path := range paths {
+		problems, err := validateTemplate(ctx, r, path)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if report.Errors == nil {
+				report.Errors = make(map[string]string)
+			}
+			report.Errors[path] = err.Error()
+			continue
+		}
+		for _, problem := range problems {
+			report.Diagnostics = append(report.Diagnostics, Diagnostic{
+				Path:     problem.Path,
+				Line:     problem.Line,
+				Column:   problem.Column,
+				Rule:     RuleInvalidTemplate,
+				Severity: DiagnosticError,
+				Message:  problem.Message,
+				Snippet:  problem.Snippet,
+			})
+		}
+	}
+	return report, nil
+}
+
+func validateTemplate(ctx context.Context, r *LocalPromptRegistry, path string) ([]*TemplateError, error) {
+	template, err := r.Find(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return template.Validate(ctx)
+}
+
+// Diagnostics returns a warning for each include of a deprecated template, at the include, and for each config
+// setting a deprecated variable, at its key
+func (d *DeprecationReport) Diagnostics(ctx context.Context, r *LocalPromptRegistry) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, usage := range d.Templates {
+		deprecation := TemplateDeprecation{Replacement: usage.Replacement, Note: usage.Note}
+		for _, includer := range usage.IncludedBy {
+			diagnostic := Diagnostic{Path: includer, Rule: RuleDeprecatedTemplate, Severity: DiagnosticWarning, Message: deprecation.Message(usage.Path)}
+			if template, err := r.Find(ctx, includer); err == nil {
+				diagnostic.Line, diagnostic.Column = includePosition(template.OriginalContent, usage.Path, core.RegistryExtensions(r))
+			}
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+	for _, usage := range d.Variables {
+		message := fmt.Sprintf("%s is deprecated by %s", usage.Path, usage.Template)
+		if usage.Note != "" {
+			message += ": " + usage.Note
+		}
+		for _, config := range usage.SetBy {
+			diagnostic := Diagnostic{Path: config, Rule: RuleDeprecatedVariable, Severity: DiagnosticWarning, Message: message}
+			if data, err := os.ReadFile(r.fullPath(config)); err == nil {
+				diagnostic.Line, diagnostic.Column = jsonKeyPosition(data, strings.Split(usage.Path, "."))
+			}
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+	return diagnostics
+}
+
+// includePosition returns the line and column of the name in the first template or block action of content
+// that includes the template at path, or zeros when there is none
+func includePosition(content, path string, exts []string) (int, int) {
+	for i, line := range strings.Split(content, "\n") {
+		for _, m := range includeNamePattern.FindAllStringSubmatchIndex(line, -1) {
+			name, err := strconv.Unquote(line[m[2]:m[3]])
+			if err != nil {
+				continue
+			}
+			for _, candidate := range core.DependencyCandidates(name, exts) {
+				if candidate == path {
+					return i + 1, utf8.RuneCountInString(line[:m[2]]) + 1
+				}
+			}
+		}
+	}
+	return 0, 0
+}
+
+// jsonKeyPosition returns the line and column of the key at the end of a path of object keys in a JSON
+// document, or zeros when the document doesn't have it
+func jsonKeyPosition(data []byte, path []string) (int, int) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	offset, ok := findJSONKey(dec, data, path)
+	if !ok {
+		return 0, 0
+	}
+	start := bytes.LastIndexByte(data[:offset], '\n') + 1
+	return bytes.Count(data[:offset], []byte("\n")) + 1, utf8.RuneCount(data[start:offset]) + 1
+}
+
+// findJSONKey reads the next value from dec and returns the offset of the opening quote of the key at the end
+// of path within it
+func findJSONKey(dec *json.Decoder, data []byte, path []string) (int, bool) {
+	tok, err := dec.Token()
+	if err != nil {
+		return 0, false
+	}
+	if tok != json.Delim('{') {
+		return 0, false
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, false
+		}
+		key, _ := tok.(string)
+		if key == path[0] {
+			if len(path) == 1 {
+				// The decoder stops just past the key's closing quote
+				end := int(dec.InputOffset())
+				return bytes.LastIndexByte(data[:end-1], '"'), true
+			}
+			return findJSONKey(dec, data, path[1:])
+		}
+		if tok, err = dec.Token(); err != nil {
+			return 0, false
+		}
+		if delim, ok := tok.(json.Delim); ok && !skipJSON(dec, delim) {
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// skipJSON reads the rest of the object or array opened by delim from dec
+func skipJSON(dec *json.Decoder, delim json.Delim) bool {
+	if delim != '{' && delim != '[' {
+		return true
+	}
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return true
+}
+
+// ParseDiagnosticsFormat checks that format is one WriteDiagnostics accepts, along with text
+func ParseDiagnosticsFormat(format string) (string, error) {
+	switch format {
+	case DiagnosticsText, DiagnosticsSARIF, DiagnosticsRDJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected one of: %s, %s, %s", format, DiagnosticsText, DiagnosticsSARIF, DiagnosticsRDJSON)
+}
+
+// WriteDiagnostics writes diagnostics as SARIF or rdjson, see DiagnosticsSARIF and DiagnosticsRDJSON, sorted by
+// path and position. Absolute paths are written as file URIs in SARIF.
+func WriteDiagnostics(w io.Writer, format string, diagnostics []Diagnostic) error {
+	diagnostics = append([]Diagnostic(nil), diagnostics...)
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	var v any
+	switch format {
+	case DiagnosticsSARIF:
+		v = sarifReport(diagnostics)
+	case DiagnosticsRDJSON:
+		v = rdjsonReport(diagnostics)
+	default:
+		_, err := ParseDiagnosticsFormat(format)
+		if err == nil {
+			err = fmt.Errorf("diagnostics can't be written as %s", format)
+		}
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("failed to write %s report: %w", format, err)
+	}
+	return nil
+}
+
+// sarifLog is the root of a SARIF 2.1.0 report
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+func sarifReport(diagnostics []Diagnostic) sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "rprompt",
+			Version:        buildVersion(),
+			InformationURI: "https://github.com/notzree/rprompt",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+	rules := make(map[string]bool)
+	for _, d := range diagnostics {
+		if !rules[d.Rule] {
+			rules[d.Rule] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: d.Rule, ShortDescription: sarifMessage{Text: ruleDescriptions[d.Rule]}})
+		}
+		uri := filepath.ToSlash(d.Path)
+		if filepath.IsAbs(d.Path) {
+			uri = pathURI(d.Path)
+		}
+		location := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: uri}}
+		if d.Line > 0 {
+			location.Region = &sarifRegion{StartLine: d.Line, StartColumn: d.Column}
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    d.Rule,
+			Level:     d.Severity,
+			Message:   sarifMessage{Text: d.Message},
+			Locations: []sarifLocation{{PhysicalLocation: location}},
+		})
+	}
+	sort.Slice(run.Tool.Driver.Rules, func(i, j int) bool { return run.Tool.Driver.Rules[i].ID < run.Tool.Driver.Rules[j].ID })
+	return sarifLog{Schema: "https://json.schemastore.org/sarif-2.1.0.json", Version: "2.1.0", Runs: []sarifRun{run}}
+}
+
+// rdjsonResult is the root of a reviewdog diagnostic report
+type rdjsonResult struct {
+	Source      rdjsonSource       `json:"source"`
+	Diagnostics []rdjsonDiagnostic `json:"diagnostics"`
+}
+
+type rdjsonSource struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type rdjsonDiagnostic struct {
+	Message  string         `json:"message"`
+	Location rdjsonLocation `json:"location"`
+	Severity string         `json:"severity"`
+	Code     rdjsonCode     `json:"code"`
+}
+
+type rdjsonLocation struct {
+	Path  string       `json:"path"`
+	Range *rdjsonRange `json:"range,omitempty"`
+}
+
+type rdjsonRange struct {
+	Start rdjsonPosition `json:"start"`
+}
+
+type rdjsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column,omitempty"`
+}
+
+type rdjsonCode struct {
+	Value string `json:"value"`
+}
+
+func rdjsonReport(diagnostics []Diagnostic) rdjsonResult {
+	result := rdjsonResult{Source: rdjsonSource{Name: "rprompt", URL: "https://github.com/notzree/rprompt"}, Diagnostics: []rdjsonDiagnostic{}}
+	for _, d := range diagnostics {
+		location := rdjsonLocation{Path: filepath.ToSlash(d.Path)}
+		if d.Line > 0 {
+			location.Range = &rdjsonRange{Start: rdjsonPosition{Line: d.Line, Column: d.Column}}
+		}
+		result.Diagnostics = append(result.Diagnostics, rdjsonDiagnostic{
+			Message:  d.Message,
+			Location: location,
+			Severity: strings.ToUpper(d.Severity),
+			Code:     rdjsonCode{Value: d.Rule},
+		})
+	}
+	return result
+}
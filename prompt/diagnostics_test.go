@@ -0,0 +1,147 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintRegistry(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "Hi\n[[upper .x]] [[template \"footer\"]]")
+	createTestFile(t, tempDir, "ok.tmpl", `Hi [[.name]]`)
+	createTestFile(t, tempDir, "broken.tmpl", `[[if .x]]`)
+
+	report, err := LintRegistry(context.Background(), NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	require.Len(t, report.Diagnostics, 3)
+	assert.Empty(t, report.Errors)
+
+	assert.Equal(t, "broken.tmpl", report.Diagnostics[0].Path)
+	assert.Contains(t, report.Diagnostics[0].Message, "unexpected EOF")
+	assert.Equal(t, Diagnostic{
+		Path:     "main.tmpl",
+		Line:     2,
+		Column:   3,
+		Rule:     RuleInvalidTemplate,
+		Severity: DiagnosticError,
+		Message:  `function "upper" not defined`,
+		Snippet:  "2 | [[upper .x]] [[template \"footer\"]]\n  |   ^",
+	}, report.Diagnostics[1])
+	assert.Equal(t, `main.tmpl:2:25: error invalid-template: template "footer" not found in the registry`, report.Diagnostics[2].String())
+}
+
+func TestDeprecationReport_Diagnostics(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "---\ndeprecated:\n  user.email: use user.contact\n---\nHi\n  [[template \"old\" .]] [[.user.name]]")
+	createTestFile(t, tempDir, "old.tmpl", "---\ndeprecation:\n  replacement: new.tmpl\n---\nHi")
+	createTestFile(t, tempDir, "main.json", "{\n  \"user\": {\n    \"name\": \"Ada\",\n    \"email\": \"ada@example.com\"\n  }\n}")
+	registry := NewInMemPromptRegistry(tempDir)
+	ctx := context.Background()
+
+	report, err := FindDeprecations(ctx, registry)
+	require.NoError(t, err)
+	assert.Equal(t, []Diagnostic{
+		{Path: "main.tmpl", Line: 6, Column: 14, Rule: RuleDeprecatedTemplate, Severity: DiagnosticWarning, Message: "old.tmpl is deprecated, use new.tmpl instead"},
+		{Path: "main.json", Line: 4, Column: 5, Rule: RuleDeprecatedVariable, Severity: DiagnosticWarning, Message: "user.email is deprecated by main.tmpl: use user.contact"},
+	}, report.Diagnostics(ctx, registry))
+}
+
+func TestJSONKeyPosition(t *testing.T) {
+	data := []byte("{\"a\": [1, {\"b\": 2}], \"b\": {\"c\": {}, \"b\": 1},\n \"é\": {\"x\": true}}")
+	line, column := jsonKeyPosition(data, []string{"b", "b"})
+	assert.Equal(t, []int{1, 37}, []int{line, column})
+	line, column = jsonKeyPosition(data, []string{"é", "x"})
+	assert.Equal(t, []int{2, 8}, []int{line, column})
+	line, column = jsonKeyPosition(data, []string{"a", "b"})
+	assert.Equal(t, []int{0, 0}, []int{line, column}, "arrays aren't searched")
+	line, column = jsonKeyPosition([]byte(`{"a": `), []string{"a", "b"})
+	assert.Equal(t, []int{0, 0}, []int{line, column})
+}
+
+func TestWriteDiagnostics(t *testing.T) {
+	diagnostics := []Diagnostic{
+		{Path: "prompts/main.tmpl", Line: 2, Column: 3, Rule: RuleInvalidTemplate, Severity: DiagnosticError, Message: `function "upper" not defined`},
+		{Path: "prompts/a.tmpl", Rule: RuleMissingOwner, Severity: DiagnosticError, Message: "a.tmpl has no owner"},
+		{Path: "prompts/main.json", Line: 4, Column: 5, Rule: RuleDeprecatedVariable, Severity: DiagnosticWarning, Message: "user.email is deprecated"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteDiagnostics(&buf, DiagnosticsSARIF, diagnostics))
+	var sarif sarifLog
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &sarif))
+	assert.Equal(t, "2.1.0", sarif.Version)
+	require.Len(t, sarif.Runs, 1)
+	run := sarif.Runs[0]
+	assert.Equal(t, "rprompt", run.Tool.Driver.Name)
+	assert.Equal(t, []sarifRule{
+		{ID: RuleDeprecatedVariable, ShortDescription: sarifMessage{Text: ruleDescriptions[RuleDeprecatedVariable]}},
+		{ID: RuleInvalidTemplate, ShortDescription: sarifMessage{Text: ruleDescriptions[RuleInvalidTemplate]}},
+		{ID: RuleMissingOwner, ShortDescription: sarifMessage{Text: ruleDescriptions[RuleMissingOwner]}},
+	}, run.Tool.Driver.Rules)
+	require.Len(t, run.Results, 3)
+	assert.Equal(t, "prompts/a.tmpl", run.Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI, "results are sorted by path")
+	assert.Nil(t, run.Results[0].Locations[0].PhysicalLocation.Region)
+	assert.Equal(t, sarifResult{
+		RuleID:  RuleInvalidTemplate,
+		Level:   "error",
+		Message: sarifMessage{Text: `function "upper" not defined`},
+		Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: "prompts/main.tmpl"},
+			Region:           &sarifRegion{StartLine: 2, StartColumn: 3},
+		}}},
+	}, run.Results[2])
+
+	buf.Reset()
+	require.NoError(t, WriteDiagnostics(&buf, DiagnosticsRDJSON, diagnostics))
+	var rdjson rdjsonResult
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &rdjson))
+	assert.Equal(t, "rprompt", rdjson.Source.Name)
+	require.Len(t, rdjson.Diagnostics, 3)
+	assert.Equal(t, rdjsonDiagnostic{
+		Message:  "user.email is deprecated",
+		Location: rdjsonLocation{Path: "prompts/main.json", Range: &rdjsonRange{Start: rdjsonPosition{Line: 4, Column: 5}}},
+		Severity: "WARNING",
+		Code:     rdjsonCode{Value: RuleDeprecatedVariable},
+	}, rdjson.Diagnostics[1])
+
+	assert.EqualError(t, WriteDiagnostics(&buf, "xml", diagnostics), `unknown format "xml", expected one of: text, sarif, rdjson`)
+	assert.Error(t, WriteDiagnostics(&buf, DiagnosticsText, diagnostics))
+}
+
+func TestApp_LintAndCheckFormats(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "Hi\n[[.name]] [[template \"old\"]]")
+	createTestFile(t, tempDir, "broken.tmpl", "Hi\n[[upper .x]]")
+	createTestFile(t, tempDir, "old.tmpl", "---\ndeprecation:\n  note: gone soon\n---\nHi")
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	ctx := context.Background()
+
+	err := app.Command().Run(ctx, []string{"rprompt", "lint"})
+	assert.EqualError(t, err, "1 problem found")
+	assert.Contains(t, stdout.String(), "broken.tmpl:2:3: error invalid-template: function \"upper\" not defined\n2 | [[upper .x]]")
+
+	stdout.Reset()
+	err = app.Command().Run(ctx, []string{"rprompt", "lint", "--format", "sarif"})
+	assert.EqualError(t, err, "1 problem found")
+	var sarif sarifLog
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &sarif))
+	require.Len(t, sarif.Runs[0].Results, 1)
+	assert.Equal(t, pathURI(filepath.Join(tempDir, "broken.tmpl")), sarif.Runs[0].Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+
+	stdout.Reset()
+	require.NoError(t, app.Command().Run(ctx, []string{"rprompt", "check", "--format", "rdjson"}))
+	var rdjson rdjsonResult
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &rdjson))
+	require.Len(t, rdjson.Diagnostics, 1)
+	assert.Equal(t, rdjsonLocation{Path: filepath.ToSlash(filepath.Join(tempDir, "main.tmpl")), Range: &rdjsonRange{Start: rdjsonPosition{Line: 2, Column: 22}}}, rdjson.Diagnostics[0].Location)
+	assert.Equal(t, "old.tmpl is deprecated: gone soon", rdjson.Diagnostics[0].Message)
+
+	err = app.Command().Run(ctx, []string{"rprompt", "check", "--format", "junit"})
+	assert.EqualError(t, err, `unknown format "junit", expected one of: text, sarif, rdjson`)
+}
@@ -0,0 +1,148 @@
+package prompt
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DiffOp is what happened to a line between two versions of a file
+type DiffOp string
+
+const (
+	DiffEqual   DiffOp = "equal"
+	DiffAdded   DiffOp = "added"
+	DiffRemoved DiffOp = "removed"
+)
+
+// diffContext is how many unchanged lines WriteDiff prints around each change
+const diffContext = 3
+
+// DiffLine is a line of one or both versions of a file
+type DiffLine struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+	// OldLine and NewLine are the line's 1-based numbers in each version, 0 for the version it isn't in
+	OldLine int `json:"old_line,omitempty"`
+	NewLine int `json:"new_line,omitempty"`
+}
+
+// Diff is the line by line difference between two versions of a template or config, see 'rprompt diff'
+type Diff struct {
+	From         string `json:"from"`
+	To           string `json:"to"`
+	LinesAdded   int    `json:"lines_added"`
+	LinesRemoved int    `json:"lines_removed"`
+	// Lines are every line of both versions in order, unchanged lines included
+	Lines []DiffLine `json:"lines"`
+}
+
+// NewDiff compares old, labelled from, with updated, labelled to
+func NewDiff(from, to, old, updated string) *Diff {
+	diff := &Diff{From: from, To: to, Lines: diffLines(old, updated)}
+	for _, line := range diff.Lines {
+		switch line.Op {
+		case DiffAdded:
+			diff.LinesAdded++
+		case DiffRemoved:
+			diff.LinesRemoved++
+		}
+	}
+	return diff
+}
+
+// diffLines returns the lines of old and updated along their longest common subsequence, removed lines before
+// the lines added in their place
+func diffLines(old, updated string) []DiffLine {
+	a, b := strings.Split(old, "\n"), strings.Split(updated, "\n")
+	// lengths[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	lines := make([]DiffLine, 0, max(len(a), len(b)))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, DiffLine{Op: DiffEqual, Text: a[i], OldLine: i + 1, NewLine: j + 1})
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && lengths[i+1][j] >= lengths[i][j+1]):
+			lines = append(lines, DiffLine{Op: DiffRemoved, Text: a[i], OldLine: i + 1})
+			i++
+		default:
+			lines = append(lines, DiffLine{Op: DiffAdded, Text: b[j], NewLine: j + 1})
+			j++
+		}
+	}
+	return lines
+}
+
+// WriteDiff writes the diff in unified format, each change with a few unchanged lines around it
+func WriteDiff(w io.Writer, diff *Diff) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", diff.From, diff.To)
+	for start := 0; start < len(diff.Lines); {
+		if diff.Lines[start].Op == DiffEqual {
+			start++
+			continue
+		}
+		// A hunk runs from the change's context to the first run of unchanged lines too long to share context
+		first, end := max(start-diffContext, 0), start
+		for unchanged := 0; end < len(diff.Lines) && unchanged <= 2*diffContext; end++ {
+			if diff.Lines[end].Op != DiffEqual {
+				unchanged = 0
+			} else {
+				unchanged++
+			}
+		}
+		for end > start && diff.Lines[end-1].Op == DiffEqual {
+			end--
+		}
+		hunk := diff.Lines[first:min(end+diffContext, len(diff.Lines))]
+		writeHunk(&b, hunk)
+		start = end
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeHunk writes a hunk's @@ header and its lines prefixed with a space, + or -
+func writeHunk(b *strings.Builder, hunk []DiffLine) {
+	oldStart, newStart, oldCount, newCount := 0, 0, 0, 0
+	for _, line := range hunk {
+		if line.OldLine > 0 {
+			if oldCount == 0 {
+				oldStart = line.OldLine
+			}
+			oldCount++
+		}
+		if line.NewLine > 0 {
+			if newCount == 0 {
+				newStart = line.NewLine
+			}
+			newCount++
+		}
+	}
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+	for _, line := range hunk {
+		prefix := " "
+		switch line.Op {
+		case DiffAdded:
+			prefix = "+"
+		case DiffRemoved:
+			prefix = "-"
+		}
+		b.WriteString(prefix + line.Text + "\n")
+	}
+}
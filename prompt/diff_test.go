@@ -0,0 +1,87 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDiff(t *testing.T) {
+	diff := NewDiff("a", "b", "one\ntwo\nthree", "one\n2\nthree\nfour")
+	assert.Equal(t, 2, diff.LinesAdded)
+	assert.Equal(t, 1, diff.LinesRemoved)
+	assert.Equal(t, []DiffLine{
+		{Op: DiffEqual, Text: "one", OldLine: 1, NewLine: 1},
+		{Op: DiffRemoved, Text: "two", OldLine: 2},
+		{Op: DiffAdded, Text: "2", NewLine: 2},
+		{Op: DiffEqual, Text: "three", OldLine: 3, NewLine: 3},
+		{Op: DiffAdded, Text: "four", NewLine: 4},
+	}, diff.Lines)
+
+	unchanged := NewDiff("a", "b", "same", "same")
+	assert.Zero(t, unchanged.LinesAdded+unchanged.LinesRemoved)
+}
+
+func TestWriteDiff(t *testing.T) {
+	var old, updated []string
+	for i := 1; i <= 20; i++ {
+		old = append(old, fmt.Sprint(i))
+		updated = append(updated, fmt.Sprint(i))
+	}
+	updated[1], updated[17] = "two", "eighteen"
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteDiff(&buf, NewDiff("main.tmpl@v1", "main.tmpl", strings.Join(old, "\n"), strings.Join(updated, "\n"))))
+	assert.Equal(t, `--- main.tmpl@v1
++++ main.tmpl
+@@ -1,5 +1,5 @@
+ 1
+-2
++two
+ 3
+ 4
+ 5
+@@ -15,6 +15,6 @@
+ 15
+ 16
+ 17
+-18
++eighteen
+ 19
+ 20
+`, buf.String(), "changes far apart get their own hunks")
+}
+
+func TestApp_Diff(t *testing.T) {
+	tempDir := setupTempDir(t)
+	registry := NewInMemPromptRegistry(tempDir)
+	require.NoError(t, registry.SaveTemplate("main.tmpl", "Hello\n[[.name]]"))
+	require.NoError(t, registry.SaveTemplate("main.tmpl", "Hi\n[[.name]]"))
+	app, stdout, _ := newTestApp(t, WithRegistry(registry))
+	ctx := context.Background()
+	run := func(args ...string) error {
+		stdout.Reset()
+		return app.Command().Run(ctx, append([]string{"rprompt"}, args...))
+	}
+
+	require.NoError(t, run("diff", "main.tmpl@v1"))
+	assert.Equal(t, "--- main.tmpl@v1\n+++ main.tmpl\n@@ -1,2 +1,2 @@\n-Hello\n+Hi\n [[.name]]\n", stdout.String())
+
+	require.NoError(t, run("--json", "diff", "main.tmpl@v2", "main.tmpl@v1"))
+	var diff Diff
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &diff))
+	assert.Equal(t, "main.tmpl@v2", diff.From)
+	assert.Equal(t, "main.tmpl@v1", diff.To)
+	assert.Equal(t, 1, diff.LinesAdded)
+	assert.Equal(t, 1, diff.LinesRemoved)
+	assert.Len(t, diff.Lines, 3)
+
+	assert.ErrorContains(t, run("diff"), "one or two versions")
+	assert.ErrorContains(t, run("diff", "main.tmpl@v9"), "no version v9")
+}
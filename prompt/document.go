@@ -0,0 +1,24 @@
+package prompt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// OpenDocument opens a file in the registry directory for the document function. Files are memory-mapped
+// where the platform supports it, so even very large documents are never read into memory whole.
+func (r *LocalPromptRegistry) OpenDocument(ctx context.Context, path string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !filepath.IsLocal(filepath.FromSlash(path)) {
+		return nil, fmt.Errorf("document path %s must be relative and inside the registry", path)
+	}
+	rel, err := r.sandboxed(path)
+	if err != nil {
+		return nil, err
+	}
+	return openMapped(r.fullPath(rel))
+}
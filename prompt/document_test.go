@@ -0,0 +1,69 @@
+package prompt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate_DocumentErrors(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "missing.tmpl", `[[document "missing.md"]]`)
+	createTestFile(t, tempDir, "outside.tmpl", `[[document "../secret.md"]]`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = system.Build(ctx, "missing.tmpl", "")
+	assert.ErrorContains(t, err, "failed to open document missing.md")
+	_, err = system.Build(ctx, "outside.tmpl", "")
+	assert.ErrorContains(t, err, "must be relative and inside the registry")
+
+	template := NewTemplate("main.tmpl", `[[document "spec.md"]]`, &MockPromptRegistry{})
+	_, err = template.Build(ctx, Config{Config: map[string]any{}})
+	assert.ErrorContains(t, err, "the registry doesn't support documents")
+}
+
+func TestTemplate_Include(t *testing.T) {
+	tempDir := setupTempDir(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "docs"), 0755))
+	var lines []string
+	for i := 1; i <= 5000; i++ {
+		lines = append(lines, fmt.Sprintf("[[line %d]]", i))
+	}
+	createTestFile(t, tempDir, "docs/spec.md", strings.Join(lines, "\n"))
+	createTestFile(t, tempDir, "main.tmpl", `[[include "docs/spec.md" 2 3]]|[[include "docs/spec.md" 4999]]|[[include "docs/spec.md" 5000 9000]]`)
+	createTestFile(t, tempDir, "whole.tmpl", `[[include "docs/spec.md"]]`)
+	createTestFile(t, tempDir, "invalid.tmpl", `[[include "docs/spec.md" 3 2]]`)
+	createTestFile(t, tempDir, "missing.tmpl", `[[include "docs/missing.md" 1 2]]`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	output, err := system.Build(ctx, "main.tmpl", "")
+	require.NoError(t, err)
+	assert.Equal(t, "[[line 2]]\n[[line 3]]\n|[[line 4999]]\n[[line 5000]]|[[line 5000]]", output)
+	output, err = system.Build(ctx, "whole.tmpl", "")
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join(lines, "\n"), output)
+
+	_, err = system.Build(ctx, "invalid.tmpl", "")
+	assert.ErrorContains(t, err, "include docs/spec.md: invalid line range [3 2]")
+	_, err = system.Build(ctx, "missing.tmpl", "")
+	assert.ErrorContains(t, err, "failed to open document docs/missing.md")
+
+	memory := NewMemoryRegistry()
+	require.NoError(t, memory.SetTemplate("main.tmpl", `Spec: [[include "spec.md" 2 2]]`))
+	memory.SetDocument("spec.md", "a\nb\nc")
+	system, err = NewPromptSystem(memory)
+	require.NoError(t, err)
+	output, err = system.Build(ctx, "main.tmpl", "")
+	require.NoError(t, err)
+	assert.Equal(t, "Spec: b\n", output)
+}
@@ -0,0 +1,62 @@
+package prompt
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/notzree/rprompt/v2/prompt/core"
+	"gopkg.in/yaml.v3"
+)
+
+// Publish removes the draft flag from the front matter of the template at path, so it can be built without
+// WithAllowDraft. The change is saved as a new version of the template, recording when it was published.
+func (r *LocalPromptRegistry) Publish(ctx context.Context, path string) error {
+	template, err := r.Find(ctx, path)
+	if err != nil {
+		return err
+	}
+	fm, err := template.FrontMatter()
+	if err != nil {
+		return err
+	}
+	if !fm.Draft {
+		return fmt.Errorf("%s isn't a draft", path)
+	}
+	published, err := withoutDraftFlag(template.OriginalContent)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return r.SaveTemplate(path, published)
+}
+
+// withoutDraftFlag removes the top-level draft line from a template's front matter, leaving the rest of it as
+// written. Front matter left empty is removed entirely.
+func withoutDraftFlag(content string) (string, error) {
+	raw, body := core.SplitFrontMatter(content)
+	var kept []string
+	for _, line := range strings.SplitAfter(raw, "\n") {
+		if !strings.HasPrefix(line, "draft:") {
+			kept = append(kept, line)
+		}
+	}
+	remaining := strings.Join(kept, "")
+	var fm FrontMatter
+	if err := yaml.Unmarshal([]byte(remaining), &fm); err != nil || fm.Draft {
+		return "", fmt.Errorf("failed to remove the draft flag from the front matter, remove it by hand")
+	}
+	if strings.TrimSpace(remaining) == "" {
+		return body, nil
+	}
+	first, _, _ := strings.Cut(content, "\n")
+	// The opening line keeps its line ending, and the closing line is rebuilt from the same
+	return first + "\n" + remaining + core.FrontMatterDelim + lineEnding(first) + body, nil
+}
+
+// lineEnding returns "\r\n" for a line split off with its \r, and "\n" otherwise
+func lineEnding(line string) string {
+	if strings.HasSuffix(line, "\r") {
+		return "\r\n"
+	}
+	return "\n"
+}
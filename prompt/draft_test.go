@@ -0,0 +1,89 @@
+package prompt
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptSystem_Drafts(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "draft.tmpl", "---\ndraft: true\n---\nHello [[.name]]")
+	createTestFile(t, tempDir, "main.tmpl", `[[template "draft.tmpl" .]]!`)
+	createTestFile(t, tempDir, "published.tmpl", "---\ndraft: false\n---\nHi")
+	createTestFile(t, tempDir, "sections.tmpl", "---\ndraft: true\n---\n[[define \"intro\"]]Hi[[end]]")
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+	name := WithValue("name", "John")
+
+	_, err = system.Build(ctx, "draft.tmpl", "", name)
+	var draft *DraftError
+	require.True(t, errors.As(err, &draft))
+	assert.Equal(t, &DraftError{Path: "draft.tmpl"}, draft)
+
+	_, err = system.Build(ctx, "main.tmpl", "", name)
+	require.True(t, errors.As(err, &draft))
+	assert.Equal(t, &DraftError{Path: "draft.tmpl", IncludedBy: "main.tmpl"}, draft)
+	assert.ErrorContains(t, err, "draft.tmpl is a draft included by main.tmpl")
+
+	var buf strings.Builder
+	assert.ErrorIs(t, system.BuildTo(ctx, &buf, "draft.tmpl", "", name), ErrDraft)
+	assert.Empty(t, buf.String())
+	_, err = system.BuildSection(ctx, "sections.tmpl", "intro", "")
+	assert.ErrorIs(t, err, ErrDraft)
+
+	output, err := system.Build(ctx, "main.tmpl", "", name, WithAllowDraft())
+	require.NoError(t, err)
+	assert.Equal(t, "Hello John!", output)
+	output, err = system.Build(ctx, "published.tmpl", "")
+	require.NoError(t, err)
+	assert.Equal(t, "Hi", output)
+}
+
+func TestLocalPromptRegistry_Publish(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "draft.tmpl", "---\ndraft: true\n---\nHello")
+	createTestFile(t, tempDir, "schema.tmpl", "---\r\ndraft: true\r\nresponse_schema: {type: object}\r\n---\r\nHello")
+	createTestFile(t, tempDir, "flow.tmpl", "---\n{draft: true}\n---\nHello")
+	registry := NewInMemPromptRegistry(tempDir)
+	ctx := context.Background()
+
+	require.NoError(t, registry.Publish(ctx, "draft.tmpl"))
+	template, err := registry.Find(ctx, "draft.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", template.OriginalContent, "front matter left empty is removed")
+	versions, err := registry.History("draft.tmpl")
+	require.NoError(t, err)
+	assert.Len(t, versions, 2, "publishing is recorded as a version")
+
+	require.NoError(t, registry.Publish(ctx, "schema.tmpl"))
+	template, err = registry.Find(ctx, "schema.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, "---\r\nresponse_schema: {type: object}\r\n---\r\nHello", template.OriginalContent)
+
+	assert.ErrorContains(t, registry.Publish(ctx, "draft.tmpl"), "draft.tmpl isn't a draft")
+	assert.ErrorContains(t, registry.Publish(ctx, "flow.tmpl"), "remove it by hand")
+}
+
+func TestApp_Publish(t *testing.T) {
+	tempDir := setupTempDir(t)
+	outDir := t.TempDir()
+	createTestFile(t, tempDir, "main.tmpl", "---\ndraft: true\n---\nHello [[.name]]")
+	createTestFile(t, tempDir, "main.json", `{"name": "John"}`)
+	app, _, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	ctx := context.Background()
+	run := func(args ...string) error {
+		return app.Command().Run(ctx, append([]string{"rprompt"}, args...))
+	}
+	generate := []string{"generate", "-t", "main.tmpl", "-c", "main.json", "-o", outDir + "/{{template_stem}}.txt"}
+
+	assert.ErrorIs(t, run(generate...), ErrDraft)
+	require.NoError(t, run(append(generate, "--allow-draft")...))
+	require.NoError(t, run("publish", "main.tmpl"))
+	require.NoError(t, run(generate...))
+}
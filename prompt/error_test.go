@@ -0,0 +1,84 @@
+package prompt
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCatalog(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello [[.name]]`)
+	createTestFile(t, tempDir, "a.tmpl", `[[template "b.tmpl" .]]`)
+	createTestFile(t, tempDir, "b.tmpl", `[[template "a.tmpl" .]]`)
+	createTestFile(t, tempDir, "include.tmpl", `[[template "absent.tmpl" .]]`)
+	createTestFile(t, tempDir, "bad.json", `{"name": `)
+	createTestFile(t, tempDir, "draft.tmpl", "---\ndraft: true\n---\nHello")
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		build    func() error
+		sentinel error
+		code     string
+		exitCode int
+	}{
+		{"template not found", func() error {
+			_, err := system.Build(ctx, "absent.tmpl", "")
+			return err
+		}, ErrTemplateNotFound, CodeTemplateNotFound, 3},
+		{"dependency not found", func() error {
+			_, err := system.Build(ctx, "include.tmpl", "")
+			return err
+		}, ErrTemplateNotFound, CodeTemplateNotFound, 3},
+		{"config invalid", func() error {
+			_, err := system.Build(ctx, "main.tmpl", "bad.json")
+			return err
+		}, ErrConfigInvalid, CodeConfigInvalid, 4},
+		{"strict validation", func() error {
+			_, err := system.Build(ctx, "main.tmpl", "", WithValidation(ValidationStrict), WithValue("name", "John"), WithValue("extra", 1))
+			return err
+		}, ErrConfigInvalid, CodeConfigInvalid, 4},
+		{"missing fields", func() error {
+			_, err := system.Build(ctx, "main.tmpl", "")
+			return err
+		}, ErrMissingFields, CodeMissingFields, 5},
+		{"cycle", func() error {
+			_, err := system.Build(ctx, "a.tmpl", "")
+			return err
+		}, ErrCycle, CodeCycle, 6},
+		{"budget", func() error {
+			_, err := system.Build(ctx, "main.tmpl", "", WithValue("name", "John"), WithTokenBudget(1, nil))
+			return err
+		}, ErrBudgetExceeded, CodeBudgetExceeded, 7},
+		{"draft", func() error {
+			_, err := system.Build(ctx, "draft.tmpl", "")
+			return err
+		}, ErrDraft, CodeDraft, 12},
+		{"outside registry", func() error {
+			_, err := system.Build(ctx, "../main.tmpl", "")
+			return err
+		}, ErrOutsideRegistry, CodeOutsideRegistry, 13},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.build()
+			require.Error(t, err)
+			assert.ErrorIs(t, err, tt.sentinel)
+			assert.Equal(t, tt.code, ErrorCode(err))
+			assert.Equal(t, tt.exitCode, ExitCode(err))
+		})
+	}
+}
+
+func TestErrorCode_Unknown(t *testing.T) {
+	assert.Equal(t, "", ErrorCode(nil))
+	assert.Equal(t, 0, ExitCode(nil))
+	assert.Equal(t, CodeUnknown, ErrorCode(errors.New("boom")))
+	assert.Equal(t, 1, ExitCode(errors.New("boom")))
+}
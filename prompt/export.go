@@ -0,0 +1,101 @@
+package prompt
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Export copies a template, its full transitive dependency set and the matching configs the system's registry
+// holds to out, see PromptSystem.ExportFiles. If out ends in .tar.gz or .tgz an archive is written, otherwise out
+// is treated as a directory.
+func Export(ctx context.Context, s *PromptSystem, templatePath string, configPaths []string, out string) (*ExportResult, error) {
+	return ExportAs(ctx, s, ExportNative, templatePath, configPaths, out)
+}
+
+// ExportAs is Export in one of the Export formats. Formats other than ExportNative replace the template
+// closure with a single converted file, see Template.ConvertToJinja, next to the same configs.
+func ExportAs(ctx context.Context, s *PromptSystem, format, templatePath string, configPaths []string, out string) (*ExportResult, error) {
+	files, result, err := s.ExportFiles(ctx, format, templatePath, configPaths)
+	if err != nil {
+		return nil, err
+	}
+	if isArchivePath(out) {
+		err = writeExportArchive(out, files)
+	} else {
+		err = writeExportDir(out, files)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func isArchivePath(path string) bool {
+	return strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")
+}
+
+func writeExportDir(dir string, files map[string][]byte) error {
+	for path, content := range files {
+		fullPath := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directories: %w", err)
+		}
+		if err := os.WriteFile(fullPath, content, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", fullPath, err)
+		}
+	}
+	return nil
+}
+
+func writeExportArchive(out string, files map[string][]byte) error {
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return fmt.Errorf("failed to create directories: %w", err)
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return fmt.Errorf("failed to create archive %s: %w", out, err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	// Write entries in a stable order so archive listings are predictable
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	now := time.Now()
+	for _, path := range paths {
+		content := files[path]
+		hdr := &tar.Header{
+			Name:    filepath.ToSlash(path),
+			Mode:    0644,
+			Size:    int64(len(content)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write archive header for %s: %w", path, err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			return fmt.Errorf("failed to write %s to archive: %w", path, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finalize archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finalize archive: %w", err)
+	}
+	return nil
+}
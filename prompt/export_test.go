@@ -0,0 +1,163 @@
+package prompt
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupExportRegistry(t *testing.T) *LocalPromptRegistry {
+	tempDir := setupTempDir(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "partials"), 0755))
+	createTestFile(t, tempDir, "main.tmpl", `[[template "partials/header.tmpl" .]] Hello [[.name]]`)
+	createTestFile(t, tempDir, "main.json", `{"name": "John"}`)
+	createTestFile(t, tempDir, "partials/header.tmpl", `[[template "partials/logo.tmpl" .]] Header`)
+	createTestFile(t, tempDir, "partials/logo.tmpl", `Logo`)
+	createTestFile(t, tempDir, "extra.json", "{\n\t\"name\": \"Jane\",\n\t\"age\": 30.0\n}\n")
+	createTestFile(t, tempDir, "unrelated.tmpl", `Unrelated`)
+	return NewInMemPromptRegistry(tempDir)
+}
+
+func TestExport_Directory(t *testing.T) {
+	registry := setupExportRegistry(t)
+	system, _ := NewPromptSystem(registry)
+	out := filepath.Join(t.TempDir(), "export")
+
+	result, err := Export(context.Background(), system, "main.tmpl", []string{"extra.json"}, out)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"main.tmpl", "partials/header.tmpl", "partials/logo.tmpl"}, result.Templates)
+	assert.Equal(t, []string{"extra.json", "main.json"}, result.Configs)
+
+	for _, path := range append(result.Templates, result.Configs...) {
+		_, err := os.Stat(filepath.Join(out, path))
+		assert.NoError(t, err, path)
+	}
+	_, err = os.Stat(filepath.Join(out, "unrelated.tmpl"))
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, readTestFile(t, registry.Directory, "extra.json"), readTestFile(t, out, "extra.json"), "configs are copied as they were written")
+
+	// The exported directory should work as a standalone registry
+	exported, _ := NewPromptSystem(NewInMemPromptRegistry(out))
+	prompt, err := exported.Build(context.Background(), "main.tmpl", "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, "Logo Header Hello John", prompt)
+}
+
+func TestExport_Archive(t *testing.T) {
+	registry := setupExportRegistry(t)
+	system, _ := NewPromptSystem(registry)
+	out := filepath.Join(t.TempDir(), "main.tar.gz")
+
+	_, err := Export(context.Background(), system, "main.tmpl", nil, out)
+	require.NoError(t, err)
+
+	f, err := os.Open(out)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+	}
+	assert.Equal(t, []string{"main.json", "main.tmpl", "partials/header.tmpl", "partials/logo.tmpl"}, names)
+}
+
+func TestExport_EncodesConfigsOfOtherRegistries(t *testing.T) {
+	// Embedding the interface hides ReadConfig, so the config can only be loaded
+	system, _ := NewPromptSystem(struct{ PromptRegistry }{setupExportRegistry(t)})
+	out := t.TempDir()
+
+	_, err := Export(context.Background(), system, "main.tmpl", []string{"extra.json"}, out)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "Jane", "age": 30}`, readTestFile(t, out, "extra.json"))
+}
+
+func TestExport_MissingExplicitConfig(t *testing.T) {
+	registry := setupExportRegistry(t)
+	system, _ := NewPromptSystem(registry)
+
+	_, err := Export(context.Background(), system, "main.tmpl", []string{"missing.json"}, t.TempDir())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "err loading config missing.json")
+}
+
+func TestExportAs_Formats(t *testing.T) {
+	tests := []struct {
+		format string
+		path   string
+		want   string
+	}{
+		{ExportJinja, "main.j2", `Logo Header Hello {{ name }}`},
+		{ExportPromptfoo, "main.promptfoo.txt", `Logo Header Hello {{ name }}`},
+		{ExportLangChain, "main.langchain.json", `{
+  "_type": "prompt",
+  "input_variables": [
+    "name"
+  ],
+  "template": "Logo Header Hello {{ name }}",
+  "template_format": "jinja2"
+}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			system, _ := NewPromptSystem(setupExportRegistry(t))
+			out := filepath.Join(t.TempDir(), "export")
+
+			result, err := ExportAs(context.Background(), system, tt.format, "main.tmpl", nil, out)
+			require.NoError(t, err)
+			assert.Equal(t, []string{tt.path}, result.Templates)
+			assert.Equal(t, []string{"main.json"}, result.Configs)
+
+			content, err := os.ReadFile(filepath.Join(out, tt.path))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(content))
+			_, err = os.Stat(filepath.Join(out, "main.tmpl"))
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestExportAs_ChatFormats(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "chat.tmpl", `[[role "system"]]Be brief.[[role "user"]][[.question]]`)
+	system, _ := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	out := t.TempDir()
+
+	_, err := ExportAs(context.Background(), system, ExportLangChain, "chat.tmpl", nil, out)
+	require.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(out, "chat.langchain.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"input_variables": ["question"],
+		"template_format": "jinja2",
+		"messages": [["system", "Be brief."], ["user", "{{ question }}"]]
+	}`, string(content))
+
+	_, err = ExportAs(context.Background(), system, ExportPromptfoo, "chat.tmpl", nil, out)
+	require.NoError(t, err)
+	content, err = os.ReadFile(filepath.Join(out, "chat.promptfoo.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"role": "system", "content": "Be brief."}, {"role": "user", "content": "{{ question }}"}]`, string(content))
+}
+
+func TestExportAs_UnknownFormat(t *testing.T) {
+	system, _ := NewPromptSystem(setupExportRegistry(t))
+	_, err := ExportAs(context.Background(), system, "yaml", "main.tmpl", nil, t.TempDir())
+	assert.ErrorContains(t, err, "unknown export format")
+}
@@ -0,0 +1,93 @@
+package prompt
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/notzree/rprompt/v2/prompt/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateExtensions(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.prompt", `[[template "header"]] [[.name]][[template "sig.prompt"]]`)
+	createTestFile(t, tempDir, "header.md.tmpl", `# Hello`)
+	createTestFile(t, tempDir, "sig.prompt", `!`)
+	createTestFile(t, tempDir, "notes.txt", `not a template`)
+	registry := NewInMemPromptRegistry(tempDir)
+	ctx := context.Background()
+
+	_, err := registry.Find(ctx, "main.prompt")
+	assert.EqualError(t, err, "template file must have .tmpl extension: main.prompt", "only .tmpl is accepted by default")
+
+	registry.Extensions = []string{".tmpl", ".prompt", ".md.tmpl"}
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	output, err := system.Build(ctx, "main.prompt", "", WithValue("name", "Ada"))
+	require.NoError(t, err)
+	assert.Equal(t, "# Hello Ada!", output)
+	template, err := registry.Find(ctx, "main.prompt")
+	require.NoError(t, err)
+	require.NoError(t, template.LoadDependencies(ctx))
+	assert.Equal(t, []string{"header.md.tmpl", "sig.prompt"}, template.Dependencies())
+
+	createTestFile(t, tempDir, "header.tmpl", `# Hi`)
+	output, err = system.Build(ctx, "main.prompt", "", WithValue("name", "Ada"))
+	require.NoError(t, err)
+	assert.Equal(t, "# Hi Ada!", output, "extensions are looked up in order")
+
+	paths, err := registry.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"header.md.tmpl", "header.tmpl", "main.prompt", "sig.prompt"}, paths)
+	index, err := registry.WriteIndex(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"header.tmpl", "sig.prompt"}, index.Templates[2].Dependencies)
+
+	_, err = registry.Find(ctx, "notes.txt")
+	assert.EqualError(t, err, "template file must have one of the extensions .tmpl, .prompt, .md.tmpl: notes.txt")
+	assert.Error(t, registry.SaveTemplate("notes.txt", "Hi"))
+	require.NoError(t, registry.SaveTemplate("saved.prompt", "Hi"))
+
+	memory := NewMemoryRegistry()
+	assert.Error(t, memory.SetTemplate("main.prompt", "Hi"))
+	memory.Extensions = []string{".prompt"}
+	require.NoError(t, memory.SetTemplate("main.prompt", `[[template "sig"]]`))
+	require.NoError(t, memory.SetTemplate("sig.prompt", `!`))
+	system, err = NewPromptSystem(memory)
+	require.NoError(t, err)
+	output, err = system.Build(ctx, "main.prompt", "")
+	require.NoError(t, err)
+	assert.Equal(t, "!", output)
+}
+
+func TestParseExtensions(t *testing.T) {
+	exts, err := ParseExtensions(".tmpl, prompt,.md.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, []string{".tmpl", ".prompt", ".md.tmpl"}, exts)
+
+	for _, invalid := range []string{"", ".tmpl,", "../x", "a b"} {
+		_, err := ParseExtensions(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestApp_TemplateExtensions(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.prompt", `Hi [[template "sig"]]`)
+	createTestFile(t, tempDir, "sig.prompt", `Ada`)
+	createTestFile(t, tempDir, "main.json", `{}`)
+	outDir := t.TempDir()
+	app, _, _ := newTestApp(t, WithSettings(&settings.Settings{
+		CurrentRegistry: "vendor",
+		Extensions:      ".tmpl",
+		Registries:      map[string]settings.Registry{"vendor": {Dir: tempDir, Extensions: ".tmpl,.prompt"}},
+	}))
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "generate", "-t", "main.prompt", "-c", "main.json", "-o", filepath.Join(outDir, "out.txt")}))
+	assert.Equal(t, "Hi Ada", readTestFile(t, outDir, "out.txt"))
+
+	err := app.Command().Run(context.Background(), []string{"rprompt", "config", "set", "extensions", ".tmpl,../x"})
+	assert.ErrorContains(t, err, "extensions: invalid template extension")
+}
@@ -0,0 +1,79 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupFanoutRegistry(t *testing.T) string {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "email.tmpl", `Dear [[.name]], thanks for choosing [[.company]].`)
+	createTestFile(t, tempDir, "customers.json", `{"company": "Acme", "customers": [{"id": "c1", "name": "Ada"}, {"id": "c2", "name": "Alan", "company": "Acme Labs"}]}`)
+	return tempDir
+}
+
+func TestPromptSystem_Fanout(t *testing.T) {
+	tempDir := setupFanoutRegistry(t)
+	createTestFile(t, tempDir, "bad.json", `{"customers": ["Ada"]}`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	results, err := system.Fanout(ctx, "email.tmpl", "customers.json", ".customers")
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "Dear Ada, thanks for choosing Acme.", results[0].Result.Output)
+	assert.Equal(t, "Dear Alan, thanks for choosing Acme Labs.", results[1].Result.Output, "elements override shared values")
+	assert.Equal(t, map[string]any{"company": "Acme", "id": "c1", "name": "Ada"}, results[0].Data)
+	assert.Equal(t, []Warning{{Kind: WarningUnusedKey, Path: "id", Message: "id is set in the config but not used by the template"}}, results[0].Result.Warnings, "the array isn't reported as unused")
+
+	paths, err := FanoutOutputPaths("out/{{template_stem}}-{{.id}}.txt", "email.tmpl", results)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"out/email-c1.txt", "out/email-c2.txt"}, paths)
+	_, err = FanoutOutputPaths("out/{{template_stem}}.txt", "email.tmpl", results)
+	assert.EqualError(t, err, "elements 0 and 1 would both be written to out/email.txt, add a placeholder such as {{.id}} to the output")
+	_, err = FanoutOutputPaths("out/{{.missing}}.txt", "email.tmpl", results)
+	assert.ErrorContains(t, err, "element 0: invalid output path")
+
+	_, err = system.Fanout(ctx, "email.tmpl", "customers.json", "company")
+	assert.EqualError(t, err, "company is a string, not an array")
+	_, err = system.Fanout(ctx, "email.tmpl", "customers.json", ".nope")
+	assert.EqualError(t, err, "config has no nope to fan out over")
+	_, err = system.Fanout(ctx, "email.tmpl", "bad.json", "customers")
+	assert.EqualError(t, err, "customers.0 is a string, not an object")
+}
+
+func TestApp_Fanout(t *testing.T) {
+	tempDir := setupFanoutRegistry(t)
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	outDir := t.TempDir()
+	ctx := context.Background()
+
+	require.NoError(t, app.Command().Run(ctx, []string{"rprompt", "fanout", "-t", "email.tmpl", "-c", "customers.json", "--each", ".customers", "-o", filepath.Join(outDir, "{{.id}}.txt")}))
+	assert.Equal(t, "Dear Ada, thanks for choosing Acme.", readTestFile(t, outDir, "c1.txt"))
+	assert.Equal(t, "Dear Alan, thanks for choosing Acme Labs.", readTestFile(t, outDir, "c2.txt"))
+	assert.Contains(t, stdout.String(), "Generated 2 prompts from email.tmpl")
+
+	stdout.Reset()
+	require.NoError(t, app.Command().Run(ctx, []string{"rprompt", "--json", "fanout", "-t", "email.tmpl", "-c", "customers.json", "--each", "customers", "--format", FormatJSON, "-o", filepath.Join(outDir, "{{.name}}.json")}))
+	var report struct {
+		Outputs []struct {
+			Index  int    `json:"index"`
+			Output string `json:"output"`
+		} `json:"outputs"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	require.Len(t, report.Outputs, 2)
+	assert.Equal(t, filepath.Join(outDir, "Alan.json"), report.Outputs[1].Output)
+	var envelope Envelope
+	require.NoError(t, json.Unmarshal([]byte(readTestFile(t, outDir, "Alan.json")), &envelope))
+	assert.Equal(t, "Dear Alan, thanks for choosing Acme Labs.", envelope.Prompt)
+
+	err := app.Command().Run(ctx, []string{"rprompt", "fanout", "-t", "email.tmpl", "-c", "customers.json", "--each", ".company", "-o", filepath.Join(outDir, "x.txt")})
+	assert.EqualError(t, err, "failed to fan out email.tmpl: company is a string, not an array")
+}
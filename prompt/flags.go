@@ -0,0 +1,143 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Defaults of HTTPFlags
+const (
+	httpFlagsTimeout = 10 * time.Second
+	httpFlagsTTL     = 30 * time.Second
+)
+
+// EnvFlagsPrefix starts the environment variables EnvFlags reads when it has no prefix of its own
+const EnvFlagsPrefix = "RPROMPT_FLAG_"
+
+// NewFlagsProvider returns the provider for source: EnvFlags for "env", an HTTPFlags for http and https URLs
+// and otherwise StaticFlags turning on the comma separated flags in source, such as "new-tone,short-answers"
+func NewFlagsProvider(source string) (FlagsProvider, error) {
+	switch {
+	case source == "env":
+		return &EnvFlags{}, nil
+	case strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"):
+		return &HTTPFlags{URL: source}, nil
+	}
+	flags := StaticFlags{}
+	for _, name := range strings.Split(source, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			return nil, fmt.Errorf("invalid flags %q: empty flag name", source)
+		}
+		flags[name] = true
+	}
+	return flags, nil
+}
+
+// EnvFlags reads flags from environment variables named by the prefix and the flag's name in upper case, with
+// characters other than letters and digits replaced by underscores: RPROMPT_FLAG_NEW_TONE=true turns on
+// new-tone. Values are parsed with strconv.ParseBool, and flags without a variable are off.
+type EnvFlags struct {
+	// Prefix defaults to EnvFlagsPrefix
+	Prefix string
+}
+
+// Enabled reads the flag's environment variable
+func (f *EnvFlags) Enabled(_ context.Context, name string) (bool, error) {
+	prefix := f.Prefix
+	if prefix == "" {
+		prefix = EnvFlagsPrefix
+	}
+	key := prefix + strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, name)
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return false, nil
+	}
+	on, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s=%s is not a boolean", key, value)
+	}
+	return on, nil
+}
+
+// HTTPFlags reads flags from a flag service. It gets URL and expects {"flags": {"new-tone": true, ...}} back,
+// so any flag service can sit behind a small endpoint. The answer is reused for TTL, so a build evaluating
+// many flags makes one request.
+type HTTPFlags struct {
+	URL string
+	// Headers are set on every request, e.g. Authorization
+	Headers map[string]string
+	// Client defaults to a client that gives up after 10 seconds
+	Client *http.Client
+	// TTL is how long the flags are reused for, 30 seconds when zero
+	TTL time.Duration
+
+	mu      sync.Mutex
+	flags   map[string]bool
+	fetched time.Time
+}
+
+// Enabled returns the flag from the service's latest answer, asking again once it is older than TTL
+func (f *HTTPFlags) Enabled(ctx context.Context, name string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ttl := f.TTL
+	if ttl <= 0 {
+		ttl = httpFlagsTTL
+	}
+	if f.flags == nil || time.Since(f.fetched) >= ttl {
+		flags, err := f.fetch(ctx)
+		if err != nil {
+			return false, err
+		}
+		f.flags, f.fetched = flags, time.Now()
+	}
+	return f.flags[name], nil
+}
+
+func (f *HTTPFlags) fetch(ctx context.Context) (map[string]bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for key, value := range f.Headers {
+		req.Header.Set(key, value)
+	}
+	client := f.Client
+	if client == nil {
+		client = &http.Client{Timeout: httpFlagsTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("err calling %s: %w", f.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("%s returned %s: %s", f.URL, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var body struct {
+		Flags map[string]bool `json:"flags"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("err decoding response from %s: %w", f.URL, err)
+	}
+	if body.Flags == nil {
+		body.Flags = map[string]bool{}
+	}
+	return body.Flags, nil
+}
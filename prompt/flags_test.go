@@ -0,0 +1,137 @@
+package prompt
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/notzree/rprompt/v2/prompt/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlag(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[if flag "new-tone"]]Hey there![[else]]Hello.[[end]]`)
+	createTestFile(t, tempDir, "main.json", `{}`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	result, err := system.Build(ctx, "main.tmpl", "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello.", result, "flags are off without a provider")
+
+	system.Flags = StaticFlags{"new-tone": true}
+	result, err = system.Build(ctx, "main.tmpl", "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, "Hey there!", result)
+
+	system.Flags = FlagsProviderFunc(func(context.Context, string) (bool, error) {
+		return false, errors.New("service down")
+	})
+	_, err = system.Build(ctx, "main.tmpl", "main.json")
+	assert.ErrorContains(t, err, `failed to evaluate flag "new-tone": service down`)
+}
+
+func TestNewFlagsProvider(t *testing.T) {
+	provider, err := NewFlagsProvider("new-tone, short")
+	require.NoError(t, err)
+	assert.Equal(t, StaticFlags{"new-tone": true, "short": true}, provider)
+
+	provider, err = NewFlagsProvider("env")
+	require.NoError(t, err)
+	assert.IsType(t, &EnvFlags{}, provider)
+
+	provider, err = NewFlagsProvider("https://flags.example.com/prompts")
+	require.NoError(t, err)
+	assert.Equal(t, "https://flags.example.com/prompts", provider.(*HTTPFlags).URL)
+
+	_, err = NewFlagsProvider("a,,b")
+	assert.EqualError(t, err, `invalid flags "a,,b": empty flag name`)
+}
+
+func TestEnvFlags(t *testing.T) {
+	t.Setenv("RPROMPT_FLAG_NEW_TONE", "true")
+	t.Setenv("RPROMPT_FLAG_SHORT", "0")
+	t.Setenv("RPROMPT_FLAG_BROKEN", "maybe")
+	t.Setenv("TEAM_NEW_TONE", "1")
+	ctx := context.Background()
+	flags := &EnvFlags{}
+
+	on, err := flags.Enabled(ctx, "new-tone")
+	require.NoError(t, err)
+	assert.True(t, on)
+	on, err = flags.Enabled(ctx, "short")
+	require.NoError(t, err)
+	assert.False(t, on)
+	on, err = flags.Enabled(ctx, "unset")
+	require.NoError(t, err)
+	assert.False(t, on)
+	_, err = flags.Enabled(ctx, "broken")
+	assert.EqualError(t, err, "RPROMPT_FLAG_BROKEN=maybe is not a boolean")
+
+	on, err = (&EnvFlags{Prefix: "TEAM_"}).Enabled(ctx, "new.tone")
+	require.NoError(t, err)
+	assert.True(t, on)
+}
+
+func TestHTTPFlags(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		requests++
+		w.Write([]byte(`{"flags": {"new-tone": true, "short": false}}`))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	flags := &HTTPFlags{URL: server.URL}
+	_, err := flags.Enabled(ctx, "new-tone")
+	assert.ErrorContains(t, err, "401 Unauthorized: unauthorized")
+
+	flags.Headers = map[string]string{"Authorization": "Bearer secret"}
+	on, err := flags.Enabled(ctx, "new-tone")
+	require.NoError(t, err)
+	assert.True(t, on)
+	on, err = flags.Enabled(ctx, "short")
+	require.NoError(t, err)
+	assert.False(t, on)
+	on, err = flags.Enabled(ctx, "unknown")
+	require.NoError(t, err)
+	assert.False(t, on)
+	assert.Equal(t, 1, requests, "the flags are reused within the TTL")
+
+	flags.TTL = -1
+	flags.fetched = flags.fetched.Add(-httpFlagsTTL)
+	_, err = flags.Enabled(ctx, "new-tone")
+	require.NoError(t, err)
+	assert.Equal(t, 2, requests)
+}
+
+func TestApp_Flags(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[if flag "new-tone"]]Hey[[else]]Hello[[end]] [[.name]]`)
+	createTestFile(t, tempDir, "main.json", `{"name": "Ada"}`)
+	app, _, _ := newTestApp(t,
+		WithRegistry(NewInMemPromptRegistry(tempDir)),
+		WithSettings(&settings.Settings{Flags: map[string]bool{"new-tone": true}}),
+	)
+	outDir := t.TempDir()
+	ctx := context.Background()
+
+	require.NoError(t, app.Command().Run(ctx, []string{"rprompt", "generate", "-t", "main.tmpl", "-c", "main.json", "-o", filepath.Join(outDir, "out.txt")}))
+	assert.Equal(t, "Hey Ada", readTestFile(t, outDir, "out.txt"), "the settings' flags apply without --flags")
+
+	require.NoError(t, app.Command().Run(ctx, []string{"rprompt", "--flags", "other", "generate", "-t", "main.tmpl", "-c", "main.json", "-o", filepath.Join(outDir, "out.txt")}))
+	assert.Equal(t, "Hello Ada", readTestFile(t, outDir, "out.txt"))
+
+	err := app.Command().Run(ctx, []string{"rprompt", "--flags", ",", "generate", "-t", "main.tmpl", "-c", "main.json"})
+	assert.ErrorContains(t, err, "empty flag name")
+}
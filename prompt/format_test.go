@@ -0,0 +1,93 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func setupSectionsSystem(t *testing.T) *PromptSystem {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "agent.tmpl", `[[define "persona"]]You are [[.persona]].
+Stay in character.[[end]][[define "task"]]Answer [[.question]][[end]][[role "system"]][[template "persona" .]]
+[[role "user"]][[template "task" .]]`)
+	createTestFile(t, tempDir, "agent.json", `{"persona": "a pirate", "question": "why?"}`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	return system
+}
+
+func TestFormatResult_Envelopes(t *testing.T) {
+	system := setupSectionsSystem(t)
+	ctx := context.Background()
+	result, err := system.BuildWithResult(ctx, "agent.tmpl", "agent.json", WithSections(), WithMessages())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"persona": "You are a pirate.\nStay in character.", "task": "Answer why?"}, result.Sections)
+
+	output, err := FormatResult(FormatJSON, result)
+	require.NoError(t, err)
+	var envelope Envelope
+	require.NoError(t, json.Unmarshal([]byte(output), &envelope))
+	assert.Equal(t, Envelope{
+		Prompt:   "You are a pirate.\nStay in character.\nAnswer why?",
+		Sections: result.Sections,
+		Meta:     EnvelopeMeta{Template: "agent.tmpl", Tokens: result.Tokens, Variables: []string{"persona", "question"}},
+	}, envelope)
+
+	output, err = FormatResult(FormatYAML, result)
+	require.NoError(t, err)
+	assert.Equal(t, `prompt: |-
+    You are a pirate.
+    Stay in character.
+    Answer why?
+sections:
+    persona: |-
+        You are a pirate.
+        Stay in character.
+    task: Answer why?
+meta:
+    template: agent.tmpl
+    tokens: `+jsonNumber(t, result.Tokens)+`
+    variables:
+        - persona
+        - question
+`, output)
+	var decoded Envelope
+	require.NoError(t, yaml.Unmarshal([]byte(output), &decoded))
+	assert.Equal(t, envelope, decoded)
+
+	output, err = FormatResult(FormatMarkdown, result)
+	require.NoError(t, err)
+	assert.Equal(t, "## System\n\nYou are a pirate.\nStay in character.\n\n## User\n\nAnswer why?\n", output)
+
+	plain, err := system.BuildWithResult(ctx, "agent.tmpl", "agent.json")
+	require.NoError(t, err)
+	assert.Nil(t, plain.Sections, "sections are only rendered WithSections")
+}
+
+func jsonNumber(t *testing.T, n int) string {
+	data, err := json.Marshal(n)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestApp_GenerateEnvelopeFormats(t *testing.T) {
+	system := setupSectionsSystem(t)
+	app, _, _ := newTestApp(t, WithRegistry(system.Registry.(*LocalPromptRegistry)))
+	outDir := t.TempDir()
+	ctx := context.Background()
+
+	require.NoError(t, app.Command().Run(ctx, []string{"rprompt", "generate", "-t", "agent.tmpl", "-c", "agent.json", "--format", FormatJSON, "-o", filepath.Join(outDir, "out.json")}))
+	var envelope Envelope
+	require.NoError(t, json.Unmarshal([]byte(readTestFile(t, outDir, "out.json")), &envelope))
+	assert.Equal(t, "Answer why?", envelope.Sections["task"])
+	assert.Equal(t, "agent.tmpl", envelope.Meta.Template)
+
+	require.NoError(t, app.Command().Run(ctx, []string{"rprompt", "generate", "-t", "agent.tmpl", "-c", "agent.json", "--format", FormatMarkdown, "-o", filepath.Join(outDir, "out.md")}))
+	assert.Equal(t, "## System\n\nYou are a pirate.\nStay in character.\n\n## User\n\nAnswer why?\n", readTestFile(t, outDir, "out.md"))
+}
@@ -0,0 +1,31 @@
+package prompt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate_FrontMatter(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "---\nresponse_schema:\n  type: object\n  required: [answer]\n---\nQ: [[.question]]")
+	registry := NewInMemPromptRegistry(tempDir)
+
+	template, err := registry.Find(context.Background(), "main.tmpl")
+	require.NoError(t, err)
+	fm, err := template.FrontMatter()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"type": "object", "required": []any{"answer"}}, fm.ResponseSchema)
+
+	output, err := template.Build(context.Background(), *NewConfig(map[string]any{"question": "why"}, ""))
+	require.NoError(t, err)
+	assert.Equal(t, "Q: why", output, "front matter is not rendered")
+}
+
+func TestTemplate_InvalidFrontMatter(t *testing.T) {
+	template := NewTemplate("main.tmpl", "---\nresponse_schema: [unclosed\n---\nHi", nil)
+	_, err := template.FrontMatter()
+	assert.ErrorContains(t, err, "invalid front matter in main.tmpl")
+}
@@ -0,0 +1,128 @@
+package prompt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// FuzzCrash is a panic FuzzCheck provoked
+type FuzzCrash struct {
+	Template string `json:"template"`
+	// Step is what was being done: generate config, schema or build
+	Step string `json:"step"`
+	// Input describes the config a build was given
+	Input string `json:"input,omitempty"`
+	Panic string `json:"panic"`
+	Stack string `json:"stack"`
+}
+
+// FuzzCheckReport is the result of FuzzCheck
+type FuzzCheckReport struct {
+	Templates int         `json:"templates"`
+	Runs      int         `json:"runs"`
+	Crashes   []FuzzCrash `json:"crashes"`
+}
+
+// fuzzInputs are the values FuzzCheck puts in every variable of the generated config, named for the report
+var fuzzInputs = []struct {
+	name  string
+	value func() any
+}{
+	{"null values", func() any { return nil }},
+	{"number values", func() any { return 0 }},
+	{"bool values", func() any { return true }},
+	{"empty list values", func() any { return []any{} }},
+	{"list values", func() any { return []any{"item", map[string]any{}} }},
+	{"object values", func() any { return map[string]any{} }},
+}
+
+// FuzzCheck runs config generation, schema inference and builds over every template in the registry, with an
+// empty config, the generated config and the generated config with every variable set to values of other
+// types. It reports every panic, so crashers in a template set are caught before they reach production.
+// Ordinary errors, such as missing fields, are expected and not reported.
+func FuzzCheck(ctx context.Context, r *LocalPromptRegistry) (*FuzzCheckReport, error) {
+	paths, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	system, err := NewPromptSystem(r)
+	if err != nil {
+		return nil, err
+	}
+	report := &FuzzCheckReport{Templates: len(paths), Crashes: []FuzzCrash{}}
+
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		run := func(step, input string, f func(t *Template) error) {
+			report.Runs++
+			template, err := system.Find(ctx, path)
+			if err != nil {
+				return
+			}
+			if crash := catchPanic(func() error { return f(template) }); crash != nil {
+				crash.Template, crash.Step, crash.Input = path, step, input
+				report.Crashes = append(report.Crashes, *crash)
+			}
+		}
+
+		var generated map[string]any
+		run("generate config", "", func(t *Template) error {
+			cfg, err := t.GenerateConfig(ctx, "")
+			if err == nil {
+				generated = cfg.Config
+			}
+			return err
+		})
+		run("schema", "", func(t *Template) error {
+			_, err := t.Schema(ctx)
+			return err
+		})
+
+		build := func(input string, data map[string]any) {
+			run("build", input, func(t *Template) error {
+				_, err := t.Build(ctx, Config{Config: data})
+				return err
+			})
+		}
+		build("empty config", map[string]any{})
+		if generated == nil {
+			continue
+		}
+		build("generated config", generated)
+		for _, input := range fuzzInputs {
+			build(input.name, fillLeaves(generated, input.value))
+		}
+	}
+	return report, nil
+}
+
+// catchPanic runs f and returns the panic it raised, including panics recovered into a RenderPanicError
+func catchPanic(f func() error) (crash *FuzzCrash) {
+	defer func() {
+		if r := recover(); r != nil {
+			crash = &FuzzCrash{Panic: fmt.Sprint(r), Stack: string(debug.Stack())}
+		}
+	}()
+	var panicErr *RenderPanicError
+	if err := f(); errors.As(err, &panicErr) {
+		return &FuzzCrash{Panic: fmt.Sprint(panicErr.Value), Stack: panicErr.Stack}
+	}
+	return nil
+}
+
+// fillLeaves returns a copy of a generated config with every leaf replaced by a value from value
+func fillLeaves(data map[string]any, value func() any) map[string]any {
+	filled := make(map[string]any, len(data))
+	for k, v := range data {
+		if nested, ok := v.(map[string]any); ok && len(nested) > 0 {
+			filled[k] = fillLeaves(nested, value)
+			continue
+		}
+		filled[k] = value()
+	}
+	return filled
+}
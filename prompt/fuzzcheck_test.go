@@ -0,0 +1,53 @@
+package prompt
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuzzCheck(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "header.tmpl" .]][[range .items]][[.name]][[end]][[with .user]][[.email]][[end]][[len .tags]]`)
+	createTestFile(t, tempDir, "header.tmpl", `[[if .title]][[.title]][[end]]`)
+	createTestFile(t, tempDir, "broken.tmpl", `[[if]]`)
+
+	report, err := FuzzCheck(context.Background(), NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Templates)
+	// Every parseable template gets config generation, schema and 2+len(fuzzInputs) builds
+	assert.Equal(t, 2*(4+len(fuzzInputs))+3, report.Runs)
+	assert.Empty(t, report.Crashes)
+}
+
+func TestCatchPanic(t *testing.T) {
+	crash := catchPanic(func() error { panic("boom") })
+	require.NotNil(t, crash)
+	assert.Equal(t, "boom", crash.Panic)
+	assert.NotEmpty(t, crash.Stack)
+
+	crash = catchPanic(func() error { return NewRenderPanicError("main.tmpl", "recovered", "stack") })
+	require.NotNil(t, crash)
+	assert.Equal(t, "recovered", crash.Panic)
+
+	assert.Nil(t, catchPanic(func() error { return errors.New("ordinary") }))
+}
+
+func TestFillLeaves(t *testing.T) {
+	data := map[string]any{"a": "", "b": map[string]any{"c": "", "d": map[string]any{}}}
+	filled := fillLeaves(data, func() any { return 0 })
+	assert.Equal(t, map[string]any{"a": 0, "b": map[string]any{"c": 0, "d": 0}}, filled)
+	assert.Equal(t, "", data["a"])
+}
+
+func TestApp_FuzzCheck(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello [[.name]]`)
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "fuzz-check"}))
+	assert.Contains(t, stdout.String(), "No crashes")
+}
@@ -0,0 +1,172 @@
+package prompt
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+
+	"github.com/notzree/rprompt/v2/api/rpromptpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// GRPCServer implements the rprompt.v1.PromptService gRPC service, defined in api/rprompt.proto, over a
+// registry. See the client package for a typed Go client.
+type GRPCServer struct {
+	rpromptpb.UnimplementedPromptServiceServer
+	registry *LocalPromptRegistry
+	system   *PromptSystem
+}
+
+// NewGRPCServer creates a GRPCServer for the templates in registry
+func NewGRPCServer(registry *LocalPromptRegistry) (*GRPCServer, error) {
+	system, err := NewPromptSystem(registry)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCServer{registry: registry, system: system}, nil
+}
+
+// Serve serves the service on lis until ctx is cancelled, then stops gracefully
+func (s *GRPCServer) Serve(ctx context.Context, lis net.Listener, opts ...grpc.ServerOption) error {
+	server := grpc.NewServer(opts...)
+	rpromptpb.RegisterPromptServiceServer(server, s)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(lis)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		server.GracefulStop()
+		return nil
+	}
+}
+
+func (s *GRPCServer) List(ctx context.Context, req *rpromptpb.ListRequest) (*rpromptpb.ListResponse, error) {
+	templates, err := s.registry.List()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &rpromptpb.ListResponse{Templates: templates}, nil
+}
+
+func (s *GRPCServer) GenerateConfig(ctx context.Context, req *rpromptpb.GenerateConfigRequest) (*rpromptpb.GenerateConfigResponse, error) {
+	info, err := s.system.Describe(ctx, req.GetTemplate())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	config, err := structpb.NewStruct(info.Config)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &rpromptpb.GenerateConfigResponse{Config: config, Variables: info.Variables}, nil
+}
+
+func (s *GRPCServer) Render(ctx context.Context, req *rpromptpb.RenderRequest) (*rpromptpb.RenderResponse, error) {
+	opts, err := renderOptions(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetMessages() {
+		opts = append(opts, WithMessages())
+	}
+	result, err := s.system.BuildWithResult(ctx, req.GetTemplate(), req.GetConfigPath(), opts...)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &rpromptpb.RenderResponse{
+		Output:       result.Output,
+		Dependencies: result.Dependencies,
+		Variables:    result.Variables,
+		UnusedKeys:   result.UnusedKeys,
+		Duration:     durationpb.New(result.Duration),
+		Tokens:       int64(result.Tokens),
+		Issues:       issuesToProto(result.Issues),
+	}
+	for _, message := range result.Messages {
+		resp.Messages = append(resp.Messages, &rpromptpb.Message{Role: message.Role, Content: message.Content})
+	}
+	return resp, nil
+}
+
+func (s *GRPCServer) RenderStream(req *rpromptpb.RenderRequest, stream grpc.ServerStreamingServer[rpromptpb.RenderChunk]) error {
+	opts, err := renderOptions(req)
+	if err != nil {
+		return err
+	}
+	if err := s.system.BuildTo(stream.Context(), chunkWriter{stream}, req.GetTemplate(), req.GetConfigPath(), opts...); err != nil {
+		return grpcError(err)
+	}
+	return nil
+}
+
+func (s *GRPCServer) Validate(ctx context.Context, req *rpromptpb.ValidateRequest) (*rpromptpb.ValidateResponse, error) {
+	issues, err := s.system.Validate(ctx, req.GetTemplate(), NewConfig(req.GetValues().AsMap(), ""))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &rpromptpb.ValidateResponse{Valid: len(issues) == 0, Issues: issuesToProto(issues)}, nil
+}
+
+// chunkWriter sends each write as a chunk of a RenderStream
+type chunkWriter struct {
+	stream grpc.ServerStreamingServer[rpromptpb.RenderChunk]
+}
+
+func (w chunkWriter) Write(p []byte) (int, error) {
+	if err := w.stream.Send(&rpromptpb.RenderChunk{Text: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// renderOptions converts the values and validation mode of a render request to build options
+func renderOptions(req *rpromptpb.RenderRequest) ([]BuildOption, error) {
+	opts := []BuildOption{WithData(req.GetValues().AsMap())}
+	if req.GetValidation() != "" {
+		mode, err := ParseValidationMode(req.GetValidation())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		opts = append(opts, WithValidation(mode))
+	}
+	return opts, nil
+}
+
+func issuesToProto(issues []ValidationIssue) []*rpromptpb.ValidationIssue {
+	converted := make([]*rpromptpb.ValidationIssue, 0, len(issues))
+	for _, issue := range issues {
+		converted = append(converted, &rpromptpb.ValidationIssue{Kind: string(issue.Kind), Path: issue.Path, Message: issue.Message})
+	}
+	return converted
+}
+
+// grpcError maps an error to a status: missing templates and configs are NotFound, problems with the
+// request's values InvalidArgument, unpublished drafts FailedPrecondition and anything else Internal
+func grpcError(err error) error {
+	var missing *MissingFieldsError
+	var invalid *ValidationError
+	var budget *TokenBudgetError
+	var pii *PIIError
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.As(err, &missing), errors.As(err, &invalid), errors.As(err, &budget), errors.As(err, &pii):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrDraft):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,69 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "header.tmpl" .]] Hello [[.name]]`)
+	createTestFile(t, tempDir, "header.tmpl", `Header`)
+	createTestFile(t, tempDir, "a.json", `{"name": "John", "extra": {"b": 1, "a": 2}}`)
+	createTestFile(t, tempDir, "b.json", `{"extra": {"a": 2, "b": 1}, "name": "John"}`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	hash, err := system.Hash(ctx, "main.tmpl", "a.json")
+	require.NoError(t, err)
+	assert.Len(t, hash, 64)
+
+	// Key order in the config file doesn't matter
+	same, err := system.Hash(ctx, "main.tmpl", "b.json")
+	require.NoError(t, err)
+	assert.Equal(t, hash, same)
+
+	// Build options are part of the config
+	overridden, err := system.Hash(ctx, "main.tmpl", "a.json", WithValue("name", "Jane"))
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, overridden)
+
+	// Editing a dependency changes the hash
+	createTestFile(t, tempDir, "header.tmpl", `New header`)
+	edited, err := system.Hash(ctx, "main.tmpl", "a.json")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, edited)
+}
+
+func TestWithHashFooter(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello [[.name]]`)
+	createTestFile(t, tempDir, "main.json", `{"name": "John"}`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	hash, err := system.Hash(ctx, "main.tmpl", "main.json")
+	require.NoError(t, err)
+	expected := "Hello John" + fmt.Sprintf(HashFooterFormat, hash)
+
+	result, err := system.BuildWithResult(ctx, "main.tmpl", "main.json", WithHashFooter())
+	require.NoError(t, err)
+	assert.Equal(t, expected, result.Output)
+	assert.Equal(t, hash, result.Hash)
+
+	var buf bytes.Buffer
+	require.NoError(t, system.BuildTo(ctx, &buf, "main.tmpl", "main.json", WithHashFooter()))
+	assert.Equal(t, expected, buf.String())
+
+	// Without the option the output is unchanged
+	output, err := system.Build(ctx, "main.tmpl", "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello John", output)
+}
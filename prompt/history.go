@@ -0,0 +1,330 @@
+package prompt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/notzree/rprompt/v2/prompt/core"
+)
+
+// HistoryDir is the hidden directory of a local registry holding every saved version of its templates and
+// configs. Each file saved through the registry gets a directory named after its path, holding the versions
+// as v1, v2 and so on, and the tags naming them in tags.json.
+const HistoryDir = ".history"
+
+// Version is one saved version of a template or config
+type Version struct {
+	Path    string    `json:"path"`
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+	Size    int64     `json:"size"`
+	// Tags are the semantic version tags pointing at the version, see LocalPromptRegistry.Tag
+	Tags []string `json:"tags,omitempty"`
+}
+
+// String returns the version's reference, such as agents/billing.tmpl@v3
+func (v Version) String() string {
+	return fmt.Sprintf("%s@v%d", v.Path, v.Version)
+}
+
+// VersionRef is a reference to a template or config, optionally pinned to one of its saved versions by number,
+// as in agents/billing.tmpl@v3, or by tag, as in agents/billing.tmpl@v1.2.0
+type VersionRef struct {
+	Path string
+	// Version is the version number, zero when the reference isn't pinned to one
+	Version int
+	// Tag is the semantic version tag, empty when the reference isn't pinned to one
+	Tag string
+}
+
+var (
+	versionNumberPattern = regexp.MustCompile(`^v([1-9][0-9]*)$`)
+	// semverTagPattern matches semantic versions with a v prefix and an optional pre-release, such as
+	// v1.2.0 or v2.0.0-rc.1
+	semverTagPattern = regexp.MustCompile(`^v(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(-[0-9A-Za-z.-]+)?$`)
+)
+
+// ParseVersionRef parses a reference such as agents/billing.tmpl@v3 or agents/billing.tmpl@v1.2.0
+func ParseVersionRef(ref string) (VersionRef, error) {
+	path, version, ok := strings.Cut(ref, "@")
+	if !ok {
+		return VersionRef{Path: ref}, nil
+	}
+	if m := versionNumberPattern.FindStringSubmatch(version); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return VersionRef{Path: path, Version: n}, nil
+	}
+	if semverTagPattern.MatchString(version) {
+		return VersionRef{Path: path, Tag: version}, nil
+	}
+	return VersionRef{}, fmt.Errorf("invalid version %q in %s, expected a version such as v3 or a tag such as v1.2.0", version, ref)
+}
+
+// ResolveRef parses a reference as ParseVersionRef does and returns its path and version number, looking
+// tags up in the registry. The version is zero for references to the current file.
+func (r *LocalPromptRegistry) ResolveRef(ref string) (string, int, error) {
+	parsed, err := ParseVersionRef(ref)
+	if err != nil {
+		return "", 0, err
+	}
+	if parsed.Tag == "" {
+		return parsed.Path, parsed.Version, nil
+	}
+	tags, err := r.Tags(parsed.Path)
+	if err != nil {
+		return "", 0, err
+	}
+	version, ok := tags[parsed.Tag]
+	if !ok {
+		return "", 0, fmt.Errorf("%s has no tag %s", parsed.Path, parsed.Tag)
+	}
+	return parsed.Path, version, nil
+}
+
+// History returns the saved versions of the file at path, oldest first. A file that was never saved through
+// the registry has no versions.
+func (r *LocalPromptRegistry) History(path string) ([]Version, error) {
+	path, err := r.sandboxed(path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(r.historyDir(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Version{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history of %s: %w", path, err)
+	}
+	versions := make([]Version, 0, len(entries))
+	for _, entry := range entries {
+		n, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), "v"))
+		if err != nil || entry.IsDir() || !strings.HasPrefix(entry.Name(), "v") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history of %s: %w", path, err)
+		}
+		versions = append(versions, Version{Path: path, Version: n, Time: info.ModTime(), Size: info.Size()})
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+
+	tags, err := r.Tags(path)
+	if err != nil {
+		return nil, err
+	}
+	for tag, n := range tags {
+		i := sort.Search(len(versions), func(i int) bool { return versions[i].Version >= n })
+		if i < len(versions) && versions[i].Version == n {
+			versions[i].Tags = append(versions[i].Tags, tag)
+		}
+	}
+	for i := range versions {
+		sort.Strings(versions[i].Tags)
+	}
+	return versions, nil
+}
+
+// Tags returns the version number each tag of the file at path points at
+func (r *LocalPromptRegistry) Tags(path string) (map[string]int, error) {
+	path, err := r.sandboxed(path)
+	if err != nil {
+		return nil, err
+	}
+	tags := map[string]int{}
+	data, err := os.ReadFile(r.tagsPath(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return tags, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tags of %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return nil, fmt.Errorf("failed to parse tags of %s: %w", path, err)
+	}
+	return tags, nil
+}
+
+// Tag names a saved version of the file at path with a semantic version tag such as v1.2.0, so code can pin
+// it with Find("main.tmpl@v1.2.0"). Version 0 tags the current file, saving it as a new version first if it
+// was changed outside the registry. Tags can't be moved, since services pinned to them would silently
+// change.
+func (r *LocalPromptRegistry) Tag(path string, version int, tag string) (*Version, error) {
+	if !semverTagPattern.MatchString(tag) {
+		return nil, fmt.Errorf("invalid tag %q, expected a semantic version such as v1.2.0", tag)
+	}
+	path, err := r.sandboxed(path)
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		content, err := os.ReadFile(r.fullPath(path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if err := r.writeVersioned(path, content); err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	versions, err := r.History(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%s has no saved versions", path)
+	}
+	if version == 0 {
+		version = versions[len(versions)-1].Version
+	}
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].Version >= version })
+	if i == len(versions) || versions[i].Version != version {
+		return nil, fmt.Errorf("%s has no version v%d, see 'rprompt history %s'", path, version, path)
+	}
+	tags, err := r.Tags(path)
+	if err != nil {
+		return nil, err
+	}
+	if existing, ok := tags[tag]; ok {
+		return nil, fmt.Errorf("tag %s of %s already points at v%d", tag, path, existing)
+	}
+	tags[tag] = version
+	data, err := json.MarshalIndent(tags, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(r.tagsPath(path), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to save tags of %s: %w", path, err)
+	}
+	tagged := versions[i]
+	tagged.Tags = append(tagged.Tags, tag)
+	sort.Strings(tagged.Tags)
+	return &tagged, nil
+}
+
+// ReadVersion returns the content of a saved version of the file at path
+func (r *LocalPromptRegistry) ReadVersion(path string, version int) ([]byte, error) {
+	path, err := r.sandboxed(path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(r.versionPath(path, version))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s has no version v%d, see 'rprompt history %s'", path, version, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s@v%d: %w", path, version, err)
+	}
+	return content, nil
+}
+
+// Rollback saves the content of an earlier version of the file at path as its newest version, so the rollback
+// can itself be rolled back. Version 0 rolls back to the version before the newest.
+func (r *LocalPromptRegistry) Rollback(path string, version int) (*Version, error) {
+	path, err := r.sandboxed(path)
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		versions, err := r.History(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) < 2 {
+			return nil, fmt.Errorf("%s has no earlier version to roll back to", path)
+		}
+		version = versions[len(versions)-2].Version
+	}
+	content, err := r.ReadVersion(path, version)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case core.CheckTemplateExtension(path, core.RegistryExtensions(r)) == nil:
+		err = r.SaveTemplate(path, string(content))
+	case strings.HasSuffix(path, ".json"):
+		err = r.writeVersioned(path, content)
+	default:
+		return nil, fmt.Errorf("only templates and configs have versions: %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	versions, err := r.History(path)
+	if err != nil {
+		return nil, err
+	}
+	return &versions[len(versions)-1], nil
+}
+
+// writeVersioned writes content to the file at path, relative to the registry, and records it as a new
+// version. A file that existed before it had any history gets its old content recorded first, so the first
+// change through the registry can be rolled back too.
+func (r *LocalPromptRegistry) writeVersioned(path string, content []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fullPath := filepath.Join(r.Directory, path)
+	versions, err := r.History(path)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		if previous, err := os.ReadFile(fullPath); err == nil && !bytes.Equal(previous, content) {
+			if err := r.recordVersion(path, 1, previous); err != nil {
+				return err
+			}
+			versions = append(versions, Version{Version: 1})
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directories: %w", err)
+	}
+	if err := os.WriteFile(fullPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", fullPath, err)
+	}
+
+	// Saving unchanged content doesn't make a new version
+	if len(versions) > 0 {
+		latest := versions[len(versions)-1].Version
+		if previous, err := os.ReadFile(r.versionPath(path, latest)); err == nil && bytes.Equal(previous, content) {
+			return nil
+		}
+		return r.recordVersion(path, latest+1, content)
+	}
+	return r.recordVersion(path, 1, content)
+}
+
+func (r *LocalPromptRegistry) recordVersion(path string, version int, content []byte) error {
+	if err := os.MkdirAll(r.historyDir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create history of %s: %w", path, err)
+	}
+	if err := os.WriteFile(r.versionPath(path, version), content, 0644); err != nil {
+		return fmt.Errorf("failed to record %s@v%d: %w", path, version, err)
+	}
+	return nil
+}
+
+func (r *LocalPromptRegistry) historyDir(path string) string {
+	return filepath.Join(r.Directory, HistoryDir, filepath.FromSlash(path))
+}
+
+func (r *LocalPromptRegistry) tagsPath(path string) string {
+	return filepath.Join(r.historyDir(path), "tags.json")
+}
+
+func (r *LocalPromptRegistry) versionPath(path string, version int) string {
+	return filepath.Join(r.historyDir(path), "v"+strconv.Itoa(version))
+}
@@ -0,0 +1,235 @@
+package prompt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersionRef(t *testing.T) {
+	for ref, expected := range map[string]VersionRef{
+		"agents/billing.tmpl@v3":          {Path: "agents/billing.tmpl", Version: 3},
+		"agents/billing.tmpl@v1.2.0":      {Path: "agents/billing.tmpl", Tag: "v1.2.0"},
+		"agents/billing.tmpl@v2.0.0-rc.1": {Path: "agents/billing.tmpl", Tag: "v2.0.0-rc.1"},
+		"main.json":                       {Path: "main.json"},
+	} {
+		parsed, err := ParseVersionRef(ref)
+		require.NoError(t, err, ref)
+		assert.Equal(t, expected, parsed, ref)
+	}
+
+	for _, ref := range []string{"main.tmpl@latest", "main.tmpl@v0", "main.tmpl@", "main.tmpl@1.2.0", "main.tmpl@v1.2"} {
+		_, err := ParseVersionRef(ref)
+		assert.ErrorContains(t, err, "invalid version", ref)
+	}
+}
+
+func TestLocalPromptRegistry_History(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hand written`)
+	registry := NewInMemPromptRegistry(tempDir)
+
+	require.NoError(t, registry.SaveTemplate("main.tmpl", "Hello [[.name]]"))
+	require.NoError(t, registry.SaveTemplate("main.tmpl", "Hello [[.name]]"))
+	require.NoError(t, registry.SaveTemplate("main.tmpl", "Hi [[.name]]"))
+
+	versions, err := registry.History("main.tmpl")
+	require.NoError(t, err)
+	require.Len(t, versions, 3, "the content from before the first save is kept and unchanged saves are skipped")
+	for i, v := range versions {
+		assert.Equal(t, i+1, v.Version)
+		assert.Equal(t, "main.tmpl", v.Path)
+	}
+	content, err := registry.ReadVersion("main.tmpl", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Hand written", string(content))
+	_, err = registry.ReadVersion("main.tmpl", 4)
+	assert.ErrorContains(t, err, "main.tmpl has no version v4")
+
+	saved, err := registry.Rollback("main.tmpl", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 4, saved.Version)
+	template, err := registry.Find(context.Background(), "main.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello [[.name]]", template.OriginalContent)
+
+	_, err = registry.Rollback("main.tmpl", 1)
+	require.NoError(t, err)
+	template, err = registry.Find(context.Background(), "main.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, "Hand written", template.OriginalContent)
+
+	paths, err := registry.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.tmpl"}, paths, "versions aren't listed as templates")
+
+	versions, err = registry.History("missing.tmpl")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+	_, err = registry.Rollback("missing.tmpl", 0)
+	assert.ErrorContains(t, err, "no earlier version")
+}
+
+func TestLocalPromptRegistry_SaveTemplateAbsolutePath(t *testing.T) {
+	tempDir := setupTempDir(t)
+	registry := NewInMemPromptRegistry(tempDir)
+
+	require.NoError(t, registry.SaveTemplate(filepath.Join(tempDir, "nested", "main.tmpl"), "Hello"))
+	assert.Equal(t, "Hello", readTestFile(t, filepath.Join(tempDir, "nested"), "main.tmpl"))
+	versions, err := registry.History("nested/main.tmpl")
+	require.NoError(t, err)
+	assert.Len(t, versions, 1, "the version is recorded under the path within the registry")
+	paths, err := registry.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"nested/main.tmpl"}, paths)
+}
+
+func TestLocalPromptRegistry_HistoryOutsideRegistry(t *testing.T) {
+	tempDir := setupTempDir(t)
+	registry := NewInMemPromptRegistry(filepath.Join(tempDir, "registry"))
+	createTestFile(t, tempDir, "secret.txt", "secret")
+	secret := "../secret.txt"
+
+	_, err := registry.Tag(secret, 0, "v1.0.0")
+	assert.ErrorIs(t, err, ErrOutsideRegistry)
+	_, err = registry.History(secret)
+	assert.ErrorIs(t, err, ErrOutsideRegistry)
+	_, err = registry.Tags(secret)
+	assert.ErrorIs(t, err, ErrOutsideRegistry)
+	_, err = registry.ReadVersion(secret, 1)
+	assert.ErrorIs(t, err, ErrOutsideRegistry)
+	_, err = registry.Rollback(secret, 1)
+	assert.ErrorIs(t, err, ErrOutsideRegistry)
+	assert.NoDirExists(t, filepath.Join(tempDir, "registry", HistoryDir), "nothing outside the registry is copied into its history")
+}
+
+func TestLocalPromptRegistry_HistoryExtensions(t *testing.T) {
+	registry := NewInMemPromptRegistry(setupTempDir(t))
+	registry.Extensions = []string{".prompt"}
+
+	require.NoError(t, registry.SaveTemplate("main.prompt", "Hello [[.name]]"))
+	require.NoError(t, registry.SaveTemplate("main.prompt", "Hi [[.name]]"))
+	saved, err := registry.Rollback("main.prompt", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, saved.Version)
+	template, err := registry.Find(context.Background(), "main.prompt")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello [[.name]]", template.OriginalContent, "templates with a configured extension have versions")
+}
+
+func TestLocalPromptRegistry_ConfigHistory(t *testing.T) {
+	tempDir := setupTempDir(t)
+	registry := NewInMemPromptRegistry(tempDir)
+	ctx := context.Background()
+
+	require.NoError(t, registry.SaveConfig(ctx, NewConfig(map[string]any{"name": "John"}, "nested/main.json")))
+	require.NoError(t, registry.SaveConfig(ctx, NewConfig(map[string]any{"name": "Jane"}, filepath.Join(tempDir, "nested", "main.json"))))
+
+	versions, err := registry.History("nested/main.json")
+	require.NoError(t, err)
+	assert.Len(t, versions, 2, "relative and absolute paths within the registry are the same config")
+
+	_, err = registry.Rollback("nested/main.json", 1)
+	require.NoError(t, err)
+	cfg, err := registry.LoadConfig(ctx, "nested/main.json")
+	require.NoError(t, err)
+	assert.Equal(t, "John", cfg.Config["name"])
+
+	// Configs outside the registry are refused
+	outside := filepath.Join(t.TempDir(), "outside.json")
+	assert.ErrorIs(t, registry.SaveConfig(ctx, NewConfig(map[string]any{}, outside)), ErrOutsideRegistry)
+	assert.NoFileExists(t, outside)
+	entries, err := os.ReadDir(filepath.Join(tempDir, HistoryDir))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestApp_History(t *testing.T) {
+	tempDir := setupTempDir(t)
+	registry := NewInMemPromptRegistry(tempDir)
+	require.NoError(t, registry.SaveTemplate("main.tmpl", "v1"))
+	require.NoError(t, registry.SaveTemplate("main.tmpl", "v2"))
+	app, stdout, _ := newTestApp(t, WithRegistry(registry))
+	ctx := context.Background()
+	run := func(args ...string) error {
+		stdout.Reset()
+		return app.Command().Run(ctx, append([]string{"rprompt"}, args...))
+	}
+
+	require.NoError(t, run("history", "main.tmpl"))
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "v2\t"), "newest first")
+
+	require.NoError(t, run("show", "main.tmpl@v1"))
+	assert.Equal(t, "v1", stdout.String())
+
+	require.NoError(t, run("rollback", "main.tmpl"))
+	require.NoError(t, run("show", "main.tmpl"))
+	assert.Equal(t, "v1", stdout.String())
+	require.NoError(t, run("show", "main.tmpl@v3"))
+	assert.Equal(t, "v1", stdout.String())
+
+	assert.ErrorContains(t, run("show", "main.tmpl@v9"), "no version v9")
+	assert.ErrorContains(t, run("rollback", "main.tmpl@next"), "invalid version")
+
+	require.NoError(t, run("tag", "main.tmpl@v2", "v1.0.0"))
+	require.NoError(t, run("show", "main.tmpl@v1.0.0"))
+	assert.Equal(t, "v2", stdout.String())
+	require.NoError(t, run("history", "main.tmpl"))
+	assert.Contains(t, stdout.String(), "v1.0.0")
+}
+
+func TestLocalPromptRegistry_Tag(t *testing.T) {
+	tempDir := setupTempDir(t)
+	registry := NewInMemPromptRegistry(tempDir)
+	ctx := context.Background()
+	require.NoError(t, registry.SaveTemplate("main.tmpl", "Hello [[.name]]"))
+	require.NoError(t, registry.SaveConfig(ctx, NewConfig(map[string]any{"name": "John"}, "main.json")))
+
+	tagged, err := registry.Tag("main.tmpl", 0, "v1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, 1, tagged.Version)
+	_, err = registry.Tag("main.json", 0, "v1.0.0")
+	require.NoError(t, err)
+
+	// Edits outside the registry are saved as a new version when the current file is tagged
+	createTestFile(t, tempDir, "main.tmpl", "Hi [[.name]]")
+	tagged, err = registry.Tag("main.tmpl", 0, "v1.1.0")
+	require.NoError(t, err)
+	assert.Equal(t, 2, tagged.Version)
+	require.NoError(t, registry.SaveTemplate("main.tmpl", "Bye [[.name]]"))
+
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	for path, expected := range map[string]string{
+		"main.tmpl":        "Bye John",
+		"main.tmpl@v1.0.0": "Hello John",
+		"main.tmpl@v1.1.0": "Hi John",
+		"main.tmpl@v2":     "Hi John",
+	} {
+		output, err := system.Build(ctx, path, "main.json@v1.0.0")
+		require.NoError(t, err, path)
+		assert.Equal(t, expected, output, path)
+	}
+
+	versions, err := registry.History("main.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1.0.0"}, versions[0].Tags)
+	assert.Equal(t, []string{"v1.1.0"}, versions[1].Tags)
+	assert.Empty(t, versions[2].Tags)
+
+	_, err = registry.Tag("main.tmpl", 3, "v1.0.0")
+	assert.ErrorContains(t, err, "tag v1.0.0 of main.tmpl already points at v1")
+	_, err = registry.Tag("main.tmpl", 9, "v9.0.0")
+	assert.ErrorContains(t, err, "no version v9")
+	_, err = registry.Tag("main.tmpl", 0, "stable")
+	assert.ErrorContains(t, err, "invalid tag")
+	_, err = registry.Find(ctx, "main.tmpl@v2.0.0")
+	assert.ErrorContains(t, err, "main.tmpl has no tag v2.0.0")
+}
@@ -0,0 +1,29 @@
+package prompt
+
+import (
+	"context"
+	"strings"
+
+	"github.com/notzree/rprompt/v2/prompt/core"
+)
+
+// ResolveID returns the path of the template whose front matter declares the id in a reference such as
+// id:onboarding-email, keeping any version as in id:onboarding-email@v3. Paths without IDPrefix are returned
+// as they are. Ids are looked up in the registry's index where it is still fresh.
+func (r *LocalPromptRegistry) ResolveID(ctx context.Context, ref string) (string, error) {
+	id, ok := strings.CutPrefix(ref, IDPrefix)
+	if !ok {
+		return ref, nil
+	}
+	entries, err := r.entries(ctx)
+	if err != nil {
+		return "", err
+	}
+	ids := make(map[string][]string)
+	for _, entry := range entries {
+		if entry.ID != "" {
+			ids[entry.ID] = append(ids[entry.ID], entry.Path)
+		}
+	}
+	return core.PathForID(id, ids)
+}
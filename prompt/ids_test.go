@@ -0,0 +1,73 @@
+package prompt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalPromptRegistry_FindByID(t *testing.T) {
+	tempDir := setupTempDir(t)
+	registry := NewInMemPromptRegistry(tempDir)
+	require.NoError(t, registry.SaveTemplate("emails/onboarding.tmpl", "---\nid: onboarding-email\n---\nWelcome [[.name]]"))
+	createTestFile(t, tempDir, "main.tmpl", "Hi")
+	ctx := context.Background()
+
+	require.NoError(t, registry.SaveTemplate("emails/onboarding.tmpl", "---\nid: onboarding-email\n---\nHello [[.name]]"))
+	template, err := registry.Find(ctx, "id:onboarding-email")
+	require.NoError(t, err)
+	assert.Equal(t, "emails/onboarding.tmpl", template.Path)
+	assert.Contains(t, template.OriginalContent, "Hello")
+	template, err = registry.Find(ctx, "id:onboarding-email@v1")
+	require.NoError(t, err)
+	assert.Contains(t, template.OriginalContent, "Welcome", "ids keep the version")
+
+	_, err = registry.WriteIndex(ctx)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "archive"), 0755))
+	require.NoError(t, os.Rename(filepath.Join(tempDir, "emails", "onboarding.tmpl"), filepath.Join(tempDir, "archive", "onboarding.tmpl")))
+	_, err = registry.WriteIndex(ctx)
+	require.NoError(t, err)
+	template, err = registry.Find(ctx, "id:onboarding-email")
+	require.NoError(t, err)
+	assert.Equal(t, "archive/onboarding.tmpl", template.Path, "the id follows the file")
+
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	output, err := system.Build(ctx, "id:onboarding-email", "", WithValue("name", "Ada"))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello Ada", output)
+
+	_, err = system.Build(ctx, "id:missing", "")
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+	assert.ErrorContains(t, err, `no template has id "missing"`)
+
+	require.NoError(t, registry.SaveTemplate("copy.tmpl", "---\nid: onboarding-email\n---\nHey"))
+	_, err = registry.Find(ctx, "id:onboarding-email")
+	assert.EqualError(t, err, `id "onboarding-email" is declared by more than one template: archive/onboarding.tmpl, copy.tmpl`)
+}
+
+func TestFindByID_OtherRegistries(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryRegistry()
+	require.NoError(t, memory.SetTemplate("a/b.tmpl", "---\nid: greeting\n---\nHi"))
+	template, err := memory.Find(ctx, "id:greeting")
+	require.NoError(t, err)
+	assert.Equal(t, "a/b.tmpl", template.Path)
+	_, err = memory.Find(ctx, "id:other")
+	assert.ErrorContains(t, err, `no template has id "other"`)
+
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "b.tmpl", "---\nid: greeting\n---\nHi")
+	bundle, err := CompileRegistry(ctx, NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	compiled, err := NewCompiledRegistry(bundle)
+	require.NoError(t, err)
+	template, err = compiled.Find(ctx, "id:greeting")
+	require.NoError(t, err)
+	assert.Equal(t, "b.tmpl", template.Path)
+}
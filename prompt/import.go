@@ -0,0 +1,152 @@
+package prompt
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ImportOptions controls how external prompt files are brought into a registry
+type ImportOptions struct {
+	// Dest is the directory, relative to the registry root, that imported files are placed under
+	Dest string
+	// Syntax is the template syntax of the source files: SyntaxGo, the default, SyntaxJinja or SyntaxFString
+	Syntax string
+	// Delims holds the left and right delimiters used by Go template source files. When set, they are
+	// rewritten to [[ and ]]
+	Delims []string
+	// GenerateConfigs writes a config next to each imported template containing the variables it uses
+	GenerateConfigs bool
+	// Force overwrites templates that already exist in the registry
+	Force bool
+}
+
+// ImportResult describes the files written by an import
+type ImportResult struct {
+	Templates []string `json:"templates"`
+	Configs   []string `json:"configs"`
+	// Unsupported lists, per template, the source constructs that couldn't be converted
+	Unsupported []string `json:"unsupported,omitempty"`
+}
+
+// ParseDelims parses a delimiter pair of the form "{{,}}"
+func ParseDelims(s string) ([]string, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid delimiters %q, expected the form '{{,}}'", s)
+	}
+	return parts, nil
+}
+
+// ConvertDelims rewrites every action delimited by left and right to use the registry's [[ ]] delimiters
+func ConvertDelims(content, left, right string) string {
+	return replaceActions(content, left, right, LeftDelim, RightDelim, nil)
+}
+
+// convertSource converts the content of a source file from opts.Syntax to an rprompt template, returning
+// the constructs that couldn't be converted
+func convertSource(content string, opts ImportOptions) (string, []string, error) {
+	switch opts.Syntax {
+	case "", SyntaxGo:
+		if len(opts.Delims) == 2 {
+			content = ConvertDelims(content, opts.Delims[0], opts.Delims[1])
+		}
+		return content, nil, nil
+	case SyntaxJinja:
+		// Includes name files relative to the source root, which is imported under Dest
+		c := &jinjaImporter{locals: map[string]bool{}}
+		return c.convert(content, filepath.ToSlash(opts.Dest)), c.unsupported, nil
+	case SyntaxFString:
+		converted, unsupported := ConvertFString(content)
+		return converted, unsupported, nil
+	}
+	return "", nil, fmt.Errorf("unknown import syntax %q, expected one of: %s, %s, %s", opts.Syntax, SyntaxGo, SyntaxJinja, SyntaxFString)
+}
+
+// Import copies a prompt file, or every file under a directory, into the registry as .tmpl templates
+func (r *LocalPromptRegistry) Import(ctx context.Context, src string, opts ImportOptions) (*ImportResult, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", src, err)
+	}
+
+	sources := make(map[string]string)
+	if info.IsDir() {
+		err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(d.Name(), ".") && path != src {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(src, path)
+			if err != nil {
+				return err
+			}
+			sources[path] = rel
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk %s: %w", src, err)
+		}
+	} else {
+		sources[src] = filepath.Base(src)
+	}
+
+	paths := make([]string, 0, len(sources))
+	for path := range sources {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	result := &ImportResult{}
+	for _, path := range paths {
+		rel := sources[path]
+		templatePath := filepath.ToSlash(filepath.Join(opts.Dest, strings.TrimSuffix(rel, filepath.Ext(rel))+".tmpl"))
+		if !opts.Force {
+			if _, err := os.Stat(filepath.Join(r.Directory, templatePath)); err == nil {
+				return nil, fmt.Errorf("template already exists: %s", templatePath)
+			}
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		converted, unsupported, err := convertSource(string(content), opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, construct := range unsupported {
+			result.Unsupported = append(result.Unsupported, fmt.Sprintf("%s: %s", templatePath, construct))
+		}
+		if err := r.SaveTemplate(templatePath, converted); err != nil {
+			return nil, err
+		}
+		result.Templates = append(result.Templates, templatePath)
+
+		if opts.GenerateConfigs {
+			configPath := strings.TrimSuffix(templatePath, ".tmpl") + ".json"
+			template := NewTemplate(templatePath, converted, r)
+			cfg, err := template.GenerateConfig(ctx, filepath.Join(r.Directory, configPath))
+			if err != nil {
+				return nil, fmt.Errorf("err generating config for %s: %w", templatePath, err)
+			}
+			if err := r.SaveConfig(ctx, cfg); err != nil {
+				return nil, err
+			}
+			result.Configs = append(result.Configs, configPath)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,95 @@
+package prompt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDelims(t *testing.T) {
+	delims, err := ParseDelims("{{,}}")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"{{", "}}"}, delims)
+
+	_, err = ParseDelims("{{")
+	assert.Error(t, err)
+
+	_, err = ParseDelims(",}}")
+	assert.Error(t, err)
+}
+
+func TestConvertDelims(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "single action",
+			content:  "Hello {{.name}}",
+			expected: "Hello [[.name]]",
+		},
+		{
+			name:     "multiple actions",
+			content:  "{{if .premium}}Hi {{.name}}{{end}}",
+			expected: "[[if .premium]]Hi [[.name]][[end]]",
+		},
+		{
+			name:     "unterminated action is left alone",
+			content:  "Hello {{.name",
+			expected: "Hello {{.name",
+		},
+		{
+			name:     "no actions",
+			content:  "plain text",
+			expected: "plain text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ConvertDelims(tt.content, "{{", "}}"))
+		})
+	}
+}
+
+func TestLocalPromptRegistry_Import(t *testing.T) {
+	srcDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "nested"), 0755))
+	createTestFile(t, srcDir, "greeting.txt", "Hello {{.user.name}}")
+	createTestFile(t, srcDir, "nested/summary.md", "Summarize {{.document}}")
+	createTestFile(t, srcDir, ".hidden", "ignored")
+
+	registryDir := setupTempDir(t)
+	registry := NewInMemPromptRegistry(registryDir)
+
+	result, err := registry.Import(context.Background(), srcDir, ImportOptions{
+		Dest:            "imported",
+		Delims:          []string{"{{", "}}"},
+		GenerateConfigs: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"imported/greeting.tmpl", "imported/nested/summary.tmpl"}, result.Templates)
+	assert.Equal(t, []string{"imported/greeting.json", "imported/nested/summary.json"}, result.Configs)
+
+	template, err := registry.Find(context.Background(), "imported/greeting.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello [[.user.name]]", template.OriginalContent)
+
+	cfg, err := registry.LoadConfig(context.Background(), "imported/greeting.json")
+	require.NoError(t, err)
+	assert.Contains(t, cfg.Config, "user")
+
+	t.Run("refuses to overwrite without force", func(t *testing.T) {
+		_, err := registry.Import(context.Background(), filepath.Join(srcDir, "greeting.txt"), ImportOptions{Dest: "imported"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "template already exists")
+
+		_, err = registry.Import(context.Background(), filepath.Join(srcDir, "greeting.txt"), ImportOptions{Dest: "imported", Force: true})
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,673 @@
+package prompt
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Source syntaxes Import can convert from
+const (
+	// SyntaxGo is Go template syntax, with ImportOptions.Delims rewritten to [[ ]] when set
+	SyntaxGo = "go"
+	// SyntaxJinja is Jinja2 syntax, also used by Nunjucks and LangChain's jinja2 templates
+	SyntaxJinja = "jinja"
+	// SyntaxFString is Python str.format / f-string syntax, as used by LangChain's default templates
+	SyntaxFString = "fstring"
+)
+
+// ConvertJinja converts a Jinja2 prompt to an rprompt template. Variables, attribute and item access,
+// if/elif/else, for loops, set, include, comments and the filters with a Go template equivalent are
+// translated; anything else is left in the output as a comment and listed in the returned constructs.
+func ConvertJinja(content string) (string, []string) {
+	c := &jinjaImporter{locals: map[string]bool{}}
+	return c.convert(content, ""), c.unsupported
+}
+
+// ConvertFString converts a Python f-string style prompt, such as "Hello {name}", to an rprompt template.
+// Fields, attribute and item access and simple format specs are translated; positional fields and
+// expressions are left in place and listed in the returned constructs.
+func ConvertFString(content string) (string, []string) {
+	var b strings.Builder
+	var unsupported []string
+	for i := 0; i < len(content); i++ {
+		ch := content[i]
+		switch {
+		case ch == '{' && strings.HasPrefix(content[i:], "{{"):
+			b.WriteByte('{')
+			i++
+		case ch == '}' && strings.HasPrefix(content[i:], "}}"):
+			b.WriteByte('}')
+			i++
+		case ch == '{':
+			end := strings.IndexByte(content[i:], '}')
+			if end < 0 {
+				b.WriteString(escapeTemplateText(content[i:]))
+				return b.String(), unsupported
+			}
+			field := content[i+1 : i+end]
+			action, ok := convertFStringField(field)
+			if !ok {
+				unsupported = append(unsupported, "{"+field+"}")
+				b.WriteString(escapeTemplateText("{" + field + "}"))
+			} else {
+				b.WriteString(LeftDelim + action + RightDelim)
+			}
+			i += end
+		case ch == '[' && strings.HasPrefix(content[i:], LeftDelim):
+			b.WriteString(escapeTemplateText(LeftDelim))
+			i++
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String(), unsupported
+}
+
+var (
+	fstringFieldPattern  = regexp.MustCompile(`^([A-Za-z_]\w*)((?:\.[A-Za-z_]\w*|\[[^\]]+\])*)(?:!([rsa]))?(?::(.*))?$`)
+	fstringAccessPattern = regexp.MustCompile(`\.\w+|\[[^\]]+\]`)
+	fstringSpecPattern   = regexp.MustCompile(`^[+\- ]?0?\d*(?:\.\d+)?[bdoxXeEfFgGs]?$`)
+)
+
+// convertFStringField converts the inside of a replacement field, e.g. "user.name" or "score:.2f"
+func convertFStringField(field string) (string, bool) {
+	m := fstringFieldPattern.FindStringSubmatch(strings.TrimSpace(field))
+	if m == nil {
+		return "", false
+	}
+	value := goExpr{code: "." + m[1]}
+	for _, part := range fstringAccessPattern.FindAllString(m[2], -1) {
+		if strings.HasPrefix(part, ".") {
+			value = goExpr{code: value.arg() + part}
+			continue
+		}
+		key := part[1 : len(part)-1]
+		if _, err := strconv.Atoi(key); err != nil {
+			key = strconv.Quote(key)
+		}
+		value = goCall("index", value, goExpr{code: key})
+	}
+
+	switch {
+	case m[3] == "r" || m[3] == "a":
+		if m[4] != "" {
+			return "", false
+		}
+		return goCall("printf", goExpr{code: `"%q"`}, value).code, true
+	case m[4] != "":
+		spec := m[4]
+		if !fstringSpecPattern.MatchString(spec) {
+			return "", false
+		}
+		if unicode.IsDigit(rune(spec[len(spec)-1])) {
+			spec += "v"
+		}
+		return goCall("printf", goExpr{code: strconv.Quote("%" + spec)}, value).code, true
+	}
+	return value.code, true
+}
+
+// escapeTemplateText makes literal text safe to place in a template by quoting any left delimiters
+func escapeTemplateText(text string) string {
+	return strings.ReplaceAll(text, LeftDelim, LeftDelim+`"`+LeftDelim+`"`+RightDelim)
+}
+
+// jinjaImporter converts Jinja2 source to Go template syntax
+type jinjaImporter struct {
+	// locals are the Jinja2 variables declared by for and set, which become template variables
+	locals map[string]bool
+	// blocks holds the open if and for statements, innermost last
+	blocks []jinjaBlock
+	// loops counts the open for statements, inside which the root is reached through $
+	loops       int
+	unsupported []string
+}
+
+// jinjaBlock is an open if or for statement along with the variables declared in it, which Go templates
+// scope to the block
+type jinjaBlock struct {
+	kind   string
+	locals []string
+}
+
+func (c *jinjaImporter) fail(b *strings.Builder, source, reason string) {
+	c.unsupported = append(c.unsupported, fmt.Sprintf("%s: %s", source, reason))
+	fmt.Fprintf(b, "%s/* unsupported: %s */%s", LeftDelim, strings.ReplaceAll(source, "*/", "* /"), RightDelim)
+}
+
+// convert converts content, with includes resolved relative to dest
+func (c *jinjaImporter) convert(content, dest string) string {
+	var b strings.Builder
+	rest := content
+	for {
+		start := indexJinjaTag(rest)
+		if start < 0 {
+			b.WriteString(escapeTemplateText(rest))
+			break
+		}
+		b.WriteString(escapeTemplateText(rest[:start]))
+		rest = rest[start:]
+
+		closing := map[byte]string{'{': "}}", '%': "%}", '#': "#}"}[rest[1]]
+		end := strings.Index(rest[2:], closing)
+		if end < 0 {
+			c.fail(&b, rest, "unterminated tag")
+			break
+		}
+		tag := rest[:end+4]
+		rest = rest[end+4:]
+
+		inner := tag[2 : len(tag)-2]
+		trimLeft, trimRight := strings.HasPrefix(inner, "-"), strings.HasSuffix(inner, "-")
+		inner = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(inner, "-"), "-"))
+
+		switch tag[1] {
+		case '#':
+			b.WriteString(LeftDelim + "/* " + strings.ReplaceAll(inner, "*/", "* /") + " */" + RightDelim)
+		case '{':
+			expr, err := c.expr(inner)
+			if err != nil {
+				c.fail(&b, tag, err.Error())
+				continue
+			}
+			b.WriteString(jinjaAction(expr, trimLeft, trimRight))
+		case '%':
+			if inner == "raw" {
+				raw := regexp.MustCompile(`\{%-?\s*endraw\s*-?%\}`).FindStringIndex(rest)
+				if raw == nil {
+					c.fail(&b, tag, "unterminated raw block")
+					continue
+				}
+				b.WriteString(escapeTemplateText(rest[:raw[0]]))
+				rest = rest[raw[1]:]
+				continue
+			}
+			statement, err := c.statement(inner, dest)
+			if err != nil {
+				c.fail(&b, tag, err.Error())
+				continue
+			}
+			b.WriteString(jinjaAction(statement, trimLeft, trimRight))
+		}
+	}
+	for range c.blocks {
+		c.unsupported = append(c.unsupported, "unclosed block")
+		b.WriteString(LeftDelim + "end" + RightDelim)
+	}
+	return b.String()
+}
+
+func indexJinjaTag(s string) int {
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] == '{' && (s[i+1] == '{' || s[i+1] == '%' || s[i+1] == '#') {
+			return i
+		}
+	}
+	return -1
+}
+
+// jinjaAction wraps a converted expression or statement in delimiters, keeping Jinja2 whitespace control
+func jinjaAction(code string, trimLeft, trimRight bool) string {
+	if trimLeft {
+		code = "- " + code
+	}
+	if trimRight {
+		code += " -"
+	}
+	return LeftDelim + code + RightDelim
+}
+
+var forPattern = regexp.MustCompile(`^for\s+(\w+)(?:\s*,\s*(\w+))?\s+in\s+(.+)$`)
+
+// statement converts the inside of a {% %} tag
+func (c *jinjaImporter) statement(inner, dest string) (string, error) {
+	keyword, rest, _ := strings.Cut(inner, " ")
+	rest = strings.TrimSpace(rest)
+	switch keyword {
+	case "if":
+		cond, err := c.expr(rest)
+		if err != nil {
+			return "", err
+		}
+		c.blocks = append(c.blocks, jinjaBlock{kind: "if"})
+		return "if " + cond, nil
+	case "elif":
+		if !c.inBlock("if") {
+			return "", fmt.Errorf("elif outside of an if")
+		}
+		cond, err := c.expr(rest)
+		if err != nil {
+			return "", err
+		}
+		return "else if " + cond, nil
+	case "else":
+		if len(c.blocks) == 0 {
+			return "", fmt.Errorf("else outside of an if or for")
+		}
+		return "else", nil
+	case "endif", "endfor":
+		if !c.inBlock(strings.TrimPrefix(keyword, "end")) {
+			return "", fmt.Errorf("%s without a matching block", keyword)
+		}
+		if keyword == "endfor" {
+			c.loops--
+		}
+		for _, name := range c.blocks[len(c.blocks)-1].locals {
+			delete(c.locals, name)
+		}
+		c.blocks = c.blocks[:len(c.blocks)-1]
+		return "end", nil
+	case "for":
+		m := forPattern.FindStringSubmatch(inner)
+		if m == nil {
+			return "", fmt.Errorf("unsupported loop")
+		}
+		iterable := strings.TrimSpace(m[3])
+		if m[2] != "" {
+			// Go templates range over maps in key order, giving the same pairs as dict.items()
+			if !strings.HasSuffix(iterable, ".items()") {
+				return "", fmt.Errorf("unpacking is only supported over dict.items()")
+			}
+			iterable = strings.TrimSuffix(iterable, ".items()")
+		}
+		expr, err := c.expr(iterable)
+		if err != nil {
+			return "", err
+		}
+		c.blocks = append(c.blocks, jinjaBlock{kind: "for"})
+		c.loops++
+		c.declare(m[1])
+		if m[2] != "" {
+			c.declare(m[2])
+			return fmt.Sprintf("range $%s, $%s := %s", m[1], m[2], expr), nil
+		}
+		return fmt.Sprintf("range $%s := %s", m[1], expr), nil
+	case "set":
+		name, value, ok := strings.Cut(rest, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !isJinjaIdent(name) {
+			return "", fmt.Errorf("only single variable assignments are supported")
+		}
+		expr, err := c.expr(value)
+		if err != nil {
+			return "", err
+		}
+		op := ":="
+		if c.locals[name] {
+			op = "="
+		} else {
+			c.declare(name)
+		}
+		return fmt.Sprintf("$%s %s %s", name, op, expr), nil
+	case "include":
+		name, err := strconv.Unquote(pythonQuoted(rest))
+		if err != nil {
+			return "", fmt.Errorf("only includes of a literal name are supported")
+		}
+		included := path.Join(dest, strings.TrimSuffix(name, path.Ext(name))+".tmpl")
+		return fmt.Sprintf("template %q %s", included, c.root()), nil
+	}
+	return "", fmt.Errorf("no rprompt equivalent")
+}
+
+func (c *jinjaImporter) inBlock(kind string) bool {
+	return len(c.blocks) > 0 && c.blocks[len(c.blocks)-1].kind == kind
+}
+
+// declare adds a template variable, scoped to the innermost block
+func (c *jinjaImporter) declare(name string) {
+	c.locals[name] = true
+	if len(c.blocks) > 0 {
+		block := &c.blocks[len(c.blocks)-1]
+		block.locals = append(block.locals, name)
+	}
+}
+
+// root is the expression for the template's data, which inside a range is no longer the dot
+func (c *jinjaImporter) root() string {
+	if c.loops > 0 {
+		return "$"
+	}
+	return "."
+}
+
+// expr converts a Jinja2 expression to a Go template pipeline
+func (c *jinjaImporter) expr(source string) (string, error) {
+	tokens, err := lexJinja(source)
+	if err != nil {
+		return "", err
+	}
+	p := &jinjaParser{c: c, tokens: tokens}
+	e, err := p.or()
+	if err != nil {
+		return "", err
+	}
+	if p.pos < len(p.tokens) {
+		return "", fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return e.code, nil
+}
+
+// goExpr is a converted expression. Calls need parentheses when used as an argument.
+type goExpr struct {
+	code string
+	call bool
+}
+
+func (e goExpr) arg() string {
+	if e.call {
+		return "(" + e.code + ")"
+	}
+	return e.code
+}
+
+func goCall(name string, args ...goExpr) goExpr {
+	parts := []string{name}
+	for _, arg := range args {
+		parts = append(parts, arg.arg())
+	}
+	return goExpr{code: strings.Join(parts, " "), call: true}
+}
+
+type jinjaParser struct {
+	c      *jinjaImporter
+	tokens []string
+	pos    int
+}
+
+func (p *jinjaParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *jinjaParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *jinjaParser) expect(token string) error {
+	if got := p.next(); got != token {
+		return fmt.Errorf("expected %q, got %q", token, got)
+	}
+	return nil
+}
+
+func (p *jinjaParser) or() (goExpr, error) {
+	return p.binary("or", p.and)
+}
+
+func (p *jinjaParser) and() (goExpr, error) {
+	return p.binary("and", p.not)
+}
+
+func (p *jinjaParser) binary(op string, operand func() (goExpr, error)) (goExpr, error) {
+	left, err := operand()
+	if err != nil {
+		return goExpr{}, err
+	}
+	for p.peek() == op {
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return goExpr{}, err
+		}
+		left = goCall(op, left, right)
+	}
+	return left, nil
+}
+
+func (p *jinjaParser) not() (goExpr, error) {
+	if p.peek() == "not" {
+		p.next()
+		operand, err := p.not()
+		if err != nil {
+			return goExpr{}, err
+		}
+		return goCall("not", operand), nil
+	}
+	return p.compare()
+}
+
+var goComparisons = map[string]string{"==": "eq", "!=": "ne", "<": "lt", "<=": "le", ">": "gt", ">=": "ge"}
+
+func (p *jinjaParser) compare() (goExpr, error) {
+	left, err := p.concat()
+	if err != nil {
+		return goExpr{}, err
+	}
+	if p.peek() == "is" {
+		p.next()
+		negate := p.peek() == "not"
+		if negate {
+			p.next()
+		}
+		if test := p.next(); test != "defined" {
+			return goExpr{}, fmt.Errorf("unsupported test %q", test)
+		}
+		// Missing keys render as empty, so definedness is the closest Go templates get to truthiness
+		if negate {
+			return goCall("not", left), nil
+		}
+		return left, nil
+	}
+	op, ok := goComparisons[p.peek()]
+	if !ok {
+		if p.peek() == "in" {
+			return goExpr{}, fmt.Errorf("unsupported operator \"in\"")
+		}
+		return left, nil
+	}
+	p.next()
+	right, err := p.concat()
+	if err != nil {
+		return goExpr{}, err
+	}
+	return goCall(op, left, right), nil
+}
+
+func (p *jinjaParser) concat() (goExpr, error) {
+	parts := []goExpr{}
+	for {
+		operand, err := p.filtered()
+		if err != nil {
+			return goExpr{}, err
+		}
+		parts = append(parts, operand)
+		if p.peek() != "~" {
+			break
+		}
+		p.next()
+	}
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	return goCall("print", parts...), nil
+}
+
+func (p *jinjaParser) filtered() (goExpr, error) {
+	value, err := p.postfix()
+	if err != nil {
+		return goExpr{}, err
+	}
+	for p.peek() == "|" {
+		p.next()
+		name := p.next()
+		var args []goExpr
+		if p.peek() == "(" {
+			if args, err = p.args(); err != nil {
+				return goExpr{}, err
+			}
+		}
+		if value, err = applyFilter(name, value, args); err != nil {
+			return goExpr{}, err
+		}
+	}
+	return value, nil
+}
+
+// applyFilter maps a Jinja2 filter to the equivalent template function
+func applyFilter(name string, value goExpr, args []goExpr) (goExpr, error) {
+	switch {
+	case (name == "length" || name == "count") && len(args) == 0:
+		return goCall("len", value), nil
+	case (name == "e" || name == "escape") && len(args) == 0:
+		return goCall("html", value), nil
+	case name == "urlencode" && len(args) == 0:
+		return goCall("urlquery", value), nil
+	case name == "string" && len(args) == 0:
+		return goCall("print", value), nil
+	case name == "first" && len(args) == 0:
+		return goCall("index", value, goExpr{code: "0"}), nil
+	case name == "safe" && len(args) == 0:
+		return value, nil
+	case (name == "default" || name == "d") && len(args) == 1:
+		return goCall("or", value, args[0]), nil
+	case name == "format":
+		return goCall("printf", append([]goExpr{value}, args...)...), nil
+	}
+	return goExpr{}, fmt.Errorf("unsupported filter %q", name)
+}
+
+func (p *jinjaParser) args() ([]goExpr, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args []goExpr
+	for p.peek() != ")" {
+		arg, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.peek() == "," {
+			p.next()
+		} else if p.peek() != ")" {
+			return nil, fmt.Errorf("expected \",\" or \")\", got %q", p.peek())
+		}
+	}
+	p.next()
+	return args, nil
+}
+
+func (p *jinjaParser) postfix() (goExpr, error) {
+	value, err := p.primary()
+	if err != nil {
+		return goExpr{}, err
+	}
+	for {
+		switch p.peek() {
+		case ".":
+			p.next()
+			field := p.next()
+			if !isJinjaIdent(field) {
+				return goExpr{}, fmt.Errorf("unexpected %q after \".\"", field)
+			}
+			value = goExpr{code: value.arg() + "." + field}
+		case "[":
+			p.next()
+			key, err := p.or()
+			if err != nil {
+				return goExpr{}, err
+			}
+			if err := p.expect("]"); err != nil {
+				return goExpr{}, err
+			}
+			if field, err := strconv.Unquote(key.code); err == nil && isJinjaIdent(field) && !value.call {
+				value = goExpr{code: value.code + "." + field}
+			} else {
+				value = goCall("index", value, key)
+			}
+		case "(":
+			return goExpr{}, fmt.Errorf("function calls are not supported")
+		default:
+			return value, nil
+		}
+	}
+}
+
+func (p *jinjaParser) primary() (goExpr, error) {
+	token := p.next()
+	switch {
+	case token == "(":
+		inner, err := p.or()
+		if err != nil {
+			return goExpr{}, err
+		}
+		return inner, p.expect(")")
+	case token == "true" || token == "True":
+		return goExpr{code: "true"}, nil
+	case token == "false" || token == "False":
+		return goExpr{code: "false"}, nil
+	case token == "none" || token == "None":
+		return goExpr{code: "nil"}, nil
+	case token == "loop":
+		return goExpr{}, fmt.Errorf("loop variables are not supported")
+	case isJinjaIdent(token):
+		if p.c.locals[token] {
+			return goExpr{code: "$" + token}, nil
+		}
+		if p.c.loops > 0 {
+			return goExpr{code: "$." + token}, nil
+		}
+		return goExpr{code: "." + token}, nil
+	case token != "" && (token[0] == '"' || token[0] == '\''):
+		return goExpr{code: pythonQuoted(token)}, nil
+	case token != "" && (unicode.IsDigit(rune(token[0]))):
+		return goExpr{code: token}, nil
+	case token == "":
+		return goExpr{}, fmt.Errorf("unexpected end of expression")
+	}
+	return goExpr{}, fmt.Errorf("unsupported operator %q", token)
+}
+
+// pythonQuoted rewrites a single or double quoted Python string literal as a Go string literal
+func pythonQuoted(literal string) string {
+	if len(literal) < 2 || (literal[0] != '\'' && literal[0] != '"') || literal[len(literal)-1] != literal[0] {
+		return literal
+	}
+	inner := literal[1 : len(literal)-1]
+	if literal[0] == '\'' {
+		inner = strings.ReplaceAll(strings.ReplaceAll(inner, `\'`, `'`), `"`, `\"`)
+	}
+	if unquoted, err := strconv.Unquote(`"` + inner + `"`); err == nil {
+		return strconv.Quote(unquoted)
+	}
+	return strconv.Quote(inner)
+}
+
+func isJinjaIdent(s string) bool {
+	if s == "" || !(unicode.IsLetter(rune(s[0])) || s[0] == '_') {
+		return false
+	}
+	for _, r := range s {
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+var jinjaTokenPattern = regexp.MustCompile(`^(?:\s+|"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|\d+(?:\.\d+)?|[A-Za-z_]\w*|==|!=|<=|>=|\*\*|//|[<>|~()\[\].,+\-*/%=])`)
+
+// lexJinja splits a Jinja2 expression into tokens
+func lexJinja(source string) ([]string, error) {
+	var tokens []string
+	for source != "" {
+		token := jinjaTokenPattern.FindString(source)
+		if token == "" {
+			return nil, fmt.Errorf("unexpected %q", source[:1])
+		}
+		source = source[len(token):]
+		if strings.TrimSpace(token) != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
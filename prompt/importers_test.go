@@ -0,0 +1,113 @@
+package prompt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertJinja(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"variable", `Hello {{ user.name }}!`, `Hello [[.user.name]]!`},
+		{"item access", `{{ user["name"] }} {{ items[0] }}`, `[[.user.name]] [[index .items 0]]`},
+		{"if elif else", `{% if admin %}a{% elif role == 'mod' %}m{% else %}u{% endif %}`, `[[if .admin]]a[[else if eq .role "mod"]]m[[else]]u[[end]]`},
+		{"logic", `{% if a and not b %}x{% endif %}`, `[[if and .a (not .b)]]x[[end]]`},
+		{"for", `{% for doc in docs %}- {{ doc.title }} for {{ user }}{% endfor %}`, `[[range $doc := .docs]]- [[$doc.title]] for [[$.user]][[end]]`},
+		{"for items", `{% for k, v in meta.items() %}{{ k }}={{ v }}{% endfor %}`, `[[range $k, $v := .meta]][[$k]]=[[$v]][[end]]`},
+		{"set", `{% set n = docs|length %}{{ n }}`, `[[$n := len .docs]][[$n]]`},
+		{"filters", `{{ name|default("anon") }} {{ q|urlencode }} {{ "%d items"|format(n) }}`, `[[or .name "anon"]] [[urlquery .q]] [[printf "%d items" .n]]`},
+		{"concat", `{{ a ~ "-" ~ b }}`, `[[print .a "-" .b]]`},
+		{"is defined", `{% if x is not defined %}none{% endif %}`, `[[if not .x]]none[[end]]`},
+		{"whitespace control", "{%- if x -%}\n y {{- z }}{% endif %}", "[[- if .x -]]\n y [[- .z]][[end]]"},
+		{"comment", `{# note #}hi`, `[[/* note */]]hi`},
+		{"raw", `{% raw %}{{ literal }}{% endraw %}`, `{{ literal }}`},
+		{"include", `{% include "partials/header.j2" %}`, `[[template "partials/header.tmpl" .]]`},
+		{"escaped delimiters", `[[x]] {{ y }}`, `[["[["]]x]] [[.y]]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unsupported := ConvertJinja(tt.source)
+			assert.Equal(t, tt.want, got)
+			assert.Empty(t, unsupported)
+		})
+	}
+}
+
+func TestConvertJinja_Unsupported(t *testing.T) {
+	got, unsupported := ConvertJinja(`{% macro greet(n) %}hi{% endmacro %}{{ name|upper }}{{ loop.index }}`)
+
+	assert.Len(t, unsupported, 4)
+	assert.Contains(t, unsupported[2], `unsupported filter "upper"`)
+	assert.Contains(t, got, `[[/* unsupported: {{ name|upper }} */]]`)
+	assert.Contains(t, got, "hi")
+}
+
+func TestConvertJinja_Renders(t *testing.T) {
+	source := `{% for doc in docs %}{{ doc.title }};{% endfor %} for {{ user|default("you") }}`
+	converted, unsupported := ConvertJinja(source)
+	require.Empty(t, unsupported)
+
+	template := NewTemplate("main.tmpl", converted, NewInMemPromptRegistry(setupTempDir(t)))
+	output, err := template.Build(context.Background(), *NewConfig(map[string]any{
+		"docs": []any{map[string]any{"title": "A"}, map[string]any{"title": "B"}},
+	}, ""))
+	require.NoError(t, err)
+	assert.Equal(t, "A;B; for you", output)
+}
+
+func TestConvertFString(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"field", `Hello {name}!`, `Hello [[.name]]!`},
+		{"attribute and item", `{user.name} {docs[0]} {meta[lang]}`, `[[.user.name]] [[index .docs 0]] [[index .meta "lang"]]`},
+		{"format spec", `{score:.2f} {count:5}`, `[[printf "%.2f" .score]] [[printf "%5v" .count]]`},
+		{"repr", `{query!r}`, `[[printf "%q" .query]]`},
+		{"escaped braces", `{{"key": {value}}}`, `{"key": [[.value]]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unsupported := ConvertFString(tt.source)
+			assert.Equal(t, tt.want, got)
+			assert.Empty(t, unsupported)
+		})
+	}
+
+	got, unsupported := ConvertFString(`{} and {a + b}`)
+	assert.Equal(t, []string{"{}", "{a + b}"}, unsupported)
+	assert.Equal(t, `{} and {a + b}`, got)
+}
+
+func TestLocalPromptRegistry_ImportJinja(t *testing.T) {
+	srcDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "partials"), 0755))
+	createTestFile(t, srcDir, "main.j2", `{% include "partials/intro.j2" %} {{ topic|upper }}`)
+	createTestFile(t, srcDir, "partials/intro.j2", `Hi {{ name }}`)
+
+	registry := NewInMemPromptRegistry(setupTempDir(t))
+	result, err := registry.Import(context.Background(), srcDir, ImportOptions{Dest: "imported", Syntax: SyntaxJinja})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"imported/main.tmpl", "imported/partials/intro.tmpl"}, result.Templates)
+	require.Len(t, result.Unsupported, 1)
+	assert.Contains(t, result.Unsupported[0], "imported/main.tmpl: {{ topic|upper }}")
+
+	system, _ := NewPromptSystem(registry)
+	template, err := system.Find(context.Background(), "imported/main.tmpl")
+	require.NoError(t, err)
+	output, err := template.Build(context.Background(), *NewConfig(map[string]any{"name": "Ada"}, ""))
+	require.NoError(t, err)
+	assert.Equal(t, "Hi Ada ", output)
+
+	_, err = registry.Import(context.Background(), srcDir, ImportOptions{Syntax: "mustache"})
+	assert.ErrorContains(t, err, "unknown import syntax")
+}
@@ -0,0 +1,89 @@
+package prompt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// BuildStateFile is the name of the file in a registry's directory recording the outputs generate
+// --only-changed and build-all wrote, so later runs can skip the outputs whose inputs haven't changed
+const BuildStateFile = ".rprompt-build.json"
+
+// buildStateVersion is bumped whenever the build state format changes, so older states are ignored
+const buildStateVersion = 1
+
+// BuildState records how each output was last built
+type BuildState struct {
+	Version int `json:"version"`
+	// Outputs are keyed by the output's absolute path
+	Outputs map[string]BuildRecord `json:"outputs"`
+}
+
+// LoadBuildState reads the registry's BuildStateFile, returning an empty state if there is none yet or it was
+// written by a different version of rprompt
+func (r *LocalPromptRegistry) LoadBuildState() (*BuildState, error) {
+	state := &BuildState{Version: buildStateVersion, Outputs: map[string]BuildRecord{}}
+	data, err := os.ReadFile(filepath.Join(r.Directory, BuildStateFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read build state: %w", err)
+	}
+	saved := &BuildState{}
+	if err := json.Unmarshal(data, saved); err != nil {
+		return nil, fmt.Errorf("invalid build state %s: %w", BuildStateFile, err)
+	}
+	if saved.Version != buildStateVersion || saved.Outputs == nil {
+		return state, nil
+	}
+	return saved, nil
+}
+
+// SaveBuildState writes state to the registry's BuildStateFile
+func (r *LocalPromptRegistry) SaveBuildState(state *BuildState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode build state: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(r.Directory, BuildStateFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write build state: %w", err)
+	}
+	return nil
+}
+
+// Fresh reports whether the output was last built from the same inputs as record and is still as it was written
+func (s *BuildState) Fresh(output string, record BuildRecord) bool {
+	last, ok := s.Outputs[buildStateKey(output)]
+	if !ok || last.Template != record.Template || last.Config != record.Config || last.Hash != record.Hash || last.Options != record.Options {
+		return false
+	}
+	content, err := os.ReadFile(output)
+	return err == nil && last.OutputHash == contentHash(content)
+}
+
+// Record notes that content was written to output from the inputs of record
+func (s *BuildState) Record(output string, record BuildRecord, content []byte) {
+	record.OutputHash = contentHash(content)
+	s.Outputs[buildStateKey(output)] = record
+}
+
+// buildStateKey returns the absolute path outputs are recorded under, so runs from different directories agree
+func buildStateKey(output string) string {
+	if abs, err := filepath.Abs(output); err == nil {
+		return abs
+	}
+	return output
+}
+
+// contentHash returns the hex-encoded sha256 of content
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,135 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildState(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hi [[.name]] [[template "footer.tmpl"]]`)
+	createTestFile(t, tempDir, "footer.tmpl", `bye`)
+	createTestFile(t, tempDir, "main.json", `{"name": "Ada"}`)
+	registry := NewInMemPromptRegistry(tempDir)
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	ctx := context.Background()
+	output := filepath.Join(t.TempDir(), "out.txt")
+
+	state, err := registry.LoadBuildState()
+	require.NoError(t, err)
+	record, err := system.BuildRecord(ctx, "main.tmpl", "main.json", "text")
+	require.NoError(t, err)
+	assert.False(t, state.Fresh(output, record), "outputs never built are stale")
+
+	require.NoError(t, os.WriteFile(output, []byte("Hi Ada bye"), 0644))
+	state.Record(output, record, []byte("Hi Ada bye"))
+	require.NoError(t, registry.SaveBuildState(state))
+	state, err = registry.LoadBuildState()
+	require.NoError(t, err)
+	assert.True(t, state.Fresh(output, record))
+
+	other, err := system.BuildRecord(ctx, "main.tmpl", "main.json", "json")
+	require.NoError(t, err)
+	assert.False(t, state.Fresh(output, other), "different options")
+
+	createTestFile(t, tempDir, "footer.tmpl", `see you`)
+	changed, err := system.BuildRecord(ctx, "main.tmpl", "main.json", "text")
+	require.NoError(t, err)
+	assert.NotEqual(t, record.Hash, changed.Hash)
+	assert.False(t, state.Fresh(output, changed), "an include changed")
+
+	require.NoError(t, os.WriteFile(output, []byte("edited"), 0644))
+	assert.False(t, state.Fresh(output, record), "the output was edited")
+
+	createTestFile(t, tempDir, BuildStateFile, `{"version": 0, "outputs": {"x": {}}}`)
+	state, err = registry.LoadBuildState()
+	require.NoError(t, err)
+	assert.Empty(t, state.Outputs, "states of other versions are ignored")
+}
+
+func TestApp_GenerateOnlyChanged(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hi [[.name]]`)
+	createTestFile(t, tempDir, "main.json", `{"name": "Ada"}`)
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	outPath := filepath.Join(t.TempDir(), "out.txt")
+	ctx := context.Background()
+	generate := func(args ...string) {
+		t.Helper()
+		stdout.Reset()
+		require.NoError(t, app.Command().Run(ctx, append([]string{"rprompt", "generate", "-t", "main.tmpl", "-c", "main.json", "-o", outPath, "--only-changed"}, args...)))
+	}
+
+	generate()
+	assert.Contains(t, stdout.String(), "Successfully generated prompt")
+	generate()
+	assert.Contains(t, stdout.String(), "Skipped main.tmpl, unchanged")
+
+	createTestFile(t, tempDir, "main.json", `{"name": "Alan"}`)
+	generate()
+	assert.Contains(t, stdout.String(), "Successfully generated prompt")
+	assert.Equal(t, "Hi Alan", readTestFile(t, filepath.Dir(outPath), "out.txt"))
+
+	generate("--format", FormatJSON)
+	assert.Contains(t, stdout.String(), "Successfully generated prompt", "the format changed")
+	generate("--format", FormatJSON)
+	assert.Contains(t, stdout.String(), "Skipped")
+
+	err := app.Command().Run(ctx, []string{"rprompt", "generate", "-t", "main.tmpl", "-c", "main.json", "--clipboard", "--only-changed"})
+	assert.EqualError(t, err, "--only-changed can't be used with --clipboard")
+}
+
+func TestApp_BuildAll(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hi [[.name]] [[template "footer.tmpl"]]`)
+	createTestFile(t, tempDir, "main.json", `{"name": "Ada"}`)
+	createTestFile(t, tempDir, "other.tmpl", `Other [[.n]]`)
+	createTestFile(t, tempDir, "other.json", `{"n": 1}`)
+	createTestFile(t, tempDir, "footer.tmpl", `bye`)
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	outDir := t.TempDir()
+	ctx := context.Background()
+	buildAll := func(args ...string) map[string]bool {
+		t.Helper()
+		stdout.Reset()
+		require.NoError(t, app.Command().Run(ctx, append([]string{"rprompt", "--json", "build-all", "-o", filepath.Join(outDir, "{{template_stem}}.txt")}, args...)))
+		var report struct {
+			Outputs []struct {
+				Template string `json:"template"`
+				Skipped  bool   `json:"skipped"`
+			} `json:"outputs"`
+		}
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+		skipped := map[string]bool{}
+		for _, output := range report.Outputs {
+			skipped[output.Template] = output.Skipped
+		}
+		return skipped
+	}
+
+	assert.Equal(t, map[string]bool{"main.tmpl": false, "other.tmpl": false}, buildAll(), "footer.tmpl has no config")
+	assert.Equal(t, "Hi Ada bye", readTestFile(t, outDir, "main.txt"))
+	assert.Equal(t, map[string]bool{"main.tmpl": true, "other.tmpl": true}, buildAll())
+
+	createTestFile(t, tempDir, "footer.tmpl", `see you`)
+	assert.Equal(t, map[string]bool{"main.tmpl": false, "other.tmpl": true}, buildAll())
+	assert.Equal(t, "Hi Ada see you", readTestFile(t, outDir, "main.txt"))
+
+	require.NoError(t, os.Remove(filepath.Join(outDir, "other.txt")))
+	assert.Equal(t, map[string]bool{"main.tmpl": true, "other.tmpl": false}, buildAll())
+	assert.Equal(t, map[string]bool{"main.tmpl": false, "other.tmpl": false}, buildAll("--force"))
+
+	stdout.Reset()
+	require.NoError(t, app.Command().Run(ctx, []string{"rprompt", "build-all", "-o", filepath.Join(outDir, "{{template_stem}}.txt")}))
+	assert.Contains(t, stdout.String(), "Generated 0 prompts, 2 unchanged")
+
+	err := app.Command().Run(ctx, []string{"rprompt", "build-all", "-o", filepath.Join(outDir, "out.txt")})
+	assert.ErrorContains(t, err, "must contain a placeholder")
+}
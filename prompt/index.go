@@ -0,0 +1,237 @@
+package prompt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/notzree/rprompt/v2/prompt/core"
+	"github.com/notzree/rprompt/v2/utils"
+)
+
+// IndexFile is the name of the index a LocalPromptRegistry reads from its directory, written by WriteIndex
+const IndexFile = ".rprompt-index.json"
+
+// indexVersion is bumped whenever the index format changes, so older indexes are ignored
+const indexVersion = 3
+
+// RegistryIndex is a snapshot of a registry's templates, so listing templates and walking the dependency graph
+// don't need to read and parse every file
+type RegistryIndex struct {
+	Version   int          `json:"version"`
+	Templates []IndexEntry `json:"templates"`
+}
+
+// IndexEntry describes one template in a RegistryIndex
+type IndexEntry struct {
+	Path string `json:"path"`
+	// Hash is the hex-encoded sha256 of the template's content
+	Hash string `json:"hash"`
+	// Size and ModTime tell whether the file changed since it was indexed
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	// Dependencies are the registry paths of the templates it includes directly, sorted
+	Dependencies []string `json:"dependencies"`
+	// ID, Deprecation, Deprecated and Owners are copied from the template's front matter
+	ID          string               `json:"id,omitempty"`
+	Deprecation *TemplateDeprecation `json:"deprecation,omitempty"`
+	Deprecated  map[string]string    `json:"deprecated,omitempty"`
+	Owners      []string             `json:"owners,omitempty"`
+	// Error is why the template's front matter or body couldn't be parsed, if it couldn't
+	Error string `json:"error,omitempty"`
+}
+
+// fresh reports whether the entry still describes the file info
+func (e *IndexEntry) fresh(info fs.FileInfo) bool {
+	return e.Size == info.Size() && e.ModTime.Equal(info.ModTime())
+}
+
+// WriteIndex reads and parses every template in the registry and writes the result to IndexFile. From then on
+// List answers from the index, so it should be rewritten after templates are added or removed other than
+// through SaveTemplate.
+func (r *LocalPromptRegistry) WriteIndex(ctx context.Context) (*RegistryIndex, error) {
+	paths, err := r.listFiles(core.RegistryExtensions(r)...)
+	if err != nil {
+		return nil, err
+	}
+	index := &RegistryIndex{Version: indexVersion, Templates: make([]IndexEntry, 0, len(paths))}
+	for _, path := range paths {
+		entry, err := r.indexEntry(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		index.Templates = append(index.Templates, *entry)
+	}
+	if err := r.saveIndex(index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// Index returns the registry's index, or nil if it has none
+func (r *LocalPromptRegistry) Index() (*RegistryIndex, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.loadIndex()
+}
+
+// loadIndex reads IndexFile the first time it is needed. r.mu must be held.
+func (r *LocalPromptRegistry) loadIndex() (*RegistryIndex, error) {
+	if r.indexLoaded {
+		return r.index, nil
+	}
+	data, err := os.ReadFile(filepath.Join(r.Directory, IndexFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		r.indexLoaded = true
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	index := &RegistryIndex{}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("invalid index %s: %w", IndexFile, err)
+	}
+	r.indexLoaded = true
+	if index.Version == indexVersion {
+		r.index = index
+	}
+	return r.index, nil
+}
+
+// saveIndex writes index to IndexFile and uses it from then on
+func (r *LocalPromptRegistry) saveIndex(index *RegistryIndex) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(r.Directory, IndexFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.index = index
+	r.indexLoaded = true
+	return nil
+}
+
+// updateIndex refreshes the index entry of a template written through the registry, if there is an index
+func (r *LocalPromptRegistry) updateIndex(ctx context.Context, path string) error {
+	r.mu.Lock()
+	index, err := r.loadIndex()
+	r.mu.Unlock()
+	if err != nil || index == nil {
+		return err
+	}
+	entry, err := r.indexEntry(ctx, path)
+	if err != nil {
+		return err
+	}
+	updated := &RegistryIndex{Version: index.Version}
+	for _, existing := range index.Templates {
+		if existing.Path != path {
+			updated.Templates = append(updated.Templates, existing)
+		}
+	}
+	updated.Templates = append(updated.Templates, *entry)
+	sort.Slice(updated.Templates, func(i, j int) bool { return updated.Templates[i].Path < updated.Templates[j].Path })
+	return r.saveIndex(updated)
+}
+
+// indexEntry reads and parses the template at path. Templates that fail to parse get an entry with Error set.
+func (r *LocalPromptRegistry) indexEntry(ctx context.Context, path string) (*IndexEntry, error) {
+	info, err := os.Stat(filepath.Join(r.Directory, path))
+	if err != nil {
+		return nil, err
+	}
+	template, err := r.Find(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(template.OriginalContent))
+	entry := &IndexEntry{
+		Path:         path,
+		Hash:         hex.EncodeToString(sum[:]),
+		Size:         info.Size(),
+		ModTime:      info.ModTime(),
+		Dependencies: []string{},
+	}
+	fm, err := template.FrontMatter()
+	if err != nil {
+		entry.Error = err.Error()
+		return entry, nil
+	}
+	entry.ID = fm.ID
+	entry.Deprecation = fm.Deprecation
+	entry.Deprecated = fm.Deprecated
+	entry.Owners = fm.Owners
+	if err := template.Compile(); err != nil {
+		entry.Error = err.Error()
+		return entry, nil
+	}
+	entry.Dependencies = utils.UniqueString(mapStrings(template.FileDependencies(), r.dependencyPath))
+	sort.Strings(entry.Dependencies)
+	return entry, nil
+}
+
+// entries describes every template in the registry, from the index where it is still fresh and by reading and
+// parsing the template otherwise
+func (r *LocalPromptRegistry) entries(ctx context.Context) ([]IndexEntry, error) {
+	index, err := r.Index()
+	if err != nil {
+		return nil, err
+	}
+	indexed := make(map[string]*IndexEntry)
+	if index != nil {
+		for i := range index.Templates {
+			indexed[index.Templates[i].Path] = &index.Templates[i]
+		}
+	}
+	paths, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]IndexEntry, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(filepath.Join(r.Directory, path))
+		if errors.Is(err, fs.ErrNotExist) {
+			// Removed since the index was written
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entry, ok := indexed[path]; ok && entry.fresh(info) {
+			entries = append(entries, *entry)
+			continue
+		}
+		entry, err := r.indexEntry(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, nil
+}
+
+// Graph returns the registry paths each template includes directly, keyed by template path. It answers from
+// the index for templates that haven't changed since it was written.
+func (r *LocalPromptRegistry) Graph(ctx context.Context) (map[string][]string, error) {
+	entries, err := r.entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	graph := make(map[string][]string, len(entries))
+	for _, entry := range entries {
+		graph[entry.Path] = entry.Dependencies
+	}
+	return graph, nil
+}
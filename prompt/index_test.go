@@ -0,0 +1,75 @@
+package prompt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalPromptRegistry_WriteIndex(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "header" .]] [[template "header.tmpl" .]] Hi`)
+	createTestFile(t, tempDir, "header.tmpl", "---\ndeprecation:\n  replacement: title.tmpl\n---\nHeader")
+	createTestFile(t, tempDir, "broken.tmpl", `[[if]]`)
+	ctx := context.Background()
+
+	index, err := NewInMemPromptRegistry(tempDir).WriteIndex(ctx)
+	require.NoError(t, err)
+	require.Len(t, index.Templates, 3)
+	assert.Equal(t, "broken.tmpl", index.Templates[0].Path)
+	assert.NotEmpty(t, index.Templates[0].Error)
+	assert.Equal(t, &TemplateDeprecation{Replacement: "title.tmpl"}, index.Templates[1].Deprecation)
+	assert.Equal(t, []string{"header.tmpl"}, index.Templates[2].Dependencies)
+	assert.Len(t, index.Templates[2].Hash, 64)
+
+	// A new registry over the same directory reads the index
+	registry := NewInMemPromptRegistry(tempDir)
+	loaded, err := registry.Index()
+	require.NoError(t, err)
+	assert.Equal(t, len(index.Templates), len(loaded.Templates))
+
+	// Templates added behind the index's back aren't listed until it is rewritten
+	createTestFile(t, tempDir, "new.tmpl", `New`)
+	paths, err := registry.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"broken.tmpl", "header.tmpl", "main.tmpl"}, paths)
+
+	// Templates saved through the registry are
+	require.NoError(t, registry.SaveTemplate("saved.tmpl", `[[template "main.tmpl" .]]`))
+	paths, err = registry.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"broken.tmpl", "header.tmpl", "main.tmpl", "saved.tmpl"}, paths)
+}
+
+func TestLocalPromptRegistry_Graph(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "header.tmpl" .]]`)
+	createTestFile(t, tempDir, "header.tmpl", `Header`)
+	registry := NewInMemPromptRegistry(tempDir)
+	ctx := context.Background()
+
+	graph, err := registry.Graph(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"header.tmpl": {}, "main.tmpl": {"header.tmpl"}}, graph)
+
+	_, err = registry.WriteIndex(ctx)
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(tempDir, IndexFile))
+	require.NoError(t, err)
+
+	// Changed templates are read again instead of trusting the index
+	createTestFile(t, tempDir, "header.tmpl", `[[template "title.tmpl" .]] and more`)
+	graph, err = registry.Graph(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"title.tmpl"}, graph["header.tmpl"])
+
+	// Removed templates are skipped
+	require.NoError(t, os.Remove(filepath.Join(tempDir, "main.tmpl")))
+	graph, err = registry.Graph(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, graph, "main.tmpl")
+}
@@ -0,0 +1,32 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApp_Audit(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[role "user"]]<input>[[.input]]</input>`)
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	ctx := context.Background()
+
+	require.NoError(t, app.Command().Run(ctx, []string{"rprompt", "audit", "--fail"}))
+	assert.Contains(t, stdout.String(), "No prompt injection risks found")
+
+	createTestFile(t, tempDir, "main.tmpl", `[[role "system"]]Reply to [[.input]]`)
+	stdout.Reset()
+	require.NoError(t, app.Command().Run(ctx, []string{"rprompt", "audit"}))
+	assert.Contains(t, stdout.String(), "main.tmpl:1:29: high system-interpolation: .input is user-controlled")
+
+	stdout.Reset()
+	err := app.Command().Run(ctx, []string{"rprompt", "--json", "audit", "--fail"})
+	assert.EqualError(t, err, "2 prompt injection risks found")
+	var report InjectionReport
+	require.NoError(t, json.NewDecoder(stdout).Decode(&report))
+	assert.Len(t, report.Findings, 2)
+}
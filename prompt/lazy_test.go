@@ -0,0 +1,97 @@
+package prompt
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/notzree/rprompt/v2/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRegistry records the templates found through it
+type countingRegistry struct {
+	*LocalPromptRegistry
+	mu    sync.Mutex
+	found []string
+}
+
+func (r *countingRegistry) Find(ctx context.Context, path string) (*Template, error) {
+	r.mu.Lock()
+	r.found = append(r.found, path)
+	r.mu.Unlock()
+	template, err := r.LocalPromptRegistry.Find(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	// Includes are found through r too
+	return NewTemplate(template.Path, template.OriginalContent, r), nil
+}
+
+func TestWithLazyDeps(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[if .premium]][[template "premium.tmpl" .]][[else]][[template "basic.tmpl" .]][[end]]`+
+		`[[with .user]][[template "user.tmpl" .]][[end]][[if not .quiet]][[template "footer.tmpl" .]][[end]]`)
+	createTestFile(t, tempDir, "premium.tmpl", `Premium [[template "perks.tmpl" .]]`)
+	createTestFile(t, tempDir, "perks.tmpl", `perks`)
+	createTestFile(t, tempDir, "basic.tmpl", `Basic[[if .ads]] [[template "ads.tmpl" .]][[end]]`)
+	createTestFile(t, tempDir, "ads.tmpl", `ads`)
+	createTestFile(t, tempDir, "user.tmpl", ` [[.name]]`)
+	createTestFile(t, tempDir, "footer.tmpl", ` Footer`)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		data   map[string]any
+		output string
+		found  []string
+	}{
+		{"premium", map[string]any{"premium": true, "quiet": true}, "Premium perks", []string{"main.tmpl", "premium.tmpl", "perks.tmpl"}},
+		{"basic", map[string]any{"premium": false, "ads": true, "user": map[string]any{"name": "Ada"}}, "Basic ads Ada Footer",
+			[]string{"main.tmpl", "basic.tmpl", "ads.tmpl", "user.tmpl", "footer.tmpl"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := &countingRegistry{LocalPromptRegistry: NewInMemPromptRegistry(tempDir)}
+			system, err := NewPromptSystem(registry)
+			require.NoError(t, err)
+
+			result, err := system.BuildWithResult(ctx, "main.tmpl", "", WithData(tt.data), WithLazyDeps(), WithValidation(ValidationOff))
+			require.NoError(t, err)
+			assert.Equal(t, tt.output, result.Output)
+			assert.ElementsMatch(t, tt.found, utils.UniqueString(registry.found))
+		})
+	}
+}
+
+func TestWithLazyDeps_BuildTo(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello[[if .premium]][[template "premium.tmpl" .]][[end]]`)
+	createTestFile(t, tempDir, "premium.tmpl", ` premium`)
+	registry := &countingRegistry{LocalPromptRegistry: NewInMemPromptRegistry(tempDir)}
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+
+	var out strings.Builder
+	require.NoError(t, system.BuildTo(context.Background(), &out, "main.tmpl", "", WithData(map[string]any{"premium": false}), WithLazyDeps()))
+	assert.Equal(t, "Hello", out.String())
+	assert.Equal(t, []string{"main.tmpl"}, utils.UniqueString(registry.found), "streamed builds skip partials the config rules out")
+}
+
+func TestWithLazyDeps_UnknownConditions(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[if eq .tier "gold"]][[template "gold.tmpl" .]][[end]][[range .items]][[template "item.tmpl" .]][[end]]`)
+	createTestFile(t, tempDir, "gold.tmpl", `Gold`)
+	createTestFile(t, tempDir, "item.tmpl", `Item`)
+	registry := &countingRegistry{LocalPromptRegistry: NewInMemPromptRegistry(tempDir)}
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+
+	// eq can't be evaluated statically, so both branches count while the empty range is skipped
+	result, err := system.BuildWithResult(context.Background(), "main.tmpl", "", WithData(map[string]any{"tier": "gold", "items": []any{}}), WithLazyDeps())
+	require.NoError(t, err)
+	assert.Equal(t, "Gold", result.Output)
+	assert.ElementsMatch(t, []string{"main.tmpl", "gold.tmpl"}, utils.UniqueString(registry.found))
+}
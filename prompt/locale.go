@@ -0,0 +1,67 @@
+package prompt
+
+import (
+	"context"
+	"sort"
+
+	"github.com/notzree/rprompt/v2/prompt/core"
+	"github.com/notzree/rprompt/v2/utils"
+)
+
+// LocaleReport lists the locales a registry's templates are translated into and the translations missing
+type LocaleReport struct {
+	Locales []string             `json:"locales"`
+	Missing []MissingTranslation `json:"missing"`
+}
+
+// MissingTranslation is a template translated into some of the registry's locales but not all of them
+type MissingTranslation struct {
+	// Template is the path of the unsuffixed template, such as greeting.tmpl for greeting.fr.tmpl
+	Template string   `json:"template"`
+	Locales  []string `json:"locales"`
+}
+
+// FindMissingTranslations reports the templates missing a variant for any locale another template has a
+// variant for. Templates without any variant aren't reported, since they don't depend on the locale. An
+// unsuffixed template stands for defaultLocale, when it is given.
+func FindMissingTranslations(ctx context.Context, r *LocalPromptRegistry, defaultLocale string) (*LocaleReport, error) {
+	paths, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool, len(paths))
+	translated := make(map[string]map[string]bool)
+	var locales []string
+	for _, path := range paths {
+		exists[path] = true
+		template, locale, ok := core.LocaleVariant(path)
+		if !ok {
+			continue
+		}
+		if translated[template] == nil {
+			translated[template] = make(map[string]bool)
+		}
+		translated[template][locale] = true
+		locales = append(locales, locale)
+	}
+	locales = utils.UniqueString(locales)
+	sort.Strings(locales)
+
+	report := &LocaleReport{Locales: locales, Missing: []MissingTranslation{}}
+	for _, template := range sortedKeys(translated) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var missing []string
+		for _, locale := range locales {
+			if translated[template][locale] || locale == defaultLocale && exists[template] {
+				continue
+			}
+			missing = append(missing, locale)
+		}
+		if len(missing) > 0 {
+			report.Missing = append(report.Missing, MissingTranslation{Template: template, Locales: missing})
+		}
+	}
+	return report, nil
+}
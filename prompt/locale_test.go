@@ -0,0 +1,100 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptSystem_WithLocale(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "greeting.tmpl", `[[template "header.tmpl" .]] Hello [[.name]] [[template "footer"]]`)
+	createTestFile(t, tempDir, "greeting.fr.tmpl", `[[template "header.tmpl" .]] Bonjour [[.name]] [[template "footer"]]`)
+	createTestFile(t, tempDir, "header.tmpl", `Header`)
+	createTestFile(t, tempDir, "header.fr.tmpl", `En-tête`)
+	createTestFile(t, tempDir, "header.de.tmpl", `Kopfzeile`)
+	createTestFile(t, tempDir, "footer.tmpl", `Bye`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+	build := func(opts ...BuildOption) string {
+		t.Helper()
+		result, err := system.BuildWithResult(ctx, "greeting.tmpl", "", append(opts, WithValue("name", "Ana"))...)
+		require.NoError(t, err)
+		return result.Output
+	}
+
+	assert.Equal(t, "Header Hello Ana Bye", build())
+	assert.Equal(t, "En-tête Bonjour Ana Bye", build(WithLocale("fr")))
+	assert.Equal(t, "En-tête Bonjour Ana Bye", build(WithLocale("fr-CA")), "regions fall back to their language")
+	assert.Equal(t, "Kopfzeile Hello Ana Bye", build(WithLocale("de")), "only the header is translated")
+	assert.Equal(t, "Header Hello Ana Bye", build(WithLocale("es")))
+
+	system.DefaultLocale = "fr"
+	assert.Equal(t, "Kopfzeile Bonjour Ana Bye", build(WithLocale("de")), "the default locale comes before the unsuffixed template")
+
+	result, err := system.BuildWithResult(ctx, "greeting.tmpl", "", WithLocale("fr"), WithValue("name", "Ana"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"footer.tmpl", "header.fr.tmpl"}, result.Dependencies)
+
+	_, err = system.Build(ctx, "greeting.tmpl", "", WithLocale("French"))
+	assert.ErrorContains(t, err, `invalid locale "French"`)
+}
+
+func TestFindMissingTranslations(t *testing.T) {
+	tempDir := setupTempDir(t)
+	for _, name := range []string{"greeting.tmpl", "greeting.fr.tmpl", "greeting.de.tmpl", "farewell.tmpl", "farewell.fr.tmpl", "neutral.tmpl", "only.en.tmpl"} {
+		createTestFile(t, tempDir, name, "x")
+	}
+	registry := NewInMemPromptRegistry(tempDir)
+	ctx := context.Background()
+
+	report, err := FindMissingTranslations(ctx, registry, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"de", "en", "fr"}, report.Locales)
+	assert.Equal(t, []MissingTranslation{
+		{Template: "farewell.tmpl", Locales: []string{"de", "en"}},
+		{Template: "greeting.tmpl", Locales: []string{"en"}},
+		{Template: "only.tmpl", Locales: []string{"de", "fr"}},
+	}, report.Missing)
+
+	report, err = FindMissingTranslations(ctx, registry, "en")
+	require.NoError(t, err)
+	assert.Equal(t, []MissingTranslation{
+		{Template: "farewell.tmpl", Locales: []string{"de"}},
+		{Template: "only.tmpl", Locales: []string{"de", "fr"}},
+	}, report.Missing)
+}
+
+func TestApp_Locales(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "greeting.tmpl", "Hello")
+	createTestFile(t, tempDir, "greeting.fr.tmpl", "Bonjour")
+	createTestFile(t, tempDir, "farewell.tmpl", "Bye")
+	createTestFile(t, tempDir, "farewell.de.tmpl", "Tschüss")
+	createTestFile(t, tempDir, "empty.json", "{}")
+	outPath := filepath.Join(t.TempDir(), "out.txt")
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	ctx := context.Background()
+	run := func(args ...string) error {
+		stdout.Reset()
+		return app.Command().Run(ctx, append([]string{"rprompt"}, args...))
+	}
+
+	require.NoError(t, run("generate", "-t", "greeting.tmpl", "-c", "empty.json", "--locale", "fr", "-o", outPath))
+	assert.Equal(t, "Bonjour", readTestFile(t, filepath.Dir(outPath), "out.txt"))
+
+	err := run("--json", "check", "--locales")
+	assert.ErrorContains(t, err, "2 templates missing translations")
+	var report struct {
+		Locales *LocaleReport `json:"locales"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	require.NotNil(t, report.Locales)
+	assert.Equal(t, []string{"de", "fr"}, report.Locales.Locales)
+	assert.Len(t, report.Locales.Missing, 2)
+}
@@ -0,0 +1,48 @@
+package prompt
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateError_Parse(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "Hello\n[[if .x]]unclosed")
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+
+	_, err = system.Build(context.Background(), "main.tmpl", "")
+	var templateErr *TemplateError
+	require.True(t, errors.As(err, &templateErr), "got %v", err)
+	assert.Equal(t, "main.tmpl", templateErr.Path)
+	assert.Equal(t, 2, templateErr.Line)
+	assert.Equal(t, 0, templateErr.Column)
+	assert.Equal(t, "2 | [[if .x]]unclosed", templateErr.Snippet)
+}
+
+func TestTemplateError_Execution(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "---\nresponse_schema: {type: string}\n---\nIntro\n[[template \"header.tmpl\" .]]")
+	createTestFile(t, tempDir, "header.tmpl", "Title\nHi [[index .names 5]]")
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+
+	_, err = system.Build(context.Background(), "main.tmpl", "", WithData(map[string]any{"names": []any{"a"}}))
+	var templateErr *TemplateError
+	require.True(t, errors.As(err, &templateErr), "got %v", err)
+	assert.Equal(t, "header.tmpl", templateErr.Path, "errors point into the dependency they occur in")
+	assert.Equal(t, 2, templateErr.Line)
+	assert.Equal(t, 6, templateErr.Column)
+	assert.Equal(t, "2 | Hi [[index .names 5]]\n  |      ^", templateErr.Snippet)
+	assert.Contains(t, err.Error(), "header.tmpl:2:6: ")
+}
+
+func TestTemplateError_Unwrap(t *testing.T) {
+	err := NewTemplateError("main.tmpl", 1, 0, "boom", "", errors.New("inner"))
+	assert.Equal(t, "main.tmpl:1: boom", err.Error())
+	assert.EqualError(t, errors.Unwrap(err), "inner")
+}
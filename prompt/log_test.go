@@ -0,0 +1,26 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptSystem_Logger(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "header.tmpl" .]][[.name]]`)
+	createTestFile(t, tempDir, "header.tmpl", `Hi [[.greeting]]`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	system.Logger = slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	_, err = system.Build(context.Background(), "main.tmpl", "", WithData(map[string]any{"name": "n", "greeting": "g"}))
+	require.NoError(t, err)
+	assert.Contains(t, logs.String(), `"msg":"found dependencies","template":"main.tmpl","dependencies":["header.tmpl"]`)
+	assert.Contains(t, logs.String(), `"msg":"walking included template"`)
+}
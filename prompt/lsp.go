@@ -0,0 +1,522 @@
+package prompt
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/notzree/rprompt/v2/prompt/core"
+	"github.com/notzree/rprompt/v2/utils"
+)
+
+// LSP completion item kinds and diagnostic severities
+const (
+	lspCompletionField = 5
+	lspSeverityError   = 1
+)
+
+// LanguageServer is a Language Server Protocol server for the templates in a registry, run by 'rprompt lsp'.
+// It reports the problems Template.Validate finds as diagnostics, describes config values on hover, jumps
+// from an include to the template or define it names and completes config keys after a dot.
+//
+// Open documents are checked as they are typed, while the templates they include are read from the registry.
+type LanguageServer struct {
+	registry *LocalPromptRegistry
+	// documents holds the text of every open document by URI
+	documents map[string]string
+	w         io.Writer
+}
+
+// NewLanguageServer creates a LanguageServer for the templates in registry
+func NewLanguageServer(registry *LocalPromptRegistry) *LanguageServer {
+	return &LanguageServer{registry: registry, documents: make(map[string]string)}
+}
+
+// LSPPosition is a 0-based line and character in a document
+type LSPPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// LSPRange is a span of a document, its end exclusive
+type LSPRange struct {
+	Start LSPPosition `json:"start"`
+	End   LSPPosition `json:"end"`
+}
+
+// LSPLocation is a span of a document named by its URI
+type LSPLocation struct {
+	URI   string   `json:"uri"`
+	Range LSPRange `json:"range"`
+}
+
+// LSPDiagnostic is a problem in a document
+type LSPDiagnostic struct {
+	Range    LSPRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+// LSPCompletionItem is a suggestion for the text being typed
+type LSPCompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// LSPHover is the Markdown shown when hovering over a config value
+type LSPHover struct {
+	Contents LSPMarkupContent `json:"contents"`
+	Range    *LSPRange        `json:"range,omitempty"`
+}
+
+// LSPMarkupContent is text in a format such as Markdown
+type LSPMarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// lspTextDocumentPosition are the params of requests about a position in a document
+type lspTextDocumentPosition struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position LSPPosition `json:"position"`
+}
+
+// Serve reads JSON-RPC messages framed by Content-Length headers from r and writes responses and diagnostics
+// to w, as in LSP's stdio transport, until the client sends exit, r is exhausted or ctx is cancelled
+func (s *LanguageServer) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.w = w
+	reader := bufio.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		body, err := readLSPMessage(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req rpcRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := s.send(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+		result, err := s.handle(ctx, req)
+		// Notifications such as textDocument/didOpen get no response
+		if len(req.ID) == 0 {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		resp := rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result}
+		if err != nil {
+			var rpcErr *rpcError
+			if !errors.As(err, &rpcErr) {
+				rpcErr = &rpcError{Code: rpcInternalError, Message: err.Error()}
+			}
+			resp.Error = rpcErr
+		}
+		if err := s.send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// readLSPMessage reads the headers of a message and returns its body
+func readLSPMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) && line == "" && length < 0 {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("failed to read message header: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("message without a Content-Length header")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("failed to read message body: %w", err)
+	}
+	return body, nil
+}
+
+// send writes a message framed by its Content-Length header
+func (s *LanguageServer) send(message any) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	return err
+}
+
+func (s *LanguageServer) handle(ctx context.Context, req rpcRequest) (any, error) {
+	switch req.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				// Full document sync
+				"textDocumentSync":   map[string]any{"openClose": true, "change": 1},
+				"hoverProvider":      true,
+				"definitionProvider": true,
+				"completionProvider": map[string]any{"triggerCharacters": []string{"."}},
+			},
+			"serverInfo": map[string]any{"name": "rprompt", "version": buildVersion()},
+		}, nil
+	case "initialized", "$/cancelRequest", "textDocument/didSave":
+		return nil, nil
+	case "shutdown":
+		return json.RawMessage("null"), nil
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		s.documents[params.TextDocument.URI] = params.TextDocument.Text
+		return nil, s.publishDiagnostics(ctx, params.TextDocument.URI)
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.documents[params.TextDocument.URI] = params.ContentChanges[n-1].Text
+		}
+		return nil, s.publishDiagnostics(ctx, params.TextDocument.URI)
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		// Clear the document's diagnostics
+		return nil, s.send(map[string]any{
+			"jsonrpc": "2.0",
+			"method":  "textDocument/publishDiagnostics",
+			"params":  map[string]any{"uri": params.TextDocument.URI, "diagnostics": []LSPDiagnostic{}},
+		})
+	case "textDocument/hover":
+		var params lspTextDocumentPosition
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.Hover(ctx, params.TextDocument.URI, params.Position)
+	case "textDocument/definition":
+		var params lspTextDocumentPosition
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.Definition(params.TextDocument.URI, params.Position)
+	case "textDocument/completion":
+		var params lspTextDocumentPosition
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.Completion(ctx, params.TextDocument.URI, params.Position)
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+}
+
+// document returns the template of an open document, named by its path in the registry
+func (s *LanguageServer) document(uri string) (*Template, error) {
+	text, ok := s.documents[uri]
+	if !ok {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("document %s is not open", uri)}
+	}
+	path, err := uriPath(uri)
+	if err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	rel, ok := s.registry.RelativePath(path)
+	if !ok {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("%s is outside the registry", path)}
+	}
+	return NewTemplate(rel, text, s.registry), nil
+}
+
+// Diagnostics returns the problems Template.Validate finds in an open document
+func (s *LanguageServer) Diagnostics(ctx context.Context, uri string) ([]LSPDiagnostic, error) {
+	template, err := s.document(uri)
+	if err != nil {
+		return nil, err
+	}
+	problems, err := template.Validate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	diagnostics := []LSPDiagnostic{}
+	for _, problem := range problems {
+		start := LSPPosition{Line: max(problem.Line-1, 0), Character: max(problem.Column-1, 0)}
+		end := start
+		if problem.Column == 0 {
+			// Parse errors only have a line, so mark all of it
+			end.Character = utf8.RuneCountInString(core.LineAt(template.OriginalContent, problem.Line))
+		} else {
+			end.Character++
+		}
+		diagnostics = append(diagnostics, LSPDiagnostic{
+			Range:    LSPRange{Start: start, End: end},
+			Severity: lspSeverityError,
+			Source:   "rprompt",
+			Message:  problem.Message,
+		})
+	}
+	return diagnostics, nil
+}
+
+// publishDiagnostics sends the diagnostics of an open document to the client
+func (s *LanguageServer) publishDiagnostics(ctx context.Context, uri string) error {
+	diagnostics, err := s.Diagnostics(ctx, uri)
+	var rpcErr *rpcError
+	if errors.As(err, &rpcErr) {
+		// Documents outside the registry aren't rprompt templates
+		return nil
+	}
+	if err != nil {
+		diagnostics = []LSPDiagnostic{{Severity: lspSeverityError, Source: "rprompt", Message: err.Error()}}
+	}
+	return s.send(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  "textDocument/publishDiagnostics",
+		"params":  map[string]any{"uri": uri, "diagnostics": diagnostics},
+	})
+}
+
+// referencePattern matches a config value referred to in an action, such as .user.name or $.user.name
+var referencePattern = regexp.MustCompile(`\$?[\p{L}\p{N}_]*(?:\.[\p{L}\p{N}_]+)+`)
+
+// Hover describes the config value under the cursor: its path, type, whether it is only used conditionally,
+// any deprecation note and where the template uses it. It returns nil when the cursor isn't on one.
+func (s *LanguageServer) Hover(ctx context.Context, uri string, position LSPPosition) (*LSPHover, error) {
+	template, err := s.document(uri)
+	if err != nil {
+		return nil, err
+	}
+	line := core.LineAt(template.OriginalContent, position.Line+1)
+	start, end, ok := wordAt(line, position.Character, referencePattern)
+	if !ok {
+		return nil, nil
+	}
+	variables, err := template.Variables(ctx)
+	if err != nil {
+		// A template that doesn't resolve has nothing to describe yet
+		return nil, nil
+	}
+	for _, variable := range variables {
+		for _, use := range variable.Uses {
+			if use.Template != template.Path || use.Line != position.Line+1 || use.Column-1 != start {
+				continue
+			}
+			var b strings.Builder
+			fmt.Fprintf(&b, "**%s** `%s`", variable.Path, variable.Type)
+			if variable.Conditional {
+				b.WriteString(", only used conditionally")
+			}
+			if fm, err := template.FrontMatter(); err == nil && fm.Deprecated[variable.Path] != "" {
+				fmt.Fprintf(&b, "\n\nDeprecated: %s", fm.Deprecated[variable.Path])
+			}
+			b.WriteString("\n\nUsed in:")
+			for _, use := range variable.Uses {
+				fmt.Fprintf(&b, "\n- %s:%d:%d", use.Template, use.Line, use.Column)
+			}
+			return &LSPHover{
+				Contents: LSPMarkupContent{Kind: "markdown", Value: b.String()},
+				Range:    &LSPRange{Start: LSPPosition{Line: position.Line, Character: start}, End: LSPPosition{Line: position.Line, Character: end}},
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// includeNamePattern matches the quoted name of a template or block action
+var includeNamePattern = regexp.MustCompile("\\b(?:template|block)\\s+(\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`)")
+
+// Definition returns where the template or define named by the include under the cursor is: the define in
+// the same document, or else the file in the registry. It returns nil when the cursor isn't on an include or
+// nothing has the name.
+func (s *LanguageServer) Definition(uri string, position LSPPosition) (*LSPLocation, error) {
+	template, err := s.document(uri)
+	if err != nil {
+		return nil, err
+	}
+	line := core.LineAt(template.OriginalContent, position.Line+1)
+	var name string
+	for _, m := range includeNamePattern.FindAllStringSubmatchIndex(line, -1) {
+		start, end := utf8.RuneCountInString(line[:m[2]]), utf8.RuneCountInString(line[:m[3]])
+		if position.Character >= start && position.Character < end {
+			if name, err = strconv.Unquote(line[m[2]:m[3]]); err != nil {
+				return nil, nil
+			}
+		}
+	}
+	if name == "" {
+		return nil, nil
+	}
+
+	definePattern := regexp.MustCompile(`\b(?:define|block)\s+` + regexp.QuoteMeta(strconv.Quote(name)))
+	for i, text := range strings.Split(template.OriginalContent, "\n") {
+		if loc := definePattern.FindStringIndex(text); loc != nil {
+			at := LSPPosition{Line: i, Character: utf8.RuneCountInString(text[:loc[0]])}
+			return &LSPLocation{URI: uri, Range: LSPRange{Start: at, End: at}}, nil
+		}
+	}
+	for _, candidate := range core.DependencyCandidates(name, core.RegistryExtensions(s.registry)) {
+		rel, err := s.registry.sandboxed(candidate)
+		if err != nil {
+			continue
+		}
+		path, err := filepath.Abs(s.registry.fullPath(rel))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(path); err == nil {
+			return &LSPLocation{URI: pathURI(path)}, nil
+		}
+	}
+	return nil, nil
+}
+
+// completionPrefixPattern matches the reference being typed at the end of the text before the cursor
+var completionPrefixPattern = regexp.MustCompile(`\$?((?:\.[\p{L}\p{N}_]+)*)\.([\p{L}\p{N}_]*)$`)
+
+// Completion suggests the config keys that can follow the reference being typed, such as name and email
+// after .user., from the variables the template uses and the config sharing its stem
+func (s *LanguageServer) Completion(ctx context.Context, uri string, position LSPPosition) ([]LSPCompletionItem, error) {
+	template, err := s.document(uri)
+	if err != nil {
+		return nil, err
+	}
+	line := []rune(core.LineAt(template.OriginalContent, position.Line+1))
+	before := string(line[:min(position.Character, len(line))])
+	m := completionPrefixPattern.FindStringSubmatch(before)
+	if m == nil {
+		return []LSPCompletionItem{}, nil
+	}
+	parent := strings.TrimPrefix(m[1], ".")
+
+	keys := make(map[string]string)
+	if variables, err := template.Variables(ctx); err == nil {
+		for _, variable := range variables {
+			if key, ok := childKey(variable.Path, parent); ok {
+				if _, seen := keys[key]; !seen || variable.Path == strings.TrimPrefix(parent+"."+key, ".") {
+					keys[key] = variable.Type
+				}
+			}
+		}
+	}
+	stem := core.TrimTemplateExtension(template.Path, core.RegistryExtensions(s.registry))
+	cfg, err := s.registry.LoadConfig(ctx, stem+".json")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if cfg != nil {
+		for _, path := range utils.FlattenKeys(cfg.Config) {
+			if key, ok := childKey(path, parent); ok {
+				if _, seen := keys[key]; !seen {
+					keys[key] = "config key"
+				}
+			}
+		}
+	}
+
+	items := make([]LSPCompletionItem, 0, len(keys))
+	for key, detail := range keys {
+		if strings.HasPrefix(key, m[2]) {
+			items = append(items, LSPCompletionItem{Label: key, Kind: lspCompletionField, Detail: detail})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items, nil
+}
+
+// childKey returns the key following parent in a dotted path, such as name for user.name under user
+func childKey(path, parent string) (string, bool) {
+	if parent != "" {
+		rest, ok := strings.CutPrefix(path, parent+".")
+		if !ok {
+			return "", false
+		}
+		path = rest
+	}
+	key, _, _ := strings.Cut(path, ".")
+	return key, key != "" && !strings.HasSuffix(key, "[]")
+}
+
+// wordAt returns the rune offsets of the match of pattern in line that holds the character at offset
+func wordAt(line string, offset int, pattern *regexp.Regexp) (int, int, bool) {
+	for _, loc := range pattern.FindAllStringIndex(line, -1) {
+		start, end := utf8.RuneCountInString(line[:loc[0]]), utf8.RuneCountInString(line[:loc[1]])
+		if offset >= start && offset < end {
+			return start, end, true
+		}
+	}
+	return 0, 0, false
+}
+
+// uriPath returns the file path of a file:// URI
+func uriPath(uri string) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" {
+		return "", fmt.Errorf("unsupported document URI %s", uri)
+	}
+	return filepath.FromSlash(parsed.Path), nil
+}
+
+// pathURI returns the file:// URI of an absolute file path
+func pathURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
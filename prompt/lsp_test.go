@@ -0,0 +1,192 @@
+package prompt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveLSP(t *testing.T, registry *LocalPromptRegistry, requests ...string) []map[string]any {
+	var in bytes.Buffer
+	for _, req := range requests {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(req), req)
+	}
+	var out bytes.Buffer
+	require.NoError(t, NewLanguageServer(registry).Serve(context.Background(), &in, &out))
+
+	var messages []map[string]any
+	reader := bufio.NewReader(&out)
+	for reader.Buffered() > 0 || out.Len() > 0 {
+		body, err := readLSPMessage(reader)
+		require.NoError(t, err)
+		var message map[string]any
+		require.NoError(t, json.Unmarshal(body, &message))
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func setupLSPRegistry(t *testing.T) (*LocalPromptRegistry, string) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "header.tmpl", `# Header`)
+	createTestFile(t, tempDir, "main.json", `{"user": {"name": "", "role": ""}}`)
+	return NewInMemPromptRegistry(tempDir), pathURI(filepath.Join(tempDir, "main.tmpl"))
+}
+
+const lspTemplate = `---
+deprecated:
+  user.email: use user.contact
+---
+[[define "row"]]- [[.user.name]][[end]]
+Hello [[.user.name]] [[template "header"]]
+[[if .show]][[.user.email]][[end]][[template "row" .]]`
+
+func lspRequest(id int, method string, params any) string {
+	data, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	return string(data)
+}
+
+func lspNotification(method string, params any) string {
+	data, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+	return string(data)
+}
+
+func lspPosition(uri string, line, character int) map[string]any {
+	return map[string]any{"textDocument": map[string]any{"uri": uri}, "position": map[string]any{"line": line, "character": character}}
+}
+
+func TestLanguageServer_Lifecycle(t *testing.T) {
+	registry, _ := setupLSPRegistry(t)
+	messages := serveLSP(t, registry,
+		lspRequest(1, "initialize", map[string]any{"capabilities": map[string]any{}}),
+		lspNotification("initialized", map[string]any{}),
+		lspRequest(2, "textDocument/formatting", map[string]any{}),
+		lspRequest(3, "shutdown", nil),
+		lspNotification("exit", nil),
+		lspRequest(4, "shutdown", nil),
+	)
+	require.Len(t, messages, 3, "nothing is read after exit")
+
+	capabilities := messages[0]["result"].(map[string]any)["capabilities"].(map[string]any)
+	assert.Equal(t, true, capabilities["hoverProvider"])
+	assert.Equal(t, true, capabilities["definitionProvider"])
+	assert.Equal(t, []any{"."}, capabilities["completionProvider"].(map[string]any)["triggerCharacters"])
+	assert.Equal(t, float64(rpcMethodNotFound), messages[1]["error"].(map[string]any)["code"])
+	assert.Contains(t, messages[2], "result")
+}
+
+func TestLanguageServer_Diagnostics(t *testing.T) {
+	registry, uri := setupLSPRegistry(t)
+	messages := serveLSP(t, registry,
+		lspNotification("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": uri, "languageId": "rprompt", "version": 1, "text": lspTemplate + " [[upper .x]]"}}),
+		lspNotification("textDocument/didChange", map[string]any{"textDocument": map[string]any{"uri": uri, "version": 2}, "contentChanges": []any{map[string]any{"text": "Hi [[.name]]"}}}),
+		lspNotification("textDocument/didChange", map[string]any{"textDocument": map[string]any{"uri": uri, "version": 3}, "contentChanges": []any{map[string]any{"text": "Hi\n[[if .name]]"}}}),
+		lspNotification("textDocument/didClose", map[string]any{"textDocument": map[string]any{"uri": uri}}),
+		lspNotification("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": "file:///elsewhere/main.tmpl", "text": "[[upper .x]]"}}),
+	)
+	require.Len(t, messages, 4, "documents outside the registry get no diagnostics")
+	for _, message := range messages {
+		assert.Equal(t, "textDocument/publishDiagnostics", message["method"])
+		assert.Equal(t, uri, message["params"].(map[string]any)["uri"])
+	}
+
+	diagnostics := messages[0]["params"].(map[string]any)["diagnostics"].([]any)
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, map[string]any{
+		"range":    map[string]any{"start": map[string]any{"line": float64(6), "character": float64(57)}, "end": map[string]any{"line": float64(6), "character": float64(58)}},
+		"severity": float64(lspSeverityError),
+		"source":   "rprompt",
+		"message":  `function "upper" not defined`,
+	}, diagnostics[0])
+
+	assert.Empty(t, messages[1]["params"].(map[string]any)["diagnostics"])
+	diagnostics = messages[2]["params"].(map[string]any)["diagnostics"].([]any)
+	require.Len(t, diagnostics, 1)
+	assert.Contains(t, diagnostics[0].(map[string]any)["message"], "unexpected EOF")
+	assert.Empty(t, messages[3]["params"].(map[string]any)["diagnostics"], "closing clears the diagnostics")
+}
+
+func TestLanguageServer_Hover(t *testing.T) {
+	registry, uri := setupLSPRegistry(t)
+	server := NewLanguageServer(registry)
+	server.documents[uri] = lspTemplate
+	ctx := context.Background()
+
+	hover, err := server.Hover(ctx, uri, LSPPosition{Line: 6, Character: 20})
+	require.NoError(t, err)
+	require.NotNil(t, hover)
+	assert.Equal(t, "markdown", hover.Contents.Kind)
+	assert.Equal(t, "**user.email** `string`, only used conditionally\n\nDeprecated: use user.contact\n\nUsed in:\n- main.tmpl:7:15", hover.Contents.Value)
+	assert.Equal(t, &LSPRange{Start: LSPPosition{Line: 6, Character: 14}, End: LSPPosition{Line: 6, Character: 25}}, hover.Range)
+
+	hover, err = server.Hover(ctx, uri, LSPPosition{Line: 5, Character: 9})
+	require.NoError(t, err)
+	require.NotNil(t, hover)
+	assert.True(t, strings.HasPrefix(hover.Contents.Value, "**user.name** `string`\n\nUsed in:\n"), hover.Contents.Value)
+
+	hover, err = server.Hover(ctx, uri, LSPPosition{Line: 5, Character: 2})
+	require.NoError(t, err)
+	assert.Nil(t, hover)
+
+	_, err = server.Hover(ctx, pathURI("/elsewhere/main.tmpl"), LSPPosition{})
+	assert.Error(t, err)
+}
+
+func TestLanguageServer_Definition(t *testing.T) {
+	registry, uri := setupLSPRegistry(t)
+	server := NewLanguageServer(registry)
+	server.documents[uri] = lspTemplate
+
+	location, err := server.Definition(uri, LSPPosition{Line: 5, Character: 34})
+	require.NoError(t, err)
+	require.NotNil(t, location)
+	assert.Equal(t, pathURI(filepath.Join(registry.Directory, "header.tmpl")), location.URI)
+
+	location, err = server.Definition(uri, LSPPosition{Line: 6, Character: 46})
+	require.NoError(t, err)
+	require.NotNil(t, location)
+	assert.Equal(t, LSPLocation{URI: uri, Range: LSPRange{Start: LSPPosition{Line: 4, Character: 2}, End: LSPPosition{Line: 4, Character: 2}}}, *location)
+
+	location, err = server.Definition(uri, LSPPosition{Line: 5, Character: 2})
+	require.NoError(t, err)
+	assert.Nil(t, location)
+
+	server.documents[uri] = `[[template "footer"]]`
+	location, err = server.Definition(uri, LSPPosition{Line: 0, Character: 13})
+	require.NoError(t, err)
+	assert.Nil(t, location, "templates missing from the registry have no definition")
+}
+
+func TestLanguageServer_Completion(t *testing.T) {
+	registry, uri := setupLSPRegistry(t)
+	messages := serveLSP(t, registry,
+		lspNotification("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": uri, "text": lspTemplate}}),
+		lspRequest(1, "textDocument/completion", lspPosition(uri, 5, 14)),
+		lspRequest(2, "textDocument/completion", lspPosition(uri, 5, 11)),
+		lspRequest(3, "textDocument/completion", lspPosition(uri, 5, 3)),
+	)
+	require.Len(t, messages, 4)
+
+	labels := func(message map[string]any) []string {
+		var labels []string
+		for _, item := range message["result"].([]any) {
+			labels = append(labels, item.(map[string]any)["label"].(string))
+		}
+		return labels
+	}
+	assert.Equal(t, []string{"email", "name", "role"}, labels(messages[1]))
+	assert.Equal(t, []string{"user"}, labels(messages[2]))
+	assert.Empty(t, labels(messages[3]))
+
+	items := messages[1]["result"].([]any)
+	assert.Equal(t, map[string]any{"label": "role", "kind": float64(lspCompletionField), "detail": "config key"}, items[2])
+	assert.Equal(t, "string", items[1].(map[string]any)["detail"])
+}
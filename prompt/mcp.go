@@ -0,0 +1,352 @@
+package prompt
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// MCPProtocolVersions are the Model Context Protocol versions MCPServer speaks, newest first
+var MCPProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+// MCPServer serves a registry's templates as Model Context Protocol prompts. Each template is a prompt named
+// by its path without the .tmpl extension, with an argument per top-level config key it uses. A config
+// sharing the template's stem (main.tmpl -> main.json) provides defaults, making its keys optional.
+type MCPServer struct {
+	registry *LocalPromptRegistry
+	system   *PromptSystem
+}
+
+// NewMCPServer creates an MCPServer for the templates in registry
+func NewMCPServer(registry *LocalPromptRegistry) (*MCPServer, error) {
+	system, err := NewPromptSystem(registry)
+	if err != nil {
+		return nil, err
+	}
+	return &MCPServer{registry: registry, system: system}, nil
+}
+
+// MCPPrompt describes a prompt in a prompts/list response
+type MCPPrompt struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Arguments   []MCPPromptArgument `json:"arguments"`
+}
+
+// MCPPromptArgument describes an argument a prompt accepts
+type MCPPromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+}
+
+// MCPPromptMessage is a message in a prompts/get response
+type MCPPromptMessage struct {
+	Role    string     `json:"role"`
+	Content MCPContent `json:"content"`
+}
+
+// MCPContent is the text content of a prompt message
+type MCPContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// Serve reads newline-delimited JSON-RPC messages from r and writes responses to w, as in MCP's stdio
+// transport, until r is exhausted or ctx is cancelled
+func (s *MCPServer) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	encoder := json.NewEncoder(w)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var req rpcRequest
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			if err := encoder.Encode(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+		// Notifications such as notifications/initialized get no response
+		if len(req.ID) == 0 {
+			continue
+		}
+
+		resp := rpcResponse{JSONRPC: "2.0", ID: req.ID}
+		var result any
+		var err error
+		if req.JSONRPC != "2.0" || req.Method == "" {
+			err = &rpcError{Code: rpcInvalidRequest, Message: "invalid JSON-RPC 2.0 request"}
+		} else {
+			result, err = s.handle(ctx, req)
+		}
+		if err != nil {
+			var rpcErr *rpcError
+			if !errors.As(err, &rpcErr) {
+				rpcErr = &rpcError{Code: rpcInternalError, Message: err.Error()}
+			}
+			resp.Error = rpcErr
+		} else {
+			resp.Result = result
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *MCPServer) handle(ctx context.Context, req rpcRequest) (any, error) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		version := MCPProtocolVersions[0]
+		for _, supported := range MCPProtocolVersions {
+			if supported == params.ProtocolVersion {
+				version = supported
+			}
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"prompts": map[string]any{"listChanged": false}},
+			"serverInfo":      map[string]any{"name": "rprompt", "version": buildVersion()},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "prompts/list":
+		prompts, err := s.ListPrompts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"prompts": prompts}, nil
+	case "prompts/get":
+		var params struct {
+			Name      string            `json:"name"`
+			Arguments map[string]string `json:"arguments"`
+		}
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		messages, err := s.GetPrompt(ctx, params.Name, params.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"description": "Rendered " + templatePathFor(params.Name), "messages": messages}, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+}
+
+// buildVersion is the version of the rprompt module in this binary
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+func decodeParams(raw json.RawMessage, v any) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// templatePathFor maps a prompt name back to its template path
+func templatePathFor(name string) string {
+	if strings.HasSuffix(name, ".tmpl") {
+		return name
+	}
+	return name + ".tmpl"
+}
+
+// ListPrompts describes every template in the registry as a prompt
+func (s *MCPServer) ListPrompts(ctx context.Context) ([]MCPPrompt, error) {
+	paths, err := s.registry.List()
+	if err != nil {
+		return nil, err
+	}
+	prompts := make([]MCPPrompt, 0, len(paths))
+	for _, path := range paths {
+		arguments, err := s.arguments(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("err reading arguments of %s: %w", path, err)
+		}
+		prompts = append(prompts, MCPPrompt{
+			Name:        strings.TrimSuffix(path, ".tmpl"),
+			Description: fmt.Sprintf("rprompt template %s", path),
+			Arguments:   arguments,
+		})
+	}
+	return prompts, nil
+}
+
+// jsonArgumentDescription marks arguments for config values with nested fields, which are passed as JSON
+const jsonArgumentDescription = "JSON object"
+
+// arguments derives a prompt's arguments from the config its template requires
+func (s *MCPServer) arguments(ctx context.Context, path string) ([]MCPPromptArgument, error) {
+	template, err := s.system.Find(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	required, err := template.GenerateConfig(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := s.defaults(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	arguments := []MCPPromptArgument{}
+	for key, value := range required.Config {
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+		argument := MCPPromptArgument{Name: key}
+		_, hasDefault := defaults[key]
+		argument.Required = !hasDefault
+		if _, isMap := value.(map[string]any); isMap {
+			argument.Description = jsonArgumentDescription
+		}
+		arguments = append(arguments, argument)
+	}
+	sort.Slice(arguments, func(i, j int) bool { return arguments[i].Name < arguments[j].Name })
+	return arguments, nil
+}
+
+// defaults loads the config sharing the template's stem, if there is one
+func (s *MCPServer) defaults(ctx context.Context, path string) (map[string]any, error) {
+	cfg, err := s.registry.LoadConfig(ctx, strings.TrimSuffix(path, ".tmpl")+".json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]any{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Config, nil
+}
+
+// GetPrompt renders the named prompt with arguments over its defaults. Arguments with nested fields are
+// parsed as JSON objects, and any argument holding a JSON array or object is parsed so it can be ranged over. The template's [[role]] sections become messages; system sections are sent as
+// user messages since MCP prompts have no system role.
+func (s *MCPServer) GetPrompt(ctx context.Context, name string, arguments map[string]string) ([]MCPPromptMessage, error) {
+	path := templatePathFor(name)
+	if _, err := s.system.Find(ctx, path); err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("unknown prompt: %s", name)}
+	}
+	expected, err := s.arguments(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]any{}
+	var missing []string
+	for _, argument := range expected {
+		value, ok := arguments[argument.Name]
+		if !ok {
+			if argument.Required {
+				missing = append(missing, argument.Name)
+			}
+			continue
+		}
+		if argument.Description == jsonArgumentDescription {
+			var parsed map[string]any
+			if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+				return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("argument %s must be a JSON object: %v", argument.Name, err)}
+			}
+			data[argument.Name] = parsed
+			continue
+		}
+		data[argument.Name] = parseArgument(value)
+	}
+	if len(missing) > 0 {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("missing arguments: %s", strings.Join(missing, ", "))}
+	}
+
+	defaults, err := s.defaults(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.system.BuildWithResult(ctx, path, "", WithData(defaults), WithData(data), WithMessages())
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []MCPPromptMessage{}
+	for _, message := range result.Messages {
+		role := message.Role
+		if role == RoleSystem {
+			role = RoleUser
+		}
+		messages = append(messages, MCPPromptMessage{Role: role, Content: MCPContent{Type: "text", Text: message.Content}})
+	}
+	return messages, nil
+}
+
+// parseArgument returns value parsed as JSON if it holds an array or object, otherwise value itself
+func parseArgument(value string) any {
+	trimmed := strings.TrimSpace(value)
+	if !strings.HasPrefix(trimmed, "[") && !strings.HasPrefix(trimmed, "{") {
+		return value
+	}
+	var parsed any
+	if err := json.Unmarshal([]byte(trimmed), &parsed); err != nil {
+		return value
+	}
+	return parsed
+}
@@ -0,0 +1,119 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveMCP(t *testing.T, registry *LocalPromptRegistry, requests ...string) []map[string]any {
+	server, err := NewMCPServer(registry)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, server.Serve(context.Background(), strings.NewReader(strings.Join(requests, "\n")), &out))
+
+	var responses []map[string]any
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var resp map[string]any
+		require.NoError(t, decoder.Decode(&resp))
+		responses = append(responses, resp)
+	}
+	return responses
+}
+
+func setupMCPRegistry(t *testing.T) *LocalPromptRegistry {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "review.tmpl", `[[role "system"]]You review [[.language]] code.[[role "user"]][[.code]] in[[range .files]] [[.]][[end]] by [[.author.name]]`)
+	createTestFile(t, tempDir, "review.json", `{"language": "Go"}`)
+	createTestFile(t, tempDir, "hello.tmpl", `Hello [[.name]]`)
+	return NewInMemPromptRegistry(tempDir)
+}
+
+func TestMCPServer_Initialize(t *testing.T) {
+	responses := serveMCP(t, setupMCPRegistry(t),
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"ping"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/list"}`,
+		`not json`,
+	)
+	require.Len(t, responses, 4)
+
+	result := responses[0]["result"].(map[string]any)
+	assert.Equal(t, "2024-11-05", result["protocolVersion"])
+	assert.Contains(t, result["capabilities"], "prompts")
+	assert.Equal(t, float64(2), responses[1]["id"])
+	assert.Equal(t, float64(rpcMethodNotFound), responses[2]["error"].(map[string]any)["code"])
+	assert.Equal(t, float64(rpcParseError), responses[3]["error"].(map[string]any)["code"])
+}
+
+func TestMCPServer_ListPrompts(t *testing.T) {
+	server, err := NewMCPServer(setupMCPRegistry(t))
+	require.NoError(t, err)
+
+	prompts, err := server.ListPrompts(context.Background())
+	require.NoError(t, err)
+	require.Len(t, prompts, 2)
+	assert.Equal(t, "hello", prompts[0].Name)
+	assert.Equal(t, []MCPPromptArgument{{Name: "name", Required: true}}, prompts[0].Arguments)
+	assert.Equal(t, "review", prompts[1].Name)
+	assert.Equal(t, []MCPPromptArgument{
+		{Name: "author", Description: jsonArgumentDescription, Required: true},
+		{Name: "code", Required: true},
+		{Name: "files", Required: true},
+		{Name: "language", Required: false},
+	}, prompts[1].Arguments)
+}
+
+func TestMCPServer_GetPrompt(t *testing.T) {
+	server, err := NewMCPServer(setupMCPRegistry(t))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	messages, err := server.GetPrompt(ctx, "review", map[string]string{"code": "x := 1", "files": `["a.go", "b.go"]`, "author": `{"name": "Ada"}`})
+	require.NoError(t, err)
+	assert.Equal(t, []MCPPromptMessage{
+		{Role: RoleUser, Content: MCPContent{Type: "text", Text: "You review Go code."}},
+		{Role: RoleUser, Content: MCPContent{Type: "text", Text: "x := 1 in a.go b.go by Ada"}},
+	}, messages)
+
+	messages, err = server.GetPrompt(ctx, "hello.tmpl", map[string]string{"name": "Ada"})
+	require.NoError(t, err)
+	assert.Equal(t, []MCPPromptMessage{{Role: RoleUser, Content: MCPContent{Type: "text", Text: "Hello Ada"}}}, messages)
+
+	_, err = server.GetPrompt(ctx, "review", map[string]string{"files": `[]`, "author": `{}`})
+	assert.ErrorContains(t, err, "missing arguments: code")
+	_, err = server.GetPrompt(ctx, "review", map[string]string{"code": "x", "files": `[]`, "author": "Ada"})
+	assert.ErrorContains(t, err, "must be a JSON object")
+	_, err = server.GetPrompt(ctx, "missing", nil)
+	assert.ErrorContains(t, err, "unknown prompt")
+}
+
+func TestMCPServer_GetPromptRequest(t *testing.T) {
+	responses := serveMCP(t, setupMCPRegistry(t),
+		`{"jsonrpc":"2.0","id":"a","method":"prompts/get","params":{"name":"hello","arguments":{"name":"Ada"}}}`,
+		`{"jsonrpc":"2.0","id":"b","method":"prompts/get","params":{"name":"nope"}}`,
+	)
+	require.Len(t, responses, 2)
+
+	messages := responses[0]["result"].(map[string]any)["messages"].([]any)
+	assert.Equal(t, "Hello Ada", messages[0].(map[string]any)["content"].(map[string]any)["text"])
+	assert.Equal(t, float64(rpcInvalidParams), responses[1]["error"].(map[string]any)["code"])
+}
+
+func TestMCPServer_PII(t *testing.T) {
+	registry := setupMCPRegistry(t)
+	registry.PII = PIIMask
+	server, err := NewMCPServer(registry)
+	require.NoError(t, err)
+
+	messages, err := server.GetPrompt(context.Background(), "hello", map[string]string{"name": "jane@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, []MCPPromptMessage{{Role: RoleUser, Content: MCPContent{Type: "text", Text: "Hello [EMAIL]"}}}, messages)
+}
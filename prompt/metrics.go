@@ -0,0 +1,89 @@
+package prompt
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus collectors a PromptSystem records builds to. A nil *Metrics records nothing,
+// so metrics are off unless PromptSystem.Metrics is set.
+type Metrics struct {
+	renders  *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	cache    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	tokens   *prometheus.HistogramVec
+}
+
+// NewMetrics creates the library's collectors and registers them with reg:
+//
+//	rprompt_renders_total{template}                  builds, successful or not
+//	rprompt_render_errors_total{type}                failed builds by error type, see ErrorType
+//	rprompt_parse_cache_lookups_total{result}        parse cache lookups, result is hit or miss
+//	rprompt_render_duration_seconds{template}        build latency
+//	rprompt_output_tokens{template}                  estimated tokens of built prompts
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		renders: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rprompt_renders_total",
+			Help: "Prompt builds, successful or not.",
+		}, []string{"template"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rprompt_render_errors_total",
+			Help: "Failed prompt builds by error type.",
+		}, []string{"type"}),
+		cache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rprompt_parse_cache_lookups_total",
+			Help: "Parse cache lookups by result.",
+		}, []string{"result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "rprompt_render_duration_seconds",
+			Help:    "Time taken to build a prompt.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"template"}),
+		tokens: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "rprompt_output_tokens",
+			Help:    "Estimated tokens of built prompts.",
+			Buckets: prometheus.ExponentialBuckets(64, 2, 12),
+		}, []string{"template"}),
+	}
+	for _, c := range []prometheus.Collector{m.renders, m.errors, m.cache, m.duration, m.tokens} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ObserveBuild records a finished build of templatePath that started at start, see core.MetricsRecorder
+func (m *Metrics) ObserveBuild(templatePath string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.renders.WithLabelValues(templatePath).Inc()
+	m.duration.WithLabelValues(templatePath).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(ErrorType(err)).Inc()
+	}
+}
+
+// ObserveTokens records the token count of a built prompt
+func (m *Metrics) ObserveTokens(templatePath string, tokens int) {
+	if m == nil {
+		return
+	}
+	m.tokens.WithLabelValues(templatePath).Observe(float64(tokens))
+}
+
+// ObserveCacheLookup records a parse cache hit or miss
+func (m *Metrics) ObserveCacheLookup(hit bool) {
+	if m == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cache.WithLabelValues(result).Inc()
+}
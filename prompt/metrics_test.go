@@ -0,0 +1,64 @@
+package prompt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_Builds(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "broken.tmpl", `[[.missing.field]]`)
+	registry := NewInMemPromptRegistry(tempDir)
+
+	reg := prometheus.NewRegistry()
+	metrics, err := NewMetrics(reg)
+	require.NoError(t, err)
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	system.Metrics = metrics
+	// Saving the templates drops them from the shared parse cache, so the first builds miss
+	require.NoError(t, registry.SaveTemplate("main.tmpl", `[[template "header.tmpl" .]] Hello [[.name]]`))
+	require.NoError(t, registry.SaveTemplate("header.tmpl", `Header`))
+	ctx := context.Background()
+
+	for range 2 {
+		_, err := system.BuildWithResult(ctx, "main.tmpl", "", WithValue("name", "John"))
+		require.NoError(t, err)
+	}
+	_, err = system.BuildWithResult(ctx, "broken.tmpl", "")
+	require.Error(t, err)
+	_, err = system.BuildWithResult(ctx, "absent.tmpl", "")
+	require.Error(t, err)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.renders.WithLabelValues("main.tmpl")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.errors.WithLabelValues(ErrorTypeMissing)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.errors.WithLabelValues(ErrorTypeNotFound)))
+	assert.Positive(t, testutil.ToFloat64(metrics.cache.WithLabelValues("hit")))
+	assert.Positive(t, testutil.ToFloat64(metrics.cache.WithLabelValues("miss")))
+	assert.Equal(t, 3, testutil.CollectAndCount(metrics.duration))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.tokens))
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+
+	output, err := system.Build(context.Background(), "main.tmpl", "")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", output)
+}
+
+func TestMetrics_RegisterTwice(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	_, err := NewMetrics(reg)
+	require.NoError(t, err)
+	_, err = NewMetrics(reg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,82 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMiddlewareTestSystem(t *testing.T) *PromptSystem {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello [[.name]], your card is [[.card]]`)
+	createTestFile(t, tempDir, "config.json", `{"name": "John", "card": "4111"}`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	return system
+}
+
+func TestPromptSystem_Use(t *testing.T) {
+	system := newMiddlewareTestSystem(t)
+
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next RenderFunc) RenderFunc {
+			return func(ctx context.Context, tmpl *Template, cfg *Config) (string, error) {
+				calls = append(calls, name+":before")
+				output, err := next(ctx, tmpl, cfg)
+				calls = append(calls, name+":after")
+				return output, err
+			}
+		}
+	}
+	redact := func(next RenderFunc) RenderFunc {
+		return func(ctx context.Context, tmpl *Template, cfg *Config) (string, error) {
+			output, err := next(ctx, tmpl, cfg)
+			return strings.ReplaceAll(output, "4111", "****"), err
+		}
+	}
+	system.Use(trace("outer"), trace("inner"))
+	system.Use(redact)
+
+	result, err := system.Build(context.Background(), "main.tmpl", "config.json")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello John, your card is ****", result)
+	assert.Equal(t, []string{"outer:before", "inner:before", "inner:after", "outer:after"}, calls)
+
+	// Streaming builds go through the chain too
+	var buf bytes.Buffer
+	require.NoError(t, system.BuildTo(context.Background(), &buf, "main.tmpl", "config.json"))
+	assert.Equal(t, "Hello John, your card is ****", buf.String())
+}
+
+func TestPromptSystem_UseShortCircuit(t *testing.T) {
+	system := newMiddlewareTestSystem(t)
+	budgetErr := errors.New("over budget")
+	system.Use(func(next RenderFunc) RenderFunc {
+		return func(ctx context.Context, tmpl *Template, cfg *Config) (string, error) {
+			return "", budgetErr
+		}
+	})
+
+	_, err := system.Build(context.Background(), "main.tmpl", "config.json")
+	assert.ErrorIs(t, err, budgetErr)
+}
+
+func TestPromptSystem_UseModifiesConfig(t *testing.T) {
+	system := newMiddlewareTestSystem(t)
+	system.Use(func(next RenderFunc) RenderFunc {
+		return func(ctx context.Context, tmpl *Template, cfg *Config) (string, error) {
+			cfg.Config["name"] = "Jane"
+			return next(ctx, tmpl, cfg)
+		}
+	})
+
+	result, err := system.Build(context.Background(), "main.tmpl", "config.json")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello Jane, your card is 4111", result)
+}
@@ -0,0 +1,418 @@
+package prompt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/notzree/rprompt/v2/prompt/core"
+	"github.com/notzree/rprompt/v2/utils"
+)
+
+// MigrationResult lists the files a migration rewrote or moved, or would have on a dry run
+type MigrationResult struct {
+	Templates []string `json:"templates"`
+	Configs   []string `json:"configs"`
+	// Moved maps the registry paths of moved files to their new paths
+	Moved  map[string]string `json:"moved,omitempty"`
+	DryRun bool              `json:"dry_run,omitempty"`
+}
+
+// MigrateDelims rewrites every template in the registry from its current delimiters to left and right, and
+// uses them from then on. The registry's settings must be changed to match. Saved versions keep the old
+// delimiters, so pinned references to versions saved before the migration no longer parse.
+//
+// Templates are checked before anything is written: the migration fails if one already contains the new
+// delimiters as plain text, which would start an action, or doesn't parse once rewritten.
+func (r *LocalPromptRegistry) MigrateDelims(ctx context.Context, left, right string, dryRun bool) (*MigrationResult, error) {
+	oldLeft, oldRight := core.RegistryDelims(r)
+	if left == oldLeft && right == oldRight {
+		return nil, fmt.Errorf("the registry already uses the delimiters %s %s", left, right)
+	}
+	migrated := &LocalPromptRegistry{Directory: r.Directory, LeftDelim: left, RightDelim: right}
+	rewritten := make(map[string]string)
+	err := r.rewriteTemplates(ctx, func(path, content string) (string, error) {
+		for _, segment := range splitActions(content, oldLeft, oldRight) {
+			if !segment.action && (strings.Contains(segment.text, left) || strings.Contains(segment.text, right)) {
+				return "", fmt.Errorf("%s already contains %s or %s outside of actions", path, left, right)
+			}
+		}
+		updated := replaceActions(content, oldLeft, oldRight, left, right, nil)
+		if err := NewTemplate(path, updated, migrated).Compile(); err != nil {
+			return "", fmt.Errorf("%s doesn't parse with the delimiters %s %s: %w", path, left, right, err)
+		}
+		return updated, nil
+	}, rewritten)
+	if err != nil {
+		return nil, err
+	}
+	result := &MigrationResult{Templates: sortedKeys(rewritten), Configs: []string{}, DryRun: dryRun}
+	if dryRun {
+		return result, nil
+	}
+	if err := r.saveTemplates(rewritten); err != nil {
+		return nil, err
+	}
+	r.LeftDelim, r.RightDelim = left, right
+	return result, nil
+}
+
+// variablePathPattern matches dotted config paths such as user.name
+var variablePathPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// RenameVariable renames the config value at the dotted path from to the path to, in every template that
+// uses it and every config in the registry that sets it. Values nested under from move along with it, so
+// renaming user to customer rewrites .user.name to .customer.name.
+//
+// References are rewritten in the actions of templates whose variables include from. References relative
+// to a dot rebound by range or with can't be told apart from config references, so the changes should be
+// reviewed, e.g. with 'rprompt changelog'.
+func (r *LocalPromptRegistry) RenameVariable(ctx context.Context, from, to string, dryRun bool) (*MigrationResult, error) {
+	for _, path := range []string{from, to} {
+		if !variablePathPattern.MatchString(path) {
+			return nil, fmt.Errorf("invalid variable %q, expected a dotted path such as user.name", path)
+		}
+	}
+	if from == to || strings.HasPrefix(to, from+".") || strings.HasPrefix(from, to+".") {
+		return nil, fmt.Errorf("can't rename %s to %s, neither may contain the other", from, to)
+	}
+
+	rewritten := make(map[string]string)
+	err := r.rewriteTemplates(ctx, func(path, content string) (string, error) {
+		template, err := r.Find(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		cfg, err := template.GenerateConfig(ctx, "")
+		if err != nil {
+			return "", fmt.Errorf("failed to read the variables of %s: %w", path, err)
+		}
+		if !usesVariable(utils.FlattenKeys(cfg.Config), from) {
+			return content, nil
+		}
+		left, right := core.RegistryDelims(r)
+		return replaceActions(content, left, right, left, right, func(action string) string {
+			return rewriteCode(action, func(code string) string { return renameField(code, from, to) })
+		}), nil
+	}, rewritten)
+	if err != nil {
+		return nil, err
+	}
+
+	configs, err := r.ListConfigs()
+	if err != nil {
+		return nil, err
+	}
+	renamed := make(map[string]*Config)
+	for _, path := range configs {
+		cfg, err := r.LoadConfig(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		value, ok := utils.GetPath(cfg.Config, from)
+		if !ok {
+			continue
+		}
+		if _, exists := utils.GetPath(cfg.Config, to); exists {
+			return nil, fmt.Errorf("%s already sets %s", path, to)
+		}
+		data := utils.WithoutPath(cfg.Config, from)
+		utils.SetPath(data, to, value)
+		renamed[path] = NewConfig(data, path)
+	}
+
+	result := &MigrationResult{Templates: sortedKeys(rewritten), Configs: sortedKeys(renamed), DryRun: dryRun}
+	if dryRun {
+		return result, nil
+	}
+	if err := r.saveTemplates(rewritten); err != nil {
+		return nil, err
+	}
+	for _, path := range result.Configs {
+		if err := r.SaveConfig(ctx, renamed[path]); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// MoveTemplate moves the template at from to the path to, along with the config and prompt tests named after
+// it and their saved versions, and rewrites the templates that include it
+func (r *LocalPromptRegistry) MoveTemplate(ctx context.Context, from, to string, dryRun bool) (*MigrationResult, error) {
+	for _, path := range []string{from, to} {
+		if !strings.HasSuffix(path, ".tmpl") {
+			return nil, fmt.Errorf("template file must have .tmpl extension: %s", path)
+		}
+	}
+	if _, err := r.ResolvePath(from); err != nil {
+		return nil, err
+	}
+	stem, newStem := strings.TrimSuffix(from, ".tmpl"), strings.TrimSuffix(to, ".tmpl")
+	moved := make(map[string]string)
+	for _, ext := range []string{".tmpl", ".json", PromptTestSuffix} {
+		src, dest := stem+ext, newStem+ext
+		if _, err := os.Stat(filepath.Join(r.Directory, filepath.FromSlash(src))); err != nil {
+			continue
+		}
+		for _, existing := range []string{filepath.Join(r.Directory, filepath.FromSlash(dest)), r.historyDir(dest)} {
+			if _, err := os.Stat(existing); !errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("can't move %s to %s, %s already exists", src, dest, existing)
+			}
+		}
+		moved[src] = dest
+	}
+
+	left, right := core.RegistryDelims(r)
+	rewritten := make(map[string]string)
+	err := r.rewriteTemplates(ctx, func(path, content string) (string, error) {
+		if path == from {
+			return content, nil
+		}
+		updated := replaceActions(content, left, right, left, right, func(action string) string {
+			return renameInclude(action, from, to)
+		})
+		if updated == content {
+			return content, nil
+		}
+		if err := NewTemplate(path, updated, r).Compile(); err != nil {
+			return "", fmt.Errorf("%s doesn't parse once its includes are rewritten: %w", path, err)
+		}
+		return updated, nil
+	}, rewritten)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MigrationResult{Templates: sortedKeys(rewritten), Configs: []string{}, Moved: moved, DryRun: dryRun}
+	if dryRun {
+		return result, nil
+	}
+	for _, src := range sortedKeys(moved) {
+		dest := moved[src]
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(r.Directory, filepath.FromSlash(dest))), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directories: %w", err)
+		}
+		if err := os.Rename(filepath.Join(r.Directory, filepath.FromSlash(src)), filepath.Join(r.Directory, filepath.FromSlash(dest))); err != nil {
+			return nil, fmt.Errorf("failed to move %s: %w", src, err)
+		}
+		if _, err := os.Stat(r.historyDir(src)); err == nil {
+			if err := os.MkdirAll(filepath.Dir(r.historyDir(dest)), 0755); err != nil {
+				return nil, fmt.Errorf("failed to create directories: %w", err)
+			}
+			if err := os.Rename(r.historyDir(src), r.historyDir(dest)); err != nil {
+				return nil, fmt.Errorf("failed to move the history of %s: %w", src, err)
+			}
+		}
+	}
+	if err := r.saveTemplates(rewritten); err != nil {
+		return nil, err
+	}
+	// The index still lists the template at its old path
+	if index, err := r.Index(); err != nil || index != nil {
+		if err == nil {
+			_, err = r.WriteIndex(ctx)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	r.notify(from)
+	r.notify(to)
+	return result, nil
+}
+
+// rewriteTemplates calls rewrite with the content of every template in the registry, and adds the templates
+// it changes to rewritten
+func (r *LocalPromptRegistry) rewriteTemplates(ctx context.Context, rewrite func(path, content string) (string, error), rewritten map[string]string) error {
+	paths, err := r.listFiles(core.RegistryExtensions(r)...)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		content, err := os.ReadFile(filepath.Join(r.Directory, filepath.FromSlash(path)))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		updated, err := rewrite(path, string(content))
+		if err != nil {
+			return err
+		}
+		if updated != string(content) {
+			rewritten[path] = updated
+		}
+	}
+	return nil
+}
+
+// saveTemplates saves every rewritten template, in order of path
+func (r *LocalPromptRegistry) saveTemplates(rewritten map[string]string) error {
+	for _, path := range sortedKeys(rewritten) {
+		if err := r.SaveTemplate(path, rewritten[path]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// actionSegment is a run of a template's content, either an action without its delimiters or the text
+// between actions
+type actionSegment struct {
+	text   string
+	action bool
+}
+
+// splitActions splits content into actions delimited by left and right and the text between them. An
+// unterminated action is left as text.
+func splitActions(content, left, right string) []actionSegment {
+	var segments []actionSegment
+	rest := content
+	for {
+		start := strings.Index(rest, left)
+		if start < 0 {
+			break
+		}
+		end := strings.Index(rest[start+len(left):], right)
+		if end < 0 {
+			break
+		}
+		end += start + len(left)
+		segments = append(segments, actionSegment{text: rest[:start]}, actionSegment{text: rest[start+len(left) : end], action: true})
+		rest = rest[end+len(right):]
+	}
+	return append(segments, actionSegment{text: rest})
+}
+
+// replaceActions rewrites every action delimited by left and right to use newLeft and newRight, applying
+// rewrite to the action's text when it isn't nil
+func replaceActions(content, left, right, newLeft, newRight string, rewrite func(action string) string) string {
+	var builder strings.Builder
+	for _, segment := range splitActions(content, left, right) {
+		if !segment.action {
+			builder.WriteString(segment.text)
+			continue
+		}
+		action := segment.text
+		if rewrite != nil {
+			action = rewrite(action)
+		}
+		builder.WriteString(newLeft)
+		builder.WriteString(action)
+		builder.WriteString(newRight)
+	}
+	return builder.String()
+}
+
+// rewriteCode applies rewrite to the code of an action, leaving its string literals, raw strings, character
+// constants and comments as they are
+func rewriteCode(action string, rewrite func(code string) string) string {
+	var builder strings.Builder
+	code := 0
+	for i := 0; i < len(action); i++ {
+		end := -1
+		switch {
+		case strings.HasPrefix(action[i:], "/*"):
+			if close := strings.Index(action[i+2:], "*/"); close >= 0 {
+				end = i + 2 + close + 2
+			}
+		case action[i] == '`':
+			if close := strings.IndexByte(action[i+1:], '`'); close >= 0 {
+				end = i + 1 + close + 1
+			}
+		case action[i] == '"' || action[i] == '\'':
+			for j := i + 1; j < len(action); j++ {
+				if action[j] == '\\' {
+					j++
+				} else if action[j] == action[i] {
+					end = j + 1
+					break
+				}
+			}
+		}
+		if end < 0 {
+			continue
+		}
+		builder.WriteString(rewrite(action[code:i]))
+		builder.WriteString(action[i:end])
+		code, i = end, end-1
+	}
+	builder.WriteString(rewrite(action[code:]))
+	return builder.String()
+}
+
+// usesVariable reports whether the variables include path or a value nested under it
+func usesVariable(variables []string, path string) bool {
+	for _, variable := range variables {
+		if variable == path || strings.HasPrefix(variable, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// renameField rewrites the field chains starting with .from, or $.from, in action code to start with .to.
+// Fields of variables such as $user.from and of other fields such as .a.from are left alone.
+func renameField(code, from, to string) string {
+	field := "." + from
+	var builder strings.Builder
+	for {
+		i := strings.Index(code, field)
+		if i < 0 {
+			break
+		}
+		end := i + len(field)
+		atStart := i == 0 || !isFieldChar(code[i-1]) && code[i-1] != ')' && code[i-1] != '.'
+		atEnd := end == len(code) || !isFieldChar(code[end])
+		builder.WriteString(code[:i])
+		if atStart && atEnd {
+			builder.WriteString("." + to)
+		} else {
+			builder.WriteString(field)
+		}
+		code = code[end:]
+	}
+	builder.WriteString(code)
+	return builder.String()
+}
+
+func isFieldChar(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// includePattern matches the start of a template action, capturing the name it includes
+var includePattern = regexp.MustCompile("^(-?\\s*template\\s+)(\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`)")
+
+// renameInclude rewrites a template action including from to include to instead, keeping whether the name
+// was written with its .tmpl extension
+func renameInclude(action, from, to string) string {
+	m := includePattern.FindStringSubmatchIndex(action)
+	if m == nil {
+		return action
+	}
+	name, err := strconv.Unquote(action[m[4]:m[5]])
+	if err != nil || core.DependencyPath(name) != from {
+		return action
+	}
+	if !strings.HasSuffix(name, ".tmpl") {
+		to = strings.TrimSuffix(to, ".tmpl")
+	}
+	return action[:m[4]] + strconv.Quote(to) + action[m[5]:]
+}
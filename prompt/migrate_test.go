@@ -0,0 +1,154 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/notzree/rprompt/v2/prompt/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readTestFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestLocalPromptRegistry_MigrateDelims(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[- template "header.tmpl" . ]] Hello [[.name]] {"json": true}`)
+	createTestFile(t, tempDir, "header.tmpl", `Header`)
+	registry := NewInMemPromptRegistry(tempDir)
+	ctx := context.Background()
+
+	result, err := registry.MigrateDelims(ctx, "{{", "}}", true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.tmpl"}, result.Templates)
+	assert.Contains(t, readTestFile(t, tempDir, "main.tmpl"), "[[.name]]", "dry runs change nothing")
+
+	_, err = registry.MigrateDelims(ctx, "{", "}", false)
+	assert.ErrorContains(t, err, "main.tmpl already contains { or } outside of actions")
+
+	_, err = registry.MigrateDelims(ctx, "{{", "}}", false)
+	require.NoError(t, err)
+	assert.Equal(t, `{{- template "header.tmpl" . }} Hello {{.name}} {"json": true}`, readTestFile(t, tempDir, "main.tmpl"))
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	output, err := system.Build(ctx, "main.tmpl", "", WithValue("name", "John"))
+	require.NoError(t, err)
+	assert.Equal(t, `Header Hello John {"json": true}`, output)
+
+	_, err = registry.MigrateDelims(ctx, "{{", "}}", false)
+	assert.ErrorContains(t, err, "already uses")
+}
+
+func TestLocalPromptRegistry_RenameVariable(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hi [[.user.name]] [[$.user.age]] [[.username]] [[printf "%s .user" .user.name]] [[/* .user */]]`)
+	createTestFile(t, tempDir, "other.tmpl", `[[range .items]][[.user]][[end]]`)
+	createTestFile(t, tempDir, "main.json", `{"user": {"name": "John", "age": 3}, "username": "jd"}`)
+	createTestFile(t, tempDir, "taken.json", `{"user": {}, "customer": {}}`)
+	registry := NewInMemPromptRegistry(tempDir)
+	ctx := context.Background()
+
+	_, err := registry.RenameVariable(ctx, "user", "customer", false)
+	assert.ErrorContains(t, err, "taken.json already sets customer")
+	require.NoError(t, os.Remove(filepath.Join(tempDir, "taken.json")))
+	_, err = registry.RenameVariable(ctx, "user", "user.inner", false)
+	assert.ErrorContains(t, err, "neither may contain the other")
+
+	result, err := registry.RenameVariable(ctx, "user", "customer", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.tmpl"}, result.Templates, "other.tmpl uses items, not user")
+	assert.Equal(t, []string{"main.json"}, result.Configs)
+	assert.Equal(t, `Hi [[.customer.name]] [[$.customer.age]] [[.username]] [[printf "%s .user" .customer.name]] [[/* .user */]]`, readTestFile(t, tempDir, "main.tmpl"))
+
+	cfg, err := registry.LoadConfig(ctx, "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"customer": map[string]any{"name": "John", "age": float64(3)}, "username": "jd"}, cfg.Config)
+
+	versions, err := registry.History("main.tmpl")
+	require.NoError(t, err)
+	assert.Len(t, versions, 2, "the rename can be rolled back")
+}
+
+func TestLocalPromptRegistry_MoveTemplate(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "header.tmpl", `Header`)
+	createTestFile(t, tempDir, "header.json", `{}`)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "header.tmpl" .]] [[template "header"]] [[template "headers.tmpl"]]`)
+	createTestFile(t, tempDir, "headers.tmpl", `Headers`)
+	registry := NewInMemPromptRegistry(tempDir)
+	require.NoError(t, registry.SaveTemplate("header.tmpl", "Header v2"))
+	ctx := context.Background()
+	_, err := registry.WriteIndex(ctx)
+	require.NoError(t, err)
+
+	_, err = registry.MoveTemplate(ctx, "header.tmpl", "headers.tmpl", false)
+	assert.ErrorContains(t, err, "already exists")
+
+	result, err := registry.MoveTemplate(ctx, "header.tmpl", "partials/header.tmpl", false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"header.tmpl": "partials/header.tmpl", "header.json": "partials/header.json"}, result.Moved)
+	assert.Equal(t, []string{"main.tmpl"}, result.Templates)
+	assert.Equal(t, `[[template "partials/header.tmpl" .]] [[template "partials/header"]] [[template "headers.tmpl"]]`, readTestFile(t, tempDir, "main.tmpl"))
+	assert.NoFileExists(t, filepath.Join(tempDir, "header.tmpl"))
+
+	versions, err := registry.History("partials/header.tmpl")
+	require.NoError(t, err)
+	assert.Len(t, versions, 2)
+	templates, err := registry.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"headers.tmpl", "main.tmpl", "partials/header.tmpl"}, templates)
+
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	output, err := system.Build(ctx, "main.tmpl", "")
+	require.NoError(t, err)
+	assert.Equal(t, "Header v2 Header v2 Headers", output)
+}
+
+func TestRenameField(t *testing.T) {
+	for code, expected := range map[string]string{
+		" .a.b ":         " .c.b ",
+		"$.a":            "$.c",
+		"$x.a":           "$x.a",
+		".x.a":           ".x.a",
+		".ab":            ".ab",
+		"(index .a 0).a": "(index .c 0).a",
+	} {
+		assert.Equal(t, expected, renameField(code, "a", "c"), code)
+	}
+}
+
+func TestApp_Migrate(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "Hello [[.name]]")
+	var saved *settings.Settings
+	app, stdout, _ := newTestApp(t,
+		WithRegistry(NewInMemPromptRegistry(tempDir)),
+		WithSettingsSaver(func(s *settings.Settings) error { saved = s; return nil }),
+	)
+	ctx := context.Background()
+	run := func(args ...string) error {
+		stdout.Reset()
+		return app.Command().Run(ctx, append([]string{"rprompt"}, args...))
+	}
+
+	require.NoError(t, run("migrate", "rename-var", "--dry-run", "name", "user"))
+	assert.Equal(t, "Would rewrite main.tmpl\n", stdout.String())
+	require.NoError(t, run("--json", "migrate", "move", "main.tmpl", "agents/main.tmpl"))
+	var result MigrationResult
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+	assert.Equal(t, map[string]string{"main.tmpl": "agents/main.tmpl"}, result.Moved)
+
+	require.NoError(t, run("migrate", "delims", "{{,}}"))
+	assert.Equal(t, "Hello {{.name}}", readTestFile(t, tempDir, "agents/main.tmpl"))
+	require.NotNil(t, saved)
+	assert.Equal(t, "{{,}}", saved.Delims)
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package prompt
+
+import (
+	"io"
+	"os"
+)
+
+// openMapped opens the file at path for reading. Platforms without mmap read it as it is streamed instead.
+func openMapped(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
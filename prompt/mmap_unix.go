@@ -0,0 +1,47 @@
+//go:build unix
+
+package prompt
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"syscall"
+)
+
+// mappedFile reads a memory-mapped file and unmaps it on Close
+type mappedFile struct {
+	*bytes.Reader
+	data []byte
+}
+
+func (m *mappedFile) Close() error {
+	if m.data == nil {
+		return nil
+	}
+	data := m.data
+	m.data = nil
+	return syscall.Munmap(data)
+}
+
+// openMapped memory-maps the file at path for reading
+func openMapped(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// Empty files can't be mapped
+	if info.Size() == 0 {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	return &mappedFile{Reader: bytes.NewReader(data), data: data}, nil
+}
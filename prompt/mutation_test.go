@@ -0,0 +1,79 @@
+package prompt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMutationCheck(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "Hello [[.name]], welcome to [[.product]].\n"+
+		"[[if .notes]]Notes: [[.notes]][[end]]\n"+
+		"[[range .items]]- [[.]]\n[[end]]"+
+		"[[with .footer]][[.]][[end]]")
+	createTestFile(t, tempDir, "main.json", `{"name": "Alice", "product": "rprompt", "notes": "none", "items": ["a", "b"], "footer": "Bye", "unused": 1}`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+
+	report, err := system.MutationCheck(context.Background(), "main.tmpl", "main.json")
+	require.NoError(t, err)
+	outcomes := make(map[string]MutationOutcome)
+	for _, result := range report.Results {
+		outcomes[result.Variable+" "+result.Mutation] = result.Outcome
+	}
+	assert.Equal(t, map[string]MutationOutcome{
+		"footer emptied":  MutationOK,
+		"footer removed":  MutationOK,
+		"items emptied":   MutationOK,
+		"items removed":   MutationOK,
+		"name emptied":    MutationBroken,
+		"name removed":    MutationBroken,
+		"notes emptied":   MutationOK,
+		"notes removed":   MutationOK,
+		"product emptied": MutationBroken,
+		"product removed": MutationBroken,
+	}, outcomes)
+	assert.Equal(t, []string{"name", "product"}, report.Fragile())
+
+	for _, result := range report.Broken() {
+		if result.Variable == "name" && result.Mutation == "emptied" {
+			assert.Equal(t, []string{"space before punctuation (1 more)"}, result.Problems)
+		}
+		if result.Variable == "name" && result.Mutation == "removed" {
+			assert.Contains(t, result.Problems, "renders <no value> (1 more)")
+		}
+	}
+}
+
+func TestMutationCheck_Errors(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[.user.name | printf "%s"]] [[index .tags 0]]`)
+	createTestFile(t, tempDir, "main.json", `{"user": {"name": "Alice"}, "tags": ["x"]}`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+
+	report, err := system.MutationCheck(context.Background(), "main.tmpl", "main.json")
+	require.NoError(t, err)
+	for _, result := range report.Results {
+		if result.Variable == "tags" {
+			assert.Equal(t, MutationFailed, result.Outcome, result.Mutation)
+			assert.NotEmpty(t, result.Error)
+		}
+	}
+
+	_, err = system.MutationCheck(context.Background(), "main.tmpl", "")
+	assert.ErrorContains(t, err, "must build with the config")
+}
+
+func TestApp_MutationCheck(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello [[.name]], how are you?`)
+	createTestFile(t, tempDir, "main.json", `{"name": "Alice"}`)
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "mutation-check", "-t", "main.tmpl", "-c", "main.json"}))
+	assert.Contains(t, stdout.String(), "Consider requiring: name")
+}
@@ -0,0 +1,73 @@
+package prompt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildOptions(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[.greeting]] [[.user.name]] ([[.user.role]])`)
+	createTestFile(t, tempDir, "config.json", `{"greeting": "Hello", "user": {"name": "John", "role": "admin"}}`)
+	registry := NewInMemPromptRegistry(tempDir)
+	system, _ := NewPromptSystem(registry)
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		configPath string
+		opts       []BuildOption
+		expected   string
+	}{
+		{
+			name:       "no options",
+			configPath: "config.json",
+			expected:   "Hello John (admin)",
+		},
+		{
+			name:       "data merges over nested config",
+			configPath: "config.json",
+			opts:       []BuildOption{WithData(map[string]any{"user": map[string]any{"name": "Jane"}})},
+			expected:   "Hello Jane (admin)",
+		},
+		{
+			name:       "value at dotted path",
+			configPath: "config.json",
+			opts:       []BuildOption{WithValue("user.role", "viewer")},
+			expected:   "Hello John (viewer)",
+		},
+		{
+			name:       "later options win",
+			configPath: "config.json",
+			opts: []BuildOption{
+				WithData(map[string]any{"greeting": "Hi"}),
+				WithValue("greeting", "Hey"),
+			},
+			expected: "Hey John (admin)",
+		},
+		{
+			name: "without a config file",
+			opts: []BuildOption{
+				WithData(map[string]any{"greeting": "Hi", "user": map[string]any{"name": "Kai"}}),
+				WithValue("user.role", "guest"),
+			},
+			expected: "Hi Kai (guest)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := system.Build(ctx, "main.tmpl", tt.configPath, tt.opts...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+
+	// The stored config is never modified
+	cfg, err := registry.LoadConfig(ctx, "config.json")
+	require.NoError(t, err)
+	assert.Equal(t, "John", cfg.Config["user"].(map[string]any)["name"])
+}
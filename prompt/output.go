@@ -0,0 +1,129 @@
+package prompt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+type Verbosity int
+
+const (
+	// VerbosityQuiet only prints command results and errors
+	VerbosityQuiet Verbosity = iota
+	// VerbosityNormal also prints status messages and warnings
+	VerbosityNormal
+	// VerbosityVerbose also prints debug output
+	VerbosityVerbose
+)
+
+const (
+	colorReset   = "\033[0m"
+	colorRed     = "\033[31m"
+	colorGreen   = "\033[32m"
+	colorYellow  = "\033[33m"
+	colorCyan    = "\033[36m"
+	colorGray    = "\033[90m"
+	colorBold    = "\033[1m"
+	colorReverse = "\033[7m"
+)
+
+// Output is the writer abstraction all CLI output goes through, so verbosity, color and JSON mode are
+// decided in one place rather than by each command
+type Output struct {
+	Out       io.Writer
+	Err       io.Writer
+	Verbosity Verbosity
+	Color     bool
+	JSON      bool
+}
+
+// NewOutput creates an Output writing results to out and diagnostics to errOut.
+// Color is enabled when out is a terminal and the NO_COLOR environment variable is unset.
+func NewOutput(out, errOut io.Writer) *Output {
+	return &Output{
+		Out:       out,
+		Err:       errOut,
+		Verbosity: VerbosityNormal,
+		Color:     isTerminal(out) && os.Getenv("NO_COLOR") == "",
+	}
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func (o *Output) colorize(color, s string) string {
+	if !o.Color {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// Printf writes a command result. Results are printed at every verbosity level.
+func (o *Output) Printf(format string, args ...any) {
+	fmt.Fprintf(o.Out, format, args...)
+}
+
+// Println writes a command result line. Results are printed at every verbosity level.
+func (o *Output) Println(args ...any) {
+	fmt.Fprintln(o.Out, args...)
+}
+
+// Successf writes a status message about a completed action; suppressed by --quiet
+func (o *Output) Successf(format string, args ...any) {
+	if o.Verbosity < VerbosityNormal {
+		return
+	}
+	fmt.Fprintln(o.Out, o.colorize(colorGreen, fmt.Sprintf(format, args...)))
+}
+
+// Infof writes an informational status message; suppressed by --quiet
+func (o *Output) Infof(format string, args ...any) {
+	if o.Verbosity < VerbosityNormal {
+		return
+	}
+	fmt.Fprintln(o.Out, fmt.Sprintf(format, args...))
+}
+
+// Warnf writes a warning to the error stream; suppressed by --quiet
+func (o *Output) Warnf(format string, args ...any) {
+	if o.Verbosity < VerbosityNormal {
+		return
+	}
+	fmt.Fprintln(o.Err, o.colorize(colorYellow, "Warning: ")+fmt.Sprintf(format, args...))
+}
+
+// Errorf writes an error to the error stream. Errors are printed at every verbosity level.
+func (o *Output) Errorf(format string, args ...any) {
+	fmt.Fprintln(o.Err, o.colorize(colorRed, "Error: ")+fmt.Sprintf(format, args...))
+}
+
+// Debugf writes debug output to the error stream; only printed with --verbose
+func (o *Output) Debugf(format string, args ...any) {
+	if o.Verbosity < VerbosityVerbose {
+		return
+	}
+	fmt.Fprintln(o.Err, o.colorize(colorGray, fmt.Sprintf(format, args...)))
+}
+
+// Report prints v as indented JSON in JSON mode, and otherwise runs human to print the human-readable form.
+// Every command reports its result through here so the JSON schema stays in one place.
+func (o *Output) Report(v any, human func()) error {
+	if !o.JSON {
+		human()
+		return nil
+	}
+	enc := json.NewEncoder(o.Out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
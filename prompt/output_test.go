@@ -0,0 +1,83 @@
+package prompt
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestOutput() (*Output, *bytes.Buffer, *bytes.Buffer) {
+	var stdout, stderr bytes.Buffer
+	return NewOutput(&stdout, &stderr), &stdout, &stderr
+}
+
+func TestOutput_Verbosity(t *testing.T) {
+	tests := []struct {
+		name           string
+		verbosity      Verbosity
+		expectedStdout string
+		expectedStderr string
+	}{
+		{
+			name:           "quiet only prints results and errors",
+			verbosity:      VerbosityQuiet,
+			expectedStdout: "result\n",
+			expectedStderr: "Error: broken\n",
+		},
+		{
+			name:           "normal prints status and warnings",
+			verbosity:      VerbosityNormal,
+			expectedStdout: "result\ndone\ninfo\n",
+			expectedStderr: "Warning: careful\nError: broken\n",
+		},
+		{
+			name:           "verbose prints debug output",
+			verbosity:      VerbosityVerbose,
+			expectedStdout: "result\ndone\ninfo\n",
+			expectedStderr: "Warning: careful\nError: broken\ndebug\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, stdout, stderr := newTestOutput()
+			o.Verbosity = tt.verbosity
+
+			o.Println("result")
+			o.Successf("done")
+			o.Infof("info")
+			o.Warnf("careful")
+			o.Errorf("broken")
+			o.Debugf("debug")
+
+			assert.Equal(t, tt.expectedStdout, stdout.String())
+			assert.Equal(t, tt.expectedStderr, stderr.String())
+		})
+	}
+}
+
+func TestOutput_Color(t *testing.T) {
+	o, _, stderr := newTestOutput()
+	assert.False(t, o.Color, "color should be off for non-terminal writers")
+
+	o.Color = true
+	o.Errorf("broken")
+	assert.Equal(t, colorRed+"Error: "+colorReset+"broken\n", stderr.String())
+}
+
+func TestOutput_Report(t *testing.T) {
+	o, stdout, _ := newTestOutput()
+	called := false
+	err := o.Report(map[string]string{"path": "a.tmpl"}, func() { called = true })
+	assert.NoError(t, err)
+	assert.True(t, called)
+	assert.Empty(t, stdout.String())
+
+	o.JSON = true
+	called = false
+	err = o.Report(map[string]string{"path": "a.tmpl"}, func() { called = true })
+	assert.NoError(t, err)
+	assert.False(t, called)
+	assert.JSONEq(t, `{"path": "a.tmpl"}`, stdout.String())
+}
@@ -0,0 +1,131 @@
+package prompt
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// OwnersFile is the registry-level file assigning owners to templates, in the style of CODEOWNERS. Each line is
+// a pattern followed by the owners of the templates it matches, and the last matching line wins:
+//
+//	# Everything defaults to the platform team
+//	*                 @platform
+//	agents/           @agents-team alice@example.com
+//	billing_*.tmpl    @billing
+//
+// A pattern ending in / matches every template under that directory, a pattern without a / matches file names
+// in any directory, and any other pattern matches whole registry paths. Patterns use path.Match syntax. Owners
+// listed in a template's front matter take precedence over the file.
+const OwnersFile = "OWNERS"
+
+// OwnersRule is one line of an OwnersFile
+type OwnersRule struct {
+	Pattern string   `json:"pattern"`
+	Owners  []string `json:"owners"`
+}
+
+// Matches reports whether the rule applies to the template at the registry path
+func (rule OwnersRule) Matches(templatePath string) bool {
+	pattern := strings.TrimPrefix(rule.Pattern, "/")
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasSuffix(pattern, "/"):
+		return strings.HasPrefix(templatePath, pattern)
+	case !strings.Contains(pattern, "/"):
+		matched, _ := path.Match(pattern, path.Base(templatePath))
+		return matched
+	}
+	matched, _ := path.Match(pattern, templatePath)
+	return matched
+}
+
+// ParseOwners parses the content of an OwnersFile. Blank lines and lines starting with # are skipped.
+func ParseOwners(content string) ([]OwnersRule, error) {
+	var rules []OwnersRule
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if _, err := path.Match(strings.TrimSuffix(fields[0], "/"), ""); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid pattern %q: %w", OwnersFile, line, fields[0], err)
+		}
+		rules = append(rules, OwnersRule{Pattern: fields[0], Owners: fields[1:]})
+	}
+	return rules, scanner.Err()
+}
+
+// ownersFor returns the owners of the last rule matching the template at path
+func ownersFor(rules []OwnersRule, templatePath string) []string {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].Matches(templatePath) {
+			return rules[i].Owners
+		}
+	}
+	return nil
+}
+
+// FileOwners returns the owners the registry's OwnersFile assigns the template at path, see OwnerRegistry
+func (r *LocalPromptRegistry) FileOwners(path string) ([]string, error) {
+	rules, err := r.ownersRules()
+	if err != nil {
+		return nil, err
+	}
+	return ownersFor(rules, path), nil
+}
+
+// ownersRules reads the registry's OwnersFile, returning no rules when there is none
+func (r *LocalPromptRegistry) ownersRules() ([]OwnersRule, error) {
+	content, err := os.ReadFile(filepath.Join(r.Directory, OwnersFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", OwnersFile, err)
+	}
+	return ParseOwners(string(content))
+}
+
+// TemplateOwners maps every template in the registry to its owners, from the templates' front matter or the
+// registry's OwnersFile. Templates without owners map to an empty list. Templates that haven't changed since
+// the registry's index was written aren't read again.
+func TemplateOwners(ctx context.Context, r *LocalPromptRegistry) (map[string][]string, error) {
+	entries, err := r.entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := r.ownersRules()
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[string][]string, len(entries))
+	for _, entry := range entries {
+		owners[entry.Path] = entry.Owners
+		if len(entry.Owners) == 0 {
+			owners[entry.Path] = append([]string{}, ownersFor(rules, entry.Path)...)
+		}
+	}
+	return owners, nil
+}
+
+// Unowned returns the templates in owners without any owner, sorted
+func Unowned(owners map[string][]string) []string {
+	unowned := []string{}
+	for path, templateOwners := range owners {
+		if len(templateOwners) == 0 {
+			unowned = append(unowned, path)
+		}
+	}
+	sort.Strings(unowned)
+	return unowned
+}
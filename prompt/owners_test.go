@@ -0,0 +1,103 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOwners(t *testing.T) {
+	rules, err := ParseOwners(`# Default owners
+*                 @platform
+
+agents/           @agents-team alice@example.com
+billing_*.tmpl    @billing
+/support/faq.tmpl @support
+`)
+	require.NoError(t, err)
+	require.Len(t, rules, 4)
+	assert.Equal(t, OwnersRule{Pattern: "agents/", Owners: []string{"@agents-team", "alice@example.com"}}, rules[1])
+
+	for path, expected := range map[string][]string{
+		"main.tmpl":                {"@platform"},
+		"agents/main.tmpl":         {"@agents-team", "alice@example.com"},
+		"agents/billing_v2.tmpl":   {"@billing"},
+		"support/faq.tmpl":         {"@support"},
+		"support/archive/faq.tmpl": {"@platform"},
+	} {
+		assert.Equal(t, expected, ownersFor(rules, path), path)
+	}
+
+	_, err = ParseOwners("ok.tmpl @a\n[ @b")
+	assert.ErrorContains(t, err, "OWNERS:2: invalid pattern")
+}
+
+func TestTemplateOwners(t *testing.T) {
+	tempDir := setupTempDir(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "agents"), 0755))
+	createTestFile(t, tempDir, OwnersFile, "agents/ @agents-team")
+	createTestFile(t, tempDir, "agents/billing.tmpl", "---\nowners: [alice]\n---\nBilling")
+	createTestFile(t, tempDir, "agents/support.tmpl", "Support")
+	createTestFile(t, tempDir, "stale.tmpl", "Stale")
+	registry := NewInMemPromptRegistry(tempDir)
+	ctx := context.Background()
+
+	owners, err := TemplateOwners(ctx, registry)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"agents/billing.tmpl": {"alice"},
+		"agents/support.tmpl": {"@agents-team"},
+		"stale.tmpl":          {},
+	}, owners)
+	assert.Equal(t, []string{"stale.tmpl"}, Unowned(owners))
+
+	_, err = registry.WriteIndex(ctx)
+	require.NoError(t, err)
+	indexed, err := TemplateOwners(ctx, registry)
+	require.NoError(t, err)
+	assert.Equal(t, owners, indexed)
+
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	info, err := system.Describe(ctx, "agents/support.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"@agents-team"}, info.Owners)
+}
+
+func TestApp_Owners(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "---\nowners: [alice, bob]\n---\nHello [[.name]]")
+	createTestFile(t, tempDir, "stale.tmpl", "Stale")
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	ctx := context.Background()
+	run := func(args ...string) error {
+		stdout.Reset()
+		return app.Command().Run(ctx, append([]string{"rprompt"}, args...))
+	}
+
+	require.NoError(t, run("list", "--owners"))
+	assert.Equal(t, "main.tmpl\talice, bob\nstale.tmpl\t(no owner)\n", stdout.String())
+
+	require.NoError(t, run("--json", "info", "main.tmpl"))
+	var info TemplateInfo
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &info))
+	assert.Equal(t, []string{"alice", "bob"}, info.Owners)
+	assert.Equal(t, []string{"name"}, info.Variables)
+
+	require.NoError(t, run("check"))
+	err := run("--json", "check", "--require-owner")
+	assert.ErrorContains(t, err, "1 template without an owner")
+	var report struct {
+		Unowned []string `json:"unowned"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.Equal(t, []string{"stale.tmpl"}, report.Unowned)
+
+	createTestFile(t, tempDir, OwnersFile, "* @platform")
+	require.NoError(t, run("check", "--require-owner"))
+}
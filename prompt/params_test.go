@@ -0,0 +1,58 @@
+package prompt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithParam(t *testing.T) {
+	tempDir := setupTempDir(t)
+	require.NoError(t, os.Mkdir(filepath.Join(tempDir, "agents"), 0755))
+	createTestFile(t, tempDir, "agents/billing.tmpl", `Billing agent for [[.name]]`)
+	createTestFile(t, tempDir, "agents/billing.fr.tmpl", `Agent de facturation pour [[.name]]`)
+	createTestFile(t, tempDir, "agents/support.tmpl", `Support agent for [[.name]]`)
+	createTestFile(t, tempDir, "secret.tmpl", `secret`)
+	createTestFile(t, tempDir, "main.json", `{"name": "Ada"}`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	for agent, want := range map[string]string{"billing": "Billing agent for Ada", "support": "Support agent for Ada"} {
+		result, err := system.Build(ctx, "agents/{agent}.tmpl", "main.json", WithParam("agent", agent), WithParam("unused", "x"))
+		require.NoError(t, err)
+		assert.Equal(t, want, result)
+	}
+
+	result, err := system.Build(ctx, "agents/{agent}.tmpl", "main.json", WithParam("agent", "billing"), WithLocale("fr"))
+	require.NoError(t, err)
+	assert.Equal(t, "Agent de facturation pour Ada", result, "params are filled in before the locale's variant is picked")
+
+	template, err := system.Find(ctx, "agents/{agent}.tmpl", WithParam("agent", "support"))
+	require.NoError(t, err)
+	assert.Equal(t, "agents/support.tmpl", template.Path)
+	config, err := system.Registry.LoadConfig(ctx, "main.json")
+	require.NoError(t, err)
+	result, err = template.Build(ctx, *config)
+	require.NoError(t, err)
+	assert.Equal(t, "Support agent for Ada", result)
+
+	hash, err := system.Hash(ctx, "agents/{agent}.tmpl", "main.json", WithParam("agent", "billing"))
+	require.NoError(t, err)
+	want, err := system.Hash(ctx, "agents/billing.tmpl", "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, want, hash)
+
+	_, err = system.Build(ctx, "agents/{agent}.tmpl", "main.json")
+	assert.EqualError(t, err, `err finding template: template path "agents/{agent}.tmpl" needs a value for {agent}, set it with WithParam`)
+	_, err = system.Find(ctx, "agents/{agent}.tmpl", WithParam("agent", "../secret"))
+	assert.EqualError(t, err, `invalid value "../secret" for {agent} in "agents/{agent}.tmpl": params must be a single path segment`)
+	_, err = system.Find(ctx, "agents/{agent}.tmpl", WithParam("agent", ""))
+	assert.ErrorContains(t, err, "params must be a single path segment")
+	_, err = system.Find(ctx, "agents/{agent}.tmpl", WithParam("agent", "sales"))
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+}
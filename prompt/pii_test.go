@@ -0,0 +1,124 @@
+package prompt
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/notzree/rprompt/v2/prompt/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskPII(t *testing.T) {
+	config := map[string]any{
+		"customer": map[string]any{
+			"email": "Reach me at jane.doe@example.com or +1 (555) 123-4567",
+			"card":  "4111 1111 1111 1111",
+			"id":    "1234 5678 9012 3456",
+		},
+		"notes":   []any{"call 555-123-4567", "order 42 shipped"},
+		"date":    "2024-10-16",
+		"address": "10.0.100.200",
+		"count":   3,
+	}
+
+	masked, matches := MaskPII(config)
+	assert.Equal(t, []PIIMatch{
+		{Path: "customer.card", Kinds: []string{PIICreditCard}},
+		{Path: "customer.email", Kinds: []string{PIIEmail, PIIPhone}},
+		{Path: "notes.0", Kinds: []string{PIIPhone}},
+	}, matches, "numbers failing the Luhn check, dates and addresses aren't PII")
+	assert.Equal(t, map[string]any{
+		"customer": map[string]any{
+			"email": "Reach me at [EMAIL] or [PHONE]",
+			"card":  "[CREDIT_CARD]",
+			"id":    "1234 5678 9012 3456",
+		},
+		"notes":   []any{"call [PHONE]", "order 42 shipped"},
+		"date":    "2024-10-16",
+		"address": "10.0.100.200",
+		"count":   3,
+	}, masked)
+	assert.Equal(t, "Reach me at jane.doe@example.com or +1 (555) 123-4567", config["customer"].(map[string]any)["email"], "the config is left as it is")
+	assert.Equal(t, matches, FindPII(config))
+
+	for _, mode := range []PIIMode{PIIOff, PIIWarn, PIIMask, PIIBlock} {
+		parsed, err := ParsePIIMode(mode.String())
+		require.NoError(t, err)
+		assert.Equal(t, mode, parsed)
+	}
+	_, err := ParsePIIMode("redact")
+	assert.ErrorContains(t, err, `unknown PII mode "redact"`)
+}
+
+func TestPromptSystem_PII(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[define "reply"]]Reply to [[.email]]: [[.question]][[end]][[template "reply" .]]`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+	opts := []BuildOption{WithValue("email", "jane@example.com"), WithValue("question", "Why?")}
+
+	result, err := system.BuildWithResult(ctx, "main.tmpl", "", opts...)
+	require.NoError(t, err)
+	assert.Equal(t, "Reply to jane@example.com: Why?", result.Output, "configs aren't scanned by default")
+	assert.Empty(t, result.Warnings)
+
+	system.PII = PIIWarn
+	result, err = system.BuildWithResult(ctx, "main.tmpl", "", opts...)
+	require.NoError(t, err)
+	assert.Equal(t, "Reply to jane@example.com: Why?", result.Output)
+	assert.Equal(t, []Warning{{Kind: WarningPII, Path: "email", Message: "email holds likely PII (email)"}}, result.Warnings)
+
+	system.PII = PIIMask
+	result, err = system.BuildWithResult(ctx, "main.tmpl", "", opts...)
+	require.NoError(t, err)
+	assert.Equal(t, "Reply to [EMAIL]: Why?", result.Output)
+	assert.Equal(t, []Warning{{Kind: WarningPII, Path: "email", Message: "email held likely PII (email), masked before rendering"}}, result.Warnings)
+	builder, err := system.NewBuilder(ctx, "main.tmpl", "", opts...)
+	require.NoError(t, err)
+	output, err := builder.Build(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Reply to [EMAIL]: Why?", output)
+	section, err := system.BuildSection(ctx, "main.tmpl", "reply", "", opts...)
+	require.NoError(t, err)
+	assert.Equal(t, "Reply to [EMAIL]: Why?", section)
+
+	system.PII = PIIBlock
+	_, err = system.Build(ctx, "main.tmpl", "", opts...)
+	var piiErr *PIIError
+	require.ErrorAs(t, err, &piiErr)
+	assert.Equal(t, []PIIMatch{{Path: "email", Kinds: []string{PIIEmail}}}, piiErr.Matches)
+	assert.EqualError(t, err, "config holds likely PII in email (email)")
+	assert.Equal(t, CodeConfigInvalid, ErrorCode(err))
+	_, err = builder.Build(ctx)
+	assert.ErrorAs(t, err, &piiErr)
+}
+
+func TestApp_GeneratePII(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Call [[.phone]]`)
+	createTestFile(t, tempDir, "main.json", `{"phone": "555-123-4567"}`)
+	outDir := t.TempDir()
+	generate := func(s *settings.Settings) (string, error) {
+		app, _, stderr := newTestApp(t, WithSettings(s))
+		err := app.Command().Run(context.Background(), []string{"rprompt", "generate", "-t", "main.tmpl", "-c", "main.json", "-o", filepath.Join(outDir, "out.txt")})
+		if err != nil {
+			return "", err
+		}
+		return readTestFile(t, outDir, "out.txt") + "\n" + stderr.String(), nil
+	}
+
+	output, err := generate(&settings.Settings{RegistryDir: tempDir, PII: "mask"})
+	require.NoError(t, err)
+	assert.Contains(t, output, "Call [PHONE]")
+	assert.Contains(t, output, "phone held likely PII (phone), masked before rendering")
+
+	_, err = generate(&settings.Settings{
+		CurrentRegistry: "vendor",
+		PII:             "mask",
+		Registries:      map[string]settings.Registry{"vendor": {Dir: tempDir, PII: "block"}},
+	})
+	assert.ErrorContains(t, err, "config holds likely PII in phone (phone)", "the registry's mode wins over the settings'")
+}
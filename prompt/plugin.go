@@ -0,0 +1,449 @@
+package prompt
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/notzree/rprompt/v2/prompt/core"
+)
+
+// Plugins add template functions, output formats and registry backends to rprompt without forking it. A plugin
+// is an executable that rprompt starts once and talks to over its stdin and stdout, one JSON object per line.
+// Every request carries an id, a method and its params:
+//
+//	{"id": 1, "method": "call", "params": {"function": "shout", "args": ["hi"]}}
+//
+// and the plugin answers each request in order with the same id and either a result or an error:
+//
+//	{"id": 1, "result": "HI!"}
+//	{"id": 2, "error": {"message": "no template at missing.tmpl", "code": "not_found"}}
+//
+// The methods are:
+//
+//   - manifest, sent first, returns a PluginManifest describing what the plugin provides
+//   - call {function, args} calls one of the manifest's functions and returns its value
+//   - format {format, result} renders a BuildResult in one of the manifest's formats and returns {output}
+//   - find {path} returns {content}, the source of the template at path
+//   - load_config {path} returns {config}, the values of the config at path
+//   - save_config {path, config} stores a config
+//
+// find, load_config and save_config are only sent to plugins whose manifest sets registry. A plugin exits when
+// its stdin is closed. Anything it writes to stderr is passed through, so plugins can log there.
+
+// PluginProtocol is the version of the plugin protocol this package speaks
+const PluginProtocol = 1
+
+// PluginErrNotFound is the error code plugin registries answer with when a template or config doesn't exist
+const PluginErrNotFound = "not_found"
+
+// pluginStartTimeout is how long a plugin has to answer with its manifest after it starts
+const pluginStartTimeout = 10 * time.Second
+
+// pluginCloseTimeout is how long a plugin has to exit after its stdin is closed before it is killed
+const pluginCloseTimeout = 5 * time.Second
+
+var (
+	pluginNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	// funcNamePattern matches the identifiers text/template accepts as function names
+	funcNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// PluginManifest describes what a plugin provides, see Plugin
+type PluginManifest struct {
+	// Protocol is the version of the protocol the plugin speaks, which must be PluginProtocol
+	Protocol int    `json:"protocol"`
+	Name     string `json:"name"`
+	Version  string `json:"version,omitempty"`
+	// Functions are the template functions the plugin adds
+	Functions []string `json:"functions,omitempty"`
+	// Formats are the output formats the plugin adds, see Plugin.Format
+	Formats []string `json:"formats,omitempty"`
+	// Registry is set by plugins that store templates and configs, see PluginRegistry
+	Registry bool `json:"registry,omitempty"`
+}
+
+// validate checks the manifest can be used without clashing with the built in functions and formats
+func (m *PluginManifest) validate() error {
+	if m.Protocol != PluginProtocol {
+		return fmt.Errorf("plugin speaks protocol %d, expected %d", m.Protocol, PluginProtocol)
+	}
+	if !pluginNamePattern.MatchString(m.Name) {
+		return fmt.Errorf("invalid plugin name %q, expected lowercase letters, digits, - and _", m.Name)
+	}
+	for _, name := range m.Functions {
+		if !funcNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid function name %q", name)
+		}
+		if core.BuiltinFunc(name) {
+			return fmt.Errorf("function %s is built in", name)
+		}
+	}
+	for _, format := range m.Formats {
+		if CheckFormat(format) == nil {
+			return fmt.Errorf("format %s is built in", format)
+		}
+	}
+	return nil
+}
+
+// PluginError is an error a plugin answered a request with
+type PluginError struct {
+	Message string `json:"message"`
+	// Code classifies the error, such as PluginErrNotFound
+	Code string `json:"code,omitempty"`
+}
+
+func (e *PluginError) Error() string {
+	return e.Message
+}
+
+// Is makes not_found errors match fs.ErrNotExist, so missing templates in a PluginRegistry are reported like
+// missing files
+func (e *PluginError) Is(target error) bool {
+	return e.Code == PluginErrNotFound && target == fs.ErrNotExist
+}
+
+type pluginRequest struct {
+	ID     int    `json:"id"`
+	Method string `json:"method"`
+	Params any    `json:"params,omitempty"`
+}
+
+type pluginResponse struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *PluginError    `json:"error"`
+}
+
+// Plugin is a running plugin process, see PluginProtocol. Its methods may be called concurrently, requests are
+// sent one at a time.
+type Plugin struct {
+	Path     string
+	Manifest PluginManifest
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	// exited is closed once the process has exited, with its exit status in waitErr
+	exited  chan struct{}
+	waitErr error
+
+	mu     sync.Mutex
+	nextID int
+	// broken is set once a request failed in a way that leaves the plugin's output out of step, after which
+	// every request fails with it
+	broken error
+}
+
+// StartPlugin starts the plugin executable at path and reads its manifest
+func StartPlugin(ctx context.Context, path string) (*Plugin, error) {
+	cmd := exec.Command(path)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", path, err)
+	}
+	p := &Plugin{Path: path, cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout), exited: make(chan struct{})}
+	go func() {
+		p.waitErr = cmd.Wait()
+		close(p.exited)
+	}()
+
+	// An executable that isn't a plugin may never answer
+	ctx, cancel := context.WithTimeout(ctx, pluginStartTimeout)
+	defer cancel()
+	if err := p.call(ctx, "manifest", nil, &p.Manifest); err != nil {
+		p.Close()
+		return nil, err
+	}
+	if err := p.Manifest.validate(); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// LoadPlugins starts every executable in dir. Plugins that fail to start are left out and their errors
+// joined, so one broken plugin doesn't disable the others. A missing dir has no plugins.
+func LoadPlugins(ctx context.Context, dir string) ([]*Plugin, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var plugins []*Plugin
+	var errs []error
+	for _, entry := range entries {
+		if !isPluginFile(entry) {
+			continue
+		}
+		p, err := StartPlugin(ctx, filepath.Join(dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		plugins = append(plugins, p)
+	}
+	return plugins, errors.Join(errs...)
+}
+
+// isPluginFile reports whether entry is an executable file. Windows has no executable bit, so every file
+// counts there.
+func isPluginFile(entry fs.DirEntry) bool {
+	if !entry.Type().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	info, err := entry.Info()
+	return err == nil && info.Mode().Perm()&0111 != 0
+}
+
+// InstallPlugin copies the plugin executable at path into dir, named after its manifest, and returns the
+// manifest. The plugin is started first, so a file that isn't a working plugin is never installed.
+func InstallPlugin(ctx context.Context, path, dir string) (*PluginManifest, error) {
+	p, err := StartPlugin(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	manifest := p.Manifest
+	p.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create plugins directory: %w", err)
+	}
+	name := manifest.Name
+	if runtime.GOOS == "windows" {
+		name += filepath.Ext(path)
+	}
+	// Write next to the destination and rename, so a running copy of the plugin is never overwritten in place
+	dest := filepath.Join(dir, name)
+	tmp := dest + ".tmp"
+	if err := os.WriteFile(tmp, data, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// call sends a request and decodes its result into result, unless result is nil. If ctx ends first the
+// plugin is killed, since its answer would be read as the answer to the next request.
+func (p *Plugin) call(ctx context.Context, method string, params, result any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.broken != nil {
+		return p.broken
+	}
+	p.nextID++
+	request, err := json.Marshal(pluginRequest{ID: p.nextID, Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", method, err)
+	}
+
+	var response pluginResponse
+	done := make(chan error, 1)
+	go func() {
+		if _, err := p.stdin.Write(append(request, '\n')); err != nil {
+			done <- err
+			return
+		}
+		line, err := p.stdout.ReadBytes('\n')
+		if err != nil {
+			done <- err
+			return
+		}
+		done <- json.Unmarshal(line, &response)
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		p.cmd.Process.Kill()
+		p.broken = fmt.Errorf("plugin %s was stopped: %w", p.Path, ctx.Err())
+		return p.broken
+	}
+	if err == nil && response.ID != p.nextID {
+		err = fmt.Errorf("answered request %d, expected %d", response.ID, p.nextID)
+	}
+	if err != nil {
+		p.broken = fmt.Errorf("plugin %s: %s: %w", p.Path, method, err)
+		return p.broken
+	}
+
+	if response.Error != nil {
+		return response.Error
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("plugin %s: invalid %s result: %w", p.Path, method, err)
+	}
+	return nil
+}
+
+// Funcs returns the plugin's template functions. Each takes any number of JSON encodable arguments and
+// returns the plugin's JSON result, so numbers come back as float64.
+func (p *Plugin) Funcs() template.FuncMap {
+	funcs := make(template.FuncMap, len(p.Manifest.Functions))
+	for _, name := range p.Manifest.Functions {
+		funcs[name] = func(args ...any) (any, error) {
+			if args == nil {
+				args = []any{}
+			}
+			// Template functions aren't given the build's context
+			var result any
+			if err := p.call(context.Background(), "call", map[string]any{"function": name, "args": args}, &result); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			return result, nil
+		}
+	}
+	return funcs
+}
+
+// HasFormat reports whether the plugin provides the output format
+func (p *Plugin) HasFormat(format string) bool {
+	for _, provided := range p.Manifest.Formats {
+		if provided == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Format renders a build result in one of the plugin's formats. Results built WithMessages give the plugin
+// the messages as well as the output.
+func (p *Plugin) Format(ctx context.Context, format string, result *BuildResult) (string, error) {
+	if !p.HasFormat(format) {
+		return "", fmt.Errorf("plugin %s has no format %q", p.Manifest.Name, format)
+	}
+	var formatted struct {
+		Output string `json:"output"`
+	}
+	if err := p.call(ctx, "format", map[string]any{"format": format, "result": result}, &formatted); err != nil {
+		return "", err
+	}
+	return formatted.Output, nil
+}
+
+// Close closes the plugin's stdin and waits for it to exit, killing it if it doesn't within a few seconds
+func (p *Plugin) Close() error {
+	p.stdin.Close()
+	select {
+	case <-p.exited:
+	case <-time.After(pluginCloseTimeout):
+		p.cmd.Process.Kill()
+		<-p.exited
+		return fmt.Errorf("plugin %s didn't exit and was killed", p.Path)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(p.waitErr, &exitErr) {
+		return fmt.Errorf("plugin %s: %w", p.Path, p.waitErr)
+	}
+	return nil
+}
+
+// ClosePlugins closes every plugin, joining their errors
+func ClosePlugins(plugins []*Plugin) error {
+	var errs []error
+	for _, p := range plugins {
+		errs = append(errs, p.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// PluginFuncs merges the template functions of plugins. Two plugins providing the same function is an error.
+func PluginFuncs(plugins []*Plugin) (template.FuncMap, error) {
+	funcs := template.FuncMap{}
+	provider := map[string]string{}
+	for _, p := range plugins {
+		for name, fn := range p.Funcs() {
+			if other, ok := provider[name]; ok {
+				return nil, fmt.Errorf("plugins %s and %s both provide function %s", other, p.Manifest.Name, name)
+			}
+			funcs[name], provider[name] = fn, p.Manifest.Name
+		}
+	}
+	return funcs, nil
+}
+
+// PluginRegistry is a PromptRegistry whose templates and configs are stored by a plugin, such as one reading
+// them from a database or a remote service. Its templates can call the plugin's functions. The CLI builds from
+// one when the registry it uses names the plugin, see settings.Registry.
+type PluginRegistry struct {
+	plugin *Plugin
+	funcs  template.FuncMap
+}
+
+// NewPluginRegistry uses the plugin as a registry. Its manifest must set registry.
+func NewPluginRegistry(p *Plugin) (*PluginRegistry, error) {
+	if !p.Manifest.Registry {
+		return nil, fmt.Errorf("plugin %s doesn't provide a registry", p.Manifest.Name)
+	}
+	return &PluginRegistry{plugin: p, funcs: p.Funcs()}, nil
+}
+
+// Find asks the plugin for the template at path
+func (r *PluginRegistry) Find(ctx context.Context, path string) (*Template, error) {
+	var found struct {
+		Content string `json:"content"`
+	}
+	if err := r.plugin.call(ctx, "find", map[string]string{"path": path}, &found); err != nil {
+		return nil, err
+	}
+	return NewTemplate(path, found.Content, r), nil
+}
+
+// LoadConfig asks the plugin for the config at path
+func (r *PluginRegistry) LoadConfig(ctx context.Context, path string) (*Config, error) {
+	var loaded struct {
+		Config map[string]any `json:"config"`
+	}
+	if err := r.plugin.call(ctx, "load_config", map[string]string{"path": path}, &loaded); err != nil {
+		return nil, err
+	}
+	if loaded.Config == nil {
+		loaded.Config = map[string]any{}
+	}
+	return NewConfig(loaded.Config, path), nil
+}
+
+// SaveConfig sends the config to the plugin to store
+func (r *PluginRegistry) SaveConfig(ctx context.Context, cfg *Config) error {
+	return r.plugin.call(ctx, "save_config", map[string]any{"path": cfg.Path, "config": cfg.Config}, nil)
+}
+
+// TemplateFuncs returns the plugin's functions, see FuncRegistry
+func (r *PluginRegistry) TemplateFuncs() template.FuncMap {
+	return r.funcs
+}
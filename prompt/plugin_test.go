@@ -0,0 +1,229 @@
+package prompt
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/notzree/rprompt/v2/prompt/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPluginProcess isn't a test but the plugin writeTestPlugin starts, run in a copy of the test binary
+func TestPluginProcess(t *testing.T) {
+	if os.Getenv("RPROMPT_TEST_PLUGIN") == "" {
+		t.Skip("only runs as a plugin")
+	}
+	manifest := PluginManifest{Protocol: PluginProtocol, Name: "shout", Version: "1.0.0", Functions: []string{"shout"}, Formats: []string{"upper"}, Registry: true}
+	if name := os.Getenv("RPROMPT_TEST_PLUGIN_NAME"); name != "" {
+		manifest.Name = name
+	}
+	out := json.NewEncoder(os.Stdout)
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var request struct {
+			ID     int             `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			os.Exit(2)
+		}
+		var params struct {
+			Function string         `json:"function"`
+			Args     []any          `json:"args"`
+			Format   string         `json:"format"`
+			Result   BuildResult    `json:"result"`
+			Path     string         `json:"path"`
+			Config   map[string]any `json:"config"`
+		}
+		json.Unmarshal(request.Params, &params)
+		var result any
+		var pluginErr *PluginError
+		switch request.Method {
+		case "manifest":
+			result = manifest
+		case "call":
+			result = strings.ToUpper(fmt.Sprint(params.Args...)) + "!"
+		case "format":
+			result = map[string]string{"output": fmt.Sprintf("%s (%d messages)", strings.ToUpper(params.Result.Output), len(params.Result.Messages))}
+		case "find":
+			if params.Path != "plugin.tmpl" {
+				pluginErr = &PluginError{Message: "no template at " + params.Path, Code: PluginErrNotFound}
+				break
+			}
+			result = map[string]string{"content": `[[shout .name]]`}
+		case "load_config":
+			result = map[string]any{"config": map[string]any{"name": "ana"}}
+		default:
+			pluginErr = &PluginError{Message: request.Method + " is not supported"}
+		}
+		out.Encode(map[string]any{"id": request.ID, "result": result, "error": pluginErr})
+	}
+	os.Exit(0)
+}
+
+// writeTestPlugin writes an executable into dir that runs TestPluginProcess as a plugin named name
+func writeTestPlugin(t *testing.T, dir, name string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("test plugins are shell scripts")
+	}
+	path := filepath.Join(dir, name)
+	script := fmt.Sprintf("#!/bin/sh\nRPROMPT_TEST_PLUGIN=1 RPROMPT_TEST_PLUGIN_NAME=%s exec %q -test.run='^TestPluginProcess$'\n", name, os.Args[0])
+	require.NoError(t, os.WriteFile(path, []byte(script), 0755))
+	return path
+}
+
+func TestPlugin(t *testing.T) {
+	ctx := context.Background()
+	p, err := StartPlugin(ctx, writeTestPlugin(t, t.TempDir(), "shout"))
+	require.NoError(t, err)
+	t.Cleanup(func() { p.Close() })
+	assert.Equal(t, "shout", p.Manifest.Name)
+	assert.Equal(t, []string{"shout"}, p.Manifest.Functions)
+
+	shout := p.Funcs()["shout"].(func(...any) (any, error))
+	result, err := shout("hi ", "there")
+	require.NoError(t, err)
+	assert.Equal(t, "HI THERE!", result)
+
+	output, err := p.Format(ctx, "upper", &BuildResult{Output: "hello", Messages: []Message{{Role: RoleUser, Content: "hello"}}})
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO (1 messages)", output)
+	_, err = p.Format(ctx, "lower", &BuildResult{})
+	assert.ErrorContains(t, err, `no format "lower"`)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = p.Format(canceled, "upper", &BuildResult{})
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = shout("again")
+	assert.ErrorContains(t, err, "was stopped", "a plugin interrupted mid request can't be used again")
+}
+
+func TestPluginManifest_Validate(t *testing.T) {
+	for manifest, expected := range map[*PluginManifest]string{
+		{Protocol: 2, Name: "x"}:                                     "protocol 2",
+		{Protocol: 1, Name: "My Plugin"}:                             "invalid plugin name",
+		{Protocol: 1, Name: "x", Functions: []string{"to-upper"}}:    `invalid function name "to-upper"`,
+		{Protocol: 1, Name: "x", Functions: []string{"role"}}:        "function role is built in",
+		{Protocol: 1, Name: "x", Formats: []string{FormatAnthropic}}: "format anthropic is built in",
+	} {
+		assert.ErrorContains(t, manifest.validate(), expected)
+	}
+	assert.NoError(t, (&PluginManifest{Protocol: 1, Name: "my-plugin_2", Functions: []string{"toUpper"}}).validate())
+}
+
+func TestLoadPlugins(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	plugins, err := LoadPlugins(ctx, filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, plugins)
+
+	writeTestPlugin(t, dir, "shout")
+	writeTestPlugin(t, dir, "loud")
+	createTestFile(t, dir, "README", "not executable, not a plugin")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken"), []byte("#!/bin/sh\nexit 1\n"), 0755))
+	plugins, err = LoadPlugins(ctx, dir)
+	assert.ErrorContains(t, err, "broken")
+	require.Len(t, plugins, 2)
+	defer ClosePlugins(plugins)
+
+	_, err = PluginFuncs(plugins)
+	assert.ErrorContains(t, err, "both provide function shout")
+	funcs, err := PluginFuncs(plugins[:1])
+	require.NoError(t, err)
+	assert.Contains(t, funcs, "shout")
+}
+
+func TestPluginRegistry(t *testing.T) {
+	ctx := context.Background()
+	p, err := StartPlugin(ctx, writeTestPlugin(t, t.TempDir(), "shout"))
+	require.NoError(t, err)
+	t.Cleanup(func() { p.Close() })
+	registry, err := NewPluginRegistry(p)
+	require.NoError(t, err)
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+
+	output, err := system.Build(ctx, "plugin.tmpl", "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, "ANA!", output, "the template and config come from the plugin and call its function")
+
+	_, err = system.Build(ctx, "missing.tmpl", "")
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+	assert.ErrorContains(t, registry.SaveConfig(ctx, NewConfig(map[string]any{}, "main.json")), "save_config is not supported")
+}
+
+func TestApp_Plugins(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "[[shout .name]]")
+	createTestFile(t, tempDir, "main.json", `{"name": "John"}`)
+	pluginsDir := filepath.Join(t.TempDir(), "plugins")
+	app, stdout, stderr := newTestApp(t,
+		WithRegistry(NewInMemPromptRegistry(tempDir)),
+		WithSettings(&settings.Settings{PluginsDir: pluginsDir}),
+	)
+	ctx := context.Background()
+	run := func(args ...string) error {
+		stdout.Reset()
+		return app.Command().Run(ctx, append([]string{"rprompt"}, args...))
+	}
+
+	require.NoError(t, run("plugins", "list"))
+	assert.Equal(t, "No plugins installed\n", stdout.String())
+	assert.ErrorContains(t, run("plugins", "install", filepath.Join(tempDir, "main.tmpl")), "failed to start plugin")
+
+	require.NoError(t, run("plugins", "install", writeTestPlugin(t, t.TempDir(), "shout")))
+	assert.FileExists(t, filepath.Join(pluginsDir, "shout"))
+	require.NoError(t, run("plugins", "list"))
+	assert.Equal(t, "shout\t1.0.0\tfunctions shout; formats upper; registry\n", stdout.String())
+	assert.Empty(t, app.plugins, "plugins are closed after every command")
+
+	outPath := filepath.Join(t.TempDir(), "out.txt")
+	require.NoError(t, run("generate", "-t", "main.tmpl", "-c", "main.json", "-o", outPath))
+	assert.Equal(t, "JOHN!", readTestFile(t, filepath.Dir(outPath), "out.txt"))
+	require.NoError(t, run("generate", "-t", "main.tmpl", "-c", "main.json", "--format", "upper", "-o", outPath))
+	assert.Equal(t, "JOHN! (1 messages)", readTestFile(t, filepath.Dir(outPath), "out.txt"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(pluginsDir, "broken"), []byte("#!/bin/sh\nexit 1\n"), 0755))
+	stderr.Reset()
+	require.NoError(t, run("list"))
+	require.NoError(t, run("--help"))
+	assert.Empty(t, stderr.String(), "commands that don't build prompts don't start plugins")
+	require.NoError(t, run("generate", "-t", "main.tmpl", "-c", "main.json", "-o", outPath))
+	assert.Contains(t, stderr.String(), "Failed to load plugins")
+}
+
+func TestApp_PluginRegistry(t *testing.T) {
+	pluginsDir := t.TempDir()
+	writeTestPlugin(t, pluginsDir, "shout")
+	app, stdout, _ := newTestApp(t, WithSettings(&settings.Settings{
+		PluginsDir: pluginsDir,
+		Registries: map[string]settings.Registry{"remote": {Plugin: "shout"}, "missing": {Plugin: "absent"}},
+	}))
+	ctx := context.Background()
+	run := func(args ...string) error {
+		stdout.Reset()
+		return app.Command().Run(ctx, append([]string{"rprompt"}, args...))
+	}
+
+	outPath := filepath.Join(t.TempDir(), "out.txt")
+	require.NoError(t, run("--registry", "remote", "generate", "-t", "plugin.tmpl", "-c", "main.json", "-o", outPath))
+	assert.Equal(t, "ANA!", readTestFile(t, filepath.Dir(outPath), "out.txt"), "the template and config come from the plugin")
+	assert.Nil(t, app.source, "the plugin registry is closed with the plugins")
+
+	assert.ErrorContains(t, run("--registry", "missing", "generate", "-t", "plugin.tmpl", "-c", "main.json", "-o", outPath), "plugin absent, which isn't installed")
+	assert.ErrorContains(t, run("--registry", "remote", "list"), "registry directory not set")
+	require.NoError(t, run("use", "remote"))
+	assert.Contains(t, stdout.String(), "Using registry remote (plugin shout)")
+}
@@ -0,0 +1,88 @@
+package prompt
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/notzree/rprompt/v2/prompt/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostProcess(t *testing.T) {
+	for _, tt := range []struct {
+		step, input, expected string
+	}{
+		{PostDedent, "    a\n      b\n\n    c", "a\n  b\n\nc"},
+		{PostDedent, "a\n  b", "a\n  b"},
+		{PostCollapseBlankLines, "a\n\n\n  \nb\n\nc", "a\n\nb\n\nc"},
+		{PostStripComments, "a\n<!-- note\nspanning lines -->\nb <!-- inline -->c\n[//]: # (hidden)\nd", "a\nb c\nd"},
+		{"max_width=10", "  one two three four\nshort", "  one two\n  three\n  four\nshort"},
+		{"max_width=3", "abcdef gh", "abcdef\ngh"},
+		{"suffix=\nAnswer in English.", "Hi", "Hi\nAnswer in English."},
+	} {
+		output, err := PostProcess(tt.input, []string{tt.step})
+		require.NoError(t, err, tt.step)
+		assert.Equal(t, tt.expected, output, tt.step)
+	}
+
+	for step, expected := range map[string]string{
+		"shout":           `unknown post-processor "shout"`,
+		"max_width=wide":  "max_width needs a positive width",
+		"suffix":          "suffix needs the text to append",
+		"dedent=4":        "dedent doesn't take a value",
+		"collapse_blanks": "unknown post-processor",
+	} {
+		_, err := PostProcess("", []string{step})
+		assert.ErrorContains(t, err, expected, step)
+	}
+}
+
+func TestPromptSystem_PostProcess(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `---
+post_process: [dedent, "suffix=\n-- end"]
+---
+    [[role "system"]]
+    You help.
+
+
+    [[role "user"]]
+    [[.question]]`)
+	registry := NewInMemPromptRegistry(tempDir)
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	system.PostProcess = []string{PostCollapseBlankLines}
+	ctx := context.Background()
+	question := WithValue("question", "Why?")
+
+	result, err := system.BuildWithResult(ctx, "main.tmpl", "", question, WithMessages())
+	require.NoError(t, err)
+	assert.Equal(t, "\nYou help.\n\nWhy?\n-- end", result.Output, "the front matter's steps and the system's both apply")
+	assert.Equal(t, []Message{{Role: RoleSystem, Content: "You help."}, {Role: RoleUser, Content: "Why?\n-- end"}}, result.Messages, "only the last message gets the suffix")
+
+	builder, err := system.NewBuilder(ctx, "main.tmpl", "", question)
+	require.NoError(t, err)
+	output, err := builder.Build(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, result.Output, output)
+
+	system.PostProcess = []string{"max_width=0"}
+	_, err = system.Build(ctx, "main.tmpl", "", question)
+	assert.ErrorContains(t, err, "main.tmpl: post-processor max_width needs a positive width")
+}
+
+func TestApp_GeneratePostProcess(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "a\n\n\n\nb")
+	createTestFile(t, tempDir, "main.json", `{}`)
+	app, _, _ := newTestApp(t,
+		WithRegistry(NewInMemPromptRegistry(tempDir)),
+		WithSettings(&settings.Settings{PostProcess: []string{PostCollapseBlankLines}}),
+	)
+	outDir := t.TempDir()
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "generate", "-t", "main.tmpl", "-c", "main.json", "-o", filepath.Join(outDir, "out.txt")}))
+	assert.Equal(t, "a\n\nb", readTestFile(t, outDir, "out.txt"))
+}
@@ -0,0 +1,71 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptSystem_Preview(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[role "system"]]Hi [[.user.name | upper]]![[$n := len .items]]
+[[range .items]]- [[.]]
+[[end]][[template "sig" .]]`)
+	createTestFile(t, tempDir, "sig.tmpl", `by [[.user.name]]`)
+	createTestFile(t, tempDir, "main.json", `{"user": {"name": "Ada"}, "items": ["a", "b"]}`)
+	registry := NewInMemPromptRegistry(tempDir)
+	registry.Funcs = map[string]any{"upper": func(s string) string { return s + "!" }}
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	preview, err := system.Preview(ctx, "main.tmpl", "main.json")
+	require.NoError(t, err)
+	built, err := system.Build(ctx, "main.tmpl", "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, built, preview.Output)
+	assert.Equal(t, "Hi Ada!!\n- a\n- b\nby Ada", preview.Output)
+
+	var texts []string
+	for _, region := range preview.Regions {
+		texts = append(texts, preview.Output[region.Start:region.End])
+	}
+	assert.Equal(t, []string{"Ada!", "a", "b", "Ada"}, texts, "the role marker and assignment write nothing")
+	assert.Equal(t, PreviewRegion{Start: 3, End: 7, Action: ".user.name | upper", Variables: []string{".user.name"}, Template: "main.tmpl", Line: 1, Column: 23}, preview.Regions[0])
+	assert.Equal(t, []string{"."}, preview.Regions[1].Variables)
+	assert.Equal(t, "sig.tmpl", preview.Regions[3].Template)
+
+	assert.Equal(t, "Hi «Ada!»!\n- «a»\n- «b»\nby «Ada»", preview.Highlight(false, false))
+	assert.Equal(t, "Hi «Ada!»⟨.user.name⟩!\n- «a»⟨.⟩\n- «b»⟨.⟩\nby «Ada»⟨.user.name⟩", preview.Highlight(false, true))
+	assert.True(t, strings.HasPrefix(preview.Highlight(true, true), "Hi "+colorCyan+"Ada!"+colorReset+colorGray+"⟨.user.name⟩"+colorReset+"!\n"))
+
+	again, err := system.Build(ctx, "main.tmpl", "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, built, again, "later builds aren't instrumented")
+}
+
+func TestApp_Preview(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello [[.name]], [[now.Year | printf "%d" | len]] digits`)
+	createTestFile(t, tempDir, "main.json", `{"name": "Ada"}`)
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	ctx := context.Background()
+
+	require.NoError(t, app.Command().Run(ctx, []string{"rprompt", "preview", "-t", "main.tmpl", "-c", "main.json", "--annotate"}))
+	assert.Equal(t, "Hello «Ada»⟨.name⟩, «4»⟨now.Year | printf \"%d\" | len⟩ digits\n", stdout.String())
+
+	stdout.Reset()
+	require.NoError(t, app.Command().Run(ctx, []string{"rprompt", "--json", "preview", "-t", "main.tmpl", "-c", "main.json"}))
+	var preview Preview
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &preview))
+	assert.Equal(t, "Hello Ada, 4 digits", preview.Output)
+	require.Len(t, preview.Regions, 2)
+	assert.Equal(t, []string{".name"}, preview.Regions[0].Variables)
+
+	err := app.Command().Run(ctx, []string{"rprompt", "preview", "-t", "missing.tmpl"})
+	assert.ErrorContains(t, err, "failed to preview prompt")
+}
@@ -0,0 +1,90 @@
+// Package prompttest locks prompt output in tests by comparing renders against golden files.
+package prompttest
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/notzree/rprompt/v2/prompt"
+)
+
+var update = flag.Bool("update", false, "write golden files from the current renders instead of comparing against them")
+
+// GoldenPath returns where RenderGolden keeps the golden file for a template and config:
+// testdata/<template>.<config>.golden, or testdata/<template>.golden without a config
+func GoldenPath(templatePath, configPath string) string {
+	name := strings.TrimSuffix(templatePath, ".tmpl")
+	if configPath != "" {
+		name += "." + strings.TrimSuffix(filepath.Base(configPath), ".json")
+	}
+	return filepath.Join("testdata", filepath.FromSlash(name)+".golden")
+}
+
+// RenderGolden builds the template with the config from registry and fails t if the output differs from its
+// golden file, see GoldenPath. It builds with prompt.WithDeterministic so dates and random choices in the
+// template render the same every run, and with prompt.WithAllowDraft so drafts can be tested. Run the tests with -update to write the golden files from the current
+// output.
+func RenderGolden(t testing.TB, registry prompt.PromptRegistry, templatePath, configPath string, opts ...prompt.BuildOption) {
+	t.Helper()
+	system, err := prompt.NewPromptSystem(registry)
+	if err != nil {
+		t.Fatalf("failed to create prompt system: %v", err)
+		return
+	}
+	output, err := system.Build(context.Background(), templatePath, configPath, append([]prompt.BuildOption{prompt.WithDeterministic(), prompt.WithAllowDraft()}, opts...)...)
+	if err != nil {
+		t.Fatalf("failed to render %s: %v", templatePath, err)
+		return
+	}
+
+	golden := GoldenPath(templatePath, configPath)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", filepath.Dir(golden), err)
+			return
+		}
+		if err := os.WriteFile(golden, []byte(output), 0644); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(golden)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("golden file %s doesn't exist, run the tests with -update to create it", golden)
+		return
+	}
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+		return
+	}
+	if string(want) != output {
+		t.Errorf("%s doesn't match %s:\n%s", templatePath, golden, diff(string(want), output))
+	}
+}
+
+// diff describes the first line where got differs from want
+func diff(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i >= len(wantLines) || i >= len(gotLines) || w != g {
+			return fmt.Sprintf("line %d:\n- %q\n+ %q", i+1, w, g)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+package prompttest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/notzree/rprompt/v2/prompt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder captures the failures RenderGolden reports
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+}
+
+// inDir runs the test from dir, where RenderGolden looks for testdata
+func inDir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestRenderGolden(t *testing.T) {
+	registryDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(registryDir, "main.tmpl"), []byte("Hello [[.name]]\nBye"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(registryDir, "world.json"), []byte(`{"name": "World"}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(registryDir, "moon.json"), []byte(`{"name": "Moon"}`), 0644))
+	registry := prompt.NewInMemPromptRegistry(registryDir)
+	inDir(t, t.TempDir())
+
+	// Missing golden files fail
+	r := &recorder{TB: t}
+	RenderGolden(r, registry, "main.tmpl", "world.json")
+	require.Len(t, r.failures, 1)
+	assert.Contains(t, r.failures[0], "run the tests with -update")
+
+	*update = true
+	RenderGolden(t, registry, "main.tmpl", "world.json")
+	*update = false
+	golden, err := os.ReadFile(GoldenPath("main.tmpl", "world.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello World\nBye", string(golden))
+
+	RenderGolden(t, registry, "main.tmpl", "world.json")
+
+	// A different render fails with the line that changed
+	require.NoError(t, os.WriteFile(GoldenPath("main.tmpl", "moon.json"), golden, 0644))
+	r = &recorder{TB: t}
+	RenderGolden(r, registry, "main.tmpl", "moon.json")
+	require.Len(t, r.failures, 1)
+	assert.Contains(t, r.failures[0], "line 1:\n- \"Hello World\"\n+ \"Hello Moon\"")
+}
+
+func TestGoldenPath(t *testing.T) {
+	assert.Equal(t, filepath.Join("testdata", "main.golden"), GoldenPath("main.tmpl", ""))
+	assert.Equal(t, filepath.Join("testdata", "agents", "coder.prod.golden"), GoldenPath("agents/coder.tmpl", "configs/prod.json"))
+}
@@ -0,0 +1,200 @@
+package prompttest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+
+	"github.com/notzree/rprompt/v2/prompt"
+)
+
+// Registry methods recorded by FakeRegistry
+const (
+	MethodFind         = "Find"
+	MethodLoadConfig   = "LoadConfig"
+	MethodSaveConfig   = "SaveConfig"
+	MethodOpenDocument = "OpenDocument"
+)
+
+// Call is a registry method FakeRegistry was called with
+type Call struct {
+	Method string
+	Path   string
+}
+
+// FakeRegistry is an in-memory prompt.PromptRegistry for tests. Templates, configs and documents are added
+// up front, any path can be made to fail, and every call is recorded. It is safe for concurrent use.
+type FakeRegistry struct {
+	mu        sync.Mutex
+	templates map[string]string
+	configs   map[string]map[string]any
+	documents map[string]string
+	errs      map[string]error
+	calls     []Call
+}
+
+// NewFakeRegistry returns an empty FakeRegistry
+func NewFakeRegistry() *FakeRegistry {
+	return &FakeRegistry{
+		templates: make(map[string]string),
+		configs:   make(map[string]map[string]any),
+		documents: make(map[string]string),
+		errs:      make(map[string]error),
+	}
+}
+
+// AddTemplate adds a template, replacing any at the same path
+func (r *FakeRegistry) AddTemplate(path, content string) *FakeRegistry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.templates[path] = content
+	return r
+}
+
+// AddConfig adds a config, replacing any at the same path. Loading it returns a copy of data.
+func (r *FakeRegistry) AddConfig(path string, data map[string]any) *FakeRegistry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.configs[path] = copyMap(data)
+	return r
+}
+
+// AddDocument adds a file for the document function to include
+func (r *FakeRegistry) AddDocument(path, content string) *FakeRegistry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.documents[path] = content
+	return r
+}
+
+// FailOn makes every call for path return err
+func (r *FakeRegistry) FailOn(path string, err error) *FakeRegistry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs[path] = err
+	return r
+}
+
+// Find returns the template added at path
+func (r *FakeRegistry) Find(ctx context.Context, path string) (*prompt.Template, error) {
+	content, err := r.lookup(ctx, MethodFind, path, r.templates)
+	if err != nil {
+		return nil, err
+	}
+	return prompt.NewTemplate(path, content, r), nil
+}
+
+// LoadConfig returns a copy of the config added or saved at path
+func (r *FakeRegistry) LoadConfig(ctx context.Context, path string) (*prompt.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record(ctx, MethodLoadConfig, path); err != nil {
+		return nil, err
+	}
+	data, ok := r.configs[path]
+	if !ok {
+		return nil, fmt.Errorf("config %s: %w", path, fs.ErrNotExist)
+	}
+	return prompt.NewConfig(copyMap(data), path), nil
+}
+
+// SaveConfig stores a copy of the config at its path
+func (r *FakeRegistry) SaveConfig(ctx context.Context, cfg *prompt.Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record(ctx, MethodSaveConfig, cfg.Path); err != nil {
+		return err
+	}
+	r.configs[cfg.Path] = copyMap(cfg.Config)
+	return nil
+}
+
+// OpenDocument returns the document added at path
+func (r *FakeRegistry) OpenDocument(ctx context.Context, path string) (io.ReadCloser, error) {
+	content, err := r.lookup(ctx, MethodOpenDocument, path, r.documents)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader([]byte(content))), nil
+}
+
+// Config returns the config at path, such as one saved through SaveConfig
+func (r *FakeRegistry) Config(path string) (map[string]any, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, ok := r.configs[path]
+	return copyMap(data), ok
+}
+
+// Calls returns every call made so far, in order
+func (r *FakeRegistry) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call{}, r.calls...)
+}
+
+// Count returns how many times method was called for path
+func (r *FakeRegistry) Count(method, path string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for _, call := range r.calls {
+		if call.Method == method && call.Path == path {
+			count++
+		}
+	}
+	return count
+}
+
+// lookup records a call and returns the content at path in contents
+func (r *FakeRegistry) lookup(ctx context.Context, method, path string, contents map[string]string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.record(ctx, method, path); err != nil {
+		return "", err
+	}
+	content, ok := contents[path]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", path, fs.ErrNotExist)
+	}
+	return content, nil
+}
+
+// record appends a call and returns the error it should fail with, if any. r.mu must be held.
+func (r *FakeRegistry) record(ctx context.Context, method, path string) error {
+	r.calls = append(r.calls, Call{Method: method, Path: path})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.errs[path]
+}
+
+// copyMap copies data along with the maps and slices nested in it, so tests can't change stored configs by
+// accident
+func copyMap(data map[string]any) map[string]any {
+	if data == nil {
+		return nil
+	}
+	copied := make(map[string]any, len(data))
+	for k, v := range data {
+		copied[k] = copyValue(v)
+	}
+	return copied
+}
+
+func copyValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		return copyMap(v)
+	case []any:
+		copied := make([]any, len(v))
+		for i, item := range v {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	}
+	return v
+}
@@ -0,0 +1,57 @@
+package prompttest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/notzree/rprompt/v2/prompt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeRegistry(t *testing.T) {
+	registry := NewFakeRegistry().
+		AddTemplate("main.tmpl", `[[template "header.tmpl" .]] Hello [[.user.name]] [[document "spec.md"]]`).
+		AddTemplate("header.tmpl", `# [[.title]]`).
+		AddConfig("main.json", map[string]any{"title": "Greeting", "user": map[string]any{"name": "World"}}).
+		AddDocument("spec.md", "Spec")
+	system, err := prompt.NewPromptSystem(registry)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	output, err := system.Build(ctx, "main.tmpl", "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, "# Greeting Hello World Spec", output)
+	assert.Positive(t, registry.Count(MethodFind, "header.tmpl"))
+	assert.Equal(t, Call{Method: MethodFind, Path: "main.tmpl"}, registry.Calls()[0])
+
+	// Loaded configs are copies
+	cfg, err := registry.LoadConfig(ctx, "main.json")
+	require.NoError(t, err)
+	cfg.Config["user"].(map[string]any)["name"] = "Changed"
+	stored, _ := registry.Config("main.json")
+	assert.Equal(t, "World", stored["user"].(map[string]any)["name"])
+
+	require.NoError(t, registry.SaveConfig(ctx, prompt.NewConfig(map[string]any{"a": 1}, "saved.json")))
+	saved, ok := registry.Config("saved.json")
+	require.True(t, ok)
+	assert.Equal(t, map[string]any{"a": 1}, saved)
+}
+
+func TestFakeRegistry_Errors(t *testing.T) {
+	failure := errors.New("registry down")
+	registry := NewFakeRegistry().
+		AddTemplate("main.tmpl", `[[template "header.tmpl" .]]`).
+		FailOn("header.tmpl", failure)
+	system, err := prompt.NewPromptSystem(registry)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = system.Build(ctx, "main.tmpl", "")
+	assert.ErrorIs(t, err, failure)
+	_, err = system.Build(ctx, "missing.tmpl", "")
+	assert.ErrorIs(t, err, prompt.ErrTemplateNotFound)
+	_, err = registry.LoadConfig(ctx, "missing.json")
+	assert.Error(t, err)
+}
@@ -0,0 +1,306 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/notzree/rprompt/v2/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// PromptTestSuffix ends the files rprompt test runs, kept next to the templates they test:
+//
+//	# greeting.prompttest.yaml
+//	cases:
+//	  - name: greets by name
+//	    config: configs/alice.json
+//	    data: {tone: formal}
+//	    contains: ["Hello Alice"]
+//	    not_contains: ["<no value>"]
+//	    matches: ["^Dear "]
+//	  - name: fails without a name
+//	    error: missing config values
+const PromptTestSuffix = ".prompttest.yaml"
+
+// PromptTestFile is a file of test cases for a template
+type PromptTestFile struct {
+	// Template is the registry path of the template under test. It defaults to the file's path with
+	// PromptTestSuffix replaced by .tmpl.
+	Template string           `yaml:"template"`
+	Cases    []PromptTestCase `yaml:"cases"`
+}
+
+// PromptTestCase renders the template with a config and checks the output
+type PromptTestCase struct {
+	Name string `yaml:"name"`
+	// Config is the registry path of the config to build with. It may be empty when Data has every value.
+	Config string `yaml:"config"`
+	// Data is layered over Config, as with WithData
+	Data map[string]any `yaml:"data"`
+	// Contains and NotContains are substrings the output must and must not contain
+	Contains    []string `yaml:"contains"`
+	NotContains []string `yaml:"not_contains"`
+	// Matches are regular expressions the output must match
+	Matches []string `yaml:"matches"`
+	// JSON maps dotted paths into the output, parsed as a JSON object, to the values they must hold
+	JSON map[string]any `yaml:"json"`
+	// Error is a substring of the error the build must fail with. The other checks are skipped.
+	Error string `yaml:"error"`
+}
+
+// PromptTestResult is the outcome of one test case
+type PromptTestResult struct {
+	File     string        `json:"file"`
+	Template string        `json:"template"`
+	Name     string        `json:"name"`
+	Passed   bool          `json:"passed"`
+	Failures []string      `json:"failures,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// PromptTestReport is the outcome of every test case in a registry
+type PromptTestReport struct {
+	Tests   int                `json:"tests"`
+	Failed  int                `json:"failed"`
+	Results []PromptTestResult `json:"results"`
+	// Coverage is the branches the cases rendered, when they were run with WithCoverage
+	Coverage *CoverageReport `json:"coverage,omitempty"`
+}
+
+// RunPromptTests runs the cases in every PromptTestSuffix file in the registry, building each with opts.
+// Cases build with WithDeterministic so their expectations can include dates and shuffled lists. A file that can't be read or
+// parsed fails as a single case.
+func RunPromptTests(ctx context.Context, r *LocalPromptRegistry, opts ...BuildOption) (*PromptTestReport, error) {
+	files, err := r.listFiles(PromptTestSuffix)
+	if err != nil {
+		return nil, err
+	}
+	system, err := NewPromptSystem(r)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PromptTestReport{Results: []PromptTestResult{}}
+	add := func(result PromptTestResult) {
+		result.Passed = len(result.Failures) == 0
+		report.Tests++
+		if !result.Passed {
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+	}
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		suite, err := loadPromptTestFile(r, file)
+		if err != nil {
+			add(PromptTestResult{File: file, Name: file, Failures: []string{err.Error()}})
+			continue
+		}
+		for i, tc := range suite.Cases {
+			name := tc.Name
+			if name == "" {
+				name = fmt.Sprintf("case %d", i+1)
+			}
+			start := time.Now()
+			failures := tc.run(ctx, system, suite.Template, opts)
+			add(PromptTestResult{
+				File:     file,
+				Template: suite.Template,
+				Name:     name,
+				Failures: failures,
+				Duration: time.Since(start),
+			})
+		}
+	}
+	return report, nil
+}
+
+// loadPromptTestFile reads and parses a test file, defaulting its template
+func loadPromptTestFile(r *LocalPromptRegistry, file string) (*PromptTestFile, error) {
+	fullPath, err := r.ResolvePath(file)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	suite := &PromptTestFile{}
+	if err := yaml.Unmarshal(data, suite); err != nil {
+		return nil, fmt.Errorf("invalid test file %s: %w", file, err)
+	}
+	if suite.Template == "" {
+		suite.Template = strings.TrimSuffix(file, PromptTestSuffix) + ".tmpl"
+	}
+	return suite, nil
+}
+
+// run builds the case and returns why it failed, if it did
+func (tc *PromptTestCase) run(ctx context.Context, system *PromptSystem, template string, opts []BuildOption) []string {
+	// Drafts are tested before they are published
+	opts = append([]BuildOption{WithData(tc.Data), WithDeterministic(), WithAllowDraft()}, opts...)
+	output, err := system.Build(ctx, template, tc.Config, opts...)
+	if tc.Error != "" {
+		switch {
+		case err == nil:
+			return []string{fmt.Sprintf("expected an error containing %q, but the build succeeded", tc.Error)}
+		case !strings.Contains(err.Error(), tc.Error):
+			return []string{fmt.Sprintf("expected an error containing %q, got: %v", tc.Error, err)}
+		}
+		return nil
+	}
+	if err != nil {
+		return []string{fmt.Sprintf("build failed: %v", err)}
+	}
+
+	var failures []string
+	for _, s := range tc.Contains {
+		if !strings.Contains(output, s) {
+			failures = append(failures, fmt.Sprintf("output doesn't contain %q", s))
+		}
+	}
+	for _, s := range tc.NotContains {
+		if strings.Contains(output, s) {
+			failures = append(failures, fmt.Sprintf("output contains %q", s))
+		}
+	}
+	for _, pattern := range tc.Matches {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("invalid pattern %q: %v", pattern, err))
+			continue
+		}
+		if !re.MatchString(output) {
+			failures = append(failures, fmt.Sprintf("output doesn't match %q", pattern))
+		}
+	}
+	if len(tc.JSON) > 0 {
+		failures = append(failures, checkJSON(output, tc.JSON)...)
+	}
+	return failures
+}
+
+// checkJSON checks the values at dotted paths of output parsed as a JSON object. Expected values go through
+// JSON too, so YAML's integers compare equal to JSON's numbers.
+func checkJSON(output string, expected map[string]any) []string {
+	var actual map[string]any
+	if err := json.Unmarshal([]byte(output), &actual); err != nil {
+		return []string{fmt.Sprintf("output isn't a JSON object: %v", err)}
+	}
+	paths := make([]string, 0, len(expected))
+	for p := range expected {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var failures []string
+	for _, p := range paths {
+		want, err := normalizeJSON(expected[p])
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("invalid expected value at %s: %v", p, err))
+			continue
+		}
+		got, ok := utils.GetPath(actual, p)
+		if !ok {
+			failures = append(failures, fmt.Sprintf("output has no value at %s", p))
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			failures = append(failures, fmt.Sprintf("output has %v at %s, expected %v", got, p, want))
+		}
+	}
+	return failures
+}
+
+// normalizeJSON round trips v through JSON
+func normalizeJSON(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var normalized any
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
+
+// junitTestSuites is the root of a JUnit XML report
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Tests   int              `xml:"tests,attr"`
+	Failed  int              `xml:"failures,attr"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name   string          `xml:"name,attr"`
+	Tests  int             `xml:"tests,attr"`
+	Failed int             `xml:"failures,attr"`
+	Time   string          `xml:"time,attr"`
+	Cases  []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML, with a test suite per test file, for CI systems to display
+func WriteJUnit(w io.Writer, report *PromptTestReport) error {
+	root := junitTestSuites{Tests: report.Tests, Failed: report.Failed}
+	suites := make(map[string]int)
+	var durations []time.Duration
+	for _, result := range report.Results {
+		i, ok := suites[result.File]
+		if !ok {
+			i = len(root.Suites)
+			suites[result.File] = i
+			root.Suites = append(root.Suites, junitTestSuite{Name: result.File})
+			durations = append(durations, 0)
+		}
+		suite := &root.Suites[i]
+		suite.Tests++
+		durations[i] += result.Duration
+		tc := junitTestCase{Name: result.Name, ClassName: result.Template, Time: seconds(result.Duration)}
+		if !result.Passed {
+			suite.Failed++
+			tc.Failure = &junitFailure{Message: result.Failures[0], Text: strings.Join(result.Failures, "\n")}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	for i := range root.Suites {
+		root.Suites[i].Time = seconds(durations[i])
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// seconds formats d the way JUnit reports times
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,105 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const greetingTests = `cases:
+  - name: greets by name
+    config: alice.json
+    contains: ["Hello Alice"]
+    not_contains: ["<no value>"]
+    matches: ["^Hello "]
+  - name: data overrides config
+    config: alice.json
+    data: {name: Bob}
+    contains: ["Hello Bob"]
+  - name: wrong expectation
+    data: {name: Carol}
+    contains: ["Hello Dave"]
+    matches: ["^Bye"]
+  - name: fails without a name
+    error: missing config values
+`
+
+func TestRunPromptTests(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "greeting.tmpl", `Hello [[.name]]`)
+	createTestFile(t, tempDir, "alice.json", `{"name": "Alice"}`)
+	createTestFile(t, tempDir, "greeting"+PromptTestSuffix, greetingTests)
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "api"), 0755))
+	createTestFile(t, tempDir, "api/reply.tmpl", `{"user": {"name": "[[.name]]"}, "count": [[.count]]}`)
+	createTestFile(t, tempDir, "api/checks"+PromptTestSuffix, `template: api/reply.tmpl
+cases:
+  - data: {name: Alice, count: 2}
+    json: {user.name: Alice, count: 2}
+  - data: {name: Alice, count: 2}
+    json: {user.email: a@example.com}
+`)
+	createTestFile(t, tempDir, "broken"+PromptTestSuffix, `cases: [`)
+
+	report, err := RunPromptTests(context.Background(), NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	assert.Equal(t, 7, report.Tests)
+	assert.Equal(t, 3, report.Failed)
+
+	byName := make(map[string]PromptTestResult)
+	for _, result := range report.Results {
+		byName[result.Name] = result
+	}
+	assert.True(t, byName["case 1"].Passed, byName["case 1"].Failures)
+	assert.Equal(t, []string{"output has no value at user.email"}, byName["case 2"].Failures)
+	assert.True(t, byName["greets by name"].Passed, byName["greets by name"].Failures)
+	assert.True(t, byName["data overrides config"].Passed, byName["data overrides config"].Failures)
+	assert.True(t, byName["fails without a name"].Passed, byName["fails without a name"].Failures)
+	assert.Equal(t, []string{`output doesn't contain "Hello Dave"`, `output doesn't match "^Bye"`}, byName["wrong expectation"].Failures)
+	assert.Contains(t, byName["broken"+PromptTestSuffix].Failures[0], "invalid test file")
+	assert.Equal(t, "greeting.tmpl", byName["greets by name"].Template)
+}
+
+func TestWriteJUnit(t *testing.T) {
+	report := &PromptTestReport{
+		Tests:  2,
+		Failed: 1,
+		Results: []PromptTestResult{
+			{File: "a" + PromptTestSuffix, Template: "a.tmpl", Name: "passes", Passed: true},
+			{File: "a" + PromptTestSuffix, Template: "a.tmpl", Name: "fails", Failures: []string{"first", "second"}},
+		},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, WriteJUnit(&buf, report))
+
+	var parsed junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &parsed))
+	require.Len(t, parsed.Suites, 1)
+	assert.Equal(t, 2, parsed.Suites[0].Tests)
+	assert.Equal(t, 1, parsed.Suites[0].Failed)
+	assert.Nil(t, parsed.Suites[0].Cases[0].Failure)
+	assert.Equal(t, "first", parsed.Suites[0].Cases[1].Failure.Message)
+	assert.Equal(t, "first\nsecond", parsed.Suites[0].Cases[1].Failure.Text)
+}
+
+func TestApp_Test(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "greeting.tmpl", `Hello [[.name]]`)
+	createTestFile(t, tempDir, "greeting"+PromptTestSuffix, "cases:\n  - data: {name: Alice}\n    contains: [Alice]\n")
+	junit := filepath.Join(t.TempDir(), "junit.xml")
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "test", "--junit", junit}))
+	assert.Contains(t, stdout.String(), "1 tests passed")
+	_, err := os.Stat(junit)
+	assert.NoError(t, err)
+
+	createTestFile(t, tempDir, "greeting"+PromptTestSuffix, "cases:\n  - data: {name: Alice}\n    contains: [Bob]\n")
+	err = app.Command().Run(context.Background(), []string{"rprompt", "test"})
+	assert.ErrorContains(t, err, "1 of 1 tests failed")
+}
@@ -0,0 +1,79 @@
+package prompt
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithProvenance(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hello [[.name]][[template "sig.tmpl"]]`)
+	createTestFile(t, tempDir, "sig.tmpl", `!`)
+	createTestFile(t, tempDir, "main.json", `{"name": "Ada"}`)
+	registry := NewInMemPromptRegistry(tempDir)
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	result, err := system.BuildWithResult(ctx, "main.tmpl", "main.json", WithProvenance(ProvenanceHeader), WithDeterministic())
+	require.NoError(t, err)
+	header, body, ok := strings.Cut(result.Output, "\n")
+	require.True(t, ok)
+	assert.Equal(t, "Hello Ada!", body)
+	provenance, err := ParseProvenance(header)
+	require.NoError(t, err)
+	assert.Equal(t, result.Provenance, provenance)
+	assert.Equal(t, "main.tmpl", provenance.Template)
+	assert.Equal(t, "main.json", provenance.Config)
+	assert.Equal(t, DeterministicTime, provenance.RenderedAt)
+	assert.Len(t, provenance.Version, 64)
+	assert.Len(t, provenance.ConfigHash, 64)
+
+	changed, err := system.BuildWithResult(ctx, "main.tmpl", "main.json", WithProvenance(ProvenanceHeader), WithDeterministic(), WithValue("name", "Grace"))
+	require.NoError(t, err)
+	assert.Equal(t, provenance.Version, changed.Provenance.Version)
+	assert.NotEqual(t, provenance.ConfigHash, changed.Provenance.ConfigHash)
+	createTestFile(t, tempDir, "sig.tmpl", `?`)
+	changed, err = system.BuildWithResult(ctx, "main.tmpl", "main.json", WithProvenance(ProvenanceHeader), WithDeterministic())
+	require.NoError(t, err)
+	assert.NotEqual(t, provenance.Version, changed.Provenance.Version, "the version covers included templates")
+
+	builder, err := system.NewBuilder(ctx, "main.tmpl", "main.json", WithProvenance(ProvenanceFooter), WithDeterministic())
+	require.NoError(t, err)
+	output, err := builder.Build(ctx)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(output, "Hello Ada?\n<!-- rprompt-provenance {"), output)
+	assert.True(t, strings.HasSuffix(output, " -->\n"), output)
+	footer, err := ParseProvenance(output)
+	require.NoError(t, err)
+	assert.Equal(t, changed.Provenance, footer)
+
+	_, err = ParseProvenance("Hello Ada")
+	assert.EqualError(t, err, "no rprompt provenance comment found")
+	_, err = ParseProvenancePosition("middle")
+	assert.ErrorContains(t, err, `unknown provenance position "middle"`)
+}
+
+func TestApp_GenerateProvenance(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Hi`)
+	createTestFile(t, tempDir, "main.json", `{}`)
+	app, _, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	outDir := t.TempDir()
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "generate", "-t", "main.tmpl", "-c", "main.json", "-o", filepath.Join(outDir, "out.txt"), "--provenance", "footer"}))
+	output := readTestFile(t, outDir, "out.txt")
+	assert.True(t, strings.HasPrefix(output, "Hi\n<!-- rprompt-provenance "), output)
+	provenance, err := ParseProvenance(output)
+	require.NoError(t, err)
+	assert.Equal(t, "main.tmpl", provenance.Template)
+	assert.False(t, provenance.RenderedAt.IsZero())
+
+	err = app.Command().Run(context.Background(), []string{"rprompt", "generate", "-t", "main.tmpl", "-c", "main.json", "-o", filepath.Join(outDir, "out.txt"), "--provenance", "inline"})
+	assert.ErrorContains(t, err, "unknown provenance position")
+}
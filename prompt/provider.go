@@ -0,0 +1,230 @@
+package prompt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Providers the run command can send prompts to
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+)
+
+// defaultMaxTokens is used when a completion request doesn't set MaxTokens but the provider requires it
+const defaultMaxTokens = 1024
+
+// CompletionRequest is a rendered prompt to send to an LLM
+type CompletionRequest struct {
+	Model    string
+	Messages []Message
+	// MaxTokens caps the completion length; 0 uses the provider's default
+	MaxTokens int
+}
+
+// Provider sends prompts to an LLM API
+type Provider interface {
+	// Stream sends the request and writes the completion to w as it arrives
+	Stream(ctx context.Context, req CompletionRequest, w io.Writer) error
+}
+
+// ProviderConfig configures how a provider reaches its API
+type ProviderConfig struct {
+	APIKey string
+	// BaseURL overrides the provider's API address, e.g. for proxies or compatible servers
+	BaseURL string
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+func (c ProviderConfig) client() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c ProviderConfig) baseURL(fallback string) string {
+	if c.BaseURL != "" {
+		return strings.TrimSuffix(c.BaseURL, "/")
+	}
+	return fallback
+}
+
+// NewProvider creates a provider by name. The API key is read from OPENAI_API_KEY or ANTHROPIC_API_KEY,
+// and OPENAI_BASE_URL or ANTHROPIC_BASE_URL override the API address when set.
+func NewProvider(name string) (Provider, error) {
+	envPrefix := strings.ToUpper(name)
+	cfg := ProviderConfig{
+		APIKey:  os.Getenv(envPrefix + "_API_KEY"),
+		BaseURL: os.Getenv(envPrefix + "_BASE_URL"),
+	}
+	switch name {
+	case ProviderOpenAI:
+		return NewOpenAIProvider(cfg)
+	case ProviderAnthropic:
+		return NewAnthropicProvider(cfg)
+	default:
+		return nil, fmt.Errorf("unknown provider %q, expected one of: %s, %s", name, ProviderOpenAI, ProviderAnthropic)
+	}
+}
+
+type openAIProvider struct {
+	cfg ProviderConfig
+}
+
+// NewOpenAIProvider creates a provider for the OpenAI chat completions API
+func NewOpenAIProvider(cfg ProviderConfig) (Provider, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("no API key set for %s, set OPENAI_API_KEY", ProviderOpenAI)
+	}
+	return &openAIProvider{cfg: cfg}, nil
+}
+
+func (p *openAIProvider) Stream(ctx context.Context, req CompletionRequest, w io.Writer) error {
+	body := map[string]any{
+		"model":    req.Model,
+		"messages": req.Messages,
+		"stream":   true,
+	}
+	if req.MaxTokens > 0 {
+		body["max_tokens"] = req.MaxTokens
+	}
+	headers := map[string]string{"Authorization": "Bearer " + p.cfg.APIKey}
+	url := p.cfg.baseURL("https://api.openai.com/v1") + "/chat/completions"
+
+	return streamEvents(ctx, p.cfg.client(), ProviderOpenAI, url, headers, body, func(data []byte) (bool, error) {
+		if string(data) == "[DONE]" {
+			return true, nil
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return false, fmt.Errorf("err decoding %s stream: %w", ProviderOpenAI, err)
+		}
+		for _, choice := range chunk.Choices {
+			if _, err := io.WriteString(w, choice.Delta.Content); err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	})
+}
+
+type anthropicProvider struct {
+	cfg ProviderConfig
+}
+
+// NewAnthropicProvider creates a provider for the Anthropic Messages API
+func NewAnthropicProvider(cfg ProviderConfig) (Provider, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("no API key set for %s, set ANTHROPIC_API_KEY", ProviderAnthropic)
+	}
+	return &anthropicProvider{cfg: cfg}, nil
+}
+
+func (p *anthropicProvider) Stream(ctx context.Context, req CompletionRequest, w io.Writer) error {
+	prompt := NewAnthropicRequest(req.Messages)
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+	body := map[string]any{
+		"model":      req.Model,
+		"messages":   prompt.Messages,
+		"max_tokens": maxTokens,
+		"stream":     true,
+	}
+	if prompt.System != "" {
+		body["system"] = prompt.System
+	}
+	headers := map[string]string{
+		"x-api-key":         p.cfg.APIKey,
+		"anthropic-version": "2023-06-01",
+	}
+	url := p.cfg.baseURL("https://api.anthropic.com") + "/v1/messages"
+
+	return streamEvents(ctx, p.cfg.client(), ProviderAnthropic, url, headers, body, func(data []byte) (bool, error) {
+		var event struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Text string `json:"text"`
+			} `json:"delta"`
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			return false, fmt.Errorf("err decoding %s stream: %w", ProviderAnthropic, err)
+		}
+		switch event.Type {
+		case "content_block_delta":
+			_, err := io.WriteString(w, event.Delta.Text)
+			return false, err
+		case "message_stop":
+			return true, nil
+		case "error":
+			return false, fmt.Errorf("%s stream error: %s", ProviderAnthropic, event.Error.Message)
+		}
+		return false, nil
+	})
+}
+
+// streamEvents posts body as JSON and passes the data of every server-sent event to handle until it reports
+// the stream is done or the response ends
+func streamEvents(ctx context.Context, client *http.Client, provider, url string, headers map[string]string, body any, handle func(data []byte) (bool, error)) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("err encoding %s request: %w", provider, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("err calling %s: %w", provider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s returned %s: %s", provider, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := bytes.CutPrefix(scanner.Bytes(), []byte("data:"))
+		if !ok {
+			continue
+		}
+		done, err := handle(bytes.TrimSpace(data))
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("err reading %s stream: %w", provider, err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSSEServer serves events as a server-sent event stream, recording the request it received
+func newSSEServer(t *testing.T, events []string, request *map[string]any, headers *http.Header) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*headers = r.Header.Clone()
+		require.NoError(t, json.NewDecoder(r.Body).Decode(request))
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenAIProvider_Stream(t *testing.T) {
+	var request map[string]any
+	var headers http.Header
+	server := newSSEServer(t, []string{
+		`{"choices":[{"delta":{"content":"Hello"}}]}`,
+		`{"choices":[{"delta":{"content":" there"}}]}`,
+		`[DONE]`,
+	}, &request, &headers)
+
+	provider, err := NewOpenAIProvider(ProviderConfig{APIKey: "key", BaseURL: server.URL})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	err = provider.Stream(context.Background(), CompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []Message{{Role: RoleSystem, Content: "Be brief."}, {Role: RoleUser, Content: "Hi"}},
+	}, &buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Hello there", buf.String())
+	assert.Equal(t, "Bearer key", headers.Get("Authorization"))
+	assert.Equal(t, "gpt-4o", request["model"])
+	assert.Equal(t, true, request["stream"])
+	assert.Len(t, request["messages"], 2)
+	assert.NotContains(t, request, "max_tokens")
+}
+
+func TestAnthropicProvider_Stream(t *testing.T) {
+	var request map[string]any
+	var headers http.Header
+	server := newSSEServer(t, []string{
+		`{"type":"message_start"}`,
+		`{"type":"content_block_delta","delta":{"type":"text_delta","text":"Ahoy"}}`,
+		`{"type":"message_stop"}`,
+	}, &request, &headers)
+
+	provider, err := NewAnthropicProvider(ProviderConfig{APIKey: "key", BaseURL: server.URL})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	err = provider.Stream(context.Background(), CompletionRequest{
+		Model:    "claude",
+		Messages: []Message{{Role: RoleSystem, Content: "Be brief."}, {Role: RoleUser, Content: "Hi"}},
+	}, &buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Ahoy", buf.String())
+	assert.Equal(t, "key", headers.Get("x-api-key"))
+	assert.Equal(t, "Be brief.", request["system"])
+	assert.Equal(t, float64(defaultMaxTokens), request["max_tokens"])
+	assert.Equal(t, []any{map[string]any{"role": "user", "content": "Hi"}}, request["messages"])
+}
+
+func TestProvider_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "bad key"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	provider, err := NewOpenAIProvider(ProviderConfig{APIKey: "key", BaseURL: server.URL})
+	require.NoError(t, err)
+	err = provider.Stream(context.Background(), CompletionRequest{Model: "gpt-4o"}, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "401 Unauthorized")
+	assert.ErrorContains(t, err, "bad key")
+
+	_, err = NewAnthropicProvider(ProviderConfig{})
+	assert.ErrorContains(t, err, "ANTHROPIC_API_KEY")
+	_, err = NewProvider("llama")
+	assert.ErrorContains(t, err, `unknown provider "llama"`)
+}
@@ -0,0 +1,51 @@
+package prompt
+
+import (
+	"context"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate_Validate(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "header.tmpl", `Header`)
+	registry := NewInMemPromptRegistry(tempDir)
+	registry.Funcs = template.FuncMap{"shout": func(s string) string { return s }}
+	ctx := context.Background()
+	validate := func(content string) []*TemplateError {
+		t.Helper()
+		problems, err := NewTemplate("main.tmpl", content, registry).Validate(ctx)
+		require.NoError(t, err)
+		return problems
+	}
+
+	assert.Empty(t, validate(`---
+owners: [ada]
+---
+[[template "header"]][[define "row"]][[shout .name | printf "%s"]][[end]][[template "row" .]][[/* ]] */]][[ "]]" ]]`))
+
+	problems := validate("---\nowners: [ada]\n---\n[[template \"header.tmpl\"]]\n[[template \"footer\"]] [[upper .name]]\n[[if .x]][[lower .y]][[end]]")
+	require.Len(t, problems, 3)
+	assert.Equal(t, "main.tmpl:5:12: template \"footer\" not found in the registry", problems[0].Error())
+	assert.Equal(t, "main.tmpl:5:25: function \"upper\" not defined", problems[1].Error())
+	assert.Equal(t, "main.tmpl:6:12: function \"lower\" not defined", problems[2].Error())
+	assert.Equal(t, "5 | [[template \"footer\"]] [[upper .name]]\n  |                         ^", problems[1].Snippet)
+
+	problems = validate("Hello]] [[.name]]\n[[.missing")
+	require.Len(t, problems, 2)
+	assert.Equal(t, `main.tmpl:1:6: unexpected "]]" outside an action`, problems[0].Error())
+	assert.Equal(t, `main.tmpl:2:1: unclosed action, missing "]]"`, problems[1].Error())
+
+	problems = validate(`[[if .x]]unterminated`)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Error(), "main.tmpl:1: unexpected EOF")
+
+	registry.LeftDelim, registry.RightDelim = "{{", "}}"
+	assert.Empty(t, validate(`]] {{template "header"}}`))
+
+	_, err := NewTemplate("main.tmpl", "Hi", nil).Validate(ctx)
+	assert.EqualError(t, err, "no registry set for template main.tmpl")
+}
@@ -1,20 +1,39 @@
 package prompt
 
 import (
+	"context"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
-)
+	"sync"
+	"text/template"
 
-type PromptRegistry interface {
-	Find(path string) (*Template, error)
-	LoadConfig(path string) (*Config, error)
-	SaveConfig(cfg *Config) error
-}
+	"github.com/notzree/rprompt/v2/prompt/core"
+)
 
 type LocalPromptRegistry struct {
 	Directory string
+	// LeftDelim and RightDelim replace the default action delimiters of the registry's templates when both
+	// are set
+	LeftDelim  string
+	RightDelim string
+	// Funcs are extra functions the registry's templates can call, see FuncRegistry
+	Funcs template.FuncMap
+	// PII is the PII mode systems created for the registry with NewPromptSystem build its templates with, see
+	// PromptSystem.PII
+	PII PIIMode
+	// Extensions are the accepted extensions of the registry's template files, nil for
+	// DefaultTemplateExtension alone, see ExtensionRegistry
+	Extensions []string
+
+	mu        sync.Mutex
+	listeners []func(path string)
+	// index is read from IndexFile the first time it is needed, see WriteIndex
+	index       *RegistryIndex
+	indexLoaded bool
 }
 
 func NewInMemPromptRegistry(Directory string) *LocalPromptRegistry {
@@ -23,13 +42,64 @@ func NewInMemPromptRegistry(Directory string) *LocalPromptRegistry {
 	}
 }
 
-func (r *LocalPromptRegistry) Find(path string) (*Template, error) {
-	dir := r.Directory
-	if !strings.HasSuffix(path, ".tmpl") {
-		return nil, fmt.Errorf("template file must have .tmpl extension: %s", path)
+// NewPromptSystem creates a system building prompts from registry, see core.NewPromptSystem. A
+// *LocalPromptRegistry's PII mode becomes the system's.
+func NewPromptSystem(registry PromptRegistry) (*PromptSystem, error) {
+	s, err := core.NewPromptSystem(registry)
+	if err != nil {
+		return nil, err
+	}
+	if local, ok := registry.(*LocalPromptRegistry); ok {
+		s.PII = local.PII
+	}
+	return s, nil
+}
+
+// Delimiters returns the registry's action delimiters, see DelimitedRegistry
+func (r *LocalPromptRegistry) Delimiters() (string, string) {
+	return r.LeftDelim, r.RightDelim
+}
+
+// TemplateFuncs returns the registry's extra template functions, see FuncRegistry
+func (r *LocalPromptRegistry) TemplateFuncs() template.FuncMap {
+	return r.Funcs
+}
+
+// TemplateExtensions returns the registry's template extensions, see ExtensionRegistry
+func (r *LocalPromptRegistry) TemplateExtensions() []string {
+	return r.Extensions
+}
+
+// Find reads the template at path, or a saved version of it when path is pinned to one with a reference such
+// as main.tmpl@v3 or main.tmpl@v1.2.0, see ParseVersionRef. Path may name the template by its id instead, see
+// IDPrefix.
+func (r *LocalPromptRegistry) Find(ctx context.Context, path string) (*Template, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	path, err := r.ResolveID(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	templatePath, version, err := r.ResolveRef(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := core.CheckTemplateExtension(templatePath, core.RegistryExtensions(r)); err != nil {
+		return nil, err
+	}
+	rel, err := r.sandboxed(templatePath)
+	if err != nil {
+		return nil, err
+	}
+	if version > 0 {
+		content, err := r.ReadVersion(rel, version)
+		if err != nil {
+			return nil, err
+		}
+		return NewTemplate(path, string(content), r), nil
 	}
-	fullPath := filepath.Join(dir, path)
-	fileBytes, err := os.ReadFile(fullPath)
+	fileBytes, err := os.ReadFile(r.fullPath(rel))
 	if err != nil {
 		return nil, err
 	}
@@ -37,13 +107,252 @@ func (r *LocalPromptRegistry) Find(path string) (*Template, error) {
 	return NewTemplate(path, string(fileBytes), r), nil
 }
 
-// LoadConfig loads a config file from the given path
-func (r *LocalPromptRegistry) LoadConfig(path string) (*Config, error) {
-	fullPath := filepath.Join(r.Directory, path)
-	return CfgFromFile(fullPath)
+// LoadConfig loads a config file from the given path, which may be pinned to a saved version like Find's
+func (r *LocalPromptRegistry) LoadConfig(ctx context.Context, path string) (*Config, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	configPath, version, err := r.ResolveRef(path)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := r.sandboxed(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if version > 0 {
+		content, err := r.ReadVersion(rel, version)
+		if err != nil {
+			return nil, err
+		}
+		return CfgFromJSONString(string(content), path)
+	}
+	return CfgFromFile(r.fullPath(rel))
+}
+
+// ReadConfig returns the contents of the config file at path, which may name a version like LoadConfig's, see
+// RawConfigRegistry
+func (r *LocalPromptRegistry) ReadConfig(ctx context.Context, path string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	configPath, version, err := r.ResolveRef(path)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := r.sandboxed(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if version > 0 {
+		return r.ReadVersion(rel, version)
+	}
+	data, err := os.ReadFile(r.fullPath(rel))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// SaveConfig saves the config to the specified path, relative to the registry directory like LoadConfig's or
+// absolute within it, and records it as a new version, see History
+func (r *LocalPromptRegistry) SaveConfig(ctx context.Context, cfg *Config) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	path, err := r.sandboxed(cfg.Path)
+	if err != nil {
+		return err
+	}
+	// Keep the key order and indentation of the config being replaced
+	previous, _ := os.ReadFile(r.fullPath(path))
+	data, err := core.MarshalConfig(cfg.Config, previous)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config to JSON: %w", err)
+	}
+	return r.writeVersioned(path, data)
+}
+
+// sandboxed returns the slash-separated path within the registry of path, which may be relative to the registry
+// directory or absolute. Paths come from callers such as the server that may not be trusted, so it fails with
+// an *OutsideRegistryError when path leads outside the directory, whether through .. elements, an absolute path
+// elsewhere or a symlink.
+func (r *LocalPromptRegistry) sandboxed(path string) (string, error) {
+	rel, ok := r.RelativePath(path)
+	if !ok {
+		return "", NewOutsideRegistryError(path)
+	}
+	root, err := filepath.Abs(r.Directory)
+	if err != nil {
+		return "", err
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		// A missing registry has no symlinks to follow, and reading from it fails anyway
+		return rel, nil
+	}
+	// Symlinks are checked on the deepest part of the path that exists, so files that are about to be
+	// created are checked too. The path is made absolute like root, so the two compare with a relative Directory.
+	existing, err := filepath.Abs(r.fullPath(rel))
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		existing = filepath.Dir(existing)
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	if within, err := filepath.Rel(root, resolved); err != nil || within == ".." || strings.HasPrefix(within, ".."+string(filepath.Separator)) {
+		return "", NewOutsideRegistryError(path)
+	}
+	return rel, nil
+}
+
+// fullPath returns the file path of a slash-separated path within the registry
+func (r *LocalPromptRegistry) fullPath(rel string) string {
+	return filepath.Join(r.Directory, filepath.FromSlash(rel))
+}
+
+// dependencyPath returns the registry path of a template named in a template action, the first file with one
+// of the registry's extensions added when the name has none, see dependencyResolver.path
+func (r *LocalPromptRegistry) dependencyPath(name string) string {
+	candidates := core.DependencyCandidates(name, core.RegistryExtensions(r))
+	for _, candidate := range candidates[:len(candidates)-1] {
+		if _, err := os.Stat(r.fullPath(candidate)); err == nil {
+			return candidate
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// RelativePath returns the slash-separated path of a file within the registry, and false for files outside it, see
+// PathRegistry
+func (r *LocalPromptRegistry) RelativePath(path string) (string, bool) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.Directory, path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	dir, err := filepath.Abs(r.Directory)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// SaveTemplate writes template content to the given path, creating any missing directories, and records it as
+// a new version, see History
+func (r *LocalPromptRegistry) SaveTemplate(path string, content string) error {
+	if err := core.CheckTemplateExtension(path, core.RegistryExtensions(r)); err != nil {
+		return err
+	}
+	rel, err := r.sandboxed(path)
+	if err != nil {
+		return err
+	}
+	if err := r.writeVersioned(rel, []byte(content)); err != nil {
+		return err
+	}
+	if err := r.updateIndex(context.Background(), rel); err != nil {
+		return err
+	}
+	r.notify(rel)
+	return nil
+}
+
+// OnChange registers fn to be called with the path of every template written through SaveTemplate
+func (r *LocalPromptRegistry) OnChange(fn func(path string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// notify calls every change listener with path
+func (r *LocalPromptRegistry) notify(path string) {
+	r.mu.Lock()
+	listeners := append([]func(string){}, r.listeners...)
+	r.mu.Unlock()
+	for _, fn := range listeners {
+		fn(path)
+	}
+}
+
+// List returns the paths of every template in the registry, relative to the registry directory. When the
+// registry has an index the paths come from it instead of walking the directory.
+func (r *LocalPromptRegistry) List() ([]string, error) {
+	index, err := r.Index()
+	if err != nil {
+		return nil, err
+	}
+	if index == nil {
+		return r.listFiles(core.RegistryExtensions(r)...)
+	}
+	paths := make([]string, 0, len(index.Templates))
+	for _, entry := range index.Templates {
+		paths = append(paths, entry.Path)
+	}
+	return paths, nil
+}
+
+// listFiles walks the registry directory and returns the slash-separated relative paths of files with any of the
+// given extensions, skipping hidden files and directories
+func (r *LocalPromptRegistry) listFiles(exts ...string) ([]string, error) {
+	paths := []string{}
+	err := filepath.WalkDir(r.Directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != r.Directory && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, ok := core.TemplateExtension(d.Name(), exts); d.IsDir() || !ok {
+			return nil
+		}
+		rel, err := filepath.Rel(r.Directory, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list registry %s: %w", r.Directory, err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// ResolvePath returns the absolute path of a file in the registry, returning an error if it does not exist
+func (r *LocalPromptRegistry) ResolvePath(path string) (string, error) {
+	rel, err := r.sandboxed(path)
+	if err != nil {
+		return "", err
+	}
+	fullPath, err := filepath.Abs(r.fullPath(rel))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve absolute path: %w", err)
+	}
+	if _, err := os.Stat(fullPath); err != nil {
+		return "", fmt.Errorf("failed to find %s in registry: %w", path, err)
+	}
+	return fullPath, nil
 }
 
-// SaveConfig saves the config to the specified path
-func (r *LocalPromptRegistry) SaveConfig(cfg *Config) error {
-	return cfg.Save()
+// ListConfigs returns the paths of every config in the registry, relative to the registry directory
+func (r *LocalPromptRegistry) ListConfigs() ([]string, error) {
+	return r.listFiles(".json")
 }
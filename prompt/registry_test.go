@@ -1,11 +1,13 @@
 package prompt
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLocalPromptRegistry_Find(t *testing.T) {
@@ -28,7 +30,7 @@ func TestLocalPromptRegistry_Find(t *testing.T) {
 	registry := NewInMemPromptRegistry(tmpDir)
 
 	t.Run("successfully find template", func(t *testing.T) {
-		template, err := registry.Find(templatePath)
+		template, err := registry.Find(context.Background(), templatePath)
 		assert.NoError(t, err)
 		assert.NotNil(t, template)
 		assert.Equal(t, templatePath, template.Path)
@@ -36,13 +38,187 @@ func TestLocalPromptRegistry_Find(t *testing.T) {
 	})
 
 	t.Run("error on wrong file extension", func(t *testing.T) {
-		_, err := registry.Find("test.txt")
+		_, err := registry.Find(context.Background(), "test.txt")
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "template file must have .tmpl extension")
 	})
 
 	t.Run("error on non-existent file", func(t *testing.T) {
-		_, err := registry.Find("nonexistent.tmpl")
+		_, err := registry.Find(context.Background(), "nonexistent.tmpl")
 		assert.Error(t, err)
 	})
 }
+
+func TestLocalPromptRegistry_SaveTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+	registry := NewInMemPromptRegistry(tmpDir)
+
+	err := registry.SaveTemplate("nested/new.tmpl", "Hello [[.name]]")
+	assert.NoError(t, err)
+
+	template, err := registry.Find(context.Background(), "nested/new.tmpl")
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello [[.name]]", template.OriginalContent)
+
+	err = registry.SaveTemplate("new.txt", "")
+	assert.Error(t, err)
+}
+
+func TestLocalPromptRegistry_List(t *testing.T) {
+	tmpDir := t.TempDir()
+	registry := NewInMemPromptRegistry(tmpDir)
+
+	assert.NoError(t, registry.SaveTemplate("b.tmpl", ""))
+	assert.NoError(t, registry.SaveTemplate("a/nested.tmpl", ""))
+	assert.NoError(t, registry.SaveTemplate(".hidden/skipped.tmpl", ""))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "config.json"), []byte("{}"), 0644))
+
+	paths, err := registry.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a/nested.tmpl", "b.tmpl"}, paths)
+}
+
+func TestLocalPromptRegistry_ResolvePath(t *testing.T) {
+	tmpDir := t.TempDir()
+	registry := NewInMemPromptRegistry(tmpDir)
+	assert.NoError(t, registry.SaveTemplate("nested/a.tmpl", ""))
+
+	path, err := registry.ResolvePath("nested/a.tmpl")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(tmpDir, "nested", "a.tmpl"), path)
+	assert.True(t, filepath.IsAbs(path))
+
+	_, err = registry.ResolvePath("missing.tmpl")
+	assert.Error(t, err)
+}
+
+func TestLocalPromptRegistry_PathTraversal(t *testing.T) {
+	root := t.TempDir()
+	tempDir := filepath.Join(root, "registry")
+	require.NoError(t, os.MkdirAll(tempDir, 0755))
+	createTestFile(t, tempDir, "main.tmpl", "inside")
+	createTestFile(t, tempDir, "main.json", `{"name": "inside"}`)
+	createTestFile(t, root, "secret.tmpl", "outside")
+	createTestFile(t, root, "secret.json", `{"token": "outside"}`)
+	require.NoError(t, os.Symlink(root, filepath.Join(tempDir, "escape")))
+	require.NoError(t, os.Symlink(filepath.Join(tempDir, "main.tmpl"), filepath.Join(tempDir, "alias.tmpl")))
+	registry := NewInMemPromptRegistry(tempDir)
+	ctx := context.Background()
+
+	for _, path := range []string{"../secret.tmpl", "nested/../../secret.tmpl", filepath.Join(root, "secret.tmpl"), "escape/secret.tmpl", "../secret.tmpl@v1"} {
+		_, err := registry.Find(ctx, path)
+		assert.ErrorIs(t, err, ErrOutsideRegistry, path)
+	}
+	for _, path := range []string{"../secret.json", filepath.Join(root, "secret.json"), "escape/secret.json"} {
+		_, err := registry.LoadConfig(ctx, path)
+		assert.ErrorIs(t, err, ErrOutsideRegistry, path)
+	}
+	for _, path := range []string{"../new.json", filepath.Join(root, "new.json"), "escape/new.json", "escape/dir/new.json"} {
+		err := registry.SaveConfig(ctx, NewConfig(map[string]any{}, path))
+		assert.ErrorIs(t, err, ErrOutsideRegistry, path)
+	}
+	assert.NoFileExists(t, filepath.Join(root, "new.json"))
+	assert.NoDirExists(t, filepath.Join(root, "dir"))
+	assert.ErrorIs(t, registry.SaveTemplate("../new.tmpl", "x"), ErrOutsideRegistry)
+	_, err := registry.ResolvePath("../secret.json")
+	assert.ErrorIs(t, err, ErrOutsideRegistry)
+	_, err = registry.OpenDocument(ctx, "escape/secret.json")
+	assert.ErrorIs(t, err, ErrOutsideRegistry)
+
+	template, err := registry.Find(ctx, "nested/../alias.tmpl")
+	require.NoError(t, err, "paths and symlinks that stay inside the registry are fine")
+	assert.Equal(t, "inside", template.OriginalContent)
+	template, err = registry.Find(ctx, filepath.Join(tempDir, "main.tmpl"))
+	require.NoError(t, err)
+	assert.Equal(t, "inside", template.OriginalContent)
+	_, err = registry.LoadConfig(ctx, "./main.json")
+	require.NoError(t, err)
+	require.NoError(t, registry.SaveConfig(ctx, NewConfig(map[string]any{}, "nested/new.json")))
+}
+
+func TestLocalPromptRegistry_RelativeDirectory(t *testing.T) {
+	root := t.TempDir()
+	tempDir := filepath.Join(root, "prompts")
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "nested"), 0755))
+	createTestFile(t, tempDir, "main.tmpl", "Hi [[.name]]")
+	createTestFile(t, tempDir, "main.json", `{"name": "Ada"}`)
+	createTestFile(t, root, "secret.tmpl", "outside")
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(root))
+	t.Cleanup(func() { os.Chdir(wd) })
+	registry := NewInMemPromptRegistry("prompts")
+	ctx := context.Background()
+
+	template, err := registry.Find(ctx, "main.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, "Hi [[.name]]", template.OriginalContent)
+	_, err = registry.LoadConfig(ctx, "main.json")
+	require.NoError(t, err)
+	require.NoError(t, registry.SaveConfig(ctx, NewConfig(map[string]any{}, "nested/new.json")))
+	assert.FileExists(t, filepath.Join(tempDir, "nested", "new.json"))
+	_, err = registry.Find(ctx, "../secret.tmpl")
+	assert.ErrorIs(t, err, ErrOutsideRegistry)
+}
+
+func TestLocalPromptRegistry_ContextCancelled(t *testing.T) {
+	registry := NewInMemPromptRegistry(t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := registry.Find(ctx, "test.tmpl")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = registry.LoadConfig(ctx, "test.json")
+	assert.ErrorIs(t, err, context.Canceled)
+	err = registry.SaveConfig(ctx, NewConfig(map[string]any{}, "test.json"))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func BenchmarkLocalPromptRegistry_Find(b *testing.B) {
+	tempDir := setupTempDir(b)
+	createTestFile(b, tempDir, "main.tmpl", largeTemplate(100))
+	registry := NewInMemPromptRegistry(tempDir)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := registry.Find(ctx, "main.tmpl"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestLocalPromptRegistry_Delimiters(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `{{template "header.tmpl" .}}{{.name}} [[.name]]`)
+	createTestFile(t, tempDir, "header.tmpl", `# {{.title}}
+`)
+	createTestFile(t, tempDir, "main.json", `{"name": "John", "title": "Hi"}`)
+	ctx := context.Background()
+
+	custom := NewInMemPromptRegistry(tempDir)
+	custom.LeftDelim, custom.RightDelim = "{{", "}}"
+	system, err := NewPromptSystem(custom)
+	require.NoError(t, err)
+	output, err := system.Build(ctx, "main.tmpl", "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, "# Hi\nJohn [[.name]]", output)
+
+	// The same source parsed with the default delimiters isn't served from the shared cache
+	system, err = NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	output, err = system.Build(ctx, "main.tmpl", "main.json", WithValidation(ValidationOff))
+	require.NoError(t, err)
+	assert.Equal(t, `{{template "header.tmpl" .}}{{.name}} John`, output)
+
+	// Bundles keep the delimiters of the registry they were compiled from
+	bundle, err := CompileRegistry(ctx, custom)
+	require.NoError(t, err)
+	compiled, err := NewCompiledRegistry(bundle)
+	require.NoError(t, err)
+	system, err = NewPromptSystem(compiled)
+	require.NoError(t, err)
+	output, err = system.Build(ctx, "main.tmpl", "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, "# Hi\nJohn [[.name]]", output)
+}
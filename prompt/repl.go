@@ -0,0 +1,217 @@
+package prompt
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/notzree/rprompt/v2/utils"
+)
+
+// replHelp is printed by the REPL's help command
+const replHelp = `Commands:
+  load <template> [config]  start from a template, with the values of a config if given
+  set <variable> <value>    set a value, read as JSON such as "Kai", 3 or ["a"] and as text otherwise
+  unset <variable>          remove a value
+  render                    render the template again
+  vars                      list the template's variables and their values
+  dump [config]             print the values as a config, or save them into a config of the registry
+  reset                     remove every value
+  help                      print this help
+  quit                      leave the REPL`
+
+// errQuit ends a REPL session
+var errQuit = errors.New("quit")
+
+// replSession is the state of 'rprompt repl': a template and the values it is rendered with, which every set
+// re-renders
+type replSession struct {
+	system   *PromptSystem
+	registry *LocalPromptRegistry
+	out      *Output
+	template string
+	values   map[string]any
+}
+
+// replResult is what a REPL command reports in JSON mode, one object per command
+type replResult struct {
+	Template  string            `json:"template,omitempty"`
+	Output    *string           `json:"output,omitempty"`
+	Issues    []ValidationIssue `json:"issues,omitempty"`
+	Variables []replVariable    `json:"variables,omitempty"`
+	Values    map[string]any    `json:"values,omitempty"`
+	Saved     string            `json:"saved,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Code      string            `json:"code,omitempty"`
+}
+
+// replVariable is a variable of the REPL's template and its value, if it is set
+type replVariable struct {
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+	Set   bool   `json:"set"`
+}
+
+// run reads commands from r until it ends or a quit command, printing prompt before each one
+func (s *replSession) run(ctx context.Context, r io.Reader, prompt string) error {
+	scanner := bufio.NewScanner(r)
+	for {
+		fmt.Fprint(s.out.Out, prompt)
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		err := s.exec(ctx, line)
+		if errors.Is(err, errQuit) {
+			return nil
+		}
+		if err != nil {
+			s.out.Report(replResult{Error: err.Error(), Code: ErrorCode(err)}, func() {
+				s.out.Errorf("%v", err)
+			})
+		}
+	}
+}
+
+// exec runs a single command line
+func (s *replSession) exec(ctx context.Context, line string) error {
+	command, args, _ := strings.Cut(line, " ")
+	args = strings.TrimSpace(args)
+	switch command {
+	case "load":
+		fields := strings.Fields(args)
+		if len(fields) < 1 || len(fields) > 2 {
+			return fmt.Errorf("usage: load <template> [config]")
+		}
+		return s.load(ctx, fields[0], fields[1:])
+	case "set":
+		path, value, _ := strings.Cut(args, " ")
+		if !variablePathPattern.MatchString(path) {
+			return fmt.Errorf("usage: set <variable> <value>, such as set user.name \"Kai\"")
+		}
+		utils.SetPath(s.values, path, parseEditValue(strings.TrimSpace(value)))
+		return s.render(ctx)
+	case "unset":
+		if !variablePathPattern.MatchString(args) {
+			return fmt.Errorf("usage: unset <variable>")
+		}
+		s.values = utils.WithoutPath(s.values, args)
+		return s.render(ctx)
+	case "render":
+		return s.render(ctx)
+	case "vars":
+		return s.vars(ctx)
+	case "dump":
+		return s.dump(ctx, args)
+	case "reset":
+		s.values = map[string]any{}
+		return s.render(ctx)
+	case "help":
+		return s.out.Report(map[string]string{"help": replHelp}, func() {
+			s.out.Println(replHelp)
+		})
+	case "quit", "exit":
+		return errQuit
+	default:
+		return fmt.Errorf("unknown command %q, type help for the commands", command)
+	}
+}
+
+// load switches to a template, starting from the values of a config when one is given
+func (s *replSession) load(ctx context.Context, template string, config []string) error {
+	if _, err := s.system.Describe(ctx, template); err != nil {
+		return err
+	}
+	values := map[string]any{}
+	if len(config) > 0 {
+		cfg, err := s.registry.LoadConfig(ctx, config[0])
+		if err != nil {
+			return err
+		}
+		values = cfg.Config
+	}
+	s.template, s.values = template, values
+	return s.render(ctx)
+}
+
+// render renders the template with the values. Missing values are reported rather than failing, so a prompt
+// can be filled in one value at a time.
+func (s *replSession) render(ctx context.Context) error {
+	if s.template == "" {
+		return fmt.Errorf("no template loaded, use load <template> first")
+	}
+	result, err := s.system.BuildWithResult(ctx, s.template, "", WithData(s.values), WithValidation(ValidationWarn))
+	if err != nil {
+		return err
+	}
+	return s.out.Report(replResult{Template: s.template, Output: &result.Output, Issues: result.Issues}, func() {
+		s.out.Println(result.Output)
+		for _, issue := range result.Issues {
+			s.out.Warnf("%s", issue.Message)
+		}
+	})
+}
+
+// vars lists the template's variables and their values
+func (s *replSession) vars(ctx context.Context) error {
+	if s.template == "" {
+		return fmt.Errorf("no template loaded, use load <template> first")
+	}
+	info, err := s.system.Describe(ctx, s.template)
+	if err != nil {
+		return err
+	}
+	variables := make([]replVariable, 0, len(info.Variables))
+	for _, path := range info.Variables {
+		value, set := utils.GetPath(s.values, path)
+		variables = append(variables, replVariable{Path: path, Value: value, Set: set})
+	}
+	return s.out.Report(replResult{Template: s.template, Variables: variables}, func() {
+		for _, v := range variables {
+			if !v.Set {
+				s.out.Printf("%s\t(not set)\n", v.Path)
+				continue
+			}
+			data, _ := json.Marshal(v.Value)
+			s.out.Printf("%s\t%s\n", v.Path, data)
+		}
+	})
+}
+
+// dump prints the values as a config, or saves them into the registry as the config at path
+func (s *replSession) dump(ctx context.Context, path string) error {
+	if path == "" {
+		return s.out.Report(replResult{Values: s.values}, func() {
+			data, _ := json.MarshalIndent(s.values, "", "  ")
+			s.out.Println(string(data))
+		})
+	}
+	if !strings.HasSuffix(path, ".json") {
+		return fmt.Errorf("config %s must have .json extension", path)
+	}
+	if err := s.registry.SaveConfig(ctx, NewConfig(s.values, path)); err != nil {
+		return err
+	}
+	return s.out.Report(replResult{Saved: path, Values: s.values}, func() {
+		s.out.Successf("Saved %s", path)
+	})
+}
+
+// parseEditValue reads a typed value as JSON, such as 3, true or ["a"], and as a string otherwise
+func parseEditValue(value string) any {
+	var parsed any
+	if err := json.Unmarshal([]byte(value), &parsed); err == nil {
+		return parsed
+	}
+	return value
+}
@@ -0,0 +1,97 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplSession(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "Hello [[.user.name]], you are [[.user.age]]")
+	createTestFile(t, tempDir, "main.json", `{"user": {"name": "Ana"}}`)
+	registry := NewInMemPromptRegistry(tempDir)
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	app, stdout, stderr := newTestApp(t, WithRegistry(registry))
+	session := &replSession{system: system, registry: registry, out: app.out, values: map[string]any{}}
+	ctx := context.Background()
+
+	input := strings.Join([]string{
+		"render",
+		"load main.tmpl main.json",
+		`set user.name "Kai"`,
+		"set user.age 30",
+		"vars",
+		"# a comment",
+		"unset user.age",
+		"set user.tags [\"a\", \"b\"]",
+		"dump",
+		"dump saved.json",
+		"bogus",
+		"quit",
+		"render",
+	}, "\n")
+	require.NoError(t, session.run(ctx, strings.NewReader(input), ""))
+
+	out := stdout.String()
+	assert.Contains(t, out, "Hello Ana, you are <no value>\n")
+	assert.Contains(t, out, "Hello Kai, you are <no value>\n", "set re-renders")
+	assert.Contains(t, out, "Hello Kai, you are 30\n")
+	assert.Contains(t, out, "user.age\t30\nuser.name\t\"Kai\"\n")
+	assert.Contains(t, out, `"tags": [`)
+	assert.Contains(t, stderr.String(), "no template loaded")
+	assert.Contains(t, stderr.String(), `unknown command "bogus"`)
+	assert.Equal(t, 1, strings.Count(stderr.String(), "no template loaded"), "nothing runs after quit")
+
+	saved, err := registry.LoadConfig(ctx, "saved.json")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"user": map[string]any{"name": "Kai", "tags": []any{"a", "b"}}}, saved.Config)
+}
+
+func TestReplSession_Errors(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "[[.name]]")
+	registry := NewInMemPromptRegistry(tempDir)
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	app, _, _ := newTestApp(t, WithRegistry(registry))
+	session := &replSession{system: system, registry: registry, out: app.out, values: map[string]any{}}
+	ctx := context.Background()
+
+	assert.ErrorIs(t, session.exec(ctx, "load missing.tmpl"), ErrTemplateNotFound)
+	assert.ErrorContains(t, session.exec(ctx, "load"), "usage: load")
+	require.NoError(t, session.exec(ctx, "load main.tmpl"))
+	assert.ErrorContains(t, session.exec(ctx, "set 1bad x"), "usage: set")
+	assert.ErrorContains(t, session.exec(ctx, "dump saved.txt"), ".json extension")
+	assert.ErrorIs(t, session.exec(ctx, "exit"), errQuit)
+	assert.Equal(t, "main.tmpl", session.template, "a failed load keeps the current template")
+}
+
+func TestApp_REPL(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "Hello [[.name]]")
+	createTestFile(t, tempDir, "main.json", `{"name": "Ana"}`)
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	cmd := app.Command()
+	cmd.Reader = strings.NewReader("set name \"Kai\"\n")
+
+	require.NoError(t, cmd.Run(context.Background(), []string{"rprompt", "--json", "repl", "main.tmpl", "-c", "main.json"}))
+	decoder := json.NewDecoder(stdout)
+	var results []replResult
+	for decoder.More() {
+		var result replResult
+		require.NoError(t, decoder.Decode(&result), "one JSON object per command, without prompts")
+		results = append(results, result)
+	}
+	require.Len(t, results, 2)
+	assert.Equal(t, "Hello Ana", *results[0].Output)
+	assert.Equal(t, "Hello Kai", *results[1].Output)
+
+	app, _, _ = newTestApp(t)
+	assert.ErrorContains(t, app.Command().Run(context.Background(), []string{"rprompt", "repl"}), "registry directory not set")
+}
@@ -0,0 +1,91 @@
+package prompt
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const answerSchemaTemplate = `---
+response_schema:
+  type: object
+  properties:
+    answer: {type: string}
+    confidence: {type: number}
+    tags: {type: array, items: {type: string}}
+    verdict: {enum: [yes, no]}
+  required: [answer]
+  additionalProperties: false
+---
+[[role "system"]]Answer questions.[[role "user"]][[.question]]`
+
+func TestWithResponseFormat(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", answerSchemaTemplate)
+	createTestFile(t, tempDir, "plain.tmpl", `Hi`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	result, err := system.BuildWithResult(ctx, "main.tmpl", "", WithValue("question", "Why?"), WithResponseFormat(), WithMessages())
+	require.NoError(t, err)
+	assert.Contains(t, result.Output, "Why?\n\nRespond only with JSON matching this schema:\n{\n")
+	assert.Contains(t, result.Output, `"required": [`)
+	require.Len(t, result.Messages, 2)
+	assert.Contains(t, result.Messages[1].Content, "Respond only with JSON", "the section belongs to the last message")
+
+	builder, err := system.NewBuilder(ctx, "main.tmpl", "", WithValue("question", "Why?"), WithResponseFormat())
+	require.NoError(t, err)
+	output, err := builder.Build(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, result.Output, output)
+
+	output, err = system.Build(ctx, "main.tmpl", "", WithValue("question", "Why?"))
+	require.NoError(t, err)
+	assert.Equal(t, "Answer questions.Why?", output, "the section is only appended when asked for")
+
+	output, err = system.Build(ctx, "plain.tmpl", "", WithResponseFormat())
+	require.NoError(t, err)
+	assert.Equal(t, "Hi", output)
+}
+
+func TestValidateResponse(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", answerSchemaTemplate)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	schema, err := system.ResponseSchema(context.Background(), "main.tmpl")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		reply    string
+		problems []string
+	}{
+		{"valid", `{"answer": "42", "confidence": 0.9, "tags": ["math"], "verdict": "yes"}`, nil},
+		{"fenced", "```json\n{\"answer\": \"42\"}\n```", nil},
+		{"integer is a number", `{"answer": "42", "confidence": 1}`, nil},
+		{"not json", `The answer is 42`, []string{"reply is not JSON: invalid character 'T' looking for beginning of value"}},
+		{"missing", `{"confidence": 1}`, []string{"$.answer: required"}},
+		{"wrong type", `{"answer": 42}`, []string{"$.answer: expected string, got integer"}},
+		{"item type", `{"answer": "a", "tags": ["x", 1]}`, []string{"$.tags[1]: expected string, got integer"}},
+		{"enum", `{"answer": "a", "verdict": "maybe"}`, []string{"$.verdict: maybe is not one of the allowed values"}},
+		{"additional", `{"answer": "a", "extra": true}`, []string{"$.extra: not allowed"}},
+		{"root type", `["answer"]`, []string{"$: expected object, got array"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateResponse(schema, tt.reply)
+			if tt.problems == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var formatErr *ResponseFormatError
+			require.True(t, errors.As(err, &formatErr), "got %v", err)
+			assert.Equal(t, tt.problems, formatErr.Problems)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package prompt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// httpContextTimeout bounds a request to a retrieval service when HTTPContextProvider has no client of its own
+const httpContextTimeout = 30 * time.Second
+
+// NewContextProvider returns the provider for source: an HTTPContextProvider for http and https URLs and a
+// FileContextProvider matching source as a glob otherwise
+func NewContextProvider(source string) (ContextProvider, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		return &HTTPContextProvider{URL: source}, nil
+	}
+	if _, err := filepath.Match(source, ""); err != nil {
+		return nil, fmt.Errorf("invalid context glob %q: %w", source, err)
+	}
+	return &FileContextProvider{Pattern: source}, nil
+}
+
+// FileContextProvider retrieves the files matching a glob, ranked by how often they mention the words of the
+// query. It needs no index or service, which suits small document sets such as a docs directory.
+type FileContextProvider struct {
+	// Pattern is matched with filepath.Glob, e.g. docs/*.md
+	Pattern string
+}
+
+// Fetch returns the k matching files that mention the query's words most. Files that mention none of them are
+// left out.
+func (p *FileContextProvider) Fetch(query string, k int) ([]Document, error) {
+	paths, err := filepath.Glob(p.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid context glob %q: %w", p.Pattern, err)
+	}
+	words := queryWords(query)
+	var docs []Document
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		lower := strings.ToLower(string(content))
+		score := 0
+		for _, word := range words {
+			score += strings.Count(lower, word)
+		}
+		if score > 0 {
+			docs = append(docs, Document{Source: filepath.ToSlash(path), Content: string(content), Score: float64(score)})
+		}
+	}
+	sort.SliceStable(docs, func(i, j int) bool { return docs[i].Score > docs[j].Score })
+	if len(docs) > k {
+		docs = docs[:k]
+	}
+	return docs, nil
+}
+
+// queryWords splits a query into the lowercase words FileContextProvider counts, ignoring single letters
+func queryWords(query string) []string {
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if len([]rune(word)) > 1 {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// HTTPContextProvider retrieves documents from a retrieval service. It posts {"query": ..., "k": ...} to URL
+// and expects {"documents": [{"source": ..., "content": ..., "score": ...}]} back, so any search or vector
+// database can sit behind a small endpoint.
+type HTTPContextProvider struct {
+	URL string
+	// Headers are set on every request, e.g. Authorization
+	Headers map[string]string
+	// Client defaults to a client that gives up after 30 seconds
+	Client *http.Client
+}
+
+// Fetch posts the query to the service and returns the documents it answers with
+func (p *HTTPContextProvider) Fetch(query string, k int) ([]Document, error) {
+	payload, err := json.Marshal(map[string]any{"query": query, "k": k})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, p.URL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range p.Headers {
+		req.Header.Set(key, value)
+	}
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: httpContextTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("err calling %s: %w", p.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("%s returned %s: %s", p.URL, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var body struct {
+		Documents []Document `json:"documents"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("err decoding response from %s: %w", p.URL, err)
+	}
+	return body.Documents, nil
+}
@@ -0,0 +1,76 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileContextProvider(t *testing.T) {
+	dir := t.TempDir()
+	createTestFile(t, dir, "billing.md", "Billing: invoices and billing cycles.")
+	createTestFile(t, dir, "refunds.md", "Refunds are part of billing.")
+	createTestFile(t, dir, "onboarding.md", "Welcome aboard.")
+	createTestFile(t, dir, "notes.txt", "billing billing billing")
+
+	provider, err := NewContextProvider(filepath.Join(dir, "*.md"))
+	require.NoError(t, err)
+	docs, err := provider.Fetch("Billing docs", 5)
+	require.NoError(t, err)
+	require.Len(t, docs, 2, "files that don't mention the query and files outside the glob are left out")
+	assert.Equal(t, filepath.ToSlash(filepath.Join(dir, "billing.md")), docs[0].Source)
+	assert.Equal(t, 2.0, docs[0].Score)
+	assert.Equal(t, "Refunds are part of billing.", docs[1].Content)
+
+	docs, err = provider.Fetch("billing", 1)
+	require.NoError(t, err)
+	assert.Len(t, docs, 1)
+
+	_, err = NewContextProvider("docs/[")
+	assert.ErrorContains(t, err, "invalid context glob")
+}
+
+func TestHTTPContextProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query string `json:"query"`
+			K     int    `json:"k"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"documents": []Document{{Source: "kb/1", Content: req.Query, Score: float64(req.K)}}})
+	}))
+	defer server.Close()
+
+	provider, err := NewContextProvider(server.URL)
+	require.NoError(t, err)
+	_, err = provider.Fetch("billing", 3)
+	assert.ErrorContains(t, err, "401 Unauthorized: unauthorized")
+
+	provider.(*HTTPContextProvider).Headers = map[string]string{"Authorization": "Bearer secret"}
+	docs, err := provider.Fetch("billing", 3)
+	require.NoError(t, err)
+	assert.Equal(t, []Document{{Source: "kb/1", Content: "billing", Score: 3}}, docs)
+}
+
+func TestApp_Retrieve(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `Context: [[retrieve "refunds" 1]]`)
+	createTestFile(t, tempDir, "main.json", `{}`)
+	docs := t.TempDir()
+	createTestFile(t, docs, "refunds.md", "Refunds take 5 days.")
+	app, _, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	outDir := t.TempDir()
+
+	require.NoError(t, app.Command().Run(context.Background(), []string{"rprompt", "--context", filepath.Join(docs, "*.md"), "generate", "-t", "main.tmpl", "-c", "main.json", "-o", filepath.Join(outDir, "out.txt")}))
+	assert.Equal(t, "Context: Refunds take 5 days.", readTestFile(t, outDir, "out.txt"))
+}
@@ -0,0 +1,85 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate_Schema(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "ticket.tmpl", `[[/* Draft a reply to a support ticket */]]
+Customer: [[.customer.name]]
+[[if .urgent]]URGENT[[end]]
+[[if gt .priority 2]]High priority[[end]]
+[[range .messages]]- [[.author]]: [[.text]]
+[[end]]
+[[range .tags]]#[[.]] [[end]]
+[[with .order]]Order [[.id]][[end]]
+[[if .notes]]Notes: [[.notes]][[end]]
+[[template "footer.tmpl" .customer]]`)
+	createTestFile(t, tempDir, "footer.tmpl", `Regards, [[.agent]]`)
+	system, _ := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+
+	tool, err := system.ToolSchema(context.Background(), "ticket.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, "function", tool.Type)
+	assert.Equal(t, "ticket", tool.Function.Name)
+	assert.Equal(t, "Draft a reply to a support ticket", tool.Function.Description)
+
+	got, err := json.Marshal(tool.Function.Parameters)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"additionalProperties": false,
+		"required": ["customer", "messages", "priority", "tags"],
+		"properties": {
+			"customer": {
+				"type": "object",
+				"additionalProperties": false,
+				"required": ["agent", "name"],
+				"properties": {"agent": {"type": "string"}, "name": {"type": "string"}}
+			},
+			"urgent": {"type": "boolean"},
+			"priority": {"type": "number"},
+			"messages": {
+				"type": "array",
+				"items": {
+					"type": "object",
+					"additionalProperties": false,
+					"required": ["author", "text"],
+					"properties": {"author": {"type": "string"}, "text": {"type": "string"}}
+				}
+			},
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"order": {
+				"type": "object",
+				"additionalProperties": false,
+				"required": ["id"],
+				"properties": {"id": {"type": "string"}}
+			},
+			"notes": {"type": "string"}
+		}
+	}`, string(got))
+}
+
+func TestToolSchema_NoVariables(t *testing.T) {
+	tempDir := setupTempDir(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "support"), 0755))
+	createTestFile(t, tempDir, "support/static.tmpl", `Just text`)
+	system, _ := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+
+	tool, err := system.ToolSchema(context.Background(), "support/static.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, "support_static", tool.Function.Name)
+	assert.Empty(t, tool.Function.Description)
+
+	got, err := json.Marshal(tool.Function.Parameters)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "object", "additionalProperties": false}`, string(got))
+}
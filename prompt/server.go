@@ -0,0 +1,196 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/notzree/rprompt/v2/prompt/core"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// maxRequestBytes bounds the JSON config a client can send to the HTTP server
+const maxRequestBytes = 8 << 20
+
+// HTTPServer serves a registry over a REST API:
+//
+//	GET  /templates              lists the registry's templates
+//	GET  /templates/{path}       describes a template, see TemplateInfo
+//	POST /render/{path}          renders a template with the JSON body as config values
+//	POST /validate/{path}        checks the JSON body against the variables a template uses
+//	GET  /metrics                serves Prometheus metrics, see Metrics
+//
+// Render takes the query parameters config, a registry config the body is layered over, validation and
+// format. Errors are returned as {"error": "..."}. EnableUI adds a web UI over the same API.
+type HTTPServer struct {
+	registry *LocalPromptRegistry
+	system   *PromptSystem
+	mux      *http.ServeMux
+	// uiPoll is how often the UI's event stream checks for changes, see EnableUI
+	uiPoll time.Duration
+}
+
+// NewHTTPServer creates an HTTPServer for the templates in registry
+func NewHTTPServer(registry *LocalPromptRegistry) (*HTTPServer, error) {
+	system, err := NewPromptSystem(registry)
+	if err != nil {
+		return nil, err
+	}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	if system.Metrics, err = NewMetrics(reg); err != nil {
+		return nil, err
+	}
+	s := &HTTPServer{registry: registry, system: system, mux: http.NewServeMux()}
+	s.mux.Handle("GET /metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	s.mux.HandleFunc("GET /templates", s.listTemplates)
+	s.mux.HandleFunc("GET /templates/{path...}", s.describeTemplate)
+	s.mux.HandleFunc("POST /render/{path...}", s.renderTemplate)
+	s.mux.HandleFunc("POST /validate/{path...}", s.validateConfig)
+	return s, nil
+}
+
+func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves on addr until ctx is cancelled, then shuts down gracefully
+func (s *HTTPServer) ListenAndServe(ctx context.Context, addr string) error {
+	server := &http.Server{Addr: addr, Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+func (s *HTTPServer) listTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := s.registry.List()
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"templates": templates})
+}
+
+func (s *HTTPServer) describeTemplate(w http.ResponseWriter, r *http.Request) {
+	info, err := s.system.Describe(r.Context(), r.PathValue("path"))
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *HTTPServer) renderTemplate(w http.ResponseWriter, r *http.Request) {
+	data, err := readConfigBody(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	query := r.URL.Query()
+	opts := []BuildOption{WithData(data)}
+	if mode := query.Get("validation"); mode != "" {
+		parsed, err := ParseValidationMode(mode)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		opts = append(opts, WithValidation(parsed))
+	}
+	format := query.Get("format")
+	if format != "" {
+		if err := CheckFormat(format); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if core.FormatNeedsMessages(format) {
+			opts = append(opts, WithMessages())
+		}
+		if core.FormatNeedsSections(format) {
+			opts = append(opts, WithSections())
+		}
+	}
+
+	result, err := s.system.BuildWithResult(r.Context(), r.PathValue("path"), query.Get("config"), opts...)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	if format == "" || format == FormatText {
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	formatted, err := FormatResult(format, result)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", core.FormatContentType(format))
+	io.WriteString(w, formatted)
+}
+
+func (s *HTTPServer) validateConfig(w http.ResponseWriter, r *http.Request) {
+	data, err := readConfigBody(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	issues, err := s.system.Validate(r.Context(), r.PathValue("path"), NewConfig(data, ""))
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"valid": len(issues) == 0, "issues": issues})
+}
+
+// readConfigBody decodes a request body of config values. An empty body is an empty config.
+func readConfigBody(r *http.Request) (map[string]any, error) {
+	data := map[string]any{}
+	err := json.NewDecoder(io.LimitReader(r.Body, maxRequestBytes)).Decode(&data)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return data, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeHTTPError maps an error to a status: missing templates and configs are 404s, problems with the
+// request's config 400s, unpublished drafts 403s and anything else a 500. The body carries the error's stable code, see ErrorCode.
+func writeHTTPError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var missing *MissingFieldsError
+	var invalid *ValidationError
+	var budget *TokenBudgetError
+	var pii *PIIError
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		status = http.StatusNotFound
+	case errors.As(err, &missing), errors.As(err, &invalid), errors.As(err, &budget), errors.As(err, &pii):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrDraft):
+		status = http.StatusForbidden
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error(), "code": ErrorCode(err)})
+}
@@ -0,0 +1,133 @@
+package prompt
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHTTPServer(t *testing.T) *httptest.Server {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "greeting.tmpl", `[[template "header.tmpl" .]] Hello [[.user.name]]`)
+	createTestFile(t, tempDir, "header.tmpl", `Header`)
+	createTestFile(t, tempDir, "chat.tmpl", `[[role "system"]]Be brief.[[role "user"]][[.question]]`)
+	createTestFile(t, tempDir, "base.json", `{"user": {"name": "John"}}`)
+	server, err := NewHTTPServer(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func doJSON(t *testing.T, method, url, body string, v any) int {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+	return resp.StatusCode
+}
+
+func TestHTTPServer_Templates(t *testing.T) {
+	ts := newTestHTTPServer(t)
+
+	var list map[string][]string
+	assert.Equal(t, http.StatusOK, doJSON(t, "GET", ts.URL+"/templates", "", &list))
+	assert.Equal(t, []string{"chat.tmpl", "greeting.tmpl", "header.tmpl"}, list["templates"])
+
+	var info TemplateInfo
+	assert.Equal(t, http.StatusOK, doJSON(t, "GET", ts.URL+"/templates/greeting.tmpl", "", &info))
+	assert.Equal(t, []string{"header.tmpl"}, info.Dependencies)
+	assert.Equal(t, []string{"user.name"}, info.Variables)
+	assert.Contains(t, info.Config, "user")
+
+	var errResp map[string]string
+	assert.Equal(t, http.StatusNotFound, doJSON(t, "GET", ts.URL+"/templates/missing.tmpl", "", &errResp))
+	assert.NotEmpty(t, errResp["error"])
+	assert.Equal(t, CodeTemplateNotFound, errResp["code"])
+}
+
+func TestHTTPServer_Render(t *testing.T) {
+	ts := newTestHTTPServer(t)
+
+	var result BuildResult
+	assert.Equal(t, http.StatusOK, doJSON(t, "POST", ts.URL+"/render/greeting.tmpl", `{"user": {"name": "Ada"}}`, &result))
+	assert.Equal(t, "Header Hello Ada", result.Output)
+
+	assert.Equal(t, http.StatusOK, doJSON(t, "POST", ts.URL+"/render/greeting.tmpl?config=base.json", ``, &result))
+	assert.Equal(t, "Header Hello John", result.Output)
+
+	var messages []Message
+	assert.Equal(t, http.StatusOK, doJSON(t, "POST", ts.URL+"/render/chat.tmpl?format=messages-json", `{"question": "Why?"}`, &messages))
+	assert.Equal(t, []Message{{Role: RoleSystem, Content: "Be brief."}, {Role: RoleUser, Content: "Why?"}}, messages)
+
+	var errResp map[string]string
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, "POST", ts.URL+"/render/greeting.tmpl", `{}`, &errResp))
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, "POST", ts.URL+"/render/greeting.tmpl", `not json`, &errResp))
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, "POST", ts.URL+"/render/greeting.tmpl?format=xml", `{}`, &errResp))
+}
+
+func TestHTTPServer_Validate(t *testing.T) {
+	ts := newTestHTTPServer(t)
+
+	var resp struct {
+		Valid  bool              `json:"valid"`
+		Issues []ValidationIssue `json:"issues"`
+	}
+	assert.Equal(t, http.StatusOK, doJSON(t, "POST", ts.URL+"/validate/greeting.tmpl", `{"user": {"name": "Ada"}}`, &resp))
+	assert.True(t, resp.Valid)
+
+	assert.Equal(t, http.StatusOK, doJSON(t, "POST", ts.URL+"/validate/greeting.tmpl", `{"extra": 1}`, &resp))
+	assert.False(t, resp.Valid)
+	require.Len(t, resp.Issues, 2)
+	assert.Equal(t, IssueUnused, resp.Issues[0].Kind)
+	assert.Equal(t, IssueMissing, resp.Issues[1].Kind)
+}
+
+func TestHTTPServer_Metrics(t *testing.T) {
+	ts := newTestHTTPServer(t)
+
+	var rendered map[string]any
+	require.Equal(t, http.StatusOK, doJSON(t, http.MethodPost, ts.URL+"/render/greeting.tmpl", `{"user": {"name": "Ada"}}`, &rendered))
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `rprompt_renders_total{template="greeting.tmpl"} 1`)
+	assert.Contains(t, string(body), "rprompt_render_duration_seconds_bucket")
+	assert.Contains(t, string(body), "go_goroutines")
+}
+
+func TestHTTPServer_PII(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "reply.tmpl", `Reply to [[.email]]`)
+	registry := NewInMemPromptRegistry(tempDir)
+	registry.PII = PIIMask
+	server, err := NewHTTPServer(registry)
+	require.NoError(t, err)
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	var result BuildResult
+	assert.Equal(t, http.StatusOK, doJSON(t, "POST", ts.URL+"/render/reply.tmpl", `{"email": "jane@example.com"}`, &result))
+	assert.Equal(t, "Reply to [EMAIL]", result.Output, "the registry's PII mode applies to served renders")
+
+	registry.PII = PIIBlock
+	server, err = NewHTTPServer(registry)
+	require.NoError(t, err)
+	ts = httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	var errResp map[string]string
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, "POST", ts.URL+"/render/reply.tmpl", `{"email": "jane@example.com"}`, &errResp))
+	assert.Equal(t, CodeConfigInvalid, errResp["code"])
+}
@@ -0,0 +1,193 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/notzree/rprompt/v2/utils"
+)
+
+// Settings are addressed by keys made of their JSON names, joined with dots and with map keys in between,
+// such as registry_dir or profiles.fast.max_context. Keys that don't name a setting are rejected and values
+// are parsed as the setting's type, so editing settings through keys can't produce a file that doesn't load.
+
+// Get returns the value of the setting at key. Keys naming a group of settings, such as profiles.fast, return
+// the whole group. Settings that aren't set return their zero value.
+func (s *Settings) Get(key string) (any, error) {
+	v := reflect.ValueOf(s).Elem()
+	path := strings.Split(key, ".")
+	for i, part := range path {
+		switch v.Kind() {
+		case reflect.Struct:
+			field, ok := fieldByName(v.Type(), part)
+			if !ok {
+				return nil, unknownSetting(key)
+			}
+			v = v.FieldByIndex(field.Index)
+		case reflect.Map:
+			elem := v.MapIndex(reflect.ValueOf(part))
+			if !elem.IsValid() {
+				if i == len(path)-1 {
+					return nil, fmt.Errorf("%s is not set", key)
+				}
+				// Check the rest of the key against the type even though the entry doesn't exist
+				elem = reflect.New(v.Type().Elem()).Elem()
+			}
+			v = elem
+		default:
+			return nil, unknownSetting(key)
+		}
+	}
+	return v.Interface(), nil
+}
+
+// Set parses value as the type of the setting at key and sets it, creating map entries along the way, e.g.
+// setting profiles.fast.model creates the fast profile
+func (s *Settings) Set(key, value string) error {
+	return update(reflect.ValueOf(s).Elem(), key, strings.Split(key, "."), func(v reflect.Value) error {
+		return parseInto(v, key, value)
+	})
+}
+
+// Unset clears the setting at key. Unsetting a map entry such as profiles.fast removes it.
+func (s *Settings) Unset(key string) error {
+	return update(reflect.ValueOf(s).Elem(), key, strings.Split(key, "."), nil)
+}
+
+// List returns every setting that is set, by key
+func (s *Settings) List() (map[string]any, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	settings := make(map[string]any)
+	for _, key := range utils.FlattenKeys(raw) {
+		value, _ := utils.GetPath(raw, key)
+		// Groups of settings that are all unset flatten to an empty map
+		if group, ok := value.(map[string]any); value == nil || reflect.ValueOf(value).IsZero() || ok && len(group) == 0 {
+			continue
+		}
+		settings[key] = value
+	}
+	return settings, nil
+}
+
+// Clone returns a deep copy of s, so it can be changed and validated without changing s
+func (s *Settings) Clone() *Settings {
+	clone := *s
+	clone.Profiles = cloneMap(s.Profiles)
+	clone.Registries = cloneMap(s.Registries)
+	return &clone
+}
+
+func cloneMap[V any](m map[string]V) map[string]V {
+	if m == nil {
+		return nil
+	}
+	clone := make(map[string]V, len(m))
+	for k, v := range m {
+		clone[k] = v
+	}
+	return clone
+}
+
+// update applies set to the setting at path within v, or clears it when set is nil
+func update(v reflect.Value, key string, path []string, set func(reflect.Value) error) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		field, ok := fieldByName(v.Type(), path[0])
+		if !ok {
+			return unknownSetting(key)
+		}
+		fv := v.FieldByIndex(field.Index)
+		if len(path) > 1 {
+			return update(fv, key, path[1:], set)
+		}
+		if set == nil {
+			fv.SetZero()
+			return nil
+		}
+		return set(fv)
+	case reflect.Map:
+		name := reflect.ValueOf(path[0])
+		if len(path) == 1 {
+			if set != nil {
+				return fmt.Errorf("%s is a group of settings, set one of its keys instead", key)
+			}
+			if !v.IsNil() {
+				v.SetMapIndex(name, reflect.Value{})
+			}
+			return nil
+		}
+		// Map entries aren't addressable, so the entry is updated in a copy and stored back
+		elem := reflect.New(v.Type().Elem()).Elem()
+		existing := v.MapIndex(name)
+		if existing.IsValid() {
+			elem.Set(existing)
+		} else if set == nil {
+			// Check the rest of the key, but there is nothing to clear
+			return update(elem, key, path[1:], set)
+		}
+		if err := update(elem, key, path[1:], set); err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		v.SetMapIndex(name, elem)
+		return nil
+	}
+	return unknownSetting(key)
+}
+
+// parseInto parses value as the type of v and sets it
+func parseInto(v reflect.Value, key, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be an integer, got %q", key, value)
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a number, got %q", key, value)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false, got %q", key, value)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("%s is a group of settings, set one of its keys instead", key)
+	}
+	return nil
+}
+
+// fieldByName returns the field of a settings struct with the JSON name
+func fieldByName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if jsonName == name && field.IsExported() {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func unknownSetting(key string) error {
+	return fmt.Errorf("unknown setting %q", key)
+}
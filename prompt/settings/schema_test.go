@@ -0,0 +1,79 @@
+package settings
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettings_Set(t *testing.T) {
+	s := &Settings{}
+	require.NoError(t, s.Set("format", "messages-json"))
+	require.NoError(t, s.Set("profiles.fast.model", "gpt-4o-mini"))
+	require.NoError(t, s.Set("profiles.fast.max_context", "128000"))
+	require.NoError(t, s.Set("profiles.fast.input_price", "0.15"))
+	require.NoError(t, s.Set("registries.work.dir", "/work"))
+	assert.Equal(t, &Settings{
+		Format:     "messages-json",
+		Profiles:   map[string]ModelProfile{"fast": {Model: "gpt-4o-mini", MaxContext: 128000, InputPrice: 0.15}},
+		Registries: map[string]Registry{"work": {Dir: "/work"}},
+	}, s)
+
+	for key, expected := range map[string]string{
+		"colour":                     `unknown setting "colour"`,
+		"profiles.fast.colour":       `unknown setting "profiles.fast.colour"`,
+		"format.nested":              `unknown setting "format.nested"`,
+		"profiles.fast":              "profiles.fast is a group of settings",
+		"profiles":                   "profiles is a group of settings",
+		"profiles.fast.max_context":  `profiles.fast.max_context must be an integer, got "lots"`,
+		"profiles.fast.output_price": `profiles.fast.output_price must be a number, got "lots"`,
+	} {
+		assert.ErrorContains(t, s.Set(key, "lots"), expected, key)
+	}
+	// Rejected keys don't create map entries
+	assert.ErrorContains(t, s.Set("profiles.new.colour", "red"), "unknown setting")
+	assert.NotContains(t, s.Profiles, "new")
+}
+
+func TestSettings_GetUnset(t *testing.T) {
+	s := &Settings{
+		RegistryDir: "/prompts",
+		Profiles:    map[string]ModelProfile{"fast": {Model: "gpt-4o-mini", MaxContext: 128000}},
+	}
+
+	value, err := s.Get("profiles.fast.max_context")
+	require.NoError(t, err)
+	assert.Equal(t, 128000, value)
+	value, err = s.Get("profiles.fast")
+	require.NoError(t, err)
+	assert.Equal(t, ModelProfile{Model: "gpt-4o-mini", MaxContext: 128000}, value)
+	value, err = s.Get("format")
+	require.NoError(t, err)
+	assert.Equal(t, "", value)
+	_, err = s.Get("profiles.slow")
+	assert.ErrorContains(t, err, "profiles.slow is not set")
+	_, err = s.Get("profiles.slow.colour")
+	assert.ErrorContains(t, err, "unknown setting")
+
+	list, err := s.List()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"registry_dir":              "/prompts",
+		"profiles.fast.model":       "gpt-4o-mini",
+		"profiles.fast.max_context": float64(128000),
+	}, list)
+
+	clone := s.Clone()
+	require.NoError(t, clone.Unset("profiles.fast.max_context"))
+	require.NoError(t, clone.Unset("registry_dir"))
+	assert.Equal(t, ModelProfile{Model: "gpt-4o-mini"}, clone.Profiles["fast"])
+	assert.Empty(t, clone.RegistryDir)
+	assert.Equal(t, 128000, s.Profiles["fast"].MaxContext, "clones don't share maps")
+
+	require.NoError(t, clone.Unset("profiles.fast"))
+	assert.Empty(t, clone.Profiles)
+	require.NoError(t, clone.Unset("registries.missing.dir"))
+	assert.Nil(t, clone.Registries)
+	assert.ErrorContains(t, clone.Unset("colour"), "unknown setting")
+}
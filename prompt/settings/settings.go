@@ -2,13 +2,326 @@ package settings
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+
+	"gopkg.in/yaml.v3"
 )
 
 type Settings struct {
-	RegistryDir string `json:"registry_dir"`
+	// RegistryDir is the registry used when no named registry is selected
+	RegistryDir string `json:"registry_dir" yaml:"registry_dir"`
+	// Delims replaces the [[ ]] delimiters of the registry's templates, in the form "{{,}}"
+	Delims string `json:"delims,omitempty" yaml:"delims"`
+	// PII is what builds do with likely PII in config values: off, warn, mask or block. Empty is off.
+	PII string `json:"pii,omitempty" yaml:"pii"`
+	// Extensions lists the accepted extensions of the registry's template files, such as ".tmpl,.prompt".
+	// Empty accepts .tmpl alone.
+	Extensions string `json:"extensions,omitempty" yaml:"extensions"`
+	// Registries holds named registries to switch between, such as work and personal
+	Registries map[string]Registry `json:"registries,omitempty" yaml:"registries"`
+	// CurrentRegistry is the named registry used when a command isn't given one
+	CurrentRegistry string `json:"current_registry,omitempty" yaml:"current_registry"`
+	// Format is the output format generate uses when it isn't given --format
+	Format string `json:"format,omitempty" yaml:"format"`
+	// Profiles holds named model profiles, so per-model behavior is configured once
+	Profiles map[string]ModelProfile `json:"profiles,omitempty" yaml:"profiles"`
+	// DefaultProfile is the profile used when a command isn't given one
+	DefaultProfile string `json:"default_profile,omitempty" yaml:"default_profile"`
+	// Defaults holds values for common flags, used when a command isn't given the flag
+	Defaults FlagDefaults `json:"defaults,omitempty" yaml:"defaults"`
+	// Stacks name ordered lists of templates generate renders as one prompt with --stack, e.g. persona, task,
+	// constraints and examples
+	Stacks map[string][]string `json:"stacks,omitempty" yaml:"stacks"`
+	// PostProcess lists the post-processors applied to every prompt, e.g. [collapse_blank_lines, max_width=100],
+	// after those in the template's front matter
+	PostProcess []string `json:"post_process,omitempty" yaml:"post_process"`
+	// Flags turns feature flags templates check with [[if flag "new-tone"]] on or off when rprompt isn't given
+	// --flags
+	Flags map[string]bool `json:"flags,omitempty" yaml:"flags"`
+	// PluginsDir holds the plugins every command starts, see 'rprompt plugins'. Empty uses ~/.rprompt/plugins.
+	// Project settings can't change it, since plugins are programs.
+	PluginsDir string `json:"plugins_dir,omitempty" yaml:"plugins_dir"`
+}
+
+// FlagDefaults are values for flags commands use when they aren't given, so a team can standardize how
+// prompts are generated without long command lines. Flags given on the command line always win.
+type FlagDefaults struct {
+	// Output is generate's --output and may contain the same placeholders, e.g. prompts/{{template_stem}}.txt
+	// writes every prompt into the prompts directory
+	Output string `json:"output,omitempty" yaml:"output"`
+	// Validation is generate's --validation: default, off, warn or strict
+	Validation string `json:"validation,omitempty" yaml:"validation"`
+	// FailOnWarn is generate's --fail-on-warn
+	FailOnWarn bool `json:"fail_on_warn,omitempty" yaml:"fail_on_warn"`
+	// Provider and Model are run's --provider and --model, used when the profile doesn't set them. Model also
+	// picks the tokenizer generate counts tokens with.
+	Provider string `json:"provider,omitempty" yaml:"provider"`
+	Model    string `json:"model,omitempty" yaml:"model"`
+}
+
+// Registry is a named prompt registry
+type Registry struct {
+	Dir string `json:"dir" yaml:"dir"`
+	// Delims replaces the [[ ]] delimiters of the registry's templates, in the form "{{,}}". Empty uses the
+	// settings' Delims.
+	Delims string `json:"delims,omitempty" yaml:"delims"`
+	// PII is what builds from the registry do with likely PII in config values, see Settings.PII. Empty uses the
+	// settings' PII.
+	PII string `json:"pii,omitempty" yaml:"pii"`
+	// Extensions lists the accepted extensions of the registry's template files, see Settings.Extensions. Empty
+	// uses the settings' Extensions.
+	Extensions string `json:"extensions,omitempty" yaml:"extensions"`
+	// Plugin names the installed plugin that stores the registry's templates and configs instead of Dir, see
+	// 'rprompt plugins'. Only the commands that build prompts can use such a registry.
+	Plugin string `json:"plugin,omitempty" yaml:"plugin"`
+}
+
+// ModelProfile describes a model: where to send prompts, how to count their tokens, how much fits and what
+// it costs
+type ModelProfile struct {
+	Provider string `json:"provider,omitempty" yaml:"provider"`
+	Model    string `json:"model" yaml:"model"`
+	// Tokenizer names how tokens are counted. Empty picks the tokenizer for the model.
+	Tokenizer string `json:"tokenizer,omitempty" yaml:"tokenizer"`
+	// MaxContext is the model's context window in tokens
+	MaxContext int `json:"max_context,omitempty" yaml:"max_context"`
+	// MaxOutputTokens is the number of tokens to generate, reserved out of MaxContext
+	MaxOutputTokens int `json:"max_output_tokens,omitempty" yaml:"max_output_tokens"`
+	// InputPrice and OutputPrice are in dollars per million tokens
+	InputPrice  float64 `json:"input_price,omitempty" yaml:"input_price"`
+	OutputPrice float64 `json:"output_price,omitempty" yaml:"output_price"`
+}
+
+// InputCost is the price in dollars of sending tokens to the model, zero when the profile has no pricing
+func (p *ModelProfile) InputCost(tokens int) float64 {
+	return float64(tokens) * p.InputPrice / 1e6
+}
+
+// ContextBudget is the number of tokens a prompt can use, the context window less the tokens reserved for
+// output. It is zero when the profile has no context window.
+func (p *ModelProfile) ContextBudget() int {
+	if p.MaxContext <= 0 {
+		return 0
+	}
+	return p.MaxContext - p.MaxOutputTokens
+}
+
+// Profile returns the named profile, or the default profile if name is empty. It returns nil without an
+// error when name is empty and there is no default.
+func (s *Settings) Profile(name string) (*ModelProfile, error) {
+	if name == "" {
+		name = s.DefaultProfile
+	}
+	if name == "" {
+		return nil, nil
+	}
+	profile, ok := s.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q", name)
+	}
+	return &profile, nil
+}
+
+// Merge returns a copy of s with the settings project sets layered over it. Profiles, registries and stacks are
+// merged by name, so a project can add them without hiding the user's. A nil project returns a copy of s.
+//
+// A project that sets a registry directory or selects a registry overrides the registry the user selected,
+// since the project's registry travels with it.
+func (s *Settings) Merge(project *Settings) *Settings {
+	merged := *s
+	if project == nil {
+		return &merged
+	}
+	if project.RegistryDir != "" {
+		merged.RegistryDir = project.RegistryDir
+		merged.CurrentRegistry = ""
+	}
+	if project.CurrentRegistry != "" {
+		merged.CurrentRegistry = project.CurrentRegistry
+	}
+	if len(project.Registries) > 0 {
+		merged.Registries = make(map[string]Registry, len(s.Registries)+len(project.Registries))
+		for name, r := range s.Registries {
+			merged.Registries[name] = r
+		}
+		for name, r := range project.Registries {
+			merged.Registries[name] = r
+		}
+	}
+	if project.Delims != "" {
+		merged.Delims = project.Delims
+	}
+	if project.Format != "" {
+		merged.Format = project.Format
+	}
+	if project.PII != "" {
+		merged.PII = project.PII
+	}
+	if project.Extensions != "" {
+		merged.Extensions = project.Extensions
+	}
+	if len(project.PostProcess) > 0 {
+		merged.PostProcess = project.PostProcess
+	}
+	if project.DefaultProfile != "" {
+		merged.DefaultProfile = project.DefaultProfile
+	}
+	merged.Defaults = s.Defaults.merge(project.Defaults)
+	if len(project.Profiles) > 0 {
+		merged.Profiles = make(map[string]ModelProfile, len(s.Profiles)+len(project.Profiles))
+		for name, p := range s.Profiles {
+			merged.Profiles[name] = p
+		}
+		for name, p := range project.Profiles {
+			merged.Profiles[name] = p
+		}
+	}
+	if len(project.Flags) > 0 {
+		merged.Flags = make(map[string]bool, len(s.Flags)+len(project.Flags))
+		for name, on := range s.Flags {
+			merged.Flags[name] = on
+		}
+		for name, on := range project.Flags {
+			merged.Flags[name] = on
+		}
+	}
+	if len(project.Stacks) > 0 {
+		merged.Stacks = make(map[string][]string, len(s.Stacks)+len(project.Stacks))
+		for name, templates := range s.Stacks {
+			merged.Stacks[name] = templates
+		}
+		for name, templates := range project.Stacks {
+			merged.Stacks[name] = templates
+		}
+	}
+	return &merged
+}
+
+// merge returns d with the defaults project sets layered over it. A project can turn FailOnWarn on but not off,
+// since false can't be told apart from not set.
+func (d FlagDefaults) merge(project FlagDefaults) FlagDefaults {
+	if project.Output != "" {
+		d.Output = project.Output
+	}
+	if project.Validation != "" {
+		d.Validation = project.Validation
+	}
+	d.FailOnWarn = d.FailOnWarn || project.FailOnWarn
+	if project.Provider != "" {
+		d.Provider = project.Provider
+	}
+	if project.Model != "" {
+		d.Model = project.Model
+	}
+	return d
+}
+
+// ProjectFiles are the names of the per-project settings files FindProject looks for, in order of preference
+var ProjectFiles = []string{".rprompt.json", "rprompt.yaml"}
+
+// FindProject returns the path of the per-project settings file in dir or the closest of its parents, so
+// the registry configuration can travel with a repository. The search stops at the root of the repository
+// dir is in, the first directory holding .git. It returns an empty path when there is no project file.
+func FindProject(start string) (string, error) {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", start, err)
+	}
+	for {
+		for _, name := range ProjectFiles {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return "", fmt.Errorf("failed to check for project settings: %w", err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// LoadProject reads a per-project settings file, as JSON or as YAML when it ends in .yaml or .yml. A relative
+// registry directory or output is resolved against the file's directory.
+func LoadProject(path string) (*Settings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project settings: %w", err)
+	}
+
+	var settings Settings
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &settings)
+	default:
+		err = json.Unmarshal(data, &settings)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse project settings %s: %w", path, err)
+	}
+	resolve := func(dir string) string {
+		if dir == "" || filepath.IsAbs(dir) {
+			return dir
+		}
+		return filepath.Join(filepath.Dir(path), dir)
+	}
+	settings.RegistryDir = resolve(settings.RegistryDir)
+	settings.Defaults.Output = resolve(settings.Defaults.Output)
+	for name, r := range settings.Registries {
+		r.Dir = resolve(r.Dir)
+		settings.Registries[name] = r
+	}
+	return &settings, nil
+}
+
+// Registry returns the named registry, or the current registry if name is empty. Without a current registry it
+// returns RegistryDir, or nil without an error when that isn't set either.
+func (s *Settings) Registry(name string) (*Registry, error) {
+	if name == "" {
+		name = s.CurrentRegistry
+	}
+	if name == "" {
+		if s.RegistryDir == "" {
+			return nil, nil
+		}
+		return &Registry{Dir: s.RegistryDir, Delims: s.Delims, PII: s.PII, Extensions: s.Extensions}, nil
+	}
+	registry, ok := s.Registries[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown registry %q", name)
+	}
+	if registry.Delims == "" {
+		registry.Delims = s.Delims
+	}
+	if registry.PII == "" {
+		registry.PII = s.PII
+	}
+	if registry.Extensions == "" {
+		registry.Extensions = s.Extensions
+	}
+	return &registry, nil
+}
+
+// Plugins returns the directory plugins are installed in and started from
+func (s *Settings) Plugins() (string, error) {
+	if s.PluginsDir != "" {
+		return s.PluginsDir, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".rprompt", "plugins"), nil
 }
 
 func getSettingsPath() (string, error) {
@@ -0,0 +1,148 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindProject(t *testing.T) {
+	root := t.TempDir()
+	repo := filepath.Join(root, "repo")
+	nested := filepath.Join(repo, "services", "api")
+	require.NoError(t, os.MkdirAll(nested, 0755))
+	require.NoError(t, os.Mkdir(filepath.Join(repo, ".git"), 0755))
+
+	// Files above the repository root aren't found
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".rprompt.json"), []byte(`{}`), 0644))
+	path, err := FindProject(nested)
+	require.NoError(t, err)
+	assert.Empty(t, path)
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "rprompt.yaml"), []byte(`format: text`), 0644))
+	path, err = FindProject(nested)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(repo, "rprompt.yaml"), path)
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo, ".rprompt.json"), []byte(`{}`), 0644))
+	path, err = FindProject(repo)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(repo, ".rprompt.json"), path, ".rprompt.json is preferred")
+}
+
+func TestLoadProject(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "rprompt.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`registry_dir: prompts
+delims: "{{,}}"
+format: messages-json
+default_profile: team
+profiles:
+  team: {provider: anthropic, model: claude-sonnet, max_context: 200000}
+registries:
+  docs: {dir: docs/prompts}
+defaults:
+  output: out/{{template_stem}}.txt
+  validation: strict
+`), 0644))
+
+	project, err := LoadProject(yamlPath)
+	require.NoError(t, err)
+	assert.Equal(t, &Settings{
+		RegistryDir:    filepath.Join(dir, "prompts"),
+		Delims:         "{{,}}",
+		Format:         "messages-json",
+		DefaultProfile: "team",
+		Profiles:       map[string]ModelProfile{"team": {Provider: "anthropic", Model: "claude-sonnet", MaxContext: 200000}},
+		Registries:     map[string]Registry{"docs": {Dir: filepath.Join(dir, "docs", "prompts")}},
+		Defaults:       FlagDefaults{Output: filepath.Join(dir, "out", "{{template_stem}}.txt"), Validation: "strict"},
+	}, project)
+
+	jsonPath := filepath.Join(dir, ".rprompt.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{"registry_dir": "/abs/prompts"}`), 0644))
+	project, err = LoadProject(jsonPath)
+	require.NoError(t, err)
+	assert.Equal(t, "/abs/prompts", project.RegistryDir)
+
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{`), 0644))
+	_, err = LoadProject(jsonPath)
+	assert.ErrorContains(t, err, "failed to parse project settings")
+}
+
+func TestSettings_Merge(t *testing.T) {
+	user := &Settings{
+		RegistryDir:    "/home/user/prompts",
+		Format:         "text",
+		DefaultProfile: "personal",
+		Profiles:       map[string]ModelProfile{"personal": {Model: "gpt-4o"}, "shared": {Model: "old"}},
+		Defaults:       FlagDefaults{Output: "/home/user/out.txt", Validation: "warn", Model: "gpt-4o"},
+		PluginsDir:     "/home/user/plugins",
+		Stacks:         map[string][]string{"mine": {"a.tmpl"}, "agent": {"old.tmpl"}},
+		PostProcess:    []string{"dedent"},
+		PII:            "warn",
+		Flags:          map[string]bool{"new-tone": true, "short": true},
+	}
+	merged := user.Merge(&Settings{
+		RegistryDir: "/repo/prompts",
+		Profiles:    map[string]ModelProfile{"shared": {Model: "new"}},
+		Defaults:    FlagDefaults{Validation: "strict", FailOnWarn: true},
+		PluginsDir:  "/repo/plugins",
+		Stacks:      map[string][]string{"agent": {"persona.tmpl", "task.tmpl"}},
+		PostProcess: []string{"collapse_blank_lines"},
+		PII:         "block",
+		Flags:       map[string]bool{"short": false},
+	})
+	assert.Equal(t, &Settings{
+		RegistryDir:    "/repo/prompts",
+		Format:         "text",
+		DefaultProfile: "personal",
+		Profiles:       map[string]ModelProfile{"personal": {Model: "gpt-4o"}, "shared": {Model: "new"}},
+		Defaults:       FlagDefaults{Output: "/home/user/out.txt", Validation: "strict", FailOnWarn: true, Model: "gpt-4o"},
+		PluginsDir:     "/home/user/plugins",
+		Stacks:         map[string][]string{"mine": {"a.tmpl"}, "agent": {"persona.tmpl", "task.tmpl"}},
+		PostProcess:    []string{"collapse_blank_lines"},
+		PII:            "block",
+		Flags:          map[string]bool{"new-tone": true, "short": false},
+	}, merged, "projects can't start plugins")
+	assert.Equal(t, "old", user.Profiles["shared"].Model, "the user's settings are left untouched")
+	assert.Equal(t, user, user.Merge(nil))
+}
+
+func TestSettings_Registry(t *testing.T) {
+	s := &Settings{
+		RegistryDir: "/prompts",
+		Delims:      "{{,}}",
+		PII:         "warn",
+		Registries: map[string]Registry{
+			"work":     {Dir: "/work"},
+			"personal": {Dir: "/personal", Delims: "<<,>>", PII: "mask"},
+		},
+	}
+	for _, tt := range []struct {
+		current, name string
+		expected      *Registry
+	}{
+		{"", "", &Registry{Dir: "/prompts", Delims: "{{,}}", PII: "warn"}},
+		{"work", "", &Registry{Dir: "/work", Delims: "{{,}}", PII: "warn"}},
+		{"work", "personal", &Registry{Dir: "/personal", Delims: "<<,>>", PII: "mask"}},
+	} {
+		s.CurrentRegistry = tt.current
+		registry, err := s.Registry(tt.name)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, registry)
+	}
+
+	_, err := s.Registry("missing")
+	assert.ErrorContains(t, err, `unknown registry "missing"`)
+	registry, err := (&Settings{}).Registry("")
+	require.NoError(t, err)
+	assert.Nil(t, registry)
+
+	// A project's registry directory wins over the registry the user selected
+	s.CurrentRegistry = "work"
+	assert.Empty(t, s.Merge(&Settings{RegistryDir: "/repo/prompts"}).CurrentRegistry)
+	assert.Equal(t, "personal", s.Merge(&Settings{CurrentRegistry: "personal"}).CurrentRegistry)
+}
@@ -0,0 +1,67 @@
+package prompt
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/notzree/rprompt/v2/prompt/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptSystem_Stacks(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "persona.tmpl", `[[role "system"]]You are [[.agent]].`)
+	createTestFile(t, tempDir, "task.tmpl", `[[role "user"]]Help with [[.topic]].`)
+	createTestFile(t, tempDir, "task.fr.tmpl", `[[role "user"]]Aidez avec [[.topic]].`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	system.Stacks = map[string][]string{
+		"support-agent": {"persona.tmpl", "task"},
+		"empty":         {},
+	}
+	ctx := context.Background()
+	data := WithData(map[string]any{"agent": "a support agent", "topic": "billing"})
+
+	result, err := system.BuildWithResult(ctx, StackPath("support-agent"), "", data, WithMessages())
+	require.NoError(t, err)
+	assert.Equal(t, "You are a support agent.\n\nHelp with billing.", result.Output)
+	assert.Equal(t, []Message{{Role: RoleSystem, Content: "You are a support agent."}, {Role: RoleUser, Content: "Help with billing."}}, result.Messages)
+	assert.Equal(t, []string{"persona.tmpl", "task.tmpl"}, result.Dependencies)
+	assert.Equal(t, []string{"agent", "topic"}, result.Variables)
+
+	output, err := system.Build(ctx, StackPath("support-agent"), "", data, WithLocale("fr"))
+	require.NoError(t, err)
+	assert.Equal(t, "You are a support agent.\n\nAidez avec billing.", output, "the templates' locale variants are used")
+
+	_, err = system.Build(ctx, StackPath("support-agent"), "", WithValue("agent", "x"))
+	var missing *MissingFieldsError
+	assert.ErrorAs(t, err, &missing, "the stack is validated as a whole")
+
+	_, err = system.Build(ctx, StackPath("empty"), "")
+	assert.ErrorContains(t, err, "stack empty has no templates")
+	_, err = system.Build(ctx, StackPath("missing"), "")
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+}
+
+func TestApp_GenerateStack(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "persona.tmpl", "You are [[.agent]].")
+	createTestFile(t, tempDir, "task.tmpl", "Help with [[.topic]].")
+	createTestFile(t, tempDir, "main.json", `{"agent": "a support agent", "topic": "billing"}`)
+	app, _, _ := newTestApp(t,
+		WithRegistry(NewInMemPromptRegistry(tempDir)),
+		WithSettings(&settings.Settings{Stacks: map[string][]string{"support-agent": {"persona.tmpl", "task.tmpl"}}}),
+	)
+	outDir := t.TempDir()
+	run := func(args ...string) error {
+		return app.Command().Run(context.Background(), append([]string{"rprompt", "generate", "-c", "main.json", "-o", filepath.Join(outDir, "{{template_stem}}.txt")}, args...))
+	}
+
+	require.NoError(t, run("--stack", "support-agent", "-t", "task.tmpl"))
+	assert.Equal(t, "You are a support agent.\n\nHelp with billing.", readTestFile(t, outDir, "support-agent.txt"))
+	assert.Equal(t, "Help with billing.", readTestFile(t, outDir, "task.txt"))
+	assert.ErrorContains(t, run("--stack", "other"), `unknown stack "other"`)
+	assert.ErrorContains(t, run(), "--template or --stack is required")
+}
@@ -0,0 +1,141 @@
+package prompt
+
+import (
+	"context"
+	"sort"
+
+	"github.com/notzree/rprompt/v2/utils"
+)
+
+// UsageCount is the number of templates that use a partial or variable
+type UsageCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// ConfigGaps lists the leaf values of a config that are still empty
+type ConfigGaps struct {
+	Path          string   `json:"path"`
+	MissingValues []string `json:"missing_values,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// RegistryStats summarizes the health of a registry
+type RegistryStats struct {
+	TemplateCount int `json:"template_count"`
+	// Token figures are estimated from the raw template source, not from rendered output
+	AvgTokens         float64           `json:"avg_tokens"`
+	MaxTokens         int               `json:"max_tokens"`
+	MaxTokensTemplate string            `json:"max_tokens_template,omitempty"`
+	Partials          []UsageCount      `json:"partials"`
+	Variables         []UsageCount      `json:"variables"`
+	IncompleteConfigs []ConfigGaps      `json:"incomplete_configs"`
+	ParseErrors       map[string]string `json:"parse_errors,omitempty"`
+}
+
+// ComputeStats walks every template and config in the registry and summarizes them
+func ComputeStats(ctx context.Context, r *LocalPromptRegistry) (*RegistryStats, error) {
+	templatePaths, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &RegistryStats{
+		TemplateCount:     len(templatePaths),
+		Partials:          []UsageCount{},
+		Variables:         []UsageCount{},
+		IncompleteConfigs: []ConfigGaps{},
+	}
+	partials := make(map[string]int)
+	variables := make(map[string]int)
+	totalTokens := 0
+
+	for _, path := range templatePaths {
+		template, err := r.Find(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens := EstimateTokens(template.Body())
+		totalTokens += tokens
+		if tokens > stats.MaxTokens {
+			stats.MaxTokens = tokens
+			stats.MaxTokensTemplate = path
+		}
+
+		// Only this template's own source is inspected so shared partials aren't counted once per includer
+		if err := template.Compile(); err != nil {
+			if stats.ParseErrors == nil {
+				stats.ParseErrors = make(map[string]string)
+			}
+			stats.ParseErrors[path] = err.Error()
+			continue
+		}
+		for _, dep := range template.FileDependencies() {
+			partials[r.dependencyPath(dep)]++
+		}
+		for _, variable := range utils.FlattenKeys(template.Walk(template.Tmpl.Tree.Root)) {
+			variables[variable]++
+		}
+	}
+	if len(templatePaths) > 0 {
+		stats.AvgTokens = float64(totalTokens) / float64(len(templatePaths))
+	}
+	stats.Partials = sortUsageCounts(partials)
+	stats.Variables = sortUsageCounts(variables)
+
+	configPaths, err := r.ListConfigs()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range configPaths {
+		cfg, err := r.LoadConfig(ctx, path)
+		if err != nil {
+			stats.IncompleteConfigs = append(stats.IncompleteConfigs, ConfigGaps{Path: path, Error: err.Error()})
+			continue
+		}
+		if missing := emptyValuePaths(cfg.Config, ""); len(missing) > 0 {
+			sort.Strings(missing)
+			stats.IncompleteConfigs = append(stats.IncompleteConfigs, ConfigGaps{Path: path, MissingValues: missing})
+		}
+	}
+
+	return stats, nil
+}
+
+// emptyValuePaths returns the dotted paths of leaves that are empty strings or null
+func emptyValuePaths(data map[string]any, prefix string) []string {
+	paths := []string{}
+	for k, v := range data {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		switch value := v.(type) {
+		case nil:
+			paths = append(paths, path)
+		case string:
+			if value == "" {
+				paths = append(paths, path)
+			}
+		case map[string]any:
+			paths = append(paths, emptyValuePaths(value, path)...)
+		}
+	}
+	return paths
+}
+
+// sortUsageCounts orders counts from most to least used, breaking ties by name
+func sortUsageCounts(counts map[string]int) []UsageCount {
+	result := make([]UsageCount, 0, len(counts))
+	for name, count := range counts {
+		result = append(result, UsageCount{Name: name, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
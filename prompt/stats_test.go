@@ -0,0 +1,50 @@
+package prompt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeStats(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "a.tmpl", `[[template "header.tmpl" .]] Hello [[.user.name]]`)
+	createTestFile(t, tempDir, "b.tmpl", `[[template "header" .]] Bye [[.user.name]] [[.reason]]`)
+	createTestFile(t, tempDir, "header.tmpl", `Header [[.site.title]] with a much longer body than the others`)
+	createTestFile(t, tempDir, "broken.tmpl", `[[if .x`)
+	createTestFile(t, tempDir, "a.json", `{"user": {"name": "John"}}`)
+	createTestFile(t, tempDir, "b.json", `{"user": {"name": ""}, "reason": null}`)
+	createTestFile(t, tempDir, "invalid.json", `{`)
+
+	stats, err := ComputeStats(context.Background(), NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, stats.TemplateCount)
+	assert.Equal(t, "header.tmpl", stats.MaxTokensTemplate)
+	assert.Equal(t, EstimateTokens(`Header [[.site.title]] with a much longer body than the others`), stats.MaxTokens)
+	assert.Greater(t, stats.AvgTokens, 0.0)
+
+	assert.Equal(t, []UsageCount{{Name: "header.tmpl", Count: 2}}, stats.Partials)
+	assert.Equal(t, []UsageCount{
+		{Name: "user.name", Count: 2},
+		{Name: "reason", Count: 1},
+		{Name: "site.title", Count: 1},
+	}, stats.Variables)
+
+	require.Len(t, stats.IncompleteConfigs, 2)
+	assert.Equal(t, ConfigGaps{Path: "b.json", MissingValues: []string{"reason", "user.name"}}, stats.IncompleteConfigs[0])
+	assert.Equal(t, "invalid.json", stats.IncompleteConfigs[1].Path)
+	assert.NotEmpty(t, stats.IncompleteConfigs[1].Error)
+
+	assert.Contains(t, stats.ParseErrors, "broken.tmpl")
+}
+
+func TestComputeStats_EmptyRegistry(t *testing.T) {
+	stats, err := ComputeStats(context.Background(), NewInMemPromptRegistry(setupTempDir(t)))
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.TemplateCount)
+	assert.Equal(t, 0.0, stats.AvgTokens)
+	assert.Empty(t, stats.Partials)
+}
@@ -1,11 +1,16 @@
 package prompt
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockRegistry is a mock implementation of PromptRegistry
@@ -13,7 +18,7 @@ type MockRegistry struct {
 	mock.Mock
 }
 
-func (m *MockRegistry) Find(path string) (*Template, error) {
+func (m *MockRegistry) Find(ctx context.Context, path string) (*Template, error) {
 	args := m.Called(path)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -21,7 +26,7 @@ func (m *MockRegistry) Find(path string) (*Template, error) {
 	return args.Get(0).(*Template), args.Error(1)
 }
 
-func (m *MockRegistry) LoadConfig(path string) (*Config, error) {
+func (m *MockRegistry) LoadConfig(ctx context.Context, path string) (*Config, error) {
 	args := m.Called(path)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -29,7 +34,7 @@ func (m *MockRegistry) LoadConfig(path string) (*Config, error) {
 	return args.Get(0).(*Config), args.Error(1)
 }
 
-func (m *MockRegistry) SaveConfig(config *Config) error {
+func (m *MockRegistry) SaveConfig(ctx context.Context, config *Config) error {
 	args := m.Called(config)
 	return args.Error(0)
 }
@@ -39,17 +44,17 @@ type MockTemplate struct {
 	mock.Mock
 }
 
-func (m *MockTemplate) Parse(config Config) error {
+func (m *MockTemplate) Parse(ctx context.Context, config Config) error {
 	args := m.Called(config)
 	return args.Error(0)
 }
 
-func (m *MockTemplate) Build(config Config) (string, error) {
+func (m *MockTemplate) Build(ctx context.Context, config Config) (string, error) {
 	args := m.Called(config)
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockTemplate) GenerateConfig(configPath string) (*Config, error) {
+func (m *MockTemplate) GenerateConfig(ctx context.Context, configPath string) (*Config, error) {
 	args := m.Called(configPath)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -70,11 +75,7 @@ func TestGenerateOrFillConfig(t *testing.T) {
 			configPath:   "test.json",
 			templatePath: "template.tmpl",
 			setupMock: func(m *MockRegistry) {
-				template := &Template{
-					Path:            "template.tmpl",
-					OriginalContent: "Hello [[.name]]",
-					r:               m,
-				}
+				template := NewTemplate("template.tmpl", "Hello [[.name]]", m)
 				m.On("Find", "template.tmpl").Return(template, nil)
 
 				existingConfig := &Config{
@@ -108,7 +109,7 @@ func TestGenerateOrFillConfig(t *testing.T) {
 			tt.setupMock(mockRegistry)
 
 			system, _ := NewPromptSystem(mockRegistry)
-			err := system.GenerateOrFillConfig(tt.templatePath, tt.configPath)
+			err := system.GenerateOrFillConfig(context.Background(), tt.templatePath, tt.configPath)
 
 			if tt.expectedError != "" {
 				assert.Error(t, err)
@@ -121,6 +122,42 @@ func TestGenerateOrFillConfig(t *testing.T) {
 	}
 }
 
+func TestGenerateOrFillConfig_KeepsExistingValues(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[.count]] [[.user.name]] [[.user.email]] [[range .items]][[.title]][[.url]][[end]] [[.profile.bio]] [[range .tags]][[.]][[end]]`)
+	createTestFile(t, tempDir, "main.json", `{"count": 3, "flag": true, "user": {"name": "Ada", "age": 36}, "items": [{"title": "a"}, {"title": "b", "url": "u"}, "c"], "profile": "", "tags": ["x"]}`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, system.GenerateOrFillConfig(ctx, "main.tmpl", "main.json"))
+	cfg, err := system.Registry.LoadConfig(ctx, "main.json")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"count": float64(3),
+		"flag":  true,
+		"user":  map[string]any{"name": "Ada", "age": float64(36), "email": ""},
+		"items": []any{
+			map[string]any{"title": "a", "url": ""},
+			map[string]any{"title": "b", "url": "u"},
+			"c",
+		},
+		"profile": map[string]any{"bio": ""},
+		"tags":    []any{"x"},
+	}, cfg.Config)
+
+	require.NoError(t, system.GenerateOrFillConfig(ctx, "main.tmpl", "new.json"))
+	cfg, err = system.Registry.LoadConfig(ctx, "new.json")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"count":   "",
+		"user":    map[string]any{"name": "", "email": ""},
+		"items":   []any{map[string]any{"title": "", "url": ""}},
+		"profile": map[string]any{"bio": ""},
+		"tags":    "",
+	}, cfg.Config, "a missing config is generated")
+}
+
 func TestBuild(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -179,7 +216,7 @@ func TestBuild(t *testing.T) {
 			tt.setupMock(mockRegistry)
 
 			system, _ := NewPromptSystem(mockRegistry)
-			result, err := system.Build(tt.templatePath, tt.configPath)
+			result, err := system.Build(context.Background(), tt.templatePath, tt.configPath)
 
 			if tt.expectedError != "" {
 				assert.Error(t, err)
@@ -193,3 +230,186 @@ func TestBuild(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildWithResult(t *testing.T) {
+	mockRegistry := &MockRegistry{}
+	template := NewTemplate("template.tmpl", `[[template "footer.tmpl" .]]Hello [[.user.name]]`, mockRegistry)
+	footer := NewTemplate("footer.tmpl", `[[range .items]][[.]][[end]]`, mockRegistry)
+	mockRegistry.On("Find", "template.tmpl").Return(template, nil)
+	mockRegistry.On("Find", "footer.tmpl").Return(footer, nil)
+	mockRegistry.On("LoadConfig", "config.json").Return(&Config{
+		Path: "config.json",
+		Config: map[string]interface{}{
+			"user": map[string]interface{}{
+				"name":  "John",
+				"email": "john@example.com",
+			},
+			"items": []interface{}{"a", "b"},
+			"extra": "unused",
+		},
+	}, nil)
+
+	system, _ := NewPromptSystem(mockRegistry)
+	result, err := system.BuildWithResult(context.Background(), "template.tmpl", "config.json")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "abHello John", result.Output)
+	assert.Equal(t, []string{"footer.tmpl"}, result.Dependencies)
+	assert.Equal(t, []string{"items", "user.name"}, result.Variables)
+	assert.Equal(t, []string{"extra", "user.email"}, result.UnusedKeys)
+	assert.Equal(t, EstimateTokens("abHello John"), result.Tokens)
+	assert.Greater(t, result.Duration, time.Duration(0))
+}
+
+func TestBuildTo(t *testing.T) {
+	mockRegistry := &MockRegistry{}
+	template := NewTemplate("template.tmpl", "Hello [[.name]]", mockRegistry)
+	mockRegistry.On("Find", "template.tmpl").Return(template, nil)
+	mockRegistry.On("LoadConfig", "config.json").Return(&Config{
+		Path:   "config.json",
+		Config: map[string]interface{}{"name": "John"},
+	}, nil)
+	mockRegistry.On("LoadConfig", "empty.json").Return(&Config{
+		Path:   "empty.json",
+		Config: map[string]interface{}{},
+	}, nil)
+
+	system, _ := NewPromptSystem(mockRegistry)
+
+	var buf bytes.Buffer
+	err := system.BuildTo(context.Background(), &buf, "template.tmpl", "config.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello John", buf.String())
+
+	// Missing fields are reported before anything is written
+	buf.Reset()
+	err = system.BuildTo(context.Background(), &buf, "template.tmpl", "empty.json")
+	assert.Error(t, err)
+	assert.Empty(t, buf.String())
+}
+
+func TestPromptBuilder_Build(t *testing.T) {
+	mockRegistry := &MockRegistry{}
+	template := NewTemplate("template.tmpl", "Hello [[.name]]", mockRegistry)
+	mockRegistry.On("Find", "template.tmpl").Return(template, nil)
+	mockRegistry.On("LoadConfig", "config.json").Return(&Config{
+		Path:   "config.json",
+		Config: map[string]interface{}{"name": "John"},
+	}, nil)
+
+	system, _ := NewPromptSystem(mockRegistry)
+	builder, err := system.NewBuilder(context.Background(), "template.tmpl", "config.json")
+	assert.NoError(t, err)
+	assert.Equal(t, system, builder.System)
+
+	result, err := builder.Build(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello John", result)
+}
+
+func TestPromptBuilder_Reuse(t *testing.T) {
+	mockRegistry := &MockRegistry{}
+	template := NewTemplate("template.tmpl", `[[template "header.tmpl" .]] Hello [[.name]]`, mockRegistry)
+	mockRegistry.On("Find", "template.tmpl").Return(template, nil)
+	mockRegistry.On("Find", "header.tmpl").Return(NewTemplate("header.tmpl", "Header", mockRegistry), nil)
+	mockRegistry.On("LoadConfig", "config.json").Return(NewConfig(map[string]any{"name": "John"}, "config.json"), nil)
+
+	system, _ := NewPromptSystem(mockRegistry)
+	ctx := context.Background()
+	builder, err := system.NewBuilder(ctx, "template.tmpl", "config.json")
+	require.NoError(t, err)
+
+	clone := builder.Clone()
+	clone.Config = NewConfig(map[string]any{"name": "Jane"}, "")
+
+	var wg sync.WaitGroup
+	outputs := make([]string, 10)
+	errs := make([]error, 10)
+	for i := range outputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b := builder
+			if i%2 == 1 {
+				b = clone
+			}
+			outputs[i], errs[i] = b.Build(ctx)
+		}(i)
+	}
+	wg.Wait()
+	for i := range outputs {
+		require.NoError(t, errs[i])
+		if i%2 == 1 {
+			assert.Equal(t, "Header Hello Jane", outputs[i])
+		} else {
+			assert.Equal(t, "Header Hello John", outputs[i])
+		}
+	}
+
+	// Dependencies were resolved once for the builder and all its clones
+	mockRegistry.AssertNumberOfCalls(t, "Find", 2)
+
+	// Replacing the config is still validated
+	clone.Config = NewConfig(map[string]any{}, "")
+	_, err = clone.Build(ctx)
+	var missingErr *MissingFieldsError
+	assert.ErrorAs(t, err, &missingErr)
+}
+
+func TestBuildSection(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "chat.tmpl", `[[define "system"]]You are [[.persona]].[[end]][[define "user"]]Hi, I'm [[.user.name]][[end]][[template "footer.tmpl" .]]`)
+	createTestFile(t, tempDir, "footer.tmpl", `[[block "signoff" .]]Bye [[.user.name]][[end]]`)
+	createTestFile(t, tempDir, "chat.json", `{"persona": "a pirate", "user": {"name": "John"}}`)
+	createTestFile(t, tempDir, "persona.json", `{"persona": "a pirate"}`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	output, err := system.BuildSection(ctx, "chat.tmpl", "system", "chat.json")
+	require.NoError(t, err)
+	assert.Equal(t, "You are a pirate.", output)
+
+	output, err = system.BuildSection(ctx, "chat.tmpl", "user", "chat.json")
+	require.NoError(t, err)
+	assert.Equal(t, "Hi, I'm John", output)
+
+	// Blocks declared in dependencies are available too
+	output, err = system.BuildSection(ctx, "chat.tmpl", "signoff", "chat.json")
+	require.NoError(t, err)
+	assert.Equal(t, "Bye John", output)
+
+	// Only the section's variables are required
+	output, err = system.BuildSection(ctx, "chat.tmpl", "system", "persona.json")
+	require.NoError(t, err)
+	assert.Equal(t, "You are a pirate.", output)
+
+	// The whole template still renders
+	output, err = system.Build(ctx, "chat.tmpl", "chat.json")
+	require.NoError(t, err)
+	assert.Equal(t, "Bye John", output)
+
+	_, err = system.BuildSection(ctx, "chat.tmpl", "missing", "chat.json")
+	assert.ErrorContains(t, err, `section "missing" not found`)
+	_, err = system.BuildSection(ctx, "chat.tmpl", "footer.tmpl", "chat.json")
+	assert.ErrorContains(t, err, `section "footer.tmpl" not found`)
+}
+
+func BenchmarkPromptSystem_Build(b *testing.B) {
+	tempDir := setupTempDir(b)
+	createTestFile(b, tempDir, "main.tmpl", `[[template "header.tmpl" .]] Hello [[.name]][[range .items]] [[.]][[end]]`)
+	createTestFile(b, tempDir, "header.tmpl", `# [[.title]]`)
+	createTestFile(b, tempDir, "main.json", `{"name": "World", "title": "Greeting", "items": ["a", "b", "c"]}`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := system.Build(ctx, "main.tmpl", "main.json"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package prompt
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/notzree/rprompt/v2/prompt/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTarget(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[if eq .provider "anthropic"]]<task>[[.task]]</task>[[else]]Task: [[.task]][[end]] ([[.model]])`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	system.Validation = ValidationStrict
+	ctx := context.Background()
+	task := WithValue("task", "summarize")
+
+	result, err := system.BuildWithResult(ctx, "main.tmpl", "", task)
+	require.NoError(t, err, "model and provider aren't required from the config")
+	assert.Equal(t, "Task: summarize ()", result.Output)
+	assert.Equal(t, []string{"task"}, result.Variables)
+
+	output, err := system.Build(ctx, "main.tmpl", "", task, WithTarget(Target{Provider: "anthropic", Model: "claude-sonnet-4"}))
+	require.NoError(t, err)
+	assert.Equal(t, "<task>summarize</task> (claude-sonnet-4)", output)
+
+	output, err = system.Build(ctx, "main.tmpl", "", task, WithValue("model", "pinned"), WithTarget(Target{Model: "gpt-4o"}))
+	require.NoError(t, err, "a config setting the model isn't reported unused")
+	assert.Equal(t, "Task: summarize (pinned)", output, "the config overrides the target")
+
+	info, err := system.Describe(ctx, "main.tmpl")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"task"}, info.Variables)
+}
+
+func TestApp_GenerateTarget(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "[[.provider]]/[[.model]]")
+	createTestFile(t, tempDir, "main.json", `{}`)
+	app, _, _ := newTestApp(t,
+		WithRegistry(NewInMemPromptRegistry(tempDir)),
+		WithSettings(&settings.Settings{
+			Profiles: map[string]settings.ModelProfile{"claude": {Provider: "anthropic", Model: "claude-sonnet-4"}},
+			Defaults: settings.FlagDefaults{Provider: "openai", Model: "gpt-4o"},
+		}),
+	)
+	outDir := t.TempDir()
+	generate := func(global []string, flags ...string) string {
+		args := append(append([]string{"rprompt"}, global...), "generate", "-t", "main.tmpl", "-c", "main.json", "-o", filepath.Join(outDir, "out.txt"))
+		require.NoError(t, app.Command().Run(context.Background(), append(args, flags...)))
+		return readTestFile(t, outDir, "out.txt")
+	}
+
+	assert.Equal(t, "openai/gpt-4o", generate(nil), "the defaults")
+	assert.Equal(t, "anthropic/claude-sonnet-4", generate([]string{"--profile", "claude"}))
+	assert.Equal(t, "anthropic/claude-opus-4", generate([]string{"--profile", "claude"}, "--model", "claude-opus-4"))
+	assert.Equal(t, "bedrock/gpt-4o", generate(nil, "--provider", "bedrock"))
+}
@@ -0,0 +1,69 @@
+package prompt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandOutputPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		template string
+		expected string
+	}{
+		{
+			name:     "no placeholders",
+			pattern:  "out/prompt.txt",
+			template: "agents/billing.tmpl",
+			expected: "out/prompt.txt",
+		},
+		{
+			name:     "template stem",
+			pattern:  "out/{{template_stem}}.txt",
+			template: "agents/billing.tmpl",
+			expected: "out/billing.txt",
+		},
+		{
+			name:     "template path",
+			pattern:  "out/{{template_path}}.txt",
+			template: "agents/billing.tmpl",
+			expected: "out/agents/billing.txt",
+		},
+		{
+			name:     "template dir",
+			pattern:  "out/{{template_dir}}/{{template_stem}}.md",
+			template: "agents/billing.tmpl",
+			expected: "out/agents/billing.md",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ExpandOutputPath(tt.pattern, tt.template))
+		})
+	}
+}
+
+func TestExpandOutputPaths(t *testing.T) {
+	outputs, err := ExpandOutputPaths("out/{{template_stem}}.txt", []string{"a.tmpl", "nested/b.tmpl"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"a.tmpl":        "out/a.txt",
+		"nested/b.tmpl": "out/b.txt",
+	}, outputs)
+
+	_, err = ExpandOutputPaths("out/prompt.txt", []string{"a.tmpl", "b.tmpl"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "must contain a placeholder")
+
+	_, err = ExpandOutputPaths("out/{{template_stem}}.txt", []string{"a/x.tmpl", "b/x.tmpl"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "would both be written to")
+
+	outputs, err = ExpandOutputPaths("out/prompt.txt", []string{"a.tmpl"})
+	require.NoError(t, err)
+	assert.Equal(t, "out/prompt.txt", outputs["a.tmpl"])
+}
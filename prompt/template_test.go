@@ -1,12 +1,16 @@
 package prompt
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"text/template/parse"
 
@@ -20,7 +24,7 @@ type MockPromptRegistry struct {
 	mock.Mock
 }
 
-func (m *MockPromptRegistry) Find(path string) (*Template, error) {
+func (m *MockPromptRegistry) Find(ctx context.Context, path string) (*Template, error) {
 	args := m.Called(path)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -28,7 +32,7 @@ func (m *MockPromptRegistry) Find(path string) (*Template, error) {
 	return args.Get(0).(*Template), args.Error(1)
 }
 
-func (m *MockPromptRegistry) LoadConfig(path string) (*Config, error) {
+func (m *MockPromptRegistry) LoadConfig(ctx context.Context, path string) (*Config, error) {
 	args := m.Called(path)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -36,13 +40,13 @@ func (m *MockPromptRegistry) LoadConfig(path string) (*Config, error) {
 	return args.Get(0).(*Config), args.Error(1)
 }
 
-func (m *MockPromptRegistry) SaveConfig(cfg *Config) error {
+func (m *MockPromptRegistry) SaveConfig(ctx context.Context, cfg *Config) error {
 	args := m.Called(cfg)
 	return args.Error(0)
 }
 
 // Setup helper function to create a temporary directory for testing
-func setupTempDir(t *testing.T) string {
+func setupTempDir(t testing.TB) string {
 	tempDir, err := os.MkdirTemp("", "template-test-*")
 	require.NoError(t, err)
 	t.Cleanup(func() {
@@ -52,30 +56,19 @@ func setupTempDir(t *testing.T) string {
 }
 
 // createTestFile is a helper to create test template files
-func createTestFile(t *testing.T, dir, name, content string) string {
+func createTestFile(t testing.TB, dir, name, content string) string {
 	path := filepath.Join(dir, name)
 	err := os.WriteFile(path, []byte(content), 0644)
 	require.NoError(t, err)
 	return path
 }
 
-// Test NewTemplate function
-func TestNewTemplate(t *testing.T) {
-	registry := &MockPromptRegistry{}
-	template := NewTemplate("test.tmpl", "This is a [[.test]] template", registry)
-
-	assert.Equal(t, "test.tmpl", template.Path)
-	assert.Equal(t, "This is a [[.test]] template", template.OriginalContent)
-	assert.NotNil(t, template.Tmpl)
-	assert.Equal(t, registry, template.r)
-}
-
 // Test GenerateConfig with a simple template
 func TestGenerateConfig_Simple(t *testing.T) {
 	registry := &MockPromptRegistry{}
 	template := NewTemplate("test.tmpl", "Hello [[.name]]", registry)
 
-	config, err := template.GenerateConfig("test_config.json")
+	config, err := template.GenerateConfig(context.Background(), "test_config.json")
 	require.NoError(t, err)
 
 	// Verify the config has the expected variables
@@ -105,7 +98,7 @@ func TestGenerateConfig_Complex(t *testing.T) {
 	`
 	template := NewTemplate("complex.tmpl", templateContent, registry)
 
-	config, err := template.GenerateConfig("complex_config.json")
+	config, err := template.GenerateConfig(context.Background(), "complex_config.json")
 	require.NoError(t, err)
 	log.Print(config.Config)
 	// Check nested structure
@@ -132,15 +125,24 @@ func TestGenerateConfig_ParseError(t *testing.T) {
 	templateContent := `Hello [[.name`
 	template := NewTemplate("error.tmpl", templateContent, registry)
 
-	_, err := template.GenerateConfig("error_config.json")
+	_, err := template.GenerateConfig(context.Background(), "error_config.json")
 	assert.Error(t, err)
 }
 
+func TestGenerateConfig_RecursiveDefine(t *testing.T) {
+	registry := &MockPromptRegistry{}
+	template := NewTemplate("tree.tmpl", `[[define "node"]][[.name]][[with .child]][[template "node" .]][[end]][[end]][[define "a"]][[.x]][[template "b" .]][[end]][[define "b"]][[.y]][[template "a" .]][[end]][[template "node" .]][[template "a" .]]`, registry)
+
+	config, err := template.GenerateConfig(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "", "child": map[string]any{}, "x": "", "y": ""}, config.Config)
+}
+
 // Test LoadDependencies with no registry
 func TestLoadDependencies_NoRegistry(t *testing.T) {
 	template := NewTemplate("test.tmpl", "Hello [[.name]]", nil)
 
-	err := template.LoadDependencies()
+	err := template.LoadDependencies(context.Background())
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "no registry set")
 }
@@ -150,7 +152,7 @@ func TestLoadDependencies_NoDependencies(t *testing.T) {
 	registry := &MockPromptRegistry{}
 	template := NewTemplate("test.tmpl", "Hello [[.name]]", registry)
 
-	err := template.LoadDependencies()
+	err := template.LoadDependencies(context.Background())
 	assert.NoError(t, err)
 }
 
@@ -169,7 +171,7 @@ func TestLoadDependencies_WithDependencies(t *testing.T) {
 	// Setup expectations
 	registry.On("Find", "footer.tmpl").Return(footerTemplate, nil)
 
-	err := template.LoadDependencies()
+	err := template.LoadDependencies(context.Background())
 	assert.NoError(t, err)
 	registry.AssertExpectations(t)
 }
@@ -206,7 +208,7 @@ func TestLoadDependencies_NestedDependencies(t *testing.T) {
 	registry.On("Find", "footer.tmpl").Return(footerTemplate, nil)
 	registry.On("Find", "logo.tmpl").Return(logoTemplate, nil)
 
-	err := mainTemplate.LoadDependencies()
+	err := mainTemplate.LoadDependencies(context.Background())
 	assert.NoError(t, err)
 	registry.AssertExpectations(t)
 }
@@ -228,7 +230,7 @@ func TestLoadDependencies_DependencyParseError(t *testing.T) {
 	// Setup expectations
 	registry.On("Find", "invalid.tmpl").Return(invalidTemplate, nil)
 
-	err := mainTemplate.LoadDependencies()
+	err := mainTemplate.LoadDependencies(context.Background())
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "error parsing dependent template")
 }
@@ -245,11 +247,40 @@ func TestLoadDependencies_CircularDependencies(t *testing.T) {
 
 	// Setup expectations
 	registry.On("Find", "b.tmpl").Return(templateB, nil)
-	// This should NOT be called a second time due to circular detection
+	// This should NOT be called due to circular detection
 	registry.On("Find", "a.tmpl").Return(templateA, nil).Maybe()
 
-	err := templateA.LoadDependencies()
-	assert.NoError(t, err) // Should handle circular deps gracefully
+	err := templateA.LoadDependencies(context.Background())
+	var cycleErr *DependencyCycleError
+	require.ErrorAs(t, err, &cycleErr)
+	assert.Equal(t, []string{"a.tmpl", "b.tmpl", "a.tmpl"}, cycleErr.Cycle)
+	assert.EqualError(t, err, "dependency cycle: a.tmpl -> b.tmpl -> a.tmpl")
+}
+
+func TestLoadDependencies_SelfInclude(t *testing.T) {
+	registry := &MockPromptRegistry{}
+	template := NewTemplate("a.tmpl", `[[template "a" .]]`, registry)
+
+	var cycleErr *DependencyCycleError
+	require.ErrorAs(t, template.LoadDependencies(context.Background()), &cycleErr)
+	assert.Equal(t, []string{"a.tmpl", "a.tmpl"}, cycleErr.Cycle)
+}
+
+// A file shared by several templates, and included under different names, is only loaded once
+func TestLoadDependencies_SharedDependency(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "left.tmpl" .]] [[template "right.tmpl" .]]`)
+	createTestFile(t, tempDir, "left.tmpl", `L [[template "shared" .]]`)
+	createTestFile(t, tempDir, "right.tmpl", `R [[template "shared.tmpl" .]]`)
+	createTestFile(t, tempDir, "shared.tmpl", `S`)
+	registry := NewInMemPromptRegistry(tempDir)
+
+	template, err := registry.Find(context.Background(), "main.tmpl")
+	require.NoError(t, err)
+	output, err := template.Build(context.Background(), *NewConfig(map[string]any{}, ""))
+	require.NoError(t, err)
+	assert.Equal(t, "L S R S", output)
+	assert.Equal(t, []string{"left.tmpl", "right.tmpl", "shared.tmpl"}, template.Dependencies())
 }
 
 // Test LoadDependencies when a dependency can't be found
@@ -263,7 +294,7 @@ func TestLoadDependencies_DependencyNotFound(t *testing.T) {
 	// Setup expectations
 	registry.On("Find", "missing.tmpl").Return(nil, fmt.Errorf("template not found"))
 
-	err := template.LoadDependencies()
+	err := template.LoadDependencies(context.Background())
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "error finding template")
 }
@@ -292,18 +323,18 @@ func TestWithLocalPromptRegistry(t *testing.T) {
 	registry := NewInMemPromptRegistry(tempDir)
 
 	// Test finding a template
-	template, err := registry.Find("main.tmpl")
+	template, err := registry.Find(context.Background(), "main.tmpl")
 	require.NoError(t, err)
 	assert.Equal(t, "main.tmpl", template.Path)
 	assert.Equal(t, mainContent, template.OriginalContent)
 
 	// Test loading dependencies
-	err = template.LoadDependencies()
+	err = template.LoadDependencies(context.Background())
 	require.NoError(t, err)
 
 	// Generate config
 	configPath := filepath.Join(tempDir, "main_config.json")
-	config, err := template.GenerateConfig(configPath)
+	config, err := template.GenerateConfig(context.Background(), configPath)
 	require.NoError(t, err)
 
 	// Check config structure
@@ -320,7 +351,7 @@ func TestWithLocalPromptRegistry(t *testing.T) {
 	assert.Contains(t, siteMap, "copyright")
 
 	// Test saving and loading config
-	err = registry.SaveConfig(config)
+	err = registry.SaveConfig(context.Background(), config)
 	require.NoError(t, err)
 
 	// Verify the file exists
@@ -328,7 +359,7 @@ func TestWithLocalPromptRegistry(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Test loading the config
-	loadedConfig, err := registry.LoadConfig(filepath.Base(configPath))
+	loadedConfig, err := registry.LoadConfig(context.Background(), filepath.Base(configPath))
 	require.NoError(t, err)
 
 	// Compare loaded config with original
@@ -427,7 +458,7 @@ func TestBuildNestedStructure(t *testing.T) {
 
 	// Test multiple nested levels
 	deepTemplate := NewTemplate("deep.tmpl", "[[.a.b.c.d.e]]", registry)
-	deepConfig, err := deepTemplate.GenerateConfig("")
+	deepConfig, err := deepTemplate.GenerateConfig(context.Background(), "")
 	require.NoError(t, err)
 
 	aMap, ok := deepConfig.Config["a"].(map[string]any)
@@ -476,7 +507,7 @@ func TestFindTemplateDependencies(t *testing.T) {
 	registry.On("Find", "regular.tmpl").Return(regularTemplate, nil)
 	registry.On("Find", "footer.tmpl").Return(footerTemplate, nil)
 
-	err := multiDepTemplate.LoadDependencies()
+	err := multiDepTemplate.LoadDependencies(context.Background())
 	assert.NoError(t, err)
 
 	// Verify all dependencies were found
@@ -505,7 +536,7 @@ func TestWithMockConfig(t *testing.T) {
 	config := NewConfig(data, configPath)
 
 	// Test saving
-	err := config.Save()
+	err := SaveConfigFile(config)
 	require.NoError(t, err)
 
 	// Verify file exists
@@ -569,11 +600,11 @@ func TestGenerateConfig_WithNestedDependencies(t *testing.T) {
 	registry.On("Find", "footer.tmpl").Return(footerTemplate, nil)
 
 	// First load dependencies
-	err := mainTemplate.LoadDependencies()
+	err := mainTemplate.LoadDependencies(context.Background())
 	require.NoError(t, err)
 
 	// Generate config
-	config, err := mainTemplate.GenerateConfig("test_config.json")
+	config, err := mainTemplate.GenerateConfig(context.Background(), "test_config.json")
 	require.NoError(t, err)
 
 	// Verify the config structure contains all expected fields
@@ -605,3 +636,150 @@ func TestGenerateConfig_WithNestedDependencies(t *testing.T) {
 	// Verify all dependencies were loaded
 	registry.AssertExpectations(t)
 }
+
+// Test LoadDependencies stops when the context is cancelled
+func TestLoadDependencies_ContextCancelled(t *testing.T) {
+	registry := &MockPromptRegistry{}
+	template := NewTemplate("main.tmpl", `[[template "footer.tmpl" .]]`, registry)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := template.LoadDependencies(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	registry.AssertNotCalled(t, "Find", "footer.tmpl")
+}
+
+// Test BuildTo writes the rendered template to the writer
+func TestTemplate_BuildTo(t *testing.T) {
+	registry := &MockPromptRegistry{}
+	template := NewTemplate("test.tmpl", "Hello [[.name]]", registry)
+
+	var buf bytes.Buffer
+	err := template.BuildTo(context.Background(), &buf, *NewConfig(map[string]any{"name": "John"}, ""))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello John", buf.String())
+}
+
+func TestTemplate_Sections(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[define "a"]]A[[end]][[template "a" .]][[template "part.tmpl" .]]`)
+	createTestFile(t, tempDir, "part.tmpl", `[[define "b"]]B[[end]]P`)
+	registry := NewInMemPromptRegistry(tempDir)
+
+	tmpl, err := registry.Find(context.Background(), "main.tmpl")
+	require.NoError(t, err)
+	require.NoError(t, tmpl.LoadDependencies(context.Background()))
+	assert.Equal(t, []string{"part.tmpl"}, tmpl.Dependencies())
+	assert.Equal(t, []string{"a", "b"}, tmpl.Sections())
+}
+
+// panicWriter panics on every write
+type panicWriter struct{}
+
+func (panicWriter) Write(p []byte) (int, error) {
+	panic("boom")
+}
+
+func TestTemplate_BuildToRecoversPanic(t *testing.T) {
+	registry := &MockPromptRegistry{}
+	template := NewTemplate("test.tmpl", "Hello [[.name]]", registry)
+
+	err := template.BuildTo(context.Background(), panicWriter{}, *NewConfig(map[string]any{"name": "John"}, ""))
+	var panicErr *RenderPanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "test.tmpl", panicErr.Path)
+	assert.Equal(t, "boom", panicErr.Value)
+	assert.Contains(t, panicErr.Stack, "executeRaw")
+	assert.Equal(t, ErrorTypePanic, ErrorType(err))
+}
+
+// largeTemplate returns a template with n actions spread over nested fields, ifs, ranges and withs
+func largeTemplate(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		switch i % 4 {
+		case 0:
+			fmt.Fprintf(&b, "[[.user.field%d]]\n", i)
+		case 1:
+			fmt.Fprintf(&b, "[[if .flags.f%d]][[.section%d.title]][[else]][[.fallback]][[end]]\n", i, i)
+		case 2:
+			fmt.Fprintf(&b, "[[range .items%d]][[.name]][[end]]\n", i)
+		case 3:
+			fmt.Fprintf(&b, "[[with .group%d]][[.label]] [[.value]][[end]]\n", i)
+		}
+	}
+	return b.String()
+}
+
+func TestGenerateConfig_LargeTemplate(t *testing.T) {
+	template := NewTemplate("large.tmpl", largeTemplate(1000), &MockPromptRegistry{})
+	cfg, err := template.GenerateConfig(context.Background(), "")
+	require.NoError(t, err)
+
+	assert.Len(t, cfg.Config["user"], 250)
+	assert.Equal(t, "", cfg.Config["fallback"])
+	assert.Equal(t, "", cfg.Config["flags"].(map[string]any)["f1"])
+	assert.Equal(t, map[string]any{"title": ""}, cfg.Config["section1"])
+	assert.Equal(t, []any{map[string]any{"name": ""}}, cfg.Config["items2"])
+	assert.Equal(t, map[string]any{"label": "", "value": ""}, cfg.Config["group3"])
+}
+
+func BenchmarkGenerateConfig_Large(b *testing.B) {
+	template := NewTemplate("large.tmpl", largeTemplate(1000), &MockPromptRegistry{})
+	ctx := context.Background()
+	if _, err := template.GenerateConfig(ctx, ""); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := template.GenerateConfig(ctx, ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTemplate_Parse(b *testing.B) {
+	content := largeTemplate(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := NewTemplate("large.tmpl", content, &MockPromptRegistry{}).Compile(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestTemplate_ConcurrentBuild(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "header.tmpl" .]] Hello [[.name]]`)
+	createTestFile(t, tempDir, "header.tmpl", `# [[.title]]`)
+	registry := NewInMemPromptRegistry(tempDir)
+	template, err := registry.Find(context.Background(), "main.tmpl")
+	require.NoError(t, err)
+	cfg := Config{Config: map[string]any{"name": "World", "title": "Greeting"}}
+
+	// Every build resolves the dependencies again into its own set while others execute
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			output, err := template.Build(context.Background(), cfg)
+			if err == nil && output != "# Greeting Hello World" {
+				err = fmt.Errorf("unexpected output %q", output)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"header.tmpl"}, template.Dependencies())
+	// The template's own set never gains its dependencies
+	assert.Nil(t, template.Tmpl.Lookup("header.tmpl"))
+}
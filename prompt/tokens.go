@@ -0,0 +1,13 @@
+package prompt
+
+import (
+	"github.com/notzree/rprompt/v2/prompt/settings"
+)
+
+// TokenizerForProfile returns the tokenizer a model profile names, or the one for its model
+func TokenizerForProfile(profile *settings.ModelProfile) (Tokenizer, error) {
+	if profile.Tokenizer != "" {
+		return TokenizerNamed(profile.Tokenizer)
+	}
+	return TokenizerForModel(profile.Model), nil
+}
@@ -0,0 +1,38 @@
+package prompt
+
+import (
+	"testing"
+
+	"github.com/notzree/rprompt/v2/prompt/settings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	assert.Equal(t, 0, EstimateTokens(""))
+	assert.Equal(t, 1, EstimateTokens("abc"))
+	assert.Equal(t, 1, EstimateTokens("abcd"))
+	assert.Equal(t, 2, EstimateTokens("abcde"))
+	// Multi-byte characters are counted once each
+	assert.Equal(t, 2, EstimateTokens("日本語のテキスト"))
+}
+
+func TestTokenizerForModel(t *testing.T) {
+	assert.Equal(t, 2, TokenizerForModel("gpt-4o")("abcdefg"))
+	assert.Equal(t, 2, TokenizerForModel("claude-sonnet")("abcdefg"))
+	assert.Equal(t, 3, TokenizerForModel("claude-sonnet")("abcdefgh"))
+	assert.Equal(t, 0, TokenizerForModel("claude-sonnet")(""))
+}
+
+func TestTokenizerForProfile(t *testing.T) {
+	tokenizer, err := TokenizerForProfile(&settings.ModelProfile{Model: "claude-sonnet", Tokenizer: TokenizerEstimate})
+	require.NoError(t, err)
+	assert.Equal(t, 2, tokenizer("abcdefgh"), "the profile's tokenizer wins over its model")
+
+	tokenizer, err = TokenizerForProfile(&settings.ModelProfile{Model: "claude-sonnet"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, tokenizer("abcdefgh"))
+
+	_, err = TokenizerForProfile(&settings.ModelProfile{Model: "x", Tokenizer: "bpe"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,65 @@
+package prompt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestPromptSystem_Tracing(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "header.tmpl" .]] [[.name]]`)
+	createTestFile(t, tempDir, "header.tmpl", `Header`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	recorder := tracetest.NewSpanRecorder()
+	system.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	_, err = system.Build(context.Background(), "main.tmpl", "", WithValue("name", "John"))
+	require.NoError(t, err)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	var names []string
+	for _, span := range recorder.Ended() {
+		names = append(names, span.Name())
+		spans[span.Name()+" "+templateAttribute(span)] = span
+	}
+	assert.Subset(t, names, []string{"rprompt.Find", "rprompt.ResolveDependencies", "rprompt.Execute", "rprompt.Build"})
+
+	build := spans["rprompt.Build main.tmpl"]
+	require.NotNil(t, build)
+	for _, name := range []string{"rprompt.Find main.tmpl", "rprompt.ResolveDependencies main.tmpl", "rprompt.Execute main.tmpl"} {
+		require.Contains(t, spans, name)
+		assert.Equal(t, build.SpanContext().SpanID(), spans[name].Parent().SpanID(), "%s is part of the build", name)
+	}
+	resolve := spans["rprompt.ResolveDependencies main.tmpl"]
+	assert.Equal(t, resolve.SpanContext().SpanID(), spans["rprompt.Find header.tmpl"].Parent().SpanID(), "dependency lookups are part of resolution")
+}
+
+func TestPromptSystem_TracingError(t *testing.T) {
+	tempDir := setupTempDir(t)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	recorder := tracetest.NewSpanRecorder()
+	system.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	_, err = system.Build(context.Background(), "missing.tmpl", "")
+	require.Error(t, err)
+	for _, span := range recorder.Ended() {
+		assert.Equal(t, codes.Error, span.Status().Code, span.Name())
+	}
+}
+
+func templateAttribute(span sdktrace.ReadOnlySpan) string {
+	for _, attr := range span.Attributes() {
+		if attr.Key == "rprompt.template" {
+			return attr.Value.AsString()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,365 @@
+//go:build !js && !wasip1
+
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/notzree/rprompt/v2/utils"
+)
+
+// tuiHelp lists the keys of the TUI in its footer
+const tuiHelp = "↑↓ template  c config  tab variables  space/b scroll  e edit value  w save  r reload  q quit"
+
+// tuiPane is what the right side of the TUI shows
+type tuiPane int
+
+const (
+	panePreview tuiPane = iota
+	paneVariables
+)
+
+// tuiModel is the Bubble Tea model of 'rprompt tui'. It only changes through Update and is drawn by View, so it
+// can be driven with messages without a terminal.
+type tuiModel struct {
+	ctx      context.Context
+	system   *PromptSystem
+	registry *LocalPromptRegistry
+	out      *Output
+
+	templates []string
+	// configs are the registry's configs, after "" for rendering without one
+	configs  []string
+	template int
+	config   int
+	// edits are values typed after e, rendered over the selected config until w saves them into it
+	edits map[string]any
+	pane  tuiPane
+	// scroll is the first line of the pane shown, page the number of lines the last view showed
+	scroll int
+	page   int
+	// editing is set while a key=value is typed into input
+	editing bool
+	input   string
+	status  string
+	quit    bool
+	// width and height are the terminal's size, from the last tea.WindowSizeMsg
+	width  int
+	height int
+
+	info   *TemplateInfo
+	result *BuildResult
+	values map[string]any
+	err    error
+}
+
+func newTUIModel(ctx context.Context, system *PromptSystem, registry *LocalPromptRegistry, out *Output) (*tuiModel, error) {
+	templates, err := registry.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(templates) == 0 {
+		return nil, fmt.Errorf("the registry in %s has no templates", registry.Directory)
+	}
+	configs, err := registry.ListConfigs()
+	if err != nil {
+		return nil, err
+	}
+	m := &tuiModel{
+		ctx:       ctx,
+		system:    system,
+		registry:  registry,
+		out:       out,
+		templates: templates,
+		configs:   append([]string{""}, configs...),
+		edits:     map[string]any{},
+		page:      1,
+		width:     80,
+		height:    24,
+	}
+	m.selectTemplate(0)
+	return m, nil
+}
+
+// selectTemplate selects the template at index i, along with its own config if it has one, e.g. main.json
+// for main.tmpl
+func (m *tuiModel) selectTemplate(i int) {
+	m.template = max(0, min(i, len(m.templates)-1))
+	m.scroll = 0
+	own := strings.TrimSuffix(m.templates[m.template], ".tmpl") + ".json"
+	for i, config := range m.configs {
+		if config == own {
+			m.config = i
+		}
+	}
+	m.render()
+}
+
+// render describes and builds the selected template with the selected config and the edits. Validation
+// issues are shown with the output instead of failing the build, so incomplete configs can be previewed.
+func (m *tuiModel) render() {
+	path, configPath := m.templates[m.template], m.configs[m.config]
+	m.info, m.result, m.values = nil, nil, nil
+	if m.info, m.err = m.system.Describe(m.ctx, path); m.err != nil {
+		return
+	}
+	m.values = map[string]any{}
+	if configPath != "" {
+		cfg, err := m.registry.LoadConfig(m.ctx, configPath)
+		if err != nil {
+			m.err = err
+			return
+		}
+		m.values = cfg.Config
+	}
+	m.values = utils.DeepMerge(m.values, m.edits)
+	m.result, m.err = m.system.BuildWithResult(m.ctx, path, configPath, WithData(m.edits), WithValidation(ValidationWarn))
+}
+
+// Init implements tea.Model. The first render happens in newTUIModel, so there is nothing to start.
+func (m *tuiModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update implements tea.Model, following the terminal's size and handling keys
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case tea.KeyMsg:
+		m.update(msg)
+		if m.quit {
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+// update handles a key pressed outside of editing
+func (m *tuiModel) update(key tea.KeyMsg) {
+	if m.editing {
+		m.updateInput(key)
+		return
+	}
+	m.status = ""
+	switch key.String() {
+	case "ctrl+c", "q":
+		m.quit = true
+	case "up", "k":
+		m.selectTemplate(m.template - 1)
+	case "down", "j":
+		m.selectTemplate(m.template + 1)
+	case "c":
+		m.config = (m.config + 1) % len(m.configs)
+		m.scroll = 0
+		m.render()
+	case "tab":
+		m.pane = (m.pane + 1) % 2
+		m.scroll = 0
+	case "pgdown", "ctrl+d", " ":
+		m.scroll += m.page
+	case "pgup", "ctrl+u", "b":
+		m.scroll = max(0, m.scroll-m.page)
+	case "e":
+		m.editing, m.input = true, ""
+	case "w":
+		m.save()
+	case "r":
+		m.render()
+		m.status = "Reloaded"
+	}
+}
+
+// updateInput edits the key=value typed after e, and sets the value when enter is pressed
+func (m *tuiModel) updateInput(key tea.KeyMsg) {
+	switch key.Type {
+	case tea.KeyEsc, tea.KeyCtrlC:
+		m.editing = false
+	case tea.KeyBackspace:
+		if _, size := utf8.DecodeLastRuneInString(m.input); size > 0 {
+			m.input = m.input[:len(m.input)-size]
+		}
+	case tea.KeyEnter:
+		m.editing = false
+		path, value, ok := strings.Cut(m.input, "=")
+		path = strings.TrimSpace(path)
+		if !ok || !variablePathPattern.MatchString(path) {
+			m.status = "Expected a variable and its value, such as user.name=Ana"
+			return
+		}
+		utils.SetPath(m.edits, path, parseEditValue(strings.TrimSpace(value)))
+		m.render()
+	case tea.KeySpace:
+		m.input += " "
+	case tea.KeyRunes:
+		// Pasted text arrives as a single key of several runes
+		m.input += string(key.Runes)
+	}
+}
+
+// save merges the edits into the selected config
+func (m *tuiModel) save() {
+	configPath := m.configs[m.config]
+	switch {
+	case len(m.edits) == 0:
+		m.status = "No edits to save"
+		return
+	case configPath == "":
+		m.status = "Select a config with c to save the edits into"
+		return
+	}
+	cfg, err := m.registry.LoadConfig(m.ctx, configPath)
+	if err == nil {
+		cfg.Config = utils.DeepMerge(cfg.Config, m.edits)
+		err = m.registry.SaveConfig(m.ctx, cfg)
+	}
+	if err != nil {
+		m.status = fmt.Sprintf("Failed to save %s: %v", configPath, err)
+		return
+	}
+	m.edits = map[string]any{}
+	m.status = "Saved " + configPath
+	m.render()
+}
+
+// View draws the TUI as lines of at most the terminal's width: a header, the template list beside the preview
+// or variables, and a footer
+func (m *tuiModel) View() string {
+	width, height := max(1, m.width), max(3, m.height)
+	configLabel := m.configs[m.config]
+	if configLabel == "" {
+		configLabel = "(none)"
+	}
+	if len(m.edits) > 0 {
+		configLabel += fmt.Sprintf(" + %d edited", len(utils.FlattenKeys(m.edits)))
+	}
+	lines := []string{m.out.colorize(colorBold, fitWidth(fmt.Sprintf("rprompt  %s  config: %s", m.registry.Directory, configLabel), width))}
+
+	bodyHeight := max(1, height-2)
+	listWidth := min(32, width/3)
+	paneWidth := max(1, width-listWidth-3)
+	m.page = bodyHeight
+
+	first := max(0, m.template-bodyHeight+1)
+	pane := m.paneLines(paneWidth)
+	m.scroll = max(0, min(m.scroll, len(pane)-1))
+	for row := 0; row < bodyHeight; row++ {
+		left := ""
+		if i := first + row; i < len(m.templates) {
+			left = fitWidth(m.templates[i], listWidth)
+			if i == m.template {
+				left = m.out.colorize(colorReverse, left)
+			}
+		} else {
+			left = fitWidth("", listWidth)
+		}
+		right := ""
+		if i := m.scroll + row; i < len(pane) {
+			right = pane[i]
+		}
+		lines = append(lines, left+" │ "+right)
+	}
+
+	switch {
+	case m.editing:
+		lines = append(lines, fitWidth("Set (variable=value, esc cancels): "+m.input+"█", width))
+	case m.status != "":
+		lines = append(lines, fitWidth(m.status, width))
+	default:
+		lines = append(lines, m.out.colorize(colorGray, fitWidth(tuiHelp, width)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// paneLines are the lines of the preview or variables, wrapped to width
+func (m *tuiModel) paneLines(width int) []string {
+	var text []string
+	switch {
+	case m.err != nil:
+		return colorLines(m.out, colorRed, wrapLines("Error: "+m.err.Error(), width))
+	case m.pane == paneVariables:
+		text = append(text, "Variables:")
+		for _, variable := range m.info.Variables {
+			value := "(not set)"
+			if v, ok := utils.GetPath(m.values, variable); ok {
+				data, _ := json.Marshal(v)
+				value = string(data)
+			}
+			if _, edited := utils.GetPath(m.edits, variable); edited {
+				value += " (edited)"
+			}
+			text = append(text, "  "+variable+" = "+value)
+		}
+		if len(m.info.Dependencies) > 0 {
+			text = append(text, "", "Includes: "+strings.Join(m.info.Dependencies, ", "))
+		}
+	default:
+		text = append(text, m.result.Output)
+	}
+	lines := wrapLines(strings.Join(text, "\n"), width)
+	if m.pane == panePreview && m.result != nil && len(m.result.Issues) > 0 {
+		issues := []string{""}
+		for _, issue := range m.result.Issues {
+			issues = append(issues, "! "+issue.Message)
+		}
+		lines = append(lines, colorLines(m.out, colorYellow, wrapLines(strings.Join(issues, "\n"), width))...)
+	}
+	return lines
+}
+
+// colorLines colors every line on its own, so scrolling never cuts a color off from its reset
+func colorLines(out *Output, color string, lines []string) []string {
+	for i, line := range lines {
+		lines[i] = out.colorize(color, line)
+	}
+	return lines
+}
+
+// wrapLines splits text into lines of at most width characters, breaking long lines wherever they reach it
+func wrapLines(text string, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\t", "    "), "\n") {
+		runes := []rune(strings.TrimRight(line, "\r"))
+		for len(runes) > width {
+			lines = append(lines, string(runes[:width]))
+			runes = runes[width:]
+		}
+		lines = append(lines, string(runes))
+	}
+	return lines
+}
+
+// fitWidth cuts s to width characters, or pads it with spaces to width
+func fitWidth(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) > width {
+		return string(runes[:max(0, width-1)]) + "…"
+	}
+	return s + strings.Repeat(" ", width-len(runes))
+}
+
+// browseTUI runs 'rprompt tui' on the terminal
+func browseTUI(ctx context.Context, system *PromptSystem, registry *LocalPromptRegistry, out *Output) error {
+	model, err := newTUIModel(ctx, system, registry, out)
+	if err != nil {
+		return err
+	}
+	return runTUI(ctx, model, os.Stdin, out.Out)
+}
+
+// runTUI shows the model full screen, reading keys from in and drawing to out, until a key quits it or ctx
+// ends
+func runTUI(ctx context.Context, m *tuiModel, in io.Reader, out io.Writer) error {
+	program := tea.NewProgram(m, tea.WithContext(ctx), tea.WithInput(in), tea.WithOutput(out), tea.WithAltScreen())
+	if _, err := program.Run(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to run the TUI: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build js || wasip1
+
+package prompt
+
+import (
+	"context"
+	"errors"
+)
+
+// browseTUI fails on platforms without a terminal for Bubble Tea to run on
+func browseTUI(ctx context.Context, system *PromptSystem, registry *LocalPromptRegistry, out *Output) error {
+	return errors.New("rprompt tui isn't supported on this platform")
+}
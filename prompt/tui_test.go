@@ -0,0 +1,123 @@
+//go:build !js && !wasip1
+
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// typeKeys returns the key messages of typing s, with tab and carriage return as their keys
+func typeKeys(s string) []tea.KeyMsg {
+	var keys []tea.KeyMsg
+	for _, r := range s {
+		switch r {
+		case '\t':
+			keys = append(keys, tea.KeyMsg{Type: tea.KeyTab})
+		case '\r':
+			keys = append(keys, tea.KeyMsg{Type: tea.KeyEnter})
+		case ' ':
+			keys = append(keys, tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{r}})
+		default:
+			keys = append(keys, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		}
+	}
+	return keys
+}
+
+func TestTUIModel(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "a.tmpl", `Hello [[.name]] [[template "b.tmpl" .]]`)
+	createTestFile(t, tempDir, "a.json", `{"name": "John"}`)
+	createTestFile(t, tempDir, "b.tmpl", `from [[.team]]`)
+	registry := NewInMemPromptRegistry(tempDir)
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	out := NewOutput(&bytes.Buffer{}, &bytes.Buffer{})
+	m, err := newTUIModel(context.Background(), system, registry, out)
+	require.NoError(t, err)
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 8})
+	screen := func() string {
+		t.Helper()
+		view := m.View()
+		require.Len(t, strings.Split(view, "\n"), 8)
+		return view
+	}
+	press := func(keys string) {
+		for _, key := range typeKeys(keys) {
+			m.Update(key)
+		}
+	}
+
+	assert.Equal(t, "a.json", m.configs[m.config], "a template's own config is selected")
+	assert.Contains(t, screen(), "Hello John from <no value>")
+	assert.Contains(t, screen(), "! ", "missing values are shown as issues")
+
+	press("\t")
+	assert.Contains(t, screen(), `name = "John"`)
+	assert.Contains(t, screen(), "team = (not set)")
+
+	press("eteam=\"Core\"\r")
+	assert.Contains(t, screen(), `team = "Core" (edited)`)
+	assert.Contains(t, screen(), "config: a.json + 1 edited")
+	press("e=oops\r")
+	assert.Contains(t, screen(), "Expected a variable")
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	assert.Equal(t, "b.tmpl", m.templates[m.template])
+	m.Update(tea.KeyMsg{Type: tea.KeyUp})
+
+	press("w")
+	assert.Equal(t, "Saved a.json", m.status)
+	assert.JSONEq(t, `{"name": "John", "team": "Core"}`, readTestFile(t, tempDir, "a.json"))
+	press("\t")
+	assert.Contains(t, screen(), "Hello John from Core")
+
+	press("j")
+	assert.Equal(t, "b.tmpl", m.templates[m.template])
+	assert.Contains(t, screen(), "from Core", "b.tmpl has no config of its own, so a.json stays selected")
+	press("c")
+	assert.Equal(t, "", m.configs[m.config])
+	press("w")
+	assert.Equal(t, "No edits to save", m.status)
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	require.NotNil(t, cmd)
+	assert.Equal(t, tea.QuitMsg{}, cmd())
+}
+
+func TestRunTUI(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "a.tmpl", `Hello [[.name]]`)
+	createTestFile(t, tempDir, "b.tmpl", `Bye`)
+	registry := NewInMemPromptRegistry(tempDir)
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	m, err := newTUIModel(context.Background(), system, registry, NewOutput(&bytes.Buffer{}, &bytes.Buffer{}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var out bytes.Buffer
+	require.NoError(t, runTUI(ctx, m, strings.NewReader("\x1b[Bq"), &out))
+	assert.True(t, m.quit, "the TUI quit on q rather than timing out")
+	assert.Equal(t, "b.tmpl", m.templates[m.template])
+	assert.Contains(t, out.String(), "Bye")
+}
+
+func TestWrapLines(t *testing.T) {
+	assert.Equal(t, []string{"abcd", "ef", "", "gh"}, wrapLines("abcdef\n\ngh", 4))
+	assert.Equal(t, "ab…", fitWidth("abcdef", 3))
+	assert.Equal(t, "ab  ", fitWidth("ab", 4))
+}
+
+func TestApp_TUI(t *testing.T) {
+	app, _, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(setupTempDir(t))))
+	err := app.Command().Run(context.Background(), []string{"rprompt", "tui"})
+	assert.ErrorContains(t, err, "needs an interactive terminal")
+}
@@ -0,0 +1,168 @@
+package prompt
+
+import (
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//go:embed ui/index.html
+var uiPage []byte
+
+// uiPollInterval is how often the web UI's event stream checks the registry directory for changes
+const uiPollInterval = time.Second
+
+// EnableUI adds a web UI at /ui/ for browsing templates, editing configs and previewing renders, which
+// reloads whenever a file in the registry changes. It adds these routes:
+//
+//	GET /ui/                     the page
+//	GET /ui/events               a server-sent event stream with a change event whenever the registry changes
+//	GET /configs                 lists the registry's configs
+//	GET /configs/{path}          returns a config's values
+//	PUT /configs/{path}          saves the JSON body as a config, recorded as a new version, see History
+//
+// Saving configs is only possible through the API once the UI is enabled, so only enable it where everyone
+// who can reach the server may change configs.
+func (s *HTTPServer) EnableUI() {
+	if s.uiPoll == 0 {
+		s.uiPoll = uiPollInterval
+	}
+	s.mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ui/", http.StatusFound)
+	})
+	s.mux.HandleFunc("GET /ui/{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(uiPage)
+	})
+	s.mux.HandleFunc("GET /ui/events", s.registryEvents)
+	s.mux.HandleFunc("GET /configs", s.listConfigs)
+	s.mux.HandleFunc("GET /configs/{path...}", s.getConfig)
+	s.mux.HandleFunc("PUT /configs/{path...}", s.putConfig)
+}
+
+func (s *HTTPServer) listConfigs(w http.ResponseWriter, r *http.Request) {
+	configs, err := s.registry.ListConfigs()
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"configs": configs})
+}
+
+func (s *HTTPServer) getConfig(w http.ResponseWriter, r *http.Request) {
+	path := r.PathValue("path")
+	if err := checkConfigPath(path); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	cfg, err := s.registry.LoadConfig(r.Context(), path)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, cfg.Config)
+}
+
+func (s *HTTPServer) putConfig(w http.ResponseWriter, r *http.Request) {
+	path := r.PathValue("path")
+	if err := checkConfigPath(path); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	data, err := readConfigBody(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := s.registry.SaveConfig(r.Context(), NewConfig(data, path)); err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, data)
+}
+
+// checkConfigPath rejects config paths the API mustn't read or write, such as ones outside the registry
+func checkConfigPath(path string) error {
+	if !strings.HasSuffix(path, ".json") || !filepath.IsLocal(filepath.FromSlash(path)) {
+		return fmt.Errorf("config path %s must be a .json file inside the registry", path)
+	}
+	return nil
+}
+
+// registryEvents streams a change event whenever a file in the registry changes, until the client leaves
+func (s *HTTPServer) registryEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming is not supported"})
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	last, _ := registryFingerprint(r.Context(), s.registry.Directory)
+	ticker := time.NewTicker(s.uiPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+		current, err := registryFingerprint(r.Context(), s.registry.Directory)
+		if err != nil || current == last {
+			continue
+		}
+		last = current
+		if _, err := fmt.Fprint(w, "event: change\ndata: {}\n\n"); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// registryFingerprint hashes the path, size and modification time of every file in dir, skipping hidden
+// files and directories such as the history, so it changes whenever a template or config is edited
+func registryFingerprint(ctx context.Context, dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// Removed while walking
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,94 @@
+package prompt
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestUIServer(t *testing.T, dir string) *httptest.Server {
+	server, err := NewHTTPServer(NewInMemPromptRegistry(dir))
+	require.NoError(t, err)
+	server.uiPoll = 10 * time.Millisecond
+	server.EnableUI()
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestHTTPServer_UI(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "greeting.tmpl", `Hello [[.name]]`)
+	createTestFile(t, tempDir, "greeting.json", `{"name": "John"}`)
+	ts := newTestUIServer(t, tempDir)
+
+	resp, err := http.Get(ts.URL + "/")
+	require.NoError(t, err)
+	page, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, ts.URL+"/ui/", resp.Request.URL.String(), "/ redirects to the UI")
+	assert.Contains(t, string(page), "<title>rprompt</title>")
+
+	var configs map[string][]string
+	assert.Equal(t, http.StatusOK, doJSON(t, "GET", ts.URL+"/configs", "", &configs))
+	assert.Equal(t, []string{"greeting.json"}, configs["configs"])
+
+	var values map[string]any
+	assert.Equal(t, http.StatusOK, doJSON(t, "PUT", ts.URL+"/configs/greeting.json", `{"name": "Jane"}`, &values))
+	assert.Equal(t, http.StatusOK, doJSON(t, "GET", ts.URL+"/configs/greeting.json", "", &values))
+	assert.Equal(t, map[string]any{"name": "Jane"}, values)
+
+	var errResp map[string]string
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, "PUT", ts.URL+"/configs/..%2Foutside.json", `{}`, &errResp))
+	assert.Contains(t, errResp["error"], "inside the registry")
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(tempDir), "outside.json"))
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, "GET", ts.URL+"/configs/greeting.tmpl", "", &errResp))
+	assert.Equal(t, http.StatusNotFound, doJSON(t, "GET", ts.URL+"/configs/missing.json", "", &errResp))
+}
+
+func TestHTTPServer_UIRequiresEnableUI(t *testing.T) {
+	ts := newTestHTTPServer(t)
+	resp, err := http.Get(ts.URL + "/ui/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	req, err := http.NewRequest("PUT", ts.URL+"/configs/base.json", strings.NewReader("{}"))
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.NotEqual(t, http.StatusOK, resp.StatusCode, "configs can't be written without the UI")
+}
+
+func TestHTTPServer_UIEvents(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "greeting.tmpl", `Hello`)
+	ts := newTestUIServer(t, tempDir)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", ts.URL+"/ui/events", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// Give the stream time to take its first fingerprint before changing the registry
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "greeting.tmpl"), []byte("Hello again"), 0644))
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "event: change\n", line)
+}
@@ -0,0 +1,138 @@
+package prompt
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// UsageSource is implemented by usage sinks that can tell when templates were last rendered
+type UsageSource interface {
+	// LastUsed maps every template rendered so far, directly or as a dependency, to when it last was
+	LastUsed(ctx context.Context) (map[string]time.Time, error)
+}
+
+// UsageLog is a UsageSink and UsageSource appending each record as a line of JSON to a file. It grows with
+// every render, so it suits CLI use and servers that rotate it. It is safe for concurrent use.
+type UsageLog struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// NewUsageLog creates a UsageLog writing to path. The file and its directory are created on the first record.
+func NewUsageLog(path string) *UsageLog {
+	return &UsageLog{Path: path}
+}
+
+// Record appends record to the log
+func (l *UsageLog) Record(ctx context.Context, record UsageRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("err encoding usage record: %w", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := appendLine(l.Path, data); err != nil {
+		return fmt.Errorf("failed to write usage log %s: %w", l.Path, err)
+	}
+	return nil
+}
+
+// LastUsed reads the log, see UsageSource. A log that doesn't exist yet has no usage.
+func (l *UsageLog) LastUsed(ctx context.Context) (map[string]time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lastUsed := make(map[string]time.Time)
+	f, err := os.Open(l.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return lastUsed, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage log %s: %w", l.Path, err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("invalid usage log %s:%d: %w", l.Path, line, err)
+		}
+		for _, path := range append([]string{record.Template}, record.Dependencies...) {
+			if record.Time.After(lastUsed[path]) {
+				lastUsed[path] = record.Time
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage log %s: %w", l.Path, err)
+	}
+	return lastUsed, nil
+}
+
+// UnusedTemplate is a template that hasn't been rendered recently, see UnusedTemplates
+type UnusedTemplate struct {
+	Path string `json:"path"`
+	// LastUsed is when the template was last rendered, nil if it never was
+	LastUsed *time.Time `json:"last_used,omitempty"`
+}
+
+// UnusedTemplates returns the templates in the registry that usage shows weren't rendered, directly or as a
+// dependency, since the given time, sorted by path
+func UnusedTemplates(ctx context.Context, r *LocalPromptRegistry, usage UsageSource, since time.Time) ([]UnusedTemplate, error) {
+	lastUsed, err := usage.LastUsed(ctx)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	unused := []UnusedTemplate{}
+	for _, path := range paths {
+		used, ok := lastUsed[path]
+		switch {
+		case !ok:
+			unused = append(unused, UnusedTemplate{Path: path})
+		case used.Before(since):
+			unused = append(unused, UnusedTemplate{Path: path, LastUsed: &used})
+		}
+	}
+	return unused, nil
+}
+
+// agePattern matches ages in days or weeks, such as 90d or 12w
+var agePattern = regexp.MustCompile(`^([0-9]+)([dw])$`)
+
+// ParseSince parses a point in time relative to now: an age in days or weeks such as 90d or 12w, a duration
+// such as 36h, or a date such as 2026-01-31
+func ParseSince(since string, now time.Time) (time.Time, error) {
+	if m := agePattern.FindStringSubmatch(since); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid age %q: %w", since, err)
+		}
+		days := n
+		if m[2] == "w" {
+			days = 7 * n
+		}
+		return now.AddDate(0, 0, -days), nil
+	}
+	if d, err := time.ParseDuration(since); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, since, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected an age such as 90d or 12w, a duration such as 36h or a date such as 2026-01-31", since)
+}
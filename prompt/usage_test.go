@@ -0,0 +1,139 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingUsage keeps every usage record in memory
+type recordingUsage struct {
+	records []UsageRecord
+	err     error
+}
+
+func (s *recordingUsage) Record(ctx context.Context, record UsageRecord) error {
+	s.records = append(s.records, record)
+	return s.err
+}
+
+func TestUsage_Records(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[define "intro"]]Hi[[end]][[template "header.tmpl" .]] Hello [[.name]]`)
+	createTestFile(t, tempDir, "header.tmpl", `Header`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	usage := &recordingUsage{}
+	system.Usage = usage
+	ctx := context.Background()
+
+	_, err = system.Build(ctx, "main.tmpl", "", WithValue("name", "John"))
+	require.NoError(t, err)
+	builder, err := system.NewBuilder(ctx, "main.tmpl", "", WithValue("name", "Jane"))
+	require.NoError(t, err)
+	require.NoError(t, builder.BuildTo(ctx, &bytes.Buffer{}))
+	_, err = system.BuildSection(ctx, "main.tmpl", "intro", "")
+	require.NoError(t, err)
+	_, err = system.Build(ctx, "main.tmpl", "", WithValidation(ValidationStrict))
+	require.Error(t, err)
+
+	require.Len(t, usage.records, 3, "failed builds aren't usage")
+	for _, record := range usage.records {
+		assert.Equal(t, "main.tmpl", record.Template)
+		assert.Equal(t, []string{"header.tmpl"}, record.Dependencies)
+		assert.False(t, record.Time.IsZero())
+	}
+
+	usage.err = errors.New("disk full")
+	_, err = system.Build(ctx, "main.tmpl", "", WithValue("name", "John"))
+	assert.NoError(t, err, "usage tracking doesn't fail builds")
+}
+
+func TestUsageLog(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "header.tmpl" .]]`)
+	createTestFile(t, tempDir, "header.tmpl", `Header`)
+	createTestFile(t, tempDir, "old.tmpl", `Old`)
+	createTestFile(t, tempDir, "dead.tmpl", `Dead`)
+	registry := NewInMemPromptRegistry(tempDir)
+	log := NewUsageLog(filepath.Join(tempDir, "logs", "usage.jsonl"))
+	ctx := context.Background()
+
+	lastUsed, err := log.LastUsed(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, lastUsed, "no log yet")
+
+	monthAgo := time.Now().AddDate(0, -1, 0).UTC()
+	require.NoError(t, log.Record(ctx, UsageRecord{Time: monthAgo, Template: "old.tmpl"}))
+	system, err := NewPromptSystem(registry)
+	require.NoError(t, err)
+	system.Usage = log
+	_, err = system.Build(ctx, "main.tmpl", "")
+	require.NoError(t, err)
+
+	lastUsed, err = log.LastUsed(ctx)
+	require.NoError(t, err)
+	assert.Len(t, lastUsed, 3)
+	assert.True(t, lastUsed["old.tmpl"].Equal(monthAgo))
+
+	unused, err := UnusedTemplates(ctx, registry, log, time.Now().AddDate(0, 0, -7))
+	require.NoError(t, err)
+	require.Len(t, unused, 2)
+	assert.Equal(t, UnusedTemplate{Path: "dead.tmpl"}, unused[0])
+	assert.Equal(t, "old.tmpl", unused[1].Path)
+	require.NotNil(t, unused[1].LastUsed)
+	assert.True(t, unused[1].LastUsed.Equal(monthAgo))
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	for since, expected := range map[string]time.Time{
+		"90d": time.Date(2025, 12, 31, 12, 0, 0, 0, time.UTC),
+		"2w":  time.Date(2026, 3, 17, 12, 0, 0, 0, time.UTC),
+		"36h": time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC),
+	} {
+		parsed, err := ParseSince(since, now)
+		require.NoError(t, err, since)
+		assert.True(t, expected.Equal(parsed), "%s: %s", since, parsed)
+	}
+	parsed, err := ParseSince("2026-01-31", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.January, parsed.Month())
+
+	_, err = ParseSince("3 months", now)
+	assert.ErrorContains(t, err, `invalid time "3 months"`)
+}
+
+func TestApp_Unused(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "Hello [[.name]]")
+	createTestFile(t, tempDir, "main.json", `{"name": "John"}`)
+	createTestFile(t, tempDir, "dead.tmpl", "Dead")
+	logPath := filepath.Join(t.TempDir(), "usage.jsonl")
+	app, stdout, _ := newTestApp(t, WithRegistry(NewInMemPromptRegistry(tempDir)))
+	ctx := context.Background()
+	run := func(args ...string) error {
+		stdout.Reset()
+		return app.Command().Run(ctx, append([]string{"rprompt"}, args...))
+	}
+
+	assert.ErrorContains(t, run("unused"), "no usage log")
+	require.NoError(t, run("--usage-log", logPath, "generate", "-t", "main.tmpl", "-c", "main.json", "-o", filepath.Join(t.TempDir(), "out.txt")))
+
+	require.NoError(t, run("--json", "--usage-log", logPath, "unused", "--since", "30d"))
+	var report struct {
+		Templates []UnusedTemplate `json:"templates"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.Equal(t, []UnusedTemplate{{Path: "dead.tmpl"}}, report.Templates)
+
+	require.NoError(t, run("--usage-log", logPath, "unused"))
+	assert.Equal(t, "dead.tmpl\tnever rendered\n", stdout.String())
+}
@@ -0,0 +1,131 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupValidationSystem(t *testing.T) *PromptSystem {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "test.tmpl", `Hello [[.user.name]] from [[.site]]`)
+	createTestFile(t, tempDir, "complete.json", `{"user": {"name": "John"}, "site": "x", "extra": 1}`)
+	createTestFile(t, tempDir, "nested.json", `{"user": {}, "site": "x"}`)
+	createTestFile(t, tempDir, "mismatch.json", `{"user": "John", "site": "x"}`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+	return system
+}
+
+func TestParseValidationMode(t *testing.T) {
+	for _, mode := range []ValidationMode{ValidationDefault, ValidationOff, ValidationWarn, ValidationStrict} {
+		parsed, err := ParseValidationMode(mode.String())
+		require.NoError(t, err)
+		assert.Equal(t, mode, parsed)
+	}
+	_, err := ParseValidationMode("loud")
+	assert.Error(t, err)
+}
+
+func TestValidation_Default(t *testing.T) {
+	system := setupValidationSystem(t)
+	ctx := context.Background()
+
+	// Nested gaps are reported but don't fail the build
+	result, err := system.BuildWithResult(ctx, "test.tmpl", "nested.json")
+	require.NoError(t, err)
+	assert.Equal(t, []ValidationIssue{{
+		Kind:    IssueMissing,
+		Path:    "user.name",
+		Message: "user.name is used by the template but missing from the config",
+	}}, result.Issues)
+
+	result, err = system.BuildWithResult(ctx, "test.tmpl", "complete.json")
+	require.NoError(t, err)
+	require.Len(t, result.Issues, 1)
+	assert.Equal(t, IssueUnused, result.Issues[0].Kind)
+	assert.Equal(t, "extra", result.Issues[0].Path)
+}
+
+func TestValidation_Off(t *testing.T) {
+	system := setupValidationSystem(t)
+	system.Validation = ValidationOff
+
+	result, err := system.BuildWithResult(context.Background(), "test.tmpl", "", WithValue("site", "x"))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello <no value> from x", result.Output)
+	assert.Empty(t, result.Issues)
+}
+
+func TestValidation_Warn(t *testing.T) {
+	system := setupValidationSystem(t)
+	system.Validation = ValidationWarn
+
+	result, err := system.BuildWithResult(context.Background(), "test.tmpl", "complete.json")
+	require.NoError(t, err)
+	require.Len(t, result.Issues, 1)
+	assert.Equal(t, IssueUnused, result.Issues[0].Kind)
+
+	// Top-level fields missing is only a warning
+	_, err = system.BuildWithResult(context.Background(), "test.tmpl", "")
+	assert.NoError(t, err)
+}
+
+func TestValidation_Strict(t *testing.T) {
+	system := setupValidationSystem(t)
+	system.Validation = ValidationStrict
+	ctx := context.Background()
+
+	_, err := system.BuildWithResult(ctx, "test.tmpl", "complete.json")
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "extra", validationErr.Issues[0].Path)
+
+	// Type mismatches are caught before the template fails to execute
+	_, err = system.BuildWithResult(ctx, "test.tmpl", "mismatch.json")
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []ValidationIssue{{
+		Kind:    IssueTypeMismatch,
+		Path:    "user",
+		Message: "user should be an object but is string",
+	}}, validationErr.Issues)
+
+	// Builders validate with the same mode
+	builder, err := system.NewBuilder(ctx, "test.tmpl", "nested.json")
+	require.NoError(t, err)
+	_, err = builder.Build(ctx)
+	assert.ErrorAs(t, err, &validationErr)
+
+	// A per-build override wins over the system's mode
+	result, err := system.BuildWithResult(ctx, "test.tmpl", "complete.json", WithValidation(ValidationWarn))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello John from x", result.Output)
+}
+
+func TestMissingFieldsError(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `[[template "header.tmpl" .]][[.user.profile.name]] [[.user.id]] [[.topic]]`)
+	createTestFile(t, tempDir, "header.tmpl", `[[.title]] for [[.user.profile.name]]`)
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+
+	_, err = system.Build(context.Background(), "main.tmpl", "", WithValue("topic", "x"))
+	var missingErr *MissingFieldsError
+	require.ErrorAs(t, err, &missingErr)
+	assert.Equal(t, []string{"title", "user.id", "user.profile.name"}, missingErr.MissingFields)
+	assert.Equal(t, map[string][]string{
+		"main.tmpl":   {"user.id", "user.profile.name"},
+		"header.tmpl": {"title", "user.profile.name"},
+	}, missingErr.Templates)
+	assert.Equal(t, "missing config values:\n  header.tmpl: title, user.profile.name\n  main.tmpl: user.id, user.profile.name\n", err.Error())
+
+	data, err := json.Marshal(missingErr)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"missing_fields": ["title", "user.id", "user.profile.name"],
+		"templates": {"header.tmpl": ["title", "user.profile.name"], "main.tmpl": ["user.id", "user.profile.name"]}
+	}`, string(data))
+}
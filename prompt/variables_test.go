@@ -0,0 +1,39 @@
+package prompt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate_Variables(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", `---
+owners: [ada]
+---
+Hello [[.user.name]]
+[[if .vip]]Thanks, [[.user.name]][[end]]
+[[range .items]]- [[.title]] [[if gt .price 10]]![[end]]
+[[end]][[template "footer.tmpl" .]]`)
+	createTestFile(t, tempDir, "footer.tmpl", `[[with .signature]][[.text]][[end]]`)
+	registry := NewInMemPromptRegistry(tempDir)
+	template, err := registry.Find(context.Background(), "main.tmpl")
+	require.NoError(t, err)
+
+	variables, err := template.Variables(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []VariableInfo{
+		{Path: "items", Type: "array", Uses: []VariableUse{{Template: "main.tmpl", Line: 6, Column: 9}}},
+		{Path: "items[].price", Type: "number", Conditional: true, Uses: []VariableUse{{Template: "main.tmpl", Line: 6, Column: 38, Conditional: true}}},
+		{Path: "items[].title", Type: "string", Uses: []VariableUse{{Template: "main.tmpl", Line: 6, Column: 21}}},
+		{Path: "signature", Type: "object", Conditional: true, Uses: []VariableUse{{Template: "footer.tmpl", Line: 1, Column: 8, Conditional: true}}},
+		{Path: "signature.text", Type: "string", Conditional: true, Uses: []VariableUse{{Template: "footer.tmpl", Line: 1, Column: 22, Conditional: true}}},
+		{Path: "user.name", Type: "string", Uses: []VariableUse{
+			{Template: "main.tmpl", Line: 4, Column: 9},
+			{Template: "main.tmpl", Line: 5, Column: 22, Conditional: true},
+		}},
+		{Path: "vip", Type: "boolean", Conditional: true, Uses: []VariableUse{{Template: "main.tmpl", Line: 5, Column: 6, Conditional: true}}},
+	}, variables)
+}
@@ -0,0 +1,49 @@
+package prompt
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildWarnings(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", "---\ndeprecated:\n  user.nick: use user.name\n  legacy: \"\"\n---\nHi [[.user.name]] [[.topic]]")
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+
+	result, err := system.BuildWithResult(context.Background(), "main.tmpl", "", WithData(map[string]any{
+		"user":   map[string]any{"nick": "jd"},
+		"topic":  "x",
+		"legacy": true,
+		"extra":  1,
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "Hi <no value> x", result.Output)
+	assert.Equal(t, []Warning{
+		{Kind: WarningUnusedKey, Path: "extra", Message: "extra is set in the config but not used by the template"},
+		{Kind: WarningUnusedKey, Path: "legacy", Message: "legacy is set in the config but not used by the template"},
+		{Kind: WarningUnusedKey, Path: "user.nick", Message: "user.nick is set in the config but not used by the template"},
+		{Kind: WarningNoValue, Path: "user.name", Message: "user.name is missing from the config and may render as <no value>"},
+		{Kind: WarningDeprecated, Path: "legacy", Message: "legacy is deprecated"},
+		{Kind: WarningDeprecated, Path: "user.nick", Message: "user.nick is deprecated: use user.name"},
+	}, result.Warnings)
+}
+
+func TestBuildWarnings_NearTokenLimit(t *testing.T) {
+	tempDir := setupTempDir(t)
+	createTestFile(t, tempDir, "main.tmpl", strings.Repeat("a", 40))
+	system, err := NewPromptSystem(NewInMemPromptRegistry(tempDir))
+	require.NoError(t, err)
+
+	result, err := system.BuildWithResult(context.Background(), "main.tmpl", "", WithTokenBudget(10, nil))
+	require.NoError(t, err)
+	assert.Equal(t, []Warning{{Kind: WarningNearTokenLimit, Message: "the prompt is 10 tokens, 100% of the budget of 10"}}, result.Warnings)
+
+	result, err = system.BuildWithResult(context.Background(), "main.tmpl", "", WithTokenBudget(100, nil))
+	require.NoError(t, err)
+	assert.Empty(t, result.Warnings)
+}
@@ -3,6 +3,8 @@ package utils
 import (
 	"errors"
 	"reflect"
+	"sort"
+	"strings"
 )
 
 // MergeAsSet merges two maps treating them as sets, where only keys matter
@@ -49,6 +51,24 @@ func MergeAsSet[K comparable, V any](this, other map[K]V) (map[K]V, error) {
 	return result, nil
 }
 
+// MergeInto merges src into dst in place with the same set semantics as MergeAsSet: the value already in dst
+// wins, and nested map[string]any values are merged recursively. Maps taken from src become part of dst, so
+// src must not be used afterwards. It avoids MergeAsSet's copies when accumulating many small maps into one.
+func MergeInto(dst, src map[string]any) {
+	for k, v := range src {
+		existing, exists := dst[k]
+		if !exists {
+			dst[k] = v
+			continue
+		}
+		existingMap, existingIsMap := existing.(map[string]any)
+		srcMap, srcIsMap := v.(map[string]any)
+		if existingIsMap && srcIsMap {
+			MergeInto(existingMap, srcMap)
+		}
+	}
+}
+
 // tryMergeNestedMaps attempts to merge two values if they are both maps.
 // Returns the merged result and a boolean indicating whether a merge was performed.
 func tryMergeNestedMaps(val1, val2 interface{}) (interface{}, bool) {
@@ -111,3 +131,166 @@ func UniqueString(items []string) []string {
 
 	return result
 }
+
+// FlattenKeys returns the dotted paths of every leaf in a nested map, sorted.
+// A nested map with no keys is treated as a leaf.
+func FlattenKeys(data map[string]any) []string {
+	keys := []string{}
+	flattenKeys(data, "", &keys)
+	sort.Strings(keys)
+	return keys
+}
+
+func flattenKeys(data map[string]any, prefix string, keys *[]string) {
+	for k, v := range data {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		if nested, ok := v.(map[string]any); ok && len(nested) > 0 {
+			flattenKeys(nested, path, keys)
+			continue
+		}
+		*keys = append(*keys, path)
+	}
+}
+
+// DeepMerge returns a new map with the values of override layered over base. Unlike MergeAsSet, values from
+// override win; nested map[string]any values are merged recursively. Neither input is modified.
+func DeepMerge(base, override map[string]any) map[string]any {
+	result := make(map[string]any, len(base)+len(override))
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range override {
+		baseMap, baseIsMap := result[k].(map[string]any)
+		overrideMap, overrideIsMap := v.(map[string]any)
+		if baseIsMap && overrideIsMap {
+			result[k] = DeepMerge(baseMap, overrideMap)
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// FillMissing returns a copy of existing with the values of skeleton it lacks added, for filling a config in
+// from the structure a template requires. Values already in existing are kept whatever their type; nested
+// map[string]any values are filled recursively, and every object in an existing []any is filled from the first
+// element of skeleton's array at the same key. An empty string or nil in existing is a placeholder, so it gives
+// way to an object or array in skeleton. Neither input is modified.
+func FillMissing(existing, skeleton map[string]any) map[string]any {
+	return fillValue(existing, skeleton).(map[string]any)
+}
+
+func fillValue(existing, skeleton any) any {
+	switch s := skeleton.(type) {
+	case map[string]any:
+		e, ok := existing.(map[string]any)
+		if !ok {
+			if !isPlaceholder(existing) {
+				return existing
+			}
+			e = map[string]any{}
+		}
+		result := make(map[string]any, len(e)+len(s))
+		for k, v := range e {
+			result[k] = v
+		}
+		for k, v := range s {
+			if current, exists := result[k]; exists {
+				result[k] = fillValue(current, v)
+			} else {
+				result[k] = fillValue(nil, v)
+			}
+		}
+		return result
+	case []any:
+		e, ok := existing.([]any)
+		if !ok {
+			if !isPlaceholder(existing) {
+				return existing
+			}
+			e = make([]any, len(s))
+			for i := range s {
+				e[i] = fillValue(nil, s[i])
+			}
+			return e
+		}
+		if len(s) == 0 {
+			return e
+		}
+		item, ok := s[0].(map[string]any)
+		if !ok {
+			return e
+		}
+		result := make([]any, len(e))
+		for i, v := range e {
+			if _, isMap := v.(map[string]any); isMap {
+				v = fillValue(v, item)
+			}
+			result[i] = v
+		}
+		return result
+	default:
+		if existing == nil {
+			return skeleton
+		}
+		return existing
+	}
+}
+
+// isPlaceholder reports whether v is an empty string or nil, the values a generated config leaves to be filled in
+func isPlaceholder(v any) bool {
+	return v == nil || v == ""
+}
+
+// SetPath sets the value at a dotted path such as "user.profile.name", creating intermediate maps as needed
+// and replacing any non-map value found along the way
+func SetPath(data map[string]any, path string, value any) {
+	keys := strings.Split(path, ".")
+	current := data
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			current[key] = next
+		}
+		current = next
+	}
+	current[keys[len(keys)-1]] = value
+}
+
+// GetPath returns the value at a dotted path such as "user.profile.name", and whether every key along the
+// path exists
+func GetPath(data map[string]any, path string) (any, bool) {
+	keys := strings.Split(path, ".")
+	current := data
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current = next
+	}
+	value, ok := current[keys[len(keys)-1]]
+	return value, ok
+}
+
+// WithoutPath returns a copy of data without the value at a dotted path such as "user.profile.name". Only the
+// maps along the path are copied; data itself is left untouched.
+func WithoutPath(data map[string]any, path string) map[string]any {
+	key, rest, nested := strings.Cut(path, ".")
+	result := make(map[string]any, len(data))
+	for k, v := range data {
+		result[k] = v
+	}
+	if !nested {
+		delete(result, key)
+		return result
+	}
+	if next, ok := data[key].(map[string]any); ok {
+		result[key] = WithoutPath(next, rest)
+	}
+	return result
+}
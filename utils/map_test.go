@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"encoding/json"
 	"reflect"
+	"strconv"
 	"testing"
 )
 
@@ -305,3 +307,292 @@ func TestMergeAsSetPerformance(t *testing.T) {
 		}
 	}
 }
+
+// TestMergeInto tests that MergeInto keeps existing values and merges nested maps in place
+func TestMergeInto(t *testing.T) {
+	dst := map[string]any{
+		"name": "",
+		"user": map[string]any{
+			"email": "",
+		},
+		"leaf": "",
+	}
+	src := map[string]any{
+		"name": map[string]any{"first": ""},
+		"user": map[string]any{
+			"email":   map[string]any{"domain": ""},
+			"profile": map[string]any{"bio": ""},
+		},
+		"leaf":  "",
+		"added": "",
+	}
+
+	expected := map[string]any{
+		"name": "",
+		"user": map[string]any{
+			"email":   "",
+			"profile": map[string]any{"bio": ""},
+		},
+		"leaf":  "",
+		"added": "",
+	}
+
+	MergeInto(dst, src)
+	if !reflect.DeepEqual(dst, expected) {
+		t.Errorf("MergeInto() = %v, want %v", dst, expected)
+	}
+
+	// It must agree with MergeAsSet
+	merged, err := MergeAsSet(map[string]any{"a": map[string]any{"b": ""}, "c": ""}, map[string]any{"a": map[string]any{"d": ""}, "c": map[string]any{}})
+	if err != nil {
+		t.Fatalf("MergeAsSet() returned an error: %v", err)
+	}
+	inPlace := map[string]any{"a": map[string]any{"b": ""}, "c": ""}
+	MergeInto(inPlace, map[string]any{"a": map[string]any{"d": ""}, "c": map[string]any{}})
+	if !reflect.DeepEqual(inPlace, merged) {
+		t.Errorf("MergeInto() = %v, MergeAsSet() = %v", inPlace, merged)
+	}
+}
+
+// benchmarkMaps returns n small nested maps like the ones extracted from template actions
+func benchmarkMaps(n int) []map[string]any {
+	maps := make([]map[string]any, n)
+	for i := range maps {
+		maps[i] = map[string]any{
+			"user":                  map[string]any{"field" + strconv.Itoa(i): ""},
+			"var" + strconv.Itoa(i): "",
+		}
+	}
+	return maps
+}
+
+func BenchmarkMergeAsSet(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data := make(map[string]any)
+		for _, m := range benchmarkMaps(1000) {
+			if result, err := MergeAsSet(data, m); err == nil {
+				data = result
+			}
+		}
+	}
+}
+
+func BenchmarkMergeInto(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data := make(map[string]any)
+		for _, m := range benchmarkMaps(1000) {
+			MergeInto(data, m)
+		}
+	}
+}
+
+// TestFlattenKeys tests that nested maps are flattened into sorted dotted paths
+func TestFlattenKeys(t *testing.T) {
+	data := map[string]any{
+		"name": "",
+		"user": map[string]any{
+			"profile": map[string]any{
+				"email": "",
+			},
+			"role": "",
+		},
+		"empty": map[string]any{},
+		"items": []any{"a", "b"},
+	}
+
+	expected := []string{"empty", "items", "name", "user.profile.email", "user.role"}
+	if result := FlattenKeys(data); !reflect.DeepEqual(result, expected) {
+		t.Errorf("FlattenKeys() = %v, want %v", result, expected)
+	}
+
+	if result := FlattenKeys(nil); len(result) != 0 {
+		t.Errorf("FlattenKeys(nil) = %v, want empty", result)
+	}
+}
+
+// TestDeepMerge tests that override values win and nested maps are merged
+func TestDeepMerge(t *testing.T) {
+	base := map[string]any{
+		"name": "John",
+		"user": map[string]any{
+			"email": "john@example.com",
+			"role":  "admin",
+		},
+		"tags": []any{"a"},
+	}
+	override := map[string]any{
+		"name": "Jane",
+		"user": map[string]any{
+			"role": "viewer",
+		},
+		"tags": []any{"b"},
+		"new":  true,
+	}
+
+	expected := map[string]any{
+		"name": "Jane",
+		"user": map[string]any{
+			"email": "john@example.com",
+			"role":  "viewer",
+		},
+		"tags": []any{"b"},
+		"new":  true,
+	}
+
+	result := DeepMerge(base, override)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("DeepMerge() = %v, want %v", result, expected)
+	}
+
+	// Inputs must not be modified
+	if base["name"] != "John" || base["user"].(map[string]any)["role"] != "admin" {
+		t.Errorf("DeepMerge() modified its base map: %v", base)
+	}
+}
+
+func TestFillMissing(t *testing.T) {
+	existing := map[string]any{
+		"count": 3.0,
+		"user":  map[string]any{"name": "Ada"},
+		"items": []any{map[string]any{"title": "a"}, "b"},
+		"bio":   "",
+		"meta":  "kept",
+	}
+	skeleton := map[string]any{
+		"count": "",
+		"user":  map[string]any{"name": "", "email": ""},
+		"items": []any{map[string]any{"title": "", "url": ""}},
+		"bio":   map[string]any{"text": ""},
+		"meta":  map[string]any{"a": ""},
+		"new":   []any{map[string]any{"id": ""}},
+	}
+
+	expected := map[string]any{
+		"count": 3.0,
+		"user":  map[string]any{"name": "Ada", "email": ""},
+		"items": []any{map[string]any{"title": "a", "url": ""}, "b"},
+		"bio":   map[string]any{"text": ""},
+		"meta":  "kept",
+		"new":   []any{map[string]any{"id": ""}},
+	}
+
+	result := FillMissing(existing, skeleton)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("FillMissing() = %v, want %v", result, expected)
+	}
+
+	// Inputs must not be modified
+	if _, ok := existing["user"].(map[string]any)["email"]; ok {
+		t.Errorf("FillMissing() modified its existing map: %v", existing)
+	}
+	if _, ok := existing["items"].([]any)[0].(map[string]any)["url"]; ok {
+		t.Errorf("FillMissing() modified an item of its existing map: %v", existing)
+	}
+}
+
+// TestSetPath tests setting values at dotted paths
+func TestSetPath(t *testing.T) {
+	data := map[string]any{
+		"user":   map[string]any{"email": "john@example.com"},
+		"scalar": "value",
+	}
+
+	SetPath(data, "user.name", "John")
+	SetPath(data, "scalar.nested", 1)
+	SetPath(data, "top", true)
+	SetPath(data, "a.b.c", "deep")
+
+	expected := map[string]any{
+		"user":   map[string]any{"email": "john@example.com", "name": "John"},
+		"scalar": map[string]any{"nested": 1},
+		"top":    true,
+		"a":      map[string]any{"b": map[string]any{"c": "deep"}},
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("SetPath() = %v, want %v", data, expected)
+	}
+}
+
+// TestGetPath tests reading values at dotted paths
+func TestGetPath(t *testing.T) {
+	data := map[string]any{
+		"user":   map[string]any{"name": "John", "empty": nil},
+		"scalar": "value",
+	}
+
+	tests := []struct {
+		path  string
+		value any
+		ok    bool
+	}{
+		{"user.name", "John", true},
+		{"user.empty", nil, true},
+		{"scalar", "value", true},
+		{"user.missing", nil, false},
+		{"scalar.nested", nil, false},
+		{"missing.name", nil, false},
+	}
+	for _, tt := range tests {
+		value, ok := GetPath(data, tt.path)
+		if value != tt.value || ok != tt.ok {
+			t.Errorf("GetPath(%q) = %v, %v, want %v, %v", tt.path, value, ok, tt.value, tt.ok)
+		}
+	}
+}
+
+// TestWithoutPath tests removing values at dotted paths without modifying the input
+func TestWithoutPath(t *testing.T) {
+	data := map[string]any{
+		"user":   map[string]any{"name": "John", "email": "john@example.com"},
+		"scalar": "value",
+	}
+
+	tests := []struct {
+		path     string
+		expected map[string]any
+	}{
+		{"user.name", map[string]any{"user": map[string]any{"email": "john@example.com"}, "scalar": "value"}},
+		{"scalar", map[string]any{"user": data["user"]}},
+		{"scalar.nested", data},
+		{"missing.name", data},
+	}
+	for _, tt := range tests {
+		if got := WithoutPath(data, tt.path); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("WithoutPath(%q) = %v, want %v", tt.path, got, tt.expected)
+		}
+	}
+	if _, ok := GetPath(data, "user.name"); !ok {
+		t.Error("WithoutPath() modified its input")
+	}
+}
+
+// FuzzMergeAsSet checks that merging arbitrary JSON objects never panics and that MergeInto agrees with
+// MergeAsSet
+func FuzzMergeAsSet(f *testing.F) {
+	f.Add(`{"a": "", "b": {"c": ""}}`, `{"a": {"x": ""}, "b": {"d": [1, 2]}}`)
+	f.Add(`{"a": null}`, `{"a": {"b": {"c": {}}}}`)
+	f.Fuzz(func(t *testing.T, this, other string) {
+		var thisMap, otherMap map[string]any
+		if json.Unmarshal([]byte(this), &thisMap) != nil || json.Unmarshal([]byte(other), &otherMap) != nil {
+			return
+		}
+		if thisMap == nil || otherMap == nil {
+			return
+		}
+		merged, err := MergeAsSet(thisMap, otherMap)
+		if err != nil {
+			t.Fatalf("MergeAsSet() returned an error: %v", err)
+		}
+		// MergeInto takes over the maps it is given, so it gets its own copies
+		var dst, src map[string]any
+		json.Unmarshal([]byte(this), &dst)
+		json.Unmarshal([]byte(other), &src)
+		MergeInto(dst, src)
+		if !reflect.DeepEqual(dst, merged) {
+			t.Errorf("MergeInto() = %v, MergeAsSet() = %v", dst, merged)
+		}
+		DeepMerge(thisMap, otherMap)
+	})
+}